@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// BalanceRecord is the balances of a wallet in the machine-readable output formats, by asset symbol.
+type BalanceRecord struct {
+	Wallet   string            `json:"wallet" yaml:"wallet"`
+	Name     string            `json:"name" yaml:"name"`
+	ENS      string            `json:"ens,omitempty" yaml:"ens,omitempty"`
+	Balances map[string]string `json:"balances" yaml:"balances"`
+	Errors   map[string]string `json:"errors,omitempty" yaml:"errors,omitempty"`
+	// Values are the balances in the fiat Currency, Total is their sum.
+	Currency string            `json:"currency,omitempty" yaml:"currency,omitempty"`
+	Values   map[string]string `json:"values,omitempty" yaml:"values,omitempty"`
+	Total    string            `json:"total,omitempty" yaml:"total,omitempty"`
+}
+
+// balanceCell is the balance in token units, or in the smallest units if raw.
+func balanceCell(row *executor.BalanceRow, i int, raw bool) string {
+	if row.Errors[i] != nil {
+		return ""
+	} else if raw {
+		return row.Balances[i].String()
+	}
+	return row.Amounts[i]
+}
+
+// writeBalances writes the balance matrix in the format to the output file, or to stdout if not set.
+// The valued matrix has the totals of the wallets in the fiat currency, and their sum in the text format.
+func writeBalances(format, path string, matrix *executor.BalanceMatrix, raw bool) error {
+	w, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	switch format {
+	case OutputText:
+		return writeBalanceTable(w, matrix, raw)
+	case OutputCSV:
+		csvWriter := csv.NewWriter(w)
+		header := append([]string{"wallet", "name", "ens"}, matrix.Assets...)
+		if len(matrix.Currency) > 0 {
+			header = append(header, matrix.Currency)
+		}
+		if err := csvWriter.Write(header); err != nil {
+			return err
+		}
+		for _, row := range matrix.Rows {
+			record := []string{row.Wallet, row.Name, row.ENS}
+			for i := range matrix.Assets {
+				record = append(record, balanceCell(row, i, raw))
+			}
+			if len(matrix.Currency) > 0 {
+				record = append(record, model.FormatFiat(row.Total))
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return writeStructured(w, format, balanceRecords(matrix, raw))
+}
+
+// balanceRecords returns the rows of the balance matrix as records by asset symbol.
+func balanceRecords(matrix *executor.BalanceMatrix, raw bool) []*BalanceRecord {
+	records := make([]*BalanceRecord, 0, len(matrix.Rows))
+	for _, row := range matrix.Rows {
+		record := &BalanceRecord{
+			Wallet:   row.Wallet,
+			Name:     row.Name,
+			ENS:      row.ENS,
+			Balances: make(map[string]string, len(matrix.Assets)),
+		}
+		if len(matrix.Currency) > 0 {
+			record.Currency = matrix.Currency
+			record.Values = make(map[string]string, len(matrix.Assets))
+			record.Total = model.FormatFiat(row.Total)
+		}
+		for i, asset := range matrix.Assets {
+			if row.Errors[i] != nil {
+				if record.Errors == nil {
+					record.Errors = make(map[string]string)
+				}
+				record.Errors[asset] = row.Errors[i].Error()
+				continue
+			}
+			record.Balances[asset] = balanceCell(row, i, raw)
+			if len(matrix.Currency) > 0 && row.Values[i] != nil {
+				record.Values[asset] = model.FormatFiat(row.Values[i])
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// writeBalanceTable writes the balance matrix as a table with the errors below it.
+func writeBalanceTable(w io.Writer, matrix *executor.BalanceMatrix, raw bool) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "WALLET\t")
+	for _, asset := range matrix.Assets {
+		fmt.Fprintf(tw, "%s\t", asset)
+	}
+	if len(matrix.Currency) > 0 {
+		fmt.Fprintf(tw, "%s\t", strings.ToUpper(matrix.Currency))
+	}
+	fmt.Fprintln(tw)
+	total := new(big.Rat)
+	for _, row := range matrix.Rows {
+		if len(row.ENS) > 0 {
+			fmt.Fprintf(tw, "@%s (%s)\t", row.Name, row.ENS)
+		} else {
+			fmt.Fprintf(tw, "@%s\t", row.Name)
+		}
+		for i := range matrix.Assets {
+			cell := balanceCell(row, i, raw)
+			if row.Errors[i] != nil {
+				cell = "error"
+			}
+			fmt.Fprintf(tw, "%s\t", cell)
+		}
+		if len(matrix.Currency) > 0 {
+			fmt.Fprintf(tw, "%s\t", model.FormatFiat(row.Total))
+			total.Add(total, row.Total)
+		}
+		fmt.Fprintln(tw)
+	}
+	if len(matrix.Currency) > 0 {
+		fmt.Fprintf(tw, "TOTAL\t%s%s\t\n", strings.Repeat("\t", len(matrix.Assets)), model.FormatFiat(total))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, row := range matrix.Rows {
+		for i, err := range row.Errors {
+			if err != nil {
+				fmt.Fprintf(w, "@%s %s: %v\n", row.Name, matrix.Assets[i], err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,1886 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	cli "github.com/jawher/mow.cli"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// registerBuiltins adds commands that are provided by the playbook itself,
+// spec commands and targets with the same name take precedence.
+func registerBuiltins(app *cli.Cli, spec *model.Spec) {
+	builtin := func(name, desc string, init cli.CmdInitializer) {
+		if _, ok := spec.Targets[name]; ok || spec.HasCommand(name) {
+			log.WithField("command", name).Debugln("builtin command is shadowed by the spec")
+			return
+		}
+		app.Command(name, desc, init)
+		builtinNames = append(builtinNames, name)
+	}
+	builtin("decode", "Decode calldata or a transaction input using the spec ABIs", newDecode(spec))
+	builtin("storage-read", "Read a raw contract storage slot", newStorageRead(spec))
+	builtin("storage-write", "Overwrite a contract storage slot on a dev node", newStorageWrite(spec))
+	builtin("trace", "Trace a transaction or a call, printing the decoded call tree", newTrace(spec))
+	builtin("logs", "Query the decoded logs of a contract event over a block range, filtered by its args", newLogs(spec))
+	builtin("scan", "Run the extractors of a scan over the block range, resuming from its cursor", newScan(spec))
+	builtin("monitor", "Watch the events of the spec contracts, alerting on the ones matching the monitor rules", newMonitor(spec))
+	builtin("history", "Export the transfers and fees of the matching wallets as CSV for accounting", newHistory(spec))
+	builtin("reconcile", "Match the transfers of the wallets against a ledger of expected ones, reporting the unmatched", newReconcile(spec))
+	builtin("snapshot", "Reconstruct the holders of a token or an ERC-721 collection at a block from its transfer logs", newSnapshot(spec))
+	builtin("balances", "Print the balances of all wallets in ether and every token", newBalances(spec))
+	builtin("wrap", "Wrap ether of a wallet into the wrapped native token of the chain", newWrap(spec, false))
+	builtin("unwrap", "Unwrap the wrapped native token of a wallet into ether", newWrap(spec, true))
+	builtin("swap", "Swap ether or tokens of a wallet through the aggregator, checked against the price feeds", newSwap(spec))
+	builtin("airdrop", "Send ether or a token to the recipients of a CSV file, resumably", newAirdrop(spec))
+	builtin("merkle", "Build the Merkle distributor tree of a CSV file, exporting the claim proofs", newMerkle(spec))
+	builtin("sweep", "Move the ether and token balances of the matching wallets to a destination", newSweep(spec))
+	builtin("fund", "Top up the wallets below the min balances of the fund rules, within their caps", newFund(spec))
+	builtin("vesting", "Print the vesting schedules with the released, releasable and locked amounts and the next unlocks", newVesting(spec))
+	builtin("release", "Claim the vested amounts of the vesting schedules to their wallets", newRelease(spec))
+	builtin("staking", "Print the validators and liquid staking tokens of the stakes with the ether of their wallets", newStaking(spec))
+	builtin("nfts", "List the tokens of an NFT collection owned by a wallet", newNFTs(spec))
+	builtin("allowances", "List the token allowances of the matching wallets, revoking the ones not allowed", newAllowances(spec))
+	builtin("ens", "Register ENS names and set their resolver, records and owner", newENS(spec))
+	builtin("find-block", "Find the first block where a view result crosses a threshold", newFindBlock(spec))
+	builtin("proof", "Fetch and verify Merkle proofs of an account and its storage slots", newProof(spec))
+	builtin("schema", "Print the JSON Schema of the spec format", newSchema())
+	builtin("diff", "Check the views against their expectations, reporting the drift", newDiff(spec))
+	builtin("validate", "Validate the spec, --strict rejects unknown keys and wrong types", newValidate(spec))
+	builtin("lint", "Check the spec for risky patterns", newLint(spec))
+	builtin("migrate", migrateDesc, newMigrate())
+	builtin("approve", "Print the approval tokens of a reviewed run of commands requiring confirmation", newApprove(spec))
+	builtin("list", "List the targets, commands and wallets of the spec", newList(spec))
+	builtin("console", "Run commands and evaluate expressions interactively", newConsole())
+	builtin("completion", "Print the shell completion script for bash, zsh or fish", newCompletion())
+}
+
+const migrateDesc = "Rewrite the spec file to the current format version"
+
+// isSpecOutdated checks whether the spec file is of an older format version, unreadable
+// and broken specs are reported by the spec loading.
+func isSpecOutdated() bool {
+	data, err := ioutil.ReadFile(*specPath)
+	if err != nil {
+		return false
+	}
+	version, err := model.ParseSpecVersion(data)
+	return err == nil && version < model.SpecVersion
+}
+
+func newMigrate() cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		dryRun := cmd.BoolOpt("dry-run", false, "Print the migrated spec instead of rewriting the file")
+		cmd.Action = func() {
+			migrateLog := log.WithField("filename", *specPath)
+			data, err := ioutil.ReadFile(*specPath)
+			if err != nil {
+				migrateLog.WithError(err).Fatalln("failed to load spec file")
+			} else if model.IsSopsEncrypted(data) {
+				migrateLog.Fatalln("spec is encrypted by sops, migrate the decrypted file with sops edit")
+			}
+			migrated, migrations, err := model.MigrateSpec(data)
+			if err != nil {
+				migrateLog.WithError(err).Fatalln("failed to migrate spec")
+			} else if len(migrations) == 0 {
+				migrateLog.Infoln("spec format is up to date")
+				return
+			}
+			for _, migration := range migrations {
+				migrateLog.WithField("migration", migration).Infoln("applied")
+			}
+			if *dryRun {
+				fmt.Print(string(migrated))
+				return
+			}
+			info, err := os.Stat(*specPath)
+			if err != nil {
+				migrateLog.WithError(err).Fatalln("failed to stat spec file")
+			}
+			if err := ioutil.WriteFile(*specPath, migrated, info.Mode()); err != nil {
+				migrateLog.WithError(err).Fatalln("failed to write spec file")
+			}
+			migrateLog.WithField("version", model.SpecVersion).Infoln("spec migrated")
+		}
+	}
+}
+
+func newLint(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		rules := cmd.BoolOpt("rules", false, "List the lint rules")
+		fix := cmd.BoolOpt("fix", false, "Rewrite the unchecksummed addresses of the spec file in the EIP-55 form")
+		cmd.Action = func() {
+			if *fix {
+				fixChecksums()
+				return
+			}
+			if *rules {
+				for _, rule := range model.LintRules {
+					fmt.Printf("%s  %-26s %-8s %s\n", rule.ID, rule.Name, rule.Severity, rule.Desc)
+				}
+				return
+			}
+			issues, err := spec.LintIssues()
+			if err != nil {
+				log.WithError(err).Fatalln("failed to lint the spec")
+			}
+			var failed bool
+			for _, issue := range issues {
+				fmt.Println(issue)
+				failed = failed || issue.Severity == model.LintError
+			}
+			if failed {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// fixChecksums rewrites the addresses of the spec file in the checksummed form, in place.
+func fixChecksums() {
+	fixLog := log.WithField("filename", *specPath)
+	data, err := ioutil.ReadFile(*specPath)
+	if err != nil {
+		fixLog.WithError(err).Fatalln("failed to load spec file")
+	} else if model.IsSopsEncrypted(data) {
+		fixLog.Fatalln("spec is encrypted by sops, fix the decrypted file with sops edit")
+	}
+	fixed, count := model.FixChecksums(data)
+	if count == 0 {
+		fixLog.Infoln("spec addresses are checksummed")
+		return
+	}
+	info, err := os.Stat(*specPath)
+	if err != nil {
+		fixLog.WithError(err).Fatalln("failed to stat spec file")
+	}
+	if err := ioutil.WriteFile(*specPath, fixed, info.Mode()); err != nil {
+		fixLog.WithError(err).Fatalln("failed to write spec file")
+	}
+	fixLog.WithField("addresses", count).Infoln("spec addresses checksummed")
+}
+
+func newSchema() cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Action = func() {
+			data, err := json.MarshalIndent(model.JSONSchema(), "", "  ")
+			if err != nil {
+				log.WithError(err).Fatalln("failed to marshal JSON Schema")
+			}
+			fmt.Println(string(data))
+		}
+	}
+}
+
+// newApprove prints the approval tokens signed with the approval secret, run by its holder
+// after reviewing the run, which is then repeated with --approve and the tokens.
+func newApprove(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "NAME [ARG...]"
+		name := cmd.StringArg("NAME", "", "Target or command requiring confirmation")
+		args := cmd.StringsArg("ARG", nil, "Args of the reviewed run")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, *name, append([]string{*name}, *args...))
+			cmdLog := log.WithField("command", *name)
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			tokens, err := exec.ApprovalTokens(ctx, *name)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to sign approval tokens")
+			} else if len(tokens) == 0 {
+				cmdLog.Warningln("nothing requires confirmation")
+				return
+			}
+			cmdNames := make([]string, 0, len(tokens))
+			for cmdName := range tokens {
+				cmdNames = append(cmdNames, cmdName)
+			}
+			sort.Strings(cmdNames)
+			for _, cmdName := range cmdNames {
+				fmt.Printf("%s\t%s\n", cmdName, tokens[cmdName])
+			}
+		}
+	}
+}
+
+func newValidate(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		strict := cmd.BoolOpt("strict", false, "Reject unknown keys and values of wrong types")
+		cmd.Action = func() {
+			if *strict {
+				specData, ok := readSpecData(log.WithField("filename", *specPath))
+				if !ok {
+					os.Exit(exitValidation)
+				}
+				if specErrors := model.CheckStrict(specData); len(specErrors) > 0 {
+					for _, err := range specErrors {
+						if err.Line > 0 {
+							fmt.Fprintf(os.Stderr, "%s:%v\n", *specPath, err)
+						} else {
+							fmt.Fprintf(os.Stderr, "%s: %s\n", *specPath, err.Message)
+						}
+					}
+					os.Exit(exitValidation)
+				}
+			}
+			validateSpec(spec, "", nil)
+			log.Infoln("spec validated")
+		}
+	}
+}
+
+func newDecode(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		input := cmd.StringArg("INPUT", "", "Calldata or transaction hash (hex)")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "decode", []string{"decode", *input})
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				log.WithError(err).Fatalln("failed to init executor")
+			}
+			result := exec.Decode(ctx, *input)
+			exportResultsText(spec, []*executor.CommandResult{result}, "")
+		}
+	}
+}
+
+func newProof(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--at] ADDRESS [SLOT...]"
+		at := cmd.StringOpt("at", "", "Block number, tag, or timestamp to prove the state at")
+		address := cmd.StringArg("ADDRESS", "", "Account address, wallet or contract name")
+		slots := cmd.StringsArg("SLOT", nil, "Storage slots to prove (hex or decimal)")
+		cmd.Action = func() {
+			appArgs := append([]string{"proof", *address}, *slots...)
+			ctx := validateSpec(spec, "proof", appArgs)
+			cmdLog := log.WithField("command", "proof")
+			account, err := spec.ResolveAddress(*address)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to resolve address")
+			}
+			keys := make([]common.Hash, 0, len(*slots))
+			for _, slot := range *slots {
+				key, err := model.ParseWord(slot)
+				if err != nil {
+					cmdLog.WithField("slot", slot).WithError(err).Fatalln("invalid storage slot")
+				}
+				keys = append(keys, key)
+			}
+			var block *model.BlockRef
+			if len(*at) > 0 {
+				if block, err = model.ParseBlockRef(*at); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			result := exec.Proof(ctx, account, keys, block)
+			exportResultsText(spec, []*executor.CommandResult{result}, "")
+		}
+	}
+}
+
+// slotOpts declares the slot math options, to address values of mappings, dynamic arrays and structs.
+type slotOpts struct {
+	slot   *string
+	keys   *[]string
+	index  *string
+	offset *string
+}
+
+func newSlotOpts(cmd *cli.Cmd) *slotOpts {
+	return &slotOpts{
+		keys:   cmd.StringsOpt("k key", nil, "Mapping key (address, wallet, contract or number), repeat for nested mappings"),
+		index:  cmd.StringOpt("i index", "", "Dynamic array element index, applied after mapping keys"),
+		offset: cmd.StringOpt("o offset", "", "Slot offset, applied last (e.g. a struct field)"),
+	}
+}
+
+// resolve computes the final slot from the base slot of the variable.
+func (opts *slotOpts) resolve(spec *model.Spec) (common.Hash, error) {
+	slot, err := model.ParseWord(*opts.slot)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid slot: %v", err)
+	}
+	for _, k := range *opts.keys {
+		key, err := spec.StorageKey(k)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		slot = model.MappingSlot(slot, key)
+	}
+	if len(*opts.index) > 0 {
+		index, err := model.ParseWord(*opts.index)
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("invalid index: %v", err)
+		}
+		slot = model.ArraySlot(slot, index.Big())
+	}
+	if len(*opts.offset) > 0 {
+		offset, err := model.ParseWord(*opts.offset)
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("invalid offset: %v", err)
+		}
+		slot = model.SlotOffset(slot, offset.Big())
+	}
+	return slot, nil
+}
+
+func newStorageRead(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--at] [-k...] [-i] [-o] ADDRESS SLOT"
+		at := cmd.StringOpt("at", "", "Block number, tag, or timestamp to read the state at")
+		opts := newSlotOpts(cmd)
+		address := cmd.StringArg("ADDRESS", "", "Contract address or name")
+		opts.slot = cmd.StringArg("SLOT", "", "Storage slot of the variable (hex or decimal)")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "storage-read", []string{"storage-read", *address, *opts.slot})
+			cmdLog := log.WithField("command", "storage-read")
+			account, err := spec.ResolveAddress(*address)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to resolve address")
+			}
+			slot, err := opts.resolve(spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to resolve storage slot")
+			}
+			var block *model.BlockRef
+			if len(*at) > 0 {
+				if block, err = model.ParseBlockRef(*at); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			result := exec.StorageRead(ctx, account, slot, block)
+			exportResultsText(spec, []*executor.CommandResult{result}, "")
+		}
+	}
+}
+
+func newStorageWrite(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[-k...] [-i] [-o] ADDRESS SLOT VALUE"
+		opts := newSlotOpts(cmd)
+		address := cmd.StringArg("ADDRESS", "", "Contract address or name")
+		opts.slot = cmd.StringArg("SLOT", "", "Storage slot of the variable (hex or decimal)")
+		value := cmd.StringArg("VALUE", "", "Value to store (address, wallet, contract or number)")
+		cmd.Action = func() {
+			appArgs := []string{"storage-write", *address, *opts.slot, *value}
+			ctx := validateSpec(spec, "storage-write", appArgs)
+			cmdLog := log.WithField("command", "storage-write")
+			account, err := spec.ResolveAddress(*address)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to resolve address")
+			}
+			slot, err := opts.resolve(spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to resolve storage slot")
+			}
+			word, err := spec.StorageKey(*value)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("invalid storage value")
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			result := exec.StorageWrite(ctx, account, slot, word)
+			exportResultsText(spec, []*executor.CommandResult{result}, "")
+		}
+	}
+}
+
+func newTrace(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--json] [--at] [--from] [--value] [--to] INPUT"
+		asJSON := cmd.BoolOpt("json", false, "Print the call tree as JSON")
+		at := cmd.StringOpt("at", "", "Block number, tag, or timestamp to run the call at")
+		from := cmd.StringOpt("from", "", "Sender of the call (address, wallet or contract name)")
+		value := cmd.StringOpt("value", "", "Value of the call in wei")
+		to := cmd.StringOpt("to", "", "Callee (address or contract name), INPUT is the calldata then")
+		input := cmd.StringArg("INPUT", "", "Transaction hash, or calldata (hex) when --to is set")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "trace", []string{"trace", *input})
+			cmdLog := log.WithField("command", "trace")
+			data, err := hexutil.Decode(*input)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("input must be a hex-string")
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			var result *executor.CommandResult
+			if len(*to) == 0 {
+				if len(data) != common.HashLength {
+					cmdLog.Fatalln("input must be a transaction hash, unless --to is set")
+				}
+				result = exec.TraceTransaction(ctx, common.BytesToHash(data))
+			} else {
+				msg := &executor.TraceCallMsg{
+					Data: data,
+				}
+				if msg.To, err = spec.ResolveAddress(*to); err != nil {
+					cmdLog.WithError(err).Fatalln("failed to resolve callee address")
+				}
+				if len(*from) > 0 {
+					sender, err := spec.ResolveAddress(*from)
+					if err != nil {
+						cmdLog.WithError(err).Fatalln("failed to resolve sender address")
+					}
+					msg.From = &sender
+				}
+				if len(*value) > 0 {
+					wei, err := model.ParseWord(*value)
+					if err != nil {
+						cmdLog.WithError(err).Fatalln("invalid value")
+					}
+					msg.Value = (*hexutil.Big)(wei.Big())
+				}
+				var block *model.BlockRef
+				if len(*at) > 0 {
+					if block, err = model.ParseBlockRef(*at); err != nil {
+						cmdLog.WithError(err).Fatalln("invalid block reference")
+					}
+				}
+				result = exec.TraceCall(ctx, msg, block)
+			}
+			if frame, ok := result.Result.(*executor.TraceFrame); ok && !*asJSON {
+				fmt.Print(frame.Text())
+				return
+			}
+			exportResultsText(spec, []*executor.CommandResult{result}, "")
+		}
+	}
+}
+
+func newBalances(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--raw] [--fold-weth] [--currency] [--since] [--all-networks] [--unfiltered]"
+		raw := cmd.BoolOpt("raw", false, "Print the balances in the smallest units, e.g. wei")
+		foldWrapped := cmd.BoolOpt("fold-weth", false, "Add the wrapped native token balances to the ether ones")
+		currency := cmd.StringOpt("currency", "", "Fiat currency to value the balances in, default is fiatCurrency of config")
+		since := cmd.StringOpt("since", "", "ID of a target run to report the balance deltas, fees and transactions since")
+		allNetworks := cmd.BoolOpt("all-networks", false, "Query every network of NETWORKS section, with the totals of the wallets across them")
+		unfiltered := cmd.BoolOpt("unfiltered", false, "Show the dust and the spam tokens hidden by reportFilter of config")
+		cmd.Action = func() {
+			cmdLog := log.WithField("command", "balances")
+			if *allNetworks {
+				if len(spec.Networks) == 0 {
+					cmdLog.Fatalln("no networks defined in NETWORKS section")
+				} else if len(*network) > 0 {
+					cmdLog.Fatalln("--network selects one network, it's not supported with --all-networks")
+				} else if *raw || len(*since) > 0 {
+					cmdLog.Fatalln("the balances of the networks are summed in token units, --raw and --since are not supported")
+				} else if len(*recordPath) > 0 || len(*replayPath) > 0 {
+					cmdLog.Fatalln("--record and --replay are not supported with --all-networks")
+				}
+				portfolio := readPortfolio(spec, *foldWrapped, *unfiltered, *currency)
+				if err := writePortfolio(*outputFormat, *outputFile, portfolio); err != nil {
+					cmdLog.WithError(err).Fatalln("failed to write balances")
+				}
+				return
+			}
+			ctx := validateSpec(spec, "balances", []string{"balances"})
+			if len(*since) > 0 {
+				if *foldWrapped {
+					cmdLog.Fatalln("the balances of the runs are compared unfolded, --fold-weth is not supported with --since")
+				}
+				journal, err := model.LoadRunJournal(ctx.SpecDir(), *since)
+				if err != nil {
+					cmdLog.WithError(err).WithField("run", *since).Fatalln("failed to load run journal")
+				}
+				runs, err := model.ListRunJournals(ctx.SpecDir())
+				if err != nil {
+					cmdLog.WithError(err).Fatalln("failed to list run journals")
+				}
+				exec, err := executor.New(ctx, spec)
+				if err != nil {
+					cmdLog.WithError(err).Fatalln("failed to init executor")
+				}
+				report, err := exec.BalanceDeltas(ctx, journal, runs)
+				if err != nil {
+					cmdLog.WithError(err).WithField("run", *since).Fatalln("failed to compare the balances")
+				}
+				if err := writeDeltas(*outputFormat, *outputFile, spec, report, *raw); err != nil {
+					cmdLog.WithError(err).Fatalln("failed to write balance deltas")
+				}
+				return
+			}
+			if len(*currency) > 0 {
+				if err := spec.Config.SetFiatCurrency(*currency); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid currency")
+				}
+			}
+			if *unfiltered {
+				spec.Config.ReportFilter = nil
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			matrix := exec.Balances(ctx, *foldWrapped)
+			if len(spec.Config.FiatCurrency) > 0 {
+				exec.ValueBalances(ctx, matrix)
+			}
+			if hidden := exec.FilterBalances(matrix); len(hidden) > 0 {
+				cmdLog.WithField("tokens", strings.Join(hidden, ",")).Debugln("tokens hidden by reportFilter")
+			}
+			if err := writeBalances(*outputFormat, *outputFile, matrix, *raw); err != nil {
+				cmdLog.WithError(err).Fatalln("failed to write balances")
+			}
+		}
+	}
+}
+
+func newWrap(spec *model.Spec, unwrap bool) cli.CmdInitializer {
+	name := "wrap"
+	if unwrap {
+		name = "unwrap"
+	}
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "WALLET AMOUNT"
+		wallet := cmd.StringArg("WALLET", "", "Name of the wallet")
+		amountDesc := "Amount of ether to wrap, e.g. 1.5"
+		if unwrap {
+			amountDesc = "Amount of the wrapped token to unwrap, or all of the balance"
+		}
+		amount := cmd.StringArg("AMOUNT", "", amountDesc)
+		cmd.Action = func() {
+			ctx := validateSpec(spec, name, []string{name})
+			cmdLog := log.WithField("command", name)
+			walletSpec, ok := spec.Wallets.WalletSpec(*wallet)
+			if !ok {
+				cmdLog.WithField("wallet", *wallet).Fatalln("wallet not found")
+			}
+			var value *big.Int
+			if !unwrap || *amount != "all" {
+				var err error
+				if value, err = model.ParseUnits(*amount, 18); err != nil {
+					cmdLog.WithError(err).WithField("amount", *amount).Fatalln("invalid amount")
+				} else if value.Sign() <= 0 {
+					cmdLog.WithField("amount", *amount).Fatalln("amount must be positive")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			var txHash string
+			if unwrap {
+				txHash, err = exec.Unwrap(ctx, walletSpec, value)
+			} else {
+				txHash, err = exec.Wrap(ctx, walletSpec, value)
+			}
+			if len(txHash) > 0 {
+				fmt.Println(txHash)
+			}
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to " + name)
+			}
+		}
+	}
+}
+
+func newSwap(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--quote] [--no-oracle] WALLET AMOUNT FROM TO"
+		quoteOnly := cmd.BoolOpt("quote", false, "Print the quote without swapping")
+		skipOracle := cmd.BoolOpt("no-oracle", false, "Don't check the quote against the price feeds of the assets")
+		wallet := cmd.StringArg("WALLET", "", "Name of the wallet")
+		amount := cmd.StringArg("AMOUNT", "", "Amount of the FROM asset to swap, in asset units")
+		from := cmd.StringArg("FROM", "", "ETH, or the name or symbol of a token in TOKENS section to sell")
+		to := cmd.StringArg("TO", "", "ETH, or the name or symbol of a token in TOKENS section to buy")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "swap", []string{"swap"})
+			cmdLog := log.WithField("command", "swap")
+			walletSpec, ok := spec.Wallets.WalletSpec(*wallet)
+			if !ok {
+				cmdLog.WithField("wallet", *wallet).Fatalln("wallet not found")
+			}
+			swapAsset := func(name string) *executor.SwapAsset {
+				if strings.EqualFold(name, executor.EtherAsset) {
+					return &executor.SwapAsset{
+						Symbol:    executor.EtherAsset,
+						Address:   model.NativeAssetAddress,
+						Decimals:  18,
+						PriceFeed: spec.Config.NativePriceFeed,
+					}
+				}
+				token, err := spec.FindToken(name)
+				if err != nil {
+					cmdLog.WithError(err).Fatalln("failed to find the asset")
+				}
+				return &executor.SwapAsset{
+					Symbol:    strings.ToUpper(token.Symbol),
+					Address:   common.HexToAddress(token.Address),
+					Decimals:  *token.Decimals,
+					PriceFeed: token.PriceFeed,
+				}
+			}
+			src, dst := swapAsset(*from), swapAsset(*to)
+			if src.Address == dst.Address {
+				cmdLog.Fatalln("assets of the swap must differ")
+			}
+			value, err := model.ParseUnits(*amount, src.Decimals)
+			if err != nil {
+				cmdLog.WithError(err).WithField("amount", *amount).Fatalln("invalid amount")
+			} else if value.Sign() <= 0 {
+				cmdLog.WithField("amount", *amount).Fatalln("amount must be positive")
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			result, err := exec.Swap(ctx, walletSpec, src, dst, value, *quoteOnly, *skipOracle)
+			if result != nil {
+				fields := log.Fields{
+					"sell": model.FormatUnits(result.Amount, src.Decimals) + " " + src.Symbol,
+					"buy":  model.FormatUnits(result.Quote, dst.Decimals) + " " + dst.Symbol,
+					"min":  model.FormatUnits(result.Floor, dst.Decimals) + " " + dst.Symbol,
+				}
+				if result.Expected != nil {
+					fields["oracle"] = model.FormatUnits(result.Expected, dst.Decimals) + " " + dst.Symbol
+					fields["deviation"] = fmt.Sprintf("%.2f%%", result.Deviation)
+				}
+				if len(result.ApproveTx) > 0 {
+					fields["approveTx"] = result.ApproveTx
+				}
+				if len(result.TxHash) > 0 {
+					cmdLog.WithFields(fields).Println("swap sent")
+					fmt.Println(result.TxHash)
+				} else {
+					cmdLog.WithFields(fields).Println("swap quoted")
+				}
+			}
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to swap")
+			}
+		}
+	}
+}
+
+func newAirdrop(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--mode] [--distributor] [--chunk] [--resume] [--allow-flagged] WALLET ASSET FILE"
+		mode := cmd.StringOpt("mode", "", "Transfers sent one by one (direct, default), in batches through the disperse contract (disperse), "+
+			"or claimed from the Merkle distributor (merkle)")
+		distributor := cmd.StringOpt("distributor", "", "Address or instance name of the Merkle distributor of the merkle mode")
+		chunk := cmd.IntOpt("chunk", 100, "Recipients sent at once, with consecutive nonces or in one disperse call")
+		resume := cmd.StringOpt("resume", "", "ID of the interrupted airdrop to resume, from its journal in runs/")
+		allowFlagged := cmd.BoolOpt("allow-flagged", false, "Send to the recipients flagged by the screening")
+		wallet := cmd.StringArg("WALLET", "", "Name of the wallet sending the airdrop")
+		asset := cmd.StringArg("ASSET", "", "ETH, or the name or symbol of a token in TOKENS section")
+		file := cmd.StringArg("FILE", "", "CSV file with address and amount columns, the amounts are in asset units")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "airdrop", []string{"airdrop"})
+			cmdLog := log.WithField("command", "airdrop")
+			if _, ok := spec.Wallets.WalletSpec(*wallet); !ok {
+				cmdLog.WithField("wallet", *wallet).Fatalln("wallet not found")
+			} else if *chunk < 1 {
+				cmdLog.WithField("chunk", *chunk).Fatalln("chunk must be at least one recipient")
+			}
+			airdropMode := model.AirdropMode(*mode)
+			switch airdropMode {
+			case "":
+				airdropMode = model.AirdropDirect
+			case model.AirdropDirect, model.AirdropDisperse, model.AirdropMerkle:
+			default:
+				cmdLog.WithField("mode", *mode).Fatalln("unknown airdrop mode (direct, disperse or merkle)")
+			}
+			var distributorAddress string
+			if len(*distributor) > 0 {
+				address, err := spec.ResolveAddress(*distributor)
+				if err != nil {
+					cmdLog.WithError(err).WithField("distributor", *distributor).Fatalln("failed to resolve the distributor")
+				}
+				distributorAddress = strings.ToLower(address.Hex())
+			}
+			symbol, decimals := executor.EtherAsset, 18
+			if !strings.EqualFold(*asset, executor.EtherAsset) {
+				token, err := spec.FindToken(*asset)
+				if err != nil {
+					cmdLog.WithError(err).Fatalln("failed to find the asset")
+				}
+				symbol, decimals = strings.ToUpper(token.Symbol), *token.Decimals
+			}
+			var journal *model.AirdropJournal
+			if len(*resume) > 0 {
+				journalLog := cmdLog.WithField("airdrop", *resume)
+				var err error
+				if journal, err = model.LoadAirdropJournal(ctx.SpecDir(), *resume); err != nil {
+					journalLog.WithError(err).Fatalln("failed to load airdrop journal")
+				} else if journal.Wallet != *wallet || journal.Asset != symbol {
+					journalLog.WithFields(log.Fields{
+						"wallet": journal.Wallet,
+						"asset":  journal.Asset,
+					}).Fatalln("the airdrop has been started from another wallet or of another asset")
+				} else if journal.NodeGroup != ctx.NodeGroup() {
+					journalLog.WithField("journal", journal.NodeGroup).Fatalln("the airdrop has been started for another node group")
+				} else if len(*mode) > 0 && journal.Mode != airdropMode {
+					journalLog.WithField("journal", journal.Mode).Fatalln("the airdrop has been started in another mode")
+				} else if len(distributorAddress) > 0 && journal.Distributor != distributorAddress {
+					journalLog.WithField("journal", journal.Distributor).Fatalln("the airdrop has been started with another distributor")
+				}
+			} else {
+				if airdropMode == model.AirdropMerkle && len(distributorAddress) == 0 {
+					cmdLog.Fatalln("the merkle mode needs the --distributor")
+				} else if airdropMode == model.AirdropMerkle && symbol == executor.EtherAsset {
+					cmdLog.Fatalln("the merkle distributor airdrops tokens only")
+				}
+				var err error
+				if journal, err = model.NewAirdropJournal(ctx, *wallet, symbol, *file, decimals, airdropMode); err != nil {
+					cmdLog.WithError(err).WithField("file", *file).Fatalln("failed to read the recipients")
+				}
+				if airdropMode == model.AirdropMerkle {
+					journal.Distributor = distributorAddress
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			exec.SetAllowFlagged(*allowFlagged)
+			airdropLog := cmdLog.WithField("airdrop", journal.ID)
+			airdropLog.WithFields(log.Fields{
+				"recipients": len(journal.Recipients),
+				"total":      model.FormatUnits(model.AirdropRecipients(journal.Recipients).Total(), decimals) + " " + symbol,
+			}).Println("airdrop started, resume it with --resume if interrupted")
+			if err := exec.Airdrop(ctx, journal, *chunk); err != nil {
+				airdropLog.WithError(err).Fatalln("airdrop interrupted")
+			}
+			done, failed, _ := journal.Counts()
+			if failed > 0 {
+				airdropLog.WithFields(log.Fields{
+					"done":   done,
+					"failed": failed,
+				}).Errorln("airdrop has failed transfers, resume it to retry them")
+				os.Exit(1)
+			}
+			airdropLog.WithField("done", done).Println("airdrop completed")
+		}
+	}
+}
+
+func newMerkle(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--out] TOKEN FILE"
+		out := cmd.StringOpt("out", "", "Path of the claims JSON, default is the CSV file with .merkle.json extension")
+		token := cmd.StringArg("TOKEN", "", "Name or symbol of the distributed token in TOKENS section")
+		file := cmd.StringArg("FILE", "", "CSV file with address and amount columns, the amounts are in token units")
+		cmd.Action = func() {
+			validateSpec(spec, "merkle", []string{"merkle"})
+			cmdLog := log.WithField("command", "merkle")
+			tokenSpec, err := spec.FindToken(*token)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to find the token")
+			}
+			symbol := strings.ToUpper(tokenSpec.Symbol)
+			recipients, err := model.LoadAllocations(*file, symbol, *tokenSpec.Decimals)
+			if err != nil {
+				cmdLog.WithError(err).WithField("file", *file).Fatalln("failed to read the allocations")
+			}
+			distribution := model.NewMerkleDistribution(recipients)
+			for account, claim := range distribution.Claims {
+				if !model.VerifyMerkleProof(distribution.MerkleRoot, common.HexToAddress(account), claim) {
+					cmdLog.WithField("account", account).Fatalln("failed to verify the proof of the claim")
+				}
+			}
+			data, err := json.MarshalIndent(distribution, "", "  ")
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to marshal the claims")
+			}
+			path := *out
+			if len(path) == 0 {
+				path = strings.TrimSuffix(*file, filepath.Ext(*file)) + ".merkle.json"
+			}
+			if err := ioutil.WriteFile(path, data, 0644); err != nil {
+				cmdLog.WithError(err).Fatalln("failed to write the claims")
+			}
+			cmdLog.WithFields(log.Fields{
+				"claims": len(distribution.Claims),
+				"total":  model.FormatUnits(recipients.Total(), *tokenSpec.Decimals) + " " + symbol,
+				"file":   path,
+			}).Println("claims written")
+			// the root alone on stdout, to be passed to the deployment of the distributor
+			fmt.Println(distribution.MerkleRoot.Hex())
+		}
+	}
+}
+
+func newSweep(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--token]... [--dust]... [--no-eth] [--dry-run] [--allow-flagged] WALLETS TO"
+		tokens := cmd.StringsOpt("token", nil, "Name or symbol of a token in TOKENS section to sweep along with ether")
+		dusts := cmd.StringsOpt("dust", nil, "Balance left in the wallets as ASSET=AMOUNT in asset units, e.g. ETH=0.01")
+		noEther := cmd.BoolOpt("no-eth", false, "Sweep the tokens only, leaving ether for the gas")
+		dryRun := cmd.BoolOpt("dry-run", false, "Print the amounts to be moved without sending anything")
+		allowFlagged := cmd.BoolOpt("allow-flagged", false, "Sweep to the destination flagged by the screening")
+		walletsRx := cmd.StringArg("WALLETS", "", "Regexp matching the names of the swept wallets")
+		to := cmd.StringArg("TO", "", "Name of the destination wallet or an address")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "sweep", []string{"sweep"})
+			cmdLog := log.WithField("command", "sweep")
+			rx, err := regexp.Compile(*walletsRx)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to compile wallets regexp")
+			}
+			destination, err := spec.ResolveAddress(*to)
+			if err != nil {
+				cmdLog.WithError(err).WithField("to", *to).Fatalln("invalid destination")
+			}
+			var wallets []*model.WalletSpec
+			for _, wallet := range spec.Wallets.GetAll(rx) {
+				if common.IsHexAddress(wallet.Address) && common.HexToAddress(wallet.Address) != destination {
+					wallets = append(wallets, wallet)
+				}
+			}
+			if len(wallets) == 0 {
+				cmdLog.WithField("wallets", *walletsRx).Fatalln("no wallets matched")
+			}
+			var assets []*executor.SweepAsset
+			for _, name := range *tokens {
+				token, err := spec.FindToken(name)
+				if err != nil {
+					cmdLog.WithError(err).Fatalln("failed to find the token")
+				}
+				assets = append(assets, &executor.SweepAsset{
+					Symbol:   strings.ToUpper(token.Symbol),
+					Token:    token,
+					Decimals: *token.Decimals,
+					Dust:     new(big.Int),
+				})
+			}
+			if !*noEther {
+				assets = append(assets, &executor.SweepAsset{
+					Symbol:   executor.EtherAsset,
+					Decimals: 18,
+					Dust:     new(big.Int),
+				})
+			} else if len(assets) == 0 {
+				cmdLog.Fatalln("nothing to sweep, specify --token")
+			}
+			for _, dust := range *dusts {
+				parts := strings.SplitN(dust, "=", 2)
+				var asset *executor.SweepAsset
+				for _, a := range assets {
+					if len(parts) == 2 && strings.EqualFold(a.Symbol, strings.TrimSpace(parts[0])) {
+						asset = a
+					}
+				}
+				if asset == nil {
+					cmdLog.WithField("dust", dust).Fatalln("dust must be ASSET=AMOUNT of a swept asset")
+				}
+				amount, err := model.ParseUnits(strings.TrimSpace(parts[1]), asset.Decimals)
+				if err != nil {
+					cmdLog.WithError(err).WithField("dust", dust).Fatalln("invalid dust amount")
+				}
+				asset.Dust = amount
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			exec.SetAllowFlagged(*allowFlagged)
+			if !*dryRun {
+				if err := exec.Screen(ctx, destination); err != nil {
+					cmdLog.WithError(err).Fatalln("destination is not screened")
+				}
+			}
+			transfers := exec.Sweep(ctx, wallets, destination, assets, *dryRun)
+			tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(tw, "WALLET\tASSET\tAMOUNT\tSTATUS")
+			var failed bool
+			for _, transfer := range transfers {
+				symbol, amount, status := "", "", transfer.TxHash
+				if transfer.Asset != nil {
+					symbol = transfer.Asset.Symbol
+					if transfer.Amount != nil {
+						amount = model.FormatUnits(transfer.Amount, transfer.Asset.Decimals)
+					}
+				}
+				switch {
+				case transfer.Error != nil:
+					status = "error: " + transfer.Error.Error()
+					failed = true
+				case len(transfer.Skipped) > 0:
+					status = "skipped: " + transfer.Skipped
+				case *dryRun:
+					status = "planned"
+				}
+				fmt.Fprintf(tw, "@%s\t%s\t%s\t%s\n", transfer.Wallet, symbol, amount, status)
+			}
+			tw.Flush()
+			if failed {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func newFund(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--dry-run] [RULE...]"
+		dryRun := cmd.BoolOpt("dry-run", false, "Print the top-ups without sending anything")
+		names := cmd.StringsArg("RULE", nil, "Names of the rules in FUNDING section, default is all")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "fund", append([]string{"fund"}, *names...))
+			cmdLog := log.WithField("command", "fund")
+			if len(*names) == 0 {
+				*names = spec.Funding.Names()
+				if len(*names) == 0 {
+					cmdLog.Fatalln("spec has no fund rules in FUNDING section")
+				}
+			}
+			for _, name := range *names {
+				if _, ok := spec.Funding[name]; !ok {
+					cmdLog.WithField("rule", name).Fatalln("fund rule not found")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(tw, "RULE\tWALLET\tASSET\tBALANCE\tTOP-UP\tSTATUS")
+			var underfunded bool
+			for _, name := range *names {
+				fund := spec.Funding[name]
+				topUps := exec.Fund(ctx, name, fund, *dryRun)
+				for _, topUp := range topUps {
+					balance, amount, status := "", "", topUp.TxHash
+					if topUp.Balance != nil {
+						balance = model.FormatUnits(topUp.Balance, fund.Decimals())
+					}
+					if topUp.Amount != nil {
+						amount = model.FormatUnits(topUp.Amount, fund.Decimals())
+					}
+					switch {
+					case topUp.Error != nil:
+						status = "error: " + topUp.Error.Error()
+					case len(topUp.Skipped) > 0:
+						status = "skipped: " + topUp.Skipped
+					case *dryRun:
+						status = "planned"
+					}
+					if topUp.Capped && topUp.Error == nil {
+						status += " (capped)"
+					}
+					underfunded = underfunded || topUp.Underfunded()
+					fmt.Fprintf(tw, "%s\t@%s\t%s\t%s\t%s\t%s\n", name, topUp.Wallet, topUp.Asset, balance, amount, status)
+				}
+				if len(topUps) == 0 {
+					cmdLog.WithField("rule", name).Println("all wallets are funded")
+				}
+			}
+			tw.Flush()
+			if underfunded {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// vestingNames returns the names of the schedules, all of them by default, and fails if any is not found.
+func vestingNames(spec *model.Spec, cmdLog *log.Entry, names []string) []string {
+	if len(names) == 0 {
+		if names = spec.Vesting.Names(); len(names) == 0 {
+			cmdLog.Fatalln("spec has no schedules in VESTING section")
+		}
+	}
+	for _, name := range names {
+		if _, ok := spec.Vesting[name]; !ok {
+			cmdLog.WithField("schedule", name).Fatalln("vesting schedule not found")
+		}
+	}
+	return names
+}
+
+func newVesting(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[SCHEDULE...]"
+		names := cmd.StringsArg("SCHEDULE", nil, "Names of the schedules in VESTING section, default is all")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "vesting", append([]string{"vesting"}, *names...))
+			cmdLog := log.WithField("command", "vesting")
+			*names = vestingNames(spec, cmdLog, *names)
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			var schedules []*executor.VestingSchedule
+			var failed bool
+			for _, name := range *names {
+				schedule, err := exec.Vesting(ctx, name, spec.Vesting[name])
+				if err != nil {
+					cmdLog.WithError(err).WithField("schedule", name).Errorln("failed to read the vesting schedule")
+					failed = true
+					continue
+				}
+				schedules = append(schedules, schedule)
+			}
+			if err := writeVesting(*outputFormat, *outputFile, schedules); err != nil {
+				cmdLog.WithError(err).Fatalln("failed to write the vesting schedules")
+			}
+			if failed {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func newRelease(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--dry-run] [SCHEDULE...]"
+		dryRun := cmd.BoolOpt("dry-run", false, "Print the releasable amounts without sending anything")
+		names := cmd.StringsArg("SCHEDULE", nil, "Names of the schedules in VESTING section, default is all")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "release", append([]string{"release"}, *names...))
+			cmdLog := log.WithField("command", "release")
+			*names = vestingNames(spec, cmdLog, *names)
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(tw, "SCHEDULE\tWALLET\tASSET\tRELEASABLE\tSTATUS")
+			var failed bool
+			for _, name := range *names {
+				vesting := spec.Vesting[name]
+				schedule, err := exec.Vesting(ctx, name, vesting)
+				if err != nil {
+					failed = true
+					fmt.Fprintf(tw, "%s\t@%s\t%s\t\terror: %v\n", name, vesting.Wallet, vesting.Asset(), err)
+					continue
+				}
+				status := "planned"
+				switch {
+				case schedule.Releasable.Sign() == 0:
+					status = "nothing to release"
+				case !*dryRun:
+					txHash, err := exec.Release(ctx, vesting)
+					if status = txHash; err != nil {
+						failed = true
+						status = "error: " + err.Error()
+					}
+				}
+				fmt.Fprintf(tw, "%s\t@%s\t%s\t%s\t%s\n", name, schedule.Wallet, schedule.Asset,
+					model.FormatUnits(schedule.Releasable, schedule.Decimals), status)
+			}
+			tw.Flush()
+			if failed {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func newStaking(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[STAKE...]"
+		names := cmd.StringsArg("STAKE", nil, "Names of the stakes in STAKING section, default is all")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "staking", append([]string{"staking"}, *names...))
+			cmdLog := log.WithField("command", "staking")
+			if len(*names) == 0 {
+				if *names = spec.Staking.Names(); len(*names) == 0 {
+					cmdLog.Fatalln("spec has no stakes in STAKING section")
+				}
+			}
+			for _, name := range *names {
+				if _, ok := spec.Staking[name]; !ok {
+					cmdLog.WithField("stake", name).Fatalln("stake not found")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			report := exec.Staking(ctx, *names)
+			if err := writeStaking(*outputFormat, *outputFile, report); err != nil {
+				cmdLog.WithError(err).Fatalln("failed to write the staking report")
+			}
+			if report.Failed() {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func newENS(spec *model.Spec) cli.CmdInitializer {
+	// ensAction validates the spec and runs the operation with the wallet, printing the sent transactions.
+	ensAction := func(op string, wallet *string, run func(ctx model.AppContext,
+		exec *executor.Executor, walletSpec *model.WalletSpec) ([]string, error)) func() {
+		return func() {
+			ctx := validateSpec(spec, "ens", []string{"ens", op})
+			cmdLog := log.WithField("command", "ens "+op)
+			walletSpec, ok := spec.Wallets.WalletSpec(*wallet)
+			if !ok {
+				cmdLog.WithField("wallet", *wallet).Fatalln("wallet not found")
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			txHashes, err := run(ctx, exec, walletSpec)
+			for _, txHash := range txHashes {
+				if len(txHash) > 0 {
+					fmt.Println(txHash)
+				}
+			}
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to " + op)
+			}
+		}
+	}
+	resolveAddress := func(op, name string) common.Address {
+		address, err := spec.ResolveAddress(name)
+		if err != nil {
+			log.WithField("command", "ens "+op).WithError(err).WithField("address", name).Fatalln("invalid address")
+		}
+		return address
+	}
+	return func(cmd *cli.Cmd) {
+		cmd.Command("register", "Commit and register a .eth name with the public resolver", func(cmd *cli.Cmd) {
+			cmd.Spec = "[--years] [--owner] WALLET NAME"
+			years := cmd.IntOpt("years", 1, "Registration duration in years")
+			owner := cmd.StringOpt("owner", "", "Owner of the name, default is the wallet")
+			wallet := cmd.StringArg("WALLET", "", "Name of the wallet paying the registration")
+			name := cmd.StringArg("NAME", "", "The .eth name to register")
+			cmd.Action = ensAction("register", wallet, func(ctx model.AppContext,
+				exec *executor.Executor, walletSpec *model.WalletSpec) ([]string, error) {
+				if *years < 1 {
+					return nil, fmt.Errorf("years must be positive, got %d", *years)
+				}
+				account := common.HexToAddress(walletSpec.Address)
+				if len(*owner) > 0 {
+					account = resolveAddress("register", *owner)
+				}
+				txHash, err := exec.ENSRegister(ctx, walletSpec, *name, *years, account)
+				return []string{txHash}, err
+			})
+		})
+		cmd.Command("set-resolver", "Set the resolver of a name", func(cmd *cli.Cmd) {
+			cmd.Spec = "WALLET NAME [RESOLVER]"
+			wallet := cmd.StringArg("WALLET", "", "Name of the wallet owning the name")
+			name := cmd.StringArg("NAME", "", "The ENS name")
+			resolver := cmd.StringArg("RESOLVER", "", "Address of the resolver, default is the public resolver")
+			cmd.Action = ensAction("set-resolver", wallet, func(ctx model.AppContext,
+				exec *executor.Executor, walletSpec *model.WalletSpec) ([]string, error) {
+				var address common.Address
+				if len(*resolver) > 0 {
+					address = resolveAddress("set-resolver", *resolver)
+				} else {
+					deployment, err := spec.Config.ENSDeployment()
+					if err != nil {
+						return nil, err
+					}
+					address = deployment.PublicResolver
+				}
+				txHash, err := exec.ENSSetResolver(ctx, walletSpec, *name, address)
+				return []string{txHash}, err
+			})
+		})
+		cmd.Command("set-addr", "Set the address record of a name", func(cmd *cli.Cmd) {
+			cmd.Spec = "WALLET NAME ADDRESS"
+			wallet := cmd.StringArg("WALLET", "", "Name of the wallet managing the name")
+			name := cmd.StringArg("NAME", "", "The ENS name")
+			address := cmd.StringArg("ADDRESS", "", "Address, wallet or contract name the name resolves to")
+			cmd.Action = ensAction("set-addr", wallet, func(ctx model.AppContext,
+				exec *executor.Executor, walletSpec *model.WalletSpec) ([]string, error) {
+				txHash, err := exec.ENSSetAddr(ctx, walletSpec, *name, resolveAddress("set-addr", *address))
+				return []string{txHash}, err
+			})
+		})
+		cmd.Command("set-text", "Set a text record of a name", func(cmd *cli.Cmd) {
+			cmd.Spec = "WALLET NAME KEY VALUE"
+			wallet := cmd.StringArg("WALLET", "", "Name of the wallet managing the name")
+			name := cmd.StringArg("NAME", "", "The ENS name")
+			key := cmd.StringArg("KEY", "", "Key of the record, e.g. url, avatar or com.twitter")
+			value := cmd.StringArg("VALUE", "", "Value of the record, empty clears it")
+			cmd.Action = ensAction("set-text", wallet, func(ctx model.AppContext,
+				exec *executor.Executor, walletSpec *model.WalletSpec) ([]string, error) {
+				txHash, err := exec.ENSSetText(ctx, walletSpec, *name, *key, *value)
+				return []string{txHash}, err
+			})
+		})
+		cmd.Command("set-contenthash", "Set the contenthash of a name", func(cmd *cli.Cmd) {
+			cmd.Spec = "WALLET NAME CONTENTHASH"
+			wallet := cmd.StringArg("WALLET", "", "Name of the wallet managing the name")
+			name := cmd.StringArg("NAME", "", "The ENS name")
+			contenthash := cmd.StringArg("CONTENTHASH", "", "An ipfs://Qm... URI or the hex encoded contenthash")
+			cmd.Action = ensAction("set-contenthash", wallet, func(ctx model.AppContext,
+				exec *executor.Executor, walletSpec *model.WalletSpec) ([]string, error) {
+				data, err := model.ParseContenthash(*contenthash)
+				if err != nil {
+					return nil, err
+				}
+				txHash, err := exec.ENSSetContenthash(ctx, walletSpec, *name, data)
+				return []string{txHash}, err
+			})
+		})
+		cmd.Command("transfer", "Transfer a name to another owner", func(cmd *cli.Cmd) {
+			cmd.Spec = "WALLET NAME TO"
+			wallet := cmd.StringArg("WALLET", "", "Name of the wallet owning the name")
+			name := cmd.StringArg("NAME", "", "The ENS name")
+			to := cmd.StringArg("TO", "", "Address, wallet or contract name of the new owner")
+			cmd.Action = ensAction("transfer", wallet, func(ctx model.AppContext,
+				exec *executor.Executor, walletSpec *model.WalletSpec) ([]string, error) {
+				return exec.ENSTransfer(ctx, walletSpec, *name, resolveAddress("transfer", *to))
+			})
+		})
+	}
+}
+
+func newNFTs(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--from] NFT WALLET"
+		from := cmd.StringOpt("from", "", "First block of the transfer logs scan (number, tag or timestamp), default is genesis")
+		nft := cmd.StringArg("NFT", "", "Name of the collection in NFTS section")
+		wallet := cmd.StringArg("WALLET", "", "Name of the owner wallet or an address")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "nfts", []string{"nfts"})
+			cmdLog := log.WithField("command", "nfts")
+			nftSpec, err := spec.FindNFT(*nft)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to find the collection")
+			}
+			owner, err := spec.ResolveAddress(*wallet)
+			if err != nil {
+				cmdLog.WithError(err).WithField("wallet", *wallet).Fatalln("invalid owner")
+			}
+			var fromBlock *model.BlockRef
+			if len(*from) > 0 {
+				if fromBlock, err = model.ParseBlockRef(*from); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			owned, err := exec.OwnedNFTs(ctx, nftSpec, owner, fromBlock)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to enumerate the tokens")
+			}
+			if err := writeNFTs(*outputFormat, *outputFile, owned); err != nil {
+				cmdLog.WithError(err).Fatalln("failed to write the tokens")
+			}
+		}
+	}
+}
+
+func newLogs(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--from] [--to] [--where]... CONTRACT EVENT"
+		from := cmd.StringOpt("from", "", "First block of the scan (number, tag or timestamp), default is genesis")
+		to := cmd.StringOpt("to", "", "Last block of the scan (number, tag or timestamp), default is the latest")
+		wheres := cmd.StringsOpt("where", nil, "Condition on an event arg as ARG OP VALUE, e.g. value>=1000 or from=@alice")
+		contract := cmd.StringArg("CONTRACT", "", "Name of a contract or a token, or an address of the emitter")
+		event := cmd.StringArg("EVENT", "", "Name or signature of the event, e.g. Transfer")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "logs", []string{"logs"})
+			cmdLog := log.WithField("command", "logs")
+			abiEvent, addresses, err := spec.LookupEvent(*contract, *event)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to find the event")
+			}
+			var filters []*model.LogFilter
+			for _, where := range *wheres {
+				filter, err := model.ParseLogFilter(where)
+				if err != nil {
+					cmdLog.WithError(err).WithField("where", where).Fatalln("invalid filter")
+				}
+				filters = append(filters, filter)
+			}
+			var fromBlock, toBlock *model.BlockRef
+			if len(*from) > 0 {
+				if fromBlock, err = model.ParseBlockRef(*from); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			if len(*to) > 0 {
+				if toBlock, err = model.ParseBlockRef(*to); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			w, err := openOutput(*outputFile)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to create the output file")
+			}
+			defer w.Close()
+			lw, err := newLogsWriter(*outputFormat, w, abiEvent)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to write the events")
+			}
+			err = exec.ScanEvents(ctx, abiEvent, addresses, filters, fromBlock, toBlock, lw.Write)
+			if closeErr := lw.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to query the logs")
+			}
+			cmdLog.WithFields(log.Fields{
+				"event":  model.EventSignature(abiEvent),
+				"events": lw.count,
+			}).Debugln("logs queried")
+		}
+	}
+}
+
+func newScan(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--reset] NAME"
+		reset := cmd.BoolOpt("reset", false, "Start the scan over, discarding its cursor and truncating the output")
+		name := cmd.StringArg("NAME", "", "Name of the scan in SCANS section")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "scan", []string{"scan"})
+			scanLog := log.WithFields(log.Fields{
+				"command": "scan",
+				"scan":    *name,
+			})
+			scan, ok := spec.Scans[*name]
+			if !ok {
+				scanLog.Fatalln("scan not found")
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				scanLog.WithError(err).Fatalln("failed to init executor")
+			}
+			cursor, err := model.LoadScanCursor(ctx.SpecDir(), *name)
+			if err != nil {
+				scanLog.WithError(err).Fatalln("failed to load the scan cursor")
+			} else if cursor != nil && *reset {
+				if err := cursor.Remove(); err != nil {
+					scanLog.WithError(err).Fatalln("failed to remove the scan cursor")
+				}
+				cursor = nil
+			}
+			from, to := scan.BlockRange()
+			if cursor == nil {
+				fromBlock, toBlock, err := exec.ScanBounds(ctx, from, to)
+				if err != nil {
+					scanLog.WithError(err).Fatalln("failed to resolve the blocks to scan")
+				}
+				cursor = model.NewScanCursor(ctx.SpecDir(), *name, ctx.NodeGroup(), fromBlock, toBlock)
+			} else if cursor.NodeGroup != ctx.NodeGroup() {
+				scanLog.WithField("cursor", cursor.NodeGroup).Fatalln("the scan has been started for another node group")
+			} else if cursor.Done() && to == nil {
+				// the open-ended scan goes on with the blocks mined since
+				if _, latest, err := exec.ScanBounds(ctx, nil, nil); err != nil {
+					scanLog.WithError(err).Fatalln("failed to get the latest block")
+				} else if latest > cursor.To {
+					cursor.To = latest
+				}
+			}
+			if cursor.Done() {
+				scanLog.WithField("rows", cursor.Rows).Println("scan is complete, use --reset to start it over")
+				return
+			}
+			w, err := newScanWriter(ctx.SpecDir(), scan.Output, *reset)
+			if err != nil {
+				scanLog.WithError(err).Fatalln("failed to open the scan output")
+			}
+			defer w.Close()
+			scanLog.WithFields(log.Fields{
+				"from": cursor.Next,
+				"to":   cursor.To,
+			}).Println("scan started, it resumes from the cursor if interrupted")
+			err = exec.Scan(ctx, scan, cursor, func(rows []*executor.ScanRow) error {
+				if err := w.Write(rows); err != nil {
+					return err
+				}
+				scanLog.WithFields(log.Fields{
+					"from": cursor.Next,
+					"rows": len(rows),
+				}).Debugln("blocks scanned")
+				return nil
+			})
+			if err != nil {
+				w.Close()
+				scanLog.WithError(err).WithField("next", cursor.Next).Fatalln("scan interrupted, run it again to resume")
+			}
+			scanLog.WithFields(log.Fields{
+				"rows":   cursor.Rows,
+				"output": scan.Output,
+			}).Println("scan completed")
+		}
+	}
+}
+
+func newMonitor(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[RULE...]"
+		names := cmd.StringsArg("RULE", nil, "Names of the rules in MONITORS section, default is all")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "monitor", append([]string{"monitor"}, *names...))
+			cmdLog := log.WithField("command", "monitor")
+			if err := checkAlertFormat(*outputFormat); err != nil {
+				cmdLog.WithError(err).Fatalln("unsupported output format")
+			}
+			monitors := spec.Monitors
+			if len(*names) > 0 {
+				monitors = make(model.Monitors, len(*names))
+				for _, name := range *names {
+					monitor, ok := spec.Monitors[name]
+					if !ok {
+						cmdLog.WithField("rule", name).Fatalln("monitor rule not found")
+					}
+					monitors[name] = monitor
+				}
+			} else if len(monitors) == 0 {
+				cmdLog.Fatalln("spec has no monitor rules in MONITORS section")
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			var w io.Writer = os.Stdout
+			if len(*outputFile) > 0 {
+				f, err := os.OpenFile(*outputFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					cmdLog.WithError(err).Fatalln("failed to open the output file")
+				}
+				defer f.Close()
+				w = f
+			}
+			cmdLog.WithField("rules", len(monitors)).Println("monitoring the events of the spec contracts")
+			err = exec.Monitor(ctx, monitors, func(alert *executor.Alert) {
+				if err := writeAlert(w, *outputFormat, alert); err != nil {
+					cmdLog.WithError(err).Warningln("failed to write the alert")
+				}
+			})
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to monitor the events")
+			}
+		}
+	}
+}
+
+func newHistory(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--from] [--to] [--source] [--currency] [--unfiltered] WALLETS"
+		from := cmd.StringOpt("from", "", "Start of the range (date, timestamp, block number or tag), default is genesis")
+		to := cmd.StringOpt("to", "", "End of the range, excluded if a date or a timestamp, default is the latest block")
+		source := cmd.StringOpt("source", executor.HistorySourceEtherscan,
+			"Source of the transactions: etherscan, or node for trace_filter and the token logs of the spec")
+		currency := cmd.StringOpt("currency", "", "Fiat currency to value the transfers in, default is fiatCurrency of config")
+		unfiltered := cmd.BoolOpt("unfiltered", false, "Show the dust and the spam token transfers hidden by reportFilter of config")
+		walletsRx := cmd.StringArg("WALLETS", "", "Regexp matching the names of the wallets")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "history", []string{"history"})
+			cmdLog := log.WithField("command", "history")
+			if len(*currency) > 0 {
+				if err := spec.Config.SetFiatCurrency(*currency); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid currency")
+				}
+			}
+			rx, err := regexp.Compile(*walletsRx)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to compile wallets regexp")
+			}
+			var wallets []string
+			for name, wallet := range spec.Wallets {
+				if rx.MatchString(name) && common.IsHexAddress(wallet.Address) {
+					wallets = append(wallets, name)
+				}
+			}
+			if len(wallets) == 0 {
+				cmdLog.WithField("wallets", *walletsRx).Fatalln("no wallets matched")
+			}
+			sort.Strings(wallets)
+			var fromBlock, toBlock *model.BlockRef
+			if len(*from) > 0 {
+				if fromBlock, err = model.ParseBlockRef(*from); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			if len(*to) > 0 {
+				if toBlock, err = model.ParseBlockRef(*to); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			entries, err := exec.History(ctx, *source, wallets, fromBlock, toBlock)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to get the transaction history")
+			}
+			transfers := len(entries)
+			if !*unfiltered {
+				entries = exec.FilterHistoryAssets(entries)
+			}
+			if len(spec.Config.FiatCurrency) > 0 {
+				exec.ValueHistory(ctx, entries)
+			}
+			if !*unfiltered {
+				entries = exec.FilterHistoryDust(entries)
+			}
+			if err := writeHistory(*outputFormat, *outputFile, entries, spec.Config.FiatCurrency); err != nil {
+				cmdLog.WithError(err).Fatalln("failed to write the transaction history")
+			}
+			cmdLog.WithFields(log.Fields{
+				"wallets":   len(wallets),
+				"transfers": len(entries),
+				"hidden":    transfers - len(entries),
+			}).Debugln("history exported")
+		}
+	}
+}
+
+func newReconcile(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--from] [--to] [--source] [--window] LEDGER"
+		from := cmd.StringOpt("from", "", "Start of the period (date, timestamp, block number or tag), default is the window before the first entry")
+		to := cmd.StringOpt("to", "", "End of the period, excluded if a date or a timestamp, default is the window after the last entry")
+		source := cmd.StringOpt("source", executor.HistorySourceEtherscan,
+			"Source of the transactions: etherscan, or node for trace_filter and the token logs of the spec")
+		window := cmd.StringOpt("window", "3d", "How far the time of a transfer may be from the date of its ledger entry")
+		ledgerPath := cmd.StringArg("LEDGER", "", "CSV file (or JSON array) of the expected transfers with date, wallet, direction, asset and amount")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "reconcile", []string{"reconcile"})
+			cmdLog := log.WithFields(log.Fields{
+				"command": "reconcile",
+				"ledger":  *ledgerPath,
+			})
+			duration, err := model.ParseDuration(*window)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("invalid window")
+			} else if duration.Blocks > 0 {
+				cmdLog.Fatalln("window must be a time, not a number of blocks")
+			}
+			ledger, err := spec.LoadLedger(*ledgerPath)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to load the ledger")
+			}
+			fromBlock, toBlock := model.LedgerPeriod(ledger, duration.Time)
+			if len(*from) > 0 {
+				if fromBlock, err = model.ParseBlockRef(*from); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			if len(*to) > 0 {
+				if toBlock, err = model.ParseBlockRef(*to); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			// the entries dated out of the period are not expected on chain
+			expected := ledger[:0]
+			for _, entry := range ledger {
+				if !fromBlock.Time.IsZero() && !entry.End().After(fromBlock.Time) && entry.Time.Before(fromBlock.Time) {
+					continue
+				} else if !toBlock.Time.IsZero() && !entry.Time.Before(toBlock.Time) {
+					continue
+				}
+				expected = append(expected, entry)
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			entries, err := exec.History(ctx, *source, model.LedgerWallets(ledger), fromBlock, toBlock)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to get the transaction history")
+			}
+			rec := executor.Reconcile(expected, entries, duration.Time)
+			if err := writeReconciliation(*outputFormat, *outputFile, rec); err != nil {
+				cmdLog.WithError(err).Fatalln("failed to write the reconciliation")
+			}
+			cmdLog.WithFields(log.Fields{
+				"matched":         rec.Matched,
+				"unmatchedLedger": len(rec.Ledger),
+				"unmatchedChain":  len(rec.Chain),
+			}).Debugln("ledger reconciled")
+			if len(rec.Ledger) > 0 || len(rec.Chain) > 0 {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func newSnapshot(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--from] [--at] [--min] [--reset] ASSET"
+		from := cmd.StringOpt("from", "", "First block of the transfer logs, e.g. the deployment of the asset, default is genesis")
+		at := cmd.StringOpt("at", "", "Block of the snapshot (number, tag, date or timestamp), default is the latest block")
+		minAmount := cmd.StringOpt("min", "", "Minimum amount of the holders in token units, or tokens of the collection")
+		reset := cmd.BoolOpt("reset", false, "Discard the checkpoint, reconstructing the holders from the first block")
+		asset := cmd.StringArg("ASSET", "", "Name or symbol of a token in TOKENS section, or an ERC-721 collection in NFTS")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "snapshot", []string{"snapshot"})
+			cmdLog := log.WithFields(log.Fields{
+				"command": "snapshot",
+				"asset":   *asset,
+			})
+			var address string
+			var decimals int
+			var nft bool
+			if _, ok := spec.NFTs[*asset]; ok {
+				nftSpec, err := spec.FindNFT(*asset)
+				if err != nil {
+					cmdLog.WithError(err).Fatalln("failed to find the collection")
+				} else if nftSpec.Standard != model.ERC721 {
+					cmdLog.Fatalln("only ERC-721 collections have the holders in their transfer logs")
+				}
+				address, nft = nftSpec.Address, true
+			} else {
+				tokenSpec, err := spec.FindToken(*asset)
+				if err != nil {
+					cmdLog.WithError(err).Fatalln("failed to find the token")
+				}
+				address, decimals = tokenSpec.Address, *tokenSpec.Decimals
+			}
+			var fromBlock, atBlock *model.BlockRef
+			var err error
+			if len(*from) > 0 {
+				if fromBlock, err = model.ParseBlockRef(*from); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			if len(*at) > 0 {
+				if atBlock, err = model.ParseBlockRef(*at); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			var threshold *big.Int
+			if len(*minAmount) > 0 {
+				if threshold, err = model.ParseUnits(*minAmount, decimals); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid minimum amount")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			first, block, err := exec.ScanBounds(ctx, fromBlock, atBlock)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to resolve the snapshot block")
+			}
+			checkpoint, err := model.LoadSnapshotCheckpoint(ctx.SpecDir(), *asset)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to load the snapshot checkpoint")
+			}
+			switch {
+			case checkpoint == nil:
+			case *reset:
+				checkpoint = nil
+			case checkpoint.NodeGroup != ctx.NodeGroup() || checkpoint.Address != strings.ToLower(address):
+				cmdLog.WithField("checkpoint", checkpoint.NodeGroup).Fatalln("the checkpoint is of another node group or address, use --reset")
+			case checkpoint.Next > block+1:
+				cmdLog.WithField("checkpoint", checkpoint.Next-1).Println("the block is before the checkpoint, reconstructing from the first block")
+				checkpoint = nil
+			}
+			if checkpoint == nil {
+				checkpoint = model.NewSnapshotCheckpoint(ctx.SpecDir(), *asset, address, ctx.NodeGroup(), first)
+			}
+			cmdLog.WithFields(log.Fields{
+				"from":  checkpoint.Next,
+				"block": block,
+			}).Debugln("applying the transfer logs")
+			holders, err := exec.Snapshot(ctx, checkpoint, block)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to reconstruct the holders, run it again to resume from the checkpoint")
+			}
+			if threshold != nil {
+				kept := holders[:0]
+				for _, holder := range holders {
+					if holder.Balance.Cmp(threshold) >= 0 {
+						kept = append(kept, holder)
+					}
+				}
+				holders = kept
+			}
+			if err := writeSnapshot(*outputFormat, *outputFile, holders, decimals, nft); err != nil {
+				cmdLog.WithError(err).Fatalln("failed to write the holders")
+			}
+			cmdLog.WithFields(log.Fields{
+				"block":   block,
+				"holders": len(holders),
+			}).Println("snapshot taken")
+		}
+	}
+}
+
+func newAllowances(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--from] [--token]... [--allow]... [--revoke] WALLETS"
+		from := cmd.StringOpt("from", "", "First block of the Approval logs scan (number, tag or timestamp), default is genesis")
+		tokens := cmd.StringsOpt("token", nil, "Name or symbol of a token in TOKENS section to audit, default is any contract")
+		allowed := cmd.StringsOpt("allow", nil, "Name of a wallet or contract, or an address of a spender to keep")
+		revoke := cmd.BoolOpt("revoke", false, "Send approve(spender, 0) for the spenders not allowed")
+		walletsRx := cmd.StringArg("WALLETS", "", "Regexp matching the names of the audited wallets")
+		cmd.Action = func() {
+			ctx := validateSpec(spec, "allowances", []string{"allowances"})
+			cmdLog := log.WithField("command", "allowances")
+			rx, err := regexp.Compile(*walletsRx)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to compile wallets regexp")
+			}
+			var wallets []*model.WalletSpec
+			for _, wallet := range spec.Wallets.GetAll(rx) {
+				if common.IsHexAddress(wallet.Address) {
+					wallets = append(wallets, wallet)
+				}
+			}
+			if len(wallets) == 0 {
+				cmdLog.WithField("wallets", *walletsRx).Fatalln("no wallets matched")
+			}
+			var addresses []common.Address
+			for _, name := range *tokens {
+				token, err := spec.FindToken(name)
+				if err != nil {
+					cmdLog.WithError(err).Fatalln("failed to find the token")
+				}
+				addresses = append(addresses, common.HexToAddress(token.Address))
+			}
+			allowlist := make(map[common.Address]bool, len(*allowed))
+			for _, name := range *allowed {
+				spender, err := spec.ResolveAddress(name)
+				if err != nil {
+					cmdLog.WithError(err).WithField("spender", name).Fatalln("invalid allowed spender")
+				}
+				allowlist[spender] = true
+			}
+			var fromBlock *model.BlockRef
+			if len(*from) > 0 {
+				if fromBlock, err = model.ParseBlockRef(*from); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			allowances, err := exec.Allowances(ctx, wallets, addresses, fromBlock)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to scan the approvals")
+			}
+			var revoked []*executor.Allowance
+			for _, allowance := range allowances {
+				if allowance.Error == nil && !allowlist[allowance.Spender] {
+					revoked = append(revoked, allowance)
+				}
+			}
+			if *revoke {
+				exec.RevokeAllowances(ctx, revoked)
+			}
+			unlimited := new(big.Int).Lsh(big.NewInt(1), 255)
+			tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(tw, "WALLET\tTOKEN\tSPENDER\tALLOWANCE\tSTATUS")
+			var failed bool
+			for _, allowance := range allowances {
+				token, amount := strings.ToLower(allowance.Token.Hex()), ""
+				if allowance.Known != nil {
+					token = strings.ToUpper(allowance.Known.Symbol)
+				}
+				switch {
+				case allowance.Amount == nil:
+				case allowance.Amount.Cmp(unlimited) >= 0:
+					amount = "unlimited"
+				case allowance.Known != nil:
+					amount = model.FormatUnits(allowance.Amount, *allowance.Known.Decimals)
+				default:
+					amount = allowance.Amount.String()
+				}
+				spender := strings.ToLower(allowance.Spender.Hex())
+				if name := spec.Wallets.NameOf(spender); len(name) > 0 {
+					spender = "@" + name
+				} else if label, ok := spec.AddressLabel(allowance.Spender); ok {
+					spender = label
+				} else if name := spec.Contracts.NameOf(spender); len(name) > 0 {
+					spender = fmt.Sprintf("%s (%s)", spender, name)
+				}
+				var status string
+				switch {
+				case allowance.Error != nil:
+					status = "error: " + allowance.Error.Error()
+					failed = true
+				case allowlist[allowance.Spender]:
+					status = "allowed"
+				case len(allowance.TxHash) > 0:
+					status = "revoked " + allowance.TxHash
+				default:
+					status = "not allowed"
+				}
+				fmt.Fprintf(tw, "@%s\t%s\t%s\t%s\t%s\n",
+					spec.Wallets.NameOf(allowance.Wallet.Address), token, spender, amount, status)
+			}
+			tw.Flush()
+			if failed {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func newFindBlock(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--from] [--to] VIEW OP VALUE [ARG...]"
+		from := cmd.StringOpt("from", "", "First block of the range (number, tag or timestamp), default is genesis")
+		to := cmd.StringOpt("to", "", "Last block of the range (number, tag or timestamp), default is latest")
+		view := cmd.StringArg("VIEW", "", "Name of the VIEW command returning a number")
+		op := cmd.StringArg("OP", "", "Comparison operator: >, >=, <, <=, ==, != (or gt, gte, lt, lte, eq, ne)")
+		value := cmd.StringArg("VALUE", "", "Threshold to compare the view result with")
+		args := cmd.StringsArg("ARG", nil, "Arguments of the VIEW command")
+		cmd.Action = func() {
+			cmdLog := log.WithField("command", "find-block")
+			if _, ok := spec.ViewCmds[*view]; !ok {
+				cmdLog.WithField("view", *view).Fatalln("view command not found")
+			}
+			ctx := validateSpec(spec, *view, append([]string{*view}, *args...))
+			compareOp, err := model.ParseCompareOp(*op)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("invalid condition")
+			}
+			threshold, ok := new(big.Int).SetString(*value, 0)
+			if !ok {
+				cmdLog.WithField("value", *value).Fatalln("threshold must be a number")
+			}
+			var fromBlock, toBlock *model.BlockRef
+			if len(*from) > 0 {
+				if fromBlock, err = model.ParseBlockRef(*from); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			if len(*to) > 0 {
+				if toBlock, err = model.ParseBlockRef(*to); err != nil {
+					cmdLog.WithError(err).Fatalln("invalid block reference")
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				cmdLog.WithError(err).Fatalln("failed to init executor")
+			}
+			result := exec.FindBlock(ctx, *view, compareOp, threshold, fromBlock, toBlock)
+			exportResultsText(spec, []*executor.CommandResult{result}, "")
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	log "github.com/Sirupsen/logrus"
+	cli "github.com/jawher/mow.cli"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// builtinNames are the builtin commands not shadowed by the spec, in order of registration.
+var builtinNames []string
+
+func newList(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--names] [KIND]"
+		names := cmd.BoolOpt("names", false, "Print the names only, one per line, for scripts and completion")
+		kind := cmd.StringArg("KIND", "", "List only targets, commands (including targets and builtins) or wallets")
+		cmd.Action = func() {
+			switch *kind {
+			case "", "targets", "commands", "wallets":
+			default:
+				log.WithField("kind", *kind).Fatalln("unknown kind, must be targets, commands or wallets")
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			defer w.Flush()
+			print := func(section, name, desc string) {
+				if *names {
+					fmt.Fprintln(w, name)
+					return
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", section, name, desc)
+			}
+			for _, specCmd := range specCommands(spec) {
+				if *kind == "" || *kind == "commands" || (*kind == "targets" && specCmd.Kind == "TARGET") {
+					print(specCmd.Kind, specCmd.Name, specCmd.Desc)
+				}
+			}
+			if *kind == "commands" {
+				for _, name := range builtinNames {
+					print("BUILTIN", name, "")
+				}
+			}
+			if *kind == "" || *kind == "wallets" {
+				walletNames := make([]string, 0, len(spec.Wallets))
+				for name := range spec.Wallets {
+					walletNames = append(walletNames, name)
+				}
+				sort.Strings(walletNames)
+				for _, name := range walletNames {
+					print("WALLET", name, spec.Wallets[name].Address)
+				}
+			}
+		}
+	}
+}
+
+func newCompletion() cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		shell := cmd.StringArg("SHELL", "", "Shell to generate the completion script for: bash, zsh or fish")
+		cmd.Action = func() {
+			script, ok := completionScripts[*shell]
+			if !ok {
+				log.WithField("shell", *shell).Fatalln("unknown shell, must be bash, zsh or fish")
+			}
+			fmt.Print(strings.Replace(script, "ethereum-playbook", appName(), -1))
+		}
+	}
+}
+
+// appName is the name the tool has been called by, so completion is registered for it.
+func appName() string {
+	name := os.Args[0]
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// completionScripts complete the first word with the commands of the spec given by -f, or playbook.yml,
+// and command args with the wallet names. The names are listed by the tool at completion time.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for ethereum-playbook, source it from ~/.bashrc:
+#   source <(ethereum-playbook completion bash)
+_ethereum_playbook() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" spec="playbook.yml" cmd="" i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+            -f) spec="${COMP_WORDS[i+1]}"; ((i++)) ;;
+            -s|-g|-p|-l|--network|--output|--output-file|--log-level) ((i++)) ;;
+            -*) ;;
+            *) cmd="${COMP_WORDS[i]}"; break ;;
+        esac
+    done
+    local kind=commands
+    if [[ -n "$cmd" ]]; then
+        kind=wallets
+    fi
+    COMPREPLY=($(compgen -W "$(ethereum-playbook -f "$spec" list --names $kind 2>/dev/null)" -- "$cur"))
+}
+complete -F _ethereum_playbook ethereum-playbook
+`,
+	"zsh": `#compdef ethereum-playbook
+# zsh completion for ethereum-playbook, source it from ~/.zshrc:
+#   source <(ethereum-playbook completion zsh)
+_ethereum_playbook() {
+    local spec="playbook.yml" cmd="" i
+    for ((i = 2; i < CURRENT; i++)); do
+        case "${words[i]}" in
+            -f) spec="${words[i+1]}"; ((i++)) ;;
+            -s|-g|-p|-l|--network|--output|--output-file|--log-level) ((i++)) ;;
+            -*) ;;
+            *) cmd="${words[i]}"; break ;;
+        esac
+    done
+    local -a names
+    if [[ -z "$cmd" ]]; then
+        names=(${(f)"$(ethereum-playbook -f "$spec" list --names commands 2>/dev/null)"})
+        _describe 'command' names
+    else
+        names=(${(f)"$(ethereum-playbook -f "$spec" list --names wallets 2>/dev/null)"})
+        _describe 'wallet' names
+    fi
+}
+compdef _ethereum_playbook ethereum-playbook
+`,
+	"fish": `# fish completion for ethereum-playbook, save it to ~/.config/fish/completions/ethereum-playbook.fish
+function __ethereum_playbook_spec
+    set -l tokens (commandline -opc)
+    set -l i (contains -i -- -f $tokens)
+    and echo $tokens[(math $i + 1)]
+    or echo playbook.yml
+end
+
+function __ethereum_playbook_needs_command
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    while set -q tokens[1]
+        switch $tokens[1]
+            case -f -s -g -p -l --network --output --output-file --log-level
+                set -e tokens[1]
+            case '-*'
+            case '*'
+                return 1
+        end
+        set -e tokens[1]
+    end
+    return 0
+end
+
+complete -c ethereum-playbook -f -n __ethereum_playbook_needs_command -a '(ethereum-playbook -f (__ethereum_playbook_spec) list --names commands 2>/dev/null)'
+complete -c ethereum-playbook -f -n 'not __ethereum_playbook_needs_command' -a '(ethereum-playbook -f (__ethereum_playbook_spec) list --names wallets 2>/dev/null)'
+`,
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/AtlantPlatform/ethfw"
+	log "github.com/Sirupsen/logrus"
+	cli "github.com/jawher/mow.cli"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+const consoleHelp = `Commands:
+  NAME [ARG...]        run the command or target of the spec with the args
+  eval EXPR            evaluate the expression, e.g. eval @alice.balance / 2
+  balance [WALLET...]  print the balances of the wallets, all by default
+  list                 list the targets, commands and wallets
+  help                 print this help
+  exit                 leave the console
+
+The outputs of commands run in the console can be referenced by the next ones.
+`
+
+// consoleSession keeps the state between the lines of the console: the keys decrypted,
+// and the executor of the last command, whose outputs are inherited by the next one.
+type consoleSession struct {
+	keycache ethfw.KeyCache
+
+	spec *model.Spec
+	ctx  model.AppContext
+	exec *executor.Executor
+}
+
+func newConsole() cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Action = func() {
+			session := &consoleSession{
+				keycache: ethfw.NewKeyCache(),
+			}
+			if !session.init("console", nil) {
+				os.Exit(exitValidation)
+			}
+			fmt.Printf("Loaded %s on %s, type help for commands.\n", *specPath, session.ctx.NodeGroup())
+			scanner := bufio.NewScanner(os.Stdin)
+			for {
+				fmt.Print("playbook> ")
+				if !scanner.Scan() {
+					fmt.Println()
+					return
+				}
+				fields := strings.Fields(scanner.Text())
+				if len(fields) == 0 {
+					continue
+				}
+				switch fields[0] {
+				case "exit", "quit":
+					return
+				case "help":
+					fmt.Print(consoleHelp)
+				case "list":
+					for _, specCmd := range specCommands(session.spec) {
+						fmt.Printf("%-8s%-24s%s\n", specCmd.Kind, specCmd.Name, specCmd.Desc)
+					}
+				case "eval":
+					session.eval(strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "eval")))
+				case "balance":
+					session.balance(fields[1:])
+				default:
+					session.run(fields[0], fields[1:])
+				}
+			}
+		}
+	}
+}
+
+// init reloads the spec and validates it for the command with args, so the args are checked
+// and resolved as if the command has been run from the command line.
+func (s *consoleSession) init(name string, args []string) bool {
+	spec, ok := loadSpec()
+	if !ok {
+		return false
+	}
+	ctx, ok := validateSpecWith(spec, name, append([]string{name}, args...), s.keycache)
+	if !ok {
+		return false
+	}
+	exec, err := executor.New(ctx, spec)
+	if err != nil {
+		log.WithError(err).Errorln("failed to init executor")
+		return false
+	}
+	if s.exec != nil {
+		exec.InheritOutputs(s.exec)
+	}
+	s.spec, s.ctx, s.exec = spec, ctx, exec
+	return true
+}
+
+func (s *consoleSession) run(name string, args []string) {
+	cmdLog := log.WithField("command", name)
+	_, isTarget := s.spec.Targets[name]
+	if !isTarget && !s.spec.HasCommand(name) {
+		cmdLog.Errorln("command not found, type list for commands")
+		return
+	}
+	if !s.init(name, args) {
+		return
+	}
+	if !isTarget {
+		results, _ := s.exec.RunCommand(s.ctx, name)
+		exportResultsText(s.spec, results, "")
+		reportExpectations(name, results)
+		return
+	}
+	resultsC := make(chan []*executor.CommandResult, 100)
+	go s.exec.RunTarget(s.ctx, name, resultsC)
+	for results := range resultsC {
+		fmt.Printf("%s:\n", results[0].Name)
+		exportResultsText(s.spec, results, "\t")
+		reportExpectations(results[0].Name, results)
+	}
+}
+
+func (s *consoleSession) eval(str string) {
+	if len(str) == 0 {
+		log.Errorln("no expression to evaluate")
+		return
+	}
+	expr, err := s.spec.ParseExpression(s.ctx, str)
+	if err != nil {
+		log.WithError(err).Errorln("failed to parse expression")
+		return
+	}
+	value, err := s.exec.Eval(s.ctx, expr)
+	if err != nil {
+		log.WithError(err).Errorln("failed to evaluate expression")
+		return
+	}
+	fmt.Println(jsonPaddedString(prettify(value), ""))
+}
+
+func (s *consoleSession) balance(names []string) {
+	if len(names) == 0 {
+		for name := range s.spec.Wallets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		name = strings.TrimPrefix(name, "@")
+		wallet, ok := s.spec.Wallets.WalletSpec(name)
+		if !ok {
+			log.WithField("wallet", name).Errorln("wallet not found")
+			continue
+		}
+		expr, err := s.spec.ParseExpression(s.ctx, "@"+name+"."+string(model.WalletSpecBalanceField))
+		if err != nil {
+			log.WithField("wallet", name).WithError(err).Errorln("failed to reference balance")
+			continue
+		}
+		value, err := s.exec.Eval(s.ctx, expr)
+		if err != nil {
+			log.WithField("wallet", name).WithError(err).Errorln("failed to get balance")
+			continue
+		}
+		balance, _ := value.(*big.Int)
+		fmt.Printf("@%s %s: %s ETH\n", name, wallet.Address, model.FormatEther(balance))
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// DeltaRecord is the report of balances --since in the machine-readable output formats,
+// the amounts are in token units, or in the smallest units if raw, the fees in ether.
+type DeltaRecord struct {
+	Since  string                `json:"since" yaml:"since"`
+	Block  uint64                `json:"block" yaml:"block"`
+	Time   time.Time             `json:"time" yaml:"time"`
+	Runs   []string              `json:"runs" yaml:"runs"`
+	Deltas []*BalanceDeltaRecord `json:"deltas" yaml:"deltas"`
+	Fees   map[string]string     `json:"fees" yaml:"fees"`
+	Txs    []*DeltaTxRecord      `json:"txs" yaml:"txs"`
+}
+
+type BalanceDeltaRecord struct {
+	Wallet string `json:"wallet" yaml:"wallet"`
+	Name   string `json:"name" yaml:"name"`
+	Asset  string `json:"asset" yaml:"asset"`
+	Before string `json:"before" yaml:"before"`
+	After  string `json:"after" yaml:"after"`
+	Change string `json:"change" yaml:"change"`
+}
+
+type DeltaTxRecord struct {
+	Run     string `json:"run" yaml:"run"`
+	Command string `json:"command" yaml:"command"`
+	Wallet  string `json:"wallet,omitempty" yaml:"wallet,omitempty"`
+	TxHash  string `json:"txHash" yaml:"txHash"`
+	Failed  bool   `json:"failed,omitempty" yaml:"failed,omitempty"`
+	Fee     string `json:"fee,omitempty" yaml:"fee,omitempty"`
+}
+
+// recordBalances snapshots the balances of the wallets into the journal at the end of the run,
+// so the later runs can be compared with it by balances --since.
+func recordBalances(ctx model.AppContext, exec *executor.Executor, journal *model.RunJournal) {
+	snapshot, err := exec.SnapshotBalances(ctx)
+	if err == nil {
+		err = journal.RecordBalances(snapshot)
+	}
+	if err != nil {
+		log.WithField("run", journal.ID).WithError(err).Warningln("failed to record the balances of the run")
+	}
+}
+
+// deltaAmount formats the amount of the delta in token units, or in the smallest units if raw.
+func deltaAmount(delta *executor.BalanceDelta, amount *big.Int, raw bool) string {
+	if raw {
+		return amount.String()
+	}
+	return model.FormatUnits(amount, delta.Decimals)
+}
+
+// writeDeltas writes the report of what has changed since the run in the format to the output file,
+// or to stdout if not set. The CSV has the balance deltas only.
+func writeDeltas(format, path string, spec *model.Spec, report *executor.DeltaReport, raw bool) error {
+	w, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	label := func(address string) string {
+		if name := spec.Wallets.NameOf(address); len(name) > 0 {
+			return "@" + name
+		}
+		return address
+	}
+	wallets := make([]string, 0, len(report.Fees))
+	for wallet := range report.Fees {
+		wallets = append(wallets, wallet)
+	}
+	sort.Strings(wallets)
+	switch format {
+	case OutputText:
+		fmt.Fprintf(w, "Since %s at block %d (%s), %d runs\n\n", report.Since, report.Block,
+			report.Time.Format("2006-01-02 15:04 UTC"), len(report.Runs))
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		if len(report.Deltas) == 0 {
+			fmt.Fprintln(tw, "  no balances have changed")
+		} else {
+			fmt.Fprintln(tw, "  WALLET\tASSET\tBEFORE\tAFTER\tCHANGE")
+		}
+		for _, delta := range report.Deltas {
+			change := deltaAmount(delta, delta.Change, raw)
+			if delta.Change.Sign() > 0 {
+				change = "+" + change
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", label(delta.Wallet), delta.Asset,
+				deltaAmount(delta, delta.Before, raw), deltaAmount(delta, delta.After, raw), change)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if len(wallets) > 0 {
+			fmt.Fprintln(w)
+			tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(tw, "  WALLET\tFEES (ETH)")
+			for _, wallet := range wallets {
+				fmt.Fprintf(tw, "  %s\t%s\n", label(wallet), model.FormatEther(report.Fees[wallet]))
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+		}
+		if len(report.Txs) > 0 {
+			fmt.Fprintln(w)
+			tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(tw, "  RUN\tCOMMAND\tWALLET\tTRANSACTION\tFEE (ETH)")
+			for _, tx := range report.Txs {
+				fee, wallet := "", ""
+				if tx.Fee != nil {
+					fee, wallet = model.FormatEther(tx.Fee), label(tx.Wallet)
+				}
+				if tx.Failed {
+					fee += " (failed)"
+				}
+				fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", tx.Run, tx.Command, wallet, tx.TxHash, fee)
+			}
+			return tw.Flush()
+		}
+		return nil
+	case OutputCSV:
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write([]string{"wallet", "name", "asset", "before", "after", "change"}); err != nil {
+			return err
+		}
+		for _, delta := range report.Deltas {
+			err := csvWriter.Write([]string{delta.Wallet, delta.Name, delta.Asset, deltaAmount(delta, delta.Before, raw),
+				deltaAmount(delta, delta.After, raw), deltaAmount(delta, delta.Change, raw)})
+			if err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	record := &DeltaRecord{
+		Since:  report.Since,
+		Block:  report.Block,
+		Time:   report.Time,
+		Runs:   report.Runs,
+		Deltas: make([]*BalanceDeltaRecord, 0, len(report.Deltas)),
+		Fees:   make(map[string]string, len(report.Fees)),
+		Txs:    make([]*DeltaTxRecord, 0, len(report.Txs)),
+	}
+	for _, delta := range report.Deltas {
+		record.Deltas = append(record.Deltas, &BalanceDeltaRecord{
+			Wallet: delta.Wallet,
+			Name:   delta.Name,
+			Asset:  delta.Asset,
+			Before: deltaAmount(delta, delta.Before, raw),
+			After:  deltaAmount(delta, delta.After, raw),
+			Change: deltaAmount(delta, delta.Change, raw),
+		})
+	}
+	for _, wallet := range wallets {
+		record.Fees[wallet] = model.FormatEther(report.Fees[wallet])
+	}
+	for _, tx := range report.Txs {
+		txRecord := &DeltaTxRecord{
+			Run:     tx.Run,
+			Command: tx.Command,
+			Wallet:  tx.Wallet,
+			TxHash:  tx.TxHash,
+			Failed:  tx.Failed,
+		}
+		if tx.Fee != nil {
+			txRecord.Fee = model.FormatEther(tx.Fee)
+		}
+		record.Txs = append(record.Txs, txRecord)
+	}
+	return writeStructured(w, format, record)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	cli "github.com/jawher/mow.cli"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+func newDiff(spec *model.Spec) cli.CmdInitializer {
+	return func(cmd *cli.Cmd) {
+		cmd.Spec = "[--all] [VIEW...]"
+		all := cmd.BoolOpt("all", false, "Print the views matching the expectations too")
+		views := cmd.StringsArg("VIEW", nil, "Views to check, all views with expectations by default")
+		cmd.Action = func() {
+			names := *views
+			if len(names) == 0 {
+				for name, cmdSpec := range spec.ViewCmds {
+					if cmdSpec.Expect == nil {
+						continue
+					} else if cmdSpec.ArgCount() > 0 {
+						log.WithField("view", name).Warningln("view accepts args, not checked")
+						continue
+					}
+					names = append(names, name)
+				}
+				sort.Strings(names)
+			}
+			for _, name := range names {
+				cmdSpec, ok := spec.ViewCmds[name]
+				if !ok {
+					log.WithField("view", name).Fatalln("view command not found")
+				} else if cmdSpec.Expect == nil {
+					log.WithField("view", name).Fatalln("view has no expectations")
+				} else if cmdSpec.ArgCount() > 0 {
+					log.WithField("view", name).Fatalln("view accepts args, it can't be checked")
+				}
+			}
+			if len(names) == 0 {
+				log.Infoln("no views with expectations")
+				return
+			}
+			ctx := validateSpec(spec, "diff", []string{"diff"})
+			for _, name := range names {
+				// the spec validates the command being run only, so the views are validated here
+				if !spec.ViewCmds[name].Validate(ctx, name, spec) {
+					os.Exit(exitValidation)
+				}
+			}
+			exec, err := executor.New(ctx, spec)
+			if err != nil {
+				log.WithError(err).Fatalln("failed to init executor")
+			}
+			var (
+				drifted, failed int
+				failedCode      int
+			)
+			for _, name := range names {
+				results, _ := exec.RunCommand(ctx, name)
+				switch code := diffView(spec, name, results, *all); code {
+				case exitOK:
+				case exitFailed:
+					drifted++
+				default:
+					if failed++; failedCode == exitOK {
+						failedCode = code
+					}
+				}
+			}
+			fmt.Printf("\n%d views checked, %d drifted, %d failed\n", len(names), drifted, failed)
+			switch {
+			case failed > 0:
+				os.Exit(failedCode)
+			case drifted > 0:
+				os.Exit(exitFailed)
+			}
+		}
+	}
+}
+
+// diffView prints the results of the view that differ from the expectations, or failed to be read,
+// and returns the exit code of the view: exitFailed on drift, the code of the failure to read it.
+func diffView(spec *model.Spec, name string, results []*executor.CommandResult, all bool) int {
+	colored := useColors(os.Stdout)
+	color := func(c, s string) string {
+		if !colored {
+			return s
+		}
+		return c + s + colorReset
+	}
+	var code int
+	for _, result := range results {
+		header := name
+		if len(result.Wallet) > 0 {
+			header = fmt.Sprintf("%s (@%s)", name, spec.Wallets.NameOf(result.Wallet))
+		}
+		if result.Error == nil {
+			if all {
+				fmt.Printf("  %s: %s\n", header, model.ExpectFormat(result.Result))
+			}
+			continue
+		}
+		expectErr, ok := result.Error.(*model.ExpectationError)
+		if !ok {
+			fmt.Println(color(colorYellow, fmt.Sprintf("! %s: %v", header, result.Error)))
+			if code = failureExitCodes[executor.ClassifyFailure(result.Error)]; code == exitFailed {
+				// the view call has failed
+				code = exitSimulation
+			}
+			continue
+		}
+		fmt.Printf("%s\n", color(colorBold, "~ "+header))
+		fmt.Println(color(colorRed, "  - "+expectErr.Expected))
+		fmt.Println(color(colorGreen, "  + "+expectErr.Actual))
+		if code == exitOK {
+			code = exitFailed
+		}
+	}
+	return code
+}
@@ -0,0 +1,439 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+var (
+	// approve(address,uint256) of ERC-20
+	approveSelector = common.FromHex("0x095ea7b3")
+	// allowance(address,address) of ERC-20
+	allowanceSelector = common.FromHex("0xdd62ed3e")
+	// disperseEther(address[],uint256[])
+	disperseEtherSelector = common.FromHex("0xe63d38ed")
+	// disperseToken(address,address[],uint256[])
+	disperseTokenSelector = common.FromHex("0xc73a2d60")
+	// claim(uint256,address,uint256,bytes32[]) of MerkleDistributor
+	claimSelector = common.FromHex("0x2e7ba6ef")
+	// isClaimed(uint256) of MerkleDistributor
+	isClaimedSelector = common.FromHex("0x9e34070f")
+	// merkleRoot() of MerkleDistributor
+	merkleRootSelector = common.FromHex("0x2eb4a7ab")
+	// token() of MerkleDistributor
+	distributorTokenSelector = common.FromHex("0xfc0c546a")
+)
+
+// Airdrop sends the pending transfers of the journal in chunks of recipients, each chunk is awaited
+// before the next one is sent. The transfers left sent by an interrupted airdrop are awaited first.
+func (e *Executor) Airdrop(ctx model.AppContext, journal *model.AirdropJournal, chunk int) error {
+	wallet, ok := e.root.Wallets.WalletSpec(journal.Wallet)
+	if !ok {
+		return fmt.Errorf("wallet %s not found", journal.Wallet)
+	}
+	var token *common.Address
+	if journal.Asset != EtherAsset {
+		tokenSpec, err := e.root.FindToken(journal.Asset)
+		if err != nil {
+			return err
+		}
+		address := common.HexToAddress(tokenSpec.Address)
+		token = &address
+	}
+	if sent := journal.Sent(); len(sent) > 0 {
+		log.WithField("recipients", len(sent)).Println("awaiting the transfers of the interrupted airdrop")
+		if err := e.awaitAirdrop(ctx, journal, sent, true); err != nil {
+			return err
+		}
+	}
+	pending := journal.Pending()
+	if len(pending) == 0 {
+		return nil
+	}
+	recipients := make([]common.Address, 0, len(pending))
+	for _, recipient := range pending {
+		recipients = append(recipients, common.HexToAddress(recipient.Address))
+	}
+	if err := e.Screen(ctx, recipients...); err != nil {
+		return err
+	}
+	account := common.HexToAddress(wallet.Address)
+	unlock := e.lockWallet(account)
+	defer unlock()
+	sender, err := e.newWalletSender(ctx, wallet)
+	if err != nil {
+		return err
+	}
+	disperse := common.HexToAddress(e.root.Config.DisperseAddress)
+	distributor := common.HexToAddress(journal.Distributor)
+	var distribution *model.MerkleDistribution
+	if journal.Mode == model.AirdropMerkle {
+		if token == nil {
+			return errors.New("the merkle distributor airdrops tokens only")
+		}
+		distribution = model.NewMerkleDistribution(journal.Recipients)
+		if err := e.checkDistributor(ctx, distributor, *token, distribution.MerkleRoot); err != nil {
+			return err
+		}
+		if pending, err = e.unclaimed(ctx, journal, pending, distributor, distribution); err != nil {
+			return err
+		} else if len(pending) == 0 {
+			return nil
+		}
+		if err := e.fundDistributor(ctx, sender, *token, distributor, pending.Total()); err != nil {
+			return fmt.Errorf("failed to fund the merkle distributor: %v", err)
+		}
+	} else if journal.Mode == model.AirdropDisperse {
+		if code, err := e.ethCli.CodeAt(ctx, disperse, nil); err != nil {
+			return err
+		} else if len(code) == 0 {
+			return fmt.Errorf("disperse contract is not deployed at %s", e.root.Config.DisperseAddress)
+		}
+		if token != nil {
+			if _, err := e.approveSpender(ctx, sender, *token, disperse, pending.Total()); err != nil {
+				return fmt.Errorf("failed to approve the disperse contract: %v", err)
+			}
+		}
+	}
+	for start := 0; start < len(pending); start += chunk {
+		end := start + chunk
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+		if journal.Mode == model.AirdropMerkle {
+			err = e.sendClaims(ctx, sender, journal, batch, distributor, distribution)
+		} else if journal.Mode == model.AirdropDisperse {
+			err = e.sendDisperse(ctx, sender, journal, batch, token, disperse)
+		} else {
+			err = e.sendDirect(ctx, sender, journal, batch, token)
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.awaitAirdrop(ctx, journal, batch, false); err != nil {
+			return err
+		}
+		done, failed, left := journal.Counts()
+		log.WithFields(log.Fields{
+			"done":   done,
+			"failed": failed,
+			"left":   left,
+		}).Println("airdrop chunk completed")
+	}
+	return nil
+}
+
+// sendDirect sends a transfer per recipient with consecutive nonces. The hash is recorded before
+// the transaction is sent, so a transfer is never repeated when the process is killed meanwhile,
+// and it's only cleared when the node rejects the transaction.
+func (e *Executor) sendDirect(ctx context.Context, sender *walletSender,
+	journal *model.AirdropJournal, batch model.AirdropRecipients, token *common.Address) error {
+	nonce, err := sender.client.PendingNonceAt(ctx, sender.account)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range batch {
+		to := common.HexToAddress(recipient.Address)
+		value, data := recipient.Value(), []byte(nil)
+		if token != nil {
+			to, value, data = *token, nil, transferData(to, value)
+		}
+		tx, err := e.signWalletTx(ctx, sender, nonce, to, value, data)
+		if err != nil {
+			return err
+		}
+		recipients := model.AirdropRecipients{recipient}
+		if err := journal.Update(recipients, model.JournalSent, strings.ToLower(tx.Hash().Hex()), nil); err != nil {
+			return err
+		}
+		if err := sender.client.SendTransaction(ctx, tx); err != nil {
+			err = e.withRevertReason(ctx, err)
+			if isRejectedTxError(err) {
+				log.WithError(err).WithField("recipient", recipient.Address).Warningln("failed to send the transfer")
+				if err := journal.Update(recipients, model.JournalFailed, "", err); err != nil {
+					return err
+				}
+				// the nonce is not used by the rejected transaction
+				continue
+			}
+			log.WithError(err).WithField("recipient", recipient.Address).Warningln("transfer may have been sent, awaiting it")
+		}
+		nonce++
+	}
+	return nil
+}
+
+// sendDisperse sends the chunk in one call of the disperse contract, ether is sent along with the call.
+func (e *Executor) sendDisperse(ctx context.Context, sender *walletSender, journal *model.AirdropJournal,
+	batch model.AirdropRecipients, token *common.Address, disperse common.Address) error {
+	addresses := make([][]byte, len(batch))
+	values := make([][]byte, len(batch))
+	for i, recipient := range batch {
+		addresses[i] = common.LeftPadBytes(common.HexToAddress(recipient.Address).Bytes(), 32)
+		values[i] = common.LeftPadBytes(recipient.Value().Bytes(), 32)
+	}
+	var data []byte
+	var value *big.Int
+	if token != nil {
+		data = append(append([]byte{}, disperseTokenSelector...), common.LeftPadBytes(token.Bytes(), 32)...)
+		data = append(data, packStaticArrays(1, addresses, values)...)
+	} else {
+		data = append(append([]byte{}, disperseEtherSelector...), packStaticArrays(0, addresses, values)...)
+		value = batch.Total()
+	}
+	nonce, err := sender.client.PendingNonceAt(ctx, sender.account)
+	if err != nil {
+		return err
+	}
+	tx, err := e.signWalletTx(ctx, sender, nonce, disperse, value, data)
+	if err != nil {
+		return err
+	}
+	if err := journal.Update(batch, model.JournalSent, strings.ToLower(tx.Hash().Hex()), nil); err != nil {
+		return err
+	}
+	if err := sender.client.SendTransaction(ctx, tx); err != nil {
+		err = e.withRevertReason(ctx, err)
+		if isRejectedTxError(err) {
+			log.WithError(err).WithField("recipients", len(batch)).Warningln("failed to send the disperse call")
+			return journal.Update(batch, model.JournalFailed, "", err)
+		}
+		log.WithError(err).WithField("recipients", len(batch)).Warningln("disperse call may have been sent, awaiting it")
+	}
+	return nil
+}
+
+// rejectedTxErrors are the errors of the nodes rejecting a transaction before it enters the pool.
+var rejectedTxErrors = []string{
+	"nonce too low",
+	"insufficient funds",
+	"intrinsic gas",
+	"execution reverted",
+}
+
+// isRejectedTxError reports whether the transaction was definitely rejected by the node, so its nonce
+// is free and the recipients can be sent again. Other errors, like timeouts, may come after the node
+// has accepted the transaction, so its recipients stay sent and it's awaited by the hash.
+func isRejectedTxError(err error) bool {
+	if _, ok := revertData(err); ok {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range rejectedTxErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDistributor checks that the distributor is deployed for the token, with the root of the allocations.
+func (e *Executor) checkDistributor(ctx context.Context, distributor, token common.Address, root common.Hash) error {
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &distributor,
+		Data: merkleRootSelector,
+	}, nil)
+	if err != nil {
+		return err
+	} else if len(output) != 32 {
+		return fmt.Errorf("merkle distributor is not deployed at %s", strings.ToLower(distributor.Hex()))
+	} else if common.BytesToHash(output) != root {
+		return fmt.Errorf("merkle root of the distributor is %s, not the root of the allocations %s",
+			common.BytesToHash(output).Hex(), root.Hex())
+	}
+	output, err = e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &distributor,
+		Data: distributorTokenSelector,
+	}, nil)
+	if err != nil {
+		return err
+	} else if len(output) != 32 || common.BytesToAddress(output) != token {
+		return fmt.Errorf("merkle distributor doesn't distribute the token %s", strings.ToLower(token.Hex()))
+	}
+	return nil
+}
+
+// unclaimed returns the pending recipients whose allocations are not claimed yet,
+// the ones claimed by the recipients themselves are marked done.
+func (e *Executor) unclaimed(ctx context.Context, journal *model.AirdropJournal, pending model.AirdropRecipients,
+	distributor common.Address, distribution *model.MerkleDistribution) (model.AirdropRecipients, error) {
+	var unclaimed, claimed model.AirdropRecipients
+	for _, recipient := range pending {
+		claim := distribution.Claims[common.HexToAddress(recipient.Address).Hex()]
+		output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+			To:   &distributor,
+			Data: append(append([]byte{}, isClaimedSelector...), abiWord(claim.Index)...),
+		}, nil)
+		if err != nil {
+			return nil, err
+		} else if len(output) != 32 {
+			return nil, errors.New("isClaimed call reverted")
+		} else if new(big.Int).SetBytes(output).Sign() != 0 {
+			claimed = append(claimed, recipient)
+			continue
+		}
+		unclaimed = append(unclaimed, recipient)
+	}
+	if len(claimed) > 0 {
+		log.WithField("recipients", len(claimed)).Println("allocations already claimed from the distributor")
+		if err := journal.Update(claimed, model.JournalDone, "", nil); err != nil {
+			return nil, err
+		}
+	}
+	return unclaimed, nil
+}
+
+// fundDistributor transfers the tokens the distributor is short of to pay the amount, and awaits the transfer.
+func (e *Executor) fundDistributor(ctx context.Context, sender *walletSender,
+	token, distributor common.Address, amount *big.Int) error {
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &token,
+		Data: append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(distributor.Bytes(), 32)...),
+	}, nil)
+	if err != nil {
+		return err
+	} else if len(output) != 32 {
+		return errBalanceReverted
+	}
+	shortfall := new(big.Int).Sub(amount, new(big.Int).SetBytes(output))
+	if shortfall.Sign() <= 0 {
+		return nil
+	}
+	nonce, err := sender.client.PendingNonceAt(ctx, sender.account)
+	if err != nil {
+		return err
+	}
+	tx, err := e.signWalletTx(ctx, sender, nonce, token, nil, transferData(distributor, shortfall))
+	if err != nil {
+		return err
+	} else if err := sender.client.SendTransaction(ctx, tx); err != nil {
+		return e.withRevertReason(ctx, err)
+	}
+	txHash := strings.ToLower(tx.Hash().Hex())
+	log.WithFields(log.Fields{
+		"tx":     txHash,
+		"amount": shortfall.String(),
+	}).Println("funding the merkle distributor")
+	awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
+	awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+	defer cancelFn()
+	_, err = e.awaitTx(awaitCtx, "tx:"+txHash)
+	return err
+}
+
+// sendClaims claims the allocations of the recipients from the distributor, a claim per recipient
+// with consecutive nonces. As in sendDirect, the hash is recorded before the transaction is sent.
+func (e *Executor) sendClaims(ctx context.Context, sender *walletSender, journal *model.AirdropJournal,
+	batch model.AirdropRecipients, distributor common.Address, distribution *model.MerkleDistribution) error {
+	nonce, err := sender.client.PendingNonceAt(ctx, sender.account)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range batch {
+		account := common.HexToAddress(recipient.Address)
+		claim := distribution.Claims[account.Hex()]
+		proof := make([][]byte, len(claim.Proof))
+		for i, node := range claim.Proof {
+			proof[i] = node.Bytes()
+		}
+		data := append(append([]byte{}, claimSelector...), abiWord(claim.Index)...)
+		data = append(data, common.LeftPadBytes(account.Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(recipient.Value().Bytes(), 32)...)
+		data = append(data, packStaticArrays(3, proof)...)
+		tx, err := e.signWalletTx(ctx, sender, nonce, distributor, nil, data)
+		if err != nil {
+			return err
+		}
+		recipients := model.AirdropRecipients{recipient}
+		if err := journal.Update(recipients, model.JournalSent, strings.ToLower(tx.Hash().Hex()), nil); err != nil {
+			return err
+		}
+		if err := sender.client.SendTransaction(ctx, tx); err != nil {
+			err = e.withRevertReason(ctx, err)
+			if isRejectedTxError(err) {
+				log.WithError(err).WithField("recipient", recipient.Address).Warningln("failed to send the claim")
+				if err := journal.Update(recipients, model.JournalFailed, "", err); err != nil {
+					return err
+				}
+				continue
+			}
+			log.WithError(err).WithField("recipient", recipient.Address).Warningln("claim may have been sent, awaiting it")
+		}
+		nonce++
+	}
+	return nil
+}
+
+// packStaticArrays encodes the arrays of static elements as the trailing args,
+// following the static args of the words count, which are packed by the caller.
+func packStaticArrays(staticWords int, arrays ...[][]byte) []byte {
+	var head, tail []byte
+	offset := uint64((staticWords + len(arrays)) * 32)
+	for _, array := range arrays {
+		head = append(head, abiWord(offset+uint64(len(tail)))...)
+		tail = append(tail, abiWord(uint64(len(array)))...)
+		for _, elem := range array {
+			tail = append(tail, elem...)
+		}
+	}
+	return append(head, tail...)
+}
+
+// awaitAirdrop awaits the transactions of the recipients, marking them done or failed. When resuming,
+// the transactions unknown to the node have not been sent before the interruption, so they are failed
+// to be sent again. Timeouts leave the recipients sent, to be awaited by the next resume.
+func (e *Executor) awaitAirdrop(ctx model.AppContext,
+	journal *model.AirdropJournal, recipients model.AirdropRecipients, resumed bool) error {
+	var hashes []string
+	byHash := make(map[string]model.AirdropRecipients)
+	for _, recipient := range recipients {
+		if recipient.Status != model.JournalSent {
+			continue
+		}
+		if _, ok := byHash[recipient.TxHash]; !ok {
+			hashes = append(hashes, recipient.TxHash)
+		}
+		byHash[recipient.TxHash] = append(byHash[recipient.TxHash], recipient)
+	}
+	awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
+	for _, hash := range hashes {
+		if resumed {
+			_, _, err := e.ethCli.TransactionByHash(ctx, common.HexToHash(hash))
+			if err == ethereum.NotFound {
+				err = errors.New("transaction is not known to the node")
+				if err := journal.Update(byHash[hash], model.JournalFailed, "", err); err != nil {
+					return err
+				}
+				continue
+			} else if err != nil {
+				return err
+			}
+		}
+		awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+		_, err := e.awaitTx(awaitCtx, "tx:"+hash)
+		cancelFn()
+		if _, ok := err.(*TxFailedError); ok {
+			log.WithError(err).WithField("tx", hash).Warningln("airdrop transaction failed")
+			if err := journal.Update(byHash[hash], model.JournalFailed, "", err); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to await %s: %v", hash, err)
+		}
+		if err := journal.Update(byHash[hash], model.JournalDone, "", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
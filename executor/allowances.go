@@ -0,0 +1,175 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// Approval(address,address,uint256) of ERC-20, ERC-721 approvals have the same topic with the token ID indexed.
+var approvalTopic = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+
+// Allowance is the current non-zero allowance of the spender over the token of the wallet,
+// the token is known when it's declared in TOKENS section.
+type Allowance struct {
+	Wallet  *model.WalletSpec
+	Token   common.Address
+	Known   *model.TokenSpec
+	Spender common.Address
+	Amount  *big.Int
+	TxHash  string
+	Error   error
+}
+
+// Allowances finds the spenders approved by the wallets in the Approval logs since the block, of the tokens
+// or of any contract, and reads their allowances as of the latest block, the revoked ones are left out.
+func (e *Executor) Allowances(ctx context.Context, wallets []*model.WalletSpec,
+	tokens []common.Address, from *model.BlockRef) ([]*Allowance, error) {
+	fromBlock, toBlock, err := e.scanRange(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	owners := make([]common.Hash, 0, len(wallets))
+	byOwner := make(map[common.Address]*model.WalletSpec, len(wallets))
+	for _, wallet := range wallets {
+		account := common.HexToAddress(wallet.Address)
+		owners = append(owners, common.BytesToHash(account.Bytes()))
+		byOwner[account] = wallet
+	}
+	logs, err := e.scanLogs(ctx, ethereum.FilterQuery{
+		Addresses: tokens,
+		Topics:    [][]common.Hash{{approvalTopic}, owners},
+	}, fromBlock, toBlock)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var allowances []*Allowance
+	for _, entry := range logs {
+		if len(entry.Topics) != 3 {
+			// ERC-721 approvals of a token ID
+			continue
+		}
+		wallet, ok := byOwner[common.BytesToAddress(entry.Topics[1].Bytes())]
+		if !ok {
+			continue
+		}
+		key := strings.Join([]string{entry.Topics[1].Hex(), entry.Address.Hex(), entry.Topics[2].Hex()}, ":")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		allowances = append(allowances, &Allowance{
+			Wallet:  wallet,
+			Token:   entry.Address,
+			Known:   e.findToken(entry.Address),
+			Spender: common.BytesToAddress(entry.Topics[2].Bytes()),
+		})
+	}
+	sort.SliceStable(allowances, func(i, j int) bool {
+		a, b := allowances[i], allowances[j]
+		if a.Wallet != b.Wallet {
+			return strings.ToLower(a.Wallet.Address) < strings.ToLower(b.Wallet.Address)
+		} else if a.Token != b.Token {
+			return bytes.Compare(a.Token.Bytes(), b.Token.Bytes()) < 0
+		}
+		return bytes.Compare(a.Spender.Bytes(), b.Spender.Bytes()) < 0
+	})
+	current := allowances[:0]
+	for _, allowance := range allowances {
+		owner := common.HexToAddress(allowance.Wallet.Address)
+		data := append(append([]byte{}, allowanceSelector...), common.LeftPadBytes(owner.Bytes(), 32)...)
+		output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+			To:   &allowance.Token,
+			Data: append(data, common.LeftPadBytes(allowance.Spender.Bytes(), 32)...),
+		}, nil)
+		if err != nil {
+			allowance.Error = err
+		} else if len(output) != 32 {
+			// not an ERC-20
+			continue
+		} else if allowance.Amount = new(big.Int).SetBytes(output); allowance.Amount.Sign() == 0 {
+			continue
+		}
+		current = append(current, allowance)
+	}
+	return current, nil
+}
+
+// findToken is the token of the address in TOKENS section, or nil.
+func (e *Executor) findToken(address common.Address) *model.TokenSpec {
+	for _, token := range e.root.Tokens {
+		if token != nil && common.HexToAddress(token.Address) == address {
+			return token
+		}
+	}
+	return nil
+}
+
+// RevokeAllowances sends approve(spender, 0) for the allowances, with consecutive nonces per wallet,
+// and awaits the transactions. The hashes and errors are recorded in the allowances.
+func (e *Executor) RevokeAllowances(ctx model.AppContext, allowances []*Allowance) {
+	byWallet := make(map[*model.WalletSpec][]*Allowance)
+	var wallets []*model.WalletSpec
+	for _, allowance := range allowances {
+		if _, ok := byWallet[allowance.Wallet]; !ok {
+			wallets = append(wallets, allowance.Wallet)
+		}
+		byWallet[allowance.Wallet] = append(byWallet[allowance.Wallet], allowance)
+	}
+	for _, wallet := range wallets {
+		e.revokeWallet(ctx, wallet, byWallet[wallet])
+	}
+}
+
+func (e *Executor) revokeWallet(ctx model.AppContext, wallet *model.WalletSpec, allowances []*Allowance) {
+	fail := func(err error) {
+		for _, allowance := range allowances {
+			if len(allowance.TxHash) == 0 && allowance.Error == nil {
+				allowance.Error = err
+			}
+		}
+	}
+	unlock := e.lockWallet(common.HexToAddress(wallet.Address))
+	defer unlock()
+	sender, err := e.newWalletSender(ctx, wallet)
+	if err != nil {
+		fail(err)
+		return
+	}
+	nonce, err := sender.client.PendingNonceAt(ctx, sender.account)
+	if err != nil {
+		fail(err)
+		return
+	}
+	var sent []*Allowance
+	for _, allowance := range allowances {
+		data := append(append([]byte{}, approveSelector...), common.LeftPadBytes(allowance.Spender.Bytes(), 32)...)
+		data = append(data, make([]byte, 32)...)
+		tx, err := e.signWalletTx(ctx, sender, nonce, allowance.Token, nil, data)
+		if err == nil {
+			err = sender.client.SendTransaction(ctx, tx)
+		}
+		if err != nil {
+			allowance.Error = e.withRevertReason(ctx, err)
+			continue
+		}
+		nonce++
+		allowance.TxHash = strings.ToLower(tx.Hash().Hex())
+		sent = append(sent, allowance)
+	}
+	awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
+	for _, allowance := range sent {
+		awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+		_, allowance.Error = e.awaitTx(awaitCtx, "tx:"+allowance.TxHash)
+		cancelFn()
+	}
+}
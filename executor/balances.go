@@ -0,0 +1,223 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// EtherAsset is the asset name of ether balances in the balance matrix.
+const EtherAsset = "ETH"
+
+// balanceCallsPerMulticall is the max number of balances aggregated into one multicall,
+// so the call stays within the gas cap of eth_call.
+const balanceCallsPerMulticall = 500
+
+var (
+	// getEthBalance(address) of Multicall3
+	getEthBalanceSelector = common.FromHex("0x4d2301cc")
+	// balanceOf(address) of ERC-20
+	balanceOfSelector = common.FromHex("0x70a08231")
+)
+
+// BalanceMatrix is the balances of every wallet in ether and every declared token.
+type BalanceMatrix struct {
+	// Assets are ETH followed by the token symbols, in the order of the row balances.
+	Assets []string
+	Rows   []*BalanceRow
+	// Currency is the fiat currency the balances are valued in, with the Prices of the assets, nil if unknown.
+	Currency string
+	Prices   []*big.Rat
+}
+
+// BalanceRow is the balances of one wallet, in the smallest units and formatted using the decimals.
+type BalanceRow struct {
+	Wallet string
+	Name   string
+	// ENS is the primary name of the wallet, with reverseENS in config.
+	ENS      string
+	Balances []*big.Int
+	Amounts  []string
+	Errors   []error
+	// Values are the balances in the fiat currency, Total is their sum.
+	Values []*big.Rat
+	Total  *big.Rat
+}
+
+type balanceAsset struct {
+	symbol   string
+	token    common.Address
+	decimals int
+}
+
+// Balances fetches the balance matrix of all wallets with addresses, using Multicall3 when it's
+// deployed, the balances are read one by one otherwise. With foldWrapped, the balances of the
+// wrapped native token are added to the ether ones, instead of being a token of their own.
+func (e *Executor) Balances(ctx context.Context, foldWrapped bool) *BalanceMatrix {
+	assets := []*balanceAsset{{
+		symbol:   EtherAsset,
+		decimals: 18,
+	}}
+	var weth common.Address
+	if foldWrapped {
+		var err error
+		if weth, err = e.root.Config.WrappedNative(); err != nil {
+			log.WithError(err).Warningln("wrapped native token is not known, not folding it")
+		}
+	}
+	var tokens []*balanceAsset
+	for name, token := range e.root.Tokens {
+		if token.Decimals == nil || !common.IsHexAddress(token.Address) {
+			log.WithField("token", name).Warningln("token metadata is not known, skipping its balances")
+			continue
+		} else if weth != (common.Address{}) && common.HexToAddress(token.Address) == weth {
+			continue
+		}
+		tokens = append(tokens, &balanceAsset{
+			symbol:   strings.ToUpper(token.Symbol),
+			token:    common.HexToAddress(token.Address),
+			decimals: *token.Decimals,
+		})
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].symbol < tokens[j].symbol
+	})
+	assets = append(assets, tokens...)
+	matrix := &BalanceMatrix{
+		Assets: make([]string, len(assets)),
+	}
+	for i, asset := range assets {
+		matrix.Assets[i] = asset.symbol
+	}
+	if weth != (common.Address{}) {
+		// the last column is folded into the first one once fetched
+		assets = append(assets, &balanceAsset{
+			symbol:   "W" + EtherAsset,
+			token:    weth,
+			decimals: 18,
+		})
+		defer matrix.foldLast()
+	}
+	names := make([]string, 0, len(e.root.Wallets))
+	for name, wallet := range e.root.Wallets {
+		if wallet != nil && common.IsHexAddress(wallet.Address) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		wallet := e.root.Wallets[name]
+		matrix.Rows = append(matrix.Rows, &BalanceRow{
+			Wallet:   strings.ToLower(wallet.Address),
+			Name:     name,
+			ENS:      e.root.LookupENS(ctx, common.HexToAddress(wallet.Address)),
+			Balances: make([]*big.Int, len(assets)),
+			Amounts:  make([]string, len(assets)),
+			Errors:   make([]error, len(assets)),
+		})
+	}
+	multicallAddr := common.HexToAddress(e.root.Config.MulticallAddress)
+	var calls []*multicallCall
+	var cells [][2]int
+	for i, row := range matrix.Rows {
+		account := common.LeftPadBytes(common.HexToAddress(row.Wallet).Bytes(), 32)
+		for j, asset := range assets {
+			call := &multicallCall{
+				Target: asset.token,
+				Data:   append(append([]byte{}, balanceOfSelector...), account...),
+			}
+			if asset.token == (common.Address{}) {
+				// ether
+				call.Target = multicallAddr
+				call.Data = append(append([]byte{}, getEthBalanceSelector...), account...)
+			}
+			calls = append(calls, call)
+			cells = append(cells, [2]int{i, j})
+		}
+	}
+	setBalance := func(cell [2]int, balance *big.Int, err error) {
+		row := matrix.Rows[cell[0]]
+		if err != nil {
+			row.Errors[cell[1]] = err
+			return
+		}
+		row.Balances[cell[1]] = balance
+		row.Amounts[cell[1]] = model.FormatUnits(balance, assets[cell[1]].decimals)
+	}
+	if len(calls) > 0 && e.multicallAvailable(ctx) {
+		for start := 0; start < len(calls); start += balanceCallsPerMulticall {
+			end := start + balanceCallsPerMulticall
+			if end > len(calls) {
+				end = len(calls)
+			}
+			returns, err := e.aggregate(ctx, calls[start:end])
+			if err != nil {
+				log.WithError(err).Warningln("multicall failed, reading balances one by one")
+				e.balancesOneByOne(ctx, assets, matrix, cells[start:], setBalance)
+				return matrix
+			}
+			for i, ret := range returns {
+				if !ret.Success || len(ret.ReturnData) != 32 {
+					setBalance(cells[start+i], nil, errBalanceReverted)
+					continue
+				}
+				setBalance(cells[start+i], new(big.Int).SetBytes(ret.ReturnData), nil)
+			}
+		}
+		return matrix
+	}
+	e.balancesOneByOne(ctx, assets, matrix, cells, setBalance)
+	return matrix
+}
+
+// foldLast adds the balances of the last column to the ether ones and removes it.
+func (matrix *BalanceMatrix) foldLast() {
+	for _, row := range matrix.Rows {
+		last := len(row.Balances) - 1
+		if row.Errors[last] != nil && row.Errors[0] == nil {
+			row.Errors[0] = row.Errors[last]
+		} else if row.Balances[0] != nil && row.Balances[last] != nil {
+			row.Balances[0].Add(row.Balances[0], row.Balances[last])
+			row.Amounts[0] = model.FormatUnits(row.Balances[0], 18)
+		}
+		row.Balances = row.Balances[:last]
+		row.Amounts = row.Amounts[:last]
+		row.Errors = row.Errors[:last]
+	}
+}
+
+var errBalanceReverted = errors.New("balance call reverted")
+
+func (e *Executor) balancesOneByOne(ctx context.Context, assets []*balanceAsset,
+	matrix *BalanceMatrix, cells [][2]int, setBalance func([2]int, *big.Int, error)) {
+	for _, cell := range cells {
+		account := common.HexToAddress(matrix.Rows[cell[0]].Wallet)
+		asset := assets[cell[1]]
+		if asset.token == (common.Address{}) {
+			balance, err := e.ethCli.BalanceAt(ctx, account, nil)
+			setBalance(cell, balance, err)
+			continue
+		}
+		token := asset.token
+		output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+			To:   &token,
+			Data: append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(account.Bytes(), 32)...),
+		}, nil)
+		if err == nil && len(output) != 32 {
+			err = errBalanceReverted
+		}
+		if err != nil {
+			setBalance(cell, nil, err)
+			continue
+		}
+		setBalance(cell, new(big.Int).SetBytes(output), nil)
+	}
+}
@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+type blockHeader struct {
+	Number    *hexutil.Big   `json:"number"`
+	Timestamp hexutil.Uint64 `json:"timestamp"`
+}
+
+// blockParam resolves the block reference into the JSON-RPC block param. Tags and timestamps
+// are resolved to block numbers once per run, so all reads of the run are made at the same block.
+func (e *Executor) blockParam(ctx context.Context, ref *model.BlockRef) (string, error) {
+	if ref == nil {
+		return model.BlockTagLatest, nil
+	}
+	switch {
+	case ref.Number != nil:
+		return hexutil.EncodeBig(ref.Number), nil
+	case ref.Tag == model.BlockTagPending || ref.Tag == model.BlockTagEarliest:
+		return ref.Tag, nil
+	}
+	key := ref.String()
+	e.blocksMux.Lock()
+	defer e.blocksMux.Unlock()
+	if param, ok := e.blocks[key]; ok {
+		return param, nil
+	}
+	var number uint64
+	if len(ref.Tag) > 0 {
+		header, err := e.blockHeader(ctx, ref.Tag)
+		if err != nil {
+			return "", fmt.Errorf("failed to get %s block: %v", ref.Tag, err)
+		}
+		number = header.Number.ToInt().Uint64()
+	} else {
+		found, err := e.findBlockByTime(ctx, ref.Time)
+		if err != nil {
+			return "", err
+		}
+		number = found
+	}
+	param := hexutil.EncodeUint64(number)
+	e.blocks[key] = param
+	return param, nil
+}
+
+func (e *Executor) blockHeader(ctx context.Context, block string) (*blockHeader, error) {
+	var header *blockHeader
+	if err := e.ethRPC.CallContext(ctx, &header, "eth_getBlockByNumber", block, false); err != nil {
+		return nil, err
+	} else if header == nil || header.Number == nil {
+		return nil, errors.New("block not found")
+	}
+	return header, nil
+}
+
+// findBlockByTime finds the latest block mined not after the given time, using binary search.
+func (e *Executor) findBlockByTime(ctx context.Context, t time.Time) (uint64, error) {
+	target := uint64(t.Unix())
+	latest, err := e.blockHeader(ctx, model.BlockTagLatest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest block: %v", err)
+	}
+	if uint64(latest.Timestamp) <= target {
+		return latest.Number.ToInt().Uint64(), nil
+	}
+	lo, hi := uint64(0), latest.Number.ToInt().Uint64()
+	genesis, err := e.blockHeader(ctx, hexutil.EncodeUint64(lo))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get genesis block: %v", err)
+	} else if uint64(genesis.Timestamp) > target {
+		err := fmt.Errorf("time %s is before the genesis block", t.Format(time.RFC3339))
+		return 0, err
+	}
+	// invariant: time(lo) <= target < time(hi)
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		header, err := e.blockHeader(ctx, hexutil.EncodeUint64(mid))
+		if err != nil {
+			return 0, fmt.Errorf("failed to get block %d: %v", mid, err)
+		}
+		if uint64(header.Timestamp) <= target {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
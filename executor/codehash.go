@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// verifyCodeHash fetches the code of a deployed instance and compares its hash with
+// the codehash from the contract spec. Successful checks are cached per address.
+func (e *Executor) verifyCodeHash(ctx context.Context, instance *model.ContractInstanceSpec) error {
+	if instance == nil {
+		return nil
+	}
+	expected, ok := instance.CodeHash()
+	if !ok || !instance.IsDeployed() {
+		return nil
+	}
+	address := common.HexToAddress(instance.Address)
+	e.codeHashesMux.Lock()
+	defer e.codeHashesMux.Unlock()
+	if _, verified := e.codeHashes[address]; verified {
+		return nil
+	}
+	code, err := e.ethCli.CodeAt(ctx, address, nil)
+	if err != nil {
+		err = fmt.Errorf("failed to get contract code: %v", err)
+		return err
+	}
+	if actual := crypto.Keccak256Hash(code); actual != expected {
+		err := fmt.Errorf("contract code at %s doesn't match codehash: expected %s, got %s",
+			strings.ToLower(address.Hex()), expected.Hex(), actual.Hex())
+		return err
+	}
+	e.codeHashes[address] = struct{}{}
+	return nil
+}
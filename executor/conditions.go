@@ -0,0 +1,22 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// checkCondition evaluates the when condition of a command, it returns
+// the reason to skip the command, or an empty string to run it.
+func (e *Executor) checkCondition(ctx model.AppContext, cond *model.Expression) (string, error) {
+	if cond == nil {
+		return "", nil
+	}
+	result, err := e.evalExpression(ctx, cond, model.ExprTypeBool)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate when condition: %v", err)
+	} else if met := result.(bool); met {
+		return "", nil
+	}
+	return fmt.Sprintf("condition not met: %s", cond.Expr), nil
+}
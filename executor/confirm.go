@@ -0,0 +1,236 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// SetApprovals sets the approval tokens of commands requiring confirmation, so they run
+// in non-interactive mode. The tokens are signed with the approval secret by its holder.
+func (e *Executor) SetApprovals(tokens []string) {
+	for _, token := range tokens {
+		e.approvals[strings.ToLower(strings.TrimSpace(token))] = struct{}{}
+	}
+}
+
+// ApprovalTokens returns the approval tokens of the commands requiring confirmation, of the target
+// or the command of the name, keyed by the command names. It fails if no approval secret is set.
+// The commands of the target are planned in order, so the commands using the outputs of the earlier
+// ones are signed as resolved at the run.
+func (e *Executor) ApprovalTokens(ctx model.AppContext, name string) (map[string]string, error) {
+	secret := e.root.Config.ApprovalSecretKey()
+	if len(secret) == 0 {
+		return nil, errors.New("no approvalSecret is set to sign the approval tokens with")
+	}
+	target, isTarget := e.root.Targets[name]
+	if !isTarget {
+		tokens := make(map[string]string)
+		if e.root.CommandConfirm(name) {
+			tokens[name] = approvalToken(secret, e.planHash(ctx, name))
+		}
+		return tokens, nil
+	}
+	plan := e.plan
+	e.plan = true
+	defer func() {
+		e.plan = plan
+	}()
+	tokens := make(map[string]string)
+	for _, cmdName := range target.CmdNames() {
+		if e.root.CommandConfirm(cmdName) {
+			tokens[cmdName] = approvalToken(secret, e.planHash(ctx, cmdName))
+		}
+		e.planTargetCmd(ctx, cmdName)
+	}
+	return tokens, nil
+}
+
+// approvalToken signs the plan hash with the approval secret, so only the holder of the secret
+// approves a reviewed run, and the approval can't be reused to run it with other args.
+func approvalToken(secret string, planHash []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(planHash)
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// planHash identifies the command run with the args on the node group, as resolved: the transactions
+// of write commands from each wallet and iteration, with their recipients, values, calldata and gas
+// settings, and the method and params of CALL commands. The errors resolving them are hashed too,
+// so editing the command in the spec invalidates its approvals.
+func (e *Executor) planHash(ctx model.AppContext, cmdName string) []byte {
+	parts := append([]string{ctx.NodeGroup(), cmdName}, ctx.AppCommandArgs()...)
+	parts = append(parts, "gasPrice="+e.root.Config.GasPrice)
+	if cmdSpec, ok := e.root.WriteCmds[cmdName]; ok {
+		denominations := e.denominations(ctx)
+		specs := cmdSpec.Iterations()
+		if len(specs) == 0 {
+			specs = []*model.WriteCmdSpec{cmdSpec}
+		}
+		for _, spec := range specs {
+			wallets := spec.FanoutWallets()
+			if len(wallets) == 0 && spec.MatchingWallet() != nil {
+				wallets = []*model.WalletSpec{spec.MatchingWallet()}
+			}
+			parts = append(parts, fmt.Sprintf("gasLimit=%d", spec.GasLimit))
+			for _, wallet := range wallets {
+				txs, msgs, err := e.planTxs(ctx, spec, common.HexToAddress(wallet.Address), denominations)
+				if err != nil {
+					parts = append(parts, "error="+err.Error())
+					continue
+				}
+				for i, tx := range txs {
+					parts = append(parts, tx.From, tx.To, tx.Value, hex.EncodeToString(msgs[i].Data))
+				}
+			}
+		}
+	} else if cmdSpec, ok := e.root.CallCmds[cmdName]; ok {
+		parts = append(parts, cmdSpec.Method)
+		params, err := e.replaceReferences(ctx, cmdSpec.ParamValues())
+		if err != nil {
+			parts = append(parts, "error="+err.Error())
+		}
+		for _, param := range params {
+			parts = append(parts, model.ExpectFormat(param))
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return sum[:]
+}
+
+// confirm asks for the approval of the command requiring confirmation: the approval token
+// passed with --approve, an interactive yes if stdin is a terminal, or the approval webhook.
+func (e *Executor) confirm(ctx model.AppContext, cmdName string) error {
+	if !e.root.CommandConfirm(cmdName) {
+		return nil
+	}
+	planHash := e.planHash(ctx, cmdName)
+	if secret := e.root.Config.ApprovalSecretKey(); len(secret) > 0 {
+		if _, ok := e.approvals[approvalToken(secret, planHash)]; ok {
+			return nil
+		}
+	}
+	// only one command asks for approval at a time
+	e.confirmMux.Lock()
+	defer e.confirmMux.Unlock()
+	confirmLog := log.WithFields(log.Fields{
+		"command": cmdName,
+		"plan":    hex.EncodeToString(planHash[:8]),
+	})
+	if e.steps != nil {
+		if e.steps.ConfirmStep(cmdName, e.confirmDescription(cmdName), ctx.NodeGroup()) {
+			return nil
+		}
+		return errors.New("command has not been confirmed")
+	} else if isTerminal(os.Stdin) {
+		desc := e.confirmDescription(cmdName)
+		if len(desc) > 0 {
+			desc = fmt.Sprintf(" (%s)", desc)
+		}
+		fmt.Fprintf(os.Stderr, "Run %s%s on %s? [y/N]: ", cmdName, desc, ctx.NodeGroup())
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return nil
+		}
+		return errors.New("command has not been confirmed")
+	}
+	if webhookURL := e.root.Config.ApprovalWebhook; len(webhookURL) > 0 {
+		awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
+		approvalCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+		defer cancelFn()
+		confirmLog.Infoln("requesting approval from the webhook")
+		approved, reason, err := model.RequestApproval(approvalCtx, webhookURL, &model.ApprovalRequest{
+			Command:     cmdName,
+			Description: e.confirmDescription(cmdName),
+			Args:        ctx.AppCommandArgs(),
+			NodeGroup:   ctx.NodeGroup(),
+			PlanHash:    hex.EncodeToString(planHash),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to request approval: %v", err)
+		} else if !approved {
+			return fmt.Errorf("command has been rejected: %s", reason)
+		}
+		return nil
+	}
+	confirmLog.Warningln("command requires confirmation, pass the token of the approval secret holder with --approve to run it non-interactively")
+	return errors.New("command requires confirmation, re-run with --approve and the token printed by the approve command")
+}
+
+// confirmDescription is the description of the command asked to confirm, followed by the
+// counterparties of the command with their fingerprints, if addressFingerprints is enabled.
+func (e *Executor) confirmDescription(cmdName string) string {
+	desc := e.root.CommandDescription(cmdName)
+	if !e.root.Config.AddressFingerprints {
+		return desc
+	}
+	var parts []string
+	if len(desc) > 0 {
+		parts = append(parts, desc)
+	}
+	for _, address := range e.counterparties(cmdName) {
+		part := fmt.Sprintf("to %s %s", address.Hex(), model.AddressFingerprint(address))
+		if name := e.root.Wallets.NameOf(address.Hex()); len(name) > 0 {
+			part = fmt.Sprintf("to @%s %s %s", name, address.Hex(), model.AddressFingerprint(address))
+		} else if label, ok := e.root.AddressLabel(address); ok {
+			part = fmt.Sprintf("to %s %s %s", label, address.Hex(), model.AddressFingerprint(address))
+		} else if name := e.root.Contracts.NameOf(address.Hex()); len(name) > 0 {
+			part = fmt.Sprintf("to %s %s %s", name, address.Hex(), model.AddressFingerprint(address))
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// counterparties returns the recipients and the address params of the command and of its foreach
+// iterations, resolved during validation.
+func (e *Executor) counterparties(cmdName string) []common.Address {
+	var addresses []common.Address
+	seen := make(map[common.Address]bool)
+	add := func(address common.Address) {
+		if address != (common.Address{}) && !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+	addParams := func(params model.ParamSpec) {
+		for _, value := range params.ParamValues() {
+			switch v := value.(type) {
+			case common.Address:
+				add(v)
+			case *model.WalletFieldReference:
+				if wallet, ok := e.root.Wallets.WalletSpec(v.WalletName); ok && common.IsHexAddress(wallet.Address) {
+					add(common.HexToAddress(wallet.Address))
+				}
+			}
+		}
+	}
+	if cmdSpec, ok := e.root.WriteCmds[cmdName]; ok {
+		for _, spec := range append([]*model.WriteCmdSpec{cmdSpec}, cmdSpec.Iterations()...) {
+			if common.IsHexAddress(spec.To) {
+				add(common.HexToAddress(spec.To))
+			}
+			addParams(spec.ParamSpec)
+		}
+	} else if cmdSpec, ok := e.root.CallCmds[cmdName]; ok {
+		addParams(cmdSpec.ParamSpec)
+	}
+	return addresses
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// Calldata is a contract call decoded using the contract ABIs known to the spec.
+type Calldata struct {
+	TxHash   string                 `json:"tx,omitempty"`
+	To       string                 `json:"to,omitempty"`
+	Value    string                 `json:"value,omitempty"`
+	Contract string                 `json:"contract,omitempty"`
+	Method   string                 `json:"method"`
+	Call     string                 `json:"call"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+}
+
+// Decode decodes raw calldata, or the input of a transaction given by its hash,
+// so externally prepared transactions can be verified before signing.
+func (e *Executor) Decode(ctx context.Context, input string) *CommandResult {
+	data, err := hexutil.Decode(input)
+	if err != nil {
+		return &CommandResult{
+			Error: errors.New("input must be a hex-string of calldata or tx hash"),
+		}
+	}
+	calldata := &Calldata{}
+	// calldata is never 32 bytes long, since it's the selector followed by 32-byte words
+	if len(data) == common.HashLength {
+		tx, _, err := e.ethCli.TransactionByHash(ctx, common.BytesToHash(data))
+		if err != nil {
+			return &CommandResult{
+				Error: fmt.Errorf("failed to get transaction: %v", err),
+			}
+		}
+		calldata.TxHash = strings.ToLower(tx.Hash().Hex())
+		calldata.Value = tx.Value().String()
+		if tx.To() == nil {
+			return &CommandResult{
+				Error: errors.New("transaction is a contract creation"),
+			}
+		}
+		calldata.To = strings.ToLower(tx.To().Hex())
+		data = tx.Data()
+	}
+	if len(data) < 4 {
+		return &CommandResult{
+			Error: errors.New("calldata is too short, no method selector"),
+		}
+	}
+	if err := e.decodeCalldata(ctx, calldata, data); err != nil {
+		return &CommandResult{
+			Error: err,
+		}
+	}
+	return &CommandResult{
+		Result: calldata,
+	}
+}
+
+func (e *Executor) decodeCalldata(ctx context.Context, calldata *Calldata, data []byte) error {
+	contract, method, ok := e.root.Contracts.FindMethod(calldata.To, data[:4])
+	if ok {
+		values, err := method.Inputs.UnpackValues(data[4:])
+		if err != nil {
+			return fmt.Errorf("failed to unpack %s arguments: %v", method.Sig(), err)
+		}
+		calldata.Contract = contract
+		calldata.Method = method.Sig()
+		calldata.Call = formatCall(method.Name, values)
+		calldata.Args = make(map[string]interface{}, len(values))
+		for i, input := range method.Inputs {
+			name := input.Name
+			if len(name) == 0 {
+				name = fmt.Sprintf("arg%d", i)
+			}
+			calldata.Args[name] = jsonValue(values[i])
+		}
+		return nil
+	}
+	selector := hexutil.Encode(data[:4])
+	signature, ok := model.LookupSignature(ctx, e.root.Config, model.SignatureFunction, selector)
+	if !ok {
+		return fmt.Errorf("no method with selector %s in the spec ABIs", selector)
+	}
+	calldata.Method = signature
+	calldata.Call = signature
+	if name, values, ok := decodeBySignature(signature, data[4:]); ok {
+		calldata.Call = formatCall(name, values)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// SnapshotBalances reads the balances of all wallets at the latest block, the balances
+// failed to read are left out of the snapshot.
+func (e *Executor) SnapshotBalances(ctx context.Context) (*model.BalanceSnapshot, error) {
+	header, err := e.blockHeader(ctx, model.BlockTagLatest)
+	if err != nil {
+		return nil, err
+	}
+	matrix := e.Balances(ctx, false)
+	snapshot := &model.BalanceSnapshot{
+		Block:    header.Number.ToInt().Uint64(),
+		Time:     time.Now().UTC(),
+		Balances: make(map[string]map[string]string, len(matrix.Rows)),
+		Decimals: make(map[string]int, len(matrix.Assets)),
+	}
+	for _, asset := range matrix.Assets {
+		snapshot.Decimals[asset] = e.assetDecimals(asset)
+	}
+	for _, row := range matrix.Rows {
+		balances := make(map[string]string, len(matrix.Assets))
+		for i, asset := range matrix.Assets {
+			if row.Errors[i] == nil {
+				balances[asset] = row.Balances[i].String()
+			}
+		}
+		snapshot.Balances[row.Wallet] = balances
+	}
+	return snapshot, nil
+}
+
+// assetDecimals are the decimals of ether or of the token by symbol.
+func (e *Executor) assetDecimals(asset string) int {
+	if token, ok := e.root.Tokens.Find(asset); ok && asset != EtherAsset && token.Decimals != nil {
+		return *token.Decimals
+	}
+	return 18
+}
+
+// BalanceDelta is the change of the balance of the wallet in the asset, in the smallest units.
+type BalanceDelta struct {
+	Wallet   string
+	Name     string
+	Asset    string
+	Decimals int
+	Before   *big.Int
+	After    *big.Int
+	Change   *big.Int
+}
+
+// DeltaTx is a transaction of a run since the compared one, the fee is nil if it's not known.
+type DeltaTx struct {
+	Run     string
+	Command string
+	Wallet  string
+	TxHash  string
+	Failed  bool
+	Fee     *big.Int
+}
+
+// DeltaReport is what has changed since the run: the balance deltas, the fees paid by the wallets
+// and the transactions of the runs started since, which are responsible for the deltas, unless
+// the balances have been moved outside of the playbook.
+type DeltaReport struct {
+	Since  string
+	Block  uint64
+	Time   time.Time
+	Runs   []string
+	Deltas []*BalanceDelta
+	Fees   map[string]*big.Int
+	Txs    []*DeltaTx
+}
+
+// BalanceDeltas compares the current balances of the wallets with the snapshot of the run, and lists
+// the transactions of the runs of the node group started after it with their fees.
+func (e *Executor) BalanceDeltas(ctx context.Context, since *model.RunJournal,
+	runs []*model.RunJournal) (*DeltaReport, error) {
+	if since.Balances == nil {
+		return nil, errors.New("the run has no balances snapshot, it has read no balances at the end")
+	}
+	current, err := e.SnapshotBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &DeltaReport{
+		Since: since.ID,
+		Block: since.Balances.Block,
+		Time:  since.Balances.Time,
+		Fees:  make(map[string]*big.Int),
+	}
+	addresses := make([]string, 0, len(since.Balances.Balances))
+	for wallet := range since.Balances.Balances {
+		addresses = append(addresses, wallet)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		// the wallets and the assets missing from either snapshot have no known delta
+		before, after := since.Balances.Balances[address], current.Balances[address]
+		assets := make(map[string]bool)
+		for asset := range before {
+			if _, ok := after[asset]; ok {
+				assets[asset] = true
+			}
+		}
+		for _, asset := range sortedAssets(assets) {
+			delta := &BalanceDelta{
+				Wallet:   address,
+				Name:     e.root.Wallets.NameOf(address),
+				Asset:    asset,
+				Decimals: current.Decimals[asset],
+				Before:   parseBalance(before[asset]),
+				After:    parseBalance(after[asset]),
+			}
+			if decimals, ok := since.Balances.Decimals[asset]; ok {
+				delta.Decimals = decimals
+			}
+			delta.Change = new(big.Int).Sub(delta.After, delta.Before)
+			if delta.Change.Sign() != 0 {
+				report.Deltas = append(report.Deltas, delta)
+			}
+		}
+	}
+	for _, run := range runs {
+		if run.ID == since.ID || !run.Started.After(since.Started) || run.NodeGroup != since.NodeGroup {
+			continue
+		}
+		report.Runs = append(report.Runs, run.ID)
+		for _, entry := range run.Commands {
+			if entry == nil {
+				continue
+			}
+			for _, result := range entry.Results {
+				for _, receipt := range result.Receipts {
+					tx := &DeltaTx{
+						Run:     run.ID,
+						Command: entry.Name,
+						TxHash:  receipt.TxHash,
+						Failed:  receipt.Status == 0,
+					}
+					hash := common.HexToHash(receipt.TxHash)
+					from, err := e.txSender(ctx, hash)
+					if err == nil {
+						tx.Fee, err = e.txFee(ctx, hash)
+					}
+					if err != nil {
+						log.WithError(err).WithField("tx", receipt.TxHash).Warningln("failed to get the fee of the transaction")
+					} else {
+						tx.Wallet = strings.ToLower(from.Hex())
+						fees, ok := report.Fees[tx.Wallet]
+						if !ok {
+							fees = new(big.Int)
+							report.Fees[tx.Wallet] = fees
+						}
+						fees.Add(fees, tx.Fee)
+					}
+					report.Txs = append(report.Txs, tx)
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// sortedAssets returns ETH first, then the token symbols in order, like the balance matrix.
+func sortedAssets(assets map[string]bool) []string {
+	symbols := make([]string, 0, len(assets))
+	for asset := range assets {
+		if asset != EtherAsset {
+			symbols = append(symbols, asset)
+		}
+	}
+	sort.Strings(symbols)
+	if assets[EtherAsset] {
+		symbols = append([]string{EtherAsset}, symbols...)
+	}
+	return symbols
+}
+
+// parseBalance parses the balance of the snapshot.
+func parseBalance(value string) *big.Int {
+	balance, ok := new(big.Int).SetString(value, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return balance
+}
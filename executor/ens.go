@@ -0,0 +1,306 @@
+package executor
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// ensYear is the registration duration of a year, as the ENS app counts it.
+const ensYear = 365 * 24 * 60 * 60
+
+var (
+	// owner(bytes32) of the registry
+	ensOwnerSelector = common.FromHex("0x02571be3")
+	// resolver(bytes32) of the registry
+	ensResolverSelector = common.FromHex("0x0178b8bf")
+	// setResolver(bytes32,address) of the registry and the NameWrapper
+	ensSetResolverSelector = common.FromHex("0x1896f70a")
+	// setOwner(bytes32,address) of the registry
+	ensSetOwnerSelector = common.FromHex("0x5b0fc9c3")
+	// setAddr(bytes32,address) of the resolver
+	ensSetAddrSelector = common.FromHex("0xd5fa2b00")
+	// setText(bytes32,string,string) of the resolver
+	ensSetTextSelector = common.FromHex("0x10f13a8c")
+	// setContenthash(bytes32,bytes) of the resolver
+	ensSetContenthashSelector = common.FromHex("0x304e6ade")
+	// safeTransferFrom(address,address,uint256) of the BaseRegistrar
+	erc721SafeTransferSelector = common.FromHex("0x42842e0e")
+	// safeTransferFrom(address,address,uint256,uint256,bytes) of the NameWrapper
+	erc1155SafeTransferSelector = common.FromHex("0xf242432a")
+	// the controller methods
+	ensAvailableSelector      = crypto.Keccak256([]byte("available(string)"))[:4]
+	ensRentPriceSelector      = crypto.Keccak256([]byte("rentPrice(string,uint256)"))[:4]
+	ensMinCommitmentSelector  = crypto.Keccak256([]byte("minCommitmentAge()"))[:4]
+	ensMaxCommitmentSelector  = crypto.Keccak256([]byte("maxCommitmentAge()"))[:4]
+	ensCommitSelector         = crypto.Keccak256([]byte("commit(bytes32)"))[:4]
+	ensMakeCommitmentSelector = crypto.Keccak256([]byte("makeCommitment(string,address,uint256,bytes32,address,bytes[],bool,uint16)"))[:4]
+	ensRegisterSelector       = crypto.Keccak256([]byte("register(string,address,uint256,bytes32,address,bytes[],bool,uint16)"))[:4]
+)
+
+// ENSRegister registers the .eth name to the owner for the years, with the public resolver, so the records
+// can be set right away. The commitment is sent first and awaited to mature, the registration is paid
+// with a margin of 10% over the rent price, the excess is refunded by the controller.
+func (e *Executor) ENSRegister(ctx model.AppContext, wallet *model.WalletSpec,
+	name string, years int, owner common.Address) (string, error) {
+	label, err := model.ENSLabel(name)
+	if err != nil {
+		return "", err
+	}
+	deployment, err := e.root.Config.ENSDeployment()
+	if err != nil {
+		return "", err
+	}
+	duration := uint64(years) * ensYear
+	commitment, err := model.LoadENSCommitment(ctx.SpecDir(), name)
+	if err != nil {
+		return "", err
+	}
+	maxAge, err := e.ensUint(ctx, deployment.Controller, ensMaxCommitmentSelector)
+	if err != nil {
+		return "", err
+	}
+	if commitment != nil && (commitment.Owner != owner || commitment.Duration != duration ||
+		time.Since(commitment.Committed) > time.Duration(maxAge)*time.Second) {
+		log.WithField("commitTx", commitment.TxHash).Warningln("commitment is stale or of other terms, committing again")
+		commitment = nil
+	}
+	available, err := e.ensUint(ctx, deployment.Controller, append(ensAvailableSelector, abiStringArgs(label)...))
+	if err != nil {
+		return "", err
+	} else if available == 0 {
+		return "", fmt.Errorf("%s is not available", name)
+	}
+	if commitment == nil {
+		var secret common.Hash
+		if _, err := rand.Read(secret[:]); err != nil {
+			return "", err
+		}
+		commitment = model.NewENSCommitment(ctx.SpecDir(), name, owner, duration, secret, deployment.PublicResolver)
+		args := registrationArgs(label, commitment)
+		hash, err := e.ensCall(ctx, deployment.Controller, append(ensMakeCommitmentSelector, args...))
+		if err != nil {
+			return "", err
+		}
+		commitment.Committed = time.Now().UTC()
+		commitment.TxHash, err = e.sendWalletTx(ctx, wallet, deployment.Controller, nil, append(ensCommitSelector, hash...))
+		if err != nil {
+			return "", err
+		} else if err := commitment.Save(); err != nil {
+			log.WithError(err).Warningln("failed to save the commitment")
+		}
+		log.WithFields(log.Fields{
+			"name":     name,
+			"commitTx": commitment.TxHash,
+		}).Infoln("registration committed")
+	}
+	minAge, err := e.ensUint(ctx, deployment.Controller, ensMinCommitmentSelector)
+	if err != nil {
+		return "", err
+	}
+	// a block of margin, the commitment must be older than the min age at the registration block
+	matured := commitment.Committed.Add(time.Duration(minAge)*time.Second + e.root.Config.BlockTimeDuration())
+	if wait := time.Until(matured); wait > 0 {
+		log.WithField("wait", wait.Round(time.Second)).Infoln("waiting for the commitment to mature")
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	output, err := e.ensCall(ctx, deployment.Controller,
+		append(append(ensRentPriceSelector, abiWord(64)...), append(abiWord(duration), abiBytes([]byte(label))...)...))
+	if err != nil {
+		return "", err
+	} else if len(output) < 64 {
+		return "", errors.New("rentPrice call reverted")
+	}
+	price := new(big.Int).Add(new(big.Int).SetBytes(output[:32]), new(big.Int).SetBytes(output[32:64]))
+	value := new(big.Int).Div(new(big.Int).Mul(price, big.NewInt(110)), big.NewInt(100))
+	txHash, err := e.sendWalletTx(ctx, wallet, deployment.Controller, value,
+		append(ensRegisterSelector, registrationArgs(label, commitment)...))
+	if err != nil {
+		return txHash, err
+	}
+	if err := commitment.Remove(); err != nil {
+		log.WithError(err).Warningln("failed to remove the commitment")
+	}
+	return txHash, nil
+}
+
+// registrationArgs encodes the args of makeCommitment and register, without resolver data, reverse record and fuses.
+func registrationArgs(label string, commitment *model.ENSCommitment) []byte {
+	name := abiBytes([]byte(label))
+	head := abiWord(8 * 32)
+	head = append(head, common.LeftPadBytes(commitment.Owner.Bytes(), 32)...)
+	head = append(head, abiWord(commitment.Duration)...)
+	head = append(head, commitment.Secret.Bytes()...)
+	head = append(head, common.LeftPadBytes(commitment.Resolver.Bytes(), 32)...)
+	head = append(head, abiWord(uint64(8*32+len(name)))...)
+	// reverseRecord, ownerControlledFuses
+	head = append(head, append(abiWord(0), abiWord(0)...)...)
+	// the empty data array
+	return append(append(head, name...), abiWord(0)...)
+}
+
+// abiStringArgs encodes the strings as the only args of the call.
+func abiStringArgs(values ...string) []byte {
+	var head, tail []byte
+	for _, value := range values {
+		head = append(head, abiWord(uint64(len(values)*32+len(tail)))...)
+		tail = append(tail, abiBytes([]byte(value))...)
+	}
+	return append(head, tail...)
+}
+
+// ENSSetResolver sets the resolver of the name, through the NameWrapper for wrapped names.
+func (e *Executor) ENSSetResolver(ctx model.AppContext, wallet *model.WalletSpec,
+	name string, resolver common.Address) (string, error) {
+	deployment, err := e.root.Config.ENSDeployment()
+	if err != nil {
+		return "", err
+	}
+	node := model.ENSNamehash(name)
+	owner, err := e.ensAddress(ctx, deployment.Registry, append(ensOwnerSelector, node.Bytes()...))
+	if err != nil {
+		return "", err
+	}
+	target := deployment.Registry
+	if owner == deployment.NameWrapper {
+		target = deployment.NameWrapper
+	}
+	data := append(append(ensSetResolverSelector, node.Bytes()...), common.LeftPadBytes(resolver.Bytes(), 32)...)
+	return e.sendWalletTx(ctx, wallet, target, nil, data)
+}
+
+// ENSSetAddr sets the ETH address record of the name in its resolver.
+func (e *Executor) ENSSetAddr(ctx model.AppContext, wallet *model.WalletSpec,
+	name string, address common.Address) (string, error) {
+	node := model.ENSNamehash(name)
+	data := append(append(ensSetAddrSelector, node.Bytes()...), common.LeftPadBytes(address.Bytes(), 32)...)
+	return e.sendRecordTx(ctx, wallet, name, data)
+}
+
+// ENSSetText sets the text record of the key of the name in its resolver.
+func (e *Executor) ENSSetText(ctx model.AppContext, wallet *model.WalletSpec, name, key, value string) (string, error) {
+	node := model.ENSNamehash(name)
+	args := abiStringArgs(key, value)
+	// the node is the first arg, the offsets are shifted by its word
+	args = append(append(abiWord(binaryWord(args[:32])+32), abiWord(binaryWord(args[32:64])+32)...), args[64:]...)
+	data := append(append(ensSetTextSelector, node.Bytes()...), args...)
+	return e.sendRecordTx(ctx, wallet, name, data)
+}
+
+// ENSSetContenthash sets the EIP-1577 contenthash of the name in its resolver.
+func (e *Executor) ENSSetContenthash(ctx model.AppContext, wallet *model.WalletSpec,
+	name string, contenthash []byte) (string, error) {
+	node := model.ENSNamehash(name)
+	data := append(append(ensSetContenthashSelector, node.Bytes()...), abiWord(64)...)
+	data = append(data, abiBytes(contenthash)...)
+	return e.sendRecordTx(ctx, wallet, name, data)
+}
+
+func binaryWord(word []byte) uint64 {
+	return new(big.Int).SetBytes(word).Uint64()
+}
+
+// sendRecordTx sends the record update to the resolver of the name.
+func (e *Executor) sendRecordTx(ctx model.AppContext, wallet *model.WalletSpec, name string, data []byte) (string, error) {
+	deployment, err := e.root.Config.ENSDeployment()
+	if err != nil {
+		return "", err
+	}
+	node := model.ENSNamehash(name)
+	resolver, err := e.ensAddress(ctx, deployment.Registry, append(ensResolverSelector, node.Bytes()...))
+	if err != nil {
+		return "", err
+	} else if resolver == (common.Address{}) {
+		return "", fmt.Errorf("%s has no resolver, set it first", name)
+	}
+	return e.sendWalletTx(ctx, wallet, resolver, nil, data)
+}
+
+// ENSTransfer transfers the name to the account: wrapped names are transferred with the NameWrapper,
+// .eth registrations with the BaseRegistrar, after passing the registry ownership, and the other
+// names by setting their owner in the registry.
+func (e *Executor) ENSTransfer(ctx model.AppContext, wallet *model.WalletSpec,
+	name string, to common.Address) ([]string, error) {
+	deployment, err := e.root.Config.ENSDeployment()
+	if err != nil {
+		return nil, err
+	}
+	account := common.HexToAddress(wallet.Address)
+	node := model.ENSNamehash(name)
+	owner, err := e.ensAddress(ctx, deployment.Registry, append(ensOwnerSelector, node.Bytes()...))
+	if err != nil {
+		return nil, err
+	}
+	from := common.LeftPadBytes(account.Bytes(), 32)
+	recipient := common.LeftPadBytes(to.Bytes(), 32)
+	if owner == deployment.NameWrapper {
+		data := append(append(append(erc1155SafeTransferSelector, from...), recipient...), node.Bytes()...)
+		data = append(append(append(data, abiWord(1)...), abiWord(5*32)...), abiWord(0)...)
+		txHash, err := e.sendWalletTx(ctx, wallet, deployment.NameWrapper, nil, data)
+		return []string{txHash}, err
+	}
+	var txHashes []string
+	if owner == account {
+		data := append(append(ensSetOwnerSelector, node.Bytes()...), recipient...)
+		txHash, err := e.sendWalletTx(ctx, wallet, deployment.Registry, nil, data)
+		if txHashes = append(txHashes, txHash); err != nil {
+			return txHashes, err
+		}
+	}
+	if label, err := model.ENSLabel(name); err == nil {
+		labelhash := crypto.Keccak256(([]byte(label)))
+		registrant, err := e.ensAddress(ctx, deployment.BaseRegistrar, append(append([]byte{}, ownerOfSelector...), labelhash...))
+		if err == nil && registrant == account {
+			data := append(append(append(erc721SafeTransferSelector, from...), recipient...), labelhash...)
+			txHash, err := e.sendWalletTx(ctx, wallet, deployment.BaseRegistrar, nil, data)
+			return append(txHashes, txHash), err
+		}
+	}
+	if len(txHashes) == 0 {
+		return nil, fmt.Errorf("%s is not owned by the wallet", name)
+	}
+	return txHashes, nil
+}
+
+func (e *Executor) ensCall(ctx context.Context, contract common.Address, data []byte) ([]byte, error) {
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &contract,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, e.withRevertReason(ctx, err)
+	} else if len(output) < 32 {
+		return nil, fmt.Errorf("call of %s reverted", contract.Hex())
+	}
+	return output, nil
+}
+
+func (e *Executor) ensUint(ctx context.Context, contract common.Address, data []byte) (uint64, error) {
+	output, err := e.ensCall(ctx, contract, data)
+	if err != nil {
+		return 0, err
+	}
+	return binaryWord(output[:32]), nil
+}
+
+func (e *Executor) ensAddress(ctx context.Context, contract common.Address, data []byte) (common.Address, error) {
+	output, err := e.ensCall(ctx, contract, data)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.BytesToAddress(output[:32]), nil
+}
@@ -0,0 +1,208 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// runEnumerate collects the full list from the at(i) style accessor of the view command,
+// the calls are aggregated into multicalls when enabled, otherwise made concurrently.
+func (e *Executor) runEnumerate(ctx model.AppContext, cmdSpec *model.ViewCmdSpec) *CommandResult {
+	enumLog := log.WithFields(log.Fields{
+		"method": cmdSpec.Method,
+		"length": cmdSpec.Enumerate.Length,
+	})
+	params, err := e.replaceReferences(ctx, cmdSpec.ParamValues())
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	opts := &bind.CallOpts{
+		Context: ctx,
+	}
+	lengthValue, err := e.viewValue(ctx, cmdSpec, opts, cmdSpec.Enumerate.Length, params)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to get length: %v", err)}
+	}
+	length, ok := integerValue(lengthValue)
+	if !ok || !length.IsUint64() {
+		return &CommandResult{Error: fmt.Errorf("unexpected length: %v", lengthValue)}
+	}
+	count := int(length.Uint64())
+	binding := cmdSpec.Instance.BoundContract()
+	method := binding.ABI().Methods[cmdSpec.Method]
+	indexType := method.Inputs[len(method.Inputs)-1].Type.Type
+	paramsAt := func(i int) []interface{} {
+		return append(append([]interface{}{}, params...), indexValue(indexType, i))
+	}
+	limiter := newRateLimiter(cmdSpec.Enumerate.Rate)
+	defer limiter.Stop()
+
+	items := make([]interface{}, count)
+	useMulticall := e.root.Config.Multicall && cmdSpec.Block() == nil &&
+		len(cmdSpec.StateOverrides()) == 0 && e.multicallAvailable(ctx)
+	if useMulticall {
+		enumLog.WithField("count", count).Debugln("enumerating using multicall")
+		err = e.enumerateMulticall(ctx, cmdSpec, items, paramsAt, limiter)
+	} else {
+		enumLog.WithField("count", count).Debugln("enumerating using concurrent calls")
+		err = e.enumerateConcurrent(ctx, cmdSpec, opts, items, paramsAt, limiter)
+	}
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	return &CommandResult{Result: items}
+}
+
+func (e *Executor) enumerateMulticall(ctx context.Context, cmdSpec *model.ViewCmdSpec,
+	items []interface{}, paramsAt func(int) []interface{}, limiter *rateLimiter) error {
+	contractABI := cmdSpec.Instance.BoundContract().ABI()
+	target := common.HexToAddress(cmdSpec.Instance.Address)
+	batchSize := cmdSpec.Enumerate.BatchSize
+	for start := 0; start < len(items); start += batchSize {
+		end := start + batchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		calls := make([]*multicallCall, 0, end-start)
+		for i := start; i < end; i++ {
+			data, err := contractABI.Pack(cmdSpec.Method, paramsAt(i)...)
+			if err != nil {
+				return fmt.Errorf("failed to pack call %d: %v", i, err)
+			}
+			calls = append(calls, &multicallCall{
+				Target: target,
+				Data:   data,
+			})
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+		returns, err := e.aggregate(ctx, calls)
+		if err != nil {
+			return fmt.Errorf("multicall of items %d-%d failed: %v", start, end-1, err)
+		}
+		for i, ret := range returns {
+			if !ret.Success {
+				err := errors.New("execution reverted")
+				if reason, ok := e.decodeRevert(ctx, ret.ReturnData); ok {
+					err = fmt.Errorf("execution reverted: %s", reason)
+				}
+				return fmt.Errorf("call of item %d failed: %v", start+i, err)
+			}
+			item, err := unpackValue(contractABI.Unpack, cmdSpec.Method, ret.ReturnData)
+			if err != nil {
+				return fmt.Errorf("failed to unpack item %d: %v", start+i, err)
+			}
+			items[start+i] = item
+		}
+	}
+	return nil
+}
+
+func (e *Executor) enumerateConcurrent(ctx model.AppContext, cmdSpec *model.ViewCmdSpec, opts *bind.CallOpts,
+	items []interface{}, paramsAt func(int) []interface{}, limiter *rateLimiter) error {
+	indices := make(chan int)
+	errs := make(chan error, len(items))
+	wg := new(sync.WaitGroup)
+	for w := 0; w < cmdSpec.Enumerate.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				if err := limiter.Wait(ctx); err != nil {
+					errs <- err
+					continue
+				}
+				item, err := e.viewValue(ctx, cmdSpec, opts, cmdSpec.Method, paramsAt(i))
+				if err != nil {
+					errs <- fmt.Errorf("call of item %d failed: %v", i, err)
+					continue
+				}
+				items[i] = item
+			}
+		}()
+	}
+	for i := range items {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// indexValue converts the index into the Go type of the ABI index input.
+func indexValue(typ reflect.Type, i int) interface{} {
+	if typ == reflect.TypeOf(&big.Int{}) {
+		return big.NewInt(int64(i))
+	}
+	return reflect.ValueOf(i).Convert(typ).Interface()
+}
+
+// rateLimiter limits the calls per second, zero rate means no limit.
+type rateLimiter struct {
+	ticker *time.Ticker
+}
+
+func newRateLimiter(rate int) *rateLimiter {
+	if rate <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{
+		ticker: time.NewTicker(time.Second / time.Duration(rate)),
+	}
+}
+
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l.ticker == nil {
+		return nil
+	}
+	select {
+	case <-l.ticker.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *rateLimiter) Stop() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+}
+
+// integerValue converts the integer of any of the kinds the ABI decoder returns into a big.Int.
+func integerValue(v interface{}) (*big.Int, bool) {
+	switch v := v.(type) {
+	case *big.Int:
+		return v, true
+	case uint8:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case uint16:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case uint32:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case uint64:
+		return new(big.Int).SetUint64(v), true
+	case int8:
+		return big.NewInt(int64(v)), true
+	case int16:
+		return big.NewInt(int64(v)), true
+	case int32:
+		return big.NewInt(int64(v)), true
+	case int64:
+		return big.NewInt(v), true
+	}
+	return nil, false
+}
@@ -0,0 +1,175 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// Event is a transaction log entry decoded using the contract ABIs known to the spec.
+// Logs that don't match any ABI are kept with raw topics and data.
+type Event struct {
+	Address  string                 `json:"address"`
+	Contract string                 `json:"contract,omitempty"`
+	Name     string                 `json:"name,omitempty"`
+	Event    string                 `json:"event,omitempty"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+	Topics   []string               `json:"topics,omitempty"`
+	Data     string                 `json:"data,omitempty"`
+	// Labels are the address book labels of the emitter and the address args, ENS are their
+	// primary names, with reverseENS in config.
+	Labels map[string]string `json:"labels,omitempty"`
+	ENS    map[string]string `json:"ens,omitempty"`
+}
+
+func (e *Executor) decodeEvents(ctx context.Context, logs []*types.Log) []*Event {
+	events := make([]*Event, 0, len(logs))
+	for _, entry := range logs {
+		events = append(events, e.decodeEvent(ctx, entry))
+	}
+	return events
+}
+
+// decodeEvent decodes the log, adding the labels and the ENS names of its accounts.
+func (e *Executor) decodeEvent(ctx context.Context, entry *types.Log) *Event {
+	event := e.decodeLog(ctx, entry)
+	e.annotateEvent(ctx, event, entry.Address)
+	return event
+}
+
+// annotateEvent adds the labels and the ENS names of the emitter and the address args.
+func (e *Executor) annotateEvent(ctx context.Context, event *Event, emitter common.Address) {
+	accounts := []common.Address{emitter}
+	for _, arg := range event.Args {
+		if value, ok := arg.(string); ok && common.IsHexAddress(value) && len(value) == 2+2*common.AddressLength {
+			accounts = append(accounts, common.HexToAddress(value))
+		}
+	}
+	for _, account := range accounts {
+		if label, ok := e.root.AddressLabel(account); ok {
+			if event.Labels == nil {
+				event.Labels = make(map[string]string)
+			}
+			event.Labels[strings.ToLower(account.Hex())] = label
+		}
+		if name := e.root.LookupENS(ctx, account); len(name) > 0 {
+			if event.ENS == nil {
+				event.ENS = make(map[string]string)
+			}
+			event.ENS[strings.ToLower(account.Hex())] = name
+		}
+	}
+}
+
+func (e *Executor) decodeLog(ctx context.Context, entry *types.Log) *Event {
+	address := strings.ToLower(entry.Address.Hex())
+	event := &Event{
+		Address: address,
+	}
+	if len(entry.Topics) > 0 {
+		contract, abiEvent, ok := e.root.Contracts.FindEvent(address, entry.Topics)
+		if ok {
+			if args, text, err := decodeEventArgs(abiEvent, entry); err == nil {
+				event.Contract = contract
+				event.Name = abiEvent.Name
+				event.Event = text
+				event.Args = args
+				return event
+			}
+		}
+	}
+	if len(entry.Topics) > 0 {
+		topic := entry.Topics[0].Hex()
+		if signature, ok := model.LookupSignature(ctx, e.root.Config, model.SignatureEvent, topic); ok {
+			event.Name = signatureName(signature)
+			event.Event = signature
+		}
+	}
+	event.Topics = make([]string, len(entry.Topics))
+	for i, topic := range entry.Topics {
+		event.Topics[i] = topic.Hex()
+	}
+	event.Data = hexutil.Encode(entry.Data)
+	event.Contract = e.root.Contracts.NameOf(address)
+	return event
+}
+
+// decodeEventArgs unpacks indexed arguments from topics and the rest from data,
+// returns the arguments by name and the event formatted as Name(arg1, arg2, ...).
+func decodeEventArgs(abiEvent *abi.Event, entry *types.Log) (map[string]interface{}, string, error) {
+	nonIndexed, err := abiEvent.Inputs.NonIndexed().UnpackValues(entry.Data)
+	if err != nil {
+		return nil, "", err
+	}
+	args := make(map[string]interface{}, len(abiEvent.Inputs))
+	values := make([]interface{}, 0, len(abiEvent.Inputs))
+	topics := entry.Topics[1:]
+	for i, input := range abiEvent.Inputs {
+		var value interface{}
+		if input.Indexed {
+			if len(topics) == 0 {
+				return nil, "", fmt.Errorf("missing topic for indexed argument %d", i)
+			}
+			if value, err = decodeTopic(input.Type, topics[0]); err != nil {
+				return nil, "", err
+			}
+			topics = topics[1:]
+		} else {
+			value, nonIndexed = nonIndexed[0], nonIndexed[1:]
+		}
+		name := input.Name
+		if len(name) == 0 {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		args[name] = jsonValue(value)
+		values = append(values, value)
+	}
+	return args, formatCall(abiEvent.Name, values), nil
+}
+
+// decodeTopic unpacks a static indexed argument, dynamic ones are stored
+// as the keccak256 hash of their value, so the hash is returned as-is.
+func decodeTopic(typ abi.Type, topic common.Hash) (interface{}, error) {
+	switch typ.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy:
+		return topic, nil
+	}
+	values, err := abi.Arguments{{Type: typ}}.UnpackValues(topic.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case common.Address:
+		return strings.ToLower(vv.Hex())
+	case common.Hash:
+		return vv.Hex()
+	case *big.Int:
+		return vv.String()
+	case []byte:
+		return hexutil.Encode(vv)
+	case [32]byte:
+		return hexutil.Encode(vv[:])
+	default:
+		return vv
+	}
+}
+
+// signatureName returns the name part of a text signature like transfer(address,uint256).
+func signatureName(signature string) string {
+	if i := strings.IndexByte(signature, '('); i >= 0 {
+		return signature[:i]
+	}
+	return signature
+}
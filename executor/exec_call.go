@@ -7,24 +7,64 @@ import (
 )
 
 func (e *Executor) runCallCmd(ctx model.AppContext, cmdSpec *model.CallCmdSpec) []*CommandResult {
+	if iterations := cmdSpec.Iterations(); len(iterations) > 0 {
+		conditions := make([]*model.Expression, len(iterations))
+		for i, iteration := range iterations {
+			conditions[i] = iteration.Condition()
+		}
+		return e.runIterations(ctx, conditions, func(i int) []*CommandResult {
+			return e.runCallCmd(ctx, iterations[i])
+		})
+	}
 	matchingWallets := cmdSpec.MatchingWallets()
 	results := make([]*CommandResult, len(matchingWallets))
 	if len(matchingWallets) > 0 {
-		for offset, walletSpec := range matchingWallets {
+		runConcurrently(cmdSpec.Concurrency, len(matchingWallets), func(offset int) {
+			walletSpec := matchingWallets[offset]
 			walletAddress := common.HexToAddress(walletSpec.Address)
-			params := replaceWalletPlaceholders(cmdSpec.ParamValues(), walletAddress)
-			params = replaceReferences(ctx, params, e.root)
+			params, err := e.replaceReferences(ctx, replaceWalletPlaceholders(cmdSpec.ParamValues(), walletAddress))
 			result := &CommandResult{
 				Wallet: walletSpec.Address,
+				Error:  err,
+			}
+			if err == nil && cmdSpec.Method == model.PendingMethod {
+				result.Result, result.Error = e.pendingTxs(ctx, walletAddress)
+			} else if err == nil {
+				result.Error = e.callMethod(ctx, cmdSpec, &result.Result, params)
 			}
-			result.Error = e.ethRPC.CallContext(ctx, &result.Result, cmdSpec.Method, params...)
 			results[offset] = result
-		}
+			e.itemDone(result)
+		})
 		return results
 	}
-	result := &CommandResult{}
-	params := replaceReferences(ctx, cmdSpec.ParamValues(), e.root)
-	result.Error = e.ethRPC.CallContext(ctx, &result.Result, cmdSpec.Method, params...)
+	params, err := e.replaceReferences(ctx, cmdSpec.ParamValues())
+	result := &CommandResult{
+		Error: err,
+	}
+	if err == nil {
+		result.Error = e.callMethod(ctx, cmdSpec, &result.Result, params)
+	}
 	results = append(results, result)
 	return results
 }
+
+// callMethod calls the RPC method with the params, at the block of the command if it's set.
+func (e *Executor) callMethod(ctx model.AppContext, cmdSpec *model.CallCmdSpec, result interface{}, params []interface{}) error {
+	params, err := e.appendBlockParam(ctx, cmdSpec, params)
+	if err != nil {
+		return err
+	}
+	return e.ethRPC.CallContext(ctx, result, cmdSpec.Method, params...)
+}
+
+func (e *Executor) appendBlockParam(ctx model.AppContext,
+	cmdSpec *model.CallCmdSpec, params []interface{}) ([]interface{}, error) {
+	if cmdSpec.Block() == nil {
+		return params, nil
+	}
+	block, err := e.blockParam(ctx, cmdSpec.Block())
+	if err != nil {
+		return nil, err
+	}
+	return append(params, block), nil
+}
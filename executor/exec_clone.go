@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// EIP-1167 minimal proxy creation code, the implementation address goes in between.
+var (
+	cloneCodePrefix = common.FromHex("0x3d602d80600a3d3981f3363d3d373d3d3d363d73")
+	cloneCodeSuffix = common.FromHex("0x5af43d82803e903d91602b57fd5bf3")
+)
+
+func cloneInitCode(implementation common.Address) []byte {
+	code := make([]byte, 0, len(cloneCodePrefix)+common.AddressLength+len(cloneCodeSuffix))
+	code = append(code, cloneCodePrefix...)
+	code = append(code, implementation.Bytes()...)
+	code = append(code, cloneCodeSuffix...)
+	return code
+}
+
+// runCloneCmd deploys minimal proxies pointing at the implementation instance,
+// the result is the list of clone addresses, so it can be referenced by other commands.
+func (e *Executor) runCloneCmd(ctx model.AppContext, cmdSpec *model.WriteCmdSpec,
+	wallet *model.WalletSpec, gasPrice *big.Int) []*CommandResult {
+	result := &CommandResult{}
+	if !cmdSpec.Clone.IsDeployed() {
+		result.Error = errors.New("implementation contract instance is not deployed yet")
+		return []*CommandResult{result}
+	}
+	if err := e.verifyCodeHash(ctx, cmdSpec.Clone); err != nil {
+		result.Error = err
+		return []*CommandResult{result}
+	}
+	account := common.HexToAddress(wallet.Address)
+	pk, err := e.walletKey(wallet)
+	if err != nil {
+		result.Error = err
+		return []*CommandResult{result}
+	}
+	client := e.walletClient(account)
+	nonce, err := client.PendingNonceAt(ctx, account)
+	if err != nil {
+		result.Error = err
+		return []*CommandResult{result}
+	}
+	initCode := cloneInitCode(common.HexToAddress(cmdSpec.Clone.Address))
+	gasLimit, _ := e.root.Config.GasLimitInt()
+	estimatedGasLimit, err := client.EstimateGas(ctx, ethereum.CallMsg{
+		From:     account,
+		GasPrice: gasPrice,
+		Data:     initCode,
+	})
+	if err == nil && estimatedGasLimit < gasLimit {
+		gasLimit = estimatedGasLimit
+	}
+	chainID, _ := e.root.Config.ChainIDInt()
+	signer := types.NewEIP155Signer(chainID)
+	cloneLog := log.WithFields(log.Fields{
+		"contract":       cmdSpec.Clone.Name,
+		"implementation": cmdSpec.Clone.Address,
+	})
+	clones := make([]interface{}, 0, cmdSpec.Count)
+	for i := 0; i < cmdSpec.Count; i++ {
+		tx := types.NewContractCreation(nonce, big.NewInt(0), gasLimit, gasPrice, initCode)
+		signedTx, err := types.SignTx(tx, signer, pk)
+		if err != nil {
+			result.Error = err
+			break
+		} else if err := e.journalTx(ctx, signedTx); err != nil {
+			result.Error = err
+			break
+		}
+		if err := client.SendTransaction(ctx, signedTx); err != nil {
+			result.Error = err
+			break
+		}
+		cloneAddr := crypto.CreateAddress(account, nonce)
+		cloneLog.WithField("address", strings.ToLower(cloneAddr.Hex())).Debugln("clone deployed")
+		clones = append(clones, cloneAddr)
+		result.Txs = append(result.Txs, "tx:"+strings.ToLower(signedTx.Hash().Hex()))
+		nonce++
+	}
+	result.Result = clones
+	return []*CommandResult{result}
+}
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// runOwnershipCmd sends the prepared ownership transfer or renounce calls,
+// AccessControl transfers take two transactions: grant to the new owner and renounce.
+// The renounce is signed only once the grant is mined and the new owner has the role,
+// so a failed grant doesn't leave the contract without an admin.
+func (e *Executor) runOwnershipCmd(ctx model.AppContext, cmdSpec *model.WriteCmdSpec,
+	wallet *model.WalletSpec, gasPrice *big.Int) []*CommandResult {
+	result := &CommandResult{}
+	if !cmdSpec.Instance.IsDeployed() {
+		result.Error = errors.New("contract instance is not deployed yet")
+		return []*CommandResult{result}
+	}
+	if err := e.verifyCodeHash(ctx, cmdSpec.Instance); err != nil {
+		result.Error = err
+		return []*CommandResult{result}
+	}
+	account := common.HexToAddress(wallet.Address)
+	client := e.walletClient(account)
+	nonce, err := client.PendingNonceAt(ctx, account)
+	if err != nil {
+		result.Error = err
+		return []*CommandResult{result}
+	}
+	binding := *cmdSpec.Instance.BoundContract()
+	binding.SetClient(client)
+	awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
+	calls := cmdSpec.OwnershipCalls()
+	for i, call := range calls {
+		opts := &bind.TransactOpts{
+			From:     account,
+			Nonce:    new(big.Int).SetUint64(nonce),
+			Signer:   e.journalSigner(ctx, e.keycache.SignerFn(account, wallet.Password)),
+			GasPrice: gasPrice,
+			GasLimit: 0, // estimate
+			Context:  ctx,
+		}
+		params := replaceWalletPlaceholders(call.Params, account)
+		if err := e.screenTx(ctx, nil, params); err != nil {
+			result.Error = err
+			break
+		}
+		tx, err := binding.Transact(opts, call.Method, params...)
+		if err != nil {
+			result.Error = e.withRevertReason(ctx, err)
+			break
+		}
+		result.Txs = append(result.Txs, "tx:"+strings.ToLower(tx.Hash().Hex()))
+		nonce++
+		if i == len(calls)-1 {
+			break
+		}
+		awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+		_, err = e.awaitTx(awaitCtx, "tx:"+tx.Hash().Hex())
+		cancelFn()
+		if err != nil {
+			result.Error = fmt.Errorf("%s is not mined: %v", call.Method, err)
+			break
+		} else if call.Method == "grantRole" {
+			var granted bool
+			if err := binding.Call(&bind.CallOpts{Context: ctx}, &granted, "hasRole", params...); err != nil {
+				result.Error = fmt.Errorf("failed to check the granted role: %v", e.withRevertReason(ctx, err))
+				break
+			} else if !granted {
+				result.Error = errors.New("role is not granted to the new owner, not renouncing it")
+				break
+			}
+		}
+	}
+	if len(result.Txs) == 1 {
+		result.Result = result.Txs[0]
+		result.Txs = nil
+	} else if len(result.Txs) > 1 {
+		hashes := make([]interface{}, 0, len(result.Txs))
+		for _, hash := range result.Txs {
+			hashes = append(hashes, hash)
+		}
+		result.Result = hashes
+	}
+	return []*CommandResult{result}
+}
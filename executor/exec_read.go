@@ -1,69 +1,185 @@
 package executor
 
 import (
+	"context"
 	"errors"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 
 	"github.com/AtlantPlatform/ethereum-playbook/model"
 )
 
 func (e *Executor) runViewCmd(ctx model.AppContext, cmdSpec *model.ViewCmdSpec) []*CommandResult {
+	if iterations := cmdSpec.Iterations(); len(iterations) > 0 {
+		conditions := make([]*model.Expression, len(iterations))
+		for i, iteration := range iterations {
+			conditions[i] = iteration.Condition()
+		}
+		return e.runIterations(ctx, conditions, func(i int) []*CommandResult {
+			return e.runViewCmd(ctx, iterations[i])
+		})
+	}
+	if cmdSpec.Kind == model.KindGraphQL {
+		return []*CommandResult{e.runGraphQL(ctx, cmdSpec)}
+	}
 	if !cmdSpec.Instance.IsDeployed() {
 		return []*CommandResult{{
 			Error: errors.New("contract instance is not deployed yet"),
 		}}
 	}
+	if err := e.verifyCodeHash(ctx, cmdSpec.Instance); err != nil {
+		return []*CommandResult{{
+			Error: err,
+		}}
+	}
 	binding := cmdSpec.Instance.BoundContract()
 	binding.SetClient(e.ethCli)
 	binding.SetAddress(common.HexToAddress(cmdSpec.Instance.Address))
+	if cmdSpec.Enumerate != nil {
+		return e.finishView(cmdSpec, []*CommandResult{e.runEnumerate(ctx, cmdSpec)})
+	}
 	matchingWallets := cmdSpec.MatchingWallets()
 	results := make([]*CommandResult, len(matchingWallets))
 	if len(matchingWallets) > 0 {
-		for offset, walletSpec := range matchingWallets {
+		runConcurrently(cmdSpec.Concurrency, len(matchingWallets), func(offset int) {
+			walletSpec := matchingWallets[offset]
 			walletAddress := common.HexToAddress(walletSpec.Address)
 			params := replaceWalletPlaceholders(cmdSpec.ParamValues(), walletAddress)
-			params = replaceReferences(ctx, params, e.root)
+			params, err := e.replaceReferences(ctx, params)
 			result := &CommandResult{
 				Wallet: walletSpec.Address,
+				Error:  err,
 			}
 			opts := &bind.CallOpts{
 				From:    walletAddress,
 				Context: ctx,
 			}
-			if err := binding.Call(opts, &result.Result, cmdSpec.Method, params...); err != nil {
-				if strings.HasPrefix(err.Error(), "abi: cannot unmarshal tuple") {
-					storage := newValStorage()
-					result.Error = binding.Call(opts, &storage.pointers, cmdSpec.Method, params...)
-					result.Result = storage.Trim()
-				} else {
-					result.Error = err
-				}
+			if err == nil {
+				e.callView(ctx, cmdSpec, opts, result, params)
 			}
 			results[offset] = result
-		}
-		return results
+			e.itemDone(result)
+		})
+		return e.finishView(cmdSpec, results)
+	}
+	params, err := e.replaceReferences(ctx, cmdSpec.ParamValues())
+	result := &CommandResult{
+		Error: err,
 	}
-	result := &CommandResult{}
-	params := replaceReferences(ctx, cmdSpec.ParamValues(), e.root)
 	opts := &bind.CallOpts{
 		Context: ctx,
 	}
-	if err := binding.Call(opts, &result.Result, cmdSpec.Method, params...); err != nil {
-		if strings.HasPrefix(err.Error(), "abi: cannot unmarshal tuple") {
-			storage := newValStorage()
-			result.Error = binding.Call(opts, &storage.pointers, cmdSpec.Method, params...)
-			result.Result = storage.Trim()
-		} else {
-			result.Error = err
-		}
+	if err == nil {
+		e.callView(ctx, cmdSpec, opts, result, params)
 	}
 	results = append(results, result)
+	return e.finishView(cmdSpec, results)
+}
+
+// checkExpect asserts the results of the view command, failing the mismatching ones.
+func checkExpect(cmdSpec *model.ViewCmdSpec, results []*CommandResult) []*CommandResult {
+	if cmdSpec.Expect == nil {
+		return results
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		result.Error = cmdSpec.Expect.Check(result.Result)
+	}
 	return results
 }
 
+// ExpectationFailed reports whether any of the results failed the view expectations.
+func ExpectationFailed(results []*CommandResult) bool {
+	for _, result := range results {
+		if _, ok := result.Error.(*model.ExpectationError); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// callView calls the view method and unpacks the result, falling back to multiple return values.
+func (e *Executor) callView(ctx model.AppContext, cmdSpec *model.ViewCmdSpec,
+	opts *bind.CallOpts, result *CommandResult, params []interface{}) {
+	result.Result, result.Error = e.viewValue(ctx, cmdSpec, opts, cmdSpec.Method, params)
+}
+
+// viewValue calls the method of the view command contract, respecting its block and state overrides.
+func (e *Executor) viewValue(ctx model.AppContext, cmdSpec *model.ViewCmdSpec,
+	opts *bind.CallOpts, method string, params []interface{}) (interface{}, error) {
+	binding := cmdSpec.Instance.BoundContract()
+	call := func(v interface{}) error {
+		// the binding can't pack struct params
+		if len(cmdSpec.StateOverrides()) > 0 || cmdSpec.Block() != nil || cmdSpec.Instance.HasTupleParams(method) {
+			block, err := e.blockParam(ctx, cmdSpec.Block())
+			if err != nil {
+				return err
+			}
+			return e.callRaw(ctx, cmdSpec.Instance, opts, v, method, block, cmdSpec.StateOverrides(), params...)
+		}
+		return binding.Call(opts, v, method, params...)
+	}
+	var value interface{}
+	if err := call(&value); err != nil {
+		if isTupleError(err) {
+			storage := newValStorage()
+			err := call(&storage.pointers)
+			return storage.Trim(), err
+		}
+		return nil, e.withRevertReason(ctx, err)
+	}
+	return value, nil
+}
+
+// unpackValue unpacks the method output, falling back to multiple return values.
+func unpackValue(unpack func(v interface{}, method string, output []byte) error,
+	method string, output []byte) (interface{}, error) {
+	var value interface{}
+	if err := unpack(&value, method, output); err != nil {
+		if isTupleError(err) {
+			storage := newValStorage()
+			err := unpack(&storage.pointers, method, output)
+			return storage.Trim(), err
+		}
+		return nil, err
+	}
+	return value, nil
+}
+
+func isTupleError(err error) bool {
+	return strings.HasPrefix(err.Error(), "abi: cannot unmarshal tuple")
+}
+
+// callRaw makes eth_call at the given block with account state overrides, the raw RPC call
+// is used, since the contract binding supports neither of those, nor struct params.
+func (e *Executor) callRaw(ctx context.Context, instance *model.ContractInstanceSpec, opts *bind.CallOpts,
+	v interface{}, method, block string, overrides model.StateOverrides, params ...interface{}) error {
+	binding := instance.BoundContract()
+	input, err := instance.Pack(method, params...)
+	if err != nil {
+		return err
+	}
+	args := map[string]interface{}{
+		"from": opts.From,
+		"to":   binding.Address(),
+		"data": hexutil.Bytes(input),
+	}
+	callArgs := []interface{}{args, block}
+	if len(overrides) > 0 {
+		callArgs = append(callArgs, overrides)
+	}
+	var output hexutil.Bytes
+	if err := e.ethRPC.CallContext(ctx, &output, "eth_call", callArgs...); err != nil {
+		return err
+	}
+	return binding.ABI().Unpack(v, method, output)
+}
+
 type valStorage struct {
 	backing  [maxReturnValues]interface{}
 	pointers [maxReturnValues]*interface{}
@@ -4,11 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/big"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 
 	"github.com/AtlantPlatform/ethereum-playbook/model"
 )
@@ -18,41 +23,348 @@ func (e *Executor) runTarget(ctx model.AppContext,
 
 	defer close(out)
 
-	for _, targetCmd := range target {
-		cmdName := targetCmd.Name()
-		if cmdSpec, ok := e.root.CallCmds[cmdName]; ok {
-			results := e.runCallCmd(ctx, cmdSpec)
-			out <- setName(results, cmdName)
-		} else if cmdSpec, ok := e.root.ViewCmds[cmdName]; ok {
-			results := e.runViewCmd(ctx, cmdSpec)
-			out <- setName(results, cmdName)
-		} else if cmdSpec, ok := e.root.WriteCmds[cmdName]; ok {
-			execLog := log.WithFields(log.Fields{
-				"target":  targetName,
-				"command": cmdName,
-			})
-			results := e.runWriteCmd(ctx, cmdSpec)
-			out <- setName(results, cmdName)
-			if len(results) == 0 || results[0].Error != nil {
+	if reason, err := e.checkCondition(ctx, e.root.TargetCondition(targetName)); err != nil {
+		out <- setName([]*CommandResult{{Error: err}}, targetName)
+		return
+	} else if len(reason) > 0 {
+		log.WithFields(log.Fields{
+			"target": targetName,
+			"reason": reason,
+		}).Infoln("skipping target")
+		for _, name := range target.CmdNames() {
+			out <- setName([]*CommandResult{{Skipped: reason}}, name)
+		}
+		return
+	}
+	hooks := e.root.Hooks[targetName]
+	if hooks == nil {
+		e.runTargetCmds(ctx, targetName, target, out)
+		return
+	}
+	if e.runHooks(ctx, targetName, "before", hooks.Before, out) &&
+		e.runTargetCmds(ctx, targetName, target, out) &&
+		e.runHooks(ctx, targetName, "after", hooks.After, out) {
+		return
+	}
+	e.runHooks(ctx, targetName, "onError", hooks.OnError, out)
+}
+
+// runHooks runs the hook commands one by one, it stops at the first failed command and reports false.
+func (e *Executor) runHooks(ctx model.AppContext, targetName, hook string,
+	names []string, out chan<- []*CommandResult) bool {
+	for _, name := range names {
+		hookLog := log.WithFields(log.Fields{
+			"target":  targetName,
+			"hook":    hook,
+			"command": name,
+		})
+		hookLog.Debugln("running hook command")
+		results, stop := e.runTargetStep(ctx, targetName, -1, model.TargetCommandSpec(name))
+		out <- setName(results, name)
+		if stop || hasErrors(results) {
+			hookLog.Errorln("hook command failed")
+			return false
+		}
+	}
+	return true
+}
+
+// runTargetCmds runs the commands of the target, it reports false if the target has been stopped.
+func (e *Executor) runTargetCmds(ctx model.AppContext,
+	targetName string, target model.TargetSpec, out chan<- []*CommandResult) bool {
+	if deps, _ := target.Dependencies(e.root); deps != nil {
+		return e.runTargetGraph(ctx, targetName, target, deps, out)
+	}
+	var completed []string
+	for i := 0; i < len(target); i++ {
+		targetCmd := target[i]
+		if e.root.Config.Multicall {
+			if batch := e.unjournaled(i, e.viewBatch(target[i:])); len(batch) > 1 {
+				for j, results := range e.runViewBatch(ctx, batch) {
+					e.setOutput(batch[j], results)
+					e.recordCompletion(i+j, batch[j], results)
+					out <- setName(results, batch[j])
+					if e.mustStop(targetName, batch[j], results, ExpectationFailed(results)) {
+						log.WithFields(log.Fields{
+							"target":  targetName,
+							"command": batch[j],
+						}).Errorln("stopping target execution — command failed")
+						e.compensate(ctx, targetName, completed, out)
+						return false
+					} else if isCompleted(results) {
+						completed = append(completed, batch[j])
+					}
+				}
+				i += len(batch) - 1
+				continue
+			}
+		}
+		results, stop := e.runTargetStep(ctx, targetName, i, targetCmd)
+		out <- setName(results, targetCmd.Name())
+		if stop {
+			e.compensate(ctx, targetName, completed, out)
+			return false
+		} else if isCompleted(results) {
+			completed = append(completed, targetCmd.Name())
+		}
+	}
+	return true
+}
+
+// runTargetCmd runs the command at the position of the target, awaiting the transactions of non-deferred
+// write commands. It reports whether the target execution must stop. With a run journal, the commands
+// completed before are skipped, and the transactions sent before are awaited instead of sending again.
+func (e *Executor) runTargetCmd(ctx model.AppContext, targetName string,
+	position int, targetCmd model.TargetCommandSpec) ([]*CommandResult, bool) {
+	cmdName := targetCmd.Name()
+	execLog := log.WithFields(log.Fields{
+		"target":  targetName,
+		"command": cmdName,
+	})
+	entry := e.journalEntry(position, cmdName)
+	if entry != nil && entry.Status == model.JournalDone {
+		results := restoreResults(entry)
+		e.setOutput(cmdName, results)
+		execLog.Infoln("skipping command — completed in the resumed run")
+		for _, result := range results {
+			result.Skipped = fmt.Sprintf("completed in run %s", e.journal.ID)
+		}
+		return results, false
+	}
+	if reason, err := e.checkCondition(ctx, e.root.CommandCondition(cmdName)); err != nil {
+		return []*CommandResult{{Error: err}}, false
+	} else if len(reason) > 0 {
+		execLog.WithField("reason", reason).Infoln("skipping command")
+		return []*CommandResult{{Skipped: reason}}, false
+	}
+	if e.plan {
+		results := e.planTargetCmd(ctx, cmdName)
+		return results, ExpectationFailed(results)
+	}
+	if entry == nil || entry.Status != model.JournalSent {
+		// re-attaching to the sent transactions needs no approval
+		if err := e.confirm(ctx, cmdName); err != nil {
+			execLog.WithError(err).Errorln("stopping target execution — command not approved")
+			return []*CommandResult{{Error: err}}, true
+		}
+	}
+	if cmdSpec, ok := e.root.CallCmds[cmdName]; ok {
+		results := e.runCallCmd(ctx, cmdSpec)
+		e.setOutput(cmdName, results)
+		e.recordCompletion(position, cmdName, results)
+		return results, false
+	} else if cmdSpec, ok := e.root.ViewCmds[cmdName]; ok {
+		results := e.runViewCmd(ctx, cmdSpec)
+		e.setOutput(cmdName, results)
+		e.recordCompletion(position, cmdName, results)
+		if ExpectationFailed(results) {
+			execLog.Errorln("stopping target execution — expectation failed")
+			return results, true
+		}
+		return results, false
+	} else if cmdSpec, ok := e.root.WriteCmds[cmdName]; ok {
+		var results []*CommandResult
+		if entry != nil && entry.Status == model.JournalSent {
+			execLog.Infoln("re-attaching to the transactions sent in the resumed run")
+			results = restoreResults(entry)
+		} else {
+			if e.journal != nil && position >= 0 {
+				ctx = withJournalItem(ctx, journalItem{
+					position: position,
+					cmdName:  cmdName,
+				})
+			}
+			results = e.runWriteCmd(ctx, cmdSpec)
+			if hasErrors(results) {
+				e.record(position, cmdName, model.JournalFailed, results, nil)
+				e.setOutput(cmdName, results)
 				execLog.Errorln("stopping target execution — tx sumbit failed")
-				return
+				return results, true
+			}
+			e.record(position, cmdName, model.JournalSent, results, nil)
+		}
+		e.setOutput(cmdName, results)
+		if !targetCmd.IsDeferred() {
+			awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
+			execLog.WithFields(log.Fields{
+				// "handle":  results[0].Result,
+				"timeout": awaitTimeout.String(),
+			}).Debugln("awaiting write command transaction")
+			awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+			defer cancelFn()
+			receipts := make(map[string]*types.Receipt)
+			for _, result := range results {
+				var logs []*types.Log
+				for _, handle := range result.TxHandles() {
+					receipt, err := e.awaitTx(awaitCtx, handle)
+					if err != nil {
+						result.Error = err
+						if receipt != nil {
+							// the transaction has been mined but failed, must be sent again
+							e.dropItemTx(position, cmdName, receipt.TxHash)
+							e.record(position, cmdName, model.JournalFailed, results, receipts)
+						}
+						execLog.WithError(err).Errorln("stopping target execution after await")
+						return results, true
+					}
+					receipts[strings.ToLower(receipt.TxHash.Hex())] = receipt
+					e.recordDeploymentBlock(receipt)
+					result.GasUsed += receipt.GasUsed
+					e.addSpent(ctx, result, receipt)
+					result.Events = append(result.Events, e.decodeEvents(ctx, receipt.Logs)...)
+					logs = append(logs, receipt.Logs...)
+				}
+				checkEventExpectations(cmdSpec, result, logs)
+			}
+			e.record(position, cmdName, model.JournalDone, results, receipts)
+			if ExpectationFailed(results) {
+				execLog.Errorln("stopping target execution — expected event not emitted")
+				return results, true
+			}
+			return results, false
+		}
+		e.record(position, cmdName, model.JournalDone, results, nil)
+		return results, false
+	}
+	return nil, false
+}
+
+// recordCompletion journals results of commands not sending transactions.
+func (e *Executor) recordCompletion(position int, cmdName string, results []*CommandResult) {
+	if hasErrors(results) || ExpectationFailed(results) {
+		e.record(position, cmdName, model.JournalFailed, results, nil)
+		return
+	}
+	e.record(position, cmdName, model.JournalDone, results, nil)
+}
+
+// unjournaled cuts the batch of views starting at the position before the first command
+// having a journal entry, so the completed commands are skipped rather than batched.
+func (e *Executor) unjournaled(position int, batch []string) []string {
+	for i, name := range batch {
+		if e.isJournaled(position+i, name) {
+			return batch[:i]
+		}
+	}
+	return batch
+}
+
+// runTargetGraph runs the target commands as a dependency graph, each command starts once
+// all its dependencies are done, so independent branches run in parallel. When a command
+// fails, the commands needing it are not run. Commands without needs run after all commands
+// before them, as in a sequential target, and not at all once a command has stopped the target.
+// It reports false if any command has stopped.
+func (e *Executor) runTargetGraph(ctx model.AppContext, targetName string,
+	target model.TargetSpec, deps [][]int, out chan<- []*CommandResult) bool {
+	done := make([]chan struct{}, len(target))
+	failed := make([]bool, len(target))
+	// stopped is set for the commands that have stopped the target, or have not run because of that
+	stopped := make([]bool, len(target))
+	skipped := make([]bool, len(target))
+	for i := range target {
+		done[i] = make(chan struct{})
+	}
+	var completed []string
+	completedMux := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	for i := range target {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+			targetCmd := target[i]
+			ordered := len(e.root.CommandNeeds(targetCmd.Name())) == 0
+			for _, dep := range deps[i] {
+				<-done[dep]
 			}
-			if !targetCmd.IsDeferred() {
-				awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
-				execLog.WithFields(log.Fields{
-					// "handle":  results[0].Result,
-					"timeout": awaitTimeout.String(),
-				}).Debugln("awaiting write command transaction")
-				awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
-				if err := e.awaitTx(awaitCtx, results[0].Result); err != nil {
-					execLog.WithError(err).Errorln("stopping target execution after await")
-					cancelFn()
+			for _, dep := range deps[i] {
+				if ordered && stopped[dep] {
+					failed[i], stopped[i], skipped[i] = true, true, true
+					return
+				} else if !ordered && failed[dep] {
+					failed[i], skipped[i] = true, true
+					reason := "failed"
+					if skipped[dep] {
+						reason = "has not run"
+					}
+					log.WithFields(log.Fields{
+						"target":     targetName,
+						"command":    targetCmd.Name(),
+						"dependency": target[dep].Name(),
+					}).Warningln("skipping command — dependency " + reason)
+					out <- setName([]*CommandResult{{
+						Error: fmt.Errorf("skipped, dependency %s %s", target[dep].Name(), reason),
+					}}, targetCmd.Name())
 					return
 				}
-				cancelFn()
 			}
+			results, stop := e.runTargetStep(ctx, targetName, i, targetCmd)
+			failed[i] = stop || hasErrors(results)
+			stopped[i] = stop
+			completedMux.Lock()
+			if !stop && isCompleted(results) {
+				completed = append(completed, targetCmd.Name())
+			}
+			completedMux.Unlock()
+			out <- setName(results, targetCmd.Name())
+		}(i)
+	}
+	wg.Wait()
+	for i := range target {
+		if stopped[i] {
+			e.compensate(ctx, targetName, completed, out)
+			return false
 		}
 	}
+	return true
+}
+
+// compensate runs the onFailure commands of the completed commands in reverse order of completion,
+// after a command of the target has failed. A failing compensation doesn't stop the others.
+func (e *Executor) compensate(ctx model.AppContext, targetName string,
+	completed []string, out chan<- []*CommandResult) {
+	for i := len(completed) - 1; i >= 0; i-- {
+		compensation := e.root.CommandOnFailure(completed[i])
+		if len(compensation) == 0 {
+			continue
+		}
+		compensateLog := log.WithFields(log.Fields{
+			"target":    targetName,
+			"command":   completed[i],
+			"onFailure": compensation,
+		})
+		compensateLog.Warningln("running compensating command")
+		results, stop := e.runTargetStep(ctx, targetName, -1, model.TargetCommandSpec(compensation))
+		if stop || hasErrors(results) {
+			compensateLog.Errorln("compensating command failed")
+		}
+		out <- setName(results, compensation)
+	}
+}
+
+// isCompleted reports whether the command has been run successfully, i.e. not skipped by the condition.
+func isCompleted(results []*CommandResult) bool {
+	if hasErrors(results) {
+		return false
+	}
+	for _, result := range results {
+		if len(result.Skipped) == 0 || result.Result != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func hasErrors(results []*CommandResult) bool {
+	if len(results) == 0 {
+		return true
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			return true
+		}
+	}
+	return false
 }
 
 func setName(results []*CommandResult, name string) []*CommandResult {
@@ -67,50 +379,141 @@ func setName(results []*CommandResult, name string) []*CommandResult {
 	return results
 }
 
-func (e *Executor) awaitTx(ctx context.Context, v interface{}) error {
+func (e *Executor) awaitTx(ctx context.Context, v interface{}) (*types.Receipt, error) {
 	value, ok := v.(string)
 	if !ok {
 		err := fmt.Errorf("unknown result type: %T", v)
-		return err
+		return nil, err
 	}
 	if strings.HasPrefix(value, "tx:") {
 		value = value[3:]
 	} else if !strings.HasPrefix(value, "0x") {
 		err := fmt.Errorf("value is not a hex-string: %s", value)
-		return err
+		return nil, err
 	}
 
-	tx, isPending, err := e.ethCli.TransactionByHash(ctx, common.HexToHash(value))
-	if err != nil {
-		return err
-	} else if !isPending {
-		return nil
+	hash := common.HexToHash(value)
+	tx, isPending, err := e.ethCli.TransactionByHash(ctx, hash)
+	if err == nil && !isPending {
+		return e.txReceipt(ctx, tx)
+	} else if err != nil && err != ethereum.NotFound {
+		return nil, err
 	}
-	t := time.NewTimer(time.Second)
-	defer t.Stop()
+	ctx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+	ticks := e.txTicks(ctx, hash)
 	for {
 		select {
-		case <-t.C:
-			_, isPending, err = e.ethCli.TransactionByHash(ctx, tx.Hash())
+		case <-ticks:
+			tx, isPending, err = e.ethCli.TransactionByHash(ctx, hash)
 			if err == nil && !isPending {
-				receipt, err := e.ethCli.TransactionReceipt(ctx, tx.Hash())
-				if err != nil {
-					return err
-				} else if status := receipt.Status; status == 0 {
-					err := errors.New("transction execution ended with failing status code")
-					return err
-				}
-				// finally a transaction receipt,
-				// with a successful status
-				return nil
+				return e.txReceipt(ctx, tx)
+			} else if err == ethereum.NotFound {
+				// the transaction sent to another node may not have reached this one yet
+				log.WithField("tx", hash.Hex()).Debugln("transaction is not known to the node yet")
 			} else if err != nil {
 				log.WithError(err).Warningln("error while checking the transaction status")
-				t.Reset(10 * time.Second)
-				continue
+				select {
+				case <-time.After(10 * time.Second):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
 			}
-			t.Reset(time.Second)
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// txReceipt gets the receipt of the mined transaction, it returns *TxFailedError
+// along with the receipt if the transaction has ended with a failing status.
+func (e *Executor) txReceipt(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	receipt, err := e.ethCli.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		return nil, err
+	} else if receipt.Status == types.ReceiptStatusFailed {
+		err := &TxFailedError{
+			Hash: tx.Hash(),
+		}
+		if reason, ok := e.txRevertReason(ctx, tx); ok {
+			err.Reason = reason
 		}
+		return receipt, err
+	}
+	return receipt, nil
+}
+
+// addSpent adds the value and the fees of the mined transaction to the ether spent by the command.
+func (e *Executor) addSpent(ctx context.Context, result *CommandResult, receipt *types.Receipt) {
+	tx, _, err := e.ethCli.TransactionByHash(ctx, receipt.TxHash)
+	if err != nil {
+		log.WithError(err).Debugln("failed to get the transaction to count the ether spent")
+		return
+	}
+	fee := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(receipt.GasUsed))
+	if result.Spent == nil {
+		result.Spent = new(big.Int)
+	}
+	result.Spent.Add(result.Spent, fee.Add(fee, tx.Value()))
+}
+
+func (e *Executor) recordDeploymentBlock(receipt *types.Receipt) {
+	if receipt == nil || receipt.ContractAddress == (common.Address{}) {
+		return
+	}
+	block, err := e.txBlockNumber(context.Background(), receipt.TxHash)
+	if err != nil {
+		log.WithError(err).Warningln("failed to get block of the deployment transaction")
+		return
+	}
+	txHash := strings.ToLower(receipt.TxHash.Hex())
+	if err := model.UpdateDeploymentBlock(e.specDir, e.root.Config.ChainID, txHash, block); err != nil {
+		log.WithError(err).Warningln("failed to update deployments registry")
+	}
+}
+
+// deploymentBlocksTimeout bounds looking up the blocks of the earlier deployments before a new one.
+const deploymentBlocksTimeout = 10 * time.Second
+
+// recordDeploymentBlocks sets the block numbers missing in the deployments registry, of the contracts
+// deployed by single write commands and deferred target commands, whose transactions are not awaited.
+// It's done on the next deployment to the chain, within deploymentBlocksTimeout.
+func (e *Executor) recordDeploymentBlocks(ctx context.Context) {
+	ctx, cancelFn := context.WithTimeout(ctx, deploymentBlocksTimeout)
+	defer cancelFn()
+	file, err := model.LoadDeployments(e.specDir, e.root.Config.ChainID)
+	if err != nil {
+		log.WithError(err).Warningln("failed to load deployments registry")
+		return
+	}
+	for key, record := range file.Instances {
+		if record.Block > 0 || len(record.TxHash) == 0 {
+			continue
+		}
+		block, err := e.txBlockNumber(ctx, common.HexToHash(record.TxHash))
+		if err != nil {
+			log.WithFields(log.Fields{
+				"instance": key,
+				"txHash":   record.TxHash,
+			}).WithError(err).Debugln("block of the deployment transaction is not known yet")
+			continue
+		}
+		if err := model.UpdateDeploymentBlock(e.specDir, e.root.Config.ChainID, record.TxHash, block); err != nil {
+			log.WithError(err).Warningln("failed to update deployments registry")
+		}
+	}
+}
+
+// txBlockNumber gets the block number of a mined transaction, because receipts
+// in this version of go-ethereum don't carry the block fields.
+func (e *Executor) txBlockNumber(ctx context.Context, txHash common.Hash) (uint64, error) {
+	var info struct {
+		BlockNumber *hexutil.Big `json:"blockNumber"`
+	}
+	if err := e.ethRPC.CallContext(ctx, &info, "eth_getTransactionReceipt", txHash); err != nil {
+		return 0, err
+	} else if info.BlockNumber == nil {
+		return 0, errors.New("transaction is not mined yet")
 	}
+	return info.BlockNumber.ToInt().Uint64(), nil
 }
@@ -3,6 +3,7 @@ package executor
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"strings"
 
 	"github.com/AtlantPlatform/ethfw"
@@ -16,6 +17,31 @@ import (
 )
 
 func (e *Executor) runWriteCmd(ctx model.AppContext, cmdSpec *model.WriteCmdSpec) []*CommandResult {
+	if iterations := cmdSpec.Iterations(); len(iterations) > 0 {
+		conditions := make([]*model.Expression, len(iterations))
+		for i, iteration := range iterations {
+			conditions[i] = iteration.Condition()
+		}
+		return e.runIterations(ctx, conditions, func(i int) []*CommandResult {
+			return e.runWriteCmd(withIteration(ctx, i), iterations[i])
+		})
+	}
+	denominations := e.denominations(ctx)
+	if wallets := cmdSpec.FanoutWallets(); len(wallets) > 0 {
+		results := make([]*CommandResult, len(wallets))
+		runConcurrently(cmdSpec.Concurrency, len(wallets), func(i int) {
+			result := e.runWriteCmdFrom(ctx, cmdSpec, wallets[i], denominations)[0]
+			result.Wallet = wallets[i].Address
+			results[i] = result
+			e.itemDone(result)
+		})
+		return results
+	}
+	return e.runWriteCmdFrom(ctx, cmdSpec, cmdSpec.MatchingWallet(), denominations)
+}
+
+// denominations are the lowercase symbols of the deployed token instances, usable as value denominators.
+func (e *Executor) denominations(ctx model.AppContext) []string {
 	var denominations []string
 	for name, contract := range e.root.Contracts {
 		for _, instance := range contract.Instances {
@@ -35,35 +61,63 @@ func (e *Executor) runWriteCmd(ctx model.AppContext, cmdSpec *model.WriteCmdSpec
 			}
 		}
 	}
+	return denominations
+}
+
+// runWriteCmdFrom runs the write command from the wallet. In a journaled target, the transactions
+// are journaled before they are sent, and the ones journaled by the resumed run are re-attached to
+// instead of being sent again.
+func (e *Executor) runWriteCmdFrom(ctx model.AppContext, cmdSpec *model.WriteCmdSpec,
+	wallet *model.WalletSpec, denominations []string) []*CommandResult {
+	item, ok := journalItemOf(ctx)
+	if !ok || e.journal == nil || e.plan {
+		return e.sendWriteCmdFrom(ctx, cmdSpec, wallet, denominations)
+	}
+	item.wallet = strings.ToLower(wallet.Address)
+	if result, ok := e.reattach(ctx, item); ok {
+		return []*CommandResult{result}
+	}
+	results := e.sendWriteCmdFrom(withJournalItem(ctx, item), cmdSpec, wallet, denominations)
+	e.recordItemSent(ctx, item, results[0])
+	return results
+}
+
+// sendWriteCmdFrom sends the transaction of the write command from the wallet. Sending
+// is serialized per wallet, so concurrent commands don't get the same pending nonce.
+func (e *Executor) sendWriteCmdFrom(ctx model.AppContext, cmdSpec *model.WriteCmdSpec,
+	wallet *model.WalletSpec, denominations []string) []*CommandResult {
 	var binding *ethfw.BoundContract
+	target := cmdSpec.Instance
 	if cmdSpec.Instance != nil {
 		binding = cmdSpec.Instance.BoundContract()
 		binding.SetClient(e.ethCli)
 		// if deployed, the address has been set in loops above
 	}
 	result := &CommandResult{}
-	wallet := cmdSpec.MatchingWallet()
 	account := common.HexToAddress(wallet.Address)
-	balance, err := e.ethCli.BalanceAt(ctx, account, nil)
+	unlock := e.lockWallet(account)
+	defer unlock()
+	client := e.walletClient(account)
+	balance, err := client.BalanceAt(ctx, account, nil)
 	if err != nil {
 		result.Error = err
 		return []*CommandResult{result}
 	}
 	wallet.Balance = balance
-	gasPrice, _ := e.root.Config.GasPriceInt()
-	suggestedGas, err := e.ethCli.SuggestGasPrice(ctx)
-	if err == nil && suggestedGas.Cmp(gasPrice) > 0 {
-		gasPrice = suggestedGas
+	gasPrice := e.gasPrice(ctx)
+	if e.plan {
+		return e.planWriteCmd(ctx, cmdSpec, wallet, gasPrice, denominations)
 	}
-	var value model.ExtendedValue
-	if len(cmdSpec.Value) > 0 {
-		v, err := cmdSpec.Value.Parse(ctx, e.root, denominations)
-		if err != nil {
-			result.Error = err
-			return []*CommandResult{result}
-		}
-		value.Value = v.Value
-		value.Denominator = v.Denominator
+	if cmdSpec.Clone != nil {
+		return e.runCloneCmd(ctx, cmdSpec, wallet, gasPrice)
+	}
+	if len(cmdSpec.OwnershipCalls()) > 0 {
+		return e.runOwnershipCmd(ctx, cmdSpec, wallet, gasPrice)
+	}
+	value, err := e.writeValue(ctx, cmdSpec, denominations)
+	if err != nil {
+		result.Error = err
+		return []*CommandResult{result}
 	}
 
 	denominatorCommonOrEmpty := len(value.Denominator) == 0 || model.IsCommonDenominator(value.Denominator)
@@ -78,23 +132,28 @@ func (e *Executor) runWriteCmd(ctx model.AppContext, cmdSpec *model.WriteCmdSpec
 			Value:    value.Value,
 			Data:     nil,
 		}
-		nonce, err := e.ethCli.PendingNonceAt(ctx, account)
+		nonce, err := client.PendingNonceAt(ctx, account)
 		if err != nil {
 			result.Error = err
 			return []*CommandResult{result}
 		}
-		gasLimit, _ := e.root.Config.GasLimitInt()
-		estimatedGasLimit, err := e.ethCli.EstimateGas(ctx, callMsg)
-		if err == nil && estimatedGasLimit < gasLimit {
-			gasLimit = estimatedGasLimit
+		gasLimit := cmdSpec.GasLimit
+		if gasLimit == 0 {
+			gasLimit, _ = e.root.Config.GasLimitInt()
+			estimatedGasLimit, err := client.EstimateGas(ctx, callMsg)
+			if err == nil && estimatedGasLimit < gasLimit {
+				gasLimit = estimatedGasLimit
+			}
 		}
 		tx := types.NewTransaction(nonce, to, value.Value, gasLimit, gasPrice, nil)
-		pk, ok := e.keycache.PrivateKey(account, wallet.Password)
-		if !ok {
-			if pk = wallet.PrivKeyECDSA(); pk == nil {
-				result.Error = errors.New("failed to get account private key")
-				return []*CommandResult{result}
-			}
+		if err := e.screenTx(ctx, &to, nil); err != nil {
+			result.Error = err
+			return []*CommandResult{result}
+		}
+		pk, err := e.walletKey(wallet)
+		if err != nil {
+			result.Error = err
+			return []*CommandResult{result}
 		}
 		chainID, _ := e.root.Config.ChainIDInt()
 		signer := types.NewEIP155Signer(chainID)
@@ -102,27 +161,40 @@ func (e *Executor) runWriteCmd(ctx model.AppContext, cmdSpec *model.WriteCmdSpec
 		if err != nil {
 			result.Error = err
 			return []*CommandResult{result}
+		} else if err := e.journalTx(ctx, signedTx); err != nil {
+			result.Error = err
+			return []*CommandResult{result}
 		}
-		result.Error = e.ethCli.SendTransaction(ctx, signedTx)
+		result.Error = e.withRevertReason(ctx, client.SendTransaction(ctx, signedTx))
 		result.Result = "tx:" + strings.ToLower(signedTx.Hash().Hex())
 		return []*CommandResult{result}
 	}
 	if denominatorCommonOrEmpty && !cmdSpec.Instance.IsDeployed() {
 		// need to deploy an instance
-		params := replaceWalletPlaceholders(cmdSpec.ParamValues(), account)
-		params = replaceReferences(ctx, params, e.root)
+		if len(cmdSpec.Instance.BoundContract().Source().Bin) == 0 {
+			result.Error = errors.New("contract has no bytecode to deploy")
+			return []*CommandResult{result}
+		}
+		params, err := e.replaceReferences(ctx, replaceWalletPlaceholders(cmdSpec.ParamValues(), account))
+		if err != nil {
+			result.Error = err
+			return []*CommandResult{result}
+		} else if err := e.screenTx(ctx, nil, params); err != nil {
+			result.Error = err
+			return []*CommandResult{result}
+		}
 		opts := &bind.TransactOpts{
 			From:     account,
 			Nonce:    nil, // pending state
-			Signer:   e.keycache.SignerFn(account, wallet.Password),
+			Signer:   e.journalSigner(ctx, e.keycache.SignerFn(account, wallet.Password)),
 			Value:    value.Value,
 			GasPrice: gasPrice,
-			GasLimit: 0, // estimate
+			GasLimit: cmdSpec.GasLimit, // estimated if not set
 			Context:  ctx,
 		}
-		contractAddr, tx, err := cmdSpec.Instance.BoundContract().DeployContract(opts, params...)
+		contractAddr, tx, err := deployContract(client, opts, cmdSpec.Instance, params)
 		if err != nil {
-			result.Error = err
+			result.Error = e.withRevertReason(ctx, err)
 			return []*CommandResult{result}
 		}
 		cmdSpec.Instance.Address = strings.ToLower(contractAddr.Hex())
@@ -136,13 +208,22 @@ func (e *Executor) runWriteCmd(ctx model.AppContext, cmdSpec *model.WriteCmdSpec
 		} else {
 			contractLog.Println("contract deployed")
 		}
+		e.recordDeploymentBlocks(ctx)
+		record := &model.DeploymentRecord{
+			Address: cmdSpec.Instance.Address,
+			TxHash:  strings.ToLower(tx.Hash().Hex()),
+		}
+		if err := model.RecordDeployment(e.specDir, e.root.Config.ChainID,
+			e.root.Contracts.DeploymentKey(cmdSpec.Instance), record); err != nil {
+			contractLog.WithError(err).Warningln("failed to record deployment")
+		}
 		result.Result = "tx:" + strings.ToLower(tx.Hash().Hex())
 		return []*CommandResult{result}
 	}
 	// at this point, contract is deployed and we just want to use its method
 	var params []interface{}
 	if len(value.Denominator) > 0 {
-		instance, ok := e.root.Contracts.FindByTokenSymbol(value.Denominator)
+		instance, ok := e.root.FindTokenInstance(value.Denominator)
 		if !ok {
 			result.Error = fmt.Errorf("referenced token contract not found: %s", value.Denominator)
 			return []*CommandResult{result}
@@ -152,6 +233,7 @@ func (e *Executor) runWriteCmd(ctx model.AppContext, cmdSpec *model.WriteCmdSpec
 		}
 		// override binding with other referenced contract
 		binding = instance.BoundContract()
+		target = instance
 		if len(cmdSpec.To) == 0 {
 			result.Error = errors.New("no transfer recipient address specified")
 			return []*CommandResult{result}
@@ -160,22 +242,85 @@ func (e *Executor) runWriteCmd(ctx model.AppContext, cmdSpec *model.WriteCmdSpec
 		cmdSpec.Method = "transfer"
 		params = []interface{}{to, value.Value}
 	} else {
-		params = replaceWalletPlaceholders(cmdSpec.ParamValues(), account)
-		params = replaceReferences(ctx, params, e.root)
+		var err error
+		if params, err = e.replaceReferences(ctx, replaceWalletPlaceholders(cmdSpec.ParamValues(), account)); err != nil {
+			result.Error = err
+			return []*CommandResult{result}
+		}
+	}
+	if err := e.verifyCodeHash(ctx, target); err != nil {
+		result.Error = err
+		return []*CommandResult{result}
+	} else if err := e.screenTx(ctx, nil, params); err != nil {
+		result.Error = err
+		return []*CommandResult{result}
 	}
 	opts := &bind.TransactOpts{
 		From:     account,
 		Nonce:    nil, // pending state
-		Signer:   e.keycache.SignerFn(account, wallet.Password),
+		Signer:   e.journalSigner(ctx, e.keycache.SignerFn(account, wallet.Password)),
 		GasPrice: gasPrice,
-		GasLimit: 0, // estimate
+		GasLimit: cmdSpec.GasLimit, // estimated if not set
 		Context:  ctx,
 	}
-	tx, err := binding.Transact(opts, cmdSpec.Method, params...)
+	tx, err := transactMethod(client, opts, target, cmdSpec.Method, params)
 	if err != nil {
-		result.Error = err
+		result.Error = e.withRevertReason(ctx, err)
+		if _, ok := revertData(err); ok {
+			return []*CommandResult{result}
+		}
+		// gas estimation errors don't carry the revert data, so simulate the call
+		if input, packErr := target.Pack(cmdSpec.Method, params...); packErr == nil {
+			to := binding.Address()
+			callMsg := ethereum.CallMsg{
+				From:     account,
+				To:       &to,
+				GasPrice: gasPrice,
+				Data:     input,
+			}
+			if reason, ok := e.simulateRevert(ctx, callMsg, nil); ok {
+				result.Error = fmt.Errorf("%v: %s", err, reason)
+			}
+		}
 		return []*CommandResult{result}
 	}
 	result.Result = "tx:" + strings.ToLower(tx.Hash().Hex())
 	return []*CommandResult{result}
 }
+
+// gasPrice is the gas price from config, or the suggested one if higher.
+func (e *Executor) gasPrice(ctx model.AppContext) *big.Int {
+	gasPrice, _ := e.root.Config.GasPriceInt()
+	suggestedGas, err := e.ethCli.SuggestGasPrice(ctx)
+	if err == nil && suggestedGas.Cmp(gasPrice) > 0 {
+		gasPrice = suggestedGas
+	}
+	return gasPrice
+}
+
+// writeValue evaluates the value of the write command, the denominator is set for token amounts.
+func (e *Executor) writeValue(ctx model.AppContext,
+	cmdSpec *model.WriteCmdSpec, denominations []string) (model.ExtendedValue, error) {
+	var value model.ExtendedValue
+	if valueExpr := cmdSpec.ValueExpression(); valueExpr != nil {
+		amount, err := e.evalExpression(ctx, valueExpr, model.ExprTypeInterger, model.ExprTypeFloat)
+		if err == nil {
+			var v *model.ExtendedValue
+			if v, err = model.NewExtendedValue(e.root, amount, cmdSpec.Value.Denominator()); err == nil {
+				value.Value = v.Value
+				value.Denominator = v.Denominator
+			}
+		}
+		if err != nil {
+			return value, fmt.Errorf("failed to evaluate value: %v", err)
+		}
+	} else if len(cmdSpec.Value) > 0 {
+		v, err := cmdSpec.Value.Parse(ctx, e.root, denominations)
+		if err != nil {
+			return value, err
+		}
+		value.Value = v.Value
+		value.Denominator = v.Denominator
+	}
+	return value, nil
+}
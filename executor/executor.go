@@ -2,8 +2,12 @@ package executor
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"math/big"
+	"reflect"
+	"sync"
 
 	"github.com/AtlantPlatform/ethfw"
 	log "github.com/Sirupsen/logrus"
@@ -17,48 +21,125 @@ import (
 type Executor struct {
 	root      *model.Spec
 	nodeGroup string
+	specDir   string
 
-	ethRPC   *rpc.Client
-	ethCli   *ethclient.Client
-	keycache ethfw.KeyCache
+	endpoints *model.Endpoints
+	ethRPC    *rpc.Client
+	ethCli    *ethclient.Client
+	keycache  ethfw.KeyCache
+
+	codeHashes    map[common.Address]struct{}
+	codeHashesMux *sync.Mutex
+	outputs       map[string]interface{}
+	walletOutputs map[string]map[string]interface{}
+	outputsMux    *sync.RWMutex
+	blocks        map[string]string
+	blocksMux     *sync.Mutex
+	walletLocks   map[common.Address]*sync.Mutex
+	walletClients map[common.Address]*ethclient.Client
+	walletMux     *sync.Mutex
+
+	journal    *model.RunJournal
+	steps      StepControl
+	progress   ProgressFunc
+	itemsDone  int64
+	failFast   bool
+	keepGoing  bool
+	approvals  map[string]struct{}
+	confirmMux *sync.Mutex
+
+	screener     *model.Screener
+	allowFlagged bool
+
+	prices    map[string]*big.Rat
+	pricesMux *sync.Mutex
+
+	plan               bool
+	plannedNonces      map[common.Address]uint64
+	plannedDeployments map[*model.ContractInstanceSpec]string
 }
 
 func New(ctx model.AppContext, root *model.Spec) (*Executor, error) {
 	nodeGroup := ctx.NodeGroup()
-	ethRPC, ok := root.Inventory.GetClient(nodeGroup)
+	endpoints, ok := root.Endpoints(nodeGroup)
 	if !ok {
 		err := errors.New("no valid RPC client found in the inventory")
 		return nil, err
 	}
+	ethRPC, err := endpoints.Dial(false)
+	if err != nil {
+		return nil, err
+	}
+	screener, err := root.Config.Screener()
+	if err != nil {
+		return nil, err
+	}
 	executor := &Executor{
 		root:      root,
 		nodeGroup: nodeGroup,
+		specDir:   ctx.SpecDir(),
+		endpoints: endpoints,
 		ethRPC:    ethRPC,
 		ethCli:    ethclient.NewClient(ethRPC),
 		keycache:  ctx.KeyCache(),
+
+		codeHashes:    make(map[common.Address]struct{}),
+		codeHashesMux: new(sync.Mutex),
+		outputs:       make(map[string]interface{}),
+		walletOutputs: make(map[string]map[string]interface{}),
+		outputsMux:    new(sync.RWMutex),
+		blocks:        make(map[string]string),
+		blocksMux:     new(sync.Mutex),
+		walletLocks:   make(map[common.Address]*sync.Mutex),
+		walletClients: make(map[common.Address]*ethclient.Client),
+		walletMux:     new(sync.Mutex),
+		approvals:     make(map[string]struct{}),
+		confirmMux:    new(sync.Mutex),
+		screener:      screener,
+		prices:        make(map[string]*big.Rat),
+		pricesMux:     new(sync.Mutex),
+
+		plannedNonces:      make(map[common.Address]uint64),
+		plannedDeployments: make(map[*model.ContractInstanceSpec]string),
 	}
 	return executor, nil
 }
 
 func (e *Executor) RunTarget(ctx model.AppContext, targetName string, resultsC chan<- []*CommandResult) bool {
 	if target, ok := e.root.Targets[targetName]; ok {
-		e.runTarget(ctx, targetName, target, resultsC)
+		e.runTarget(ctx, targetName, target, e.notifyingResults(ctx, targetName, resultsC))
 		return true
 	}
 	return false
 }
 
 func (e *Executor) RunCommand(ctx model.AppContext, cmdName string) ([]*CommandResult, bool) {
-	if cmdSpec, ok := e.root.CallCmds[cmdName]; ok {
-		return e.runCallCmd(ctx, cmdSpec), true
+	if !e.root.HasCommand(cmdName) {
+		return nil, false
+	}
+	if reason, err := e.checkCondition(ctx, e.root.CommandCondition(cmdName)); err != nil {
+		return []*CommandResult{{Error: err}}, true
+	} else if len(reason) > 0 {
+		return []*CommandResult{{Skipped: reason}}, true
 	}
-	if cmdSpec, ok := e.root.ViewCmds[cmdName]; ok {
-		return e.runViewCmd(ctx, cmdSpec), true
+	if e.plan {
+		return e.planTargetCmd(ctx, cmdName), true
 	}
-	if cmdSpec, ok := e.root.WriteCmds[cmdName]; ok {
-		return e.runWriteCmd(ctx, cmdSpec), true
+	if err := e.confirm(ctx, cmdName); err != nil {
+		return []*CommandResult{{Error: err}}, true
 	}
-	return nil, false
+	var results []*CommandResult
+	if cmdSpec, ok := e.root.CallCmds[cmdName]; ok {
+		results = e.runCallCmd(ctx, cmdSpec)
+	} else if cmdSpec, ok := e.root.ViewCmds[cmdName]; ok {
+		results = e.runViewCmd(ctx, cmdSpec)
+	} else if cmdSpec, ok := e.root.WriteCmds[cmdName]; ok {
+		results = e.runWriteCmd(ctx, cmdSpec)
+	}
+	// the output can be referenced by the commands run next, e.g. in the console
+	e.setOutput(cmdName, results)
+	e.notifyResults(ctx, "", setName(results, cmdName))
+	return results, true
 }
 
 type CommandResult struct {
@@ -66,6 +147,107 @@ type CommandResult struct {
 	Wallet string
 	Result interface{}
 	Error  error
+
+	// Txs is set when the command sends multiple transactions,
+	// so the result holds something else than the tx handle.
+	Txs []string
+
+	// Events are decoded from the logs of awaited transactions.
+	Events []*Event
+
+	// Skipped is the reason the command has not been run.
+	Skipped string
+
+	// GasUsed is the gas spent by the awaited transactions.
+	GasUsed uint64
+	// Spent is the ether spent by the awaited transactions, the value sent and the fees, in wei.
+	Spent *big.Int
+}
+
+// TxHandles returns the handles of transactions that should be awaited.
+func (r *CommandResult) TxHandles() []interface{} {
+	if len(r.Txs) == 0 {
+		return []interface{}{r.Result}
+	}
+	handles := make([]interface{}, 0, len(r.Txs))
+	for _, tx := range r.Txs {
+		handles = append(handles, tx)
+	}
+	return handles
+}
+
+func (e *Executor) walletKey(wallet *model.WalletSpec) (*ecdsa.PrivateKey, error) {
+	account := common.HexToAddress(wallet.Address)
+	pk, ok := e.keycache.PrivateKey(account, wallet.Password)
+	if !ok {
+		if pk = wallet.PrivKeyECDSA(); pk == nil {
+			return nil, errors.New("failed to get account private key")
+		}
+	}
+	return pk, nil
+}
+
+// lockWallet serializes transactions sent from the account, it returns the unlock func.
+func (e *Executor) lockWallet(account common.Address) func() {
+	e.walletMux.Lock()
+	lock, ok := e.walletLocks[account]
+	if !ok {
+		lock = new(sync.Mutex)
+		e.walletLocks[account] = lock
+	}
+	e.walletMux.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// RPCUsage returns the calls and compute units used on the nodes so far.
+func (e *Executor) RPCUsage() []*model.NodeUsage {
+	return e.endpoints.Usage()
+}
+
+// walletClient returns the client sending the transactions of the wallet, it sticks to one node
+// while it's healthy, so the pending nonce is read from the node the transactions are sent to.
+func (e *Executor) walletClient(account common.Address) *ethclient.Client {
+	e.walletMux.Lock()
+	defer e.walletMux.Unlock()
+	if client, ok := e.walletClients[account]; ok {
+		return client
+	}
+	ethRPC, err := e.endpoints.Dial(true)
+	if err != nil {
+		log.WithError(err).Warningln("failed to dial the wallet client, using the shared one")
+		return e.ethCli
+	}
+	client := ethclient.NewClient(ethRPC)
+	e.walletClients[account] = client
+	return client
+}
+
+// runConcurrently calls fn for each index, using the pool of n workers,
+// n less than 2 means the calls are made sequentially.
+func runConcurrently(n, count int, fn func(i int)) {
+	if n < 2 {
+		for i := 0; i < count; i++ {
+			fn(i)
+		}
+		return
+	}
+	indices := make(chan int)
+	wg := new(sync.WaitGroup)
+	for w := 0; w < n && w < count; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
 }
 
 func replaceWalletPlaceholders(params []interface{}, walletAddress common.Address) []interface{} {
@@ -80,11 +262,13 @@ func replaceWalletPlaceholders(params []interface{}, walletAddress common.Addres
 	return newParams
 }
 
-func replaceReferences(ctx model.AppContext, params []interface{}, root *model.Spec) []interface{} {
+// replaceReferences resolves the references and expressions of the params, failing on the first
+// one that can't be resolved.
+func (e *Executor) replaceReferences(ctx model.AppContext, params []interface{}) ([]interface{}, error) {
 	newParams := append([]interface{}{}, params...)
 	for i, param := range newParams {
 		if ref, ok := param.(*model.WalletFieldReference); ok {
-			wallet, _ := root.Wallets.WalletSpec(ref.WalletName)
+			wallet, _ := e.root.Wallets.WalletSpec(ref.WalletName)
 			walletField := wallet.FieldValue(ref.FieldName)
 			switch ref.FieldName {
 			case model.WalletSpecBalanceField:
@@ -97,11 +281,127 @@ func replaceReferences(ctx model.AppContext, params []interface{}, root *model.S
 		}
 		if arg, ok := param.(*model.ArgReference); ok {
 			if arg.ArgID < 0 {
-				log.WithField("command", ctx.AppCommand()).Errorln("insufficient arguments provided")
-				return nil
+				return nil, errors.New("insufficient arguments provided")
 			}
 			newParams[i] = ctx.AppCommandArgs()[arg.ArgID]
 		}
+		if ref, ok := param.(*model.CommandOutputReference); ok {
+			output, err := e.commandOutput(ref)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve %s: %v", ref, err)
+			}
+			newParams[i] = output
+		}
+		if expr, ok := param.(*model.ParamExpression); ok {
+			value, err := e.evalExpression(ctx, expr.Expression)
+			if err == nil {
+				value, err = expr.Convert(value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate %s: %v", expr.Expr, err)
+			}
+			newParams[i] = value
+		}
+	}
+	return newParams, nil
+}
+
+func (e *Executor) setOutput(name string, results []*CommandResult) {
+	if len(results) == 0 || results[0].Error != nil {
+		return
+	}
+	e.outputsMux.Lock()
+	e.outputs[name] = results[0].Result
+	walletOutputs := make(map[string]interface{}, len(results))
+	for _, result := range results {
+		if len(result.Wallet) > 0 && result.Error == nil {
+			walletOutputs[result.Wallet] = result.Result
+		}
+	}
+	e.walletOutputs[name] = walletOutputs
+	e.outputsMux.Unlock()
+}
+
+// InheritOutputs copies the outputs of commands run by the other executor, so they can be referenced.
+func (e *Executor) InheritOutputs(other *Executor) {
+	other.outputsMux.RLock()
+	defer other.outputsMux.RUnlock()
+	e.outputsMux.Lock()
+	defer e.outputsMux.Unlock()
+	for name, output := range other.outputs {
+		e.outputs[name] = output
+	}
+	for name, walletOutputs := range other.walletOutputs {
+		e.walletOutputs[name] = walletOutputs
+	}
+}
+
+func (e *Executor) commandOutput(ref *model.CommandOutputReference) (interface{}, error) {
+	e.outputsMux.RLock()
+	output, ok := e.outputs[ref.CmdName]
+	e.outputsMux.RUnlock()
+	if !ok {
+		err := fmt.Errorf("command %s has not been run yet", ref.CmdName)
+		return nil, err
+	}
+	if len(ref.Field) > 0 {
+		return e.commandOutputField(ref, output)
+	} else if ref.Index < 0 {
+		return normalizeOutput(output), nil
+	}
+	list, ok := output.([]interface{})
+	if !ok {
+		err := fmt.Errorf("command %s result is not an array", ref.CmdName)
+		return nil, err
+	} else if ref.Index >= len(list) {
+		err := fmt.Errorf("command %s result has no element %d", ref.CmdName, ref.Index)
+		return nil, err
+	}
+	return normalizeOutput(list[ref.Index]), nil
+}
+
+// commandOutputField resolves the field of the output: the result of the wallet for multi-wallet
+// commands, a key of the map result, or the address of the contract instance deployed by the command.
+func (e *Executor) commandOutputField(ref *model.CommandOutputReference, output interface{}) (interface{}, error) {
+	e.outputsMux.RLock()
+	walletOutput, ok := e.walletOutputs[ref.CmdName][ref.Field]
+	e.outputsMux.RUnlock()
+	if ok {
+		return normalizeOutput(walletOutput), nil
+	}
+	if fields := reflect.ValueOf(output); fields.Kind() == reflect.Map && fields.Type().Key().Kind() == reflect.String {
+		if value := fields.MapIndex(reflect.ValueOf(ref.Field)); value.IsValid() {
+			return normalizeOutput(value.Interface()), nil
+		}
+	}
+	if cmdSpec, ok := e.root.WriteCmds.WriteCmdSpec(ref.CmdName); ok && ref.Field == "address" &&
+		cmdSpec.Instance != nil && cmdSpec.Instance.IsDeployed() {
+		return common.HexToAddress(cmdSpec.Instance.Address), nil
+	}
+	err := fmt.Errorf("command %s result has no field %s", ref.CmdName, ref.Field)
+	return nil, err
+}
+
+// normalizeOutput converts results into values that can be packed as contract method params,
+// i.e. hex strings into addresses and arrays of addresses into address slices.
+func normalizeOutput(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case string:
+		if common.IsHexAddress(vv) {
+			return common.HexToAddress(vv)
+		}
+		return vv
+	case []interface{}:
+		addresses := make([]common.Address, 0, len(vv))
+		for _, item := range vv {
+			addr, ok := normalizeOutput(item).(common.Address)
+			if !ok {
+				return vv
+			}
+			addresses = append(addresses, addr)
+		}
+		return addresses
+	default:
+		return v
 	}
-	return newParams
 }
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// checkEventExpectations fails the result with *model.ExpectationError, if the logs of its
+// transactions have no event matching an expected event of the command.
+func checkEventExpectations(cmdSpec *model.WriteCmdSpec, result *CommandResult, logs []*types.Log) {
+	for _, expectation := range cmdSpec.EventExpectations() {
+		if !matchesExpectation(expectation, logs) {
+			result.Error = &model.ExpectationError{
+				Expected: expectation.Text,
+				Actual:   emittedEvents(cmdSpec, result.Events),
+			}
+			return
+		}
+	}
+}
+
+func matchesExpectation(expectation *model.EventExpectation, logs []*types.Log) bool {
+	for _, entry := range logs {
+		if matchesEvent(expectation.ABIEvent(), expectation.Emitters(), expectation.Filters, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesEvent reports whether the log is the event emitted by one of the emitters, any if there are none,
+// with the args matching the filters. A nil event matches any log of the emitters.
+func matchesEvent(abiEvent *abi.Event, emitters []common.Address, filters []*model.LogFilter, entry *types.Log) bool {
+	if entry.Removed || len(entry.Topics) == 0 {
+		return false
+	} else if abiEvent != nil && entry.Topics[0] != abiEvent.Id() {
+		return false
+	} else if len(emitters) > 0 {
+		var emitted bool
+		for _, emitter := range emitters {
+			if emitter == entry.Address {
+				emitted = true
+				break
+			}
+		}
+		if !emitted {
+			return false
+		}
+	}
+	if abiEvent == nil {
+		return true
+	}
+	args, _, err := decodeEventArgs(abiEvent, entry)
+	if err != nil {
+		// another event of the same signature with other indexed args
+		return false
+	}
+	for _, filter := range filters {
+		if !filter.Match(args[filter.Arg]) {
+			return false
+		}
+	}
+	return true
+}
+
+// emittedEvents lists the events of the result, as the actual value of the failed expectation.
+// The events not decoded by the ABI of the instance are named after the expected events of their topic.
+func emittedEvents(cmdSpec *model.WriteCmdSpec, events []*Event) string {
+	if len(events) == 0 {
+		return "no events"
+	}
+	texts := make([]string, 0, len(events))
+	for _, event := range events {
+		text := event.Event
+		if len(text) == 0 && len(event.Topics) > 0 {
+			for _, expectation := range cmdSpec.EventExpectations() {
+				if expectation.ABIEvent().Id().Hex() == event.Topics[0] {
+					text = expectation.ABIEvent().Name + " of " + event.Address
+					break
+				}
+			}
+		}
+		if len(text) == 0 {
+			text = "unknown event of " + event.Address
+		}
+		texts = append(texts, text)
+	}
+	return strings.Join(texts, "; ")
+}
@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// evalExpression substitutes the references of the expression with their current values and evaluates it,
+// an expression of a single reference yields its value as is, unless the result type is expected.
+func (e *Executor) evalExpression(ctx model.AppContext,
+	expr *model.Expression, expected ...model.ExprType) (interface{}, error) {
+	if expr.IsReference() && len(expected) == 0 {
+		return e.expressionValue(ctx, expr.Parts()[1])
+	}
+	str := new(strings.Builder)
+	for _, part := range expr.Parts() {
+		if literal, ok := part.(string); ok {
+			str.WriteString(literal)
+			continue
+		}
+		value, err := e.expressionValue(ctx, part)
+		if err != nil {
+			return nil, err
+		}
+		str.WriteString(expressionLiteral(value))
+	}
+	result, err := model.NewEvaler().Run(str.String(), expected...)
+	if err != nil {
+		return nil, fmt.Errorf("%s (%s): %v", expr.Expr, str.String(), err)
+	}
+	return result, nil
+}
+
+// Eval evaluates the expression with the current values of its references.
+func (e *Executor) Eval(ctx model.AppContext, expr *model.Expression) (interface{}, error) {
+	return e.evalExpression(ctx, expr)
+}
+
+func (e *Executor) expressionValue(ctx model.AppContext, ref interface{}) (interface{}, error) {
+	switch r := ref.(type) {
+	case *model.Expression:
+		return e.evalExpression(ctx, r)
+	case *model.FunctionCall:
+		return e.functionValue(ctx, r)
+	case *model.ContractCallReference:
+		params, err := e.replaceReferences(ctx, r.Params)
+		if err != nil {
+			return nil, fmt.Errorf("params of %s: %v", r.Method, err)
+		}
+		binding := r.Instance.BoundContract()
+		binding.SetClient(e.ethCli)
+		binding.SetAddress(common.HexToAddress(r.Instance.Address))
+		var value interface{}
+		if err := binding.Call(&bind.CallOpts{Context: ctx}, &value, r.Method, params...); err != nil {
+			return nil, e.withRevertReason(ctx, err)
+		}
+		return value, nil
+	case *model.WalletFieldReference:
+		if r.FieldName == model.WalletSpecBalanceField {
+			// the balance is fetched only for sending wallets, so get the current one
+			wallet, _ := e.root.Wallets.WalletSpec(r.WalletName)
+			return e.ethCli.BalanceAt(ctx, common.HexToAddress(wallet.Address), nil)
+		}
+	case *big.Int, common.Address:
+		return r, nil
+	}
+	values, err := e.replaceReferences(ctx, []interface{}{ref})
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+func (e *Executor) functionValue(ctx model.AppContext, call *model.FunctionCall) (interface{}, error) {
+	args := make([]interface{}, 0, len(call.Args))
+	for _, arg := range call.Args {
+		value, err := e.expressionValue(ctx, arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", call.Func, err)
+		}
+		args = append(args, value)
+	}
+	if call.Func == "len" {
+		list := reflect.ValueOf(args[0])
+		switch list.Kind() {
+		case reflect.Slice, reflect.Array, reflect.String, reflect.Map:
+			return big.NewInt(int64(list.Len())), nil
+		}
+		return nil, fmt.Errorf("len: %T is not a list", args[0])
+	}
+	if len(args) == 1 && call.Func != "abs" {
+		// sum, min and max over the list result
+		list := reflect.ValueOf(args[0])
+		if list.Kind() == reflect.Slice || list.Kind() == reflect.Array {
+			args = make([]interface{}, list.Len())
+			for i := range args {
+				args[i] = list.Index(i).Interface()
+			}
+		} else if call.Func == "sum" {
+			return nil, fmt.Errorf("sum: %T is not a list", args[0])
+		}
+	}
+	numbers := make([]*big.Rat, 0, len(args))
+	for _, arg := range args {
+		r, ok := ratOf(arg)
+		if !ok {
+			return nil, fmt.Errorf("%s: %v is not a number", call.Func, arg)
+		}
+		numbers = append(numbers, r)
+	}
+	result := new(big.Rat)
+	switch call.Func {
+	case "abs":
+		result.Abs(numbers[0])
+	case "sum":
+		for _, n := range numbers {
+			result.Add(result, n)
+		}
+	case "min", "max":
+		if len(numbers) == 0 {
+			return nil, fmt.Errorf("%s: empty list", call.Func)
+		}
+		result.Set(numbers[0])
+		for _, n := range numbers[1:] {
+			if cmp := n.Cmp(result); (call.Func == "min" && cmp < 0) || (call.Func == "max" && cmp > 0) {
+				result.Set(n)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown function %s", call.Func)
+	}
+	if result.IsInt() {
+		return new(big.Int).Set(result.Num()), nil
+	}
+	return result, nil
+}
+
+// expressionLiteral formats the value as an expression literal, numbers stay numbers,
+// everything else becomes a string literal.
+func expressionLiteral(v interface{}) string {
+	switch vv := v.(type) {
+	case bool:
+		return strconv.FormatBool(vv)
+	case *big.Int:
+		return vv.String()
+	case common.Address:
+		return strconv.Quote(strings.ToLower(vv.Hex()))
+	case string:
+		if common.IsHexAddress(vv) {
+			return strconv.Quote(strings.ToLower(vv))
+		} else if _, ok := new(big.Rat).SetString(vv); ok {
+			return vv
+		}
+		return strconv.Quote(vv)
+	}
+	if r, ok := ratOf(v); ok {
+		if r.IsInt() {
+			return r.RatString()
+		}
+		return r.FloatString(18)
+	}
+	return strconv.Quote(model.ExpectFormat(v))
+}
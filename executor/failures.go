@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// FailureKind classifies the error of a command, so the tool can exit with a distinct code.
+type FailureKind int
+
+const (
+	// FailureOther is any failure not classified below, including failed expectations.
+	FailureOther FailureKind = iota
+	// FailureSimulation means the call or the gas estimation of a transaction reverted,
+	// so the transaction hasn't been sent.
+	FailureSimulation
+	// FailureReverted means the transaction has been mined with a failing status.
+	FailureReverted
+	// FailureInsufficientFunds means the wallet can't pay for the gas and value of the transaction.
+	FailureInsufficientFunds
+	// FailureUnavailable means the node can't be reached or has timed out.
+	FailureUnavailable
+)
+
+// TxFailedError is the error of a transaction mined with a failing status.
+type TxFailedError struct {
+	Hash   common.Hash
+	Reason string
+}
+
+func (e *TxFailedError) Error() string {
+	if len(e.Reason) > 0 {
+		return "transaction execution ended with failing status code: " + e.Reason
+	}
+	return "transaction execution ended with failing status code"
+}
+
+// simulationErrors are the messages of nodes rejecting a call or a gas estimation.
+var simulationErrors = []string{
+	"execution reverted",
+	"always failing transaction",
+	"gas required exceeds allowance",
+	"invalid opcode",
+	"out of gas",
+}
+
+// unavailableErrors are the messages of a node that can't be reached.
+var unavailableErrors = []string{
+	"connection refused",
+	"no such host",
+	"connection reset",
+	"i/o timeout",
+	"502 bad gateway",
+	"503 service unavailable",
+}
+
+// ClassifyFailure returns the kind of the command error.
+func ClassifyFailure(err error) FailureKind {
+	switch err := err.(type) {
+	case nil:
+		return FailureOther
+	case *TxFailedError:
+		return FailureReverted
+	case *model.ExpectationError:
+		return FailureOther
+	case net.Error, *url.Error:
+		return FailureUnavailable
+	default:
+		if err == context.DeadlineExceeded {
+			return FailureUnavailable
+		}
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "insufficient funds") {
+		return FailureInsufficientFunds
+	}
+	for _, s := range unavailableErrors {
+		if strings.Contains(msg, s) {
+			return FailureUnavailable
+		}
+	}
+	for _, s := range simulationErrors {
+		if strings.Contains(msg, s) {
+			return FailureSimulation
+		}
+	}
+	return FailureOther
+}
+
+// SetFailFast stops the target at the first failed command, including failed views and CALL commands,
+// which don't stop the target by default.
+func (e *Executor) SetFailFast(failFast bool) {
+	e.failFast = failFast
+}
+
+// SetKeepGoing runs all the commands of the target, even if some of them have failed. The commands
+// depending on the failed ones are skipped, and no compensating commands are run.
+func (e *Executor) SetKeepGoing(keepGoing bool) {
+	e.keepGoing = keepGoing
+}
+
+// mustStop applies --fail-fast and --keep-going to the decision whether the target must stop.
+func (e *Executor) mustStop(targetName, cmdName string, results []*CommandResult, stop bool) bool {
+	switch {
+	case e.keepGoing && stop:
+		log.WithFields(log.Fields{
+			"target":  targetName,
+			"command": cmdName,
+		}).Warningln("command failed, keeping going")
+		return false
+	case e.failFast && !stop && hasErrors(results):
+		log.WithFields(log.Fields{
+			"target":  targetName,
+			"command": cmdName,
+		}).Errorln("stopping target execution — command failed")
+		return true
+	}
+	return stop
+}
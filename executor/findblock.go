@@ -0,0 +1,149 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// FoundBlock is the first block where the view condition holds.
+type FoundBlock struct {
+	Block     uint64 `json:"block"`
+	Timestamp string `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// FindBlock binary-searches the block range for the first block at which the numeric
+// result of the view satisfies the condition, e.g. when totalSupply exceeded X. The condition
+// must stay true once met, calls reverting at a block or finding no code (e.g. before deploy) count
+// as not met, while the other errors of the node abort the search.
+func (e *Executor) FindBlock(ctx model.AppContext, cmdName string, op model.CompareOp,
+	threshold *big.Int, from, to *model.BlockRef) *CommandResult {
+	cmdSpec, ok := e.root.ViewCmds[cmdName]
+	if !ok {
+		return &CommandResult{Error: fmt.Errorf("view command %s not found", cmdName)}
+	} else if cmdSpec.Kind == model.KindGraphQL {
+		return &CommandResult{Error: errors.New("graphql views have no state at past blocks")}
+	} else if !cmdSpec.Instance.IsDeployed() {
+		return &CommandResult{Error: errors.New("contract instance is not deployed yet")}
+	} else if len(cmdSpec.MatchingWallets()) > 0 {
+		return &CommandResult{Error: errors.New("views matching wallets are not supported, use a single address")}
+	}
+	params, err := e.replaceReferences(ctx, cmdSpec.ParamValues())
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	lo, err := e.blockNumber(ctx, from, 0)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	latest, err := e.blockHeader(ctx, model.BlockTagLatest)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to get latest block: %v", err)}
+	}
+	hi, err := e.blockNumber(ctx, to, latest.Number.ToInt().Uint64())
+	if err != nil {
+		return &CommandResult{Error: err}
+	} else if lo > hi {
+		return &CommandResult{Error: fmt.Errorf("block range is empty: %d-%d", lo, hi)}
+	}
+	check := func(block uint64) (*big.Int, bool, error) {
+		value, err := e.viewNumberAt(ctx, cmdSpec, params, block)
+		if err != nil {
+			// a revert or no code at the block, e.g. before the deployment, is not met
+			if ClassifyFailure(err) == FailureSimulation || isEmptyOutput(err) {
+				return nil, false, nil
+			}
+			return nil, false, fmt.Errorf("failed to call the view at block %d: %v", block, err)
+		}
+		return value, op.Compare(value, threshold), nil
+	}
+	value, met, err := check(hi)
+	if err != nil {
+		return &CommandResult{Error: err}
+	} else if !met {
+		return &CommandResult{Error: fmt.Errorf("condition is not met at block %d", hi)}
+	}
+	if v, met, err := check(lo); err != nil {
+		return &CommandResult{Error: err}
+	} else if met {
+		hi, value = lo, v
+	}
+	// invariant: not met at lo, met at hi
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		v, met, err := check(mid)
+		if err != nil {
+			return &CommandResult{Error: err}
+		} else if met {
+			hi, value = mid, v
+		} else {
+			lo = mid
+		}
+	}
+	header, err := e.blockHeader(ctx, hexutil.EncodeUint64(hi))
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to get block %d: %v", hi, err)}
+	}
+	return &CommandResult{
+		Result: &FoundBlock{
+			Block:     hi,
+			Timestamp: time.Unix(int64(header.Timestamp), 0).UTC().Format(time.RFC3339),
+			Value:     value.String(),
+		},
+	}
+}
+
+// blockNumber resolves the block reference into the block number, nil means the default one.
+func (e *Executor) blockNumber(ctx model.AppContext, ref *model.BlockRef, defaultNumber uint64) (uint64, error) {
+	if ref == nil {
+		return defaultNumber, nil
+	}
+	param, err := e.blockParam(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+	header, err := e.blockHeader(ctx, param)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s block: %v", ref, err)
+	}
+	return header.Number.ToInt().Uint64(), nil
+}
+
+// isEmptyOutput checks whether the call has returned nothing, as it does when the address has no code.
+func isEmptyOutput(err error) bool {
+	return strings.HasPrefix(err.Error(), "abi: unmarshalling empty output")
+}
+
+func (e *Executor) viewNumberAt(ctx model.AppContext, cmdSpec *model.ViewCmdSpec,
+	params []interface{}, block uint64) (*big.Int, error) {
+	binding := cmdSpec.Instance.BoundContract()
+	binding.SetClient(e.ethCli)
+	binding.SetAddress(common.HexToAddress(cmdSpec.Instance.Address))
+	opts := &bind.CallOpts{
+		Context: ctx,
+	}
+	var result interface{}
+	err := e.callRaw(ctx, cmdSpec.Instance, opts, &result, cmdSpec.Method,
+		hexutil.EncodeUint64(block), cmdSpec.StateOverrides(), params...)
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := integerValue(result); ok {
+		return value, nil
+	} else if v, ok := result.(bool); ok {
+		if v {
+			return big.NewInt(1), nil
+		}
+		return new(big.Int), nil
+	}
+	return nil, fmt.Errorf("view result is not a number: %T", result)
+}
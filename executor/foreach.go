@@ -0,0 +1,35 @@
+package executor
+
+import (
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// runIterations runs the commands rendered by foreach one by one, in the order of rows.
+// Iterations having a when condition that is not met are reported as skipped.
+func (e *Executor) runIterations(ctx model.AppContext,
+	conditions []*model.Expression, run func(i int) []*CommandResult) []*CommandResult {
+	var results []*CommandResult
+	for i, cond := range conditions {
+		if reason, err := e.checkCondition(ctx, cond); err != nil {
+			result := &CommandResult{Error: err}
+			results = append(results, result)
+			e.itemDone(result)
+			continue
+		} else if len(reason) > 0 {
+			result := &CommandResult{Skipped: reason}
+			results = append(results, result)
+			e.itemDone(result)
+			continue
+		}
+		reported := e.itemsReported()
+		rowResults := run(i)
+		results = append(results, rowResults...)
+		if e.itemsReported() == reported {
+			// the row hasn't fanned out to wallets
+			for _, result := range rowResults {
+				e.itemDone(result)
+			}
+		}
+	}
+	return results
+}
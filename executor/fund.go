@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// TopUp is the transfer topping up the wallet below the min balance of a fund rule, or the reason it's not
+// sent. A capped top-up is cut by the cap of the rule, the wallet is left below the top-up balance.
+type TopUp struct {
+	Rule    string
+	Wallet  string
+	Address string
+	Asset   string
+	Balance *big.Int
+	Amount  *big.Int
+	Capped  bool
+	TxHash  string
+	Skipped string
+	Error   error
+}
+
+// Underfunded reports whether the wallet is left below the balance it's topped up to.
+func (topUp *TopUp) Underfunded() bool {
+	return topUp.Capped || len(topUp.Skipped) > 0 || topUp.Error != nil
+}
+
+// Fund checks the balances of the wallets of the rule and tops up the ones below the min from the wallet
+// of the rule, one by one, until the cap of the rule is reached. The wallets are alerted to the webhooks,
+// as warnings if topped up, as critical if left below the top-up balance. A dry run sends nothing.
+func (e *Executor) Fund(ctx model.AppContext, name string, fund *model.FundSpec, dryRun bool) []*TopUp {
+	var remaining *big.Int
+	if capAmount := fund.CapAmount(); capAmount != nil {
+		remaining = new(big.Int).Set(capAmount)
+	}
+	var topUps []*TopUp
+	for _, wallet := range fund.Funded(e.root) {
+		topUp := &TopUp{
+			Rule:    name,
+			Wallet:  e.root.Wallets.NameOf(wallet.Address),
+			Address: strings.ToLower(wallet.Address),
+			Asset:   fund.Asset(),
+		}
+		account := common.HexToAddress(wallet.Address)
+		balance, err := e.fundBalance(ctx, fund.Token(), account)
+		if err != nil {
+			topUp.Error = err
+			topUps = append(topUps, topUp)
+			e.notifyTopUp(ctx, topUp, dryRun)
+			continue
+		}
+		topUp.Balance = balance
+		deficit := fund.Deficit(balance)
+		if deficit.Sign() == 0 {
+			continue
+		}
+		topUps = append(topUps, topUp)
+		topUp.Amount = deficit
+		if remaining != nil && remaining.Cmp(deficit) < 0 {
+			if remaining.Sign() == 0 {
+				topUp.Amount = nil
+				topUp.Skipped = "cap reached"
+				e.notifyTopUp(ctx, topUp, dryRun)
+				continue
+			}
+			topUp.Amount = new(big.Int).Set(remaining)
+			topUp.Capped = true
+		}
+		if !dryRun {
+			if token := fund.Token(); token == nil {
+				topUp.TxHash, err = e.sendWalletTx(ctx, fund.FromWallet(), account, topUp.Amount, nil)
+			} else {
+				topUp.TxHash, err = e.sendWalletTx(ctx, fund.FromWallet(), common.HexToAddress(token.Address),
+					nil, transferData(account, topUp.Amount))
+			}
+			if err != nil {
+				topUp.Error = err
+				e.notifyTopUp(ctx, topUp, dryRun)
+				continue
+			}
+		}
+		if remaining != nil {
+			remaining.Sub(remaining, topUp.Amount)
+		}
+		e.notifyTopUp(ctx, topUp, dryRun)
+	}
+	return topUps
+}
+
+// fundBalance is the ether balance of the account, or its balance of the token.
+func (e *Executor) fundBalance(ctx context.Context, token *model.TokenSpec, account common.Address) (*big.Int, error) {
+	if token == nil {
+		return e.ethCli.BalanceAt(ctx, account, nil)
+	}
+	address := common.HexToAddress(token.Address)
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &address,
+		Data: append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(account.Bytes(), 32)...),
+	}, nil)
+	if err != nil {
+		return nil, err
+	} else if len(output) != 32 {
+		return nil, errBalanceReverted
+	}
+	return new(big.Int).SetBytes(output), nil
+}
+
+// notifyTopUp alerts of the wallet below the min balance, the dry runs are not notified.
+func (e *Executor) notifyTopUp(ctx context.Context, topUp *TopUp, dryRun bool) {
+	if dryRun {
+		return
+	}
+	notification := &model.Notification{
+		Type:     model.NotifyAlert,
+		Address:  topUp.Address,
+		Rule:     topUp.Rule,
+		Severity: model.SeverityWarning,
+		Asset:    topUp.Asset,
+	}
+	if topUp.Underfunded() {
+		notification.Severity = model.SeverityCritical
+	}
+	if topUp.Balance != nil {
+		notification.Balance = topUp.Balance.String()
+	}
+	if topUp.Amount != nil && topUp.Error == nil {
+		notification.Amount = topUp.Amount.String()
+	}
+	if len(topUp.TxHash) > 0 {
+		notification.Txs = []string{topUp.TxHash}
+	}
+	if topUp.Error != nil {
+		notification.Error = topUp.Error.Error()
+	}
+	e.notify(ctx, notification)
+}
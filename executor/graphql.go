@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// runGraphQL queries the subgraph of the view with the resolved variables, the result is the map
+// of the selected fields.
+func (e *Executor) runGraphQL(ctx model.AppContext, cmdSpec *model.ViewCmdSpec) *CommandResult {
+	params, err := e.replaceReferences(ctx, cmdSpec.ParamValues())
+	if err != nil {
+		return &CommandResult{
+			Error: err,
+		}
+	}
+	variables := make(map[string]interface{}, len(params))
+	for i, name := range cmdSpec.GraphQLVariables() {
+		variables[name] = graphQLVariable(params[i])
+	}
+	data, err := e.root.Config.QuerySubgraph(ctx, cmdSpec.Subgraph, cmdSpec.Query, variables)
+	if err != nil {
+		return &CommandResult{
+			Error: err,
+		}
+	}
+	fields := make(map[string]interface{}, len(cmdSpec.Select))
+	for field, path := range cmdSpec.Select {
+		value, err := model.SelectGraphQL(data, path)
+		if err != nil {
+			return &CommandResult{
+				Error: fmt.Errorf("failed to select %s: %v", field, err),
+			}
+		}
+		fields[field] = value
+	}
+	return &CommandResult{
+		Result: fields,
+	}
+}
+
+// graphQLVariable converts the addresses into the lowercase hex the subgraph entities are keyed by.
+func graphQLVariable(param interface{}) interface{} {
+	switch v := param.(type) {
+	case common.Address:
+		return strings.ToLower(v.Hex())
+	case []common.Address:
+		addresses := make([]string, len(v))
+		for i, address := range v {
+			addresses[i] = strings.ToLower(address.Hex())
+		}
+		return addresses
+	}
+	return param
+}
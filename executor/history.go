@@ -0,0 +1,355 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// Sources of the transaction history.
+const (
+	HistorySourceEtherscan = "etherscan"
+	HistorySourceNode      = "node"
+)
+
+// Directions of the transfers of the history, relative to the wallet.
+const (
+	DirectionIn   = "in"
+	DirectionOut  = "out"
+	DirectionSelf = "self"
+)
+
+var transferEventID = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// HistoryEntry is a transfer of ether or a token of the wallet, the fee paid by the wallet is set on
+// the entry of its transaction, which has no amount if the transaction moved no ether, e.g. an approve.
+type HistoryEntry struct {
+	Time             time.Time `json:"time"`
+	Block            uint64    `json:"block"`
+	TxHash           string    `json:"txHash"`
+	Wallet           string    `json:"wallet"`
+	Direction        string    `json:"direction"`
+	Counterparty     string    `json:"counterparty"`
+	CounterpartyName string    `json:"counterpartyName,omitempty"`
+	Asset            string    `json:"asset"`
+	Amount           string    `json:"amount"`
+	Fee              string    `json:"fee,omitempty"`
+	Method           string    `json:"method,omitempty"`
+	Failed           bool      `json:"failed,omitempty"`
+	// Value and FeeValue are the amount and the fee in the fiat currency, at the prices of the day.
+	Value    string `json:"value,omitempty"`
+	FeeValue string `json:"feeValue,omitempty"`
+
+	order int
+	// token is the contract of the token transfers
+	token string
+}
+
+// History returns the transfers of the wallets in the range of blocks in the order of blocks, from the
+// account lists of Etherscan or from the traces and the token logs of the node, the tokens of the spec
+// only. The ranges given by timestamps are exact, the transfers before from or at to and later are skipped.
+func (e *Executor) History(ctx model.AppContext, source string, wallets []string,
+	from, to *model.BlockRef) ([]*HistoryEntry, error) {
+	fromBlock, toBlock, err := e.ScanBounds(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*HistoryEntry
+	for _, name := range wallets {
+		wallet, ok := e.root.Wallets.WalletSpec(name)
+		if !ok || !common.IsHexAddress(wallet.Address) {
+			return nil, fmt.Errorf("wallet %s has no address", name)
+		}
+		var walletEntries []*HistoryEntry
+		switch source {
+		case HistorySourceEtherscan:
+			walletEntries, err = e.etherscanHistory(ctx, name, wallet, fromBlock, toBlock)
+		case HistorySourceNode:
+			walletEntries, err = e.nodeHistory(ctx, name, wallet, fromBlock, toBlock)
+		default:
+			err = fmt.Errorf("unknown history source %s, must be %s or %s", source, HistorySourceEtherscan, HistorySourceNode)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("history of @%s: %v", name, err)
+		}
+		entries = append(entries, walletEntries...)
+	}
+	filtered := entries[:0]
+	for i, entry := range entries {
+		if from != nil && !from.Time.IsZero() && entry.Time.Before(from.Time) {
+			continue
+		} else if to != nil && !to.Time.IsZero() && !entry.Time.Before(to.Time) {
+			continue
+		}
+		entry.CounterpartyName = e.accountName(entry.Counterparty)
+		entry.order = i
+		filtered = append(filtered, entry)
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if filtered[i].Block != filtered[j].Block {
+			return filtered[i].Block < filtered[j].Block
+		}
+		return filtered[i].order < filtered[j].order
+	})
+	return filtered, nil
+}
+
+func (e *Executor) etherscanHistory(ctx model.AppContext, name string, wallet *model.WalletSpec, from, to uint64) ([]*HistoryEntry, error) {
+	address := strings.ToLower(wallet.Address)
+	var entries []*HistoryEntry
+	for _, action := range []string{model.EtherscanTxList, model.EtherscanTxListInternal, model.EtherscanTokenTx} {
+		txs, err := e.root.Config.EtherscanTxs(ctx, action, address, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range txs {
+			block, _ := strconv.ParseUint(tx.BlockNumber, 10, 64)
+			timestamp, _ := strconv.ParseInt(tx.TimeStamp, 10, 64)
+			value, ok := new(big.Int).SetString(tx.Value, 10)
+			if !ok {
+				value = new(big.Int)
+			}
+			entry := &HistoryEntry{
+				Time:   time.Unix(timestamp, 0).UTC(),
+				Block:  block,
+				TxHash: tx.Hash,
+				Wallet: name,
+				Asset:  EtherAsset,
+				Failed: tx.IsError == "1",
+			}
+			if !setDirection(entry, address, tx.From, tx.To) {
+				continue
+			}
+			switch action {
+			case model.EtherscanTxList:
+				if entry.Failed {
+					value.SetInt64(0)
+				}
+				if entry.Direction != DirectionIn {
+					gasUsed, _ := new(big.Int).SetString(tx.GasUsed, 10)
+					gasPrice, _ := new(big.Int).SetString(tx.GasPrice, 10)
+					if gasUsed != nil && gasPrice != nil {
+						entry.Fee = model.FormatUnits(new(big.Int).Mul(gasUsed, gasPrice), 18)
+					}
+				} else if value.Sign() == 0 {
+					continue
+				}
+				entry.Method = tx.FunctionName
+				if i := strings.Index(entry.Method, "("); i > 0 {
+					entry.Method = entry.Method[:i]
+				}
+				entry.Amount = model.FormatUnits(value, 18)
+			case model.EtherscanTxListInternal:
+				if entry.Failed || value.Sign() == 0 {
+					continue
+				}
+				entry.Amount = model.FormatUnits(value, 18)
+			case model.EtherscanTokenTx:
+				decimals, err := strconv.Atoi(tx.TokenDecimal)
+				if err != nil {
+					decimals = 0
+				}
+				entry.token = strings.ToLower(tx.ContractAddress)
+				entry.Asset = strings.ToUpper(tx.TokenSymbol)
+				if len(entry.Asset) == 0 {
+					entry.Asset = strings.ToLower(tx.ContractAddress)
+				}
+				entry.Amount = model.FormatUnits(value, decimals)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// nodeHistory finds the ether transfers with trace_filter, supported by Erigon, Nethermind and Reth,
+// and the transfers of the tokens of the spec in their Transfer logs.
+func (e *Executor) nodeHistory(ctx model.AppContext, name string, wallet *model.WalletSpec, from, to uint64) ([]*HistoryEntry, error) {
+	account := common.HexToAddress(wallet.Address)
+	address := strings.ToLower(account.Hex())
+	times := make(map[uint64]time.Time)
+	blockTime := func(block uint64) (time.Time, error) {
+		if t, ok := times[block]; ok {
+			return t, nil
+		}
+		header, err := e.blockHeader(ctx, hexutil.EncodeUint64(block))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to get block %d: %v", block, err)
+		}
+		times[block] = time.Unix(int64(header.Timestamp), 0).UTC()
+		return times[block], nil
+	}
+	var entries []*HistoryEntry
+	seen := make(map[string]bool)
+	for _, field := range []string{"fromAddress", "toAddress"} {
+		var traces []*traceFilterEntry
+		err := e.ethRPC.CallContext(ctx, &traces, "trace_filter", map[string]interface{}{
+			"fromBlock": hexutil.EncodeUint64(from),
+			"toBlock":   hexutil.EncodeUint64(to),
+			field:       []common.Address{account},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("trace_filter failed: %v", err)
+		}
+		for _, trace := range traces {
+			if trace == nil || trace.Type != "call" {
+				continue
+			}
+			key := fmt.Sprintf("%s/%v", trace.TransactionHash, trace.TraceAddress)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			value := new(big.Int)
+			if trace.Action.Value != nil {
+				value = trace.Action.Value.ToInt()
+			}
+			topLevel := len(trace.TraceAddress) == 0
+			entry := &HistoryEntry{
+				Block:  trace.BlockNumber,
+				TxHash: trace.TransactionHash,
+				Wallet: name,
+				Asset:  EtherAsset,
+				Failed: len(trace.Error) > 0,
+			}
+			setDirection(entry, address, trace.Action.From.Hex(), trace.Action.To.Hex())
+			paysFee := topLevel && entry.Direction != DirectionIn
+			if entry.Failed {
+				value = new(big.Int)
+			}
+			if value.Sign() == 0 && !paysFee {
+				continue
+			}
+			entry.Amount = model.FormatUnits(value, 18)
+			if topLevel && len(trace.Action.Input) >= 4 {
+				entry.Method = hexutil.Encode(trace.Action.Input[:4])
+				if _, method, ok := e.root.Contracts.FindMethod(trace.Action.To.Hex(), trace.Action.Input[:4]); ok {
+					entry.Method = method.Name
+				}
+			}
+			if paysFee {
+				fee, err := e.txFee(ctx, common.HexToHash(trace.TransactionHash))
+				if err != nil {
+					return nil, fmt.Errorf("failed to get the fee of %s: %v", trace.TransactionHash, err)
+				}
+				entry.Fee = model.FormatUnits(fee, 18)
+			}
+			if entry.Time, err = blockTime(entry.Block); err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+	walletTopic := common.BytesToHash(account.Bytes())
+	for _, token := range e.root.Tokens {
+		if !common.IsHexAddress(token.Address) || len(token.Symbol) == 0 || token.Decimals == nil {
+			continue
+		}
+		for _, topics := range [][][]common.Hash{
+			{{transferEventID}, {walletTopic}},
+			{{transferEventID}, nil, {walletTopic}},
+		} {
+			query := ethereum.FilterQuery{
+				Addresses: []common.Address{common.HexToAddress(token.Address)},
+				Topics:    topics,
+			}
+			err := e.scanLogsFunc(ctx, query, from, to, func(chunk []types.Log) error {
+				for _, entry := range chunk {
+					if entry.Removed || len(entry.Topics) != 3 || len(entry.Data) != 32 {
+						continue
+					}
+					key := fmt.Sprintf("%s/%d", entry.TxHash.Hex(), entry.Index)
+					if seen[key] {
+						continue
+					}
+					seen[key] = true
+					transfer := &HistoryEntry{
+						Block:  entry.BlockNumber,
+						TxHash: entry.TxHash.Hex(),
+						Wallet: name,
+						Asset:  strings.ToUpper(token.Symbol),
+						Amount: model.FormatUnits(new(big.Int).SetBytes(entry.Data), *token.Decimals),
+						token:  strings.ToLower(token.Address),
+					}
+					setDirection(transfer, address,
+						common.BytesToAddress(entry.Topics[1].Bytes()).Hex(),
+						common.BytesToAddress(entry.Topics[2].Bytes()).Hex())
+					t, err := blockTime(transfer.Block)
+					if err != nil {
+						return err
+					}
+					transfer.Time = t
+					entries = append(entries, transfer)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// setDirection sets the direction and the counterparty of the transfer between from and to,
+// it reports false if the wallet is neither of them.
+func setDirection(entry *HistoryEntry, address, from, to string) bool {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	switch {
+	case from == address && to == address:
+		entry.Direction, entry.Counterparty = DirectionSelf, address
+	case from == address:
+		entry.Direction, entry.Counterparty = DirectionOut, to
+	case to == address:
+		entry.Direction, entry.Counterparty = DirectionIn, from
+	default:
+		return false
+	}
+	return true
+}
+
+// txFee is the fee paid by the sender of the transaction, with the effective gas price of the receipt,
+// or the gas price of the transaction if the node doesn't report it.
+func (e *Executor) txFee(ctx context.Context, hash common.Hash) (*big.Int, error) {
+	receipt, err := e.rpcReceipt(ctx, hash)
+	if err != nil {
+		return nil, err
+	} else if receipt == nil {
+		return nil, ethereum.NotFound
+	}
+	var gasPrice *big.Int
+	if receipt.EffectiveGasPrice != nil {
+		gasPrice = receipt.EffectiveGasPrice.ToInt()
+	} else {
+		tx, _, err := e.ethCli.TransactionByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		gasPrice = tx.GasPrice()
+	}
+	return new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(uint64(receipt.GasUsed))), nil
+}
+
+// accountName is the name of the account in the spec: @wallet, the label or the contract, if any.
+func (e *Executor) accountName(address string) string {
+	if !common.IsHexAddress(address) {
+		return ""
+	} else if name := e.root.Wallets.NameOf(address); len(name) > 0 {
+		return "@" + name
+	} else if label, ok := e.root.AddressLabel(common.HexToAddress(address)); ok {
+		return label
+	}
+	return e.root.Contracts.NameOf(address)
+}
@@ -0,0 +1,303 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// SetJournal makes the executor record the state of target commands into the run journal,
+// the commands completed in the journal are skipped and pending transactions are re-attached.
+func (e *Executor) SetJournal(journal *model.RunJournal) {
+	e.journal = journal
+}
+
+// journalEntry returns the journaled state of the command at the position in the target,
+// nil if there is no journal or the command has not been run. Commands run outside of
+// the target sequence, like compensating ones, have negative positions and aren't journaled.
+func (e *Executor) journalEntry(position int, cmdName string) *model.JournalEntry {
+	if e.journal == nil || position < 0 {
+		return nil
+	}
+	entry := e.journal.Entry(position)
+	if entry == nil || entry.Name != cmdName {
+		return nil
+	}
+	return entry
+}
+
+func (e *Executor) isJournaled(position int, cmdName string) bool {
+	entry := e.journalEntry(position, cmdName)
+	return entry != nil && entry.Status != model.JournalFailed
+}
+
+// record saves the command results into the run journal, receipts are keyed by tx hash.
+func (e *Executor) record(position int, cmdName string, status model.JournalStatus,
+	results []*CommandResult, receipts map[string]*types.Receipt) {
+	if e.journal == nil || position < 0 {
+		return
+	}
+	entry := &model.JournalEntry{
+		Name:   cmdName,
+		Status: status,
+	}
+	for _, result := range results {
+		entry.Results = append(entry.Results, journalResultOf(result, receipts))
+	}
+	if err := e.journal.Record(position, entry); err != nil {
+		log.WithFields(log.Fields{
+			"run":     e.journal.ID,
+			"command": cmdName,
+		}).WithError(err).Warningln("failed to update run journal")
+	}
+}
+
+func journalResultOf(result *CommandResult, receipts map[string]*types.Receipt) *model.JournalResult {
+	journalResult := &model.JournalResult{
+		Wallet: result.Wallet,
+		Txs:    result.Txs,
+	}
+	if result.Error == nil && result.Result != nil {
+		if data, err := json.Marshal(result.Result); err == nil {
+			journalResult.Result = data
+		}
+	}
+	for _, handle := range result.TxHandles() {
+		txHash, ok := handle.(string)
+		if !ok {
+			continue
+		}
+		receipt, ok := receipts[strings.ToLower(strings.TrimPrefix(txHash, "tx:"))]
+		if !ok {
+			continue
+		}
+		journalReceipt := &model.JournalReceipt{
+			TxHash:  strings.ToLower(receipt.TxHash.Hex()),
+			Status:  receipt.Status,
+			GasUsed: receipt.GasUsed,
+		}
+		if receipt.ContractAddress != (common.Address{}) {
+			journalReceipt.ContractAddress = receipt.ContractAddress.Hex()
+		}
+		journalResult.Receipts = append(journalResult.Receipts, journalReceipt)
+	}
+	return journalResult
+}
+
+// restoreResults rebuilds the command results from the journal entry, numbers in the
+// outputs become big integers again, so they can be referenced by the next commands.
+func restoreResults(entry *model.JournalEntry) []*CommandResult {
+	results := make([]*CommandResult, 0, len(entry.Results))
+	for _, journalResult := range entry.Results {
+		results = append(results, restoreResult(journalResult))
+	}
+	return results
+}
+
+func restoreResult(journalResult *model.JournalResult) *CommandResult {
+	result := &CommandResult{
+		Wallet: journalResult.Wallet,
+		Txs:    journalResult.Txs,
+	}
+	if len(journalResult.Result) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(journalResult.Result))
+		dec.UseNumber()
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			result.Error = fmt.Errorf("failed to restore result: %v", err)
+		} else {
+			result.Result = restoreNumbers(value)
+		}
+	}
+	return result
+}
+
+type journalItemKey struct{}
+
+// journalItem is the iteration of the write command from a wallet, carried by the context
+// of the command run in a journaled target, so its transactions are journaled before they are sent.
+type journalItem struct {
+	position  int
+	cmdName   string
+	iteration int
+	wallet    string
+}
+
+func withJournalItem(ctx model.AppContext, item journalItem) model.AppContext {
+	return model.AppContext{Context: context.WithValue(ctx.Context, journalItemKey{}, item)}
+}
+
+func journalItemOf(ctx context.Context) (journalItem, bool) {
+	item, ok := ctx.Value(journalItemKey{}).(journalItem)
+	return item, ok
+}
+
+// withIteration sets the iteration of foreach to the journal item of the context.
+func withIteration(ctx model.AppContext, iteration int) model.AppContext {
+	item, ok := journalItemOf(ctx)
+	if !ok {
+		return ctx
+	}
+	item.iteration = iteration
+	return withJournalItem(ctx, item)
+}
+
+// journalTx records the signed transaction of the item in the context before it's sent. The transaction
+// must not be sent if this fails, since the resumed run would not know about it.
+func (e *Executor) journalTx(ctx context.Context, tx *types.Transaction) error {
+	item, ok := journalItemOf(ctx)
+	if !ok || e.journal == nil {
+		return nil
+	}
+	journaled := e.journal.Item(item.position, item.cmdName, item.iteration, item.wallet)
+	if journaled == nil {
+		journaled = &model.JournalItem{
+			Iteration: item.iteration,
+			Wallet:    item.wallet,
+		}
+	}
+	journaled.Hashes = append(journaled.Hashes, strings.ToLower(tx.Hash().Hex()))
+	if err := e.journal.RecordItem(item.position, item.cmdName, journaled); err != nil {
+		return fmt.Errorf("failed to journal the transaction: %v", err)
+	}
+	return nil
+}
+
+// journalSigner wraps the signer of the bindings, so the transactions they send are journaled as in journalTx.
+func (e *Executor) journalSigner(ctx context.Context, signerFn bind.SignerFn) bind.SignerFn {
+	return func(signer types.Signer, account common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		signedTx, err := signerFn(signer, account, tx)
+		if err != nil {
+			return nil, err
+		} else if err := e.journalTx(ctx, signedTx); err != nil {
+			return nil, err
+		}
+		return signedTx, nil
+	}
+}
+
+// reattach restores the result of the item journaled by the resumed run, instead of sending its transactions
+// again. An item interrupted while sending is re-attached to its transactions known to the node, the ones
+// unknown have not been sent; if none of them is known, the item is sent again.
+func (e *Executor) reattach(ctx context.Context, item journalItem) (*CommandResult, bool) {
+	journaled := e.journal.Item(item.position, item.cmdName, item.iteration, item.wallet)
+	if journaled == nil || len(journaled.Hashes) == 0 {
+		return nil, false
+	}
+	itemLog := log.WithFields(log.Fields{
+		"command":   item.cmdName,
+		"iteration": item.iteration,
+		"wallet":    item.wallet,
+	})
+	if journaled.Sent && journaled.Result != nil {
+		itemLog.Infoln("re-attaching to the transactions sent in the resumed run")
+		return restoreResult(journaled.Result), true
+	}
+	var known []string
+	for _, hash := range journaled.Hashes {
+		_, _, err := e.ethCli.TransactionByHash(ctx, common.HexToHash(hash))
+		if err == ethereum.NotFound {
+			continue
+		} else if err != nil {
+			return &CommandResult{Error: err}, true
+		}
+		known = append(known, hash)
+	}
+	journaled.Hashes = known
+	if len(known) == 0 {
+		itemLog.Infoln("sending again — the transactions of the resumed run are not known to the node")
+		if err := e.journal.RecordItem(item.position, item.cmdName, journaled); err != nil {
+			return &CommandResult{Error: err}, true
+		}
+		return nil, false
+	}
+	itemLog.WithField("txs", len(known)).Warningln("re-attaching to the transactions sent before the resumed run was interrupted")
+	result := &CommandResult{}
+	for _, hash := range known {
+		result.Txs = append(result.Txs, "tx:"+hash)
+	}
+	if len(result.Txs) == 1 {
+		result.Result, result.Txs = result.Txs[0], nil
+	}
+	journaled.Sent = true
+	journaled.Result = journalResultOf(result, nil)
+	if err := e.journal.RecordItem(item.position, item.cmdName, journaled); err != nil {
+		return &CommandResult{Error: err}, true
+	}
+	return result, true
+}
+
+// recordItemSent journals the result of the item once it has sent its transactions. The transaction
+// definitely rejected by the node is dropped, so the item is sent again when the run is resumed;
+// after other send errors, the transaction may have been sent and it's re-attached to.
+func (e *Executor) recordItemSent(ctx context.Context, item journalItem, result *CommandResult) {
+	journaled := e.journal.Item(item.position, item.cmdName, item.iteration, item.wallet)
+	if journaled == nil || len(journaled.Hashes) == 0 {
+		return
+	}
+	if result.Error != nil {
+		if !isRejectedTxError(result.Error) {
+			return
+		}
+		last := journaled.Hashes[len(journaled.Hashes)-1]
+		if _, _, err := e.ethCli.TransactionByHash(ctx, common.HexToHash(last)); err != ethereum.NotFound {
+			return
+		}
+		journaled.Hashes = journaled.Hashes[:len(journaled.Hashes)-1]
+	} else {
+		journaled.Sent = true
+		journaled.Result = journalResultOf(result, nil)
+	}
+	if err := e.journal.RecordItem(item.position, item.cmdName, journaled); err != nil {
+		log.WithFields(log.Fields{
+			"run":     e.journal.ID,
+			"command": item.cmdName,
+		}).WithError(err).Warningln("failed to update run journal")
+	}
+}
+
+// dropItemTx removes the items having sent the failed transaction from the journal, to be sent again.
+func (e *Executor) dropItemTx(position int, cmdName string, txHash common.Hash) {
+	if e.journal == nil || position < 0 {
+		return
+	}
+	if err := e.journal.DropItemTx(position, cmdName, txHash.Hex()); err != nil {
+		log.WithFields(log.Fields{
+			"run":     e.journal.ID,
+			"command": cmdName,
+		}).WithError(err).Warningln("failed to update run journal")
+	}
+}
+
+func restoreNumbers(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case json.Number:
+		if n, ok := new(big.Int).SetString(vv.String(), 10); ok {
+			return n
+		}
+		return vv.String()
+	case []interface{}:
+		for i := range vv {
+			vv[i] = restoreNumbers(vv[i])
+		}
+		return vv
+	case map[string]interface{}:
+		for k := range vv {
+			vv[k] = restoreNumbers(vv[k])
+		}
+		return vv
+	default:
+		return v
+	}
+}
@@ -0,0 +1,157 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// LogEvent is the decoded event found by the logs query, with its position in the chain.
+type LogEvent struct {
+	Block    uint64 `json:"block"`
+	TxHash   string `json:"txHash"`
+	LogIndex uint   `json:"logIndex"`
+	*Event
+}
+
+// ScanEvents queries the logs of the event emitted by the addresses in the range of blocks, default is
+// from genesis to the latest one, in chunks the node accepts. The events matching all filters are
+// passed to fn as each chunk is fetched, so the results are streamed rather than collected.
+func (e *Executor) ScanEvents(ctx context.Context, event *abi.Event, addresses []common.Address,
+	filters []*model.LogFilter, from, to *model.BlockRef, fn func(*LogEvent) error) error {
+	topics, err := e.root.ResolveLogFilters(event, filters)
+	if err != nil {
+		return err
+	}
+	fromBlock, toBlock, err := e.ScanBounds(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	query := ethereum.FilterQuery{
+		Addresses: addresses,
+		Topics:    topics,
+	}
+	return e.scanEvents(ctx, event, query, filters, fromBlock, toBlock, fn)
+}
+
+// ScanBounds resolves the range of blocks to scan, default is from genesis to the latest block.
+func (e *Executor) ScanBounds(ctx context.Context, from, to *model.BlockRef) (uint64, uint64, error) {
+	fromBlock, toBlock, err := e.scanRange(ctx, from)
+	if err != nil {
+		return 0, 0, err
+	}
+	if to != nil {
+		param, err := e.blockParam(ctx, to)
+		if err != nil {
+			return 0, 0, err
+		}
+		last, err := hexutil.DecodeUint64(param)
+		if err != nil {
+			return 0, 0, errors.New("block to scan to must not be pending")
+		} else if last < toBlock {
+			toBlock = last
+		}
+	}
+	if fromBlock > toBlock {
+		return 0, 0, fmt.Errorf("block range %d-%d is empty", fromBlock, toBlock)
+	}
+	return fromBlock, toBlock, nil
+}
+
+// WatchEvents passes the events matching the filters to fn as they are emitted, starting with the
+// block after the latest one, until the context is done.
+func (e *Executor) WatchEvents(ctx model.AppContext, event *abi.Event, addresses []common.Address,
+	filters []*model.LogFilter, fn func(*LogEvent)) error {
+	topics, err := e.root.ResolveLogFilters(event, filters)
+	if err != nil {
+		return err
+	}
+	query := ethereum.FilterQuery{
+		Addresses: addresses,
+		Topics:    topics,
+	}
+	return e.watchLogs(ctx, query, e.eventsHandler(ctx, event, filters, func(logEvent *LogEvent) error {
+		e.notify(ctx, &model.Notification{
+			Type:    model.NotifyEvent,
+			Block:   logEvent.Block,
+			TxHash:  logEvent.TxHash,
+			Address: logEvent.Address,
+			Event:   logEvent.Event.Event,
+			Args:    logEvent.Args,
+		})
+		fn(logEvent)
+		return nil
+	}))
+}
+
+// SetTriggerEvent makes the event triggering the run of the target referenced as @event,
+// its args are the fields along with the block, txHash and address of the emitter.
+func (e *Executor) SetTriggerEvent(event *LogEvent) {
+	output := make(map[string]interface{}, len(event.Args)+3)
+	output["block"] = event.Block
+	output["txHash"] = event.TxHash
+	output["address"] = event.Address
+	for name, value := range event.Args {
+		output[name] = value
+	}
+	e.setOutput(model.TriggerEventOutput, []*CommandResult{{Result: output}})
+}
+
+func (e *Executor) scanEvents(ctx context.Context, event *abi.Event, query ethereum.FilterQuery,
+	filters []*model.LogFilter, fromBlock, toBlock uint64, fn func(*LogEvent) error) error {
+	return e.scanLogsFunc(ctx, query, fromBlock, toBlock, e.eventsHandler(ctx, event, filters, fn))
+}
+
+// eventsHandler returns the handler of the chunks of logs passing the events matching the filters to fn.
+func (e *Executor) eventsHandler(ctx context.Context, event *abi.Event,
+	filters []*model.LogFilter, fn func(*LogEvent) error) func(chunk []types.Log) error {
+	return func(chunk []types.Log) error {
+		for i := range chunk {
+			entry := &chunk[i]
+			if entry.Removed || len(entry.Topics) == 0 || entry.Topics[0] != event.Id() {
+				continue
+			}
+			args, text, err := decodeEventArgs(event, entry)
+			if err != nil {
+				// another event of the same signature with other indexed args
+				continue
+			}
+			matches := true
+			for _, filter := range filters {
+				if !filter.Match(args[filter.Arg]) {
+					matches = false
+					break
+				}
+			}
+			if !matches {
+				continue
+			}
+			decoded := &Event{
+				Address:  strings.ToLower(entry.Address.Hex()),
+				Contract: e.root.Contracts.NameOf(entry.Address.Hex()),
+				Name:     event.Name,
+				Event:    text,
+				Args:     args,
+			}
+			e.annotateEvent(ctx, decoded, entry.Address)
+			if err := fn(&LogEvent{
+				Block:    entry.BlockNumber,
+				TxHash:   entry.TxHash.Hex(),
+				LogIndex: entry.Index,
+				Event:    decoded,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// Alert is the event of a monitored contract matching a rule of the monitor command, the caller
+// is the sender of the transaction, set for the rules checking the callers.
+type Alert struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Caller   string `json:"caller,omitempty"`
+	*LogEvent
+}
+
+// Monitor watches the events of the spec contracts and of the emitters of the rules as they are emitted,
+// until the context is done, passing the events matching the rules to fn as alerts, which are posted
+// to the webhooks as well.
+func (e *Executor) Monitor(ctx model.AppContext, monitors model.Monitors, fn func(*Alert)) error {
+	query := ethereum.FilterQuery{
+		Addresses: e.root.MonitoredAddresses(monitors),
+	}
+	if len(query.Addresses) == 0 {
+		return errors.New("no deployed contracts to monitor")
+	}
+	names := monitors.Names()
+	return e.watchLogs(ctx, query, func(chunk []types.Log) error {
+		// the sender is fetched once per transaction, for all of its events
+		senders := make(map[common.Hash]common.Address)
+		for i := range chunk {
+			entry := &chunk[i]
+			var event *LogEvent
+			for _, name := range names {
+				rule := monitors[name]
+				if !matchesEvent(rule.ABIEvent(), rule.Emitters(), rule.Filters(), entry) {
+					continue
+				}
+				alert := &Alert{
+					Rule:     name,
+					Severity: rule.Severity,
+				}
+				if rule.ChecksCallers() {
+					sender, ok := senders[entry.TxHash]
+					if !ok {
+						var err error
+						if sender, err = e.txSender(ctx, entry.TxHash); err != nil {
+							return err
+						}
+						senders[entry.TxHash] = sender
+					}
+					if rule.KnownCaller(sender) {
+						continue
+					}
+					alert.Caller = strings.ToLower(sender.Hex())
+				}
+				if event == nil {
+					event = &LogEvent{
+						Block:    entry.BlockNumber,
+						TxHash:   entry.TxHash.Hex(),
+						LogIndex: entry.Index,
+						Event:    e.decodeEvent(ctx, entry),
+					}
+				}
+				alert.LogEvent = event
+				e.notify(ctx, &model.Notification{
+					Type:     model.NotifyAlert,
+					Block:    event.Block,
+					TxHash:   event.TxHash,
+					Address:  event.Address,
+					Event:    event.Event.Event,
+					Args:     event.Args,
+					Rule:     alert.Rule,
+					Severity: alert.Severity,
+					Caller:   alert.Caller,
+				})
+				fn(alert)
+			}
+			if event == nil && !entry.Removed {
+				log.WithFields(log.Fields{
+					"block":   entry.BlockNumber,
+					"address": strings.ToLower(entry.Address.Hex()),
+				}).Debugln("event matches no monitor")
+			}
+		}
+		return nil
+	})
+}
+
+// txSender returns the account that has sent the transaction.
+func (e *Executor) txSender(ctx context.Context, txHash common.Hash) (common.Address, error) {
+	var info *struct {
+		From common.Address `json:"from"`
+	}
+	if err := e.ethRPC.CallContext(ctx, &info, "eth_getTransactionByHash", txHash); err != nil {
+		return common.Address{}, err
+	} else if info == nil {
+		return common.Address{}, errors.New("transaction not found")
+	}
+	return info.From, nil
+}
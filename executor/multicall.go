@@ -0,0 +1,271 @@
+package executor
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// aggregate3Selector is the selector of Multicall3 aggregate3((address,bool,bytes)[]),
+// the call is encoded by the tuple encoder of the model, since the ABI encoder has no tuple support.
+var aggregate3Selector = common.FromHex("0x82ad56cb")
+
+const (
+	aggregate3Inputs  = "((address target,bool allowFailure,bytes callData)[] calls)"
+	aggregate3Outputs = "((bool success,bytes returnData)[] returnData)"
+)
+
+type multicallCall struct {
+	Target common.Address
+	Data   []byte
+
+	result *CommandResult
+	method string
+	unpack func(result interface{}, method string, output []byte) error
+}
+
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// viewBatch returns the names of consecutive view commands starting the target, that can be
+// aggregated into a single multicall. Views referencing outputs of other commands, having
+// state overrides, pinned to a block, enumerated, conditional, repeated by foreach or querying
+// a subgraph, end the batch.
+func (e *Executor) viewBatch(target model.TargetSpec) []string {
+	var names []string
+	for _, targetCmd := range target {
+		cmdSpec, ok := e.root.ViewCmds[targetCmd.Name()]
+		if !ok || hasOutputReferences(cmdSpec.ParamValues()) || hasTransformReferences(cmdSpec.Transforms()) ||
+			len(cmdSpec.StateOverrides()) > 0 || cmdSpec.Block() != nil || cmdSpec.Enumerate != nil ||
+			cmdSpec.Condition() != nil || cmdSpec.Foreach != nil || cmdSpec.Kind == model.KindGraphQL {
+			break
+		}
+		names = append(names, targetCmd.Name())
+	}
+	return names
+}
+
+func hasOutputReferences(params []interface{}) bool {
+	for _, param := range params {
+		if _, ok := param.(*model.CommandOutputReference); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTransformReferences(steps []*model.TransformStep) bool {
+	for _, step := range steps {
+		if step.Ref != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// runViewBatch runs the view commands using a single Multicall3 aggregate3 call,
+// if multicall is not available, the commands are run one by one.
+func (e *Executor) runViewBatch(ctx model.AppContext, names []string) [][]*CommandResult {
+	multicallAddr := common.HexToAddress(e.root.Config.MulticallAddress)
+	batchLog := log.WithFields(log.Fields{
+		"multicall": strings.ToLower(multicallAddr.Hex()),
+		"commands":  len(names),
+	})
+	allResults := make([][]*CommandResult, len(names))
+	if !e.multicallAvailable(ctx) {
+		batchLog.Debugln("multicall contract is not deployed, running views one by one")
+		for i, name := range names {
+			allResults[i] = e.runViewCmd(ctx, e.root.ViewCmds[name])
+		}
+		return allResults
+	}
+	var calls []*multicallCall
+	for i, name := range names {
+		results, cmdCalls := e.prepareViewCalls(ctx, e.root.ViewCmds[name])
+		allResults[i] = results
+		calls = append(calls, cmdCalls...)
+	}
+	if len(calls) == 0 {
+		return allResults
+	}
+	returns, err := e.aggregate(ctx, calls)
+	if err != nil {
+		batchLog.WithError(err).Warningln("multicall failed, running views one by one")
+		for i, name := range names {
+			allResults[i] = e.runViewCmd(ctx, e.root.ViewCmds[name])
+		}
+		return allResults
+	}
+	batchLog.Debugln("views aggregated into multicall")
+	for i, call := range calls {
+		if !returns[i].Success {
+			call.result.Error = errors.New("execution reverted")
+			if reason, ok := e.decodeRevert(ctx, returns[i].ReturnData); ok {
+				call.result.Error = fmt.Errorf("execution reverted: %s", reason)
+			}
+			continue
+		}
+		call.result.Result, call.result.Error = unpackValue(call.unpack, call.method, returns[i].ReturnData)
+	}
+	for i, name := range names {
+		e.finishView(e.root.ViewCmds[name], allResults[i])
+	}
+	return allResults
+}
+
+func (e *Executor) multicallAvailable(ctx context.Context) bool {
+	multicallAddr := common.HexToAddress(e.root.Config.MulticallAddress)
+	code, err := e.ethCli.CodeAt(ctx, multicallAddr, nil)
+	return err == nil && len(code) > 0
+}
+
+// aggregate runs the calls using a single Multicall3 aggregate3 call.
+func (e *Executor) aggregate(ctx context.Context, calls []*multicallCall) ([]*multicallResult, error) {
+	multicallAddr := common.HexToAddress(e.root.Config.MulticallAddress)
+	input, err := packAggregate3(calls)
+	if err != nil {
+		return nil, err
+	}
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &multicallAddr,
+		Data: input,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	returns, err := unpackAggregate3(output)
+	if err != nil {
+		return nil, err
+	} else if len(returns) != len(calls) {
+		return nil, fmt.Errorf("expected %d results, got %d", len(calls), len(returns))
+	}
+	return returns, nil
+}
+
+// prepareViewCalls packs the calls of a view command, one per matching wallet, same as runViewCmd.
+// Note that msg.sender of aggregated calls is the multicall contract, not the wallet.
+func (e *Executor) prepareViewCalls(ctx model.AppContext,
+	cmdSpec *model.ViewCmdSpec) ([]*CommandResult, []*multicallCall) {
+	if !cmdSpec.Instance.IsDeployed() {
+		return []*CommandResult{{
+			Error: errors.New("contract instance is not deployed yet"),
+		}}, nil
+	}
+	if err := e.verifyCodeHash(ctx, cmdSpec.Instance); err != nil {
+		return []*CommandResult{{
+			Error: err,
+		}}, nil
+	}
+	binding := cmdSpec.Instance.BoundContract()
+	contractABI := binding.ABI()
+	target := common.HexToAddress(cmdSpec.Instance.Address)
+	newCall := func(result *CommandResult, params []interface{}) *multicallCall {
+		data, err := cmdSpec.Instance.Pack(cmdSpec.Method, params...)
+		if err != nil {
+			result.Error = err
+			return nil
+		}
+		return &multicallCall{
+			Target: target,
+			Data:   data,
+			result: result,
+			method: cmdSpec.Method,
+			unpack: contractABI.Unpack,
+		}
+	}
+	var calls []*multicallCall
+	matchingWallets := cmdSpec.MatchingWallets()
+	if len(matchingWallets) == 0 {
+		params, err := e.replaceReferences(ctx, cmdSpec.ParamValues())
+		result := &CommandResult{
+			Error: err,
+		}
+		if err != nil {
+			return []*CommandResult{result}, nil
+		} else if call := newCall(result, params); call != nil {
+			calls = append(calls, call)
+		}
+		return []*CommandResult{result}, calls
+	}
+	results := make([]*CommandResult, len(matchingWallets))
+	for offset, walletSpec := range matchingWallets {
+		walletAddress := common.HexToAddress(walletSpec.Address)
+		params, err := e.replaceReferences(ctx, replaceWalletPlaceholders(cmdSpec.ParamValues(), walletAddress))
+		results[offset] = &CommandResult{
+			Wallet: walletSpec.Address,
+			Error:  err,
+		}
+		if err != nil {
+			continue
+		} else if call := newCall(results[offset], params); call != nil {
+			calls = append(calls, call)
+		}
+	}
+	return results, calls
+}
+
+// packAggregate3 encodes aggregate3 calldata, every call is allowed to fail.
+func packAggregate3(calls []*multicallCall) ([]byte, error) {
+	tuples := make([]interface{}, len(calls))
+	for i, call := range calls {
+		tuples[i] = []interface{}{call.Target, true, call.Data}
+	}
+	args, err := model.PackTuple(aggregate3Inputs, []interface{}{tuples})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, aggregate3Selector...), args...), nil
+}
+
+// unpackAggregate3 decodes the (bool success, bytes returnData)[] result of aggregate3.
+func unpackAggregate3(output []byte) ([]*multicallResult, error) {
+	values, err := model.UnpackTuple(aggregate3Outputs, output)
+	if err != nil {
+		return nil, fmt.Errorf("malformed multicall result: %v", err)
+	}
+	tuples := values[0].([]interface{})
+	results := make([]*multicallResult, len(tuples))
+	for i, tuple := range tuples {
+		fields := tuple.([]interface{})
+		results[i] = &multicallResult{
+			Success:    fields[0].(bool),
+			ReturnData: fields[1].([]byte),
+		}
+	}
+	return results, nil
+}
+
+func abiWord(v uint64) []byte {
+	return common.LeftPadBytes(new(big.Int).SetUint64(v).Bytes(), 32)
+}
+
+func abiBytes(data []byte) []byte {
+	padded := make([]byte, (len(data)+31)/32*32)
+	copy(padded, data)
+	return append(abiWord(uint64(len(data))), padded...)
+}
+
+// readWord reads a 32-byte word at the offset as uint64, fails for larger values.
+func readWord(data []byte, offset uint64) (uint64, bool) {
+	if offset+32 > uint64(len(data)) {
+		return 0, false
+	}
+	word := data[offset : offset+32]
+	for _, b := range word[:24] {
+		if b != 0 {
+			return 0, false
+		}
+	}
+	return binary.BigEndian.Uint64(word[24:]), true
+}
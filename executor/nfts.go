@@ -0,0 +1,256 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// logScanRange is the initial number of blocks per eth_getLogs request, halved when the node rejects it.
+const logScanRange = 10000
+
+var (
+	erc721TransferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	erc1155SingleTopic  = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+	erc1155BatchTopic   = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
+	// ownerOf(uint256) of ERC-721
+	ownerOfSelector = common.FromHex("0x6352211e")
+	// tokenOfOwnerByIndex(address,uint256) of ERC-721 Enumerable
+	tokenOfOwnerSelector = common.FromHex("0x2f745c59")
+	// balanceOf(address,uint256) of ERC-1155
+	balanceOfTokenSelector = common.FromHex("0x00fdd58e")
+)
+
+// OwnedNFT is the token owned by the wallet, the amount is 1 for ERC-721.
+type OwnedNFT struct {
+	TokenID *big.Int
+	Amount  *big.Int
+}
+
+// OwnedNFTs enumerates the tokens of the collection owned by the account, by index for Enumerable
+// ERC-721 collections, by scanning the transfer logs since the block otherwise. The tokens found
+// in the logs are checked against ownerOf or balanceOf, so the result is as of the latest block.
+func (e *Executor) OwnedNFTs(ctx context.Context, nft *model.NFTSpec,
+	owner common.Address, from *model.BlockRef) ([]*OwnedNFT, error) {
+	collection := common.HexToAddress(nft.Address)
+	if nft.IsEnumerable() {
+		return e.enumerateNFTs(ctx, collection, owner)
+	}
+	fromBlock, toBlock, err := e.scanRange(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	ownerTopic := common.BytesToHash(owner.Bytes())
+	var queries []ethereum.FilterQuery
+	if nft.Standard == model.ERC1155 {
+		topics := []common.Hash{erc1155SingleTopic, erc1155BatchTopic}
+		queries = []ethereum.FilterQuery{
+			{Topics: [][]common.Hash{topics, nil, {ownerTopic}}},
+			{Topics: [][]common.Hash{topics, nil, nil, {ownerTopic}}},
+		}
+	} else {
+		queries = []ethereum.FilterQuery{
+			{Topics: [][]common.Hash{{erc721TransferTopic}, {ownerTopic}}},
+			{Topics: [][]common.Hash{{erc721TransferTopic}, nil, {ownerTopic}}},
+		}
+	}
+	seen := make(map[string]*big.Int)
+	for _, query := range queries {
+		query.Addresses = []common.Address{collection}
+		logs, err := e.scanLogs(ctx, query, fromBlock, toBlock)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range logs {
+			for _, id := range transferredIDs(entry) {
+				seen[id.String()] = id
+			}
+		}
+	}
+	ids := make([]*big.Int, 0, len(seen))
+	for _, id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Cmp(ids[j]) < 0
+	})
+	var owned []*OwnedNFT
+	for _, id := range ids {
+		amount, err := e.ownedAmount(ctx, nft, collection, owner, id)
+		if err != nil {
+			return nil, err
+		} else if amount.Sign() > 0 {
+			owned = append(owned, &OwnedNFT{
+				TokenID: id,
+				Amount:  amount,
+			})
+		}
+	}
+	return owned, nil
+}
+
+// scanRange is the range of blocks from the block, default is genesis, to the latest one.
+func (e *Executor) scanRange(ctx context.Context, from *model.BlockRef) (uint64, uint64, error) {
+	fromBlock := new(big.Int)
+	if from != nil {
+		param, err := e.blockParam(ctx, from)
+		if err != nil {
+			return 0, 0, err
+		}
+		if fromBlock, err = hexutil.DecodeBig(param); err != nil {
+			return 0, 0, errors.New("block to scan from must not be pending")
+		}
+	}
+	latest, err := e.blockHeader(ctx, model.BlockTagLatest)
+	if err != nil {
+		return 0, 0, err
+	}
+	return fromBlock.Uint64(), latest.Number.ToInt().Uint64(), nil
+}
+
+// scanLogs fetches the logs of the range in chunks, the chunk is halved until the node accepts it.
+func (e *Executor) scanLogs(ctx context.Context, query ethereum.FilterQuery, from, to uint64) ([]types.Log, error) {
+	var logs []types.Log
+	err := e.scanLogsFunc(ctx, query, from, to, func(chunk []types.Log) error {
+		logs = append(logs, chunk...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// scanLogsFunc passes the logs of the range to fn as each chunk is fetched.
+func (e *Executor) scanLogsFunc(ctx context.Context, query ethereum.FilterQuery, from, to uint64,
+	fn func(chunk []types.Log) error) error {
+	step := uint64(logScanRange)
+	for start := from; start <= to; {
+		end := start + step - 1
+		if end > to {
+			end = to
+		}
+		query.FromBlock = new(big.Int).SetUint64(start)
+		query.ToBlock = new(big.Int).SetUint64(end)
+		chunk, err := e.ethCli.FilterLogs(ctx, query)
+		if err != nil {
+			if step == 1 || ctx.Err() != nil {
+				return err
+			}
+			step /= 2
+			log.WithError(err).WithField("blocks", step).Debugln("logs rejected, scanning smaller ranges")
+			continue
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+		start = end + 1
+	}
+	return nil
+}
+
+// transferredIDs are the token IDs of the ERC-721 or ERC-1155 transfer log.
+func transferredIDs(entry types.Log) []*big.Int {
+	switch {
+	case len(entry.Topics) == 4 && entry.Topics[0] == erc721TransferTopic:
+		return []*big.Int{entry.Topics[3].Big()}
+	case len(entry.Topics) == 4 && entry.Topics[0] == erc1155SingleTopic && len(entry.Data) >= 32:
+		return []*big.Int{new(big.Int).SetBytes(entry.Data[:32])}
+	case len(entry.Topics) == 4 && entry.Topics[0] == erc1155BatchTopic:
+		// ids are the first of the two dynamic arrays
+		offset, ok := readWord(entry.Data, 0)
+		if !ok {
+			return nil
+		}
+		count, ok := readWord(entry.Data, offset)
+		if !ok || count > uint64(len(entry.Data))/32 {
+			return nil
+		}
+		ids := make([]*big.Int, 0, count)
+		for i := uint64(0); i < count; i++ {
+			start := offset + 32 + i*32
+			if start+32 > uint64(len(entry.Data)) {
+				return nil
+			}
+			ids = append(ids, new(big.Int).SetBytes(entry.Data[start:start+32]))
+		}
+		return ids
+	}
+	return nil
+}
+
+// ownedAmount is the amount of the token owned by the account at the latest block.
+func (e *Executor) ownedAmount(ctx context.Context, nft *model.NFTSpec,
+	collection, owner common.Address, id *big.Int) (*big.Int, error) {
+	tokenID := common.LeftPadBytes(id.Bytes(), 32)
+	if nft.Standard == model.ERC1155 {
+		data := append(append([]byte{}, balanceOfTokenSelector...), common.LeftPadBytes(owner.Bytes(), 32)...)
+		output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+			To:   &collection,
+			Data: append(data, tokenID...),
+		}, nil)
+		if err != nil {
+			return nil, err
+		} else if len(output) != 32 {
+			return nil, errBalanceReverted
+		}
+		return new(big.Int).SetBytes(output), nil
+	}
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &collection,
+		Data: append(append([]byte{}, ownerOfSelector...), tokenID...),
+	}, nil)
+	if err != nil || len(output) != 32 {
+		// burned tokens revert
+		return new(big.Int), nil
+	} else if common.BytesToAddress(output) != owner {
+		return new(big.Int), nil
+	}
+	return big.NewInt(1), nil
+}
+
+// enumerateNFTs reads the tokens of the owner by index, with ERC-721 Enumerable.
+func (e *Executor) enumerateNFTs(ctx context.Context, collection, owner common.Address) ([]*OwnedNFT, error) {
+	account := common.LeftPadBytes(owner.Bytes(), 32)
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &collection,
+		Data: append(append([]byte{}, balanceOfSelector...), account...),
+	}, nil)
+	if err != nil {
+		return nil, err
+	} else if len(output) != 32 {
+		return nil, errBalanceReverted
+	}
+	balance := new(big.Int).SetBytes(output)
+	if !balance.IsUint64() {
+		return nil, errors.New("balance is out of range")
+	}
+	owned := make([]*OwnedNFT, 0, balance.Uint64())
+	for i := uint64(0); i < balance.Uint64(); i++ {
+		data := append(append([]byte{}, tokenOfOwnerSelector...), account...)
+		output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+			To:   &collection,
+			Data: append(data, abiWord(i)...),
+		}, nil)
+		if err != nil {
+			return nil, err
+		} else if len(output) != 32 {
+			return nil, errors.New("tokenOfOwnerByIndex call reverted")
+		}
+		owned = append(owned, &OwnedNFT{
+			TokenID: new(big.Int).SetBytes(output),
+			Amount:  big.NewInt(1),
+		})
+	}
+	return owned, nil
+}
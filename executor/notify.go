@@ -0,0 +1,85 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// notifyTimeout bounds the delivery of a notification, so a slow webhook doesn't stall the run.
+const notifyTimeout = 10 * time.Second
+
+// notify posts the notification to the webhooks accepting its type, one after another, so they receive
+// the notifications in order. A failed delivery is logged, it doesn't fail the run.
+func (e *Executor) notify(ctx context.Context, notification *model.Notification) {
+	if len(e.root.Config.Webhooks) == 0 {
+		return
+	}
+	notification.Time = time.Now().UTC()
+	notification.NodeGroup = e.nodeGroup
+	for _, webhook := range e.root.Config.Webhooks {
+		if !webhook.Accepts(notification.Type) {
+			continue
+		}
+		notifyCtx, cancelFn := context.WithTimeout(ctx, notifyTimeout)
+		if err := webhook.Post(notifyCtx, notification); err != nil {
+			log.WithFields(log.Fields{
+				"webhook": webhook.URL,
+				"type":    notification.Type,
+			}).WithError(err).Warningln("failed to notify the webhook")
+		}
+		cancelFn()
+	}
+}
+
+// notifyResults notifies of the command completed or failed, the skipped commands are not notified.
+func (e *Executor) notifyResults(ctx context.Context, targetName string, results []*CommandResult) {
+	if len(e.root.Config.Webhooks) == 0 || len(results) == 0 {
+		return
+	}
+	notification := &model.Notification{
+		Type:    model.NotifyCommandCompleted,
+		Target:  targetName,
+		Command: results[0].Name,
+	}
+	skipped := true
+	for _, result := range results {
+		if len(result.Skipped) > 0 {
+			continue
+		}
+		skipped = false
+		for _, handle := range result.TxHandles() {
+			if txHash, ok := handle.(string); ok && strings.HasPrefix(txHash, "tx:") {
+				notification.Txs = append(notification.Txs, strings.TrimPrefix(txHash, "tx:"))
+			}
+		}
+		if result.Error != nil && len(notification.Error) == 0 {
+			notification.Type = model.NotifyCommandFailed
+			notification.Error = result.Error.Error()
+		}
+	}
+	if !skipped {
+		e.notify(ctx, notification)
+	}
+}
+
+// notifyingResults returns the channel of the target results passing them to out once their
+// notifications are posted, out is closed after the results channel is.
+func (e *Executor) notifyingResults(ctx context.Context, targetName string, out chan<- []*CommandResult) chan<- []*CommandResult {
+	if len(e.root.Config.Webhooks) == 0 {
+		return out
+	}
+	resultsC := make(chan []*CommandResult, cap(out))
+	go func() {
+		defer close(out)
+		for results := range resultsC {
+			e.notifyResults(ctx, targetName, results)
+			out <- results
+		}
+	}()
+	return resultsC
+}
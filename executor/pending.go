@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// PendingTx is a transaction of the wallet waiting in the pool of the node. Only the nonce
+// is known if the node doesn't expose the pool.
+type PendingTx struct {
+	Nonce                uint64   `json:"nonce"`
+	Hash                 string   `json:"hash,omitempty"`
+	To                   string   `json:"to,omitempty"`
+	Value                *big.Int `json:"value,omitempty"`
+	Gas                  uint64   `json:"gas,omitempty"`
+	GasPrice             *big.Int `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *big.Int `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *big.Int `json:"maxPriorityFeePerGas,omitempty"`
+	// Queued is set for transactions that can't be mined yet, because of a nonce gap.
+	Queued bool `json:"queued,omitempty"`
+}
+
+type rpcPoolTx struct {
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Hash                 common.Hash     `json:"hash"`
+	To                   *common.Address `json:"to"`
+	Value                *hexutil.Big    `json:"value"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+}
+
+// pendingTxs lists the transactions of the account in the pool, sorted by nonce. The pool is read
+// with txpool_contentFrom, or txpool_content of older nodes; providers not exposing the pool only
+// tell the nonces between the mined and the pending ones.
+func (e *Executor) pendingTxs(ctx context.Context, account common.Address) ([]*PendingTx, error) {
+	var content struct {
+		Pending map[string]*rpcPoolTx `json:"pending"`
+		Queued  map[string]*rpcPoolTx `json:"queued"`
+	}
+	err := e.ethRPC.CallContext(ctx, &content, "txpool_contentFrom", account)
+	if err != nil {
+		var pool struct {
+			Pending map[string]map[string]*rpcPoolTx `json:"pending"`
+			Queued  map[string]map[string]*rpcPoolTx `json:"queued"`
+		}
+		if poolErr := e.ethRPC.CallContext(ctx, &pool, "txpool_content"); poolErr != nil {
+			log.WithError(err).Debugln("the pool is not exposed by the node, listing the pending nonces")
+			return e.pendingNonces(ctx, account)
+		}
+		content.Pending = accountPoolTxs(pool.Pending, account)
+		content.Queued = accountPoolTxs(pool.Queued, account)
+	}
+	txs := make([]*PendingTx, 0, len(content.Pending)+len(content.Queued))
+	for _, tx := range content.Pending {
+		txs = append(txs, newPendingTx(tx, false))
+	}
+	for _, tx := range content.Queued {
+		txs = append(txs, newPendingTx(tx, true))
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].Nonce < txs[j].Nonce
+	})
+	return txs, nil
+}
+
+func (e *Executor) pendingNonces(ctx context.Context, account common.Address) ([]*PendingTx, error) {
+	mined, err := e.ethCli.NonceAt(ctx, account, nil)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := e.ethCli.PendingNonceAt(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*PendingTx, 0)
+	for nonce := mined; nonce < pending; nonce++ {
+		txs = append(txs, &PendingTx{
+			Nonce: nonce,
+		})
+	}
+	return txs, nil
+}
+
+// accountPoolTxs picks the transactions of the account from the pool, its keys are checksummed or not, depending on the node.
+func accountPoolTxs(pool map[string]map[string]*rpcPoolTx, account common.Address) map[string]*rpcPoolTx {
+	for address, txs := range pool {
+		if strings.EqualFold(address, account.Hex()) {
+			return txs
+		}
+	}
+	return nil
+}
+
+func newPendingTx(tx *rpcPoolTx, queued bool) *PendingTx {
+	pendingTx := &PendingTx{
+		Nonce:                uint64(tx.Nonce),
+		Hash:                 tx.Hash.Hex(),
+		Value:                tx.Value.ToInt(),
+		Gas:                  uint64(tx.Gas),
+		GasPrice:             tx.GasPrice.ToInt(),
+		MaxFeePerGas:         tx.MaxFeePerGas.ToInt(),
+		MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas.ToInt(),
+		Queued:               queued,
+	}
+	if tx.To != nil {
+		pendingTx.To = tx.To.Hex()
+	}
+	return pendingTx
+}
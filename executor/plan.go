@@ -0,0 +1,265 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// PlannedTx is a transaction the write command would send, it's the result of write commands in plan mode.
+type PlannedTx struct {
+	From     string   `json:"from"`
+	To       string   `json:"to,omitempty"`
+	Contract string   `json:"contract,omitempty"`
+	Creates  string   `json:"creates,omitempty"`
+	Method   string   `json:"method,omitempty"`
+	Args     []string `json:"args,omitempty"`
+	Value    string   `json:"value,omitempty"`
+	Nonce    uint64   `json:"nonce"`
+	Gas      uint64   `json:"gas,omitempty"`
+	GasError string   `json:"gasError,omitempty"`
+}
+
+// SetPlan enables the plan mode: write commands resolve their transactions and estimate the gas
+// without sending, CALL commands run only if read-only, views run as usual.
+func (e *Executor) SetPlan(plan bool) {
+	e.plan = plan
+}
+
+// planTargetCmd runs the command in plan mode, its results are available to the commands
+// planned next, the transactions of write commands are planned rather than sent.
+func (e *Executor) planTargetCmd(ctx model.AppContext, cmdName string) []*CommandResult {
+	var results []*CommandResult
+	if cmdSpec, ok := e.root.CallCmds[cmdName]; ok {
+		if !cmdSpec.IsReadOnly() {
+			return []*CommandResult{{Skipped: "not run in plan mode, " + cmdSpec.Method + " is not read-only"}}
+		}
+		results = e.runCallCmd(ctx, cmdSpec)
+	} else if cmdSpec, ok := e.root.ViewCmds[cmdName]; ok {
+		results = e.runViewCmd(ctx, cmdSpec)
+	} else if cmdSpec, ok := e.root.WriteCmds[cmdName]; ok {
+		results = e.runWriteCmd(ctx, cmdSpec)
+	}
+	e.setOutput(cmdName, results)
+	return results
+}
+
+// planNonce reserves n nonces of the account, following the pending nonce and the transactions planned before.
+func (e *Executor) planNonce(ctx model.AppContext, account common.Address, n int) (uint64, error) {
+	client := e.walletClient(account)
+	e.walletMux.Lock()
+	defer e.walletMux.Unlock()
+	nonce, ok := e.plannedNonces[account]
+	if !ok {
+		pending, err := client.PendingNonceAt(ctx, account)
+		if err != nil {
+			return 0, err
+		}
+		nonce = pending
+	}
+	e.plannedNonces[account] = nonce + uint64(n)
+	return nonce, nil
+}
+
+func (e *Executor) isPlannedDeployment(instance *model.ContractInstanceSpec) bool {
+	e.walletMux.Lock()
+	defer e.walletMux.Unlock()
+	_, ok := e.plannedDeployments[instance]
+	return ok
+}
+
+// planWriteCmd resolves the transactions of the write command from the wallet the same way they
+// would be sent, deployments and clones are given the addresses they would be created at.
+func (e *Executor) planWriteCmd(ctx model.AppContext, cmdSpec *model.WriteCmdSpec,
+	wallet *model.WalletSpec, gasPrice *big.Int, denominations []string) []*CommandResult {
+	result := &CommandResult{}
+	account := common.HexToAddress(wallet.Address)
+	txs, msgs, err := e.planTxs(ctx, cmdSpec, account, denominations)
+	if err != nil {
+		result.Error = err
+		return []*CommandResult{result}
+	}
+	nonce, err := e.planNonce(ctx, account, len(txs))
+	if err != nil {
+		result.Error = err
+		return []*CommandResult{result}
+	}
+	for i, tx := range txs {
+		tx.Nonce = nonce + uint64(i)
+		if msgs[i].To == nil {
+			tx.Creates = strings.ToLower(crypto.CreateAddress(account, tx.Nonce).Hex())
+			if tx.Method == "constructor" {
+				e.walletMux.Lock()
+				e.plannedDeployments[cmdSpec.Instance] = tx.Creates
+				e.walletMux.Unlock()
+			}
+		}
+		msg := msgs[i]
+		msg.From = account
+		msg.GasPrice = gasPrice
+		if tx.Gas = cmdSpec.GasLimit; tx.Gas > 0 || len(tx.GasError) > 0 {
+			continue
+		}
+		gas, err := e.ethCli.EstimateGas(ctx, msg)
+		if err != nil {
+			tx.GasError = err.Error()
+			if reason, ok := e.simulateRevert(ctx, msg, nil); ok {
+				tx.GasError = fmt.Sprintf("%v: %s", err, reason)
+			}
+			continue
+		}
+		tx.Gas = gas
+	}
+	result.Result = txs
+	return []*CommandResult{result}
+}
+
+// planTxs resolves the transactions of the write command from the account, without nonces and gas.
+func (e *Executor) planTxs(ctx model.AppContext, cmdSpec *model.WriteCmdSpec,
+	account common.Address, denominations []string) ([]*PlannedTx, []ethereum.CallMsg, error) {
+	newTx := func() *PlannedTx {
+		return &PlannedTx{
+			From: strings.ToLower(account.Hex()),
+		}
+	}
+	var (
+		txs  []*PlannedTx
+		msgs []ethereum.CallMsg
+	)
+	switch {
+	case cmdSpec.Clone != nil:
+		initCode := cloneInitCode(common.HexToAddress(cmdSpec.Clone.Address))
+		for i := 0; i < cmdSpec.Count; i++ {
+			tx := newTx()
+			tx.Contract = cmdSpec.Clone.Name
+			tx.Method = "clone"
+			txs = append(txs, tx)
+			msgs = append(msgs, ethereum.CallMsg{Data: initCode})
+		}
+	case len(cmdSpec.OwnershipCalls()) > 0:
+		binding := cmdSpec.Instance.BoundContract()
+		to := common.HexToAddress(cmdSpec.Instance.Address)
+		for _, call := range cmdSpec.OwnershipCalls() {
+			params := replaceWalletPlaceholders(call.Params, account)
+			input, err := binding.ABI().Pack(call.Method, params...)
+			if err != nil {
+				return nil, nil, err
+			}
+			tx := newTx()
+			tx.To = strings.ToLower(to.Hex())
+			tx.Contract = cmdSpec.Instance.Name
+			tx.Method = call.Method
+			tx.Args = planArgs(params)
+			txs = append(txs, tx)
+			msgs = append(msgs, ethereum.CallMsg{To: &to, Data: input})
+		}
+	default:
+		tx, msg, err := e.planWriteTx(ctx, cmdSpec, account, denominations)
+		if err != nil {
+			return nil, nil, err
+		}
+		tx.From = strings.ToLower(account.Hex())
+		txs = append(txs, tx)
+		msgs = append(msgs, msg)
+	}
+	return txs, msgs, nil
+}
+
+// planWriteTx resolves the single transaction of the write command: an ether transfer,
+// a deployment, a token transfer or a method call.
+func (e *Executor) planWriteTx(ctx model.AppContext, cmdSpec *model.WriteCmdSpec,
+	account common.Address, denominations []string) (*PlannedTx, ethereum.CallMsg, error) {
+	tx := new(PlannedTx)
+	var msg ethereum.CallMsg
+	value, err := e.writeValue(ctx, cmdSpec, denominations)
+	if err != nil {
+		return nil, msg, err
+	}
+	denominatorCommonOrEmpty := len(value.Denominator) == 0 || model.IsCommonDenominator(value.Denominator)
+	if denominatorCommonOrEmpty && value.Value != nil && (len(cmdSpec.To) > 0 || !cmdSpec.Instance.IsDeployed()) {
+		// the value is sent with ether transfers and deployments only
+		tx.Value = value.Value.String()
+		msg.Value = value.Value
+	}
+	if denominatorCommonOrEmpty && len(cmdSpec.To) > 0 {
+		to := common.HexToAddress(cmdSpec.To)
+		tx.To = strings.ToLower(to.Hex())
+		msg.To = &to
+		return tx, msg, nil
+	}
+	if denominatorCommonOrEmpty && !cmdSpec.Instance.IsDeployed() && !e.isPlannedDeployment(cmdSpec.Instance) {
+		source := cmdSpec.Instance.BoundContract().Source()
+		if len(source.Bin) == 0 {
+			return nil, msg, errors.New("contract has no bytecode to deploy")
+		}
+		params, err := e.replaceReferences(ctx, replaceWalletPlaceholders(cmdSpec.ParamValues(), account))
+		if err != nil {
+			return nil, msg, err
+		}
+		input, err := cmdSpec.Instance.Pack("", params...)
+		if err != nil {
+			return nil, msg, err
+		}
+		tx.Contract = cmdSpec.Instance.Name
+		tx.Method = "constructor"
+		tx.Args = planArgs(params)
+		msg.Data = append(common.FromHex(source.Bin), input...)
+		return tx, msg, nil
+	}
+	instance := cmdSpec.Instance
+	method := cmdSpec.Method
+	var params []interface{}
+	if len(value.Denominator) > 0 && !denominatorCommonOrEmpty {
+		var ok bool
+		if instance, ok = e.root.FindTokenInstance(value.Denominator); !ok {
+			return nil, msg, fmt.Errorf("referenced token contract not found: %s", value.Denominator)
+		} else if len(cmdSpec.To) == 0 {
+			return nil, msg, errors.New("no transfer recipient address specified")
+		}
+		method = "transfer"
+		params = []interface{}{common.HexToAddress(cmdSpec.To), value.Value}
+	} else {
+		var err error
+		if params, err = e.replaceReferences(ctx, replaceWalletPlaceholders(cmdSpec.ParamValues(), account)); err != nil {
+			return nil, msg, err
+		}
+	}
+	address := instance.Address
+	if !instance.IsDeployed() {
+		e.walletMux.Lock()
+		address = e.plannedDeployments[instance]
+		e.walletMux.Unlock()
+		if len(address) == 0 {
+			return nil, msg, fmt.Errorf("contract instance %s is not deployed yet", instance.Name)
+		}
+		// the gas can't be estimated before the contract is deployed
+		tx.GasError = "known after the deployment"
+	}
+	input, err := instance.Pack(method, params...)
+	if err != nil {
+		return nil, msg, err
+	}
+	to := common.HexToAddress(address)
+	tx.To = strings.ToLower(to.Hex())
+	tx.Contract = instance.Name
+	tx.Method = method
+	tx.Args = planArgs(params)
+	msg.To = &to
+	msg.Data = input
+	return tx, msg, nil
+}
+
+func planArgs(params []interface{}) []string {
+	args := make([]string, 0, len(params))
+	for _, param := range params {
+		args = append(args, model.ExpectFormat(param))
+	}
+	return args
+}
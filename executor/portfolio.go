@@ -0,0 +1,116 @@
+package executor
+
+import (
+	"math/big"
+	"sort"
+)
+
+// NetworkBalances is the balance matrix of the wallets on a network of the spec, Err is set
+// if the network couldn't be queried.
+type NetworkBalances struct {
+	Network string
+	Matrix  *BalanceMatrix
+	Err     error
+}
+
+// Portfolio is the balances of the wallets on each network and their totals across the networks.
+type Portfolio struct {
+	Networks []*NetworkBalances
+	// Assets are ETH followed by the token symbols of all networks, in the order of the total amounts.
+	Assets []string
+	Totals []*PortfolioRow
+	// Currency is the fiat currency the balances are valued in, if all of the networks are valued.
+	Currency string
+}
+
+// PortfolioRow is the holdings of a wallet on all networks, the amounts are in asset units, nil if
+// the wallet holds none of the asset. Values are the amounts in the fiat currency, Total is their sum.
+type PortfolioRow struct {
+	Name    string
+	Amounts []*big.Rat
+	Values  []*big.Rat
+	Total   *big.Rat
+}
+
+// AggregateBalances sums the balances of the wallets of the networks by the wallet name and the asset
+// symbol, in asset units, since the decimals of a token may differ between the chains. The native coins
+// of the networks are ETH, as in the balance matrix; the values are at the prices of their networks.
+func AggregateBalances(networks []*NetworkBalances) *Portfolio {
+	portfolio := &Portfolio{
+		Networks: networks,
+	}
+	assets := make(map[string]bool)
+	amounts := make(map[string]map[string]*big.Rat)
+	values := make(map[string]map[string]*big.Rat)
+	currencies := make(map[string]bool)
+	for _, network := range networks {
+		if network.Matrix == nil {
+			continue
+		}
+		matrix := network.Matrix
+		currencies[matrix.Currency] = true
+		for _, row := range matrix.Rows {
+			walletAmounts, ok := amounts[row.Name]
+			if !ok {
+				walletAmounts = make(map[string]*big.Rat)
+				amounts[row.Name] = walletAmounts
+				values[row.Name] = make(map[string]*big.Rat)
+			}
+			for j, asset := range matrix.Assets {
+				if row.Errors[j] != nil {
+					continue
+				}
+				amount, ok := new(big.Rat).SetString(row.Amounts[j])
+				if !ok || amount.Sign() == 0 {
+					continue
+				}
+				assets[asset] = true
+				addRat(walletAmounts, asset, amount)
+				if row.Values != nil && row.Values[j] != nil {
+					addRat(values[row.Name], asset, row.Values[j])
+				}
+			}
+		}
+	}
+	if len(currencies) == 1 {
+		for currency := range currencies {
+			portfolio.Currency = currency
+		}
+	}
+	portfolio.Assets = sortedAssets(assets)
+	names := make([]string, 0, len(amounts))
+	for name := range amounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		row := &PortfolioRow{
+			Name:    name,
+			Amounts: make([]*big.Rat, len(portfolio.Assets)),
+		}
+		for i, asset := range portfolio.Assets {
+			row.Amounts[i] = amounts[name][asset]
+		}
+		if len(portfolio.Currency) > 0 {
+			row.Values = make([]*big.Rat, len(portfolio.Assets))
+			row.Total = new(big.Rat)
+			for i, asset := range portfolio.Assets {
+				if value, ok := values[name][asset]; ok {
+					row.Values[i] = value
+					row.Total.Add(row.Total, value)
+				}
+			}
+		}
+		portfolio.Totals = append(portfolio.Totals, row)
+	}
+	return portfolio
+}
+
+// addRat adds the amount to the sum of the key.
+func addRat(sums map[string]*big.Rat, key string, amount *big.Rat) {
+	if sum, ok := sums[key]; ok {
+		sum.Add(sum, amount)
+		return
+	}
+	sums[key] = new(big.Rat).Set(amount)
+}
@@ -0,0 +1,134 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// FiatPrice returns the price of the asset, EtherAsset or the symbol of a token of the spec, in the fiat
+// currency of config: the current one if the time is zero, otherwise the price of its day. The current
+// usd prices are read from the price feeds of the assets, if set, the rest come from the price API.
+// The prices are memoized for the run.
+func (e *Executor) FiatPrice(ctx context.Context, asset string, at time.Time) (*big.Rat, error) {
+	if len(e.root.Config.FiatCurrency) == 0 {
+		return nil, errors.New("no fiatCurrency is set in config")
+	}
+	key := asset
+	if !at.IsZero() {
+		key += "@" + at.UTC().Format("2006-01-02")
+	}
+	e.pricesMux.Lock()
+	price, ok := e.prices[key]
+	e.pricesMux.Unlock()
+	if ok {
+		return price, nil
+	}
+	price, err := e.fiatPrice(ctx, asset, at)
+	if err != nil {
+		return nil, err
+	}
+	e.pricesMux.Lock()
+	e.prices[key] = price
+	e.pricesMux.Unlock()
+	return price, nil
+}
+
+func (e *Executor) fiatPrice(ctx context.Context, asset string, at time.Time) (*big.Rat, error) {
+	api := e.root.Config.PriceAPI()
+	feed := &SwapAsset{
+		Symbol:    asset,
+		PriceFeed: e.root.Config.NativePriceFeed,
+	}
+	var token *model.TokenSpec
+	if asset != EtherAsset {
+		var ok bool
+		if token, ok = e.root.Tokens.Find(asset); !ok {
+			return nil, fmt.Errorf("token %s is not found in TOKENS section", asset)
+		}
+		feed.PriceFeed = token.PriceFeed
+	}
+	if at.IsZero() && api.Currency() == "usd" && len(feed.PriceFeed) > 0 {
+		answer, decimals, err := e.feedPrice(ctx, feed)
+		if err == nil {
+			return new(big.Rat).SetFrac(answer, pow10(decimals)), nil
+		}
+		log.WithError(err).WithField("asset", asset).Warningln("failed to read the price feed, querying the price API")
+	}
+	coinID := e.root.Config.NativePriceID
+	if token != nil {
+		var err error
+		if coinID, err = api.CoinID(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+	return api.Price(ctx, coinID, at)
+}
+
+// fiatValue returns the value of the amount in the units of the asset, e.g. 1.5 of 1.5 ETH, at the price.
+func fiatValue(amount string, price *big.Rat) (*big.Rat, bool) {
+	value, ok := new(big.Rat).SetString(amount)
+	if !ok {
+		return nil, false
+	}
+	return value.Mul(value, price), true
+}
+
+// ValueBalances values the balances of the matrix in the fiat currency of config at the current prices,
+// the assets with no price are left out of the totals of the wallets, with a warning.
+func (e *Executor) ValueBalances(ctx context.Context, matrix *BalanceMatrix) {
+	matrix.Currency = e.root.Config.FiatCurrency
+	matrix.Prices = make([]*big.Rat, len(matrix.Assets))
+	for i, asset := range matrix.Assets {
+		price, err := e.FiatPrice(ctx, asset, time.Time{})
+		if err != nil {
+			log.WithError(err).WithField("asset", asset).Warningln("no price of the asset, its balances are not valued")
+			continue
+		}
+		matrix.Prices[i] = price
+	}
+	for _, row := range matrix.Rows {
+		row.Values = make([]*big.Rat, len(matrix.Assets))
+		row.Total = new(big.Rat)
+		for i, price := range matrix.Prices {
+			if price == nil || row.Errors[i] != nil {
+				continue
+			}
+			if value, ok := fiatValue(row.Amounts[i], price); ok {
+				row.Values[i] = value
+				row.Total.Add(row.Total, value)
+			}
+		}
+	}
+}
+
+// ValueHistory values the amounts and the fees of the transfers in the fiat currency of config,
+// at the prices of their days. The transfers of the assets with no price are left without values.
+func (e *Executor) ValueHistory(ctx context.Context, entries []*HistoryEntry) {
+	failed := make(map[string]bool)
+	value := func(asset, amount string, at time.Time) string {
+		if len(amount) == 0 || failed[asset] {
+			return ""
+		}
+		price, err := e.FiatPrice(ctx, asset, at)
+		if err != nil {
+			log.WithError(err).WithField("asset", asset).Warningln("no price of the asset, its transfers are not valued")
+			failed[asset] = true
+			return ""
+		}
+		if value, ok := fiatValue(amount, price); ok {
+			return model.FormatFiat(value)
+		}
+		return ""
+	}
+	for _, entry := range entries {
+		entry.Value = value(entry.Asset, entry.Amount, entry.Time)
+		entry.FeeValue = value(EtherAsset, entry.Fee, entry.Time)
+	}
+}
@@ -0,0 +1,28 @@
+package executor
+
+import (
+	"sync/atomic"
+)
+
+// ProgressFunc is called when an item of a command is done: a wallet the command fans out to, or
+// a row of foreach. Items of concurrent commands are reported concurrently.
+type ProgressFunc func(result *CommandResult)
+
+// SetProgress sets the function reporting the items done, progress is not reported if it's nil.
+func (e *Executor) SetProgress(fn ProgressFunc) {
+	e.progress = fn
+}
+
+func (e *Executor) itemDone(result *CommandResult) {
+	if e.progress == nil {
+		return
+	}
+	atomic.AddInt64(&e.itemsDone, 1)
+	e.progress(result)
+}
+
+// itemsReported returns the number of items reported so far, so the row that fanned out
+// to wallets is not reported again.
+func (e *Executor) itemsReported() int64 {
+	return atomic.LoadInt64(&e.itemsDone)
+}
@@ -0,0 +1,184 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// AccountProof is the account state and storage values,
+// verified against the state root of the block.
+type AccountProof struct {
+	Address     string          `json:"address"`
+	Block       string          `json:"block"`
+	StateRoot   string          `json:"stateRoot"`
+	Balance     string          `json:"balance"`
+	Nonce       uint64          `json:"nonce"`
+	CodeHash    string          `json:"codeHash"`
+	StorageHash string          `json:"storageHash"`
+	Storage     []*StorageProof `json:"storage,omitempty"`
+}
+
+// StorageProof is a verified value of the storage slot.
+type StorageProof struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type rpcProof struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []struct {
+		Key   string          `json:"key"`
+		Value *hexutil.Big    `json:"value"`
+		Proof []hexutil.Bytes `json:"proof"`
+	} `json:"storageProof"`
+}
+
+type rlpAccount struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// Proof fetches Merkle proofs of the account and its storage slots using eth_getProof,
+// and verifies them locally against the state root of the block, so the values can be
+// fed to light clients or bridges without trusting the node.
+func (e *Executor) Proof(ctx context.Context, address common.Address,
+	slots []common.Hash, at *model.BlockRef) *CommandResult {
+	block, err := e.blockParam(ctx, at)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	// resolve tags first, so the proof and the state root are from the same block
+	header, err := e.blockHeaderWithRoot(ctx, block)
+	if err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to get block: %v", err)}
+	}
+	number := hexutil.EncodeBig(header.Number.ToInt())
+	keys := make([]string, len(slots))
+	for i, slot := range slots {
+		keys[i] = slot.Hex()
+	}
+	var proof *rpcProof
+	if err := e.ethRPC.CallContext(ctx, &proof, "eth_getProof", address, keys, number); err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to get proof: %v", err)}
+	} else if proof == nil {
+		return &CommandResult{Error: errors.New("node returned no proof")}
+	}
+	if err := verifyAccountProof(header.StateRoot, address, proof); err != nil {
+		return &CommandResult{Error: fmt.Errorf("account proof verification failed: %v", err)}
+	}
+	result := &AccountProof{
+		Address:     strings.ToLower(address.Hex()),
+		Block:       header.Number.ToInt().String(),
+		StateRoot:   header.StateRoot.Hex(),
+		Balance:     proof.Balance.ToInt().String(),
+		Nonce:       uint64(proof.Nonce),
+		CodeHash:    proof.CodeHash.Hex(),
+		StorageHash: proof.StorageHash.Hex(),
+	}
+	for i, slot := range slots {
+		if i >= len(proof.StorageProof) {
+			return &CommandResult{Error: errors.New("node returned less storage proofs than requested")}
+		}
+		storageProof := proof.StorageProof[i]
+		value, err := verifyStorageProof(proof.StorageHash, slot, storageProof.Proof)
+		if err != nil {
+			err = fmt.Errorf("storage proof verification failed for slot %s: %v", slot.Hex(), err)
+			return &CommandResult{Error: err}
+		} else if storageProof.Value != nil && value.Cmp(storageProof.Value.ToInt()) != 0 {
+			err := fmt.Errorf("storage value of slot %s doesn't match the proof", slot.Hex())
+			return &CommandResult{Error: err}
+		}
+		result.Storage = append(result.Storage, &StorageProof{
+			Key:   slot.Hex(),
+			Value: common.BigToHash(value).Hex(),
+		})
+	}
+	return &CommandResult{Result: result}
+}
+
+type blockHeaderWithRoot struct {
+	Number    *hexutil.Big `json:"number"`
+	StateRoot common.Hash  `json:"stateRoot"`
+}
+
+func (e *Executor) blockHeaderWithRoot(ctx context.Context, block string) (*blockHeaderWithRoot, error) {
+	var header *blockHeaderWithRoot
+	if err := e.ethRPC.CallContext(ctx, &header, "eth_getBlockByNumber", block, false); err != nil {
+		return nil, err
+	} else if header == nil || header.Number == nil {
+		return nil, errors.New("block not found")
+	}
+	return header, nil
+}
+
+func proofDB(nodes []hexutil.Bytes) *ethdb.MemDatabase {
+	db := ethdb.NewMemDatabase()
+	for _, node := range nodes {
+		_ = db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+func verifyAccountProof(stateRoot common.Hash, address common.Address, proof *rpcProof) error {
+	value, _, err := trie.VerifyProof(stateRoot, crypto.Keccak256(address.Bytes()), proofDB(proof.AccountProof))
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		// proof of absence, the account must be empty
+		if proof.Balance.ToInt().Sign() != 0 || proof.Nonce != 0 {
+			return errors.New("account is absent from the state, but has balance or nonce")
+		}
+		return nil
+	}
+	var account rlpAccount
+	if err := rlp.DecodeBytes(value, &account); err != nil {
+		return fmt.Errorf("failed to decode account: %v", err)
+	}
+	switch {
+	case account.Nonce != uint64(proof.Nonce):
+		return errors.New("nonce doesn't match the proof")
+	case account.Balance.Cmp(proof.Balance.ToInt()) != 0:
+		return errors.New("balance doesn't match the proof")
+	case account.Root != proof.StorageHash:
+		return errors.New("storage hash doesn't match the proof")
+	case !bytes.Equal(account.CodeHash, proof.CodeHash.Bytes()):
+		return errors.New("code hash doesn't match the proof")
+	}
+	return nil
+}
+
+func verifyStorageProof(storageRoot, slot common.Hash, nodes []hexutil.Bytes) (*big.Int, error) {
+	value, _, err := trie.VerifyProof(storageRoot, crypto.Keccak256(slot.Bytes()), proofDB(nodes))
+	if err != nil {
+		return nil, err
+	} else if value == nil {
+		// proof of absence, the slot is empty
+		return new(big.Int), nil
+	}
+	var content []byte
+	if err := rlp.DecodeBytes(value, &content); err != nil {
+		return nil, fmt.Errorf("failed to decode value: %v", err)
+	}
+	return new(big.Int).SetBytes(content), nil
+}
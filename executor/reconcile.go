@@ -0,0 +1,96 @@
+package executor
+
+import (
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// Reconciliation is the ledger matched against the transfers on chain, the entries left on either side
+// are unmatched: the ledger expects a transfer not found on chain, or a transfer is not in the ledger.
+type Reconciliation struct {
+	Matched int
+	Ledger  []*model.LedgerEntry
+	Chain   []*HistoryEntry
+}
+
+// Reconcile matches the entries of the ledger to the transfers of the wallets, the entries with a txHash
+// first, then the others in the order of the ledger, each to the closest transfer in time within the
+// window with the same wallet, direction, asset, amount and counterparty, if given. The failed transfers,
+// the ones to self and the fees are not expected in the ledger.
+func Reconcile(ledger []*model.LedgerEntry, entries []*HistoryEntry, window time.Duration) *Reconciliation {
+	var transfers []*HistoryEntry
+	amounts := make(map[*HistoryEntry]*big.Rat)
+	for _, entry := range entries {
+		if entry.Failed || entry.Direction == DirectionSelf || len(entry.Amount) == 0 {
+			continue
+		}
+		amount, ok := new(big.Rat).SetString(entry.Amount)
+		if !ok || amount.Sign() == 0 {
+			continue
+		}
+		transfers = append(transfers, entry)
+		amounts[entry] = amount
+	}
+	matched := make(map[*HistoryEntry]bool)
+	unmatched := make(map[*model.LedgerEntry]bool)
+	rec := new(Reconciliation)
+	match := func(expected *model.LedgerEntry) {
+		expectedAmount, _ := new(big.Rat).SetString(expected.Amount)
+		var best *HistoryEntry
+		var bestDistance time.Duration
+		for _, transfer := range transfers {
+			if matched[transfer] || transfer.Wallet != expected.Wallet || transfer.Direction != expected.Direction ||
+				!strings.EqualFold(transfer.Asset, expected.Asset) || amounts[transfer].Cmp(expectedAmount) != 0 {
+				continue
+			} else if len(expected.Counterparty) > 0 && !strings.EqualFold(transfer.Counterparty, expected.Counterparty) {
+				continue
+			}
+			if len(expected.TxHash) > 0 {
+				if strings.EqualFold(transfer.TxHash, expected.TxHash) {
+					best = transfer
+					break
+				}
+				continue
+			} else if !expected.Within(transfer.Time, window) {
+				continue
+			}
+			distance := transfer.Time.Sub(expected.Time)
+			if distance < 0 {
+				distance = -distance
+			}
+			if best == nil || distance < bestDistance {
+				best, bestDistance = transfer, distance
+			}
+		}
+		if best == nil {
+			unmatched[expected] = true
+			return
+		}
+		matched[best] = true
+		rec.Matched++
+	}
+	for _, expected := range ledger {
+		if len(expected.TxHash) > 0 {
+			match(expected)
+		}
+	}
+	for _, expected := range ledger {
+		if len(expected.TxHash) == 0 {
+			match(expected)
+		}
+	}
+	for _, expected := range ledger {
+		if unmatched[expected] {
+			rec.Ledger = append(rec.Ledger, expected)
+		}
+	}
+	for _, transfer := range transfers {
+		if !matched[transfer] {
+			rec.Chain = append(rec.Chain, transfer)
+		}
+	}
+	return rec
+}
@@ -0,0 +1,209 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// ReorgChange is a transaction of the run that has changed its block or status after a reorg,
+// or has been dropped from the chain and sent again with the same nonce. Replaced is set when
+// the nonce has been taken by another transaction, so the dropped one can't be included anymore,
+// and Lost when the node doesn't know the signed transaction, so it can't be sent again.
+type ReorgChange struct {
+	Command     string `json:"command"`
+	TxHash      string `json:"txHash"`
+	FromBlock   uint64 `json:"fromBlock"`
+	ToBlock     uint64 `json:"toBlock,omitempty"`
+	FromStatus  uint64 `json:"fromStatus"`
+	ToStatus    uint64 `json:"toStatus"`
+	Rebroadcast bool   `json:"rebroadcast,omitempty"`
+	Replaced    bool   `json:"replaced,omitempty"`
+	Lost        bool   `json:"lost,omitempty"`
+}
+
+type rpcReceipt struct {
+	BlockNumber       *hexutil.Big   `json:"blockNumber"`
+	BlockHash         common.Hash    `json:"blockHash"`
+	Status            hexutil.Uint64 `json:"status"`
+	GasUsed           hexutil.Uint64 `json:"gasUsed"`
+	EffectiveGasPrice *hexutil.Big   `json:"effectiveGasPrice"`
+}
+
+type monitoredTx struct {
+	position int
+	entry    *model.JournalEntry
+	receipt  *model.JournalReceipt
+	hash     common.Hash
+	// tx is the signed transaction sent again if dropped, nil if the node doesn't know it
+	tx       *types.Transaction
+	dropped  bool
+	replaced bool
+	// lost is set when the dropped transaction can't be sent again, it doesn't hold the settlement
+	lost bool
+}
+
+// MonitorReorgs watches the transactions in the receipts of the run journal until each one is depth blocks
+// deep, or the context is done. The block and the status of the receipts are journaled, a transaction
+// dropped by a reorg is sent again, as signed, and the changes are passed to fn.
+func (e *Executor) MonitorReorgs(ctx model.AppContext, journal *model.RunJournal, depth uint64, fn func(*ReorgChange)) error {
+	var txs []*monitoredTx
+	for position, entry := range journal.Commands {
+		if entry == nil || entry.Status != model.JournalDone {
+			continue
+		}
+		for _, result := range entry.Results {
+			for _, receipt := range result.Receipts {
+				hash := common.HexToHash(receipt.TxHash)
+				tx, _, err := e.ethCli.TransactionByHash(ctx, hash)
+				if err != nil {
+					// the receipt is still monitored, the transaction is looked up again if dropped
+					log.WithFields(log.Fields{
+						"command": entry.Name,
+						"txHash":  receipt.TxHash,
+					}).WithError(err).Warningln("failed to get the transaction")
+					tx = nil
+				}
+				txs = append(txs, &monitoredTx{
+					position: position,
+					entry:    entry,
+					receipt:  receipt,
+					hash:     hash,
+					tx:       tx,
+				})
+			}
+		}
+	}
+	if len(txs) == 0 {
+		return nil
+	}
+	for _, monitored := range txs {
+		if len(monitored.receipt.BlockHash) > 0 {
+			continue
+		}
+		receipt, err := e.rpcReceipt(ctx, monitored.hash)
+		if err != nil {
+			return err
+		} else if receipt != nil {
+			monitored.receipt.BlockNumber = receipt.BlockNumber.ToInt().Uint64()
+			monitored.receipt.BlockHash = strings.ToLower(receipt.BlockHash.Hex())
+			e.recordReceipt(journal, monitored)
+		}
+	}
+	watchCtx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+	return e.WatchBlocks(model.AppContext{Context: watchCtx}, 1, func(head uint64) {
+		settled := true
+		for _, monitored := range txs {
+			if monitored.replaced {
+				continue
+			}
+			included := e.checkReorg(ctx, journal, monitored, fn)
+			if (!included && !monitored.lost) || (included && head < monitored.receipt.BlockNumber+depth) {
+				settled = false
+			}
+		}
+		if settled {
+			cancelFn()
+		}
+	})
+}
+
+// checkReorg compares the receipt of the transaction with the journaled one, it reports whether
+// the transaction is included.
+func (e *Executor) checkReorg(ctx context.Context, journal *model.RunJournal, monitored *monitoredTx, fn func(*ReorgChange)) bool {
+	reorgLog := log.WithFields(log.Fields{
+		"command": monitored.entry.Name,
+		"txHash":  monitored.receipt.TxHash,
+	})
+	change := &ReorgChange{
+		Command:    monitored.entry.Name,
+		TxHash:     monitored.receipt.TxHash,
+		FromBlock:  monitored.receipt.BlockNumber,
+		FromStatus: monitored.receipt.Status,
+		ToStatus:   monitored.receipt.Status,
+	}
+	receipt, err := e.rpcReceipt(ctx, monitored.hash)
+	if err != nil {
+		reorgLog.WithError(err).Warningln("failed to get the receipt")
+		return false
+	} else if receipt == nil {
+		if monitored.dropped {
+			return false
+		}
+		if monitored.tx == nil {
+			tx, _, err := e.ethCli.TransactionByHash(ctx, monitored.hash)
+			if err != nil {
+				reorgLog.WithError(err).Warningln("failed to get the dropped transaction to send it again")
+				monitored.dropped, monitored.lost = true, true
+				change.Lost = true
+				fn(change)
+				return false
+			}
+			monitored.tx = tx
+		}
+		monitored.dropped = true
+		change.Rebroadcast = true
+		if err := e.ethCli.SendTransaction(ctx, monitored.tx); err != nil && !isKnownTxError(err) {
+			if !isNonceTooLowError(err) {
+				reorgLog.WithError(err).Warningln("failed to send the dropped transaction again")
+				monitored.dropped = false
+				return false
+			}
+			change.Rebroadcast, change.Replaced = false, true
+			monitored.replaced = true
+		}
+		fn(change)
+		return false
+	}
+	monitored.dropped, monitored.lost = false, false
+	blockHash := strings.ToLower(receipt.BlockHash.Hex())
+	if blockHash == monitored.receipt.BlockHash && uint64(receipt.Status) == monitored.receipt.Status {
+		return true
+	}
+	monitored.receipt.BlockNumber = receipt.BlockNumber.ToInt().Uint64()
+	monitored.receipt.BlockHash = blockHash
+	monitored.receipt.Status = uint64(receipt.Status)
+	e.recordReceipt(journal, monitored)
+	change.ToBlock, change.ToStatus = monitored.receipt.BlockNumber, monitored.receipt.Status
+	if change.ToBlock != change.FromBlock || change.ToStatus != change.FromStatus {
+		fn(change)
+	}
+	return true
+}
+
+func (e *Executor) recordReceipt(journal *model.RunJournal, monitored *monitoredTx) {
+	if err := journal.Record(monitored.position, monitored.entry); err != nil {
+		log.WithFields(log.Fields{
+			"run":     journal.ID,
+			"command": monitored.entry.Name,
+		}).WithError(err).Warningln("failed to update run journal")
+	}
+}
+
+// rpcReceipt returns the receipt with the block it's included in, nil if the transaction is not mined.
+func (e *Executor) rpcReceipt(ctx context.Context, hash common.Hash) (*rpcReceipt, error) {
+	var receipt *rpcReceipt
+	if err := e.ethRPC.CallContext(ctx, &receipt, "eth_getTransactionReceipt", hash); err != nil {
+		return nil, err
+	} else if receipt != nil && receipt.BlockNumber == nil {
+		return nil, errors.New("receipt has no block")
+	}
+	return receipt, nil
+}
+
+func isKnownTxError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
+
+func isNonceTooLowError(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
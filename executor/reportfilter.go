@@ -0,0 +1,151 @@
+package executor
+
+import (
+	"math/big"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// FilterBalances removes the token columns hidden by the report filter of config from the matrix: the
+// denied tokens and, with spam, the ones looking like spam, the tokens no wallet holds with hideZero,
+// and the valued tokens no wallet holds minValue of. Ether and the allowed tokens are always kept,
+// the totals are of the kept values. It returns the symbols of the hidden tokens.
+func (e *Executor) FilterBalances(matrix *BalanceMatrix) []string {
+	filter := e.root.Config.ReportFilter
+	if filter == nil {
+		return nil
+	}
+	var kept []int
+	var hidden []string
+	for j, asset := range matrix.Assets {
+		if j == 0 || e.keepBalances(filter, matrix, j) {
+			kept = append(kept, j)
+			continue
+		}
+		hidden = append(hidden, asset)
+	}
+	if len(hidden) == 0 {
+		return nil
+	}
+	assets := make([]string, 0, len(kept))
+	for _, j := range kept {
+		assets = append(assets, matrix.Assets[j])
+	}
+	matrix.Assets = assets
+	if matrix.Prices != nil {
+		prices := make([]*big.Rat, 0, len(kept))
+		for _, j := range kept {
+			prices = append(prices, matrix.Prices[j])
+		}
+		matrix.Prices = prices
+	}
+	for _, row := range matrix.Rows {
+		balances := make([]*big.Int, 0, len(kept))
+		amounts := make([]string, 0, len(kept))
+		errs := make([]error, 0, len(kept))
+		for _, j := range kept {
+			balances = append(balances, row.Balances[j])
+			amounts = append(amounts, row.Amounts[j])
+			errs = append(errs, row.Errors[j])
+		}
+		row.Balances, row.Amounts, row.Errors = balances, amounts, errs
+		if row.Values == nil {
+			continue
+		}
+		values := make([]*big.Rat, 0, len(kept))
+		row.Total = new(big.Rat)
+		for _, j := range kept {
+			values = append(values, row.Values[j])
+			if row.Values[j] != nil {
+				row.Total.Add(row.Total, row.Values[j])
+			}
+		}
+		row.Values = values
+	}
+	return hidden
+}
+
+// keepBalances tells if the token of the column j passes the filter.
+func (e *Executor) keepBalances(filter *model.ReportFilterSpec, matrix *BalanceMatrix, j int) bool {
+	symbol := matrix.Assets[j]
+	var address string
+	if token, ok := e.root.Tokens.Find(symbol); ok {
+		address = token.Address
+	}
+	switch {
+	case filter.Allowed(symbol, address):
+		return true
+	case filter.Denied(symbol, address):
+		return false
+	case filter.Spam && e.root.SpamToken(symbol, address):
+		return false
+	}
+	if filter.HideZero {
+		var held bool
+		for _, row := range matrix.Rows {
+			// the wallets not read may hold it
+			if row.Errors[j] != nil || (row.Balances[j] != nil && row.Balances[j].Sign() > 0) {
+				held = true
+				break
+			}
+		}
+		if !held {
+			return false
+		}
+	}
+	if min := filter.MinFiatValue(); min != nil && matrix.Prices != nil && matrix.Prices[j] != nil {
+		for _, row := range matrix.Rows {
+			if row.Errors[j] != nil || (row.Values != nil && row.Values[j] != nil && row.Values[j].Cmp(min) >= 0) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// FilterHistoryAssets removes the transfers of the tokens hidden by the report filter of config: the
+// denied tokens and, with spam, the ones looking like spam and the zero transfers of address poisoning.
+// It's done before the valuation, so the tokens posing as the ones of the spec are not valued at their prices.
+func (e *Executor) FilterHistoryAssets(entries []*HistoryEntry) []*HistoryEntry {
+	filter := e.root.Config.ReportFilter
+	if filter == nil {
+		return entries
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if len(entry.token) == 0 || filter.Allowed(entry.Asset, entry.token) {
+			kept = append(kept, entry)
+			continue
+		} else if filter.Denied(entry.Asset, entry.token) {
+			continue
+		}
+		if filter.Spam {
+			amount, ok := new(big.Rat).SetString(entry.Amount)
+			if !ok || amount.Sign() == 0 || e.root.SpamToken(entry.Asset, entry.token) {
+				continue
+			}
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// FilterHistoryDust removes the valued transfers worth less than minValue of the report filter of config,
+// except of the allowed assets. The transfers paying a fee are kept, the fee is spent anyway.
+func (e *Executor) FilterHistoryDust(entries []*HistoryEntry) []*HistoryEntry {
+	filter := e.root.Config.ReportFilter
+	if filter == nil || filter.MinFiatValue() == nil {
+		return entries
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if len(entry.Value) > 0 && len(entry.Fee) == 0 && !filter.Allowed(entry.Asset, entry.token) {
+			if value, ok := new(big.Rat).SetString(entry.Value); ok && value.Cmp(filter.MinFiatValue()) < 0 {
+				continue
+			}
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
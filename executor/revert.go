@@ -0,0 +1,199 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+var (
+	errorStringSelector = common.FromHex("0x08c379a0")
+	panicSelector       = common.FromHex("0x4e487b71")
+)
+
+var panicCodes = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum value",
+	0x22: "invalid storage byte array encoding",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to zero-initialized internal function",
+}
+
+// decodeRevert decodes Error(string), Panic(uint256) and custom errors
+// declared in the contracts of the spec, from the revert data.
+func (e *Executor) decodeRevert(ctx context.Context, data []byte) (string, bool) {
+	if len(data) < 4 {
+		return "", false
+	}
+	switch {
+	case bytes.Equal(data[:4], errorStringSelector):
+		values, err := unpackValues(data[4:], "string")
+		if err != nil {
+			return "", false
+		}
+		return values[0].(string), true
+	case bytes.Equal(data[:4], panicSelector):
+		values, err := unpackValues(data[4:], "uint256")
+		if err != nil {
+			return "", false
+		}
+		code := values[0].(*big.Int)
+		if desc, ok := panicCodes[code.Uint64()]; ok && code.IsUint64() {
+			return fmt.Sprintf("panic 0x%x: %s", code, desc), true
+		}
+		return fmt.Sprintf("panic 0x%x", code), true
+	}
+	abiErr, ok := e.root.Contracts.FindError(data[:4])
+	if !ok {
+		return e.lookupRevert(ctx, data)
+	}
+	values, err := abiErr.Inputs.UnpackValues(data[4:])
+	if err != nil {
+		return abiErr.Name + "(?)", true
+	}
+	return formatCall(abiErr.Name, values), true
+}
+
+// lookupRevert resolves an unknown custom error using the signature databases.
+func (e *Executor) lookupRevert(ctx context.Context, data []byte) (string, bool) {
+	selector := hexutil.Encode(data[:4])
+	signature, ok := model.LookupSignature(ctx, e.root.Config, model.SignatureFunction, selector)
+	if !ok {
+		return "", false
+	}
+	name, values, ok := decodeBySignature(signature, data[4:])
+	if !ok {
+		return signature, true
+	}
+	return formatCall(name, values), true
+}
+
+// decodeBySignature unpacks the arguments using the types from a text signature,
+// only signatures with elementary and array types are supported.
+func decodeBySignature(signature string, data []byte) (string, []interface{}, bool) {
+	name := signatureName(signature)
+	argTypes := strings.TrimSuffix(strings.TrimPrefix(signature, name+"("), ")")
+	if strings.ContainsAny(argTypes, "()") {
+		return name, nil, false
+	} else if len(argTypes) == 0 {
+		return name, nil, len(data) == 0
+	}
+	values, err := unpackValues(data, strings.Split(argTypes, ",")...)
+	if err != nil {
+		return name, nil, false
+	}
+	return name, values, true
+}
+
+func formatCall(name string, values []interface{}) string {
+	args := make([]string, len(values))
+	for i, v := range values {
+		args[i] = formatValue(v)
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+}
+
+func unpackValues(data []byte, types ...string) ([]interface{}, error) {
+	args := make(abi.Arguments, len(types))
+	for i, typ := range types {
+		abiType, err := abi.NewType(typ)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = abi.Argument{Type: abiType}
+	}
+	return args.UnpackValues(data)
+}
+
+func formatValue(v interface{}) string {
+	switch vv := v.(type) {
+	case common.Address:
+		return strings.ToLower(vv.Hex())
+	case []byte:
+		return hexutil.Encode(vv)
+	case [32]byte:
+		return hexutil.Encode(vv[:])
+	case common.Hash:
+		return vv.Hex()
+	case string:
+		return fmt.Sprintf("%q", vv)
+	default:
+		return fmt.Sprintf("%v", vv)
+	}
+}
+
+func revertData(err error) ([]byte, bool) {
+	callErr, ok := model.AsCallError(err)
+	if !ok {
+		return nil, false
+	}
+	return callErr.Data, true
+}
+
+// withRevertReason appends the decoded revert reason to the error message, if there is one.
+func (e *Executor) withRevertReason(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	data, ok := revertData(err)
+	if !ok {
+		return err
+	}
+	if reason, ok := e.decodeRevert(ctx, data); ok {
+		return fmt.Errorf("%v: %s", err, reason)
+	}
+	return err
+}
+
+// simulateRevert runs the failed call using eth_call to get the revert reason,
+// old nodes return the revert data as the call output, newer ones as the error data.
+func (e *Executor) simulateRevert(ctx context.Context, msg ethereum.CallMsg, block *big.Int) (string, bool) {
+	data, err := model.EthCall(ctx, e.ethRPC, msg, block)
+	if err != nil {
+		if data, ok := revertData(err); ok {
+			return e.decodeRevert(ctx, data)
+		}
+		return "", false
+	}
+	return e.decodeRevert(ctx, data)
+}
+
+// txRevertReason replays a mined transaction that ended with a failing status,
+// on top of the parent block state, to get its revert reason.
+func (e *Executor) txRevertReason(ctx context.Context, tx *types.Transaction) (string, bool) {
+	chainID, _ := e.root.Config.ChainIDInt()
+	from, err := types.Sender(types.NewEIP155Signer(chainID), tx)
+	if err != nil {
+		if from, err = types.Sender(types.HomesteadSigner{}, tx); err != nil {
+			return "", false
+		}
+	}
+	var block *big.Int
+	if number, err := e.txBlockNumber(ctx, tx.Hash()); err == nil && number > 0 {
+		block = new(big.Int).SetUint64(number - 1)
+	}
+	msg := ethereum.CallMsg{
+		From:     from,
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}
+	return e.simulateRevert(ctx, msg, block)
+}
@@ -0,0 +1,211 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// ScanRow is a row extracted by a scan, the fields set depend on the extractor: events have
+// the emitter address, the event and its args, traces the call from, to, value and method,
+// balances the account address, the asset and the balance in its units.
+type ScanRow struct {
+	Block     uint64                 `json:"block"`
+	Extractor string                 `json:"extractor"`
+	TxHash    string                 `json:"txHash,omitempty"`
+	LogIndex  *uint                  `json:"logIndex,omitempty"`
+	Address   string                 `json:"address,omitempty"`
+	Event     string                 `json:"event,omitempty"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+	From      string                 `json:"from,omitempty"`
+	To        string                 `json:"to,omitempty"`
+	Value     string                 `json:"value,omitempty"`
+	Method    string                 `json:"method,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Asset     string                 `json:"asset,omitempty"`
+	Balance   string                 `json:"balance,omitempty"`
+}
+
+type traceFilterEntry struct {
+	Action struct {
+		From  common.Address `json:"from"`
+		To    common.Address `json:"to"`
+		Value *hexutil.Big   `json:"value"`
+		Input hexutil.Bytes  `json:"input"`
+	} `json:"action"`
+	BlockNumber     uint64 `json:"blockNumber"`
+	TraceAddress    []int  `json:"traceAddress"`
+	TransactionHash string `json:"transactionHash"`
+	Type            string `json:"type"`
+	Error           string `json:"error"`
+}
+
+// Scan runs the extractors of the scan over the blocks from the cursor, chunk by chunk, until the
+// last block of the cursor or the context is done. The rows of each chunk are passed to fn in the
+// order of blocks, then the cursor is saved, so an interrupted scan resumes with the next chunk.
+func (e *Executor) Scan(ctx context.Context, scan *model.ScanSpec, cursor *model.ScanCursor, fn func([]*ScanRow) error) error {
+	for !cursor.Done() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start, end := cursor.Next, cursor.Next+uint64(scan.Chunk)-1
+		if end > cursor.To {
+			end = cursor.To
+		}
+		var rows []*ScanRow
+		for _, extractor := range scan.Extract {
+			extracted, err := e.extract(ctx, extractor, start, end)
+			if err != nil {
+				return fmt.Errorf("blocks %d-%d: %v", start, end, err)
+			}
+			rows = append(rows, extracted...)
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].Block < rows[j].Block
+		})
+		if err := fn(rows); err != nil {
+			return err
+		}
+		cursor.Next = end + 1
+		cursor.Rows += len(rows)
+		if err := cursor.Save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Executor) extract(ctx context.Context, extractor *model.ExtractorSpec, start, end uint64) ([]*ScanRow, error) {
+	switch {
+	case extractor.Trigger() != nil:
+		return e.extractEvents(ctx, extractor.Trigger(), start, end)
+	case extractor.Traced() != (common.Address{}):
+		return e.extractTraces(ctx, extractor.Traced(), start, end)
+	}
+	return e.extractBalances(ctx, extractor, start, end)
+}
+
+func (e *Executor) extractEvents(ctx context.Context, trigger *model.Trigger, start, end uint64) ([]*ScanRow, error) {
+	topics, err := e.root.ResolveLogFilters(trigger.ABIEvent(), trigger.Filters)
+	if err != nil {
+		return nil, err
+	}
+	query := ethereum.FilterQuery{
+		Addresses: trigger.Addresses(),
+		Topics:    topics,
+	}
+	var rows []*ScanRow
+	err = e.scanEvents(ctx, trigger.ABIEvent(), query, trigger.Filters, start, end, func(event *LogEvent) error {
+		logIndex := event.LogIndex
+		rows = append(rows, &ScanRow{
+			Block:     event.Block,
+			Extractor: "events",
+			TxHash:    event.TxHash,
+			LogIndex:  &logIndex,
+			Address:   event.Address,
+			Event:     event.Event.Event,
+			Args:      event.Args,
+		})
+		return nil
+	})
+	return rows, err
+}
+
+// extractTraces returns the calls to the account with trace_filter, supported by Erigon, Nethermind and Reth.
+func (e *Executor) extractTraces(ctx context.Context, account common.Address, start, end uint64) ([]*ScanRow, error) {
+	var traces []*traceFilterEntry
+	err := e.ethRPC.CallContext(ctx, &traces, "trace_filter", map[string]interface{}{
+		"fromBlock": hexutil.EncodeUint64(start),
+		"toBlock":   hexutil.EncodeUint64(end),
+		"toAddress": []common.Address{account},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("trace_filter failed: %v", err)
+	}
+	rows := make([]*ScanRow, 0, len(traces))
+	for _, trace := range traces {
+		if trace == nil || trace.Type != "call" {
+			continue
+		}
+		row := &ScanRow{
+			Block:     trace.BlockNumber,
+			Extractor: "traces",
+			TxHash:    trace.TransactionHash,
+			From:      strings.ToLower(trace.Action.From.Hex()),
+			To:        strings.ToLower(trace.Action.To.Hex()),
+			Value:     "0",
+			Error:     trace.Error,
+		}
+		if trace.Action.Value != nil {
+			row.Value = trace.Action.Value.ToInt().String()
+		}
+		if input := trace.Action.Input; len(input) >= 4 {
+			row.Method = hexutil.Encode(input[:4])
+			if _, method, ok := e.root.Contracts.FindMethod(row.To, input[:4]); ok {
+				row.Method = method.Sig()
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// extractBalances samples the balances of the accounts at the blocks of the chunk that are multiples
+// of Every, default is the last block of the chunk, so the samples don't depend on where a scan resumed.
+func (e *Executor) extractBalances(ctx context.Context, extractor *model.ExtractorSpec, start, end uint64) ([]*ScanRow, error) {
+	var blocks []uint64
+	if every := uint64(extractor.Every); every > 0 {
+		for block := (start + every - 1) / every * every; block <= end; block += every {
+			blocks = append(blocks, block)
+		}
+	} else {
+		blocks = []uint64{end}
+	}
+	asset, decimals := EtherAsset, 18
+	token := extractor.BalanceToken()
+	if token != nil {
+		asset, decimals = strings.ToUpper(token.Symbol), *token.Decimals
+	}
+	var rows []*ScanRow
+	for _, block := range blocks {
+		number := new(big.Int).SetUint64(block)
+		for _, account := range extractor.Accounts() {
+			var balance *big.Int
+			if token == nil {
+				var err error
+				if balance, err = e.ethCli.BalanceAt(ctx, account, number); err != nil {
+					return nil, err
+				}
+			} else {
+				tokenAddress := common.HexToAddress(token.Address)
+				output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+					To:   &tokenAddress,
+					Data: append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(account.Bytes(), 32)...),
+				}, number)
+				if err == nil && len(output) != 32 {
+					err = errBalanceReverted
+				}
+				if err != nil {
+					return nil, err
+				}
+				balance = new(big.Int).SetBytes(output)
+			}
+			rows = append(rows, &ScanRow{
+				Block:     block,
+				Extractor: "balances",
+				Address:   strings.ToLower(account.Hex()),
+				Asset:     asset,
+				Balance:   model.FormatUnits(balance, decimals),
+			})
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SetAllowFlagged lets the transfers to the counterparties flagged by the screening be sent,
+// with a warning, the failures of the screening API are overridden too.
+func (e *Executor) SetAllowFlagged(allow bool) {
+	e.allowFlagged = allow
+}
+
+// Screen checks the counterparties against the screening list and API of the config, it fails
+// if any is flagged or can't be screened, unless overridden with SetAllowFlagged.
+func (e *Executor) Screen(ctx context.Context, addresses ...common.Address) error {
+	if e.screener == nil {
+		return nil
+	}
+	for _, address := range addresses {
+		screenLog := log.WithField("address", address.Hex())
+		reason, err := e.screener.Screen(ctx, address)
+		if err != nil {
+			if e.allowFlagged {
+				screenLog.WithError(err).Warningln("failed to screen the counterparty, sending anyway as allowed")
+				continue
+			}
+			return fmt.Errorf("failed to screen %s: %v", address.Hex(), err)
+		} else if len(reason) == 0 {
+			continue
+		}
+		if e.allowFlagged {
+			screenLog.WithField("reason", reason).Warningln("counterparty is flagged by the screening, sending anyway as allowed")
+			continue
+		}
+		return fmt.Errorf("counterparty %s is flagged by the screening (%s), use --allow-flagged to override", address.Hex(), reason)
+	}
+	return nil
+}
+
+// screenTx screens the recipient and the address args of a transaction right before it's signed,
+// once the wallets, command outputs and CLI args they reference have been resolved.
+func (e *Executor) screenTx(ctx context.Context, to *common.Address, params []interface{}) error {
+	var addresses []common.Address
+	if to != nil {
+		addresses = append(addresses, *to)
+	}
+	return e.Screen(ctx, paramAddresses(addresses, params)...)
+}
+
+// paramAddresses appends the non-zero addresses of the params, including the elements of arrays and structs.
+func paramAddresses(addresses []common.Address, params []interface{}) []common.Address {
+	for _, param := range params {
+		switch v := param.(type) {
+		case common.Address:
+			if v != (common.Address{}) {
+				addresses = append(addresses, v)
+			}
+		case []common.Address:
+			for _, address := range v {
+				if address != (common.Address{}) {
+					addresses = append(addresses, address)
+				}
+			}
+		case []interface{}:
+			addresses = paramAddresses(addresses, v)
+		}
+	}
+	return addresses
+}
@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// transfer(address,uint256) of ERC-20
+var transferSelector = common.FromHex("0xa9059cbb")
+
+// walletSender signs the transactions of the wallet sent by the builtin operations.
+type walletSender struct {
+	account  common.Address
+	client   *ethclient.Client
+	pk       *ecdsa.PrivateKey
+	signer   types.Signer
+	gasPrice *big.Int
+}
+
+// newWalletSender prepares signing the transactions of the wallet, it must be locked by the caller.
+func (e *Executor) newWalletSender(ctx model.AppContext, wallet *model.WalletSpec) (*walletSender, error) {
+	pk, err := e.walletKey(wallet)
+	if err != nil {
+		return nil, err
+	}
+	account := common.HexToAddress(wallet.Address)
+	chainID, _ := e.root.Config.ChainIDInt()
+	return &walletSender{
+		account:  account,
+		client:   e.walletClient(account),
+		pk:       pk,
+		signer:   types.NewEIP155Signer(chainID),
+		gasPrice: e.gasPrice(ctx),
+	}, nil
+}
+
+// transferData is the calldata of ERC-20 transfer.
+func transferData(to common.Address, amount *big.Int) []byte {
+	data := append(append([]byte{}, transferSelector...), common.LeftPadBytes(to.Bytes(), 32)...)
+	return append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+}
+
+// signWalletTx signs the transaction, the gas limit is estimated within the configured one.
+func (e *Executor) signWalletTx(ctx context.Context, sender *walletSender,
+	nonce uint64, to common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	gasLimit, _ := e.root.Config.GasLimitInt()
+	estimatedGasLimit, err := sender.client.EstimateGas(ctx, ethereum.CallMsg{
+		From:     sender.account,
+		To:       &to,
+		GasPrice: sender.gasPrice,
+		Value:    value,
+		Data:     data,
+	})
+	if err == nil && estimatedGasLimit < gasLimit {
+		gasLimit = estimatedGasLimit
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	tx := types.NewTransaction(nonce, to, value, gasLimit, sender.gasPrice, data)
+	return types.SignTx(tx, sender.signer, sender.pk)
+}
+
+// approveSpender approves the spender to spend the amount of the token, unless the allowance is enough,
+// and awaits the approval. The hash is empty if nothing has been sent.
+func (e *Executor) approveSpender(ctx context.Context, sender *walletSender,
+	token, spender common.Address, amount *big.Int) (string, error) {
+	data := append(append([]byte{}, allowanceSelector...), common.LeftPadBytes(sender.account.Bytes(), 32)...)
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &token,
+		Data: append(data, common.LeftPadBytes(spender.Bytes(), 32)...),
+	}, nil)
+	if err != nil {
+		return "", err
+	} else if len(output) != 32 {
+		return "", errors.New("allowance call reverted")
+	} else if new(big.Int).SetBytes(output).Cmp(amount) >= 0 {
+		return "", nil
+	}
+	data = append(append([]byte{}, approveSelector...), common.LeftPadBytes(spender.Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(amount.Bytes(), 32)...)
+	nonce, err := sender.client.PendingNonceAt(ctx, sender.account)
+	if err != nil {
+		return "", err
+	}
+	tx, err := e.signWalletTx(ctx, sender, nonce, token, nil, data)
+	if err != nil {
+		return "", err
+	} else if err := sender.client.SendTransaction(ctx, tx); err != nil {
+		return "", e.withRevertReason(ctx, err)
+	}
+	txHash := strings.ToLower(tx.Hash().Hex())
+	log.WithFields(log.Fields{
+		"tx":      txHash,
+		"spender": strings.ToLower(spender.Hex()),
+	}).Println("approving the token spender")
+	awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
+	awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+	defer cancelFn()
+	_, err = e.awaitTx(awaitCtx, "tx:"+txHash)
+	return txHash, err
+}
+
+// sendWalletTx sends the transaction from the wallet and awaits it, the hash is empty if it's not sent.
+func (e *Executor) sendWalletTx(ctx model.AppContext, wallet *model.WalletSpec,
+	to common.Address, value *big.Int, data []byte) (string, error) {
+	unlock := e.lockWallet(common.HexToAddress(wallet.Address))
+	defer unlock()
+	sender, err := e.newWalletSender(ctx, wallet)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := sender.client.PendingNonceAt(ctx, sender.account)
+	if err != nil {
+		return "", err
+	}
+	tx, err := e.signWalletTx(ctx, sender, nonce, to, value, data)
+	if err != nil {
+		return "", err
+	} else if err := sender.client.SendTransaction(ctx, tx); err != nil {
+		return "", e.withRevertReason(ctx, err)
+	}
+	txHash := strings.ToLower(tx.Hash().Hex())
+	awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
+	awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+	defer cancelFn()
+	_, err = e.awaitTx(awaitCtx, "tx:"+txHash)
+	return txHash, err
+}
@@ -0,0 +1,164 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// snapshotChunk is the number of blocks of the transfer logs applied between the checkpoints.
+const snapshotChunk = 50000
+
+// Holder is the balance of the account at the snapshot block, with the IDs of the ERC-721 tokens it owns.
+type Holder struct {
+	Address  string     `json:"address"`
+	Balance  *big.Int   `json:"balance"`
+	TokenIDs []*big.Int `json:"tokenIds,omitempty"`
+}
+
+// Snapshot reconstructs the holders of the asset at the block, applying the Transfer logs of the blocks
+// since the checkpoint. The checkpoint is saved after each chunk of the finalized blocks, the later
+// blocks are applied to its copy, so a reorg can't corrupt it. The holders are sorted by balance,
+// largest first, the accounts with no positive balance are skipped, e.g. of tokens minting with no logs.
+func (e *Executor) Snapshot(ctx context.Context, checkpoint *model.SnapshotCheckpoint, block uint64) ([]*Holder, error) {
+	finalized, err := e.finalizedBlock(ctx)
+	if err != nil {
+		return nil, err
+	} else if finalized > block {
+		finalized = block
+	}
+	query := ethereum.FilterQuery{
+		Addresses: []common.Address{common.HexToAddress(checkpoint.Address)},
+		Topics:    [][]common.Hash{{transferEventID}},
+	}
+	for checkpoint.Next <= finalized {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		start, end := checkpoint.Next, checkpoint.Next+snapshotChunk-1
+		if end > finalized {
+			end = finalized
+		}
+		logs, err := e.scanLogs(ctx, query, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("blocks %d-%d: %v", start, end, err)
+		}
+		applyTransfers(checkpoint, logs)
+		checkpoint.Next = end + 1
+		if err := checkpoint.Save(); err != nil {
+			return nil, err
+		}
+	}
+	state := checkpoint
+	if checkpoint.Next <= block {
+		state = checkpoint.Copy()
+		logs, err := e.scanLogs(ctx, query, state.Next, block)
+		if err != nil {
+			return nil, fmt.Errorf("blocks %d-%d: %v", state.Next, block, err)
+		}
+		applyTransfers(state, logs)
+	}
+	return snapshotHolders(state), nil
+}
+
+// finalizedBlock returns the finalized block, or the block FinalizedDepth blocks deep if the node
+// doesn't know the finalized tag.
+func (e *Executor) finalizedBlock(ctx context.Context) (uint64, error) {
+	if header, err := e.blockHeader(ctx, model.BlockTagFinalized); err == nil {
+		return header.Number.ToInt().Uint64(), nil
+	}
+	latest, err := e.blockHeader(ctx, model.BlockTagLatest)
+	if err != nil {
+		return 0, err
+	} else if number := latest.Number.ToInt().Uint64(); number > model.FinalizedDepth {
+		return number - model.FinalizedDepth, nil
+	}
+	return 0, nil
+}
+
+// applyTransfers applies the transfer logs to the state, the ERC-721 logs have the token ID indexed.
+func applyTransfers(state *model.SnapshotCheckpoint, logs []types.Log) {
+	for _, entry := range logs {
+		if entry.Removed || len(entry.Topics) < 3 || entry.Topics[0] != transferEventID {
+			continue
+		}
+		from := common.BytesToAddress(entry.Topics[1].Bytes())
+		to := common.BytesToAddress(entry.Topics[2].Bytes())
+		if len(entry.Topics) == 4 {
+			tokenID := entry.Topics[3].Big().String()
+			if to == (common.Address{}) {
+				delete(state.Owners, tokenID)
+			} else {
+				state.Owners[tokenID] = strings.ToLower(to.Hex())
+			}
+			continue
+		} else if len(entry.Data) < 32 {
+			continue
+		}
+		value := new(big.Int).SetBytes(entry.Data[:32])
+		if from != (common.Address{}) {
+			addBalance(state.Balances, strings.ToLower(from.Hex()), new(big.Int).Neg(value))
+		}
+		if to != (common.Address{}) {
+			addBalance(state.Balances, strings.ToLower(to.Hex()), value)
+		}
+	}
+}
+
+func addBalance(balances map[string]*big.Int, holder string, delta *big.Int) {
+	balance, ok := balances[holder]
+	if !ok {
+		balance = new(big.Int)
+		balances[holder] = balance
+	}
+	if balance.Add(balance, delta).Sign() == 0 {
+		delete(balances, holder)
+	}
+}
+
+func snapshotHolders(state *model.SnapshotCheckpoint) []*Holder {
+	byAddress := make(map[string]*Holder)
+	for address, balance := range state.Balances {
+		if balance.Sign() > 0 {
+			byAddress[address] = &Holder{
+				Address: address,
+				Balance: new(big.Int).Set(balance),
+			}
+		}
+	}
+	for tokenID, owner := range state.Owners {
+		holder, ok := byAddress[owner]
+		if !ok {
+			holder = &Holder{
+				Address: owner,
+				Balance: new(big.Int),
+			}
+			byAddress[owner] = holder
+		}
+		id, _ := new(big.Int).SetString(tokenID, 10)
+		holder.Balance.Add(holder.Balance, big.NewInt(1))
+		holder.TokenIDs = append(holder.TokenIDs, id)
+	}
+	holders := make([]*Holder, 0, len(byAddress))
+	for _, holder := range byAddress {
+		sort.Slice(holder.TokenIDs, func(i, j int) bool {
+			return holder.TokenIDs[i].Cmp(holder.TokenIDs[j]) < 0
+		})
+		holders = append(holders, holder)
+	}
+	sort.Slice(holders, func(i, j int) bool {
+		if c := holders[i].Balance.Cmp(holders[j].Balance); c != 0 {
+			return c > 0
+		}
+		return holders[i].Address < holders[j].Address
+	})
+	return holders
+}
@@ -0,0 +1,204 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// Kinds of the staking positions.
+const (
+	StakeValidator = "validator"
+	StakeLST       = "lst"
+)
+
+var (
+	gweiWei  = big.NewInt(1e9)
+	etherWei = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+)
+
+// StakingPosition is a validator or a liquid staking token of a stake. Amount is the balance of the
+// validator in wei or of the token in its smallest units, Rate is the ether per token with 18 decimals
+// and Ether the ether the position is worth, in wei. Err is set if the position couldn't be read.
+type StakingPosition struct {
+	Stake    string
+	Wallet   string
+	Kind     string
+	ID       string
+	Status   string
+	Decimals int
+	Amount   *big.Int
+	Rate     *big.Int
+	Ether    *big.Int
+	Err      error
+}
+
+// StakedWallet is the ether of a wallet, liquid and staked in the validators and the tokens, in wei.
+type StakedWallet struct {
+	Name       string
+	Liquid     *big.Int
+	Validators *big.Int
+	LSTs       *big.Int
+	Total      *big.Int
+	Err        error
+}
+
+// StakingReport is the positions of the stakes and the ether of their wallets.
+type StakingReport struct {
+	Positions []*StakingPosition
+	Wallets   []*StakedWallet
+}
+
+// Failed tells if any of the positions or the wallet balances failed to be read.
+func (report *StakingReport) Failed() bool {
+	for _, position := range report.Positions {
+		if position.Err != nil {
+			return true
+		}
+	}
+	for _, wallet := range report.Wallets {
+		if wallet.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Staking reads the positions of the stakes of the names: the balances of the validators from the
+// beacon API, the balances of the tokens and their exchange rates from the chain, and totals the ether
+// of each wallet with its liquid balance. A position held by a wallet in two stakes is counted once.
+func (e *Executor) Staking(ctx context.Context, names []string) *StakingReport {
+	report := &StakingReport{}
+	wallets := make(map[string]*StakedWallet)
+	seen := make(map[string]bool)
+	var ids []string
+	for _, name := range names {
+		stake := e.root.Staking[name]
+		walletName := e.root.Wallets.NameOf(stake.StakerWallet().Address)
+		if _, ok := wallets[walletName]; !ok {
+			wallets[walletName] = &StakedWallet{
+				Name:       walletName,
+				Validators: new(big.Int),
+				LSTs:       new(big.Int),
+			}
+		}
+		for _, id := range stake.Validators {
+			key := walletName + "/validator/" + strings.ToLower(id)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			ids = append(ids, id)
+			report.Positions = append(report.Positions, &StakingPosition{
+				Stake:    name,
+				Wallet:   walletName,
+				Kind:     StakeValidator,
+				ID:       id,
+				Decimals: 18,
+			})
+		}
+		for _, lst := range stake.LSTs {
+			key := walletName + "/lst/" + strings.ToLower(lst.TokenSpec().Address)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			position := &StakingPosition{
+				Stake:    name,
+				Wallet:   walletName,
+				Kind:     StakeLST,
+				ID:       lst.Asset(),
+				Decimals: lst.Decimals(),
+			}
+			position.Err = e.readLST(ctx, stake, lst, position)
+			report.Positions = append(report.Positions, position)
+		}
+	}
+	if len(ids) > 0 {
+		e.readValidators(ctx, ids, report.Positions)
+	}
+	for _, position := range report.Positions {
+		if position.Err != nil {
+			continue
+		}
+		wallet := wallets[position.Wallet]
+		if position.Kind == StakeValidator {
+			wallet.Validators.Add(wallet.Validators, position.Ether)
+		} else {
+			wallet.LSTs.Add(wallet.LSTs, position.Ether)
+		}
+	}
+	for _, wallet := range wallets {
+		walletSpec, _ := e.root.Wallets.WalletSpec(wallet.Name)
+		wallet.Liquid, wallet.Err = e.fundBalance(ctx, nil, common.HexToAddress(walletSpec.Address))
+		wallet.Total = new(big.Int).Add(wallet.Validators, wallet.LSTs)
+		if wallet.Err == nil {
+			wallet.Total.Add(wallet.Total, wallet.Liquid)
+		}
+		report.Wallets = append(report.Wallets, wallet)
+	}
+	sort.Slice(report.Wallets, func(i, j int) bool {
+		return report.Wallets[i].Name < report.Wallets[j].Name
+	})
+	return report
+}
+
+// readValidators reads the validators of the ids from the beacon API into the validator positions,
+// matched by the index or the pubkey.
+func (e *Executor) readValidators(ctx context.Context, ids []string, positions []*StakingPosition) {
+	validators, err := e.root.Config.BeaconAPI().Validators(ctx, ids)
+	byID := make(map[string]*model.BeaconValidator, 2*len(validators))
+	for _, validator := range validators {
+		byID[validator.Index] = validator
+		byID[strings.ToLower(validator.Pubkey)] = validator
+	}
+	for _, position := range positions {
+		if position.Kind != StakeValidator {
+			continue
+		}
+		validator, ok := byID[strings.ToLower(position.ID)]
+		switch {
+		case err != nil:
+			position.Err = err
+			continue
+		case !ok:
+			position.Err = errors.New("validator is unknown to the beacon chain")
+			continue
+		}
+		position.ID = validator.Index
+		position.Status = validator.Status
+		position.Amount = new(big.Int).Mul(validator.Balance, gweiWei)
+		position.Ether = position.Amount
+	}
+}
+
+// readLST reads the balance of the token of the wallet and the ether it's worth at the rate of the token.
+func (e *Executor) readLST(ctx context.Context, stake *model.StakingSpec,
+	lst *model.LSTSpec, position *StakingPosition) error {
+	token := lst.TokenSpec()
+	balance, err := e.fundBalance(ctx, token, common.HexToAddress(stake.StakerWallet().Address))
+	if err != nil {
+		return fmt.Errorf("balance: %v", err)
+	}
+	position.Amount = balance
+	position.Rate = etherWei
+	if len(lst.Rate) > 0 {
+		rate, err := e.callUint(ctx, common.HexToAddress(token.Address), crypto.Keccak256([]byte(lst.Rate))[:4])
+		if err != nil {
+			return fmt.Errorf("%s: %v", lst.Rate, err)
+		}
+		position.Rate = rate
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(position.Decimals)), nil)
+	position.Ether = new(big.Int).Mul(balance, position.Rate)
+	position.Ether.Div(position.Ether, scale)
+	return nil
+}
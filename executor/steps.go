@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// StepControl lets the operator intervene in the target execution, like the interactive mode does.
+type StepControl interface {
+	// BeforeStep is called before each command of the target, it blocks while the run is paused
+	// and reports whether the command must be skipped.
+	BeforeStep(name string) bool
+	// RetryStep is called when the command has failed before sending any transaction,
+	// it reports whether to run it again.
+	RetryStep(name string, results []*CommandResult) bool
+	// ConfirmStep asks to approve the command requiring confirmation.
+	ConfirmStep(name, desc, nodeGroup string) bool
+}
+
+// SetStepControl sets the control of target steps, the commands run without pauses if it's nil.
+func (e *Executor) SetStepControl(steps StepControl) {
+	e.steps = steps
+}
+
+// runTargetStep runs the command of the target under the step control, which may skip it or retry if failed.
+// The command that has sent transactions of some wallets is never retried, since that would send them again.
+func (e *Executor) runTargetStep(ctx model.AppContext, targetName string,
+	position int, targetCmd model.TargetCommandSpec) ([]*CommandResult, bool) {
+	if e.steps == nil {
+		results, stop := e.runTargetCmd(ctx, targetName, position, targetCmd)
+		return results, e.mustStop(targetName, targetCmd.Name(), results, stop)
+	}
+	for {
+		if e.steps.BeforeStep(targetCmd.Name()) {
+			return []*CommandResult{{Skipped: "skipped by the operator"}}, false
+		}
+		results, stop := e.runTargetCmd(ctx, targetName, position, targetCmd)
+		if stop || hasErrors(results) {
+			if hasSentTxs(results) {
+				log.WithFields(log.Fields{
+					"target":  targetName,
+					"command": targetCmd.Name(),
+				}).Warningln("not offering a retry — the command has sent transactions, resume the run instead")
+			} else if e.steps.RetryStep(targetCmd.Name(), results) {
+				continue
+			}
+		}
+		return results, e.mustStop(targetName, targetCmd.Name(), results, stop)
+	}
+}
+
+// hasSentTxs checks whether any of the results holds a transaction sent.
+func hasSentTxs(results []*CommandResult) bool {
+	for _, result := range results {
+		if len(result.Txs) > 0 {
+			return true
+		} else if handle, ok := result.Result.(string); ok && strings.HasPrefix(handle, "tx:") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// StorageValue is the raw value of the contract storage slot.
+type StorageValue struct {
+	Address string `json:"address"`
+	Slot    string `json:"slot"`
+	Value   string `json:"value"`
+}
+
+// StorageRead reads the raw value of the storage slot using eth_getStorageAt.
+func (e *Executor) StorageRead(ctx context.Context, address common.Address,
+	slot common.Hash, at *model.BlockRef) *CommandResult {
+	block, err := e.blockParam(ctx, at)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	var value common.Hash
+	if err := e.ethRPC.CallContext(ctx, &value, "eth_getStorageAt", address, slot, block); err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to read storage: %v", err)}
+	}
+	return &CommandResult{
+		Result: &StorageValue{
+			Address: strings.ToLower(address.Hex()),
+			Slot:    slot.Hex(),
+			Value:   value.Hex(),
+		},
+	}
+}
+
+// storageWriteMethods are the dev node methods to set a storage slot, tried in order.
+var storageWriteMethods = []string{
+	"anvil_setStorageAt",
+	"hardhat_setStorageAt",
+}
+
+// StorageWrite overwrites the storage slot on dev nodes (Anvil or Hardhat),
+// so playbooks can fixture arbitrary contract state.
+func (e *Executor) StorageWrite(ctx context.Context, address common.Address, slot, value common.Hash) *CommandResult {
+	var err error
+	for _, method := range storageWriteMethods {
+		if err = e.ethRPC.CallContext(ctx, nil, method, address, slot, value); err == nil {
+			return &CommandResult{
+				Result: &StorageValue{
+					Address: strings.ToLower(address.Hex()),
+					Slot:    slot.Hex(),
+					Value:   value.Hex(),
+				},
+			}
+		}
+	}
+	return &CommandResult{Error: fmt.Errorf("failed to write storage, the node must be Anvil or Hardhat: %v", err)}
+}
@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"context"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// txPollInterval is the interval of checking a transaction, when the node doesn't support subscriptions.
+const txPollInterval = time.Second
+
+// txTicks returns a channel ticking when the transaction may have changed its status: on each new head
+// and when the transaction enters the pool of the node. Heads and pending transactions are subscribed
+// to over WebSocket and IPC connections, HTTP nodes are polled, as well as nodes dropping the
+// subscription. The ticks stop when the context is done.
+func (e *Executor) txTicks(ctx context.Context, hash common.Hash) <-chan struct{} {
+	ticks := make(chan struct{}, 1)
+	tick := func() {
+		select {
+		case ticks <- struct{}{}:
+		default:
+		}
+	}
+	heads := make(chan *types.Header, 16)
+	headSub, err := e.ethCli.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		log.WithError(err).Debugln("new heads subscription is not supported, polling the transaction")
+		go pollTicks(ctx, tick)
+		return ticks
+	}
+	pending := make(chan common.Hash, 256)
+	pendingSub, err := e.ethRPC.EthSubscribe(ctx, pending, "newPendingTransactions")
+	if err != nil {
+		// some nodes don't expose the pool, the heads are enough
+		log.WithError(err).Debugln("pending transactions subscription is not supported")
+	}
+	go func() {
+		defer headSub.Unsubscribe()
+		var pendingErr <-chan error
+		if pendingSub != nil {
+			defer pendingSub.Unsubscribe()
+			pendingErr = pendingSub.Err()
+		}
+		for {
+			select {
+			case <-heads:
+				tick()
+			case txHash := <-pending:
+				if txHash == hash {
+					tick()
+				}
+			case err := <-pendingErr:
+				log.WithError(err).Debugln("pending transactions subscription dropped")
+				pendingErr = nil
+			case err := <-headSub.Err():
+				log.WithError(err).Warningln("new heads subscription dropped, polling the transaction")
+				pollTicks(ctx, tick)
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ticks
+}
+
+func pollTicks(ctx context.Context, tick func()) {
+	t := time.NewTicker(txPollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			tick()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchLogs passes the logs of the query to fn as they are emitted, until the context is done. The logs
+// are subscribed to over WebSocket and IPC connections, a failed log is reported and skipped. HTTP nodes,
+// and nodes dropping the subscription, are polled on new blocks from the last block seen, the range
+// of a failed query or handling is queried again with the next block, so no log is missed.
+func (e *Executor) watchLogs(ctx model.AppContext, query ethereum.FilterQuery, fn func(chunk []types.Log) error) error {
+	var last uint64
+	if header, err := e.blockHeader(ctx, model.BlockTagLatest); err == nil {
+		last = header.Number.ToInt().Uint64()
+	}
+	logs := make(chan types.Log, 256)
+	sub, err := e.ethCli.SubscribeFilterLogs(ctx, query, logs)
+	if err != nil {
+		log.WithError(err).Debugln("logs subscription is not supported, polling the logs")
+		return e.pollLogs(ctx, query, last, fn)
+	}
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case entry := <-logs:
+			if entry.BlockNumber > last {
+				last = entry.BlockNumber
+				e.resetBlockRefs()
+			}
+			if err := fn([]types.Log{entry}); err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"block":    entry.BlockNumber,
+					"logIndex": entry.Index,
+				}).Warningln("failed to handle the log")
+			}
+		case err := <-sub.Err():
+			log.WithError(err).Warningln("logs subscription dropped, polling the logs")
+			return e.pollLogs(ctx, query, last, fn)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// pollLogs queries the logs of the blocks after last on each new block, starting with the next one if not set.
+func (e *Executor) pollLogs(ctx model.AppContext, query ethereum.FilterQuery,
+	last uint64, fn func(chunk []types.Log) error) error {
+	return e.WatchBlocks(ctx, 1, func(block uint64) {
+		if last == 0 {
+			last = block
+			return
+		} else if block <= last {
+			return
+		}
+		if err := e.scanLogsFunc(ctx, query, last+1, block, fn); err != nil {
+			log.WithError(err).WithField("block", block).Warningln("failed to query the logs, retrying with the next block")
+			return
+		}
+		last = block
+	})
+}
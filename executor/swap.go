@@ -0,0 +1,199 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// priceFeedMaxAge is the age of the price feed answer the swaps are not checked against.
+const priceFeedMaxAge = 24 * time.Hour
+
+var (
+	// latestRoundData() of Chainlink aggregators
+	latestRoundDataSelector = common.FromHex("0xfeaf968c")
+	// decimals()
+	decimalsSelector = common.FromHex("0x313ce567")
+)
+
+// SwapAsset is ether or a token of the swap, priced with the Chainlink feed.
+type SwapAsset struct {
+	Symbol    string
+	Address   common.Address
+	Decimals  int
+	PriceFeed string
+}
+
+// SwapResult is the quote of the swap, the least amount received with maxSlippage, its deviation
+// from the price feeds in percents, and the transactions sent, unless it's a quote only.
+type SwapResult struct {
+	Amount    *big.Int
+	Quote     *big.Int
+	Floor     *big.Int
+	Expected  *big.Int
+	Deviation float64
+	ApproveTx string
+	TxHash    string
+}
+
+// Swap quotes the swap of the amount with the aggregator, checks the quote against the price feeds
+// of the assets, unless skipOracle, and sends it from the wallet, approving the router first if the
+// allowance is not enough. The swap reverts if less than the quote minus maxSlippage is received,
+// so it's that floor which is checked against the price feeds.
+func (e *Executor) Swap(ctx model.AppContext, wallet *model.WalletSpec, src, dst *SwapAsset,
+	amount *big.Int, quoteOnly, skipOracle bool) (*SwapResult, error) {
+	api := e.root.Config.SwapAPI()
+	result := &SwapResult{
+		Amount: amount,
+	}
+	quote, err := api.Quote(ctx, src.Address, dst.Address, amount)
+	if err != nil {
+		return nil, err
+	}
+	result.Quote = quote
+	result.Floor = e.slippageFloor(quote)
+	if !skipOracle {
+		if result.Expected, err = e.oracleAmount(ctx, src, dst, amount); err != nil {
+			return nil, err
+		}
+		if err := e.checkDeviation(result, result.Floor); err != nil {
+			return result, err
+		}
+	}
+	if quoteOnly {
+		return result, nil
+	}
+	spender, err := api.Spender(ctx)
+	if err != nil {
+		return result, err
+	}
+	unlock := e.lockWallet(common.HexToAddress(wallet.Address))
+	defer unlock()
+	sender, err := e.newWalletSender(ctx, wallet)
+	if err != nil {
+		return result, err
+	}
+	awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
+	if src.Address != model.NativeAssetAddress {
+		if result.ApproveTx, err = e.approveSpender(ctx, sender, src.Address, spender, amount); err != nil {
+			return result, err
+		}
+	}
+	tx, dstAmount, err := api.Swap(ctx, src.Address, dst.Address, amount, sender.account, e.root.Config.MaxSlippage)
+	if err != nil {
+		return result, err
+	} else if tx.To != spender {
+		return result, fmt.Errorf("swap transaction is sent to %s, not to the router %s", tx.To.Hex(), spender.Hex())
+	}
+	result.Quote = dstAmount
+	result.Floor = e.slippageFloor(dstAmount)
+	if !skipOracle {
+		if err := e.checkDeviation(result, result.Floor); err != nil {
+			return result, err
+		}
+	}
+	var value *big.Int
+	if len(tx.Value) > 0 {
+		var ok bool
+		if value, ok = new(big.Int).SetString(tx.Value, 10); !ok {
+			return result, fmt.Errorf("swap API returned malformed value %q", tx.Value)
+		}
+	}
+	nonce, err := sender.client.PendingNonceAt(ctx, sender.account)
+	if err != nil {
+		return result, err
+	}
+	signedTx, err := e.signWalletTx(ctx, sender, nonce, tx.To, value, tx.Data)
+	if err != nil {
+		return result, err
+	} else if err := sender.client.SendTransaction(ctx, signedTx); err != nil {
+		return result, e.withRevertReason(ctx, err)
+	}
+	result.TxHash = strings.ToLower(signedTx.Hash().Hex())
+	awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+	defer cancelFn()
+	_, err = e.awaitTx(awaitCtx, "tx:"+result.TxHash)
+	return result, err
+}
+
+// checkDeviation sets the deviation of the amount from the oracle one, failing if it's worse than allowed.
+func (e *Executor) checkDeviation(result *SwapResult, amount *big.Int) error {
+	diff := new(big.Float).SetInt(new(big.Int).Sub(result.Expected, amount))
+	deviation, _ := new(big.Float).Quo(diff, new(big.Float).SetInt(result.Expected)).Float64()
+	result.Deviation = deviation * 100
+	if result.Deviation > e.root.Config.MaxPriceDeviation {
+		return fmt.Errorf("quote minus maxSlippage is %.2f%% below the price feeds, more than maxPriceDeviation of %v%%",
+			result.Deviation, e.root.Config.MaxPriceDeviation)
+	}
+	return nil
+}
+
+// slippageFloor is the least amount of the swap of the quote, with maxSlippage.
+func (e *Executor) slippageFloor(quote *big.Int) *big.Int {
+	keep := new(big.Float).SetFloat64(100 - e.root.Config.MaxSlippage)
+	floor, _ := new(big.Float).Quo(new(big.Float).Mul(new(big.Float).SetInt(quote), keep), big.NewFloat(100)).Int(nil)
+	return floor
+}
+
+// oracleAmount is the amount of dst the amount of src is worth by the price feeds of both.
+func (e *Executor) oracleAmount(ctx context.Context, src, dst *SwapAsset, amount *big.Int) (*big.Int, error) {
+	srcPrice, srcDecimals, err := e.feedPrice(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	dstPrice, dstDecimals, err := e.feedPrice(ctx, dst)
+	if err != nil {
+		return nil, err
+	}
+	// amount * srcPrice / dstPrice, scaled by the decimals of the assets and of the feeds
+	num := new(big.Int).Mul(amount, srcPrice)
+	num.Mul(num, pow10(dstDecimals+dst.Decimals))
+	den := new(big.Int).Mul(dstPrice, pow10(srcDecimals+src.Decimals))
+	return num.Div(num, den), nil
+}
+
+// feedPrice reads the latest answer of the price feed of the asset and its decimals.
+func (e *Executor) feedPrice(ctx context.Context, asset *SwapAsset) (*big.Int, int, error) {
+	if len(asset.PriceFeed) == 0 {
+		return nil, 0, fmt.Errorf("no price feed of %s, set priceFeed (nativePriceFeed for ether) or skip the check", asset.Symbol)
+	}
+	feed := common.HexToAddress(asset.PriceFeed)
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &feed,
+		Data: latestRoundDataSelector,
+	}, nil)
+	if err != nil {
+		return nil, 0, err
+	} else if len(output) != 5*32 {
+		return nil, 0, fmt.Errorf("price feed of %s: latestRoundData call reverted", asset.Symbol)
+	}
+	answer := new(big.Int).SetBytes(output[32:64])
+	if output[32]&0x80 != 0 || answer.Sign() == 0 {
+		return nil, 0, fmt.Errorf("price feed of %s answered a non-positive price", asset.Symbol)
+	}
+	updatedAt := time.Unix(new(big.Int).SetBytes(output[96:128]).Int64(), 0)
+	if age := time.Since(updatedAt); age > priceFeedMaxAge {
+		return nil, 0, fmt.Errorf("price feed of %s is stale, updated %s ago", asset.Symbol, age.Round(time.Minute))
+	}
+	output, err = e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &feed,
+		Data: decimalsSelector,
+	}, nil)
+	if err != nil {
+		return nil, 0, err
+	} else if len(output) != 32 || output[31] > 36 {
+		return nil, 0, fmt.Errorf("price feed of %s: decimals call reverted", asset.Symbol)
+	}
+	return answer, int(output[31]), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
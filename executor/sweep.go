@@ -0,0 +1,246 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// opStackChains are the chains charging the L1 data fee on top of the gas, by chainID.
+var opStackChains = map[string]bool{
+	"10":        true, // OP Mainnet
+	"8453":      true, // Base
+	"7777777":   true, // Zora
+	"34443":     true, // Mode
+	"11155420":  true, // OP Sepolia
+	"84532":     true, // Base Sepolia
+	"999999999": true, // Zora Sepolia
+}
+
+var (
+	// GasPriceOracle predeploy of the OP Stack
+	gasPriceOracle = common.HexToAddress("0x420000000000000000000000000000000000000F")
+	// getL1Fee(bytes)
+	getL1FeeSelector = common.FromHex("0x49948e0e")
+)
+
+// SweepAsset is an asset swept from the wallets, the balances up to the dust are left.
+type SweepAsset struct {
+	Symbol   string
+	Token    *model.TokenSpec
+	Decimals int
+	Dust     *big.Int
+}
+
+// SweepTransfer is the balance of the asset moved from the wallet, or the reason it's not.
+type SweepTransfer struct {
+	Wallet  string
+	Asset   *SweepAsset
+	Amount  *big.Int
+	TxHash  string
+	Skipped string
+	Error   error
+}
+
+// Sweep moves the balances of the assets above the dust from the wallets to the destination. The tokens
+// are moved first and awaited, then all ether that is left after paying the gas of the last transfer.
+func (e *Executor) Sweep(ctx model.AppContext, wallets []*model.WalletSpec, to common.Address,
+	assets []*SweepAsset, dryRun bool) []*SweepTransfer {
+	var transfers []*SweepTransfer
+	for _, wallet := range wallets {
+		transfers = append(transfers, e.sweepWallet(ctx, wallet, to, assets, dryRun)...)
+	}
+	return transfers
+}
+
+func (e *Executor) sweepWallet(ctx model.AppContext, wallet *model.WalletSpec, to common.Address,
+	assets []*SweepAsset, dryRun bool) []*SweepTransfer {
+	account := common.HexToAddress(wallet.Address)
+	name := e.root.Wallets.NameOf(wallet.Address)
+	unlock := e.lockWallet(account)
+	defer unlock()
+	var transfers []*SweepTransfer
+	fail := func(asset *SweepAsset, err error) []*SweepTransfer {
+		return append(transfers, &SweepTransfer{
+			Wallet: name,
+			Asset:  asset,
+			Error:  err,
+		})
+	}
+	sender, err := e.newWalletSender(ctx, wallet)
+	if err != nil {
+		return fail(nil, err)
+	}
+	var ether *SweepAsset
+	var sent []*SweepTransfer
+	// the fees of the token transfers not sent by the dry run
+	plannedFees := new(big.Int)
+	for _, asset := range assets {
+		if asset.Token == nil {
+			ether = asset
+			continue
+		}
+		token := common.HexToAddress(asset.Token.Address)
+		output, err := sender.client.CallContract(ctx, ethereum.CallMsg{
+			To:   &token,
+			Data: append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(account.Bytes(), 32)...),
+		}, nil)
+		if err == nil && len(output) != 32 {
+			err = errBalanceReverted
+		}
+		if err != nil {
+			transfers = fail(asset, err)
+			continue
+		}
+		transfer := &SweepTransfer{
+			Wallet: name,
+			Asset:  asset,
+			Amount: new(big.Int).SetBytes(output),
+		}
+		transfers = append(transfers, transfer)
+		if transfer.Amount.Cmp(asset.Dust) <= 0 {
+			transfer.Skipped = "dust"
+			continue
+		}
+		data := transferData(to, transfer.Amount)
+		if dryRun {
+			gasLimit, err := sender.client.EstimateGas(ctx, ethereum.CallMsg{
+				From:     account,
+				To:       &token,
+				GasPrice: sender.gasPrice,
+				Data:     data,
+			})
+			if err != nil {
+				transfer.Error = e.withRevertReason(ctx, err)
+				continue
+			}
+			plannedFees.Add(plannedFees, new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), sender.gasPrice))
+			continue
+		}
+		nonce, err := sender.client.PendingNonceAt(ctx, account)
+		if err != nil {
+			transfer.Error = err
+			continue
+		}
+		tx, err := e.signWalletTx(ctx, sender, nonce, token, nil, data)
+		if err == nil {
+			err = sender.client.SendTransaction(ctx, tx)
+		}
+		if err != nil {
+			transfer.Error = e.withRevertReason(ctx, err)
+			continue
+		}
+		transfer.TxHash = strings.ToLower(tx.Hash().Hex())
+		sent = append(sent, transfer)
+	}
+	awaitTimeout, _ := e.root.Config.AwaitTimeoutDuration()
+	for _, transfer := range sent {
+		awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+		_, err := e.awaitTx(awaitCtx, "tx:"+transfer.TxHash)
+		cancelFn()
+		transfer.Error = err
+	}
+	if ether == nil {
+		return transfers
+	}
+	transfer := &SweepTransfer{
+		Wallet: name,
+		Asset:  ether,
+	}
+	transfers = append(transfers, transfer)
+	balance, err := sender.client.BalanceAt(ctx, account, nil)
+	if err != nil {
+		transfer.Error = err
+		return transfers
+	}
+	balance.Sub(balance, plannedFees)
+	transfer.Amount = balance
+	tx, err := e.sweepEtherTx(ctx, sender, to, balance)
+	if err == errBelowFee {
+		transfer.Skipped = "below gas fee"
+		return transfers
+	} else if err != nil {
+		transfer.Error = err
+		return transfers
+	}
+	transfer.Amount = tx.Value()
+	if transfer.Amount.Cmp(ether.Dust) <= 0 {
+		transfer.Skipped = "dust"
+		return transfers
+	} else if dryRun {
+		return transfers
+	}
+	if err := sender.client.SendTransaction(ctx, tx); err != nil {
+		transfer.Error = e.withRevertReason(ctx, err)
+		return transfers
+	}
+	transfer.TxHash = strings.ToLower(tx.Hash().Hex())
+	awaitCtx, cancelFn := context.WithTimeout(ctx, awaitTimeout)
+	defer cancelFn()
+	_, transfer.Error = e.awaitTx(awaitCtx, "tx:"+transfer.TxHash)
+	return transfers
+}
+
+var errBelowFee = errors.New("balance doesn't cover the gas fee")
+
+// sweepEtherTx signs the transfer of the balance minus the fee, so nothing is left. The gas is estimated,
+// as it's not 21000 on some L2s, and the L1 data fee is subtracted on the OP Stack chains.
+func (e *Executor) sweepEtherTx(ctx context.Context, sender *walletSender,
+	to common.Address, balance *big.Int) (*types.Transaction, error) {
+	gasLimit, err := sender.client.EstimateGas(ctx, ethereum.CallMsg{
+		From:     sender.account,
+		To:       &to,
+		GasPrice: sender.gasPrice,
+	})
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := sender.client.PendingNonceAt(ctx, sender.account)
+	if err != nil {
+		return nil, err
+	}
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), sender.gasPrice)
+	if opStackChains[e.root.Config.ChainID] {
+		unsigned := types.NewTransaction(nonce, to, balance, gasLimit, sender.gasPrice, nil)
+		l1Fee, err := e.l1Fee(ctx, unsigned)
+		if err != nil {
+			return nil, err
+		}
+		// the L1 fee is charged at the L1 base fee of the inclusion, leave a margin of 10%
+		fee.Add(fee, l1Fee.Add(l1Fee, new(big.Int).Div(l1Fee, big.NewInt(10))))
+	}
+	if balance.Cmp(fee) <= 0 {
+		return nil, errBelowFee
+	}
+	value := new(big.Int).Sub(balance, fee)
+	tx := types.NewTransaction(nonce, to, value, gasLimit, sender.gasPrice, nil)
+	return types.SignTx(tx, sender.signer, sender.pk)
+}
+
+// l1Fee is the L1 data fee of the transaction on the OP Stack chains, from the GasPriceOracle.
+func (e *Executor) l1Fee(ctx context.Context, tx *types.Transaction) (*big.Int, error) {
+	encoded, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	data := append(append([]byte{}, getL1FeeSelector...), abiWord(32)...)
+	data = append(data, abiBytes(encoded)...)
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &gasPriceOracle,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, err
+	} else if len(output) != 32 {
+		return nil, errors.New("getL1Fee call reverted")
+	}
+	return new(big.Int).SetBytes(output), nil
+}
@@ -0,0 +1,183 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// TraceFrame is a call frame of the callTracer, with the calldata decoded using the spec ABIs.
+type TraceFrame struct {
+	Type     string        `json:"type"`
+	From     string        `json:"from"`
+	To       string        `json:"to,omitempty"`
+	Contract string        `json:"contract,omitempty"`
+	Label    string        `json:"label,omitempty"`
+	FromENS  string        `json:"fromENS,omitempty"`
+	ToENS    string        `json:"toENS,omitempty"`
+	Call     string        `json:"call"`
+	Value    string        `json:"value,omitempty"`
+	Gas      uint64        `json:"gas"`
+	GasUsed  uint64        `json:"gasUsed"`
+	Error    string        `json:"error,omitempty"`
+	Calls    []*TraceFrame `json:"calls,omitempty"`
+}
+
+type callFrame struct {
+	Type    string         `json:"type"`
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Value   *hexutil.Big   `json:"value"`
+	Gas     hexutil.Uint64 `json:"gas"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Input   hexutil.Bytes  `json:"input"`
+	Output  hexutil.Bytes  `json:"output"`
+	Error   string         `json:"error"`
+	Calls   []*callFrame   `json:"calls"`
+}
+
+// TraceCallMsg is the call to trace using debug_traceCall.
+type TraceCallMsg struct {
+	From  *common.Address `json:"from,omitempty"`
+	To    common.Address  `json:"to"`
+	Value *hexutil.Big    `json:"value,omitempty"`
+	Data  hexutil.Bytes   `json:"data,omitempty"`
+}
+
+var callTracerConfig = map[string]interface{}{
+	"tracer": "callTracer",
+}
+
+// TraceTransaction re-traces a mined transaction using debug_traceTransaction.
+func (e *Executor) TraceTransaction(ctx context.Context, txHash common.Hash) *CommandResult {
+	var frame *callFrame
+	if err := e.ethRPC.CallContext(ctx, &frame, "debug_traceTransaction", txHash, callTracerConfig); err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to trace transaction: %v", err)}
+	} else if frame == nil {
+		return &CommandResult{Error: errors.New("node returned no trace")}
+	}
+	return &CommandResult{Result: e.decodeFrame(ctx, frame)}
+}
+
+// TraceCall runs the call on top of the block state using debug_traceCall.
+func (e *Executor) TraceCall(ctx context.Context, msg *TraceCallMsg, at *model.BlockRef) *CommandResult {
+	block, err := e.blockParam(ctx, at)
+	if err != nil {
+		return &CommandResult{Error: err}
+	}
+	var frame *callFrame
+	if err := e.ethRPC.CallContext(ctx, &frame, "debug_traceCall", msg, block, callTracerConfig); err != nil {
+		return &CommandResult{Error: fmt.Errorf("failed to trace call: %v", err)}
+	} else if frame == nil {
+		return &CommandResult{Error: errors.New("node returned no trace")}
+	}
+	return &CommandResult{Result: e.decodeFrame(ctx, frame)}
+}
+
+func (e *Executor) decodeFrame(ctx context.Context, frame *callFrame) *TraceFrame {
+	result := &TraceFrame{
+		Type:    frame.Type,
+		From:    strings.ToLower(frame.From.Hex()),
+		Gas:     uint64(frame.Gas),
+		GasUsed: uint64(frame.GasUsed),
+		Error:   frame.Error,
+		FromENS: e.root.LookupENS(ctx, frame.From),
+		ToENS:   e.root.LookupENS(ctx, frame.To),
+	}
+	if frame.Value != nil && frame.Value.ToInt().Sign() > 0 {
+		result.Value = frame.Value.ToInt().String()
+	}
+	if frame.To != (common.Address{}) {
+		result.To = strings.ToLower(frame.To.Hex())
+		result.Label, _ = e.root.AddressLabel(frame.To)
+	}
+	switch {
+	case strings.HasPrefix(frame.Type, "CREATE"):
+		result.Call = "constructor"
+	case frame.Type == "SELFDESTRUCT":
+		result.Call = "selfdestruct"
+	case len(frame.Input) == 0:
+		result.Call = "receive()"
+	case len(frame.Input) < 4:
+		result.Call = "fallback()"
+	default:
+		calldata := &Calldata{To: result.To}
+		if err := e.decodeCalldata(ctx, calldata, frame.Input); err != nil {
+			result.Call = hexutil.Encode(frame.Input[:4])
+		} else {
+			result.Contract = calldata.Contract
+			result.Call = calldata.Call
+		}
+	}
+	if len(result.Contract) == 0 && len(result.To) > 0 {
+		result.Contract = e.root.Contracts.NameOf(result.To)
+	}
+	if len(frame.Error) > 0 {
+		if reason, ok := e.decodeRevert(ctx, frame.Output); ok {
+			result.Error = fmt.Sprintf("%s: %s", frame.Error, reason)
+		}
+	}
+	for _, call := range frame.Calls {
+		result.Calls = append(result.Calls, e.decodeFrame(ctx, call))
+	}
+	return result
+}
+
+// Text renders the call tree, consecutive identical sibling frames are collapsed into one line.
+func (f *TraceFrame) Text() string {
+	buf := new(bytes.Buffer)
+	f.writeText(buf, "", 1)
+	return buf.String()
+}
+
+func (f *TraceFrame) writeText(buf *bytes.Buffer, indent string, repeats int) {
+	target := f.To
+	if len(f.Contract) > 0 {
+		target = f.Contract
+	} else if len(f.Label) > 0 {
+		target = f.Label
+	} else if len(f.ToENS) > 0 {
+		target = f.ToENS
+	}
+	fmt.Fprintf(buf, "%s%s %s.%s", indent, f.Type, target, f.Call)
+	if len(f.Value) > 0 {
+		fmt.Fprintf(buf, " value=%s", f.Value)
+	}
+	fmt.Fprintf(buf, " gas=%d/%d", f.GasUsed, f.Gas)
+	if repeats > 1 {
+		fmt.Fprintf(buf, " (x%d)", repeats)
+	}
+	if len(f.Error) > 0 {
+		fmt.Fprintf(buf, " ERROR: %s", f.Error)
+	}
+	buf.WriteString("\n")
+	for i := 0; i < len(f.Calls); {
+		n := 1
+		for i+n < len(f.Calls) && f.Calls[i+n].sameAs(f.Calls[i]) {
+			n++
+		}
+		f.Calls[i].writeText(buf, indent+"  ", n)
+		i += n
+	}
+}
+
+// sameAs reports whether the frames are identical calls, ignoring gas.
+func (f *TraceFrame) sameAs(other *TraceFrame) bool {
+	if f.Type != other.Type || f.To != other.To || f.Call != other.Call ||
+		f.Value != other.Value || f.Error != other.Error || len(f.Calls) != len(other.Calls) {
+		return false
+	}
+	for i := range f.Calls {
+		if !f.Calls[i].sameAs(other.Calls[i]) {
+			return false
+		}
+	}
+	return true
+}
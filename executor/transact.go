@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// deployContract deploys the instance from the wallet of the client with the constructor args, the constructor
+// with struct params is packed by the instance, since the binding can't pack it. The instance stays unbound.
+func deployContract(client *ethclient.Client, opts *bind.TransactOpts,
+	instance *model.ContractInstanceSpec, params []interface{}) (common.Address, *types.Transaction, error) {
+	binding := *instance.BoundContract()
+	binding.SetClient(client)
+	if !instance.HasTupleParams("") {
+		return binding.DeployContract(opts, params...)
+	}
+	input, err := instance.Pack("", params...)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	tx, err := transactInput(client, opts, nil, append(common.FromHex(binding.Source().Bin), input...))
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return crypto.CreateAddress(opts.From, tx.Nonce()), tx, nil
+}
+
+// transactMethod calls the method of the instance from the wallet of the client, the methods
+// with struct params are packed by the instance.
+func transactMethod(client *ethclient.Client, opts *bind.TransactOpts,
+	instance *model.ContractInstanceSpec, method string, params []interface{}) (*types.Transaction, error) {
+	binding := *instance.BoundContract()
+	binding.SetClient(client)
+	if !instance.HasTupleParams(method) {
+		return binding.Transact(opts, method, params...)
+	}
+	input, err := instance.Pack(method, params...)
+	if err != nil {
+		return nil, err
+	}
+	to := binding.Address()
+	return transactInput(client, opts, &to, input)
+}
+
+// transactInput sends the transaction with the packed input like the bindings do, creating
+// a contract if to is nil.
+func transactInput(client *ethclient.Client, opts *bind.TransactOpts,
+	to *common.Address, input []byte) (*types.Transaction, error) {
+	if opts.Signer == nil {
+		return nil, errors.New("no signer to authorize the transaction with")
+	}
+	ctx := opts.Context
+	nonce, err := client.PendingNonceAt(ctx, opts.From)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve account nonce: %v", err)
+	}
+	value := opts.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	gasPrice := opts.GasPrice
+	if gasPrice == nil {
+		if gasPrice, err = client.SuggestGasPrice(ctx); err != nil {
+			return nil, fmt.Errorf("failed to suggest gas price: %v", err)
+		}
+	}
+	gasLimit := opts.GasLimit
+	if gasLimit == 0 {
+		msg := ethereum.CallMsg{
+			From:     opts.From,
+			To:       to,
+			GasPrice: gasPrice,
+			Value:    value,
+			Data:     input,
+		}
+		if gasLimit, err = client.EstimateGas(ctx, msg); err != nil {
+			return nil, fmt.Errorf("failed to estimate gas needed: %v", err)
+		}
+	}
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(nonce, value, gasLimit, gasPrice, input)
+	} else {
+		tx = types.NewTransaction(nonce, *to, value, gasLimit, gasPrice, input)
+	}
+	signedTx, err := opts.Signer(types.HomesteadSigner{}, opts.From, tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.SendTransaction(ctx, signedTx); err != nil {
+		return nil, err
+	}
+	return signedTx, nil
+}
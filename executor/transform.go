@@ -0,0 +1,195 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// finishView applies the result transforms of the view command and asserts the expectations.
+func (e *Executor) finishView(cmdSpec *model.ViewCmdSpec, results []*CommandResult) []*CommandResult {
+	if transforms := cmdSpec.Transforms(); len(transforms) > 0 {
+		for _, result := range results {
+			if result.Error != nil {
+				continue
+			}
+			value, err := e.transform(transforms, result.Result)
+			if err != nil {
+				result.Error = fmt.Errorf("failed to transform result: %v", err)
+				continue
+			}
+			result.Result = value
+		}
+	}
+	return checkExpect(cmdSpec, results)
+}
+
+func (e *Executor) transform(steps []*model.TransformStep, value interface{}) (interface{}, error) {
+	for i, step := range steps {
+		var err error
+		switch {
+		case step.Op.IsArithmetic():
+			value, err = e.transformArithmetic(step, value)
+		case step.Op == model.TransformHex:
+			value, err = transformHex(value)
+		case step.Op == model.TransformChecksum:
+			value, err = transformChecksum(value)
+		case step.Op == model.TransformSlice:
+			value, err = transformSlice(step, value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %v", i, step.Op, err)
+		}
+	}
+	if r, ok := value.(*big.Rat); ok {
+		return ratValue(r, steps), nil
+	}
+	return value, nil
+}
+
+func (e *Executor) transformArithmetic(step *model.TransformStep, value interface{}) (interface{}, error) {
+	x, ok := ratOf(value)
+	if !ok {
+		return nil, fmt.Errorf("value is not a number: %v", value)
+	}
+	y := step.Number
+	if step.Ref != nil {
+		output, err := e.commandOutput(step.Ref)
+		if err != nil {
+			return nil, err
+		}
+		if y, ok = ratOf(output); !ok {
+			return nil, fmt.Errorf("output of %s is not a number", step.Ref.CmdName)
+		}
+	}
+	switch step.Op {
+	case model.TransformDiv:
+		if y.Sign() == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return new(big.Rat).Quo(x, y), nil
+	case model.TransformMul:
+		return new(big.Rat).Mul(x, y), nil
+	case model.TransformAdd:
+		return new(big.Rat).Add(x, y), nil
+	default:
+		return new(big.Rat).Sub(x, y), nil
+	}
+}
+
+func ratOf(v interface{}) (*big.Rat, bool) {
+	switch vv := v.(type) {
+	case *big.Rat:
+		return vv, true
+	case *big.Int:
+		return new(big.Rat).SetInt(vv), true
+	case string:
+		return new(big.Rat).SetString(vv)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(rv.Uint())), true
+	}
+	return nil, false
+}
+
+// ratValue converts the arithmetic result into an integer, or a decimal string with
+// as many fractional digits as needed for the divisors used, trailing zeros trimmed.
+func ratValue(r *big.Rat, steps []*model.TransformStep) interface{} {
+	if r.IsInt() {
+		return new(big.Int).Set(r.Num())
+	}
+	prec := 18
+	for _, step := range steps {
+		if step.Op == model.TransformDiv && step.Number != nil && step.Number.IsInt() {
+			if digits := len(step.Number.Num().String()) - 1; digits > prec {
+				prec = digits
+			}
+		}
+	}
+	str := r.FloatString(prec)
+	str = strings.TrimRight(str, "0")
+	return strings.TrimSuffix(str, ".")
+}
+
+func transformHex(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []byte:
+		return hexutil.Encode(v), nil
+	case string:
+		return hexutil.Encode([]byte(v)), nil
+	case common.Address:
+		return strings.ToLower(v.Hex()), nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		data := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(data), rv)
+		return hexutil.Encode(data), nil
+	}
+	r, ok := ratOf(value)
+	if !ok || !r.IsInt() || r.Sign() < 0 {
+		return nil, fmt.Errorf("value can't be converted to hex: %v", value)
+	}
+	return hexutil.EncodeBig(r.Num()), nil
+}
+
+func transformChecksum(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case common.Address:
+		return v.Hex(), nil
+	case string:
+		if common.IsHexAddress(v) {
+			return common.HexToAddress(v).Hex(), nil
+		}
+	case []common.Address:
+		list := make([]interface{}, len(v))
+		for i, addr := range v {
+			list[i] = addr.Hex()
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("value is not an address: %v", value)
+}
+
+func transformSlice(step *model.TransformStep, value interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.String:
+	case reflect.Array:
+		slice := reflect.MakeSlice(reflect.SliceOf(rv.Type().Elem()), rv.Len(), rv.Len())
+		reflect.Copy(slice, rv)
+		rv = slice
+	default:
+		return nil, fmt.Errorf("value can't be sliced: %T", value)
+	}
+	bound := func(i int) int {
+		if i < 0 {
+			i += rv.Len()
+		}
+		if i < 0 {
+			return 0
+		} else if i > rv.Len() {
+			return rv.Len()
+		}
+		return i
+	}
+	start, end := bound(step.Start), rv.Len()
+	if step.HasEnd {
+		end = bound(step.End)
+	}
+	if start > end {
+		start = end
+	}
+	return rv.Slice(start, end).Interface(), nil
+}
@@ -0,0 +1,209 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+var (
+	// VestingWallet of OpenZeppelin, the token variants take the token address
+	vestingStartSelector         = crypto.Keccak256([]byte("start()"))[:4]
+	vestingDurationSelector      = crypto.Keccak256([]byte("duration()"))[:4]
+	vestingCliffSelector         = crypto.Keccak256([]byte("cliff()"))[:4]
+	vestingReleasedSelector      = crypto.Keccak256([]byte("released()"))[:4]
+	vestingReleasedTokenSelector = crypto.Keccak256([]byte("released(address)"))[:4]
+	vestingVestedSelector        = crypto.Keccak256([]byte("vestedAmount(uint64)"))[:4]
+	vestingVestedTokenSelector   = crypto.Keccak256([]byte("vestedAmount(address,uint64)"))[:4]
+	vestingReleaseSelector       = crypto.Keccak256([]byte("release()"))[:4]
+	vestingReleaseTokenSelector  = crypto.Keccak256([]byte("release(address)"))[:4]
+	// lockup streams of Sablier, by the stream ID
+	sablierStartSelector        = crypto.Keccak256([]byte("getStartTime(uint256)"))[:4]
+	sablierCliffSelector        = crypto.Keccak256([]byte("getCliffTime(uint256)"))[:4]
+	sablierEndSelector          = crypto.Keccak256([]byte("getEndTime(uint256)"))[:4]
+	sablierAssetSelector        = crypto.Keccak256([]byte("getAsset(uint256)"))[:4]
+	sablierDepositedSelector    = crypto.Keccak256([]byte("getDepositedAmount(uint256)"))[:4]
+	sablierWithdrawnSelector    = crypto.Keccak256([]byte("getWithdrawnAmount(uint256)"))[:4]
+	sablierWithdrawableSelector = crypto.Keccak256([]byte("withdrawableAmountOf(uint256)"))[:4]
+	sablierWithdrawMaxSelector  = crypto.Keccak256([]byte("withdrawMax(uint256,address)"))[:4]
+)
+
+// VestingSchedule is the state of a vesting schedule: the amounts in the smallest units of the asset,
+// Total is all of it, vested or not, Released has been claimed and Releasable can be claimed now.
+type VestingSchedule struct {
+	Name     string
+	Kind     string
+	Wallet   string
+	Asset    string
+	Decimals int
+	// Start, Cliff and End are the times of the schedule, nothing is vested before the cliff.
+	Start      time.Time
+	Cliff      time.Time
+	End        time.Time
+	Total      *big.Int
+	Released   *big.Int
+	Releasable *big.Int
+}
+
+// Locked is the amount not vested yet.
+func (schedule *VestingSchedule) Locked() *big.Int {
+	locked := new(big.Int).Sub(schedule.Total, schedule.Released)
+	locked.Sub(locked, schedule.Releasable)
+	if locked.Sign() < 0 {
+		return new(big.Int)
+	}
+	return locked
+}
+
+// NextUnlock is the time the next amount is unlocked at, with the amount: the cliff and the amount
+// vested at the cliff, or now and nil while the amounts are streamed. It's zero once fully vested.
+func (schedule *VestingSchedule) NextUnlock(now time.Time) (time.Time, *big.Int) {
+	switch {
+	case !now.Before(schedule.End):
+		return time.Time{}, nil
+	case now.Before(schedule.Cliff):
+		amount := new(big.Int).Set(schedule.Total)
+		if span := schedule.End.Sub(schedule.Start); span > 0 {
+			amount.Mul(amount, big.NewInt(int64(schedule.Cliff.Sub(schedule.Start)/time.Second)))
+			amount.Div(amount, big.NewInt(int64(span/time.Second)))
+		}
+		return schedule.Cliff, amount
+	}
+	return now, nil
+}
+
+// Vesting reads the state of the vesting schedule from its contract.
+func (e *Executor) Vesting(ctx context.Context, name string, vesting *model.VestingSpec) (*VestingSchedule, error) {
+	schedule := &VestingSchedule{
+		Name:     name,
+		Kind:     vesting.Kind,
+		Wallet:   e.root.Wallets.NameOf(vesting.BeneficiaryWallet().Address),
+		Asset:    vesting.Asset(),
+		Decimals: vesting.Decimals(),
+	}
+	contract := common.HexToAddress(vesting.Address)
+	if vesting.Kind == model.VestingSablier {
+		return schedule, e.readSablierStream(ctx, contract, vesting, schedule)
+	}
+	return schedule, e.readVestingWallet(ctx, contract, vesting, schedule)
+}
+
+func (e *Executor) readVestingWallet(ctx context.Context, contract common.Address,
+	vesting *model.VestingSpec, schedule *VestingSchedule) error {
+	start, err := e.callUint(ctx, contract, vestingStartSelector)
+	if err != nil {
+		return fmt.Errorf("start: %v", err)
+	}
+	duration, err := e.callUint(ctx, contract, vestingDurationSelector)
+	if err != nil {
+		return fmt.Errorf("duration: %v", err)
+	}
+	schedule.Start = time.Unix(start.Int64(), 0).UTC()
+	schedule.End = schedule.Start.Add(time.Duration(duration.Int64()) * time.Second)
+	// only VestingWalletCliff has a cliff, the others vest from the start
+	schedule.Cliff = schedule.Start
+	if cliff, err := e.callUint(ctx, contract, vestingCliffSelector); err == nil {
+		schedule.Cliff = time.Unix(cliff.Int64(), 0).UTC()
+	}
+	now := common.LeftPadBytes(big.NewInt(time.Now().Unix()).Bytes(), 32)
+	releasedData, vestedData := vestingReleasedSelector, append(append([]byte{}, vestingVestedSelector...), now...)
+	if token := vesting.TokenSpec(); token != nil {
+		tokenWord := common.LeftPadBytes(common.HexToAddress(token.Address).Bytes(), 32)
+		releasedData = append(append([]byte{}, vestingReleasedTokenSelector...), tokenWord...)
+		vestedData = append(append(append([]byte{}, vestingVestedTokenSelector...), tokenWord...), now...)
+	}
+	if schedule.Released, err = e.callUint(ctx, contract, releasedData); err != nil {
+		return fmt.Errorf("released: %v", err)
+	}
+	vested, err := e.callUint(ctx, contract, vestedData)
+	if err != nil {
+		return fmt.Errorf("vestedAmount: %v", err)
+	}
+	schedule.Releasable = new(big.Int).Sub(vested, schedule.Released)
+	if schedule.Releasable.Sign() < 0 {
+		schedule.Releasable.SetInt64(0)
+	}
+	// the wallet holds all of the amounts not released yet
+	balance, err := e.fundBalance(ctx, vesting.TokenSpec(), contract)
+	if err != nil {
+		return fmt.Errorf("balance: %v", err)
+	}
+	schedule.Total = new(big.Int).Add(balance, schedule.Released)
+	return nil
+}
+
+func (e *Executor) readSablierStream(ctx context.Context, contract common.Address,
+	vesting *model.VestingSpec, schedule *VestingSchedule) error {
+	streamID := common.LeftPadBytes(new(big.Int).SetUint64(vesting.StreamID).Bytes(), 32)
+	call := func(selector []byte) (*big.Int, error) {
+		return e.callUint(ctx, contract, append(append([]byte{}, selector...), streamID...))
+	}
+	asset, err := call(sablierAssetSelector)
+	if err != nil {
+		return fmt.Errorf("stream %d: %v", vesting.StreamID, err)
+	} else if common.BigToAddress(asset) != common.HexToAddress(vesting.TokenSpec().Address) {
+		return fmt.Errorf("stream %d streams %s, not %s", vesting.StreamID, common.BigToAddress(asset).Hex(), vesting.Asset())
+	}
+	times := make([]time.Time, 3)
+	for i, selector := range [][]byte{sablierStartSelector, sablierCliffSelector, sablierEndSelector} {
+		value, err := call(selector)
+		if err != nil {
+			return fmt.Errorf("stream %d times: %v", vesting.StreamID, err)
+		}
+		times[i] = time.Unix(value.Int64(), 0).UTC()
+	}
+	schedule.Start, schedule.Cliff, schedule.End = times[0], times[1], times[2]
+	if schedule.Cliff.Unix() == 0 {
+		schedule.Cliff = schedule.Start
+	}
+	if schedule.Total, err = call(sablierDepositedSelector); err != nil {
+		return fmt.Errorf("stream %d deposited amount: %v", vesting.StreamID, err)
+	} else if schedule.Released, err = call(sablierWithdrawnSelector); err != nil {
+		return fmt.Errorf("stream %d withdrawn amount: %v", vesting.StreamID, err)
+	} else if schedule.Releasable, err = call(sablierWithdrawableSelector); err != nil {
+		return fmt.Errorf("stream %d withdrawable amount: %v", vesting.StreamID, err)
+	}
+	return nil
+}
+
+// callUint calls the view of the contract returning a single word.
+func (e *Executor) callUint(ctx context.Context, contract common.Address, data []byte) (*big.Int, error) {
+	output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+		To:   &contract,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, err
+	} else if len(output) != 32 {
+		return nil, errors.New("call reverted or returned no value")
+	}
+	return new(big.Int).SetBytes(output), nil
+}
+
+// Release claims the releasable amount of the schedule to its wallet, sent by the wallet, and awaits
+// the transaction: the release of the vesting wallet, or the max withdrawal of the stream.
+func (e *Executor) Release(ctx model.AppContext, vesting *model.VestingSpec) (string, error) {
+	wallet := vesting.BeneficiaryWallet()
+	contract := common.HexToAddress(vesting.Address)
+	var data []byte
+	switch {
+	case vesting.Kind == model.VestingSablier:
+		data = append(append([]byte{}, sablierWithdrawMaxSelector...),
+			common.LeftPadBytes(new(big.Int).SetUint64(vesting.StreamID).Bytes(), 32)...)
+		data = append(data, common.LeftPadBytes(common.HexToAddress(wallet.Address).Bytes(), 32)...)
+	case vesting.TokenSpec() != nil:
+		data = append(append([]byte{}, vestingReleaseTokenSelector...),
+			common.LeftPadBytes(common.HexToAddress(vesting.TokenSpec().Address).Bytes(), 32)...)
+	default:
+		data = vestingReleaseSelector
+	}
+	return e.sendWalletTx(ctx, wallet, contract, nil, data)
+}
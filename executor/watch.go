@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// watchPollInterval is the interval of polling the block number, when the node doesn't support subscriptions.
+const watchPollInterval = 2 * time.Second
+
+// WatchBlocks calls fn with the number of each new block, or of every nth one, until the context is done.
+// New heads are subscribed to over WebSocket and IPC connections, HTTP nodes are polled. The block
+// references resolved by the previous call are dropped, so tags like latest are resolved again.
+func (e *Executor) WatchBlocks(ctx model.AppContext, every uint64, fn func(block uint64)) error {
+	if every == 0 {
+		every = 1
+	}
+	var last uint64
+	handle := func(block uint64) {
+		if last > 0 && block < last+every {
+			return
+		}
+		last = block
+		e.resetBlockRefs()
+		e.notify(ctx, &model.Notification{
+			Type:  model.NotifyBlock,
+			Block: block,
+		})
+		fn(block)
+	}
+	heads := make(chan *types.Header, 16)
+	sub, err := e.ethCli.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		log.WithError(err).Debugln("new heads subscription is not supported, polling the block number")
+		return e.pollBlocks(ctx, handle)
+	}
+	defer sub.Unsubscribe()
+	if header, err := e.blockHeader(ctx, model.BlockTagLatest); err == nil {
+		handle(header.Number.ToInt().Uint64())
+	}
+	for {
+		select {
+		case header := <-heads:
+			handle(header.Number.Uint64())
+		case err := <-sub.Err():
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// resetBlockRefs drops the resolved block references, so tags like latest are resolved again.
+func (e *Executor) resetBlockRefs() {
+	e.blocksMux.Lock()
+	e.blocks = make(map[string]string)
+	e.blocksMux.Unlock()
+}
+
+func (e *Executor) pollBlocks(ctx model.AppContext, handle func(block uint64)) error {
+	var last uint64
+	t := time.NewTicker(watchPollInterval)
+	defer t.Stop()
+	for {
+		header, err := e.blockHeader(ctx, model.BlockTagLatest)
+		if err != nil {
+			log.WithError(err).Warningln("failed to get the latest block")
+		} else if number := header.Number.ToInt().Uint64(); number > last {
+			last = number
+			handle(number)
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"errors"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+var (
+	// deposit() of WETH9
+	depositSelector = common.FromHex("0xd0e30db0")
+	// withdraw(uint256) of WETH9
+	withdrawSelector = common.FromHex("0x2e1a7d4d")
+)
+
+// Wrap deposits the amount of ether into the wrapped native token of the chain and awaits the transaction.
+func (e *Executor) Wrap(ctx model.AppContext, wallet *model.WalletSpec, amount *big.Int) (string, error) {
+	weth, err := e.root.Config.WrappedNative()
+	if err != nil {
+		return "", err
+	}
+	return e.sendWalletTx(ctx, wallet, weth, amount, depositSelector)
+}
+
+// Unwrap withdraws the amount of the wrapped native token, the whole balance if it's nil,
+// and awaits the transaction.
+func (e *Executor) Unwrap(ctx model.AppContext, wallet *model.WalletSpec, amount *big.Int) (string, error) {
+	weth, err := e.root.Config.WrappedNative()
+	if err != nil {
+		return "", err
+	}
+	if amount == nil {
+		output, err := e.ethCli.CallContract(ctx, ethereum.CallMsg{
+			To:   &weth,
+			Data: append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(common.HexToAddress(wallet.Address).Bytes(), 32)...),
+		}, nil)
+		if err != nil {
+			return "", err
+		} else if len(output) != 32 {
+			return "", errBalanceReverted
+		}
+		if amount = new(big.Int).SetBytes(output); amount.Sign() == 0 {
+			return "", errors.New("nothing to unwrap")
+		}
+	}
+	data := append(append([]byte{}, withdrawSelector...), common.LeftPadBytes(amount.Bytes(), 32)...)
+	return e.sendWalletTx(ctx, wallet, weth, nil, data)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+)
+
+// Exit codes of the tool, so CI pipelines can branch on the kind of failure.
+const (
+	exitOK = 0
+	// exitFailed is any failure not classified below, including failed expectations.
+	exitFailed = 1
+	// exitValidation means the spec or the command args are invalid.
+	exitValidation = 2
+	// exitSimulation means a call or a gas estimation reverted, the transaction hasn't been sent.
+	exitSimulation = 3
+	// exitReverted means a transaction has been mined with a failing status.
+	exitReverted = 4
+	// exitInsufficientFunds means a wallet can't pay for the gas and value of a transaction.
+	exitInsufficientFunds = 5
+	// exitUnavailable means the node can't be reached.
+	exitUnavailable = 6
+	// exitPartial means the run has kept going after failures, and some of the commands succeeded.
+	exitPartial = 7
+)
+
+var failureExitCodes = map[executor.FailureKind]int{
+	executor.FailureOther:             exitFailed,
+	executor.FailureSimulation:        exitSimulation,
+	executor.FailureReverted:          exitReverted,
+	executor.FailureInsufficientFunds: exitInsufficientFunds,
+	executor.FailureUnavailable:       exitUnavailable,
+}
+
+// exitCode returns the exit code of the run: zero if no command has failed, the code of
+// the first failure, or exitPartial if the run has kept going and some commands succeeded.
+func exitCode(results [][]*executor.CommandResult, keepGoing bool) int {
+	var (
+		code      int
+		succeeded bool
+	)
+	for _, cmdResults := range results {
+		if !hasResultErrors(cmdResults) {
+			succeeded = succeeded || !isSkipped(cmdResults)
+			continue
+		}
+		for _, result := range cmdResults {
+			if result.Error != nil && code == exitOK {
+				code = failureExitCodes[executor.ClassifyFailure(result.Error)]
+			}
+		}
+	}
+	if code != exitOK && keepGoing && succeeded {
+		return exitPartial
+	}
+	return code
+}
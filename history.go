@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/csv"
+	"strconv"
+	"time"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+)
+
+// HistoryRecord is the transfer of the history command in the machine-readable output formats.
+type HistoryRecord struct {
+	Time             string `json:"time" yaml:"time"`
+	Block            uint64 `json:"block" yaml:"block"`
+	TxHash           string `json:"txHash" yaml:"txHash"`
+	Wallet           string `json:"wallet" yaml:"wallet"`
+	Direction        string `json:"direction" yaml:"direction"`
+	Counterparty     string `json:"counterparty" yaml:"counterparty"`
+	CounterpartyName string `json:"counterpartyName,omitempty" yaml:"counterpartyName,omitempty"`
+	Asset            string `json:"asset" yaml:"asset"`
+	Amount           string `json:"amount" yaml:"amount"`
+	Fee              string `json:"fee,omitempty" yaml:"fee,omitempty"`
+	Method           string `json:"method,omitempty" yaml:"method,omitempty"`
+	Failed           bool   `json:"failed,omitempty" yaml:"failed,omitempty"`
+	// Value and FeeValue are the amount and the fee in the fiat Currency, at the prices of the day.
+	Currency string `json:"currency,omitempty" yaml:"currency,omitempty"`
+	Value    string `json:"value,omitempty" yaml:"value,omitempty"`
+	FeeValue string `json:"feeValue,omitempty" yaml:"feeValue,omitempty"`
+}
+
+var historyColumns = []string{
+	"time", "block", "txHash", "wallet", "direction", "counterparty", "counterpartyName",
+	"asset", "amount", "fee", "method", "status",
+}
+
+// writeHistory writes the transfers to the output file, or to stdout if not set, as CSV
+// for the accounting tools, unless the output format is JSON or YAML. With the currency,
+// the transfers have the currency, value and feeValue columns.
+func writeHistory(format, path string, entries []*executor.HistoryEntry, currency string) error {
+	w, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	records := make([]*HistoryRecord, 0, len(entries))
+	for _, entry := range entries {
+		record := &HistoryRecord{
+			Time:             entry.Time.Format(time.RFC3339),
+			Block:            entry.Block,
+			TxHash:           entry.TxHash,
+			Wallet:           entry.Wallet,
+			Direction:        entry.Direction,
+			Counterparty:     entry.Counterparty,
+			CounterpartyName: entry.CounterpartyName,
+			Asset:            entry.Asset,
+			Amount:           entry.Amount,
+			Fee:              entry.Fee,
+			Method:           entry.Method,
+			Failed:           entry.Failed,
+		}
+		if len(currency) > 0 {
+			record.Currency = currency
+			record.Value = entry.Value
+			record.FeeValue = entry.FeeValue
+		}
+		records = append(records, record)
+	}
+	if format == OutputJSON || format == OutputYAML {
+		return writeStructured(w, format, records)
+	}
+	csvWriter := csv.NewWriter(w)
+	columns := historyColumns
+	if len(currency) > 0 {
+		columns = append(columns, "currency", "value", "feeValue")
+	}
+	if err := csvWriter.Write(columns); err != nil {
+		return err
+	}
+	for _, record := range records {
+		status := "ok"
+		if record.Failed {
+			status = "failed"
+		}
+		row := []string{
+			record.Time,
+			strconv.FormatUint(record.Block, 10),
+			record.TxHash,
+			record.Wallet,
+			record.Direction,
+			record.Counterparty,
+			record.CounterpartyName,
+			record.Asset,
+			record.Amount,
+			record.Fee,
+			record.Method,
+			status,
+		}
+		if len(currency) > 0 {
+			row = append(row, record.Currency, record.Value, record.FeeValue)
+		}
+		if err := csvWriter.Write(row); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+const (
+	stepPending = "pending"
+	stepRunning = "running"
+	stepDone    = "done"
+	stepFailed  = "failed"
+	stepSkipped = "skipped"
+)
+
+// interactiveLogLines is the number of the latest log lines shown under the steps.
+const interactiveLogLines = 8
+
+type runStep struct {
+	Name    string
+	Status  string
+	GasUsed uint64
+	Txs     []string
+	Error   string
+
+	// skip is set by the operator for a pending step.
+	skip bool
+}
+
+// interactiveRun is the terminal UI of a target run, it shows the live status of the commands,
+// the gas spent and the transactions sent. The operator can pause the run, mark pending steps
+// to skip, and retry the failed ones. It implements executor.StepControl.
+type interactiveRun struct {
+	spec      *model.Spec
+	target    string
+	nodeGroup string
+
+	mux      *sync.Mutex
+	resumed  *sync.Cond
+	steps    []*runStep
+	selected int
+	paused   bool
+	logs     []string
+
+	promptMux *sync.Mutex
+	prompt    string
+	answers   string
+	answerC   chan byte
+
+	fd       int
+	oldState *terminal.State
+}
+
+func newInteractiveRun(spec *model.Spec, target, nodeGroup string) (*interactiveRun, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, fmt.Errorf("interactive mode requires a terminal")
+	}
+	run := &interactiveRun{
+		spec:      spec,
+		target:    target,
+		nodeGroup: nodeGroup,
+		mux:       new(sync.Mutex),
+		promptMux: new(sync.Mutex),
+		answerC:   make(chan byte),
+		fd:        fd,
+	}
+	run.resumed = sync.NewCond(run.mux)
+	var names []string
+	targetSpec, _ := spec.Targets.TargetSpec(target)
+	if hooks := spec.Hooks[target]; hooks != nil {
+		names = append(names, hooks.Before...)
+		names = append(names, targetSpec.CmdNames()...)
+		names = append(names, hooks.After...)
+	} else {
+		names = targetSpec.CmdNames()
+	}
+	for _, name := range names {
+		run.steps = append(run.steps, &runStep{
+			Name:   name,
+			Status: stepPending,
+		})
+	}
+	return run, nil
+}
+
+// Start switches the terminal to raw mode and starts handling the keys,
+// the log output is captured to be shown under the steps.
+func (r *interactiveRun) Start() error {
+	oldState, err := terminal.MakeRaw(r.fd)
+	if err != nil {
+		return err
+	}
+	r.oldState = oldState
+	log.SetOutput(r)
+	go r.readKeys()
+	r.render()
+	return nil
+}
+
+// Stop restores the terminal and the log output.
+func (r *interactiveRun) Stop() {
+	log.SetOutput(os.Stderr)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.renderLocked()
+	if r.oldState != nil {
+		terminal.Restore(r.fd, r.oldState)
+		r.oldState = nil
+	}
+	fmt.Println()
+}
+
+// Write captures the log output.
+func (r *interactiveRun) Write(p []byte) (int, error) {
+	r.mux.Lock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		r.logs = append(r.logs, line)
+	}
+	if len(r.logs) > interactiveLogLines {
+		r.logs = r.logs[len(r.logs)-interactiveLogLines:]
+	}
+	r.renderLocked()
+	r.mux.Unlock()
+	return len(p), nil
+}
+
+func (r *interactiveRun) BeforeStep(name string) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for r.paused {
+		r.resumed.Wait()
+	}
+	step := r.pendingStep(name)
+	if step.skip {
+		step.Status = stepSkipped
+		r.renderLocked()
+		return true
+	}
+	step.Status = stepRunning
+	step.Error = ""
+	r.renderLocked()
+	return false
+}
+
+func (r *interactiveRun) RetryStep(name string, results []*executor.CommandResult) bool {
+	r.mux.Lock()
+	step := r.runningStep(name)
+	r.update(step, results)
+	r.mux.Unlock()
+	if r.ask(fmt.Sprintf("%s failed: [r]etry or [c]ontinue?", name), "rc") != 'r' {
+		return false
+	}
+	r.mux.Lock()
+	step.Status = stepPending
+	r.renderLocked()
+	r.mux.Unlock()
+	return true
+}
+
+func (r *interactiveRun) ConfirmStep(name, desc, nodeGroup string) bool {
+	if len(desc) > 0 {
+		desc = fmt.Sprintf(" (%s)", desc)
+	}
+	return r.ask(fmt.Sprintf("Run %s%s on %s? [y/n]", name, desc, nodeGroup), "yn") == 'y'
+}
+
+// Done updates the steps with the results of the finished command.
+func (r *interactiveRun) Done(results []*executor.CommandResult) {
+	if len(results) == 0 {
+		return
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	step := r.runningStep(results[0].Name)
+	r.update(step, results)
+	r.renderLocked()
+}
+
+func (r *interactiveRun) update(step *runStep, results []*executor.CommandResult) {
+	step.Status = stepDone
+	step.GasUsed = 0
+	step.Txs = nil
+	_, isWrite := r.spec.WriteCmds[step.Name]
+	for _, result := range results {
+		step.GasUsed += result.GasUsed
+		if result.Error != nil {
+			step.Status = stepFailed
+			step.Error = result.Error.Error()
+			continue
+		} else if len(result.Skipped) > 0 && result.Result == nil {
+			step.Status = stepSkipped
+			step.Error = result.Skipped
+			continue
+		}
+		if isWrite {
+			for _, handle := range result.TxHandles() {
+				if tx, ok := handle.(string); ok {
+					step.Txs = append(step.Txs, strings.TrimPrefix(tx, "tx:"))
+				}
+			}
+		}
+	}
+}
+
+// pendingStep finds the first pending step of the command, hooks and compensations
+// not known in advance are added as they run.
+func (r *interactiveRun) pendingStep(name string) *runStep {
+	for _, step := range r.steps {
+		if step.Name == name && step.Status == stepPending {
+			return step
+		}
+	}
+	step := &runStep{
+		Name:   name,
+		Status: stepPending,
+	}
+	r.steps = append(r.steps, step)
+	return step
+}
+
+func (r *interactiveRun) runningStep(name string) *runStep {
+	for _, step := range r.steps {
+		if step.Name == name && step.Status == stepRunning {
+			return step
+		}
+	}
+	for _, step := range r.steps {
+		if step.Name == name && step.Status != stepPending {
+			return step
+		}
+	}
+	return r.pendingStep(name)
+}
+
+// ask shows the prompt and waits for one of the answer keys, prompts are asked one at a time.
+func (r *interactiveRun) ask(prompt, answers string) byte {
+	r.promptMux.Lock()
+	defer r.promptMux.Unlock()
+	r.mux.Lock()
+	r.prompt = prompt
+	r.answers = answers
+	r.renderLocked()
+	r.mux.Unlock()
+	answer := <-r.answerC
+	r.mux.Lock()
+	r.prompt = ""
+	r.answers = ""
+	r.renderLocked()
+	r.mux.Unlock()
+	return answer
+}
+
+func (r *interactiveRun) readKeys() {
+	buf := make([]byte, 8)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		key := buf[:n]
+		r.mux.Lock()
+		answers := r.answers
+		switch {
+		case len(answers) > 0 && n == 1 && strings.IndexByte(answers, key[0]) >= 0:
+			r.mux.Unlock()
+			r.answerC <- key[0]
+			continue
+		case n == 1 && (key[0] == 'q' || key[0] == 3):
+			// Ctrl-C doesn't interrupt in raw mode
+			r.mux.Unlock()
+			r.Stop()
+			log.Warningln("interactive run aborted")
+			os.Exit(130)
+		case string(key) == "\x1b[A" || string(key) == "k":
+			if r.selected > 0 {
+				r.selected--
+			}
+		case string(key) == "\x1b[B" || string(key) == "j":
+			if r.selected < len(r.steps)-1 {
+				r.selected++
+			}
+		case string(key) == "p" || string(key) == " ":
+			r.paused = !r.paused
+			r.resumed.Broadcast()
+		case string(key) == "s":
+			if step := r.steps[r.selected]; step.Status == stepPending {
+				step.skip = !step.skip
+			}
+		}
+		r.renderLocked()
+		r.mux.Unlock()
+	}
+}
+
+func (r *interactiveRun) render() {
+	r.mux.Lock()
+	r.renderLocked()
+	r.mux.Unlock()
+}
+
+var stepMarks = map[string]string{
+	stepPending: " ",
+	stepRunning: ">",
+	stepDone:    "+",
+	stepFailed:  "x",
+	stepSkipped: "-",
+}
+
+// renderLocked redraws the screen, the terminal is in raw mode so lines end with CRLF.
+func (r *interactiveRun) renderLocked() {
+	width, _, err := terminal.GetSize(r.fd)
+	if err != nil || width < 20 {
+		width = 80
+	}
+	var lines []string
+	state := "running"
+	if r.paused {
+		state = "paused"
+	}
+	var gasUsed uint64
+	for _, step := range r.steps {
+		gasUsed += step.GasUsed
+	}
+	lines = append(lines, fmt.Sprintf("target %s on %s — %s, gas used %d", r.target, r.nodeGroup, state, gasUsed), "")
+	for i, step := range r.steps {
+		cursor := " "
+		if i == r.selected {
+			cursor = "*"
+		}
+		status := step.Status
+		if step.skip && step.Status == stepPending {
+			status = "to skip"
+		}
+		line := fmt.Sprintf("%s [%s] %-24s %-8s", cursor, stepMarks[step.Status], step.Name, status)
+		if step.GasUsed > 0 {
+			line += fmt.Sprintf(" gas %d", step.GasUsed)
+		}
+		if len(step.Txs) > 0 {
+			line += " " + strings.Join(step.Txs, ",")
+		}
+		if len(step.Error) > 0 {
+			line += " " + step.Error
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", "up/down select, p pause, s skip, q quit")
+	if len(r.prompt) > 0 {
+		lines = append(lines, r.prompt)
+	}
+	lines = append(lines, "")
+	lines = append(lines, r.logs...)
+	for i, line := range lines {
+		if runes := []rune(line); len(runes) > width {
+			lines[i] = string(runes[:width])
+		}
+	}
+	fmt.Fprint(os.Stdout, "\x1b[H\x1b[2J"+strings.Join(lines, "\r\n"))
+}
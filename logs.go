@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/yaml"
+)
+
+// LogRecord is the event found by the logs command in the machine-readable output formats.
+type LogRecord struct {
+	Block    uint64                 `json:"block" yaml:"block"`
+	TxHash   string                 `json:"txHash" yaml:"txHash"`
+	LogIndex uint                   `json:"logIndex" yaml:"logIndex"`
+	Address  string                 `json:"address" yaml:"address"`
+	Contract string                 `json:"contract,omitempty" yaml:"contract,omitempty"`
+	Event    string                 `json:"event" yaml:"event"`
+	Args     map[string]interface{} `json:"args" yaml:"args"`
+	Labels   map[string]string      `json:"labels,omitempty" yaml:"labels,omitempty"`
+	ENS      map[string]string      `json:"ens,omitempty" yaml:"ens,omitempty"`
+}
+
+// logsWriter streams the events in the output format as they are found, the JSON array and
+// the YAML list are written item by item, so a long scan is not held in memory.
+type logsWriter struct {
+	format string
+	w      io.Writer
+	csv    *csv.Writer
+	args   []string
+	count  int
+}
+
+func newLogsWriter(format string, w io.Writer, event *abi.Event) (*logsWriter, error) {
+	if err := checkOutputFormat(format); err != nil {
+		return nil, err
+	}
+	lw := &logsWriter{
+		format: format,
+		w:      w,
+	}
+	for i, input := range event.Inputs {
+		name := input.Name
+		if len(name) == 0 {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		lw.args = append(lw.args, name)
+	}
+	switch format {
+	case OutputCSV:
+		lw.csv = csv.NewWriter(w)
+		header := append([]string{"block", "txHash", "logIndex", "address", "contract"}, lw.args...)
+		if err := lw.csv.Write(header); err != nil {
+			return nil, err
+		}
+	case OutputJSON:
+		if _, err := fmt.Fprint(w, "["); err != nil {
+			return nil, err
+		}
+	}
+	return lw, nil
+}
+
+func (lw *logsWriter) Write(event *executor.LogEvent) error {
+	record := &LogRecord{
+		Block:    event.Block,
+		TxHash:   event.TxHash,
+		LogIndex: event.LogIndex,
+		Address:  event.Address,
+		Contract: event.Contract,
+		Event:    event.Event.Event,
+		Args:     event.Args,
+		Labels:   event.Labels,
+		ENS:      event.ENS,
+	}
+	lw.count++
+	switch lw.format {
+	case OutputText:
+		emitter := record.Address
+		if len(record.Contract) > 0 {
+			emitter = fmt.Sprintf("%s (%s)", record.Address, record.Contract)
+		}
+		_, err := fmt.Fprintf(lw.w, "%d\t%s:%d\t%s\t%s\n", record.Block, record.TxHash, record.LogIndex, emitter, record.Event)
+		return err
+	case OutputCSV:
+		row := []string{
+			strconv.FormatUint(record.Block, 10),
+			record.TxHash,
+			strconv.FormatUint(uint64(record.LogIndex), 10),
+			record.Address,
+			record.Contract,
+		}
+		for _, name := range lw.args {
+			row = append(row, csvArg(record.Args[name]))
+		}
+		if err := lw.csv.Write(row); err != nil {
+			return err
+		}
+		// flushed per row, so the rows are streamed
+		lw.csv.Flush()
+		return lw.csv.Error()
+	case OutputYAML:
+		data, err := yaml.Marshal([]*LogRecord{record})
+		if err != nil {
+			return err
+		}
+		_, err = lw.w.Write(data)
+		return err
+	case OutputJSON:
+		data, err := json.MarshalIndent(record, "\t", "\t")
+		if err != nil {
+			return err
+		}
+		sep := ","
+		if lw.count == 1 {
+			sep = ""
+		}
+		_, err = fmt.Fprintf(lw.w, "%s\n\t%s", sep, data)
+		return err
+	}
+	return nil
+}
+
+// Close ends the JSON array, an empty result is an empty array.
+func (lw *logsWriter) Close() error {
+	switch lw.format {
+	case OutputJSON:
+		if lw.count == 0 {
+			_, err := fmt.Fprintln(lw.w, "]")
+			return err
+		}
+		_, err := fmt.Fprint(lw.w, "\n]\n")
+		return err
+	case OutputYAML:
+		if lw.count == 0 {
+			_, err := fmt.Fprintln(lw.w, "[]")
+			return err
+		}
+	}
+	return nil
+}
+
+// csvArg is the arg value in a CSV cell, the arrays and tuples as JSON.
+func csvArg(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool, uint8, uint16, uint32, uint64, int8, int16, int32, int64:
+		return fmt.Sprint(v)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return strings.Trim(string(data), `"`)
+}
@@ -5,11 +5,11 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/AtlantPlatform/ethfw"
@@ -28,35 +28,87 @@ var (
 	specPath  = flag.String("f", "playbook.yml", "Custom path to playbook.yml spec file.")
 	solcPath  = flag.String("s", "solc", "Name or path of Solidity compiler (solc, not solcjs).")
 	nodeGroup = flag.String("g", "genesis", "Inventory group name, corresponding to Geth nodes.")
+	network   = flag.String("network", "", "Network overlay to apply from the NETWORKS section.")
 	printHelp = flag.Bool("h", false, "Print help.")
 	logLevel  *int
+
+	outputFormat = flag.String("output", OutputText, "Output format of command results: text, json, yaml or csv.")
+	outputFile   = flag.String("output-file", "", "Write command results to the file instead of stdout.")
+	noColor      = flag.Bool("no-color", false, "Disable colors in logs and the run summary.")
+	quiet        = flag.Bool("q", false, "Print only warnings, errors and the run summary.")
+	recordPath   = flag.String("record", "", "Record the JSON-RPC calls of the run into the cassette file.")
+	replayPath   = flag.String("replay", "", "Replay the JSON-RPC calls from the cassette file instead of calling nodes.")
+
+	paramOverrides paramFlags
 )
 
+// paramFlags collects the repeated -p name=value flags.
+type paramFlags []string
+
+func (p *paramFlags) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *paramFlags) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
 func init() {
 	app.StringOpt("f", "playbook.yml", "Custom path to playbook.yml spec file.")
 	app.StringOpt("s", "solc", "Name or path of Solidity compiler (solc, not solcjs).")
 	app.StringOpt("g", "genesis", "Inventory group name, corresponding to Geth nodes.")
+	app.StringOpt("network", "", "Network overlay to apply from the NETWORKS section.")
 	app.BoolOpt("h", false, "Print help.")
+	app.StringOpt("output", OutputText, "Output format of command results: text, json, yaml or csv.")
+	app.StringOpt("output-file", "", "Write command results to the file instead of stdout.")
+	app.BoolOpt("no-color", false, "Disable colors in logs and the run summary.")
+	app.BoolOpt("q quiet", false, "Print only warnings, errors and the run summary.")
+	app.StringOpt("record", "", "Record the JSON-RPC calls of the run into the cassette file.")
+	app.StringOpt("replay", "", "Replay the JSON-RPC calls from the cassette file instead of calling nodes.")
+	flag.BoolVar(quiet, "quiet", false, "Print only warnings, errors and the run summary.")
+	flag.Var(&paramOverrides, "p", "Override the value of a spec param, name=value.")
+	app.StringsOpt("p", nil, "Override the value of a spec param, name=value.")
 	logLevel = app.IntOpt("l log-level", 4, "Sets the log level (default: info)")
 }
 
 func main() {
 	flag.Parse()
+	if flag.Arg(0) == "migrate" && isSpecOutdated() {
+		// the outdated spec doesn't load, it's migrated before the spec commands are known
+		app.Command("migrate", migrateDesc, newMigrate())
+		if err := app.Run(os.Args); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
 	spec, ok := loadSpec()
 	if !ok {
 		if *printHelp {
 			flag.Usage()
 			os.Exit(0)
 		}
-		os.Exit(-1)
+		os.Exit(exitValidation)
 	}
 	registerCommands(app, spec)
+	registerBuiltins(app, spec)
 	app.Before = func() {
 		if *printHelp {
 			app.PrintLongHelp()
 			os.Exit(0)
 		}
 		log.SetLevel(log.Level(*logLevel))
+		if *quiet && log.GetLevel() > log.WarnLevel {
+			log.SetLevel(log.WarnLevel)
+		}
+		if *noColor {
+			log.SetFormatter(&log.TextFormatter{DisableColors: true})
+		}
+		// the address book labels are known once the spec is validated
+		log.AddHook(&model.LabelHook{Spec: spec})
+		if err := checkOutputFormat(*outputFormat); err != nil {
+			log.Fatalln(err)
+		}
 	}
 	app.Action = func() {
 		validateSpec(spec, "", nil)
@@ -68,6 +120,26 @@ func main() {
 }
 
 func registerCommands(app *cli.Cli, spec *model.Spec) {
+	for _, cmd := range specCommands(spec) {
+		if cmd.Kind == "TARGET" {
+			app.Command(cmd.Name, cmd.Desc, newTarget(spec, cmd.Name, cmd.ArgCount))
+			continue
+		}
+		app.Command(cmd.Name, cmd.Desc, newCommand(spec, cmd.Name, cmd.ArgCount))
+	}
+}
+
+type specCommand struct {
+	Kind     string
+	Name     string
+	Desc     string
+	ArgCount int
+}
+
+// specCommands lists the targets and the CALL, VIEW and WRITE commands of the spec,
+// sorted by name within each kind, with the generic description if not specified.
+func specCommands(spec *model.Spec) []*specCommand {
+	var cmds []*specCommand
 	targetsNames := make([]string, 0, len(spec.Targets))
 	for name := range spec.Targets {
 		targetsNames = append(targetsNames, name)
@@ -75,10 +147,10 @@ func registerCommands(app *cli.Cli, spec *model.Spec) {
 	sort.Strings(targetsNames)
 	for _, name := range targetsNames {
 		targetSpec, _ := spec.Targets.TargetSpec(name)
-		argCount := targetSpec.ArgCount(spec)
+		argCount := spec.TargetArgCount(name)
 		cmdNames := targetSpec.CmdNames()
 		desc := fmt.Sprintf("Target with %d commands, accepts %d args", len(cmdNames), argCount)
-		app.Command(name, desc, newTarget(spec, name, argCount))
+		cmds = append(cmds, &specCommand{"TARGET", name, desc, argCount})
 	}
 
 	callCmdNames := make([]string, 0, len(spec.CallCmds))
@@ -93,7 +165,7 @@ func registerCommands(app *cli.Cli, spec *model.Spec) {
 		if len(desc) == 0 {
 			desc = fmt.Sprintf("Generic CALL command, accepts %d args", argCount)
 		}
-		app.Command(name, desc, newCommand(spec, name, argCount))
+		cmds = append(cmds, &specCommand{"CALL", name, desc, argCount})
 	}
 
 	viewCmdNames := make([]string, 0, len(spec.ViewCmds))
@@ -108,7 +180,7 @@ func registerCommands(app *cli.Cli, spec *model.Spec) {
 		if len(desc) == 0 {
 			desc = fmt.Sprintf("Generic VIEW command, accepts %d args", argCount)
 		}
-		app.Command(name, desc, newCommand(spec, name, argCount))
+		cmds = append(cmds, &specCommand{"VIEW", name, desc, argCount})
 	}
 
 	writeCmdNames := make([]string, 0, len(spec.WriteCmds))
@@ -123,8 +195,9 @@ func registerCommands(app *cli.Cli, spec *model.Spec) {
 		if len(desc) == 0 {
 			desc = fmt.Sprintf("Generic WRITE command, accepts %d args", argCount)
 		}
-		app.Command(name, desc, newCommand(spec, name, argCount))
+		cmds = append(cmds, &specCommand{"WRITE", name, desc, argCount})
 	}
+	return cmds
 }
 
 func newCommand(spec *model.Spec, name string, argCount int) cli.CmdInitializer {
@@ -133,6 +206,9 @@ func newCommand(spec *model.Spec, name string, argCount int) cli.CmdInitializer
 		for i := 0; i < argCount; i++ {
 			args[i] = cmd.StringArg(fmt.Sprintf("ARG%d", i+1), "", fmt.Sprintf("Command argument $%d", i+1))
 		}
+		approve := cmd.StringsOpt("approve", nil, "Approval tokens of commands requiring confirmation.")
+		allowFlagged := cmd.BoolOpt("allow-flagged", false, "Send to the counterparties flagged by the screening.")
+		plan := cmd.BoolOpt("plan", false, "Print the transactions that would be sent, without sending.")
 		cmd.Action = func() {
 			appArgs := []string{name}
 			for _, arg := range args {
@@ -142,15 +218,32 @@ func newCommand(spec *model.Spec, name string, argCount int) cli.CmdInitializer
 			cmdLog := log.WithFields(log.Fields{
 				"command": name,
 			})
-			executor, err := executor.New(ctx, spec)
+			exec, err := executor.New(ctx, spec)
 			if err != nil {
 				cmdLog.WithError(err).Fatalln("failed to init executor")
 			}
-			results, found := executor.RunCommand(ctx, name)
+			exec.SetApprovals(*approve)
+			exec.SetAllowFlagged(*allowFlagged)
+			exec.SetPlan(*plan)
+			results, found := exec.RunCommand(ctx, name)
 			if !found {
 				cmdLog.Fatalln("command not found")
 			}
-			exportResultsText(spec, results, "")
+			if *plan && !structuredOutput() {
+				for _, result := range results {
+					result.Name = name
+				}
+				title := fmt.Sprintf("%s on %s", name, ctx.NodeGroup())
+				if code := printPlan(spec, title, [][]*executor.CommandResult{results}); code != exitOK {
+					os.Exit(code)
+				}
+				return
+			}
+			exportResults(ctx, spec, name, [][]*executor.CommandResult{results})
+			reportExpectations(name, results)
+			if code := exitCode([][]*executor.CommandResult{results}, false); code != exitOK {
+				os.Exit(code)
+			}
 		}
 	}
 }
@@ -161,6 +254,17 @@ func newTarget(spec *model.Spec, name string, argCount int) cli.CmdInitializer {
 		for i := 0; i < argCount; i++ {
 			args[i] = cmd.StringArg(fmt.Sprintf("ARG%d", i+1), "", fmt.Sprintf("Target argument $%d", i+1))
 		}
+		resume := cmd.StringOpt("resume", "", "Run id of an interrupted run to resume, skipping completed commands.")
+		approve := cmd.StringsOpt("approve", nil, "Approval tokens of commands requiring confirmation.")
+		allowFlagged := cmd.BoolOpt("allow-flagged", false, "Send to the counterparties flagged by the screening.")
+		interactive := cmd.BoolOpt("i interactive", false, "Show the live status of the run, allowing to pause, skip and retry commands.")
+		plan := cmd.BoolOpt("plan", false, "Print the transactions that would be sent in order, without sending.")
+		watch := cmd.BoolOpt("watch", false, "Re-run the read-only target on new blocks, printing the values that changed.")
+		every := cmd.IntOpt("every", 1, "Re-run the watched target every N blocks.")
+		noProgress := cmd.BoolOpt("no-progress", false, "Don't show the progress line, shown when stderr is a terminal.")
+		failFast := cmd.BoolOpt("fail-fast", false, "Stop at the first failed command, including views and CALL commands.")
+		keepGoing := cmd.BoolOpt("keep-going", false, "Run all commands even if some have failed, skipping the dependent ones.")
+		monitorReorgs := cmd.IntOpt("monitor-reorgs", 0, "Keep monitoring the transactions of the run until N blocks deep, sending the ones dropped by reorgs again.")
 		cmd.Action = func() {
 			appArgs := []string{name}
 			for _, arg := range args {
@@ -174,32 +278,180 @@ func newTarget(spec *model.Spec, name string, argCount int) cli.CmdInitializer {
 			if err != nil {
 				cmdLog.WithError(err).Fatalln("failed to init executor")
 			}
+			exec.SetApprovals(*approve)
+			exec.SetAllowFlagged(*allowFlagged)
+			if *failFast && *keepGoing {
+				cmdLog.Errorln("--fail-fast and --keep-going can't be used together")
+				os.Exit(exitValidation)
+			}
+			exec.SetFailFast(*failFast)
+			exec.SetKeepGoing(*keepGoing)
+			if *watch {
+				if !spec.IsReadOnlyTarget(name) {
+					cmdLog.Fatalln("only targets of views and read-only calls can be watched")
+				} else if *every < 1 {
+					cmdLog.Fatalln("--every must be a positive number of blocks")
+				}
+				watchTarget(ctx, exec, spec, name, uint64(*every))
+				return
+			} else if trigger := spec.TargetTrigger(name); trigger != nil && !*plan {
+				triggerTarget(ctx, exec, spec, name, trigger)
+				return
+			}
+			if *monitorReorgs < 0 {
+				cmdLog.Fatalln("--monitor-reorgs must be a positive number of blocks")
+			}
+			var journal *model.RunJournal
+			if *plan {
+				exec.SetPlan(true)
+			} else {
+				journal = loadJournal(ctx, name, appArgs[1:], *resume)
+				exec.SetJournal(journal)
+				cmdLog.WithField("run", journal.ID).Infoln("run journal is stored, use --resume if interrupted")
+			}
+			var run *interactiveRun
+			if *interactive {
+				if run, err = newInteractiveRun(spec, name, ctx.NodeGroup()); err != nil {
+					cmdLog.WithError(err).Fatalln("failed to start interactive mode")
+				}
+				exec.SetStepControl(run)
+			}
+			var progress *progressBar
+			if run == nil && !*noProgress && !*quiet && !*plan && isTerminal(os.Stderr) {
+				progress = newProgressBar(spec, name)
+				exec.SetProgress(progress.Item)
+			}
 			resultsC := make(chan []*executor.CommandResult, 100)
 			wg := new(sync.WaitGroup)
 			wg.Add(1)
+			var collected [][]*executor.CommandResult
+			var (
+				structured [][]*executor.CommandResult
+				all        [][]*executor.CommandResult
+			)
+			printResults := func(results []*executor.CommandResult) {
+				all = append(all, results)
+				if structuredOutput() || *plan {
+					// the records are written at once when the target is done
+					structured = append(structured, results)
+				} else if !*quiet {
+					fmt.Printf("%s:\n", results[0].Name)
+					exportResultsText(spec, results, "\t")
+				}
+				reportExpectations(results[0].Name, results)
+			}
 			go func() {
 				defer wg.Done()
 				for results := range resultsC {
-					fmt.Printf("%s:\n", results[0].Name)
-					exportResultsText(spec, results, "\t")
+					if run != nil {
+						// the results are printed once the screen is restored
+						run.Done(results)
+						collected = append(collected, results)
+						continue
+					}
+					if progress != nil {
+						progress.Done(results)
+						progress.Print(func() {
+							printResults(results)
+						})
+						continue
+					}
+					printResults(results)
 				}
 			}()
+			if run != nil {
+				if err := run.Start(); err != nil {
+					cmdLog.WithError(err).Fatalln("failed to start interactive mode")
+				}
+			} else if progress != nil {
+				progress.Start()
+			}
 			if found := exec.RunTarget(ctx, name, resultsC); !found {
+				if run != nil {
+					run.Stop()
+				}
 				cmdLog.Fatalln("target not found")
 			}
 			wg.Wait()
+			if progress != nil {
+				progress.Stop()
+			}
+			if run != nil {
+				run.Stop()
+				for _, results := range collected {
+					printResults(results)
+				}
+			}
+			title := fmt.Sprintf("%s on %s", name, ctx.NodeGroup())
+			code := exitCode(all, *keepGoing)
+			if *plan && !structuredOutput() {
+				if planCode := printPlan(spec, title, structured); code == exitOK {
+					code = planCode
+				}
+			} else if structuredOutput() {
+				exportResults(ctx, spec, "", structured)
+				etherPrice := summaryEtherPrice(ctx, spec, exec)
+				if len(*outputFile) > 0 {
+					printSummary(os.Stdout, spec, title, all, etherPrice, exec.RPCUsage())
+				} else {
+					printSummary(os.Stderr, spec, title, all, etherPrice, exec.RPCUsage())
+				}
+			} else {
+				etherPrice := summaryEtherPrice(ctx, spec, exec)
+				printSummary(os.Stdout, spec, title, all, etherPrice, exec.RPCUsage())
+			}
+			if journal != nil && !spec.IsReadOnlyTarget(name) {
+				recordBalances(ctx, exec, journal)
+			}
+			if journal != nil && *monitorReorgs > 0 {
+				if reorgCode := monitorRun(ctx, exec, journal, uint64(*monitorReorgs)); code == exitOK {
+					code = reorgCode
+				}
+			}
+			if code != exitOK {
+				os.Exit(code)
+			}
 		}
 	}
 }
 
+// loadJournal starts a new run journal for the target, or loads the journal of the run to resume,
+// which must have been started for the same target, args and node group.
+func loadJournal(ctx model.AppContext, name string, args []string, resume string) *model.RunJournal {
+	if len(resume) == 0 {
+		return model.NewRunJournal(ctx.SpecDir(), name, ctx.NodeGroup(), args)
+	}
+	journalLog := log.WithFields(log.Fields{
+		"target": name,
+		"run":    resume,
+	})
+	journal, err := model.LoadRunJournal(ctx.SpecDir(), resume)
+	if err != nil {
+		journalLog.WithError(err).Fatalln("failed to load run journal")
+	}
+	if journal.Target != name {
+		journalLog.WithField("journal", journal.Target).Fatalln("the run has been started for another target")
+	} else if journal.NodeGroup != ctx.NodeGroup() {
+		journalLog.WithField("journal", journal.NodeGroup).Fatalln("the run has been started for another node group")
+	} else if strings.Join(journal.Args, " ") != strings.Join(args, " ") {
+		journalLog.WithField("journal", journal.Args).Fatalln("the run has been started with other args")
+	}
+	return journal
+}
+
 func loadSpec() (*model.Spec, bool) {
+	return loadNetworkSpec(*network)
+}
+
+// loadNetworkSpec loads the spec with the overlay of the network applied, unless the name is empty.
+// The inventory group of the network is selected, unless -g is set explicitly.
+func loadNetworkSpec(name string) (*model.Spec, bool) {
 	var spec *model.Spec
 	specLog := log.WithFields(log.Fields{
 		"filename": *specPath,
 	})
-	specData, err := ioutil.ReadFile(*specPath)
-	if err != nil {
-		specLog.WithError(err).Errorln("failed to load spec file")
+	specData, ok := readSpecData(specLog)
+	if !ok {
 		return nil, false
 	}
 	if err := yaml.Unmarshal(specData, &spec); err != nil {
@@ -215,10 +467,95 @@ func loadSpec() (*model.Spec, bool) {
 		spec.Config = model.DefaultConfigSpec
 	}
 	spec.Config.SpecDir = filepath.Dir(absSpecPath)
+	if err := spec.ResolveSecrets(spec.Config.SpecDir); err != nil {
+		specLog.WithError(err).Errorln("failed to decrypt secrets")
+		return nil, false
+	}
+	if err := spec.ResolveImports(spec.Config.SpecDir); err != nil {
+		specLog.WithError(err).Errorln("failed to resolve imports")
+		return nil, false
+	}
+	if len(name) > 0 {
+		networkSpec, err := spec.ApplyNetwork(name)
+		if err != nil {
+			specLog.WithError(err).Errorln("failed to apply network overlay")
+			return nil, false
+		}
+		if len(networkSpec.Group) > 0 && !isFlagSet("g") {
+			*nodeGroup = networkSpec.Group
+		}
+	}
+	if len(*recordPath) > 0 && len(*replayPath) > 0 {
+		specLog.Errorln("--record and --replay are mutually exclusive")
+		return nil, false
+	} else if len(*recordPath) > 0 {
+		cassette, err := model.NewCassette(*recordPath)
+		if err != nil {
+			specLog.WithError(err).Errorln("failed to create the cassette file")
+			return nil, false
+		}
+		spec.UseCassette(cassette)
+	} else if len(*replayPath) > 0 {
+		cassette, err := model.LoadCassette(*replayPath)
+		if err != nil {
+			specLog.WithError(err).Errorln("failed to load the cassette file")
+			return nil, false
+		}
+		spec.UseCassette(cassette)
+	}
 	return spec, true
 }
 
+// readSpecData reads the spec file and renders it with the param overrides.
+func readSpecData(specLog *log.Entry) ([]byte, bool) {
+	specData, err := model.ReadSpecFile(*specPath)
+	if err != nil {
+		specLog.WithError(err).Errorln("failed to load spec file")
+		return nil, false
+	}
+	if checksumErrors := model.CheckChecksums(specData); len(checksumErrors) > 0 {
+		for _, err := range checksumErrors {
+			fmt.Fprintf(os.Stderr, "%s:%v\n", *specPath, err)
+		}
+		specLog.Errorln("spec has mistyped addresses")
+		return nil, false
+	}
+	if err := model.CheckSpecVersion(specData); err != nil {
+		specLog.WithError(err).Errorln("failed to load spec file")
+		return nil, false
+	}
+	if specData, err = model.RenderSpec(specData, paramOverrides); err != nil {
+		specLog.WithError(err).Errorln("failed to render spec")
+		return nil, false
+	}
+	return specData, true
+}
+
+func isFlagSet(name string) bool {
+	var found bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
 func validateSpec(spec *model.Spec, appCommand string, appArgs []string) model.AppContext {
+	ctx, ok := validateSpecWith(spec, appCommand, appArgs, ethfw.NewKeyCache())
+	if !ok {
+		if spec.NodesUnavailable() {
+			os.Exit(exitUnavailable)
+		}
+		os.Exit(exitValidation)
+	}
+	return ctx
+}
+
+// validateSpecWith validates the spec for the command, using the key cache, so the keys decrypted
+// once are reused by the commands run in one session.
+func validateSpecWith(spec *model.Spec, appCommand string,
+	appArgs []string, keycache ethfw.KeyCache) (model.AppContext, bool) {
 	specLog := log.WithFields(log.Fields{
 		"filename": *specPath,
 	})
@@ -235,11 +572,37 @@ func validateSpec(spec *model.Spec, appCommand string, appArgs []string) model.A
 		solcCompiler = compiler
 	}
 	ctx := model.NewAppContext(context.Background(), appCommand, appArgs, *nodeGroup,
-		spec.Config.SpecDir, solcCompiler, ethfw.NewKeyCache())
-	if ok := spec.Validate(ctx); !ok {
-		os.Exit(-1)
+		spec.Config.SpecDir, solcCompiler, keycache)
+	return ctx, spec.Validate(ctx)
+}
+
+// structuredOutput reports whether the results are exported as records, the output file implies JSON.
+func structuredOutput() bool {
+	return *outputFormat != OutputText || len(*outputFile) > 0
+}
+
+// exportResults exports the results of commands in the output format, the text format
+// is printed for a single command only, the name of the command is used if set.
+func exportResults(ctx model.AppContext, spec *model.Spec, name string, results [][]*executor.CommandResult) {
+	if !structuredOutput() {
+		exportResultsText(spec, results[0], "")
+		return
+	}
+	var records []*ResultRecord
+	for _, cmdResults := range results {
+		cmdName := name
+		if len(cmdName) == 0 && len(cmdResults) > 0 {
+			cmdName = cmdResults[0].Name
+		}
+		records = append(records, resultRecords(spec, outputNetwork(ctx), cmdName, cmdResults)...)
+	}
+	format := *outputFormat
+	if format == OutputText {
+		format = OutputJSON
+	}
+	if err := writeResultRecords(format, *outputFile, records); err != nil {
+		log.WithError(err).Fatalln("failed to write results")
 	}
-	return ctx
 }
 
 func exportResultsText(spec *model.Spec, results []*executor.CommandResult, padding string) {
@@ -253,20 +616,31 @@ func exportResultsText(spec *model.Spec, results []*executor.CommandResult, padd
 				text := jsonPaddedString(&ErrorObject{Error: results[0].Error.Error()}, padding)
 				fmt.Println(padding + text)
 				return
+			} else if len(results[0].Skipped) > 0 {
+				text := jsonPaddedString(&SkippedObject{Skipped: results[0].Skipped}, padding)
+				fmt.Println(padding + text)
+				return
 			}
-			text := jsonPaddedString(prettify(results[0].Result), padding)
+			text := jsonPaddedString(resultValue(results[0]), padding)
 			fmt.Println(padding + text)
 			return
 		}
 	}
-	for _, result := range results {
-		walletName := spec.Wallets.NameOf(result.Wallet)
+	for i, result := range results {
+		var text string
 		if result.Error != nil {
-			text := jsonPaddedString(&ErrorObject{Error: result.Error.Error()}, padding)
-			fmt.Printf("%s%s (@%s): %s\n", padding, result.Wallet, walletName, text)
+			text = jsonPaddedString(&ErrorObject{Error: result.Error.Error()}, padding)
+		} else if len(result.Skipped) > 0 {
+			text = jsonPaddedString(&SkippedObject{Skipped: result.Skipped}, padding)
+		} else {
+			text = jsonPaddedString(resultValue(result), padding)
+		}
+		if len(result.Wallet) == 0 {
+			// results of foreach iterations are labeled by the row index
+			fmt.Printf("%s[%d]: %s\n", padding, i, text)
 			continue
 		}
-		text := jsonPaddedString(prettify(result.Result), padding)
+		walletName := spec.Wallets.NameOf(result.Wallet)
 		fmt.Printf("%s%s (@%s): %s\n", padding, result.Wallet, walletName, text)
 	}
 }
@@ -279,6 +653,45 @@ func jsonPaddedString(v interface{}, padding string) string {
 	return string(vv)
 }
 
+// reportExpectations prints the failed view expectations as a diff to stderr.
+func reportExpectations(name string, results []*executor.CommandResult) bool {
+	if !executor.ExpectationFailed(results) {
+		return false
+	}
+	for _, result := range results {
+		expectErr, ok := result.Error.(*model.ExpectationError)
+		if !ok {
+			continue
+		}
+		header := name
+		if len(result.Wallet) > 0 {
+			header = fmt.Sprintf("%s (%s)", name, result.Wallet)
+		}
+		fmt.Fprintf(os.Stderr, "--- expected: %s\n+++ actual: %s\n%s\n", header, header, expectErr.Diff())
+	}
+	return true
+}
+
 type ErrorObject struct {
 	Error string `json:"error"`
 }
+
+type SkippedObject struct {
+	Skipped string `json:"skipped"`
+}
+
+type EventsObject struct {
+	Result interface{}       `json:"result"`
+	Events []*executor.Event `json:"events"`
+}
+
+// resultValue adds the decoded events of awaited transactions to the result.
+func resultValue(result *executor.CommandResult) interface{} {
+	if len(result.Events) == 0 {
+		return prettify(result.Result)
+	}
+	return &EventsObject{
+		Result: prettify(result.Result),
+		Events: result.Events,
+	}
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var bigIntType = reflect.TypeOf(new(big.Int))
+
+// validateABI checks the parsed param values against the method inputs, so type mismatches
+// and out of range integers fail the spec validation instead of the transaction. Integers are
+// converted to the exact Go types expected by the ABI encoder. References are checked once resolved.
+func (spec *ParamSpec) validateABI(inputs abi.Arguments) error {
+	if len(spec.paramValues) != len(inputs) {
+		err := fmt.Errorf("expected %d params, got %d", len(inputs), len(spec.paramValues))
+		return err
+	}
+	for i, input := range inputs {
+		if err := spec.validateArg(i, input.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTupleABI checks the param values against the inputs of the method with struct params,
+// the structs given as just tuple are parsed with the components of the ABI.
+func (spec *ParamSpec) validateTupleABI(method *tupleMethod) error {
+	inputs := method.inputs.fields
+	if len(spec.paramValues) != len(inputs) {
+		err := fmt.Errorf("expected %d params, got %d", len(inputs), len(spec.paramValues))
+		return err
+	}
+	for i, input := range inputs {
+		v, ok := spec.paramValues[i].(*TupleValue)
+		if input.kind == argElem {
+			if err := spec.validateArg(i, input.elem); err != nil {
+				return err
+			}
+			continue
+		} else if !ok {
+			err := fmt.Errorf("param %d: expected %s, use a map or a list value", i, input)
+			return err
+		}
+		if v.typ == nil {
+			if err := v.parse(input); err != nil {
+				return err
+			}
+		} else if v.typ.canonical() != input.canonical() {
+			err := fmt.Errorf("%s: expected %s, got %s", v.path, input, v.typ)
+			return err
+		}
+	}
+	return nil
+}
+
+func (spec *ParamSpec) validateArg(i int, typ abi.Type) error {
+	switch v := spec.paramValues[i].(type) {
+	case nil, *WalletFieldReference, *CommandOutputReference:
+	case *ParamExpression:
+		v.input = &typ
+	case *TupleValue:
+		err := fmt.Errorf("param %d: tuple given for %s", i, typ.String())
+		return err
+	case string:
+		if typ.T != abi.StringTy {
+			err := fmt.Errorf("param %d: plain string given for %s, use {type, value} form", i, typ.String())
+			return err
+		}
+	case *big.Int:
+		converted, err := convertInt(v, typ)
+		if err != nil {
+			return fmt.Errorf("param %d: %v", i, err)
+		}
+		spec.paramValues[i] = converted
+	default:
+		if v == PlaceholderAddr {
+			return nil
+		}
+		if !reflect.TypeOf(v).AssignableTo(typ.Type) {
+			err := fmt.Errorf("param %d: expected %s, got %T", i, typ.String(), v)
+			return err
+		}
+	}
+	return nil
+}
+
+// convertInt checks that the value fits into the integer ABI type and converts it to the Go type of the encoder.
+func convertInt(v *big.Int, typ abi.Type) (interface{}, error) {
+	switch typ.T {
+	case abi.UintTy:
+		if v.Sign() < 0 {
+			return nil, fmt.Errorf("negative value %s for %s", v.String(), typ.String())
+		} else if v.BitLen() > typ.Size {
+			return nil, fmt.Errorf("value %s overflows %s", v.String(), typ.String())
+		}
+	case abi.IntTy:
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(typ.Size-1))
+		if v.Cmp(limit) >= 0 || v.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("value %s overflows %s", v.String(), typ.String())
+		}
+	default:
+		return nil, fmt.Errorf("expected %s, got an integer", typ.String())
+	}
+	if typ.Type == bigIntType {
+		return v, nil
+	}
+	rv := reflect.New(typ.Type).Elem()
+	if typ.T == abi.UintTy {
+		rv.SetUint(v.Uint64())
+	} else {
+		rv.SetInt(v.Int64())
+	}
+	return rv.Interface(), nil
+}
+
+// isChecksumValid reports whether a mixed-case hex address matches its EIP-55 checksum,
+// all lower or upper case addresses carry no checksum.
+func isChecksumValid(address string) bool {
+	hexPart := strings.TrimPrefix(address, "0x")
+	if hexPart == strings.ToLower(hexPart) || hexPart == strings.ToUpper(hexPart) {
+		return true
+	}
+	return common.HexToAddress(address).Hex() == "0x"+hexPart
+}
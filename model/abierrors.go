@@ -0,0 +1,66 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ABIError is a custom Solidity error declared in the contract ABI,
+// the ABI parser of go-ethereum skips those entries, so they are parsed separately.
+type ABIError struct {
+	Name   string
+	Inputs abi.Arguments
+}
+
+func (e *ABIError) Sig() string {
+	types := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		types[i] = input.Type.String()
+	}
+	return fmt.Sprintf("%s(%s)", e.Name, strings.Join(types, ","))
+}
+
+func (e *ABIError) ID() []byte {
+	return crypto.Keccak256([]byte(e.Sig()))[:4]
+}
+
+func parseABIErrors(abiJSON []byte) ([]*ABIError, error) {
+	var fields []struct {
+		Type   string
+		Name   string
+		Inputs abi.Arguments
+	}
+	if err := json.Unmarshal(abiJSON, &fields); err != nil {
+		return nil, err
+	}
+	var errs []*ABIError
+	for _, field := range fields {
+		if field.Type != "error" {
+			continue
+		}
+		errs = append(errs, &ABIError{
+			Name:   field.Name,
+			Inputs: field.Inputs,
+		})
+	}
+	return errs, nil
+}
+
+// FindError looks up a custom error by its 4-byte selector among all contracts of the spec.
+func (contracts Contracts) FindError(selector []byte) (*ABIError, bool) {
+	if len(selector) < 4 {
+		return nil, false
+	}
+	for _, contract := range contracts {
+		for _, abiErr := range contract.errors {
+			if string(abiErr.ID()) == string(selector[:4]) {
+				return abiErr, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// FindEvent looks up the event of a log by its topics among all contracts of the spec,
+// preferring the contract whose instance emitted the log. Returns the name of that contract,
+// or an empty name if the event is found in the ABI of a contract the emitter is not an instance of.
+func (contracts Contracts) FindEvent(address string, topics []common.Hash) (string, *abi.Event, bool) {
+	if len(topics) == 0 {
+		return "", nil, false
+	}
+	names := contracts.names()
+	for _, name := range names {
+		for _, instance := range contracts[name].Instances {
+			if !instance.MatchesAddress(address) {
+				continue
+			}
+			if event, ok := findEvent(contracts[name].abi, topics); ok {
+				return name, event, true
+			}
+		}
+	}
+	for _, name := range names {
+		if event, ok := findEvent(contracts[name].abi, topics); ok {
+			return "", event, true
+		}
+	}
+	return "", nil, false
+}
+
+// findEvent matches the event by the first topic and the number of its indexed inputs
+// by the rest, so e.g. the Transfer of ERC-721 isn't decoded as the one of ERC-20.
+func findEvent(contractABI abi.ABI, topics []common.Hash) (*abi.Event, bool) {
+	for _, event := range contractABI.Events {
+		if event.Anonymous || event.Id() != topics[0] {
+			continue
+		}
+		if indexedInputs(event.Inputs) != len(topics)-1 {
+			continue
+		}
+		event := event
+		return &event, true
+	}
+	return nil, false
+}
+
+func indexedInputs(inputs abi.Arguments) int {
+	var count int
+	for _, input := range inputs {
+		if input.Indexed {
+			count++
+		}
+	}
+	return count
+}
+
+// names returns the names of the contracts, sorted, so the lookups don't depend on the map order.
+func (contracts Contracts) names() []string {
+	names := make([]string, 0, len(contracts))
+	for name, contract := range contracts {
+		if contract != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindMethod looks up a method by its 4-byte selector among all contracts of the spec,
+// preferring the contract of the called instance. Returns the contract name.
+func (contracts Contracts) FindMethod(address string, selector []byte) (string, *abi.Method, bool) {
+	if len(selector) < 4 {
+		return "", nil, false
+	}
+	for name, contract := range contracts {
+		for _, instance := range contract.Instances {
+			if !instance.MatchesAddress(address) {
+				continue
+			}
+			if method, ok := findMethod(contract.abi, selector[:4]); ok {
+				return name, method, true
+			}
+		}
+	}
+	for name, contract := range contracts {
+		if method, ok := findMethod(contract.abi, selector[:4]); ok {
+			return name, method, true
+		}
+	}
+	return "", nil, false
+}
+
+func findMethod(contractABI abi.ABI, selector []byte) (*abi.Method, bool) {
+	for _, method := range contractABI.Methods {
+		if string(method.Id()) == string(selector) {
+			method := method
+			return &method, true
+		}
+	}
+	return nil, false
+}
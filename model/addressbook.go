@@ -0,0 +1,117 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AddressBook labels the external addresses, e.g. exchanges, partners and known contracts,
+// by address or ENS name. The labels are used like wallet names in the params and args,
+// and replace the addresses in the logs and the reports.
+type AddressBook map[string]string
+
+func (book AddressBook) Validate(ctx AppContext, spec *Spec) bool {
+	spec.labels = make(map[common.Address]string, len(book))
+	for label, address := range book {
+		validateLog := log.WithFields(log.Fields{
+			"section": "AddressBook",
+			"label":   label,
+		})
+		if len(label) == 0 || strings.ContainsAny(label, " @$.") || common.IsHexAddress(label) {
+			validateLog.Errorln("label must be a name without spaces, dots, @ and $")
+			return false
+		} else if _, ok := spec.Wallets.WalletSpec(label); ok {
+			validateLog.Errorln("label is already a wallet name")
+			return false
+		}
+		if isENSName(address) {
+			resolved, err := spec.ResolveENS(ctx, address)
+			if err != nil {
+				validateLog.WithField("ens", address).WithError(err).Errorln("failed to resolve ENS name")
+				return false
+			}
+			address = resolved.Hex()
+			book[label] = address
+		} else if !common.IsHexAddress(address) {
+			validateLog.WithField("address", address).Errorln("address must be a hex address or an ENS name")
+			return false
+		}
+		if other, ok := spec.labels[common.HexToAddress(address)]; ok {
+			validateLog.WithField("other", other).Warningln("address is labeled twice")
+			if other < label {
+				continue
+			}
+		}
+		spec.labels[common.HexToAddress(address)] = label
+	}
+	return true
+}
+
+// Address returns the address of the label, the label may be prefixed with @ like wallet references.
+func (book AddressBook) Address(label string) (common.Address, bool) {
+	address, ok := book[strings.TrimPrefix(label, walletPrefix)]
+	if !ok || !common.IsHexAddress(address) {
+		return common.Address{}, false
+	}
+	return common.HexToAddress(address), true
+}
+
+// AddressLabel returns the label of the address in the address book.
+func (spec *Spec) AddressLabel(address common.Address) (string, bool) {
+	label, ok := spec.labels[address]
+	return label, ok
+}
+
+// LabelHook replaces the labeled addresses in the log fields with their labels,
+// and adds the names of the contracts of the spec to their addresses.
+type LabelHook struct {
+	Spec *Spec
+}
+
+func (h *LabelHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *LabelHook) Fire(entry *log.Entry) error {
+	if len(h.Spec.labels) == 0 && len(h.Spec.Contracts) == 0 {
+		return nil
+	}
+	var data log.Fields
+	for key, value := range entry.Data {
+		var address common.Address
+		switch v := value.(type) {
+		case common.Address:
+			address = v
+		case string:
+			if len(v) != 2+2*common.AddressLength || !common.IsHexAddress(v) {
+				continue
+			}
+			address = common.HexToAddress(v)
+		default:
+			continue
+		}
+		label, ok := h.Spec.labels[address]
+		if !ok {
+			name := h.Spec.Contracts.NameOf(address.Hex())
+			if len(name) == 0 {
+				continue
+			}
+			label = fmt.Sprintf("%v (%s)", value, name)
+		}
+		if data == nil {
+			// the fields are shared with the parent entries
+			data = make(log.Fields, len(entry.Data))
+			for k, v := range entry.Data {
+				data[k] = v
+			}
+		}
+		data[key] = label
+	}
+	if data != nil {
+		entry.Data = data
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type AirdropMode string
+
+const (
+	// AirdropDirect sends a transfer transaction per recipient, the transactions
+	// of a chunk are sent with consecutive nonces, then awaited together.
+	AirdropDirect AirdropMode = "direct"
+	// AirdropDisperse sends a chunk of recipients in one call of the disperse contract.
+	AirdropDisperse AirdropMode = "disperse"
+	// AirdropMerkle funds the Merkle distributor of the allocations, then claims them on behalf
+	// of the recipients, the claims of a chunk are sent with consecutive nonces.
+	AirdropMerkle AirdropMode = "merkle"
+)
+
+// AirdropJournal is the state of an airdrop, stored as runs/<airdrop-id>.json next to the spec file,
+// so an interrupted airdrop can be resumed without paying any recipient twice. The distributor
+// is the address of the Merkle distributor in the merkle mode.
+type AirdropJournal struct {
+	ID          string              `json:"id"`
+	Wallet      string              `json:"wallet"`
+	Asset       string              `json:"asset"`
+	File        string              `json:"file"`
+	Mode        AirdropMode         `json:"mode"`
+	Distributor string              `json:"distributor,omitempty"`
+	NodeGroup   string              `json:"nodeGroup"`
+	Started     time.Time           `json:"started"`
+	Recipients  []*AirdropRecipient `json:"recipients"`
+
+	specDir string
+	mux     *sync.Mutex
+}
+
+// AirdropRecipient is the amount of the recipient, in asset units and in the smallest units,
+// and the state of the transfer. The status is empty until the transfer is sent.
+type AirdropRecipient struct {
+	Address string        `json:"address"`
+	Amount  string        `json:"amount"`
+	Units   string        `json:"units"`
+	Status  JournalStatus `json:"status,omitempty"`
+	TxHash  string        `json:"txHash,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// NewAirdropJournal starts the airdrop of the recipients read from the CSV file.
+func NewAirdropJournal(ctx AppContext, wallet, asset, path string, decimals int, mode AirdropMode) (*AirdropJournal, error) {
+	recipients, err := LoadAllocations(path, asset, decimals)
+	if err != nil {
+		return nil, err
+	}
+	started := time.Now().UTC()
+	return &AirdropJournal{
+		ID:         "airdrop-" + started.Format("20060102T150405"),
+		Wallet:     wallet,
+		Asset:      asset,
+		File:       path,
+		Mode:       mode,
+		NodeGroup:  ctx.NodeGroup(),
+		Started:    started,
+		Recipients: recipients,
+
+		specDir: ctx.SpecDir(),
+		mux:     new(sync.Mutex),
+	}, nil
+}
+
+// LoadAllocations reads the recipients from the CSV file with address (or recipient) and amount
+// columns, the amounts are in asset units and converted into the smallest units using the decimals.
+func LoadAllocations(path, asset string, decimals int) (AirdropRecipients, error) {
+	rows, err := loadRows(path)
+	if err != nil {
+		return nil, err
+	} else if len(rows) == 0 {
+		return nil, errors.New("no recipients in the file")
+	}
+	unit := weiUnit(int64(decimals))
+	seen := make(map[common.Address]int, len(rows))
+	recipients := make(AirdropRecipients, 0, len(rows))
+	for i, row := range rows {
+		// the header row is the first line
+		line := i + 2
+		address := airdropField(row, "address", "recipient")
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("line %d: recipient %q is not a hex address", line, address)
+		}
+		account := common.HexToAddress(address)
+		if prev, ok := seen[account]; ok {
+			return nil, fmt.Errorf("line %d: recipient %s is duplicated on line %d", line, address, prev)
+		}
+		seen[account] = line
+		amountStr := airdropField(row, "amount")
+		amount, ok := new(big.Rat).SetString(amountStr)
+		if !ok || amount.Sign() <= 0 {
+			return nil, fmt.Errorf("line %d: amount %q must be a positive number", line, amountStr)
+		}
+		units, err := denominateAmount(amount, unit)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: amount %s is a fraction of the smallest unit of %s", line, amountStr, asset)
+		}
+		recipients = append(recipients, &AirdropRecipient{
+			Address: strings.ToLower(account.Hex()),
+			Amount:  amountStr,
+			Units:   units.String(),
+		})
+	}
+	return recipients, nil
+}
+
+// airdropField is the value of the first of the columns found in the row, the names are case-insensitive.
+func airdropField(row map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		for column, v := range row {
+			if strings.EqualFold(column, name) {
+				return strings.TrimSpace(fmt.Sprint(v))
+			}
+		}
+	}
+	return ""
+}
+
+func LoadAirdropJournal(specDir, id string) (*AirdropJournal, error) {
+	data, err := ioutil.ReadFile(runJournalPath(specDir, id))
+	if err != nil {
+		return nil, err
+	}
+	journal := &AirdropJournal{
+		specDir: specDir,
+		mux:     new(sync.Mutex),
+	}
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, err
+	} else if !strings.HasPrefix(journal.ID, "airdrop-") {
+		return nil, fmt.Errorf("%s is not an airdrop journal", id)
+	}
+	return journal, nil
+}
+
+// AirdropRecipients are the recipients sent in one chunk.
+type AirdropRecipients []*AirdropRecipient
+
+// Total is the sum of the recipient amounts in the smallest units.
+func (r AirdropRecipients) Total() *big.Int {
+	total := new(big.Int)
+	for _, recipient := range r {
+		total.Add(total, recipient.Value())
+	}
+	return total
+}
+
+// Value is the amount in the smallest units.
+func (r *AirdropRecipient) Value() *big.Int {
+	value, _ := new(big.Int).SetString(r.Units, 10)
+	if value == nil {
+		return new(big.Int)
+	}
+	return value
+}
+
+// Pending returns the recipients that haven't been sent to yet, or whose transfers have failed.
+func (j *AirdropJournal) Pending() AirdropRecipients {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	var pending AirdropRecipients
+	for _, recipient := range j.Recipients {
+		if len(recipient.Status) == 0 || recipient.Status == JournalFailed {
+			pending = append(pending, recipient)
+		}
+	}
+	return pending
+}
+
+// Sent returns the recipients whose transfers have been sent, but not awaited yet.
+func (j *AirdropJournal) Sent() AirdropRecipients {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	var sent AirdropRecipients
+	for _, recipient := range j.Recipients {
+		if recipient.Status == JournalSent {
+			sent = append(sent, recipient)
+		}
+	}
+	return sent
+}
+
+// Update sets the status of the recipients and saves the journal, it's stored
+// after each update, so it survives the process being killed.
+func (j *AirdropJournal) Update(recipients AirdropRecipients, status JournalStatus, txHash string, err error) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	for _, recipient := range recipients {
+		recipient.Status = status
+		if len(txHash) > 0 {
+			recipient.TxHash = txHash
+		}
+		recipient.Error = ""
+		if err != nil {
+			recipient.Error = err.Error()
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(j.specDir, runsDir), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(j, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(runJournalPath(j.specDir, j.ID), data)
+}
+
+// Counts returns the number of recipients done, failed and left.
+func (j *AirdropJournal) Counts() (done, failed, left int) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	for _, recipient := range j.Recipients {
+		switch recipient.Status {
+		case JournalDone:
+			done++
+		case JournalFailed:
+			failed++
+		default:
+			left++
+		}
+	}
+	return done, failed, left
+}
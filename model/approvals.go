@@ -0,0 +1,68 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+const approvalSecretEnv = "APPROVAL_SECRET"
+
+// ApprovalSecretKey returns the secret the approval tokens are signed with, approvalSecret
+// of the config or the APPROVAL_SECRET env.
+func (spec *ConfigSpec) ApprovalSecretKey() string {
+	if len(spec.ApprovalSecret) > 0 {
+		return spec.ApprovalSecret
+	}
+	return os.Getenv(approvalSecretEnv)
+}
+
+// ApprovalRequest is posted to the approval webhook when a command requires confirmation
+// in non-interactive mode, the webhook responds once the command is approved or rejected.
+type ApprovalRequest struct {
+	Command     string   `json:"command"`
+	Description string   `json:"description,omitempty"`
+	Args        []string `json:"args"`
+	NodeGroup   string   `json:"nodeGroup"`
+	PlanHash    string   `json:"planHash"`
+}
+
+type approvalResponse struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason"`
+}
+
+// RequestApproval posts the request to the webhook, the call may block until a human
+// approves it, so it's bound only by the context.
+func RequestApproval(ctx context.Context, webhookURL string, request *ApprovalRequest) (bool, string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return false, "", err
+	}
+	req, err := http.NewRequest("POST", webhookURL, bytes.NewReader(data))
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, "", err
+	} else if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("%s responded with status %s", req.URL.Host, resp.Status)
+		return false, "", err
+	}
+	var result approvalResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false, "", err
+	}
+	return result.Approved, result.Reason, nil
+}
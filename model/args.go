@@ -0,0 +1,142 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// NamedArgs maps the ABI input names to param specs, so params can be specified
+// by name in any order. The param type is taken from the ABI, unless specified.
+type NamedArgs map[string]interface{}
+
+func (args NamedArgs) lookup(name string) (interface{}, string, bool) {
+	if arg, ok := args[name]; ok {
+		return arg, name, true
+	}
+	// allow to omit the underscore prefix, e.g. _to
+	trimmed := strings.TrimPrefix(name, "_")
+	if arg, ok := args[trimmed]; ok {
+		return arg, trimmed, true
+	}
+	return nil, "", false
+}
+
+// methodInput is a named input of a method, with the type as in the params.
+type methodInput struct {
+	Name string
+	Type string
+}
+
+func abiMethodInputs(inputs abi.Arguments) []methodInput {
+	result := make([]methodInput, len(inputs))
+	for i, input := range inputs {
+		result[i] = methodInput{
+			Name: input.Name,
+			Type: input.Type.String(),
+		}
+	}
+	return result
+}
+
+// resolveNamedArgs converts named args into the ordered params list, according to the method inputs.
+func (spec *ParamSpec) resolveNamedArgs(inputs []methodInput) error {
+	if len(spec.Args) == 0 || spec.argsResolved {
+		return nil
+	} else if len(spec.Params) > 0 {
+		return errors.New("params and args cannot co-exist in command spec")
+	}
+	used := make(map[string]struct{}, len(inputs))
+	params := make([]interface{}, 0, len(inputs))
+	for offset, input := range inputs {
+		if len(input.Name) == 0 {
+			err := fmt.Errorf("method input %d has no name in ABI, use positional params", offset)
+			return err
+		}
+		arg, name, ok := spec.Args.lookup(input.Name)
+		if !ok {
+			err := fmt.Errorf("missing arg %s of type %s", input.Name, input.Type)
+			return err
+		}
+		used[name] = struct{}{}
+		params = append(params, namedArgParam(arg, name, input.Type))
+	}
+	if len(used) != len(spec.Args) {
+		var unknown []string
+		for name := range spec.Args {
+			if _, ok := used[name]; !ok {
+				unknown = append(unknown, name)
+			}
+		}
+		sort.Strings(unknown)
+		err := fmt.Errorf("unknown args for the method: %s", strings.Join(unknown, ", "))
+		return err
+	}
+	spec.Params = params
+	spec.argsResolved = true
+	return nil
+}
+
+func namedArgParam(arg interface{}, name, typ string) interface{} {
+	if p, ok := arg.(map[interface{}]interface{}); ok {
+		if _, ok := p["value"]; !ok && isTupleType(typ) {
+			// the struct is given as the value
+			return map[interface{}]interface{}{
+				"type":  typ,
+				"name":  name,
+				"value": p,
+			}
+		}
+		param := make(map[interface{}]interface{}, len(p)+1)
+		for k, v := range p {
+			param[k] = v
+		}
+		if _, ok := param["type"]; !ok {
+			param["type"] = typ
+		}
+		param["name"] = name
+		return param
+	}
+	if list, ok := arg.([]interface{}); ok {
+		return map[interface{}]interface{}{
+			"type":  typ,
+			"name":  name,
+			"value": list,
+		}
+	}
+	value := nillableStr(arg)
+	key := "value"
+	for _, part := range strings.Split(value, " ") {
+		if isArgRef(part) {
+			key = "reference"
+			break
+		}
+	}
+	return map[interface{}]interface{}{
+		"type": typ,
+		"name": name,
+		key:    value,
+	}
+}
+
+func (args NamedArgs) countArgsUsing(set map[int]struct{}) {
+	for _, arg := range args {
+		var referenceStr string
+		switch a := arg.(type) {
+		case map[interface{}]interface{}:
+			referenceStr = nillableStr(a["reference"])
+		default:
+			referenceStr = nillableStr(a)
+		}
+		for _, part := range strings.Split(referenceStr, " ") {
+			if isArgRef(part) {
+				if argID, err := argReferenceID(part); err == nil {
+					set[argID] = struct{}{}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+func isArrayType(typ string) bool {
+	return strings.HasSuffix(typ, "]")
+}
+
+// parseArrayParam encodes a YAML list into the Go slice or array expected by the ABI encoder,
+// nested lists are encoded recursively. Errors name the path of the offending element, e.g. recipients[1].
+func parseArrayParam(root *Spec, evaler *Evaler, typ string, value interface{}, path string) (interface{}, error) {
+	abiType, err := abi.NewType(typ)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	v, err := parseArrayValue(root, evaler, abiType, value, path)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+func parseArrayValue(root *Spec, evaler *Evaler, typ abi.Type, value interface{}, path string) (reflect.Value, error) {
+	if _, ok := value.(map[interface{}]interface{}); ok {
+		err := fmt.Errorf("%s: expected %s, got a map", path, typ.String())
+		return reflect.Value{}, err
+	}
+	if typ.T != abi.SliceTy && typ.T != abi.ArrayTy {
+		if _, ok := value.([]interface{}); ok {
+			err := fmt.Errorf("%s: expected %s, got a list", path, typ.String())
+			return reflect.Value{}, err
+		}
+		return parseArrayElem(root, evaler, typ, value, path)
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		err := fmt.Errorf("%s: expected a list of %s", path, typ.Elem.String())
+		return reflect.Value{}, err
+	}
+	var result reflect.Value
+	if typ.T == abi.ArrayTy {
+		if len(items) != typ.Size {
+			err := fmt.Errorf("%s: expected %d elements, got %d", path, typ.Size, len(items))
+			return reflect.Value{}, err
+		}
+		result = reflect.New(typ.Type).Elem()
+	} else {
+		result = reflect.MakeSlice(typ.Type, len(items), len(items))
+	}
+	for i, item := range items {
+		elem, err := parseArrayValue(root, evaler, *typ.Elem, item, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		result.Index(i).Set(elem)
+	}
+	return result, nil
+}
+
+func parseArrayElem(root *Spec, evaler *Evaler, typ abi.Type, value interface{}, path string) (reflect.Value, error) {
+	valueStr := nillableStr(value)
+	if typ.T == abi.AddressTy && isWalletRef(valueStr) {
+		wallet, ok := root.Wallets.WalletSpec(valueStr[1:])
+		if !ok {
+			err := fmt.Errorf("%s: unknown wallet reference %s", path, valueStr)
+			return reflect.Value{}, err
+		}
+		valueStr = wallet.Address
+	}
+	v, ok := parseParam(evaler, ParamType(typ.String()), valueStr)
+	if !ok {
+		err := fmt.Errorf("%s: %q is not a valid %s", path, valueStr, typ.String())
+		return reflect.Value{}, err
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.Type().AssignableTo(typ.Type) {
+		if !rv.Type().ConvertibleTo(typ.Type) {
+			err := fmt.Errorf("%s: cannot use %T as %s", path, v, typ.String())
+			return reflect.Value{}, err
+		}
+		rv = rv.Convert(typ.Type)
+	}
+	return rv, nil
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/AtlantPlatform/ethfw/sol"
+)
+
+// artifactFile covers Truffle, Hardhat and Foundry artifact layouts,
+// where the bytecode is either a hex string or an object with the hex string.
+type artifactFile struct {
+	ContractName string          `json:"contractName"`
+	ABI          json.RawMessage `json:"abi"`
+	Bytecode     json.RawMessage `json:"bytecode"`
+}
+
+type artifactBytecode struct {
+	Object string `json:"object"`
+}
+
+// loadArtifact loads the contract ABI and bytecode from an artifact JSON file,
+// the file can also be a plain ABI array, then the contract cannot be deployed.
+func loadArtifact(specDir, path, name string) (*sol.Contract, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(specDir, filepath.FromSlash(path))
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	contract := &sol.Contract{
+		Name:       name,
+		SourcePath: path,
+	}
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		contract.ABI = data
+	} else {
+		var artifact artifactFile
+		if err := json.Unmarshal(data, &artifact); err != nil {
+			return nil, err
+		} else if len(artifact.ABI) == 0 {
+			return nil, errors.New("artifact has no abi field")
+		}
+		contract.ABI = artifact.ABI
+		if len(contract.Name) == 0 {
+			contract.Name = artifact.ContractName
+		}
+		if len(artifact.Bytecode) > 0 {
+			var bin string
+			if err := json.Unmarshal(artifact.Bytecode, &bin); err != nil {
+				var obj artifactBytecode
+				if err := json.Unmarshal(artifact.Bytecode, &obj); err != nil {
+					return nil, errors.New("artifact bytecode must be a string or an object")
+				}
+				bin = obj.Object
+			}
+			contract.Bin = strings.TrimPrefix(bin, "0x")
+		}
+	}
+	if len(contract.Name) == 0 {
+		contract.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := checkABI(contract.ABI); err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
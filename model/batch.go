@@ -0,0 +1,216 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchWindow is the time the concurrent calls are collected for, before they're sent in a batch.
+const batchWindow = 5 * time.Millisecond
+
+// batcher sends the concurrent JSON-RPC calls of a client as batch requests, up to the size calls
+// each, e.g. the reads of a command fanned out to many wallets. The ids of the calls are replaced
+// in the batch and restored in the responses.
+type batcher struct {
+	size int
+	send func(req *http.Request, body []byte) (*http.Response, error)
+
+	mux   *sync.Mutex
+	calls []*batchCall
+	timer *time.Timer
+}
+
+type batchCall struct {
+	req  *http.Request
+	body []byte
+	msg  map[string]json.RawMessage
+	resC chan batchResult
+}
+
+type batchResult struct {
+	resp *http.Response
+	err  error
+}
+
+func newBatcher(size int, send func(req *http.Request, body []byte) (*http.Response, error)) *batcher {
+	return &batcher{
+		size: size,
+		send: send,
+		mux:  new(sync.Mutex),
+	}
+}
+
+// batchMethods are the read methods sent in batches, the rest are sent as is, since the calls of
+// a batch the node doesn't support are resent one by one, which must not repeat a transaction.
+var batchMethods = map[string]bool{
+	"eth_blockNumber":           true,
+	"eth_call":                  true,
+	"eth_chainId":               true,
+	"eth_estimateGas":           true,
+	"eth_feeHistory":            true,
+	"eth_gasPrice":              true,
+	"eth_getBalance":            true,
+	"eth_getBlockByHash":        true,
+	"eth_getBlockByNumber":      true,
+	"eth_getCode":               true,
+	"eth_getLogs":               true,
+	"eth_getProof":              true,
+	"eth_getStorageAt":          true,
+	"eth_getTransactionByHash":  true,
+	"eth_getTransactionCount":   true,
+	"eth_getTransactionReceipt": true,
+	"eth_maxPriorityFeePerGas":  true,
+	"net_version":               true,
+}
+
+// Do sends the call within the next batch, the calls that are not single JSON-RPC requests
+// of the read methods are sent as is.
+func (b *batcher) Do(req *http.Request, body []byte) (*http.Response, error) {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return b.send(req, body)
+	}
+	var method string
+	if err := json.Unmarshal(msg["method"], &method); err != nil || !batchMethods[method] {
+		return b.send(req, body)
+	}
+	call := &batchCall{
+		req:  req,
+		body: body,
+		msg:  msg,
+		resC: make(chan batchResult, 1),
+	}
+	b.mux.Lock()
+	b.calls = append(b.calls, call)
+	if len(b.calls) >= b.size {
+		calls := b.takeLocked()
+		b.mux.Unlock()
+		go b.flush(calls)
+	} else {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(batchWindow, b.flushPending)
+		}
+		b.mux.Unlock()
+	}
+	select {
+	case result := <-call.resC:
+		return result.resp, result.err
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+func (b *batcher) takeLocked() []*batchCall {
+	calls := b.calls
+	b.calls = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return calls
+}
+
+func (b *batcher) flushPending() {
+	b.mux.Lock()
+	calls := b.takeLocked()
+	b.mux.Unlock()
+	if len(calls) > 0 {
+		b.flush(calls)
+	}
+}
+
+func (b *batcher) flush(calls []*batchCall) {
+	if len(calls) == 1 {
+		b.sendEach(calls)
+		return
+	}
+	batch := make([]map[string]json.RawMessage, 0, len(calls))
+	for i, call := range calls {
+		msg := make(map[string]json.RawMessage, len(call.msg))
+		for k, v := range call.msg {
+			msg[k] = v
+		}
+		msg["id"] = json.RawMessage(strconv.Itoa(i + 1))
+		batch = append(batch, msg)
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		b.sendEach(calls)
+		return
+	}
+	// the batch outlives the callers, the node timeouts apply
+	req := calls[0].req.WithContext(context.Background())
+	resp, err := b.send(req, body)
+	if err != nil {
+		b.fail(calls, err)
+		return
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		b.fail(calls, err)
+		return
+	} else if resp.StatusCode != http.StatusOK {
+		b.fail(calls, fmt.Errorf("batch request failed: %s", resp.Status))
+		return
+	}
+	var responses []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &responses); err != nil {
+		// the node doesn't support batch requests
+		b.sendEach(calls)
+		return
+	}
+	byID := make(map[string]map[string]json.RawMessage, len(responses))
+	for _, response := range responses {
+		byID[strings.TrimSpace(string(response["id"]))] = response
+	}
+	for i, call := range calls {
+		response, ok := byID[strconv.Itoa(i+1)]
+		if !ok {
+			call.resC <- batchResult{err: fmt.Errorf("no response to the call in the batch")}
+			continue
+		}
+		response["id"] = call.msg["id"]
+		data, _ := json.Marshal(response)
+		call.resC <- batchResult{
+			resp: &http.Response{
+				Status:        resp.Status,
+				StatusCode:    resp.StatusCode,
+				Proto:         resp.Proto,
+				ProtoMajor:    resp.ProtoMajor,
+				ProtoMinor:    resp.ProtoMinor,
+				Header:        resp.Header,
+				Body:          ioutil.NopCloser(bytes.NewReader(data)),
+				ContentLength: int64(len(data)),
+				Request:       call.req,
+			},
+		}
+	}
+}
+
+// sendEach resends the calls of a batch one by one, they're all reads of batchMethods.
+func (b *batcher) sendEach(calls []*batchCall) {
+	for _, call := range calls {
+		go func(call *batchCall) {
+			resp, err := b.send(call.req, call.body)
+			call.resC <- batchResult{
+				resp: resp,
+				err:  err,
+			}
+		}(call)
+	}
+}
+
+func (b *batcher) fail(calls []*batchCall, err error) {
+	for _, call := range calls {
+		call.resC <- batchResult{err: err}
+	}
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	beaconAPIKeyEnv = "BEACON_API_KEY"
+	// beaconBatchSize is the max of the validators queried in a request, the ids go in the URL.
+	beaconBatchSize = 64
+)
+
+// BeaconAPI is the client of the standard beacon node API of beaconURL.
+type BeaconAPI struct {
+	url    string
+	apiKey string
+}
+
+func (spec *ConfigSpec) BeaconAPI() *BeaconAPI {
+	apiKey := spec.BeaconAPIKey
+	if len(apiKey) == 0 {
+		apiKey = os.Getenv(beaconAPIKeyEnv)
+	}
+	return &BeaconAPI{
+		url:    strings.TrimSuffix(spec.BeaconURL, "/"),
+		apiKey: apiKey,
+	}
+}
+
+// BeaconValidator is the state of a validator at the head of the beacon chain, the balances are in gwei.
+type BeaconValidator struct {
+	Index                 string
+	Pubkey                string
+	Status                string
+	Balance               *big.Int
+	EffectiveBalance      *big.Int
+	WithdrawalCredentials string
+}
+
+// Validators returns the validators of the ids, indices or pubkeys, in no particular order; the ones
+// unknown to the beacon chain are left out.
+func (api *BeaconAPI) Validators(ctx context.Context, ids []string) ([]*BeaconValidator, error) {
+	validators := make([]*BeaconValidator, 0, len(ids))
+	for offset := 0; offset < len(ids); offset += beaconBatchSize {
+		end := offset + beaconBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		query := url.Values{}
+		query.Set("id", strings.Join(ids[offset:end], ","))
+		var result struct {
+			Data []struct {
+				Index     string `json:"index"`
+				Balance   string `json:"balance"`
+				Status    string `json:"status"`
+				Validator struct {
+					Pubkey                string `json:"pubkey"`
+					EffectiveBalance      string `json:"effective_balance"`
+					WithdrawalCredentials string `json:"withdrawal_credentials"`
+				} `json:"validator"`
+			} `json:"data"`
+		}
+		if err := api.get(ctx, "eth/v1/beacon/states/head/validators", query, &result); err != nil {
+			return nil, err
+		}
+		for _, data := range result.Data {
+			balance, ok := new(big.Int).SetString(data.Balance, 10)
+			if !ok {
+				return nil, fmt.Errorf("beacon API returned malformed balance %q of validator %s", data.Balance, data.Index)
+			}
+			effective, ok := new(big.Int).SetString(data.Validator.EffectiveBalance, 10)
+			if !ok {
+				effective = new(big.Int)
+			}
+			validators = append(validators, &BeaconValidator{
+				Index:                 data.Index,
+				Pubkey:                data.Validator.Pubkey,
+				Status:                data.Status,
+				Balance:               balance,
+				EffectiveBalance:      effective,
+				WithdrawalCredentials: data.Validator.WithdrawalCredentials,
+			})
+		}
+	}
+	return validators, nil
+}
+
+func (api *BeaconAPI) get(ctx context.Context, method string, query url.Values, v interface{}) error {
+	endpoint := api.url + "/" + method
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if len(api.apiKey) > 0 {
+		req.Header.Set("Authorization", "Bearer "+api.apiKey)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("beacon API responded with status %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("beacon API returned malformed response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	BlockTagLatest    = "latest"
+	BlockTagPending   = "pending"
+	BlockTagEarliest  = "earliest"
+	BlockTagSafe      = "safe"
+	BlockTagFinalized = "finalized"
+)
+
+// BlockRef pins a read to a block, given by number, tag, or timestamp
+// that is resolved to the latest block mined not after that time.
+type BlockRef struct {
+	Tag    string
+	Number *big.Int
+	Time   time.Time
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+func ParseBlockRef(str string) (*BlockRef, error) {
+	str = strings.TrimSpace(str)
+	switch str {
+	case BlockTagLatest, BlockTagPending, BlockTagEarliest, BlockTagSafe, BlockTagFinalized:
+		return &BlockRef{
+			Tag: str,
+		}, nil
+	}
+	if number, ok := new(big.Int).SetString(str, 0); ok {
+		if number.Sign() < 0 {
+			return nil, errors.New("block number must not be negative")
+		}
+		return &BlockRef{
+			Number: number,
+		}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, str); err == nil {
+			return &BlockRef{
+				Time: t,
+			}, nil
+		}
+	}
+	err := errors.New("block must be a number, tag (latest, safe, finalized, earliest, pending) or timestamp")
+	return nil, err
+}
+
+func (ref *BlockRef) String() string {
+	switch {
+	case len(ref.Tag) > 0:
+		return ref.Tag
+	case ref.Number != nil:
+		return ref.Number.String()
+	default:
+		return ref.Time.Format(time.RFC3339)
+	}
+}
+
+// blockParamOffsets lists JSON-RPC methods that accept the block param, by its offset.
+var blockParamOffsets = map[string]int{
+	"eth_getBalance":          1,
+	"eth_getCode":             1,
+	"eth_getTransactionCount": 1,
+	"eth_getStorageAt":        2,
+	"eth_call":                1,
+	"eth_getProof":            2,
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheDir is the directory next to the spec file, where
+// data fetched from external services is cached.
+const cacheDir = ".cache"
+
+// httpClient is used to query external services.
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+func cachePath(specDir string, elem ...string) string {
+	return filepath.Join(append([]string{specDir, cacheDir}, elem...)...)
+}
+
+func readCache(path string) ([]byte, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func writeCache(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
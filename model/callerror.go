@@ -0,0 +1,95 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// CallError is a failed JSON-RPC call along with the error data the node returned,
+// like the revert data of eth_call.
+type CallError struct {
+	Code    int
+	Message string
+	Data    []byte
+}
+
+func (err *CallError) Error() string {
+	if len(err.Message) == 0 {
+		return fmt.Sprintf("json-rpc error %d", err.Code)
+	}
+	return err.Message
+}
+
+// AsCallError gets the error data of a JSON-RPC error. The rpc client keeps it in an
+// exported field of its unexported error type, so the error is read back from its JSON encoding.
+func AsCallError(err error) (*CallError, bool) {
+	if callErr, ok := err.(*CallError); ok {
+		return callErr, len(callErr.Data) > 0
+	} else if _, ok := err.(interface{ ErrorCode() int }); !ok {
+		return nil, false
+	}
+	encoded, jsonErr := json.Marshal(err)
+	if jsonErr != nil {
+		return nil, false
+	}
+	var rpcErr struct {
+		Code    int         `json:"code"`
+		Message string      `json:"message"`
+		Data    interface{} `json:"data"`
+	}
+	if jsonErr := json.Unmarshal(encoded, &rpcErr); jsonErr != nil {
+		return nil, false
+	}
+	hexData, ok := rpcErr.Data.(string)
+	if !ok {
+		return nil, false
+	}
+	data, decodeErr := hexutil.Decode(hexData)
+	if decodeErr != nil {
+		return nil, false
+	}
+	return &CallError{
+		Code:    rpcErr.Code,
+		Message: rpcErr.Message,
+		Data:    data,
+	}, true
+}
+
+// EthCall runs eth_call on the client at the block, or the latest one if it's nil.
+// The node errors carrying data, as reverts do, are returned as *CallError.
+func EthCall(ctx context.Context, client *rpc.Client, msg ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	arg := map[string]interface{}{
+		"from": msg.From,
+		"to":   msg.To,
+	}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	if msg.GasPrice != nil {
+		arg["gasPrice"] = (*hexutil.Big)(msg.GasPrice)
+	}
+	blockArg := "latest"
+	if block != nil {
+		blockArg = hexutil.EncodeBig(block)
+	}
+	var result hexutil.Bytes
+	if err := client.CallContext(ctx, &result, "eth_call", arg, blockArg); err != nil {
+		if callErr, ok := AsCallError(err); ok {
+			return nil, callErr
+		}
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,228 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Cassette is a file of JSON-RPC calls recorded during a run, one call per line, which
+// can be replayed instead of a live node. Calls are matched by the method and the params,
+// the same call recorded many times is replayed in the order of recording.
+type Cassette struct {
+	path string
+
+	mux   *sync.Mutex
+	file  *os.File
+	calls map[string][]*cassetteCall
+}
+
+type cassetteCall struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+type rpcMessage struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// NewCassette creates the cassette file for recording, replacing the existing one.
+func NewCassette(path string) (*Cassette, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	cassette := &Cassette{
+		path: path,
+		mux:  new(sync.Mutex),
+		file: file,
+	}
+	return cassette, nil
+}
+
+// LoadCassette loads the recorded calls for replaying.
+func LoadCassette(path string) (*Cassette, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	cassette := &Cassette{
+		path:  path,
+		mux:   new(sync.Mutex),
+		calls: make(map[string][]*cassetteCall),
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var call *cassetteCall
+		if err := json.Unmarshal(scanner.Bytes(), &call); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		key := callKey(call.Method, call.Params)
+		cassette.calls[key] = append(cassette.calls[key], call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cassette, nil
+}
+
+// Replaying reports whether the cassette serves the calls instead of the nodes.
+func (c *Cassette) Replaying() bool {
+	return c.file == nil
+}
+
+// Record appends the calls of the request to the cassette, matching the responses by id,
+// both may be batches.
+func (c *Cassette) Record(reqBody, respBody []byte) {
+	requests, _ := rpcMessages(reqBody)
+	responses, _ := rpcMessages(respBody)
+	byID := make(map[string]*rpcMessage, len(responses))
+	for _, response := range responses {
+		byID[string(response.ID)] = response
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for _, request := range requests {
+		response, ok := byID[string(request.ID)]
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(&cassetteCall{
+			Method: request.Method,
+			Params: request.Params,
+			Result: response.Result,
+			Error:  response.Error,
+		})
+		if err != nil {
+			continue
+		}
+		c.file.Write(append(data, '\n'))
+	}
+}
+
+// replay returns the response to the request from the recorded calls, the last one of
+// the same call is repeated once the others have been replayed.
+func (c *Cassette) replay(reqBody []byte) ([]byte, error) {
+	requests, batch := rpcMessages(reqBody)
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("not a JSON-RPC request: %s", reqBody)
+	}
+	responses := make([]*rpcMessage, 0, len(requests))
+	c.mux.Lock()
+	for _, request := range requests {
+		response := &rpcMessage{
+			Version: "2.0",
+			ID:      request.ID,
+		}
+		key := callKey(request.Method, request.Params)
+		if calls := c.calls[key]; len(calls) > 0 {
+			call := calls[0]
+			if len(calls) > 1 {
+				c.calls[key] = calls[1:]
+			}
+			response.Result = call.Result
+			response.Error = call.Error
+		} else {
+			response.Error = json.RawMessage(fmt.Sprintf(`{"code":-32000,"message":%q}`,
+				"no recorded response to "+request.Method+" in "+c.path))
+		}
+		if len(response.Result) == 0 && len(response.Error) == 0 {
+			response.Result = json.RawMessage("null")
+		}
+		responses = append(responses, response)
+	}
+	c.mux.Unlock()
+	if batch {
+		return json.Marshal(responses)
+	}
+	return json.Marshal(responses[0])
+}
+
+// Close closes the recorded file.
+func (c *Cassette) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	return c.file.Close()
+}
+
+// rpcMessages parses a single JSON-RPC message or a batch, it reports whether it's a batch.
+func rpcMessages(body []byte) ([]*rpcMessage, bool) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var msgs []*rpcMessage
+		json.Unmarshal(body, &msgs)
+		return msgs, true
+	}
+	var msg *rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil || msg == nil {
+		return nil, false
+	}
+	return []*rpcMessage{msg}, false
+}
+
+// callKey is the method and the params in the canonical form, so the calls are matched
+// regardless of the formatting.
+func callKey(method string, params json.RawMessage) string {
+	var v interface{}
+	if err := json.Unmarshal(params, &v); err == nil {
+		if data, err := json.Marshal(v); err == nil {
+			params = data
+		}
+	}
+	return method + string(params)
+}
+
+// replayTransport serves the requests from the cassette.
+type replayTransport struct {
+	cassette *Cassette
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		data, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = data
+	}
+	data, err := t.cassette.replay(body)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResponse(req, data), nil
+}
+
+// jsonResponse is the response to the request served without calling the node.
+func jsonResponse(req *http.Request, data []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,198 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ccipMaxLookups is the most offchain lookups of a call, as recommended by EIP-3668.
+const ccipMaxLookups = 4
+
+// offchainLookupSelector is OffchainLookup(address,string[],bytes,bytes4,bytes) of EIP-3668.
+var offchainLookupSelector = common.FromHex("0x556f1830")
+
+type offchainLookup struct {
+	Sender    common.Address
+	URLs      []string
+	CallData  []byte
+	Callback  [4]byte
+	ExtraData []byte
+}
+
+// ccipCall calls the contract, following the offchain lookups it reverts with to the gateways
+// and calling the callback with their response, see EIP-3668 CCIP-Read.
+func ccipCall(ctx context.Context, client *rpc.Client, to common.Address, data []byte) ([]byte, error) {
+	for i := 0; i <= ccipMaxLookups; i++ {
+		result, err := ethCall(ctx, client, to, data)
+		if err == nil {
+			return result, nil
+		}
+		lookup, ok := decodeOffchainLookup(err)
+		if !ok {
+			return nil, err
+		} else if i == ccipMaxLookups {
+			return nil, errors.New("too many offchain lookups")
+		} else if lookup.Sender != to {
+			return nil, fmt.Errorf("offchain lookup of %s is sent by %s", to.Hex(), lookup.Sender.Hex())
+		}
+		response, err := lookup.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// callback(bytes response, bytes extraData)
+		data = append(lookup.Callback[:], abiWord(64)...)
+		encoded := abiBytes(response)
+		data = append(data, abiWord(uint64(64+len(encoded)))...)
+		data = append(append(data, encoded...), abiBytes(lookup.ExtraData)...)
+	}
+	return nil, errors.New("too many offchain lookups")
+}
+
+func decodeOffchainLookup(err error) (*offchainLookup, bool) {
+	callErr, ok := AsCallError(err)
+	if !ok {
+		return nil, false
+	}
+	data := callErr.Data
+	if len(data) < 4 || !bytes.Equal(data[:4], offchainLookupSelector) {
+		return nil, false
+	}
+	// the args are decoded by hand, as the string arrays are not unpacked right by the abi package
+	args := data[4:]
+	if len(args) < 5*32 {
+		return nil, false
+	}
+	lookup := &offchainLookup{
+		Sender: common.BytesToAddress(args[:32]),
+	}
+	copy(lookup.Callback[:], args[3*32:])
+	var ok1, ok2 bool
+	lookup.CallData, ok1 = abiDynamicBytes(args, abiOffset(args, 2*32))
+	lookup.ExtraData, ok2 = abiDynamicBytes(args, abiOffset(args, 4*32))
+	if !ok1 || !ok2 {
+		return nil, false
+	}
+	urls := abiOffset(args, 32)
+	count := abiOffset(args, urls)
+	if count < 0 || count > len(args)/32 {
+		return nil, false
+	}
+	for i := 0; i < count; i++ {
+		url, ok := abiDynamicBytes(args[urls+32:], abiOffset(args[urls+32:], 32*i))
+		if !ok {
+			return nil, false
+		}
+		lookup.URLs = append(lookup.URLs, string(url))
+	}
+	return lookup, len(lookup.URLs) > 0
+}
+
+// abiOffset returns the word at the position as an offset or a length, -1 if it's out of the data.
+func abiOffset(data []byte, pos int) int {
+	if pos < 0 || pos+32 > len(data) {
+		return -1
+	}
+	word := new(big.Int).SetBytes(data[pos : pos+32])
+	if !word.IsInt64() || word.Int64() > int64(len(data)) {
+		return -1
+	}
+	return int(word.Int64())
+}
+
+// abiDynamicBytes returns the bytes encoded at the offset.
+func abiDynamicBytes(data []byte, offset int) ([]byte, bool) {
+	length := abiOffset(data, offset)
+	if length < 0 || offset+32+length > len(data) {
+		return nil, false
+	}
+	return data[offset+32 : offset+32+length], true
+}
+
+// fetch queries the gateways in order until one responds: the URLs with {data} are queried
+// with GET, the others with POST of the sender and the data, 4xx errors are not retried.
+func (lookup *offchainLookup) fetch(ctx context.Context) ([]byte, error) {
+	sender := strings.ToLower(lookup.Sender.Hex())
+	callData := hexutil.Encode(lookup.CallData)
+	var lastErr error
+	for _, url := range lookup.URLs {
+		var req *http.Request
+		var err error
+		if strings.Contains(url, "{data}") {
+			url = strings.Replace(strings.Replace(url, "{sender}", sender, -1), "{data}", callData, -1)
+			req, err = http.NewRequest(http.MethodGet, url, nil)
+		} else {
+			url = strings.Replace(url, "{sender}", sender, -1)
+			body, _ := json.Marshal(map[string]string{
+				"sender": sender,
+				"data":   callData,
+			})
+			if req, err = http.NewRequest(http.MethodPost, url, bytes.NewReader(body)); err == nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp, err := httpClient.Do(req.WithContext(ctx))
+		if err != nil {
+			log.WithError(err).WithField("gateway", url).Debugln("offchain lookup failed")
+			lastErr = err
+			continue
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		} else if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("gateway responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				return nil, lastErr
+			}
+			continue
+		}
+		var response struct {
+			Data hexutil.Bytes `json:"data"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			lastErr = fmt.Errorf("malformed gateway response: %v", err)
+			continue
+		}
+		return response.Data, nil
+	}
+	return nil, lastErr
+}
+
+// abiWord encodes the uint as a word.
+func abiWord(n uint64) []byte {
+	return common.LeftPadBytes(new(big.Int).SetUint64(n).Bytes(), 32)
+}
+
+// abiBytes encodes the bytes as the tail of a dynamic arg, the length and the padded data.
+func abiBytes(data []byte) []byte {
+	padded := make([]byte, (len(data)+31)/32*32)
+	copy(padded, data)
+	return append(abiWord(uint64(len(data))), padded...)
+}
+
+// dnsEncode encodes the name in the DNS wire format, as the resolve of the wildcard resolvers takes it.
+func dnsEncode(name string) []byte {
+	var buf []byte
+	for _, label := range strings.Split(strings.ToLower(name), ".") {
+		buf = append(append(buf, byte(len(label))), label...)
+	}
+	return append(buf, 0)
+}
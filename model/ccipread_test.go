@@ -0,0 +1,203 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// resolveCall is resolve(bytes,bytes) of ENSIP-10 of the name eth with addr(bytes32) of its namehash,
+// the call the offchain resolvers of ENS pass to their gateways.
+var resolveCall = abiWords(
+	"9061b923",
+	"0000000000000000000000000000000000000000000000000000000000000040",
+	"0000000000000000000000000000000000000000000000000000000000000080",
+	"0000000000000000000000000000000000000000000000000000000000000005",
+	"0365746800000000000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000024",
+	"3b3b57de93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88",
+	"a93fc4ae00000000000000000000000000000000000000000000000000000000",
+)
+
+// resolveExtraData is abi.encode(callData, address(this)), the extra data of the offchain resolvers of ENS.
+var resolveExtraData = abiWords(
+	"0000000000000000000000000000000000000000000000000000000000000040",
+	"0000000000000000000000008464135c8f25da09e49bc8782676a84730c318bc",
+	"00000000000000000000000000000000000000000000000000000000000000e4",
+	"9061b92300000000000000000000000000000000000000000000000000000000",
+	"0000004000000000000000000000000000000000000000000000000000000000",
+	"0000008000000000000000000000000000000000000000000000000000000000",
+	"0000000503657468000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000000",
+	"000000243b3b57de93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f0469",
+	"0a0bcc88a93fc4ae000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000000",
+)
+
+// ensLookup is the revert data of an ENS offchain resolver pointing to its gateway
+// and calling back resolveWithProof(bytes,bytes).
+var ensLookup = abiWords(
+	"556f1830",
+	"0000000000000000000000008464135c8f25da09e49bc8782676a84730c318bc",
+	"00000000000000000000000000000000000000000000000000000000000000a0",
+	"0000000000000000000000000000000000000000000000000000000000000140",
+	"f4d4d2f800000000000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000260",
+	"0000000000000000000000000000000000000000000000000000000000000001",
+	"0000000000000000000000000000000000000000000000000000000000000020",
+	"0000000000000000000000000000000000000000000000000000000000000030",
+	"68747470733a2f2f676174657761792e6578616d706c652e636f6d2f7b73656e",
+	"6465727d2f7b646174617d2e6a736f6e00000000000000000000000000000000",
+	"00000000000000000000000000000000000000000000000000000000000000e4",
+	"9061b92300000000000000000000000000000000000000000000000000000000",
+	"0000004000000000000000000000000000000000000000000000000000000000",
+	"0000008000000000000000000000000000000000000000000000000000000000",
+	"0000000503657468000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000000",
+	"000000243b3b57de93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f0469",
+	"0a0bcc88a93fc4ae000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000160",
+	"0000000000000000000000000000000000000000000000000000000000000040",
+	"0000000000000000000000008464135c8f25da09e49bc8782676a84730c318bc",
+	"00000000000000000000000000000000000000000000000000000000000000e4",
+	"9061b92300000000000000000000000000000000000000000000000000000000",
+	"0000004000000000000000000000000000000000000000000000000000000000",
+	"0000008000000000000000000000000000000000000000000000000000000000",
+	"0000000503657468000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000000",
+	"000000243b3b57de93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f0469",
+	"0a0bcc88a93fc4ae000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000000",
+)
+
+// twoGatewaysLookup points to the gateways queried with GET and POST and has no extra data.
+var twoGatewaysLookup = abiWords(
+	"556f1830",
+	"0000000000000000000000008464135c8f25da09e49bc8782676a84730c318bc",
+	"00000000000000000000000000000000000000000000000000000000000000a0",
+	"00000000000000000000000000000000000000000000000000000000000001a0",
+	"1234567800000000000000000000000000000000000000000000000000000000",
+	"00000000000000000000000000000000000000000000000000000000000001e0",
+	"0000000000000000000000000000000000000000000000000000000000000002",
+	"0000000000000000000000000000000000000000000000000000000000000040",
+	"00000000000000000000000000000000000000000000000000000000000000a0",
+	"0000000000000000000000000000000000000000000000000000000000000025",
+	"68747470733a2f2f612e6578616d706c652e636f6d2f7b73656e6465727d2f7b",
+	"646174617d000000000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000016",
+	"68747470733a2f2f622e6578616d706c652e636f6d2f00000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000004",
+	"deadbeef00000000000000000000000000000000000000000000000000000000",
+	"0000000000000000000000000000000000000000000000000000000000000000",
+)
+
+func TestDecodeOffchainLookup(t *testing.T) {
+	sender := common.HexToAddress("0x8464135c8F25Da09e49BC8782676a84730C318bC")
+	tests := []struct {
+		name   string
+		err    error
+		lookup *offchainLookup
+	}{{
+		name: "ens offchain resolver",
+		err:  &CallError{Code: 3, Message: "execution reverted", Data: ensLookup},
+		lookup: &offchainLookup{
+			Sender:    sender,
+			URLs:      []string{"https://gateway.example.com/{sender}/{data}.json"},
+			CallData:  resolveCall,
+			Callback:  [4]byte{0xf4, 0xd4, 0xd2, 0xf8},
+			ExtraData: resolveExtraData,
+		},
+	}, {
+		name: "json-rpc error of the client",
+		err:  &rpcError{Code: 3, Message: "execution reverted", Data: common.ToHex(twoGatewaysLookup)},
+		lookup: &offchainLookup{
+			Sender:    sender,
+			URLs:      []string{"https://a.example.com/{sender}/{data}", "https://b.example.com/"},
+			CallData:  []byte{0xde, 0xad, 0xbe, 0xef},
+			Callback:  [4]byte{0x12, 0x34, 0x56, 0x78},
+			ExtraData: []byte{},
+		},
+	}, {
+		name: "not a json-rpc error",
+		err:  errors.New("execution reverted"),
+	}, {
+		name: "no revert data",
+		err:  &CallError{Code: -32000, Message: "execution reverted"},
+	}, {
+		name: "revert reason",
+		err: &CallError{Code: 3, Message: "execution reverted: no resolver", Data: abiWords(
+			"08c379a0",
+			"0000000000000000000000000000000000000000000000000000000000000020",
+			"000000000000000000000000000000000000000000000000000000000000000b",
+			"6e6f207265736f6c766572000000000000000000000000000000000000000000",
+		)},
+	}, {
+		name: "truncated",
+		err:  &CallError{Code: 3, Data: ensLookup[:4+6*32]},
+	}, {
+		name: "url count out of the data",
+		err:  &CallError{Code: 3, Data: replaceWord(twoGatewaysLookup, 5, "00000000000000000000000000000000000000000000000000000000000000ff")},
+	}, {
+		name: "callData offset out of the data",
+		err:  &CallError{Code: 3, Data: replaceWord(twoGatewaysLookup, 2, "0000000000000000000000000000000000000000000000000000000000001000")},
+	}, {
+		name: "no urls",
+		err: &CallError{Code: 3, Data: abiWords(
+			"556f1830",
+			"0000000000000000000000008464135c8f25da09e49bc8782676a84730c318bc",
+			"00000000000000000000000000000000000000000000000000000000000000a0",
+			"00000000000000000000000000000000000000000000000000000000000000c0",
+			"1234567800000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000100",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000004",
+			"deadbeef00000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+		)},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup, ok := decodeOffchainLookup(tt.err)
+			if tt.lookup == nil {
+				if ok {
+					t.Fatalf("decoded an offchain lookup %+v", lookup)
+				}
+				return
+			} else if !ok {
+				t.Fatal("failed to decode the offchain lookup")
+			} else if !reflect.DeepEqual(lookup, tt.lookup) {
+				t.Fatalf("got lookup %+v, want %+v", lookup, tt.lookup)
+			}
+		})
+	}
+}
+
+// rpcError is the JSON-RPC error as the rpc client returns it, with the data in an exported field.
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (err *rpcError) Error() string {
+	return err.Message
+}
+
+func (err *rpcError) ErrorCode() int {
+	return err.Code
+}
+
+func abiWords(words ...string) []byte {
+	return common.FromHex(strings.Join(words, ""))
+}
+
+// replaceWord returns a copy of the revert data with the word of the args at the index replaced.
+func replaceWord(data []byte, index int, word string) []byte {
+	replaced := append([]byte{}, data...)
+	copy(replaced[4+32*index:], common.FromHex(word))
+	return replaced
+}
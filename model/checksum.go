@@ -0,0 +1,68 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// specAddressRx matches the hex addresses in the spec text, longer hex strings like hashes don't match.
+var specAddressRx = regexp.MustCompile(`\b0x[0-9a-fA-F]{40}\b`)
+
+// ChecksumError is a mixed-case address failing the EIP-55 checksum, most likely mistyped,
+// as the lowercase and the uppercase addresses have no checksum at all.
+type ChecksumError struct {
+	Line    int
+	Address string
+}
+
+func (err *ChecksumError) Error() string {
+	return fmt.Sprintf("%d: address %s fails the EIP-55 checksum, check it for typos", err.Line, err.Address)
+}
+
+// CheckChecksums returns the mixed-case addresses of the spec text failing the checksum, the comments are skipped.
+func CheckChecksums(data []byte) []*ChecksumError {
+	var errs []*ChecksumError
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("#")) {
+			continue
+		}
+		for _, match := range specAddressRx.FindAll(line, -1) {
+			address := string(match)
+			if isMixedCase(address[2:]) && common.HexToAddress(address).Hex() != address {
+				errs = append(errs, &ChecksumError{
+					Line:    i + 1,
+					Address: address,
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// FixChecksums rewrites the lowercase and uppercase addresses of the spec text in the checksummed
+// form, the formatting and comments are kept. The addresses failing the checksum are left as-is,
+// as only a human can tell the right one. It returns the number of the addresses rewritten.
+func FixChecksums(data []byte) ([]byte, int) {
+	var fixed int
+	data = specAddressRx.ReplaceAllFunc(data, func(match []byte) []byte {
+		address := string(match)
+		if isMixedCase(address[2:]) {
+			return match
+		}
+		checksummed := common.HexToAddress(address).Hex()
+		if checksummed == address {
+			return match
+		}
+		fixed++
+		return []byte(checksummed)
+	})
+	return data, fixed
+}
+
+func isMixedCase(hex string) bool {
+	return strings.ContainsAny(hex, "abcdef") && strings.ContainsAny(hex, "ABCDEF")
+}
@@ -1,9 +1,13 @@
 package model
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/yaml"
 )
 
 type CallCmds map[string]*CallCmdSpec
@@ -40,9 +44,32 @@ type CallCmdSpec struct {
 
 	Wallet string `yaml:"wallet"`
 	Method string `yaml:"method"`
+	At     string `yaml:"at"`
+
+	// Needs lists the commands of the target this one depends on.
+	Needs []string `yaml:"needs"`
+
+	// When is the condition to run the command, it's skipped otherwise.
+	When string `yaml:"when"`
+
+	// OnFailure is the compensating command, run if a later command of the target fails.
+	OnFailure string `yaml:"onFailure"`
+
+	// Confirm requires an approval before the command is run.
+	Confirm bool `yaml:"confirm"`
+
+	// Foreach repeats the command for every row of the data source.
+	Foreach *ForeachSpec `yaml:"foreach"`
 
-	walletRx *regexp.Regexp `yaml:"-"`
-	matching []*WalletSpec  `yaml:"-"`
+	// Concurrency is the number of matching wallets to run the command for in parallel.
+	Concurrency int `yaml:"concurrency"`
+
+	walletRx   *regexp.Regexp `yaml:"-"`
+	namespace  string         `yaml:"-"`
+	matching   []*WalletSpec  `yaml:"-"`
+	block      *BlockRef      `yaml:"-"`
+	condition  *Expression    `yaml:"-"`
+	iterations []*CallCmdSpec `yaml:"-"`
 }
 
 func (spec *CallCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool {
@@ -50,6 +77,9 @@ func (spec *CallCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 		"section": "CallCommands",
 		"command": name,
 	})
+	if spec.Foreach != nil {
+		return spec.validateForeach(ctx, name, root, validateLog)
+	}
 	var hasWalletName bool
 	if len(spec.Wallet) > 0 {
 		if isWalletRef(spec.Wallet) {
@@ -66,7 +96,7 @@ func (spec *CallCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 	spec.walletRx = rx
 
 	if hasWalletName {
-		spec.matching = root.Wallets.GetAll(spec.walletRx)
+		spec.matching = root.Wallets.inNamespace(spec.namespace).GetAll(spec.walletRx)
 		if len(spec.matching) == 0 {
 			validateLog.Errorln("no wallets are matching the specified regexp")
 			return false
@@ -75,19 +105,113 @@ func (spec *CallCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 	if len(spec.Method) == 0 {
 		validateLog.Errorln("no method name is specified")
 		return false
+	} else if spec.Method == PendingMethod && !hasWalletName {
+		validateLog.Errorln("pending transactions are listed for wallets, 'wallet' must be specified")
+		return false
+	} else if spec.Method == PendingMethod && len(spec.Params) > 0 {
+		validateLog.Errorln("pending method has no params")
+		return false
+	}
+	if len(spec.Args) > 0 {
+		validateLog.Errorln("named args are supported only for contract methods, use params")
+		return false
 	}
 	if !spec.ParamSpec.Validate(ctx, name, root) {
 		return false
 	}
+	if len(spec.At) > 0 {
+		offset, ok := blockParamOffsets[spec.Method]
+		if !ok {
+			validateLog.WithField("method", spec.Method).Errorln("method doesn't accept the block param, remove 'at' field")
+			return false
+		} else if len(spec.Params) != offset {
+			validateLog.Errorln("the block param is set by 'at' field, remove it from params")
+			return false
+		}
+		block, err := ParseBlockRef(spec.At)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to parse block in 'at' field")
+			return false
+		}
+		spec.block = block
+	}
+	condition, err := validateCondition(ctx, root, spec.When)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to parse when condition")
+		return false
+	}
+	spec.condition = condition
 	return true
 }
 
+// Block returns the block to append as the method param, if specified.
+func (spec *CallCmdSpec) Block() *BlockRef {
+	return spec.block
+}
+
+// PendingMethod lists the pending transactions of the wallets, with their nonces and fees, instead of calling an RPC method.
+const PendingMethod = "pending"
+
+// readOnlyMethodPrefixes are the RPC namespaces not changing the node state,
+// except for the methods sending or signing transactions.
+var readOnlyMethodPrefixes = []string{"eth_", "net_", "web3_", "txpool_", "debug_trace"}
+
+// IsReadOnly reports whether the RPC method only reads the chain state, so it's safe to run in plan mode.
+func (spec *CallCmdSpec) IsReadOnly() bool {
+	if spec.Method == PendingMethod {
+		return true
+	} else if strings.HasPrefix(spec.Method, "eth_send") || strings.HasPrefix(spec.Method, "eth_sign") {
+		return false
+	}
+	for _, prefix := range readOnlyMethodPrefixes {
+		if strings.HasPrefix(spec.Method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 func (spec *CallCmdSpec) MatchingWallets() []*WalletSpec {
 	return spec.matching
 }
 
+func (spec *CallCmdSpec) validateForeach(ctx AppContext, name string, root *Spec, validateLog *log.Entry) bool {
+	cmd := *spec
+	cmd.Foreach = nil
+	docs, err := expandForeach(ctx, root, spec.Foreach, &cmd)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to expand foreach")
+		return false
+	}
+	spec.iterations = make([]*CallCmdSpec, 0, len(docs))
+	for i, doc := range docs {
+		var iteration *CallCmdSpec
+		if err := yaml.Unmarshal(doc, &iteration); err != nil {
+			validateLog.WithField("row", i).WithError(err).Errorln("failed to parse rendered command spec")
+			return false
+		}
+		iteration.namespace = spec.namespace
+		if !iteration.Validate(ctx, fmt.Sprintf("%s[%d]", name, i), root) {
+			return false
+		}
+		spec.iterations = append(spec.iterations, iteration)
+	}
+	return true
+}
+
+// Iterations returns the commands rendered for each row of foreach.
+func (spec *CallCmdSpec) Iterations() []*CallCmdSpec {
+	return spec.iterations
+}
+
+// Condition returns the parsed when condition, nil means the command always runs.
+func (spec *CallCmdSpec) Condition() *Expression {
+	return spec.condition
+}
+
 func (spec *CallCmdSpec) CountArgsUsing(set map[int]struct{}) {
 	spec.ParamSpec.CountArgsUsing(set)
+	countConditionArgs(spec.When, set)
 }
 
 func (spec *CallCmdSpec) ArgCount() int {
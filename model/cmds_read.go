@@ -1,10 +1,12 @@
 package model
 
 import (
+	"fmt"
 	"regexp"
-	"strings"
 
 	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/yaml"
 )
 
 type ViewCmds map[string]*ViewCmdSpec
@@ -42,10 +44,56 @@ type ViewCmdSpec struct {
 	Wallet string `yaml:"wallet"`
 	Method string `yaml:"method"`
 
+	// Kind is the high-level token command, token-allowance of the Token
+	// given to the Spender, which is expanded into the method call.
+	Kind    CommandKind `yaml:"kind"`
+	Token   string      `yaml:"token"`
+	Spender string      `yaml:"spender"`
+
+	// NFT is the collection of nft-owner and nft-balance, the balance of ERC-1155 is of the TokenID.
+	NFT     string `yaml:"nft"`
+	TokenID string `yaml:"tokenId"`
+
+	// Subgraph is the config subgraph the Query of graphql is posted to, with the args as variables,
+	// Select maps the result fields to the dotted paths of the response data.
+	Subgraph string            `yaml:"subgraph"`
+	Query    string            `yaml:"query"`
+	Select   map[string]string `yaml:"select"`
+
 	Instance *ContractInstanceSpec `yaml:"instance"`
 
-	walletRx *regexp.Regexp `yaml:"-"`
-	matching []*WalletSpec  `yaml:"-"`
+	Overrides map[string]*StateOverrideSpec `yaml:"overrides"`
+	At        string                        `yaml:"at"`
+	Enumerate *EnumerateSpec                `yaml:"enumerate"`
+	Transform []interface{}                 `yaml:"transform"`
+	Expect    *ExpectSpec                   `yaml:"expect"`
+
+	// Needs lists the commands of the target this one depends on.
+	Needs []string `yaml:"needs"`
+
+	// When is the condition to run the command, it's skipped otherwise.
+	When string `yaml:"when"`
+
+	// OnFailure is the compensating command, run if a later command of the target fails.
+	OnFailure string `yaml:"onFailure"`
+
+	// Foreach repeats the command for every row of the data source.
+	Foreach *ForeachSpec `yaml:"foreach"`
+
+	// Concurrency is the number of matching wallets to run the command for in parallel.
+	Concurrency int `yaml:"concurrency"`
+
+	walletRx   *regexp.Regexp   `yaml:"-"`
+	namespace  string           `yaml:"-"`
+	token      *TokenSpec       `yaml:"-"`
+	nft        *NFTSpec         `yaml:"-"`
+	matching   []*WalletSpec    `yaml:"-"`
+	overrides  StateOverrides   `yaml:"-"`
+	block      *BlockRef        `yaml:"-"`
+	transforms []*TransformStep `yaml:"-"`
+	condition  *Expression      `yaml:"-"`
+	iterations []*ViewCmdSpec   `yaml:"-"`
+	variables  []string         `yaml:"-"`
 }
 
 func (spec *ViewCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool {
@@ -53,6 +101,11 @@ func (spec *ViewCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 		"section": "ViewCommands",
 		"command": name,
 	})
+	if spec.Foreach != nil {
+		return spec.validateForeach(ctx, name, root, validateLog)
+	} else if spec.Kind == KindGraphQL {
+		return spec.validateGraphQL(ctx, name, root, validateLog)
+	}
 	var hasWalletName bool
 	if len(spec.Wallet) > 0 {
 		if isWalletRef(spec.Wallet) {
@@ -69,12 +122,107 @@ func (spec *ViewCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 	spec.walletRx = rx
 
 	if hasWalletName {
-		spec.matching = root.Wallets.GetAll(spec.walletRx)
+		spec.matching = root.Wallets.inNamespace(spec.namespace).GetAll(spec.walletRx)
 		if len(spec.matching) == 0 {
 			validateLog.Errorln("no wallets are matching the specified regexp")
 			return false
 		}
 	}
+	if len(spec.Kind) > 0 {
+		if !spec.expandKind(validateLog, root) {
+			return false
+		}
+	} else if !spec.validateInstance(validateLog, root) {
+		return false
+	}
+	if len(spec.Method) == 0 {
+		validateLog.Errorln("no method name is specified")
+		return false
+	}
+	methodName, err := root.Contracts.resolveMethod(spec.Instance, spec.Method)
+	if err != nil {
+		validateLog.WithField("method", spec.Method).WithError(err).Errorln("failed to resolve method")
+		return false
+	}
+	spec.Method = methodName
+	method, ok := spec.Instance.BoundContract().ABI().Methods[spec.Method]
+	if !ok {
+		validateLog.WithField("method", spec.Method).Errorln("method not found in contract ABI")
+		return false
+	}
+	inputs := method.Inputs
+	tuple, hasTuples := spec.Instance.tupleMethod(spec.Method)
+	if spec.Enumerate != nil {
+		if len(spec.matching) > 0 {
+			validateLog.Errorln("enumerate can't be used with the wallet field")
+			return false
+		} else if hasTuples {
+			validateLog.Errorln("enumerate can't be used with the methods having struct params")
+			return false
+		}
+		if inputs, err = spec.Enumerate.validate(root, spec.Instance, method); err != nil {
+			validateLog.WithField("method", spec.Method).WithError(err).Errorln("invalid enumerate spec")
+			return false
+		}
+	}
+	namedInputs := abiMethodInputs(inputs)
+	if hasTuples {
+		namedInputs = tuple.namedInputs()
+	}
+	if err := spec.ParamSpec.resolveNamedArgs(namedInputs); err != nil {
+		validateLog.WithError(err).Errorln("failed to resolve named args")
+		return false
+	}
+	if !spec.ParamSpec.Validate(ctx, name, root) {
+		return false
+	}
+	if hasTuples {
+		err = spec.ParamSpec.validateTupleABI(tuple)
+	} else {
+		err = spec.ParamSpec.validateABI(inputs)
+	}
+	if err != nil {
+		validateLog.WithField("method", spec.Method).WithError(err).Errorln("params don't match the method ABI")
+		return false
+	}
+	if len(spec.Overrides) > 0 {
+		overrides, err := parseStateOverrides(root, spec.Overrides)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to parse state overrides")
+			return false
+		}
+		spec.overrides = overrides
+	}
+	if len(spec.At) > 0 {
+		block, err := ParseBlockRef(spec.At)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to parse block in 'at' field")
+			return false
+		}
+		spec.block = block
+	}
+	if len(spec.Transform) > 0 {
+		transforms, err := parseTransforms(root, spec.Transform)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to parse result transforms")
+			return false
+		}
+		spec.transforms = transforms
+	}
+	if spec.Expect != nil && !spec.Expect.Validate(ctx, name, root) {
+		return false
+	}
+	condition, err := validateCondition(ctx, root, spec.When)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to parse when condition")
+		return false
+	}
+	spec.condition = condition
+	return true
+}
+
+// validateInstance finds the target instance among the instances of the contract spec.
+func (spec *ViewCmdSpec) validateInstance(validateLog *log.Entry, root *Spec) bool {
 	if spec.Instance == nil {
 		validateLog.Errorln("no target contract instance specified")
 		return false
@@ -90,13 +238,13 @@ func (spec *ViewCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 		validateLog.Errorln("the target contract spec has no instances")
 		return false
 	}
-	address := strings.ToLower(spec.Instance.Address)
+	address := spec.Instance.Address
 	if len(address) == 0 {
 		spec.Instance = contract.Instances[0]
 	} else {
 		var found bool
 		for _, instance := range contract.Instances {
-			if strings.ToLower(instance.Address) == address {
+			if instance.MatchesAddress(address) {
 				found = true
 				spec.Instance = instance
 				break
@@ -107,22 +255,66 @@ func (spec *ViewCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 			return false
 		}
 	}
-	if len(spec.Method) == 0 {
-		validateLog.Errorln("no method name is specified")
+	return true
+}
+
+// Block returns the block to make the call at, nil means the latest one.
+func (spec *ViewCmdSpec) Block() *BlockRef {
+	return spec.block
+}
+
+// Transforms returns the steps to apply to the view result, in order.
+func (spec *ViewCmdSpec) Transforms() []*TransformStep {
+	return spec.transforms
+}
+
+// StateOverrides returns the account state overrides to apply for the call.
+func (spec *ViewCmdSpec) StateOverrides() StateOverrides {
+	return spec.overrides
+}
+
+func (spec *ViewCmdSpec) MatchingWallets() []*WalletSpec {
+	return spec.matching
+}
+
+func (spec *ViewCmdSpec) validateForeach(ctx AppContext, name string, root *Spec, validateLog *log.Entry) bool {
+	cmd := *spec
+	cmd.Foreach = nil
+	docs, err := expandForeach(ctx, root, spec.Foreach, &cmd)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to expand foreach")
 		return false
 	}
-	if !spec.ParamSpec.Validate(ctx, name, root) {
-		return false
+	spec.iterations = make([]*ViewCmdSpec, 0, len(docs))
+	for i, doc := range docs {
+		var iteration *ViewCmdSpec
+		if err := yaml.Unmarshal(doc, &iteration); err != nil {
+			validateLog.WithField("row", i).WithError(err).Errorln("failed to parse rendered command spec")
+			return false
+		}
+		iteration.namespace = spec.namespace
+		if !iteration.Validate(ctx, fmt.Sprintf("%s[%d]", name, i), root) {
+			return false
+		}
+		spec.iterations = append(spec.iterations, iteration)
 	}
 	return true
 }
 
-func (spec *ViewCmdSpec) MatchingWallets() []*WalletSpec {
-	return spec.matching
+// Iterations returns the commands rendered for each row of foreach.
+func (spec *ViewCmdSpec) Iterations() []*ViewCmdSpec {
+	return spec.iterations
+}
+
+// Condition returns the parsed when condition, nil means the command always runs.
+func (spec *ViewCmdSpec) Condition() *Expression {
+	return spec.condition
 }
 
 func (spec *ViewCmdSpec) CountArgsUsing(set map[int]struct{}) {
 	spec.ParamSpec.CountArgsUsing(set)
+	countConditionArgs(spec.When, set)
+	countKindArgs(set, spec.TokenID)
 }
 
 func (spec *ViewCmdSpec) ArgCount() int {
@@ -1,10 +1,14 @@
 package model
 
 import (
+	"fmt"
 	"regexp"
-	"strings"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/yaml"
 )
 
 type WriteCmds map[string]*WriteCmdSpec
@@ -45,10 +49,69 @@ type WriteCmdSpec struct {
 	Value  Valuer `yaml:"value"`
 	Method string `yaml:"method"`
 
+	// Kind is the high-level token command, token-transfer or token-approve
+	// of the Token, which is expanded into the method call.
+	Kind    CommandKind `yaml:"kind"`
+	Token   string      `yaml:"token"`
+	Spender string      `yaml:"spender"`
+
+	// NFT is the collection of nft-transfer and nft-batch-transfer, the amounts
+	// are of ERC-1155 tokens, data is passed to the receiver hook.
+	NFT      string   `yaml:"nft"`
+	TokenID  string   `yaml:"tokenId"`
+	TokenIDs []string `yaml:"tokenIds"`
+	Amount   string   `yaml:"amount"`
+	Amounts  []string `yaml:"amounts"`
+	Data     string   `yaml:"data"`
+
+	// GasLimit of the transaction, if not set it's estimated and capped by the config gasLimit.
+	GasLimit uint64 `yaml:"gasLimit"`
+
+	// Needs lists the commands of the target this one depends on.
+	Needs []string `yaml:"needs"`
+
+	// When is the condition to run the command, it's skipped otherwise.
+	When string `yaml:"when"`
+
+	// OnFailure is the compensating command, run if a later command of the target fails.
+	OnFailure string `yaml:"onFailure"`
+
+	// Confirm requires an approval before the command is run.
+	Confirm bool `yaml:"confirm"`
+
+	// ExpectEvents are the events the awaited transactions must emit, or the target fails.
+	ExpectEvents []string `yaml:"expectEvents"`
+
+	// Foreach repeats the command for every row of the data source.
+	Foreach *ForeachSpec `yaml:"foreach"`
+
 	Instance *ContractInstanceSpec `yaml:"instance"`
 
-	walletRx *regexp.Regexp `yaml:"-"`
-	matching *WalletSpec    `yaml:"-"`
+	// Clone deploys EIP-1167 minimal proxies of the instance, Count times.
+	Clone *ContractInstanceSpec `yaml:"clone"`
+	Count int                   `yaml:"count"`
+
+	// Ownership transfers or renounces the ownership of Ownable
+	// or AccessControl instance, detected from the ABI.
+	Ownership OwnershipAction `yaml:"ownership"`
+	NewOwner  string          `yaml:"newOwner"`
+	Role      string          `yaml:"role"`
+
+	// Concurrency sends the transaction from every matching wallet,
+	// instead of the sticky one, using the pool of Concurrency workers.
+	Concurrency int `yaml:"concurrency"`
+
+	walletRx       *regexp.Regexp      `yaml:"-"`
+	namespace      string              `yaml:"-"`
+	token          *TokenSpec          `yaml:"-"`
+	nft            *NFTSpec            `yaml:"-"`
+	matching       *WalletSpec         `yaml:"-"`
+	fanout         []*WalletSpec       `yaml:"-"`
+	ownershipCalls []*MethodCall       `yaml:"-"`
+	condition      *Expression         `yaml:"-"`
+	valueExpr      *Expression         `yaml:"-"`
+	iterations     []*WriteCmdSpec     `yaml:"-"`
+	expectEvents   []*EventExpectation `yaml:"-"`
 }
 
 func (spec *WriteCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool {
@@ -56,6 +119,9 @@ func (spec *WriteCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 		"section": "WriteCommands",
 		"command": name,
 	})
+	if spec.Foreach != nil {
+		return spec.validateForeach(ctx, name, root, validateLog)
+	}
 	var hasWalletName bool
 	if len(spec.Wallet) > 0 {
 		if isWalletRef(spec.Wallet) {
@@ -77,7 +143,7 @@ func (spec *WriteCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 	if len(spec.Sticky) == 0 {
 		spec.Sticky = name
 	}
-	spec.matching = root.Wallets.GetOne(spec.walletRx, spec.Sticky)
+	spec.matching = root.Wallets.inNamespace(spec.namespace).GetOne(spec.walletRx, spec.Sticky)
 	if hasWalletName {
 		if spec.matching == nil {
 			validateLog.Errorln("no wallets are matching the specified regexp")
@@ -87,7 +153,32 @@ func (spec *WriteCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 		validateLog.Errorln("no wallets specified to send from")
 		return false
 	}
-	if len(spec.To) == 0 {
+	if len(spec.Kind) > 0 && !spec.expandKind(validateLog, root) {
+		return false
+	}
+	if spec.Clone != nil {
+		if len(spec.To) > 0 || spec.Instance != nil {
+			validateLog.Errorln("clone must not be combined with recipient 'to' or 'instance'")
+			return false
+		} else if len(spec.Value) > 0 || len(spec.Params) > 0 {
+			validateLog.Errorln("clone accepts no value nor params, minimal proxies have no constructor")
+			return false
+		}
+		instance, err := root.Contracts.FindInstance(spec.Clone)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to find the implementation to clone")
+			return false
+		}
+		spec.Clone = instance
+		if spec.Count < 0 {
+			validateLog.Errorln("clone count must be positive")
+			return false
+		} else if spec.Count == 0 {
+			spec.Count = 1
+		}
+	} else if spec.Kind == KindTokenApprove || spec.nft != nil {
+		// the instance is set by the kind
+	} else if len(spec.To) == 0 {
 		if spec.Instance == nil {
 			validateLog.Errorln("no recipient contract instance specified")
 			return false
@@ -103,13 +194,13 @@ func (spec *WriteCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 			validateLog.Errorln("the recipient contract spec has no instances")
 			return false
 		}
-		address := strings.ToLower(spec.Instance.Address)
+		address := spec.Instance.Address
 		if len(address) == 0 {
 			spec.Instance = contract.Instances[0]
 		} else {
 			var found bool
 			for _, instance := range contract.Instances {
-				if strings.ToLower(instance.Address) == address {
+				if instance.MatchesAddress(address) {
 					found = true
 					spec.Instance = instance
 					break
@@ -120,7 +211,6 @@ func (spec *WriteCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 				return false
 			}
 		}
-		// TODO: check ABI
 	} else if spec.Instance != nil {
 		validateLog.Errorln("contract instance must not be specified while using recipient 'to' address")
 		return false
@@ -131,8 +221,21 @@ func (spec *WriteCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 		}
 		if spec.To != ZeroAddress {
 			if wallet, ok := root.Wallets.WalletSpec(spec.To); !ok {
-				validateLog.Errorln("recipient 'to' wallet name is not found")
-				return false
+				if address, ok := root.AddressBook.Address(spec.To); ok {
+					spec.To = address.Hex()
+				} else if spec.Kind == KindTokenTransfer && common.IsHexAddress(spec.To) {
+					// tokens are sent to any address
+				} else if spec.Kind == KindTokenTransfer && isENSName(spec.To) {
+					address, err := root.ResolveENS(ctx, spec.To)
+					if err != nil {
+						validateLog.WithField("ens", spec.To).WithError(err).Errorln("failed to resolve recipient ENS name")
+						return false
+					}
+					spec.To = address.Hex()
+				} else {
+					validateLog.Errorln("recipient 'to' wallet name or label is not found")
+					return false
+				}
 			} else if wallet.Address == "" || wallet.Address == ZeroAddress {
 				validateLog.Errorln("recipient 'to' wallet has no address. For 0x0, use '0x0' instead of name")
 				return false
@@ -141,19 +244,221 @@ func (spec *WriteCmdSpec) Validate(ctx AppContext, name string, root *Spec) bool
 			}
 		}
 	}
+	if spec.Instance != nil && len(spec.Method) > 0 {
+		method, err := root.Contracts.resolveMethod(spec.Instance, spec.Method)
+		if err != nil {
+			validateLog.WithField("method", spec.Method).WithError(err).Errorln("failed to resolve method")
+			return false
+		}
+		spec.Method = method
+		if _, ok := spec.Instance.BoundContract().ABI().Methods[spec.Method]; !ok {
+			validateLog.WithField("method", spec.Method).Errorln("method not found in contract ABI")
+			return false
+		}
+	}
+	if len(spec.Ownership) > 0 && !spec.validateOwnership(validateLog, root) {
+		return false
+	}
+	if len(spec.Args) > 0 && !spec.validateNamedArgs(validateLog) {
+		return false
+	}
 	if !spec.ParamSpec.Validate(ctx, name, root) {
 		return false
 	}
+	if inputs, ok := spec.abiInputs(); ok {
+		var err error
+		if tuple, ok := spec.Instance.tupleMethod(spec.Method); ok {
+			err = spec.ParamSpec.validateTupleABI(tuple)
+		} else {
+			err = spec.ParamSpec.validateABI(inputs)
+		}
+		if err != nil {
+			validateLog.WithField("method", spec.Method).WithError(err).Errorln("params don't match the method ABI")
+			return false
+		}
+	}
+	if spec.Concurrency < 0 {
+		validateLog.Errorln("concurrency must not be negative")
+		return false
+	} else if spec.Concurrency > 0 {
+		if spec.Clone != nil || len(spec.Ownership) > 0 {
+			validateLog.Errorln("concurrency can't be used with clone or ownership helpers")
+			return false
+		} else if spec.Instance != nil && !spec.Instance.IsDeployed() {
+			validateLog.Errorln("concurrency can't be used to deploy an instance")
+			return false
+		}
+		spec.fanout = root.Wallets.inNamespace(spec.namespace).GetAll(spec.walletRx)
+	}
+	if len(spec.ExpectEvents) > 0 {
+		if err := spec.validateExpectEvents(ctx, root); err != nil {
+			validateLog.WithError(err).Errorln("invalid expected event")
+			return false
+		} else if ctx.AppCommand() == name {
+			validateLog.Warningln("expected events are checked in targets only, where the transactions are awaited")
+		}
+	}
+	condition, err := validateCondition(ctx, root, spec.When)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to parse when condition")
+		return false
+	}
+	spec.condition = condition
+	if expr, ok := spec.Value.expression(root); ok {
+		valueExpr, err := parseExpression(ctx, root, expr)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to parse value expression")
+			return false
+		}
+		spec.valueExpr = valueExpr
+	}
+	return true
+}
+
+// FanoutWallets returns all matching wallets to send from, when concurrency is set.
+func (spec *WriteCmdSpec) FanoutWallets() []*WalletSpec {
+	return spec.fanout
+}
+
+// abiInputs returns the inputs of the called method, or the constructor if the instance is going
+// to be deployed. Ether and token transfers, clones and ownership helpers have no params to check.
+func (spec *WriteCmdSpec) abiInputs() (abi.Arguments, bool) {
+	if spec.Instance == nil || spec.Clone != nil || len(spec.Ownership) > 0 {
+		return nil, false
+	}
+	contractABI := spec.Instance.BoundContract().ABI()
+	if len(spec.Method) > 0 {
+		method, ok := contractABI.Methods[spec.Method]
+		if !ok {
+			return nil, false
+		}
+		return method.Inputs, true
+	} else if !spec.Instance.IsDeployed() {
+		return contractABI.Constructor.Inputs, true
+	}
+	return nil, false
+}
+
+func (spec *WriteCmdSpec) validateNamedArgs(validateLog *log.Entry) bool {
+	if spec.Instance == nil {
+		validateLog.Errorln("named args require a contract instance")
+		return false
+	}
+	contractABI := spec.Instance.BoundContract().ABI()
+	inputs := abiMethodInputs(contractABI.Constructor.Inputs)
+	if len(spec.Method) > 0 {
+		method, ok := contractABI.Methods[spec.Method]
+		if !ok {
+			validateLog.WithField("method", spec.Method).Errorln("method not found in contract ABI")
+			return false
+		}
+		inputs = abiMethodInputs(method.Inputs)
+	}
+	if tuple, ok := spec.Instance.tupleMethod(spec.Method); ok {
+		inputs = tuple.namedInputs()
+	}
+	if err := spec.ParamSpec.resolveNamedArgs(inputs); err != nil {
+		validateLog.WithError(err).Errorln("failed to resolve named args")
+		return false
+	}
+	return true
+}
+
+func (spec *WriteCmdSpec) validateOwnership(validateLog *log.Entry, root *Spec) bool {
+	if spec.Instance == nil {
+		validateLog.Errorln("ownership helpers require a contract instance")
+		return false
+	} else if len(spec.Method) > 0 || len(spec.Params) > 0 {
+		validateLog.Errorln("ownership helpers must not have method nor params specified")
+		return false
+	}
+	var newOwner common.Address
+	switch spec.Ownership {
+	case OwnershipTransfer:
+		if len(spec.NewOwner) == 0 {
+			validateLog.Errorln("no newOwner specified to transfer the ownership to")
+			return false
+		}
+		if wallet, ok := root.Wallets.WalletSpec(spec.NewOwner); ok {
+			newOwner = common.HexToAddress(wallet.Address)
+		} else if common.IsHexAddress(spec.NewOwner) {
+			newOwner = common.HexToAddress(spec.NewOwner)
+		} else {
+			validateLog.Errorln("newOwner must be a wallet name or an address")
+			return false
+		}
+	case OwnershipRenounce:
+		if len(spec.NewOwner) > 0 {
+			validateLog.Errorln("newOwner must not be specified while renouncing the ownership")
+			return false
+		}
+	default:
+		validateLog.WithField("ownership", spec.Ownership).Errorln("unknown ownership action (transfer or renounce)")
+		return false
+	}
+	calls, err := ownershipCalls(spec.Instance.BoundContract().ABI(), spec.Ownership, newOwner, spec.Role)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to prepare ownership calls")
+		return false
+	}
+	spec.ownershipCalls = calls
 	return true
 }
 
+func (spec *WriteCmdSpec) OwnershipCalls() []*MethodCall {
+	return spec.ownershipCalls
+}
+
 func (spec *WriteCmdSpec) MatchingWallet() *WalletSpec {
 	return spec.matching
 }
 
+func (spec *WriteCmdSpec) validateForeach(ctx AppContext, name string, root *Spec, validateLog *log.Entry) bool {
+	cmd := *spec
+	cmd.Foreach = nil
+	docs, err := expandForeach(ctx, root, spec.Foreach, &cmd)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to expand foreach")
+		return false
+	}
+	spec.iterations = make([]*WriteCmdSpec, 0, len(docs))
+	for i, doc := range docs {
+		var iteration *WriteCmdSpec
+		if err := yaml.Unmarshal(doc, &iteration); err != nil {
+			validateLog.WithField("row", i).WithError(err).Errorln("failed to parse rendered command spec")
+			return false
+		}
+		iteration.namespace = spec.namespace
+		if !iteration.Validate(ctx, fmt.Sprintf("%s[%d]", name, i), root) {
+			return false
+		}
+		spec.iterations = append(spec.iterations, iteration)
+	}
+	return true
+}
+
+// Iterations returns the commands rendered for each row of foreach.
+func (spec *WriteCmdSpec) Iterations() []*WriteCmdSpec {
+	return spec.iterations
+}
+
+// Condition returns the parsed when condition, nil means the command always runs.
+func (spec *WriteCmdSpec) Condition() *Expression {
+	return spec.condition
+}
+
+// ValueExpression returns the value expression over outputs of other commands, without the denominator,
+// it's evaluated right before the command is run. Nil means the value is parsed as is.
+func (spec *WriteCmdSpec) ValueExpression() *Expression {
+	return spec.valueExpr
+}
+
 func (spec *WriteCmdSpec) CountArgsUsing(set map[int]struct{}) {
 	spec.ParamSpec.CountArgsUsing(set)
+	countConditionArgs(spec.When, set)
 	spec.Value.CountArgsUsing(set)
+	countKindArgs(set, spec.TokenID, spec.Amount)
+	spec.countExpectEventArgs(set)
 }
 
 func (spec *WriteCmdSpec) ArgCount() int {
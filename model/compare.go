@@ -0,0 +1,59 @@
+package model
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// CompareOp is a comparison operator of conditions over numeric values.
+type CompareOp string
+
+const (
+	CompareGT CompareOp = ">"
+	CompareGE CompareOp = ">="
+	CompareLT CompareOp = "<"
+	CompareLE CompareOp = "<="
+	CompareEQ CompareOp = "=="
+	CompareNE CompareOp = "!="
+)
+
+// compareAliases allow to specify operators without quoting them in shell.
+var compareAliases = map[string]CompareOp{
+	"gt":  CompareGT,
+	"gte": CompareGE,
+	"lt":  CompareLT,
+	"lte": CompareLE,
+	"eq":  CompareEQ,
+	"ne":  CompareNE,
+}
+
+func ParseCompareOp(str string) (CompareOp, error) {
+	switch op := CompareOp(str); op {
+	case CompareGT, CompareGE, CompareLT, CompareLE, CompareEQ, CompareNE:
+		return op, nil
+	}
+	if op, ok := compareAliases[str]; ok {
+		return op, nil
+	}
+	return "", fmt.Errorf("unknown comparison operator: %s", str)
+}
+
+// Compare reports whether the comparison x op y holds.
+func (op CompareOp) Compare(x, y *big.Int) bool {
+	cmp := x.Cmp(y)
+	switch op {
+	case CompareGT:
+		return cmp > 0
+	case CompareGE:
+		return cmp >= 0
+	case CompareLT:
+		return cmp < 0
+	case CompareLE:
+		return cmp <= 0
+	case CompareEQ:
+		return cmp == 0
+	case CompareNE:
+		return cmp != 0
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// countConditionArgs adds the CLI args referenced in the expression to the set.
+func countConditionArgs(expr string, set map[int]struct{}) {
+	for _, ref := range argRefRx.FindAllString(expr, -1) {
+		if argID, err := argReferenceID(ref); err == nil {
+			set[argID] = struct{}{}
+		}
+	}
+}
+
+var errEmptyCondition = errors.New("when condition is empty")
+
+// validateCondition parses the `when` field of a command, nil is returned for no condition.
+func validateCondition(ctx AppContext, root *Spec, when string) (*Expression, error) {
+	if len(when) == 0 {
+		return nil, nil
+	} else if len(strings.TrimSpace(when)) == 0 {
+		return nil, errEmptyCondition
+	}
+	return parseExpression(ctx, root, when)
+}
+
+// validateTargetCondition parses the `when` field of a target, which is checked before any command
+// of the target runs, so it can't reference command outputs, except the event of a triggered target.
+func validateTargetCondition(ctx AppContext, root *Spec, when string) (*Expression, error) {
+	condition, err := validateCondition(ctx, root, when)
+	if err != nil || condition == nil {
+		return condition, err
+	}
+	for _, name := range outputRefs(condition.Parts()) {
+		if name != TriggerEventOutput {
+			return nil, fmt.Errorf("target condition references the output of command %s, which hasn't run yet", name)
+		}
+	}
+	return condition, nil
+}
+
+// outputRefs returns the names of commands whose outputs are referenced in the expression parts.
+func outputRefs(parts []interface{}) []string {
+	var names []string
+	for _, part := range parts {
+		switch ref := part.(type) {
+		case *CommandOutputReference:
+			names = append(names, ref.CmdName)
+		case *Expression:
+			names = append(names, outputRefs(ref.Parts())...)
+		case *FunctionCall:
+			names = append(names, outputRefs(ref.Args)...)
+		case *ContractCallReference:
+			names = append(names, outputRefs(ref.Params)...)
+		}
+	}
+	return names
+}
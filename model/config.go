@@ -2,11 +2,14 @@ package model
 
 import (
 	"math/big"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/AtlantPlatform/ethfw"
 	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 type ConfigSpec struct {
@@ -14,6 +17,77 @@ type ConfigSpec struct {
 	GasLimit     string `yaml:"gasLimit"`
 	ChainID      string `yaml:"chainID"`
 	AwaitTimeout string `yaml:"awaitTimeout"`
+	// BlockTime converts durations in blocks into time, e.g. awaitTimeout: 12 blocks.
+	BlockTime string `yaml:"blockTime"`
+
+	// ExplorerURL is the block explorer linked from the run summary, known for public chains by chainID.
+	ExplorerURL string `yaml:"explorerURL"`
+
+	EtherscanURL    string `yaml:"etherscanURL"`
+	EtherscanAPIKey string `yaml:"etherscanAPIKey"`
+	SignatureLookup bool   `yaml:"signatureLookup"`
+	// Subgraphs are the GraphQL endpoints of the graphql views by name, e.g. of The Graph.
+	Subgraphs map[string]string `yaml:"subgraphs"`
+	// ReverseENS shows the primary ENS names of the addresses in the balances, events and traces.
+	ReverseENS bool `yaml:"reverseENS"`
+
+	Multicall        bool   `yaml:"multicall"`
+	MulticallAddress string `yaml:"multicallAddress"`
+	// DisperseAddress is the disperse contract the airdrops of the disperse mode are sent through.
+	DisperseAddress string `yaml:"disperseAddress"`
+	// WrappedNativeAddress is the WETH of wrap and unwrap, the canonical one of the chain is used by default.
+	WrappedNativeAddress string `yaml:"wrappedNativeAddress"`
+
+	// SwapURL is the 1inch-compatible aggregator API quoting and building the swaps, the key is sent as the bearer.
+	SwapURL    string `yaml:"swapURL"`
+	SwapAPIKey string `yaml:"swapAPIKey"`
+	// MaxSlippage is the slippage of the swaps in percents, MaxPriceDeviation is how much worse than
+	// the price feeds of the assets the quote may be, and NativePriceFeed is the price feed of ether.
+	MaxSlippage       float64 `yaml:"maxSlippage"`
+	MaxPriceDeviation float64 `yaml:"maxPriceDeviation"`
+	NativePriceFeed   string  `yaml:"nativePriceFeed"`
+	// FiatCurrency is the currency the balances, the history and the gas spent are valued in, e.g. usd,
+	// none by default. The current usd prices are read from the price feeds, the rest come from the
+	// CoinGecko-compatible PriceURL, where NativePriceID is the coin of ether.
+	FiatCurrency  string `yaml:"fiatCurrency"`
+	PriceURL      string `yaml:"priceURL"`
+	PriceAPIKey   string `yaml:"priceAPIKey"`
+	NativePriceID string `yaml:"nativePriceID"`
+	// ReportFilter hides the dust and the spam tokens in the balances and the history reports.
+	ReportFilter *ReportFilterSpec `yaml:"reportFilter"`
+	// BeaconURL is the beacon node API the validators of STAKING are read from, the key is sent as the bearer.
+	BeaconURL    string `yaml:"beaconURL"`
+	BeaconAPIKey string `yaml:"beaconAPIKey"`
+
+	ApprovalWebhook string `yaml:"approvalWebhook"`
+	// ApprovalSecret signs the approval tokens of the commands requiring confirmation, held by the reviewers.
+	ApprovalSecret string `yaml:"approvalSecret"`
+	// Webhooks are notified of the new blocks, the trigger events and the completed and failed commands.
+	Webhooks []*WebhookSpec `yaml:"webhooks"`
+	// ScreeningList is the denylist file the counterparties of the transfers are screened against,
+	// ScreeningURL is the screening API queried for each of them, e.g. of the sanctioned addresses.
+	ScreeningList   string `yaml:"screeningList"`
+	ScreeningURL    string `yaml:"screeningURL"`
+	ScreeningAPIKey string `yaml:"screeningAPIKey"`
+	// AddressFingerprints shows the emoji fingerprints of the counterparties in the confirmation prompts.
+	AddressFingerprints bool `yaml:"addressFingerprints"`
+
+	// RateLimit is the requests per second sent to each HTTP node, RateBurst is the requests sent at once.
+	RateLimit int `yaml:"rateLimit"`
+	RateBurst int `yaml:"rateBurst"`
+	// BatchSize makes the concurrent calls sent as JSON-RPC batch requests of up to the size calls.
+	BatchSize int `yaml:"batchSize"`
+	// MaxLag is the number of blocks a node may be behind the highest head of the nodes and of the
+	// HeadReference node, the nodes lagging more are not used. WaitSync is the time to wait for
+	// the nodes to catch up, if all of them lag.
+	MaxLag        int    `yaml:"maxLag"`
+	HeadReference string `yaml:"headReference"`
+	WaitSync      string `yaml:"waitSync"`
+
+	// RPCQuota is the compute units the run may use on each HTTP node before a warning.
+	RPCQuota int `yaml:"rpcQuota"`
+	// RPCCache caches the reads of finalized blocks and mined transactions in .cache/rpc.
+	RPCCache bool `yaml:"rpcCache"`
 
 	SpecDir string `yaml:"-"`
 }
@@ -26,6 +100,17 @@ var DefaultConfigSpec = &ConfigSpec{
 	// hard limit, real limit is estimated
 	GasLimit:     "10000000",
 	AwaitTimeout: "10m",
+	BlockTime:    "12s",
+	// Multicall3 has the same address on most chains
+	MulticallAddress: "0xcA11bde05977b3631167028862bE2a173976CA11",
+	// Disperse.app is deployed at the same address on most chains too
+	DisperseAddress: "0xD152f549545093347A162Dce210e7293f1452150",
+	SwapURL:         "https://api.1inch.dev/swap/v6.0",
+	PriceURL:        "https://api.coingecko.com/api/v3",
+	NativePriceID:   "ethereum",
+	// percents
+	MaxSlippage:       1,
+	MaxPriceDeviation: 3,
 }
 
 func (spec *ConfigSpec) Validate() bool {
@@ -60,6 +145,101 @@ func (spec *ConfigSpec) Validate() bool {
 	} else {
 		spec.AwaitTimeout = DefaultConfigSpec.AwaitTimeout
 	}
+	if len(spec.BlockTime) > 0 {
+		if _, err := time.ParseDuration(spec.BlockTime); err != nil {
+			validateLog.Errorln("failed to parse blockTime")
+			return false
+		}
+	} else {
+		spec.BlockTime = DefaultConfigSpec.BlockTime
+	}
+	if len(spec.MulticallAddress) > 0 {
+		if !common.IsHexAddress(spec.MulticallAddress) {
+			validateLog.Errorln("failed to parse multicallAddress")
+			return false
+		}
+	} else {
+		spec.MulticallAddress = DefaultConfigSpec.MulticallAddress
+	}
+	if len(spec.DisperseAddress) > 0 {
+		if !common.IsHexAddress(spec.DisperseAddress) {
+			validateLog.Errorln("failed to parse disperseAddress")
+			return false
+		}
+	} else {
+		spec.DisperseAddress = DefaultConfigSpec.DisperseAddress
+	}
+	if len(spec.WrappedNativeAddress) > 0 && !common.IsHexAddress(spec.WrappedNativeAddress) {
+		validateLog.Errorln("failed to parse wrappedNativeAddress")
+		return false
+	}
+	if len(spec.SwapURL) == 0 {
+		spec.SwapURL = DefaultConfigSpec.SwapURL
+	}
+	if len(spec.PriceURL) == 0 {
+		spec.PriceURL = DefaultConfigSpec.PriceURL
+	} else if u, err := url.Parse(spec.PriceURL); err != nil || len(u.Host) == 0 {
+		validateLog.Errorln("failed to parse priceURL")
+		return false
+	}
+	if spec.ReportFilter != nil {
+		if err := spec.ReportFilter.validate(); err != nil {
+			validateLog.WithError(err).Errorln("invalid reportFilter")
+			return false
+		}
+	}
+	if len(spec.BeaconURL) > 0 {
+		if u, err := url.Parse(spec.BeaconURL); err != nil || len(u.Host) == 0 {
+			validateLog.Errorln("failed to parse beaconURL")
+			return false
+		}
+	}
+	if len(spec.NativePriceID) == 0 {
+		spec.NativePriceID = DefaultConfigSpec.NativePriceID
+	}
+	if spec.FiatCurrency = strings.ToLower(spec.FiatCurrency); len(spec.FiatCurrency) > 0 && !fiatCurrencyRx.MatchString(spec.FiatCurrency) {
+		validateLog.WithField("currency", spec.FiatCurrency).Errorln("fiatCurrency must be a currency code, e.g. usd")
+		return false
+	}
+	if spec.MaxSlippage == 0 {
+		spec.MaxSlippage = DefaultConfigSpec.MaxSlippage
+	}
+	if spec.MaxPriceDeviation == 0 {
+		spec.MaxPriceDeviation = DefaultConfigSpec.MaxPriceDeviation
+	}
+	if spec.MaxSlippage < 0 || spec.MaxSlippage > 50 || spec.MaxPriceDeviation < 0 || spec.MaxPriceDeviation > 50 {
+		validateLog.Errorln("maxSlippage and maxPriceDeviation must be percents within 0 and 50")
+		return false
+	}
+	if len(spec.NativePriceFeed) > 0 && !common.IsHexAddress(spec.NativePriceFeed) {
+		validateLog.Errorln("failed to parse nativePriceFeed")
+		return false
+	}
+	if len(spec.WaitSync) > 0 {
+		if _, err := spec.WaitSyncDuration(); err != nil {
+			validateLog.Errorln("failed to parse waitSync")
+			return false
+		}
+	}
+	for i, webhook := range spec.Webhooks {
+		if webhook == nil {
+			validateLog.WithField("webhook", i).Errorln("webhook has no spec")
+			return false
+		} else if err := webhook.validate(); err != nil {
+			validateLog.WithField("webhook", i).WithError(err).Errorln("invalid webhook")
+			return false
+		}
+	}
+	for name, endpoint := range spec.Subgraphs {
+		if u, err := url.Parse(endpoint); err != nil || len(u.Host) == 0 {
+			validateLog.WithField("subgraph", name).Errorln("subgraph must have a valid url")
+			return false
+		}
+	}
+	if spec.RateLimit < 0 || spec.RateBurst < 0 || spec.BatchSize < 0 || spec.RPCQuota < 0 || spec.MaxLag < 0 {
+		validateLog.Errorln("rateLimit, rateBurst, batchSize, rpcQuota and maxLag must not be negative")
+		return false
+	}
 	return true
 }
 
@@ -71,14 +251,68 @@ func (spec *ConfigSpec) GasLimitInt() (uint64, error) {
 	return i, nil
 }
 
+// GasPriceInt returns the gas price in wei, it may be set with a unit, e.g. 20 gwei.
 func (spec *ConfigSpec) GasPriceInt() (*big.Int, bool) {
-	return big.NewInt(0).SetString(spec.GasPrice, 10)
+	wei, err := parseWei(spec.GasPrice)
+	if err != nil {
+		return nil, false
+	}
+	return wei, true
 }
 
 func (spec *ConfigSpec) ChainIDInt() (*big.Int, bool) {
 	return big.NewInt(0).SetString(spec.ChainID, 10)
 }
 
+// AwaitTimeoutDuration returns the await timeout, which may be set in blocks, e.g. 12 blocks.
 func (spec *ConfigSpec) AwaitTimeoutDuration() (time.Duration, error) {
-	return time.ParseDuration(spec.AwaitTimeout)
+	d, err := ParseDuration(spec.AwaitTimeout)
+	if err != nil {
+		return 0, err
+	}
+	return d.Of(spec.BlockTimeDuration()), nil
+}
+
+// explorerURLs are the block explorers of public chains by chain ID.
+var explorerURLs = map[string]string{
+	"1":        "https://etherscan.io",
+	"10":       "https://optimistic.etherscan.io",
+	"56":       "https://bscscan.com",
+	"137":      "https://polygonscan.com",
+	"8453":     "https://basescan.org",
+	"17000":    "https://holesky.etherscan.io",
+	"42161":    "https://arbiscan.io",
+	"11155111": "https://sepolia.etherscan.io",
+}
+
+// ExplorerTxURL returns the block explorer link of the transaction, empty if no explorer is known.
+func (spec *ConfigSpec) ExplorerTxURL(txHash string) string {
+	explorerURL := spec.ExplorerURL
+	if len(explorerURL) == 0 {
+		explorerURL = explorerURLs[spec.ChainID]
+	}
+	if len(explorerURL) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(explorerURL, "/") + "/tx/" + txHash
+}
+
+// WaitSyncDuration returns the time to wait for the nodes to sync, it may be set in blocks.
+func (spec *ConfigSpec) WaitSyncDuration() (time.Duration, error) {
+	if len(spec.WaitSync) == 0 {
+		return 0, nil
+	}
+	d, err := ParseDuration(spec.WaitSync)
+	if err != nil {
+		return 0, err
+	}
+	return d.Of(spec.BlockTimeDuration()), nil
+}
+
+func (spec *ConfigSpec) BlockTimeDuration() time.Duration {
+	d, err := time.ParseDuration(spec.BlockTime)
+	if err != nil || d <= 0 {
+		d, _ = time.ParseDuration(DefaultConfigSpec.BlockTime)
+	}
+	return d
 }
@@ -1,14 +1,19 @@
 package model
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"path/filepath"
 	"strings"
 
 	"github.com/AtlantPlatform/ethfw"
 	"github.com/AtlantPlatform/ethfw/sol"
 	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 type Contracts map[string]*ContractSpec
@@ -24,16 +29,65 @@ func (contracts Contracts) UseSolc() bool {
 
 func (contracts Contracts) Validate(ctx AppContext, spec *Spec) bool {
 	for name, contract := range contracts {
-		if !contract.Validate(ctx, name) {
+		if !contract.Validate(ctx, name, spec) {
+			return false
+		}
+		var err error
+		if contract.functions, err = parseABIFunctions(contract.src.ABI); err != nil {
+			log.WithFields(log.Fields{
+				"section":  "Contracts",
+				"contract": name,
+			}).WithError(err).Errorln("failed to parse functions from ABI")
+			return false
+		}
+		// the functions with struct params are packed by the tuple encoder
+		if contract.src.ABI, contract.tuples, contract.unsupported, err = sanitizeABI(contract.src.ABI); err != nil {
+			log.WithFields(log.Fields{
+				"section":  "Contracts",
+				"contract": name,
+			}).WithError(err).Errorln("failed to parse contract ABI")
+			return false
+		}
+		abiErrors, err := parseABIErrors(contract.src.ABI)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"section":  "Contracts",
+				"contract": name,
+			}).WithError(err).Warningln("failed to parse custom errors from ABI")
+		}
+		contract.errors = abiErrors
+		if contract.abi, err = abi.JSON(bytes.NewReader(contract.src.ABI)); err != nil {
+			log.WithFields(log.Fields{
+				"section":  "Contracts",
+				"contract": name,
+			}).WithError(err).Errorln("failed to parse contract ABI")
 			return false
 		}
 		for _, instance := range contract.Instances {
-			if !instance.Validate(ctx, name, contract.src) {
+			if !instance.Validate(ctx, name, contract.src, spec) {
 				return false
 			}
+			instance.codeHash = contract.codeHash
+			instance.tuples = contract.tuples
 		}
 	}
-	return true
+	return contracts.checkSelectors()
+}
+
+// unsupportedEntry returns the error naming the function, event or error dropped off the ABI
+// of the contract, or of any contract if the name is empty, nil if it's not dropped.
+func (contracts Contracts) unsupportedEntry(contractName, entry string) error {
+	if i := strings.Index(entry, "("); i >= 0 {
+		entry = entry[:i]
+	}
+	for name, contract := range contracts {
+		if contract == nil || (len(contractName) > 0 && name != contractName) {
+			continue
+		} else if reason, ok := contract.unsupported[entry]; ok {
+			return fmt.Errorf("%s in the ABI of %s, which is not supported", reason, name)
+		}
+	}
+	return nil
 }
 
 func (contracts Contracts) ContractSpec(name string) (*ContractSpec, bool) {
@@ -41,6 +95,60 @@ func (contracts Contracts) ContractSpec(name string) (*ContractSpec, bool) {
 	return spec, ok
 }
 
+// DeploymentKey is the key of the instance in the deployments registry, by its position
+// in the instances of the contract.
+func (contracts Contracts) DeploymentKey(instance *ContractInstanceSpec) string {
+	if contract, ok := contracts[instance.Name]; ok && contract != nil {
+		for i, declared := range contract.Instances {
+			if declared == instance {
+				return DeploymentKey(instance.Name, i)
+			}
+		}
+	}
+	return DeploymentKey(instance.Name, 0)
+}
+
+// FindInstance looks up the declared contract instance matching the reference,
+// which has the contract name and optionally the instance address.
+func (contracts Contracts) FindInstance(ref *ContractInstanceSpec) (*ContractInstanceSpec, error) {
+	if len(ref.Name) == 0 {
+		return nil, errors.New("the contract spec name is not specified")
+	}
+	contract, ok := contracts.ContractSpec(ref.Name)
+	if !ok || contract == nil {
+		return nil, errors.New("the contract spec not found (name mismatch)")
+	} else if len(contract.Instances) == 0 {
+		return nil, errors.New("the contract spec has no instances")
+	}
+	if len(ref.Address) == 0 {
+		return contract.Instances[0], nil
+	}
+	for _, instance := range contract.Instances {
+		if instance.MatchesAddress(ref.Address) {
+			return instance, nil
+		}
+	}
+	return nil, errors.New("referenced contract instance is not found (address mismatch)")
+}
+
+// NameOf returns the name of the contract having a deployed instance at the address, the first
+// one by name if the instances of a few contracts share it, e.g. the proxy and the implementation.
+func (contracts Contracts) NameOf(address string) string {
+	var found string
+	for name, contract := range contracts {
+		if contract == nil || (len(found) > 0 && name > found) {
+			continue
+		}
+		for _, instance := range contract.Instances {
+			if instance.IsDeployed() && strings.EqualFold(instance.Address, address) {
+				found = name
+				break
+			}
+		}
+	}
+	return found
+}
+
 func (contracts Contracts) FindByTokenSymbol(symbol string) (*ContractInstanceSpec, bool) {
 	symbol = strings.ToUpper(symbol)
 	for _, contract := range contracts {
@@ -56,23 +164,67 @@ func (contracts Contracts) FindByTokenSymbol(symbol string) (*ContractInstanceSp
 type ContractSpec struct {
 	Name      string                  `yaml:"name"`
 	SolPath   string                  `yaml:"sol"`
+	ABIFile   string                  `yaml:"abiFile"`
+	ABISource string                  `yaml:"abi"`
+	Fragments []string                `yaml:"fragments"`
+	CodeHash  string                  `yaml:"codehash"`
 	Instances []*ContractInstanceSpec `yaml:"instances"`
 
-	src *sol.Contract `yaml:"-"`
+	src       *sol.Contract           `yaml:"-"`
+	codeHash  *common.Hash            `yaml:"-"`
+	errors    []*ABIError             `yaml:"-"`
+	abi       abi.ABI                 `yaml:"-"`
+	functions []*abiFunction          `yaml:"-"`
+	tuples    map[string]*tupleMethod `yaml:"-"`
+	// unsupported are the entries dropped off the ABI with the reason, by name
+	unsupported map[string]string `yaml:"-"`
 }
 
-func (spec *ContractSpec) Validate(ctx AppContext, name string) bool {
+func (spec *ContractSpec) Validate(ctx AppContext, name string, root *Spec) bool {
 	validateLog := log.WithFields(log.Fields{
 		"section":  "Contracts",
 		"contract": name,
 	})
-	if len(spec.Name) == 0 {
+	if len(spec.ABISource) > 0 && spec.ABISource != ABISourceEtherscan {
+		validateLog.WithField("abi", spec.ABISource).Errorln("unknown ABI source")
+		return false
+	}
+	hasABI := len(spec.ABIFile) > 0 || len(spec.ABISource) > 0 || len(spec.Fragments) > 0
+	if len(spec.Name) == 0 && !hasABI {
 		validateLog.Errorln("the root contract name must be specified")
 		return false
 	}
-	if len(spec.SolPath) == 0 {
-		validateLog.Errorln("contract spec must have the path to .sol file")
+	if len(spec.SolPath) == 0 && !hasABI {
+		validateLog.Errorln("contract spec must have the path to .sol file or artifact file")
 		return false
+	} else if len(spec.SolPath) > 0 && len(spec.ABIFile) > 0 {
+		validateLog.Errorln("contract spec must have either .sol or artifact file, not both")
+		return false
+	}
+	if len(spec.CodeHash) > 0 {
+		hashBytes, err := hexutil.Decode(spec.CodeHash)
+		if err != nil || len(hashBytes) != common.HashLength {
+			validateLog.Errorln("codehash is not valid (must be 32-byte hex string starting from 0x)")
+			return false
+		}
+		codeHash := common.BytesToHash(hashBytes)
+		spec.codeHash = &codeHash
+	}
+	if len(spec.Fragments) > 0 {
+		return spec.validateFragments(validateLog, name)
+	}
+	if spec.ABISource == ABISourceEtherscan {
+		return spec.validateEtherscan(ctx, validateLog, name, root)
+	}
+	if len(spec.ABIFile) > 0 {
+		src, err := loadArtifact(ctx.SpecDir(), spec.ABIFile, spec.Name)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to load contract artifact")
+			return false
+		}
+		spec.Name = src.Name
+		spec.src = src
+		return true
 	}
 	if !filepath.IsAbs(spec.SolPath) {
 		spec.SolPath = filepath.FromSlash(spec.SolPath)
@@ -96,26 +248,98 @@ func (spec *ContractSpec) Validate(ctx AppContext, name string) bool {
 	return true
 }
 
+// validateFragments builds the ABI from human-readable fragments,
+// such contracts are for binding only and cannot be deployed.
+func (spec *ContractSpec) validateFragments(validateLog *log.Entry, name string) bool {
+	if len(spec.SolPath) > 0 || len(spec.ABIFile) > 0 || len(spec.ABISource) > 0 {
+		validateLog.Errorln("contract spec with ABI fragments must not have other ABI sources")
+		return false
+	}
+	abiJSON, err := parseFragments(spec.Fragments)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to parse ABI fragments")
+		return false
+	}
+	if len(spec.Name) == 0 {
+		spec.Name = name
+	}
+	spec.src = &sol.Contract{
+		Name: spec.Name,
+		ABI:  abiJSON,
+	}
+	return true
+}
+
+// validateEtherscan loads the verified ABI of the first instance address,
+// such contracts are for binding only and cannot be deployed.
+func (spec *ContractSpec) validateEtherscan(ctx AppContext, validateLog *log.Entry, name string, root *Spec) bool {
+	if len(spec.SolPath) > 0 || len(spec.ABIFile) > 0 {
+		validateLog.Errorln("contract spec with ABI from etherscan must not have .sol or artifact file")
+		return false
+	} else if len(spec.Instances) == 0 {
+		validateLog.Errorln("contract spec with ABI from etherscan must have an instance address")
+		return false
+	}
+	if err := spec.Instances[0].resolveENSName(ctx, root); err != nil {
+		validateLog.WithError(err).Errorln("failed to resolve contract instance ENS name")
+		return false
+	}
+	address := spec.Instances[0].Address
+	if !common.IsHexAddress(address) {
+		validateLog.Errorln("contract instance address is not valid (must be hex string starting from 0x, or ENS name)")
+		return false
+	}
+	if len(spec.Name) == 0 {
+		spec.Name = name
+	}
+	src, err := fetchEtherscanABI(ctx, root.Config, spec.Name, address)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to fetch ABI from etherscan")
+		return false
+	}
+	spec.src = src
+	return true
+}
+
 type ContractInstanceSpec struct {
 	Name    string `yaml:"contract"`
 	Address string `yaml:"address"`
 
-	binding     *ethfw.BoundContract `yaml:"-"`
-	tokenSymbol string               `yaml:"-"`
+	binding     *ethfw.BoundContract    `yaml:"-"`
+	tokenSymbol string                  `yaml:"-"`
+	addressRef  string                  `yaml:"-"`
+	codeHash    *common.Hash            `yaml:"-"`
+	tuples      map[string]*tupleMethod `yaml:"-"`
 }
 
-func (spec *ContractInstanceSpec) Validate(ctx AppContext, name string, src *sol.Contract) bool {
+func (spec *ContractInstanceSpec) Validate(ctx AppContext, name string, src *sol.Contract, root *Spec) bool {
 	validateLog := log.WithFields(log.Fields{
 		"section":  "ContractInstances",
 		"contract": name,
 	})
+	if err := spec.resolveENSName(ctx, root); err != nil {
+		validateLog.WithError(err).Errorln("failed to resolve contract instance ENS name")
+		return false
+	}
+	if isDeploymentRef(spec.Address) {
+		address, err := resolveDeploymentRef(ctx.SpecDir(), spec.Address, root)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to resolve deployments reference")
+			return false
+		} else if len(address) == 0 {
+			validateLog.WithField("reference", spec.Address).Errorln("contract is not deployed to the referenced network")
+			return false
+		}
+		spec.addressRef = spec.Address
+		spec.Address = address
+	}
 	if len(spec.Address) == 0 || spec.Address == ZeroAddress {
 		if len(spec.Name) == 0 {
 			validateLog.Errorln("contract instance cannot be deployed without name nor address specified")
 			return false
 		}
 	} else if !common.IsHexAddress(spec.Address) {
-		validateLog.Errorln("contract instance address is not valid (must be hex string starting from 0x)")
+		validateLog.Errorln("contract instance address is not valid (must be hex string starting from 0x, or ENS name)")
 		return false
 	}
 	binding, err := ethfw.BindContract(nil, src)
@@ -127,6 +351,20 @@ func (spec *ContractInstanceSpec) Validate(ctx AppContext, name string, src *sol
 	return true
 }
 
+// resolveENSName replaces the ENS name of the instance with its address, the name is kept as the reference.
+func (spec *ContractInstanceSpec) resolveENSName(ctx AppContext, root *Spec) error {
+	if !isENSName(spec.Address) {
+		return nil
+	}
+	address, err := root.ResolveENS(ctx, spec.Address)
+	if err != nil {
+		return err
+	}
+	spec.addressRef = spec.Address
+	spec.Address = strings.ToLower(address.Hex())
+	return nil
+}
+
 func (spec *ContractInstanceSpec) TokenSymbol() string {
 	return spec.tokenSymbol
 }
@@ -144,10 +382,48 @@ func (spec *ContractInstanceSpec) FetchTokenSymbol(ctx AppContext) string {
 	return spec.tokenSymbol
 }
 
+// CodeHash returns the expected keccak256 hash of the on-chain code,
+// if it has been specified in the contract spec.
+func (spec *ContractInstanceSpec) CodeHash() (common.Hash, bool) {
+	if spec.codeHash == nil {
+		return common.Hash{}, false
+	}
+	return *spec.codeHash, true
+}
+
 func (spec *ContractInstanceSpec) BoundContract() *ethfw.BoundContract {
 	return spec.binding
 }
 
+// HasTupleParams tells if the method, or the constructor if empty, has struct params, which
+// the binding can't pack, so the calls are packed by Pack and sent as raw input.
+func (spec *ContractInstanceSpec) HasTupleParams(method string) bool {
+	_, ok := spec.tuples[method]
+	return ok
+}
+
+// Pack encodes the call of the method with the params, or the constructor args if the method is empty.
+func (spec *ContractInstanceSpec) Pack(method string, params ...interface{}) ([]byte, error) {
+	if tuple, ok := spec.tuples[method]; ok {
+		return tuple.pack(params)
+	}
+	return spec.binding.ABI().Pack(method, params...)
+}
+
+func (spec *ContractInstanceSpec) tupleMethod(method string) (*tupleMethod, bool) {
+	tuple, ok := spec.tuples[method]
+	return tuple, ok
+}
+
+// MatchesAddress checks the instance address, or the original
+// deployments reference or ENS name it has been resolved from.
+func (spec *ContractInstanceSpec) MatchesAddress(address string) bool {
+	if len(spec.addressRef) > 0 && spec.addressRef == address {
+		return true
+	}
+	return strings.ToLower(spec.Address) == strings.ToLower(address)
+}
+
 func (spec *ContractInstanceSpec) IsDeployed() bool {
 	return len(spec.Address) > 0 && spec.Address != ZeroAddress
 }
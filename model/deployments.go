@@ -0,0 +1,154 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	deploymentsDir    = "deployments"
+	deploymentsPrefix = "deployments"
+)
+
+// DeploymentsFile is the registry of deployed contract instances for a single chain,
+// stored as deployments/<chainID>.json next to the spec file. The instances are keyed
+// by the contract name and their position in the instances of the contract, e.g. token[0].
+type DeploymentsFile struct {
+	ChainID   string                       `json:"chainID"`
+	Instances map[string]*DeploymentRecord `json:"instances"`
+}
+
+type DeploymentRecord struct {
+	Address string `json:"address"`
+	TxHash  string `json:"txHash"`
+	Block   uint64 `json:"block"`
+}
+
+var deploymentsMux = new(sync.Mutex)
+
+func deploymentsPath(specDir, chainID string) string {
+	return filepath.Join(specDir, deploymentsDir, chainID+".json")
+}
+
+// DeploymentKey is the key of the instance of the contract in the registry.
+func DeploymentKey(contract string, index int) string {
+	return fmt.Sprintf("%s[%d]", contract, index)
+}
+
+func LoadDeployments(specDir, chainID string) (*DeploymentsFile, error) {
+	deploymentsMux.Lock()
+	defer deploymentsMux.Unlock()
+	return loadDeployments(specDir, chainID)
+}
+
+func loadDeployments(specDir, chainID string) (*DeploymentsFile, error) {
+	file := &DeploymentsFile{
+		Instances: make(map[string]*DeploymentRecord),
+	}
+	data, err := ioutil.ReadFile(deploymentsPath(specDir, chainID))
+	if os.IsNotExist(err) {
+		return file, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	if file.Instances == nil {
+		file.Instances = make(map[string]*DeploymentRecord)
+	}
+	return file, nil
+}
+
+func storeDeployments(specDir, chainID string, file *DeploymentsFile) error {
+	if err := os.MkdirAll(filepath.Join(specDir, deploymentsDir), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(deploymentsPath(specDir, chainID), data)
+}
+
+// RecordDeployment saves the deployed instance address into the registry of the chain,
+// replacing any previous record for the same instance.
+func RecordDeployment(specDir, chainID, key string, record *DeploymentRecord) error {
+	deploymentsMux.Lock()
+	defer deploymentsMux.Unlock()
+	file, err := loadDeployments(specDir, chainID)
+	if err != nil {
+		return err
+	}
+	file.ChainID = chainID
+	file.Instances[key] = record
+	return storeDeployments(specDir, chainID, file)
+}
+
+// UpdateDeploymentBlock sets the block number for the record created by the given transaction,
+// it's a no-op if there is no such record.
+func UpdateDeploymentBlock(specDir, chainID, txHash string, block uint64) error {
+	deploymentsMux.Lock()
+	defer deploymentsMux.Unlock()
+	file, err := loadDeployments(specDir, chainID)
+	if err != nil {
+		return err
+	}
+	var found bool
+	for _, record := range file.Instances {
+		if strings.EqualFold(record.TxHash, txHash) {
+			record.Block = block
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return storeDeployments(specDir, chainID, file)
+}
+
+func isDeploymentRef(str string) bool {
+	return strings.HasPrefix(str, walletPrefix+deploymentsPrefix+refDelim)
+}
+
+// resolveDeploymentRef resolves references of the form @deployments.network.contract, where the network
+// is a chain id or a network of the NETWORKS section setting chainID, and the contract may have
+// the instance index, e.g. token[1], the first instance by default. It returns an empty address
+// if the instance has not been deployed to that chain yet.
+func resolveDeploymentRef(specDir, ref string, root *Spec) (string, error) {
+	refParts := strings.Split(ref[1:], refDelim)
+	if len(refParts) != 3 {
+		err := errors.New("reference must have three parts: deployments.network.contract")
+		return "", err
+	}
+	chainID := refParts[1]
+	if _, ok := new(big.Int).SetString(chainID, 10); !ok {
+		network, ok := root.Networks[chainID]
+		if !ok || network == nil {
+			return "", fmt.Errorf("network %s is neither a chain id nor in NETWORKS", chainID)
+		} else if network.Config == nil || len(network.Config.ChainID) == 0 {
+			return "", fmt.Errorf("network %s has no chainID in its config", chainID)
+		}
+		chainID = network.Config.ChainID
+	}
+	key := refParts[2]
+	if !strings.HasSuffix(key, "]") {
+		key = DeploymentKey(key, 0)
+	}
+	file, err := LoadDeployments(specDir, chainID)
+	if err != nil {
+		return "", err
+	}
+	record, ok := file.Instances[key]
+	if !ok {
+		return "", nil
+	}
+	return record.Address, nil
+}
@@ -0,0 +1,283 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ensRegistry is the address of the ENS registry, the same on mainnet and the testnets.
+var ensRegistry = common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e")
+
+// ensCacheTTL is how long the resolved names are cached on disk, as the records may change.
+const ensCacheTTL = time.Hour
+
+// ensResolver resolves the names of the spec with the nodes of the selected group, once per run.
+// The names are cached in .cache/ens per chain, so the runs without nodes resolve them too.
+type ensResolver struct {
+	mux       sync.Mutex
+	ctx       AppContext
+	spec      *Spec
+	client    *rpc.Client
+	dialed    bool
+	addresses map[string]common.Address
+	names     map[common.Address]string
+}
+
+type ensCacheEntry struct {
+	Address    common.Address `json:"address"`
+	ResolvedAt time.Time      `json:"resolvedAt"`
+}
+
+// ResolveENS resolves the ENS name into the address, logging both.
+func (spec *Spec) ResolveENS(ctx context.Context, name string) (common.Address, error) {
+	if spec.ens == nil {
+		return common.Address{}, errors.New("ENS names are resolved after validation")
+	}
+	return spec.ens.resolve(ctx, name)
+}
+
+func (r *ensResolver) resolve(ctx context.Context, name string) (common.Address, error) {
+	name = strings.ToLower(name)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if address, ok := r.addresses[name]; ok {
+		return address, nil
+	}
+	path := cachePath(r.ctx.SpecDir(), "ens", r.spec.Config.ChainID, name+".json")
+	var entry *ensCacheEntry
+	if data, ok := readCache(path); ok {
+		if err := json.Unmarshal(data, &entry); err != nil || entry == nil || time.Since(entry.ResolvedAt) > ensCacheTTL {
+			entry = nil
+		}
+	}
+	if entry == nil {
+		client := r.dial()
+		if client == nil {
+			return common.Address{}, fmt.Errorf("ENS name %s is not resolved without nodes", name)
+		}
+		resolveCtx, cancel := context.WithTimeout(ctx, tokenFetchTimeout)
+		address, err := resolveENS(resolveCtx, client, name)
+		cancel()
+		if err != nil {
+			return common.Address{}, err
+		}
+		entry = &ensCacheEntry{
+			Address:    address,
+			ResolvedAt: time.Now().UTC(),
+		}
+		if data, err := json.Marshal(entry); err == nil {
+			if err := writeCache(path, data); err != nil {
+				log.WithError(err).Warningln("failed to cache ENS name")
+			}
+		}
+	}
+	log.WithFields(log.Fields{
+		"ens":     name,
+		"address": strings.ToLower(entry.Address.Hex()),
+	}).Infoln("ENS name resolved")
+	r.addresses[name] = entry.Address
+	return entry.Address, nil
+}
+
+type ensReverseEntry struct {
+	Name       string    `json:"name"`
+	ResolvedAt time.Time `json:"resolvedAt"`
+}
+
+// LookupENS returns the primary ENS name of the address for the reports, if reverseENS is enabled.
+// The name is verified to resolve back to the address. The lookups are cached in .cache/ens-reverse
+// per chain, the expired entries are still used when the nodes are unavailable, and the failed
+// lookups return no name, so the reports are never blocked by ENS.
+func (spec *Spec) LookupENS(ctx context.Context, address common.Address) string {
+	if spec.ens == nil || !spec.Config.ReverseENS || address == (common.Address{}) {
+		return ""
+	}
+	return spec.ens.lookup(ctx, address)
+}
+
+func (r *ensResolver) lookup(ctx context.Context, address common.Address) string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if name, ok := r.names[address]; ok {
+		return name
+	}
+	path := cachePath(r.ctx.SpecDir(), "ens-reverse", r.spec.Config.ChainID, strings.ToLower(address.Hex())+".json")
+	var entry *ensReverseEntry
+	if data, ok := readCache(path); ok {
+		if err := json.Unmarshal(data, &entry); err != nil {
+			entry = nil
+		}
+	}
+	if entry == nil || time.Since(entry.ResolvedAt) > ensCacheTTL {
+		if client := r.dial(); client != nil {
+			lookupCtx, cancel := context.WithTimeout(ctx, tokenFetchTimeout)
+			name, err := lookupENS(lookupCtx, client, address)
+			cancel()
+			if err != nil {
+				log.WithError(err).WithField("address", strings.ToLower(address.Hex())).Debugln("ENS reverse lookup failed")
+			} else {
+				entry = &ensReverseEntry{
+					Name:       name,
+					ResolvedAt: time.Now().UTC(),
+				}
+				if data, err := json.Marshal(entry); err == nil {
+					if err := writeCache(path, data); err != nil {
+						log.WithError(err).Warningln("failed to cache ENS reverse record")
+					}
+				}
+			}
+		}
+	}
+	var name string
+	if entry != nil {
+		name = entry.Name
+	}
+	r.names[address] = name
+	return name
+}
+
+// lookupENS returns the name of the reverse record of the address, if it resolves back to the address.
+func lookupENS(ctx context.Context, client *rpc.Client, address common.Address) (string, error) {
+	node := ENSNamehash(strings.ToLower(address.Hex()[2:]) + ".addr.reverse")
+	// resolver(bytes32)
+	result, err := ethCall(ctx, client, ensRegistry, append(common.FromHex("0x0178b8bf"), node.Bytes()...))
+	if err != nil {
+		return "", err
+	}
+	resolver := common.BytesToAddress(result)
+	if len(result) < 32 || resolver == (common.Address{}) {
+		return "", nil
+	}
+	// name(bytes32)
+	if result, err = ccipCall(ctx, client, resolver, append(common.FromHex("0x691f3431"), node.Bytes()...)); err != nil {
+		return "", err
+	}
+	name, err := decodeTokenString(result)
+	if err != nil || !isENSName(name) {
+		return "", nil
+	}
+	if resolved, err := resolveENS(ctx, client, name); err != nil || resolved != address {
+		// anyone can claim any name in their reverse record
+		return "", nil
+	}
+	return name, nil
+}
+
+// dial connects to the nodes of the group once, it's nil without nodes.
+func (r *ensResolver) dial() *rpc.Client {
+	if r.dialed {
+		return r.client
+	}
+	r.dialed = true
+	if len(r.ctx.AppCommand()) == 0 {
+		return nil
+	}
+	// the nodes have been validated with the inventory
+	if endpoints, ok := r.spec.Endpoints(r.ctx.NodeGroup()); ok {
+		r.client, _ = endpoints.Dial(false)
+	}
+	return r.client
+}
+
+// isENSName reports whether the string looks like an ENS name, e.g. dai.tokens.eth.
+func isENSName(str string) bool {
+	return strings.Contains(str, ".") && !strings.HasPrefix(str, "0x") && !strings.ContainsAny(str, " /@")
+}
+
+// ENSNamehash is the EIP-137 hash of the name, the node of the records.
+func ENSNamehash(name string) common.Hash {
+	var node common.Hash
+	if len(name) == 0 {
+		return node
+	}
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node.Bytes(), crypto.Keccak256([]byte(labels[i])))
+	}
+	return node
+}
+
+// resolveENS resolves the name into the address, using the resolver set in the registry for the name,
+// or the wildcard resolver of its closest parent (ENSIP-10). The resolvers may serve the records
+// offchain, see ccipCall.
+func resolveENS(ctx context.Context, client *rpc.Client, name string) (common.Address, error) {
+	node := ENSNamehash(name)
+	// addr(bytes32)
+	result, err := resolveRecord(ctx, client, name, append(common.FromHex("0x3b3b57de"), node.Bytes()...))
+	if err != nil {
+		return common.Address{}, err
+	}
+	address := common.BytesToAddress(result)
+	if len(result) < 32 || address == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("ENS name %s doesn't resolve to an address", name)
+	}
+	return address, nil
+}
+
+// resolveRecord calls the resolver of the name with the record calldata, returning the record.
+func resolveRecord(ctx context.Context, client *rpc.Client, name string, data []byte) ([]byte, error) {
+	resolver, exact, err := findResolver(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+	// supportsInterface(IExtendedResolver)
+	extended, err := ethCall(ctx, client, resolver, append(common.FromHex("0x01ffc9a7"),
+		common.RightPadBytes(common.FromHex("0x9061b923"), 32)...))
+	if err != nil || len(extended) < 32 || extended[31] != 1 {
+		if !exact {
+			return nil, fmt.Errorf("ENS name %s has no resolver", name)
+		}
+		return ccipCall(ctx, client, resolver, data)
+	}
+	// resolve(bytes name, bytes data)
+	encoded := abiBytes(dnsEncode(name))
+	call := append(common.FromHex("0x9061b923"), abiWord(64)...)
+	call = append(call, abiWord(uint64(64+len(encoded)))...)
+	call = append(append(call, encoded...), abiBytes(data)...)
+	result, err := ccipCall(ctx, client, resolver, call)
+	if err != nil {
+		return nil, err
+	}
+	record, ok := abiDynamicBytes(result, abiOffset(result, 0))
+	if !ok {
+		return nil, fmt.Errorf("malformed resolve result of %s", name)
+	}
+	return record, nil
+}
+
+// findResolver returns the resolver of the name, or of its closest parent with one, exact is
+// false then, as only the wildcard resolvers resolve the names below them.
+func findResolver(ctx context.Context, client *rpc.Client, name string) (common.Address, bool, error) {
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		node := ENSNamehash(strings.Join(labels[i:], "."))
+		// resolver(bytes32)
+		result, err := ethCall(ctx, client, ensRegistry, append(common.FromHex("0x0178b8bf"), node.Bytes()...))
+		if err != nil {
+			return common.Address{}, false, err
+		}
+		if resolver := common.BytesToAddress(result); len(result) >= 32 && resolver != (common.Address{}) {
+			return resolver, i == 0, nil
+		}
+	}
+	return common.Address{}, false, fmt.Errorf("ENS name %s has no resolver", name)
+}
+
+// ethCall calls the contract at the latest block.
+func ethCall(ctx context.Context, client *rpc.Client, to common.Address, data []byte) ([]byte, error) {
+	return EthCall(ctx, client, ethereum.CallMsg{
+		To:   &to,
+		Data: data,
+	}, nil)
+}
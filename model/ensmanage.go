@@ -0,0 +1,169 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ENSDeployment is the contracts of the .eth registrations on the chain, the registry is the same everywhere.
+type ENSDeployment struct {
+	Registry       common.Address
+	Controller     common.Address
+	BaseRegistrar  common.Address
+	NameWrapper    common.Address
+	PublicResolver common.Address
+}
+
+// ensDeployments are the ENS deployments by chainID.
+var ensDeployments = map[string]*ENSDeployment{
+	"1": {
+		Registry:       ensRegistry,
+		Controller:     common.HexToAddress("0x253553366Da8546fC250F225fe3d25d0C782303b"),
+		BaseRegistrar:  common.HexToAddress("0x57f1887a8BF19b14fC0dF6Fd9B2acc9Af147eA85"),
+		NameWrapper:    common.HexToAddress("0xD4416b13d2b3a9aBae7AcD5D6C2BbDBE25686401"),
+		PublicResolver: common.HexToAddress("0x231b0Ee14048e9dCcD1d247744d114a4EB5E8E63"),
+	},
+	"11155111": {
+		Registry:       ensRegistry,
+		Controller:     common.HexToAddress("0xFED6a969AaA60E4961FCD3EBF1A2e8913ac65B72"),
+		BaseRegistrar:  common.HexToAddress("0x57f1887a8BF19b14fC0dF6Fd9B2acc9Af147eA85"),
+		NameWrapper:    common.HexToAddress("0x0635513f179D50A207757E05759CbD106d7dFcE8"),
+		PublicResolver: common.HexToAddress("0x8FADE66B79cC9f707aB26799354482EB93a5B7dD"),
+	},
+}
+
+// ENSDeployment is the ENS deployment of the chain.
+func (spec *ConfigSpec) ENSDeployment() (*ENSDeployment, error) {
+	deployment, ok := ensDeployments[spec.ChainID]
+	if !ok {
+		return nil, fmt.Errorf("ENS is not known to be deployed on chain %s", spec.ChainID)
+	}
+	return deployment, nil
+}
+
+// ENSLabel is the label of the .eth name registered with the controller, e.g. vault of vault.eth.
+func ENSLabel(name string) (string, error) {
+	name = strings.ToLower(name)
+	label := strings.TrimSuffix(name, ".eth")
+	if label == name || len(label) == 0 || strings.Contains(label, ".") {
+		return "", fmt.Errorf("%s is not a .eth name, subnames are created by their parent", name)
+	} else if len([]rune(label)) < 3 {
+		return "", fmt.Errorf("%s is too short, .eth names have at least 3 characters", name)
+	}
+	return label, nil
+}
+
+// ENSCommitment is the commitment of the .eth name registration, stored as runs/ens-<name>.json, so
+// the registration interrupted while waiting for the commitment to mature doesn't commit again.
+type ENSCommitment struct {
+	Name      string         `json:"name"`
+	Owner     common.Address `json:"owner"`
+	Duration  uint64         `json:"duration"`
+	Secret    common.Hash    `json:"secret"`
+	Resolver  common.Address `json:"resolver"`
+	TxHash    string         `json:"txHash"`
+	Committed time.Time      `json:"committed"`
+
+	specDir string
+}
+
+func NewENSCommitment(specDir, name string, owner common.Address, duration uint64,
+	secret common.Hash, resolver common.Address) *ENSCommitment {
+	return &ENSCommitment{
+		Name:     strings.ToLower(name),
+		Owner:    owner,
+		Duration: duration,
+		Secret:   secret,
+		Resolver: resolver,
+
+		specDir: specDir,
+	}
+}
+
+// LoadENSCommitment loads the commitment of the name, it's nil if there is none.
+func LoadENSCommitment(specDir, name string) (*ENSCommitment, error) {
+	data, err := ioutil.ReadFile(runJournalPath(specDir, ensCommitmentID(name)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	commitment := &ENSCommitment{
+		specDir: specDir,
+	}
+	if err := json.Unmarshal(data, commitment); err != nil {
+		return nil, err
+	}
+	return commitment, nil
+}
+
+func ensCommitmentID(name string) string {
+	return "ens-" + strings.ToLower(name)
+}
+
+// Save stores the commitment once it's sent.
+func (c *ENSCommitment) Save() error {
+	if err := os.MkdirAll(filepath.Join(c.specDir, runsDir), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(runJournalPath(c.specDir, ensCommitmentID(c.Name)), data, 0644)
+}
+
+// Remove deletes the commitment once the name is registered.
+func (c *ENSCommitment) Remove() error {
+	return os.Remove(runJournalPath(c.specDir, ensCommitmentID(c.Name)))
+}
+
+// ParseContenthash encodes the EIP-1577 contenthash of ipfs://<CIDv0>, or takes it as hex.
+func ParseContenthash(str string) ([]byte, error) {
+	if strings.HasPrefix(str, "0x") {
+		return hexutil.Decode(str)
+	} else if !strings.HasPrefix(str, "ipfs://") {
+		return nil, errors.New("contenthash must be ipfs://Qm... or hex starting from 0x")
+	}
+	cid := strings.TrimPrefix(str, "ipfs://")
+	if !strings.HasPrefix(cid, "Qm") {
+		return nil, errors.New("only CIDv0 (Qm...) is supported, pass the contenthash in hex for others")
+	}
+	multihash, err := decodeBase58(cid)
+	if err != nil {
+		return nil, err
+	} else if len(multihash) != 34 {
+		return nil, errors.New("CIDv0 must be a sha2-256 multihash")
+	}
+	// ipfs-ns, CIDv1, dag-pb, then the multihash
+	return append([]byte{0xe3, 0x01, 0x01, 0x70}, multihash...), nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func decodeBase58(str string) ([]byte, error) {
+	value := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range str {
+		digit := strings.IndexRune(base58Alphabet, r)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", r)
+		}
+		value.Mul(value, radix).Add(value, big.NewInt(int64(digit)))
+	}
+	var zeros int
+	for zeros < len(str) && str[zeros] == '1' {
+		zeros++
+	}
+	return append(make([]byte, zeros), value.Bytes()...), nil
+}
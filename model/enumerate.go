@@ -0,0 +1,66 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// EnumerateSpec turns a view of the at(i) style accessor into the full list, the length
+// is taken from the length() style method with the same params, except the index.
+type EnumerateSpec struct {
+	Length      string `yaml:"length"`
+	Concurrency int    `yaml:"concurrency"`
+	Rate        int    `yaml:"rate"`
+	BatchSize   int    `yaml:"batchSize"`
+}
+
+const (
+	defaultEnumerateConcurrency = 4
+	defaultEnumerateBatchSize   = 100
+)
+
+// validate checks the accessors against the ABI, it returns the method inputs
+// without the trailing index, these are the params of the view command.
+func (spec *EnumerateSpec) validate(root *Spec,
+	instance *ContractInstanceSpec, method abi.Method) (abi.Arguments, error) {
+	inputs := method.Inputs
+	if len(inputs) == 0 {
+		return nil, errors.New("enumerated method must take the index as the last input")
+	} else if index := inputs[len(inputs)-1].Type; index.T != abi.UintTy && index.T != abi.IntTy {
+		return nil, fmt.Errorf("index input of the enumerated method must be an integer, got %s", index.String())
+	}
+	inputs = inputs[:len(inputs)-1]
+	if len(spec.Length) == 0 {
+		return nil, errors.New("no length method is specified")
+	}
+	lengthName, err := root.Contracts.resolveMethod(instance, spec.Length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve length method: %v", err)
+	}
+	spec.Length = lengthName
+	length, ok := instance.BoundContract().ABI().Methods[lengthName]
+	if !ok {
+		return nil, fmt.Errorf("length method %s not found in contract ABI", lengthName)
+	} else if len(length.Outputs) != 1 || length.Outputs[0].Type.T != abi.UintTy {
+		return nil, errors.New("length method must return a single uint")
+	} else if len(length.Inputs) != len(inputs) {
+		return nil, errors.New("length method must take the same inputs as the enumerated method, except the index")
+	}
+	for i, input := range length.Inputs {
+		if input.Type.String() != inputs[i].Type.String() {
+			return nil, fmt.Errorf("length method input %d type mismatch: %s vs %s",
+				i, input.Type.String(), inputs[i].Type.String())
+		}
+	}
+	if spec.Concurrency < 0 || spec.Rate < 0 || spec.BatchSize < 0 {
+		return nil, errors.New("concurrency, rate and batchSize must not be negative")
+	} else if spec.Concurrency == 0 {
+		spec.Concurrency = defaultEnumerateConcurrency
+	}
+	if spec.BatchSize == 0 {
+		spec.BatchSize = defaultEnumerateBatchSize
+	}
+	return inputs, nil
+}
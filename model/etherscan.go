@@ -0,0 +1,177 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/AtlantPlatform/ethfw/sol"
+)
+
+const (
+	ABISourceEtherscan = "etherscan"
+
+	defaultEtherscanURL = "https://api.etherscan.io/v2/api"
+	etherscanAPIKeyEnv  = "ETHERSCAN_API_KEY"
+
+	// etherscanPageSize is the number of the transactions per query, the API returns at most
+	// 10000 per range, so the next query starts from the block of the last one.
+	etherscanPageSize = 1000
+)
+
+// Etherscan account actions listing the transactions of an address.
+const (
+	EtherscanTxList         = "txlist"
+	EtherscanTxListInternal = "txlistinternal"
+	EtherscanTokenTx        = "tokentx"
+)
+
+type etherscanResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// fetchEtherscanABI gets the verified contract ABI from Etherscan, results are cached
+// on disk per chain and address, so the API is queried only once.
+func fetchEtherscanABI(ctx AppContext, config *ConfigSpec, name, address string) (*sol.Contract, error) {
+	address = strings.ToLower(address)
+	path := cachePath(ctx.SpecDir(), "etherscan", config.ChainID, address+".json")
+	contract := &sol.Contract{
+		Name:       name,
+		SourcePath: path,
+	}
+	if data, ok := readCache(path); ok {
+		contract.ABI = data
+		return contract, nil
+	}
+	query := url.Values{}
+	query.Set("module", "contract")
+	query.Set("action", "getabi")
+	query.Set("address", address)
+	var result string
+	if err := etherscanGet(ctx, config, query, &result); err != nil {
+		return nil, err
+	}
+	data := []byte(result)
+	if err := checkABI(data); err != nil {
+		err = fmt.Errorf("etherscan returned malformed ABI: %v", err)
+		return nil, err
+	}
+	if err := writeCache(path, data); err != nil {
+		return nil, errors.New("failed to cache ABI: " + err.Error())
+	}
+	contract.ABI = data
+	return contract, nil
+}
+
+// EtherscanTx is the transaction of an account list of Etherscan, the fields set depend on the action:
+// the normal transactions have the gas and the method, the token transfers have the token contract.
+type EtherscanTx struct {
+	BlockNumber     string `json:"blockNumber"`
+	TimeStamp       string `json:"timeStamp"`
+	Hash            string `json:"hash"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Value           string `json:"value"`
+	GasUsed         string `json:"gasUsed"`
+	GasPrice        string `json:"gasPrice"`
+	IsError         string `json:"isError"`
+	FunctionName    string `json:"functionName"`
+	ContractAddress string `json:"contractAddress"`
+	TokenSymbol     string `json:"tokenSymbol"`
+	TokenDecimal    string `json:"tokenDecimal"`
+	LogIndex        string `json:"logIndex"`
+}
+
+// EtherscanTxs lists the transactions of the address in the blocks from to, in the order of blocks,
+// with the account action txlist, txlistinternal or tokentx.
+func (config *ConfigSpec) EtherscanTxs(ctx AppContext, action, address string, from, to uint64) ([]*EtherscanTx, error) {
+	var txs []*EtherscanTx
+	for start := from; start <= to; {
+		query := url.Values{}
+		query.Set("module", "account")
+		query.Set("action", action)
+		query.Set("address", address)
+		query.Set("startblock", strconv.FormatUint(start, 10))
+		query.Set("endblock", strconv.FormatUint(to, 10))
+		query.Set("page", "1")
+		query.Set("offset", strconv.Itoa(etherscanPageSize))
+		query.Set("sort", "asc")
+		var page []*EtherscanTx
+		if err := etherscanGet(ctx, config, query, &page); err != nil {
+			return nil, err
+		}
+		if len(page) < etherscanPageSize {
+			return append(txs, page...), nil
+		}
+		last, err := strconv.ParseUint(page[len(page)-1].BlockNumber, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("etherscan returned malformed block number: %v", err)
+		}
+		// the transactions of the last block are queried again, unless the page has no other block
+		if lastBlock := page[len(page)-1].BlockNumber; page[0].BlockNumber != lastBlock {
+			for page[len(page)-1].BlockNumber == lastBlock {
+				page = page[:len(page)-1]
+			}
+			start = last
+		} else {
+			start = last + 1
+		}
+		txs = append(txs, page...)
+	}
+	return txs, nil
+}
+
+// etherscanGet queries the API with the key and the chain of the config, decoding the result.
+// The empty lists are reported with status 0, so they are not errors.
+func etherscanGet(ctx AppContext, config *ConfigSpec, query url.Values, result interface{}) error {
+	apiKey := config.EtherscanAPIKey
+	if len(apiKey) == 0 {
+		apiKey = os.Getenv(etherscanAPIKeyEnv)
+	}
+	if len(apiKey) == 0 {
+		err := fmt.Errorf("no Etherscan API key, set etherscanAPIKey in config or %s env", etherscanAPIKeyEnv)
+		return err
+	}
+	apiURL := config.EtherscanURL
+	if len(apiURL) == 0 {
+		apiURL = defaultEtherscanURL
+	}
+	query.Set("chainid", config.ChainID)
+	query.Set("apikey", apiKey)
+	req, err := http.NewRequest("GET", apiURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	} else if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("etherscan responded with status %s", resp.Status)
+		return err
+	}
+	var response etherscanResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return err
+	} else if response.Status != "1" && !strings.HasPrefix(response.Message, "No transactions found") {
+		var message string
+		if err := json.Unmarshal(response.Result, &message); err != nil {
+			message = string(response.Result)
+		}
+		err := fmt.Errorf("etherscan error: %s: %s", response.Message, message)
+		return err
+	}
+	return json.Unmarshal(response.Result, result)
+}
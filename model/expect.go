@@ -0,0 +1,193 @@
+package model
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ExpectSpec declares assertions on the view result, so the playbook can serve as a
+// post-deployment smoke test. All specified assertions must hold.
+type ExpectSpec struct {
+	Equals  interface{}   `yaml:"equals"`
+	GT      string        `yaml:"gt"`
+	GTE     string        `yaml:"gte"`
+	LT      string        `yaml:"lt"`
+	LTE     string        `yaml:"lte"`
+	Matches string        `yaml:"matches"`
+	OneOf   []interface{} `yaml:"oneOf"`
+
+	equals *string                `yaml:"-"`
+	bounds map[CompareOp]*big.Int `yaml:"-"`
+	rx     *regexp.Regexp         `yaml:"-"`
+	oneOf  []string               `yaml:"-"`
+}
+
+func (spec *ExpectSpec) Validate(ctx AppContext, name string, root *Spec) bool {
+	validateLog := log.WithFields(log.Fields{
+		"section": "ViewCommands",
+		"command": name,
+		"func":    "Expect",
+	})
+	if spec.Equals != nil {
+		equals := expectedValue(root, spec.Equals)
+		spec.equals = &equals
+	}
+	spec.bounds = make(map[CompareOp]*big.Int)
+	for op, bound := range map[CompareOp]string{
+		CompareGT: spec.GT,
+		CompareGE: spec.GTE,
+		CompareLT: spec.LT,
+		CompareLE: spec.LTE,
+	} {
+		if len(bound) == 0 {
+			continue
+		}
+		v, ok := new(big.Int).SetString(bound, 0)
+		if !ok {
+			validateLog.WithField("bound", bound).Errorln("comparison bound must be a number")
+			return false
+		}
+		spec.bounds[op] = v
+	}
+	if len(spec.Matches) > 0 {
+		rx, err := regexp.Compile(spec.Matches)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to compile 'matches' regexp")
+			return false
+		}
+		spec.rx = rx
+	}
+	for _, v := range spec.OneOf {
+		spec.oneOf = append(spec.oneOf, expectedValue(root, v))
+	}
+	if spec.equals == nil && len(spec.bounds) == 0 && spec.rx == nil && len(spec.oneOf) == 0 {
+		validateLog.Errorln("expect has no assertions")
+		return false
+	}
+	return true
+}
+
+// expectedValue resolves wallet references into addresses, so expectations can name wallets.
+func expectedValue(root *Spec, v interface{}) string {
+	str := nillableStr(v)
+	if isWalletRef(str) {
+		if wallet, ok := root.Wallets.WalletSpec(str[1:]); ok {
+			return wallet.Address
+		}
+	}
+	return str
+}
+
+// ExpectationError describes the mismatch between the expected and actual view result,
+// or the expected and emitted events of a write command.
+type ExpectationError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ExpectationError) Error() string {
+	return fmt.Sprintf("expectation failed: expected %s, got %s", e.Expected, e.Actual)
+}
+
+// Diff renders the mismatch in a diff-style form.
+func (e *ExpectationError) Diff() string {
+	return fmt.Sprintf("- %s\n+ %s", e.Expected, e.Actual)
+}
+
+// Check asserts the view result, it returns *ExpectationError on a mismatch.
+func (spec *ExpectSpec) Check(result interface{}) error {
+	actual := ExpectFormat(result)
+	if spec.equals != nil && !expectEqual(*spec.equals, actual) {
+		return &ExpectationError{Expected: *spec.equals, Actual: actual}
+	}
+	for _, op := range []CompareOp{CompareGT, CompareGE, CompareLT, CompareLE} {
+		bound, ok := spec.bounds[op]
+		if !ok {
+			continue
+		}
+		expected := fmt.Sprintf("%s %s", op, bound)
+		v, ok := new(big.Int).SetString(actual, 10)
+		if !ok || !op.Compare(v, bound) {
+			return &ExpectationError{Expected: expected, Actual: actual}
+		}
+	}
+	if spec.rx != nil && !spec.rx.MatchString(actual) {
+		expected := fmt.Sprintf("matching /%s/", spec.rx.String())
+		return &ExpectationError{Expected: expected, Actual: actual}
+	}
+	if len(spec.oneOf) > 0 {
+		var found bool
+		for _, v := range spec.oneOf {
+			if expectEqual(v, actual) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			expected := fmt.Sprintf("one of [%s]", strings.Join(spec.oneOf, ", "))
+			return &ExpectationError{Expected: expected, Actual: actual}
+		}
+	}
+	return nil
+}
+
+// expectEqual compares numbers by value and addresses case-insensitively.
+func expectEqual(expected, actual string) bool {
+	if expected == actual {
+		return true
+	}
+	if common.IsHexAddress(expected) && common.IsHexAddress(actual) {
+		return strings.EqualFold(expected, actual)
+	}
+	x, ok := new(big.Int).SetString(expected, 0)
+	if !ok {
+		return false
+	}
+	y, ok := new(big.Int).SetString(actual, 0)
+	return ok && x.Cmp(y) == 0
+}
+
+// ExpectFormat formats the view result for assertions, numbers as decimals,
+// addresses and bytes as hex, lists as comma-separated values in brackets.
+func ExpectFormat(v interface{}) string {
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case common.Address:
+		return strings.ToLower(vv.Hex())
+	case []byte:
+		return hexutil.Encode(vv)
+	case [32]byte:
+		return hexutil.Encode(vv[:])
+	case common.Hash:
+		return vv.Hex()
+	case []common.Address:
+		items := make([]interface{}, len(vv))
+		for i, item := range vv {
+			items[i] = item
+		}
+		return ExpectFormat(items)
+	case []*big.Int:
+		items := make([]interface{}, len(vv))
+		for i, item := range vv {
+			items[i] = item
+		}
+		return ExpectFormat(items)
+	case *TupleValue:
+		return ExpectFormat(vv.value)
+	case []interface{}:
+		items := make([]string, len(vv))
+		for i, item := range vv {
+			items[i] = ExpectFormat(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprintf("%v", vv)
+	}
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EventExpectation is the event a write command expects in the logs of its transactions, given as
+// Event(arg=value, ...) or contract.Event(arg=value, ...), with the filters of the logs command.
+// Without the contract, the event is looked up in the ABI of the instance, then in the ABIs
+// of the spec, and may be emitted by any contract, e.g. the Transfer of a token sold by a market.
+type EventExpectation struct {
+	Text     string
+	Contract string
+	Event    string
+	Filters  []*LogFilter
+
+	abiEvent  *abi.Event
+	addresses []common.Address
+}
+
+// ABIEvent returns the expected event.
+func (spec *EventExpectation) ABIEvent() *abi.Event {
+	return spec.abiEvent
+}
+
+// Emitters returns the addresses of the contract of the expectation, nil means any emitter.
+func (spec *EventExpectation) Emitters() []common.Address {
+	return spec.addresses
+}
+
+// validateExpectEvents parses the expectEvents of the command, the integer args may be given
+// as token amounts, e.g. 100 DAI, or in the exponent notation, e.g. 100e18.
+func (spec *WriteCmdSpec) validateExpectEvents(ctx AppContext, root *Spec) error {
+	spec.expectEvents = make([]*EventExpectation, 0, len(spec.ExpectEvents))
+	for _, text := range spec.ExpectEvents {
+		head, filters, err := parseEventFilters(text, "expected event")
+		if err != nil {
+			return err
+		}
+		expectation := &EventExpectation{
+			Text:    text,
+			Event:   head,
+			Filters: filters,
+		}
+		if dot := strings.LastIndexByte(head, '.'); dot > 0 {
+			expectation.Contract, expectation.Event = head[:dot], head[dot+1:]
+			expectation.abiEvent, expectation.addresses, err = root.LookupEvent(expectation.Contract, expectation.Event)
+			if err != nil {
+				return err
+			}
+		} else if expectation.abiEvent = spec.lookupExpectedEvent(root, head); expectation.abiEvent == nil {
+			if err := root.Contracts.unsupportedEntry("", head); err != nil {
+				return err
+			}
+			return fmt.Errorf("event %s is not found in the ABIs of the spec", head)
+		}
+		var unresolved bool
+		for _, filter := range filters {
+			if isArgRef(filter.Value) {
+				argID, err := argReferenceID(filter.Value)
+				if err != nil {
+					return err
+				} else if argID >= len(ctx.AppCommandArgs()) {
+					// the command is not run with the args given
+					unresolved = true
+					continue
+				}
+				filter.Value = ctx.AppCommandArgs()[argID]
+			}
+		}
+		if unresolved {
+			continue
+		} else if err := root.resolveAmountFilters(expectation.abiEvent, filters); err != nil {
+			return err
+		} else if _, err := root.ResolveLogFilters(expectation.abiEvent, filters); err != nil {
+			return err
+		}
+		spec.expectEvents = append(spec.expectEvents, expectation)
+	}
+	return nil
+}
+
+// lookupExpectedEvent finds the event in the ABI of the instance, then in any ABI of the spec.
+func (spec *WriteCmdSpec) lookupExpectedEvent(root *Spec, name string) *abi.Event {
+	var abis []abi.ABI
+	if spec.Instance != nil && spec.Instance.BoundContract() != nil {
+		abis = append(abis, spec.Instance.BoundContract().ABI())
+	}
+	for _, contractSpec := range root.Contracts {
+		abis = append(abis, contractSpec.abi)
+	}
+	abis = append(abis, erc20ABI())
+	for _, contractABI := range abis {
+		if abiEvent, ok := findEventByName(contractABI, name); ok {
+			return abiEvent
+		}
+	}
+	return nil
+}
+
+// resolveAmountFilters converts the values of the filters of the integer args to integers.
+func (spec *Spec) resolveAmountFilters(abiEvent *abi.Event, filters []*LogFilter) error {
+	for _, filter := range filters {
+		for _, input := range abiEvent.Inputs {
+			if input.Name != filter.Arg || (input.Type.T != abi.IntTy && input.Type.T != abi.UintTy) {
+				continue
+			}
+			amount, err := spec.expectedAmount(filter.Value)
+			if err != nil {
+				return fmt.Errorf("filter of %s: %v", filter.Arg, err)
+			}
+			filter.Value = amount.String()
+		}
+	}
+	return nil
+}
+
+// expectedAmount parses the integer arg of the filter: a token amount, or a number
+// in the exponent notation that has no fraction.
+func (spec *Spec) expectedAmount(value string) (*big.Int, error) {
+	if amount, ok, err := spec.tokenAmount(value); err != nil {
+		return nil, err
+	} else if ok {
+		return amount, nil
+	}
+	amount, ok := new(big.Rat).SetString(value)
+	if !ok || !amount.IsInt() {
+		return nil, fmt.Errorf("%s is not an integer", value)
+	}
+	return new(big.Int).Set(amount.Num()), nil
+}
+
+// countExpectEventArgs adds the CLI args referenced by the filters of the expected events.
+func (spec *WriteCmdSpec) countExpectEventArgs(set map[int]struct{}) {
+	for _, text := range spec.ExpectEvents {
+		if _, filters, err := parseEventFilters(text, "expected event"); err == nil {
+			for _, filter := range filters {
+				countKindArgs(set, filter.Value)
+			}
+		}
+	}
+}
+
+// EventExpectations returns the parsed expectEvents of the command.
+func (spec *WriteCmdSpec) EventExpectations() []*EventExpectation {
+	return spec.expectEvents
+}
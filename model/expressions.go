@@ -0,0 +1,355 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Expression is a parsed expression over references, like `@balances.treasury * 2 / 10`, with arithmetic,
+// comparisons and function calls. The references are substituted with their values lazily, right before
+// the evaluation during execution, so outputs of commands run earlier can be used.
+type Expression struct {
+	Expr string
+
+	// parts are the literal chunks of the expression, interleaved with the references.
+	parts []interface{}
+}
+
+// Parts returns the literal chunks (strings) of the expression and the references (*WalletFieldReference,
+// *ArgReference, *CommandOutputReference, *ContractCallReference, *FunctionCall).
+func (e *Expression) Parts() []interface{} {
+	return e.parts
+}
+
+// IsReference reports whether the expression is a single reference, its value is used as is then.
+func (e *Expression) IsReference() bool {
+	return len(e.parts) == 3 &&
+		len(strings.TrimSpace(e.parts[0].(string))) == 0 &&
+		len(strings.TrimSpace(e.parts[2].(string))) == 0
+}
+
+// ContractCallReference is a call of the contract view method within an expression,
+// like @token.balanceOf(@treasury), made on the first instance of the contract.
+type ContractCallReference struct {
+	Instance *ContractInstanceSpec
+	Method   string
+	Params   []interface{}
+}
+
+// FunctionCall is a call of a built-in function within an expression, like max(@a, @b) or len(@holders).
+// The args are references, constants, or *Expression for the computed ones, like max(abs(@a), @b * 2).
+type FunctionCall struct {
+	Func string
+	Args []interface{}
+}
+
+// ExpressionFuncs are the functions available in expressions, with the number of args, -1 for any.
+var ExpressionFuncs = map[string]int{
+	// len is the length of a list output
+	"len": 1,
+	// sum is the sum of numbers in a list output
+	"sum": 1,
+	// min and max of the args, or of numbers in a single list output
+	"min": -1,
+	"max": -1,
+	"abs": 1,
+}
+
+var (
+	argRefRx = regexp.MustCompile(`\$\d+`)
+	// argRefPrefixRx is the CLI arg reference at the position of the parser.
+	argRefPrefixRx = regexp.MustCompile(`^\$\d+`)
+	// refNameRx is the name of a wallet, command or contract reference, with the optional field or method.
+	refNameRx = regexp.MustCompile(`^@[\w/-]+(\.\w+)?`)
+)
+
+// ParseExpression parses the expression over references of the spec, like the expr of params.
+func (spec *Spec) ParseExpression(ctx AppContext, expr string) (*Expression, error) {
+	return parseExpression(ctx, spec, expr)
+}
+
+func parseExpression(ctx AppContext, root *Spec, expr string) (*Expression, error) {
+	p := &exprParser{
+		ctx:  ctx,
+		root: root,
+		expr: expr,
+	}
+	e, err := p.parseExpr(false)
+	if err != nil {
+		return nil, err
+	}
+	e.Expr = expr
+	return e, nil
+}
+
+// exprParser is a recursive-descent parser of the references and function calls of an expression,
+// the arithmetic and comparisons between them are left to the evaluator, as literal chunks.
+type exprParser struct {
+	ctx  AppContext
+	root *Spec
+	expr string
+	pos  int
+}
+
+// parseExpr parses the expression up to the end, or the arg of a call up to its comma or closing paren.
+func (p *exprParser) parseExpr(arg bool) (*Expression, error) {
+	start := p.pos
+	e := new(Expression)
+	literal := new(strings.Builder)
+	depth := 0
+	addRef := func(ref interface{}) {
+		e.parts = append(e.parts, literal.String(), ref)
+		literal.Reset()
+	}
+loop:
+	for p.pos < len(p.expr) {
+		rest := p.expr[p.pos:]
+		switch c := rest[0]; {
+		case c == '"' || c == '`':
+			end := strings.IndexByte(rest[1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", p.pos)
+			}
+			literal.WriteString(rest[:end+2])
+			p.pos += end + 2
+		case c == '(':
+			depth++
+			literal.WriteByte(c)
+			p.pos++
+		case c == ')' && depth > 0:
+			depth--
+			literal.WriteByte(c)
+			p.pos++
+		case c == ')' && arg, c == ',' && arg && depth == 0:
+			break loop
+		case c == ')':
+			return nil, fmt.Errorf("unbalanced parenthesis at %d", p.pos)
+		case argRefPrefixRx.MatchString(rest):
+			str := argRefPrefixRx.FindString(rest)
+			ref, err := newArgReference(p.ctx, str)
+			if err != nil {
+				return nil, err
+			}
+			p.pos += len(str)
+			addRef(ref)
+		case refNameRx.MatchString(rest):
+			ref, err := p.parseRef()
+			if err != nil {
+				return nil, err
+			}
+			addRef(ref)
+		case isIdentStart(c) && (p.pos == 0 || !isIdentChar(p.expr[p.pos-1])):
+			ident := rest
+			if end := strings.IndexFunc(rest, func(r rune) bool { return r > 0x7f || !isIdentChar(byte(r)) }); end >= 0 {
+				ident = rest[:end]
+			}
+			if _, ok := ExpressionFuncs[ident]; ok && strings.HasPrefix(rest[len(ident):], "(") {
+				call, err := p.parseFunctionCall(ident)
+				if err != nil {
+					return nil, err
+				}
+				addRef(call)
+				continue
+			}
+			literal.WriteString(ident)
+			p.pos += len(ident)
+		default:
+			literal.WriteByte(c)
+			p.pos++
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("unbalanced parenthesis in %s", p.expr[start:])
+	}
+	e.parts = append(e.parts, literal.String())
+	e.Expr = strings.TrimSpace(p.expr[start:p.pos])
+	return e, nil
+}
+
+// parseArgs parses the args of the call, starting at its open paren and ending after the closing one.
+func (p *exprParser) parseArgs(name string) ([]*Expression, error) {
+	p.pos++ // (
+	var args []*Expression
+	if p.pos < len(p.expr) && strings.HasPrefix(strings.TrimSpace(p.expr[p.pos:]), ")") {
+		p.pos += strings.IndexByte(p.expr[p.pos:], ')') + 1
+		return nil, nil
+	}
+	for {
+		arg, err := p.parseExpr(true)
+		if err != nil {
+			return nil, err
+		} else if len(arg.Expr) == 0 {
+			return nil, fmt.Errorf("%s: empty argument", name)
+		}
+		args = append(args, arg)
+		if p.pos >= len(p.expr) {
+			return nil, fmt.Errorf("%s: missing closing parenthesis", name)
+		}
+		p.pos++
+		if p.expr[p.pos-1] == ')' {
+			return args, nil
+		}
+	}
+}
+
+func (p *exprParser) parseRef() (interface{}, error) {
+	str := refNameRx.FindString(p.expr[p.pos:])
+	p.pos += len(str)
+	if strings.Contains(str, refDelim) && strings.HasPrefix(p.expr[p.pos:], "(") {
+		return p.parseContractCallRef(str)
+	}
+	if ref, ok := newCommandOutputReference(p.root, str); ok {
+		return ref, nil
+	}
+	ref, err := newWalletFieldReference(p.root, str)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reference %s: %v", str, err)
+	}
+	return ref, nil
+}
+
+func (p *exprParser) parseFunctionCall(name string) (*FunctionCall, error) {
+	p.pos += len(name)
+	args, err := p.parseArgs(name)
+	if err != nil {
+		return nil, err
+	}
+	call := &FunctionCall{
+		Func: name,
+	}
+	for _, arg := range args {
+		value, err := p.functionArg(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s(%s): %v", name, arg.Expr, err)
+		}
+		call.Args = append(call.Args, value)
+	}
+	if arity := ExpressionFuncs[call.Func]; arity >= 0 && len(call.Args) != arity {
+		return nil, fmt.Errorf("function %s takes %d args", call.Func, arity)
+	} else if len(call.Args) == 0 {
+		return nil, fmt.Errorf("function %s takes at least one arg", call.Func)
+	}
+	return call, nil
+}
+
+// functionArg is the reference of the arg, its constant value, or the arg expression itself.
+func (p *exprParser) functionArg(arg *Expression) (interface{}, error) {
+	if arg.IsReference() {
+		return arg.parts[1], nil
+	} else if len(arg.parts) > 1 {
+		return arg, nil
+	}
+	return parseExpressionArg(p.ctx, p.root, arg.Expr)
+}
+
+func (p *exprParser) parseContractCallRef(str string) (*ContractCallReference, error) {
+	parts := strings.SplitN(str[1:], refDelim, 2)
+	contract, ok := p.root.Contracts.ContractSpec(parts[0])
+	if !ok || contract == nil || len(contract.Instances) == 0 {
+		return nil, fmt.Errorf("contract %s is not found or has no instances", parts[0])
+	}
+	ref := &ContractCallReference{
+		Instance: contract.Instances[0],
+	}
+	methodName, err := p.root.Contracts.resolveMethod(ref.Instance, parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve method %s: %v", parts[1], err)
+	}
+	ref.Method = methodName
+	method, ok := ref.Instance.BoundContract().ABI().Methods[methodName]
+	if !ok {
+		return nil, fmt.Errorf("method %s not found in contract ABI", methodName)
+	} else if !method.Const {
+		return nil, fmt.Errorf("method %s is not a view", methodName)
+	} else if ref.Instance.HasTupleParams(methodName) {
+		return nil, fmt.Errorf("method %s has struct params, which can't be passed in expressions", methodName)
+	}
+	args, err := p.parseArgs(str)
+	if err != nil {
+		return nil, err
+	}
+	var params ParamSpec
+	for _, arg := range args {
+		// the params are passed to the call as they are, so they can't be computed
+		var value interface{}
+		switch {
+		case arg.IsReference():
+			value = arg.parts[1]
+			switch value.(type) {
+			case *ArgReference, *WalletFieldReference, *CommandOutputReference:
+			default:
+				err = errors.New("a contract call argument must be a reference, an address or an integer")
+			}
+		case len(arg.parts) > 1:
+			err = errors.New("a contract call argument must be a reference, an address or an integer")
+		default:
+			value, err = parseExpressionArg(p.ctx, p.root, arg.Expr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s(%s): %v", str, arg.Expr, err)
+		}
+		params.paramValues = append(params.paramValues, value)
+	}
+	if err := params.validateABI(method.Inputs); err != nil {
+		return nil, fmt.Errorf("%s: %v", str, err)
+	}
+	ref.Params = params.paramValues
+	return ref, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func parseExpressionArg(ctx AppContext, root *Spec, arg string) (interface{}, error) {
+	switch {
+	case isArgRef(arg):
+		return newArgReference(ctx, arg)
+	case isWalletRef(arg):
+		if ref, ok := newCommandOutputReference(root, arg); ok {
+			return ref, nil
+		}
+		return newWalletFieldReference(root, arg)
+	case common.IsHexAddress(arg):
+		return common.HexToAddress(arg), nil
+	}
+	result, err := NewEvaler().Run(arg, ExprTypeInterger)
+	if err != nil {
+		return nil, fmt.Errorf("argument %s must be a reference, an address or an integer", arg)
+	}
+	return result.(*big.Int), nil
+}
+
+// ParamExpression is a param given as an expression with `expr`, it's evaluated right before the
+// command is run, the result is converted to the param type.
+type ParamExpression struct {
+	*Expression
+	Type ParamType
+
+	input *abi.Type
+}
+
+// Convert converts the evaluated value to the Go type expected by the ABI encoder.
+func (p *ParamExpression) Convert(value interface{}) (interface{}, error) {
+	if v, ok := value.(*big.Float); ok {
+		return nil, fmt.Errorf("expression result %s is not an integer", v.String())
+	} else if v, ok := value.(*big.Int); ok && p.input != nil {
+		return convertInt(v, *p.input)
+	} else if v, ok := value.(string); ok && p.Type == ParamTypeAddress {
+		if !common.IsHexAddress(v) {
+			return nil, errors.New("expression result is not an address")
+		}
+		return common.HexToAddress(v), nil
+	}
+	return value, nil
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	root := &Spec{
+		ViewCmds: ViewCmds{
+			"holders":  &ViewCmdSpec{},
+			"balances": &ViewCmdSpec{},
+		},
+	}
+	ctx := NewAppContext(context.Background(), "target", []string{"sweep", "all"}, "", "", nil, nil)
+	tests := []struct {
+		expr  string
+		parts string
+		err   string
+	}{{
+		expr:  "@holders * 2",
+		parts: `"" output(holders) " * 2"`,
+	}, {
+		expr:  "max(abs($1), len(@holders))",
+		parts: `"" max(abs(arg(1)), len(output(holders))) ""`,
+	}, {
+		expr:  "min(@balances.treasury * 2, sum(@holders)) / 10 > 0",
+		parts: `"" min(expr("" output(balances.treasury) " * 2"), sum(output(holders))) " / 10 > 0"`,
+	}, {
+		expr:  `$1 == "max(" && len(@holders) > 0`,
+		parts: `"" arg(1) " == \"max(\" && " len(output(holders)) " > 0"`,
+	}, {
+		expr:  "(1 + 2) * max(1, 1e3)",
+		parts: `"(1 + 2) * " max(1, 1000) ""`,
+	}, {
+		expr: "max(abs(@holders)",
+		err:  "missing closing parenthesis",
+	}, {
+		expr: "abs(@holders, 1)",
+		err:  "takes 1 args",
+	}, {
+		expr: "max(1,)",
+		err:  "empty argument",
+	}, {
+		expr: "@holders)",
+		err:  "unbalanced parenthesis",
+	}}
+	for _, tt := range tests {
+		e, err := parseExpression(ctx, root, tt.expr)
+		if len(tt.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got error %v, want %q", tt.expr, err, tt.err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if parts := formatParts(e.Parts()); parts != tt.parts {
+			t.Errorf("%s: got parts\n%s\nwant\n%s", tt.expr, parts, tt.parts)
+		}
+	}
+}
+
+func formatParts(parts []interface{}) string {
+	formatted := make([]string, len(parts))
+	for i, part := range parts {
+		formatted[i] = formatPart(part)
+	}
+	return strings.Join(formatted, " ")
+}
+
+func formatPart(part interface{}) string {
+	switch p := part.(type) {
+	case string:
+		return fmt.Sprintf("%q", p)
+	case *ArgReference:
+		return fmt.Sprintf("arg(%d)", p.ArgID)
+	case *CommandOutputReference:
+		if len(p.Field) > 0 {
+			return fmt.Sprintf("output(%s.%s)", p.CmdName, p.Field)
+		}
+		return fmt.Sprintf("output(%s)", p.CmdName)
+	case *FunctionCall:
+		args := make([]string, len(p.Args))
+		for i, arg := range p.Args {
+			args[i] = formatPart(arg)
+		}
+		return fmt.Sprintf("%s(%s)", p.Func, strings.Join(args, ", "))
+	case *Expression:
+		return fmt.Sprintf("expr(%s)", formatParts(p.Parts()))
+	case *big.Int:
+		return p.String()
+	}
+	return fmt.Sprintf("%T", part)
+}
@@ -0,0 +1,428 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// nodeCooldown is the time a failed node is avoided for, before it's checked again.
+	nodeCooldown = 30 * time.Second
+	// nodeTimeout is the time to wait for the response headers of a node, before failing over.
+	nodeTimeout = 30 * time.Second
+	// nodeProbeTimeout is the time to wait for a node being checked after the cooldown.
+	nodeProbeTimeout = 5 * time.Second
+	// nodeThrottleRetries is the number of retries of a node responding with 429 Too Many Requests,
+	// before failing over.
+	nodeThrottleRetries = 3
+	// nodeThrottleBackoff is the wait before the retry, unless the node sets Retry-After.
+	nodeThrottleBackoff = time.Second
+)
+
+// Endpoints are the nodes of an inventory group in order of priority. The clients dialed
+// share the health of nodes, so a node failed by one client is avoided by the others.
+type Endpoints struct {
+	group string
+	urls  []string
+
+	nodes     map[string]*NodeSpec
+	limits    map[string]*rateLimit
+	batchSize int
+	cassette  *Cassette
+	cache     *rpcCache
+
+	quotas map[string]int
+
+	mux       *sync.Mutex
+	downUntil map[string]time.Time
+	usage     map[string]*NodeUsage
+	transport http.RoundTripper
+}
+
+// Endpoints returns the nodes of the group, failed over in order of priority.
+func (inventory Inventory) Endpoints(groupName string) (*Endpoints, bool) {
+	group, ok := inventory[groupName]
+	if !ok || len(group) == 0 {
+		return nil, false
+	}
+	endpoints := &Endpoints{
+		group:     groupName,
+		urls:      group,
+		limits:    make(map[string]*rateLimit, len(group)),
+		quotas:    make(map[string]int, len(group)),
+		mux:       new(sync.Mutex),
+		downUntil: make(map[string]time.Time),
+		usage:     make(map[string]*NodeUsage),
+		transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: nodeTimeout,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+	for _, url := range group {
+		endpoints.limits[url] = newRateLimit(0, 0)
+	}
+	return endpoints, true
+}
+
+// Endpoints returns the nodes of the group, with the credentials of the network nodes, limited
+// to the rate and metered against the quota set for the node in the network, or in the config
+// for all nodes.
+func (spec *Spec) Endpoints(groupName string) (*Endpoints, bool) {
+	endpoints, ok := spec.Inventory.Endpoints(groupName)
+	if !ok {
+		return nil, false
+	}
+	config := spec.Config
+	if config == nil {
+		config = DefaultConfigSpec
+	}
+	for _, url := range endpoints.urls {
+		rate, burst := config.RateLimit, config.RateBurst
+		quota := config.RPCQuota
+		if node, ok := spec.nodes[url]; ok {
+			if node.RateLimit > 0 {
+				rate, burst = node.RateLimit, node.Burst
+			}
+			if node.Quota > 0 {
+				quota = node.Quota
+			}
+		}
+		endpoints.limits[url] = newRateLimit(rate, burst)
+		endpoints.quotas[url] = quota
+	}
+	endpoints.nodes = spec.nodes
+	endpoints.cassette = spec.cassette
+	if config.RPCCache && spec.cassette == nil {
+		// the recorded run must call the nodes
+		if spec.rpcCache == nil {
+			spec.rpcCache = openRPCCache(config.SpecDir)
+		}
+		endpoints.cache = spec.rpcCache
+	}
+	endpoints.batchSize = config.BatchSize
+	return endpoints, true
+}
+
+// Dial connects to the nodes, HTTP requests are sent to the healthy node of the highest priority and
+// retried with the next one on errors and timeouts. A sticky client keeps using the node it has sent
+// to first while it's healthy, so the transactions of a wallet see consistent nonces. Other transports
+// can't fail over, nor be limited, the first node is dialed.
+func (e *Endpoints) Dial(sticky bool) (*rpc.Client, error) {
+	if e.cassette != nil && e.cassette.Replaying() {
+		client := &http.Client{
+			Transport: &replayTransport{
+				cassette: e.cassette,
+			},
+		}
+		return rpc.DialHTTPWithClient("http://replay", client)
+	}
+	if !isHTTP(e.urls[0]) {
+		if e.cassette != nil {
+			return nil, fmt.Errorf("node %s: calls can be recorded for HTTP nodes only", e.urls[0])
+		}
+		if node, ok := e.nodes[e.urls[0]]; ok && node.hasAuth() {
+			return nil, fmt.Errorf("node %s: credentials can be set for HTTP nodes only", e.urls[0])
+		}
+		return rpc.Dial(e.urls[0])
+	}
+	for _, url := range e.urls {
+		if !isHTTP(url) {
+			return nil, fmt.Errorf("nodes of group %s must all be HTTP to fail over: %s", e.group, url)
+		}
+	}
+	transport := &failoverTransport{
+		endpoints: e,
+		sticky:    sticky,
+		mux:       new(sync.Mutex),
+	}
+	if e.batchSize > 1 {
+		transport.batch = newBatcher(e.batchSize, transport.send)
+	}
+	client := &http.Client{
+		Transport: transport,
+	}
+	return rpc.DialHTTPWithClient(e.urls[0], client)
+}
+
+// candidates returns the nodes to try in order: the preferred one, then the healthy nodes by priority,
+// the failed ones back after the cooldown, and the nodes failed recently, if all the others fail too.
+func (e *Endpoints) candidates(preferred string) []string {
+	var healthy, expired, failed []string
+	now := time.Now()
+	e.mux.Lock()
+	if len(preferred) > 0 && !now.Before(e.downUntil[preferred]) {
+		healthy = append(healthy, preferred)
+	}
+	for _, url := range e.urls {
+		if url == preferred && len(healthy) > 0 {
+			continue
+		}
+		downUntil, ok := e.downUntil[url]
+		switch {
+		case !ok:
+			healthy = append(healthy, url)
+		case now.Before(downUntil):
+			failed = append(failed, url)
+		default:
+			expired = append(expired, url)
+		}
+	}
+	e.mux.Unlock()
+	for _, url := range expired {
+		probeCtx, cancel := context.WithTimeout(context.Background(), nodeProbeTimeout)
+		err := e.probe(probeCtx, url)
+		cancel()
+		if err == nil {
+			e.markUp(url)
+			healthy = append(healthy, url)
+			continue
+		}
+		e.mux.Lock()
+		e.downUntil[url] = time.Now().Add(nodeCooldown)
+		e.mux.Unlock()
+		failed = append(failed, url)
+	}
+	return append(healthy, failed...)
+}
+
+func (e *Endpoints) markDown(url string, err error) {
+	log.WithFields(log.Fields{
+		"group": e.group,
+		"node":  redactURL(url),
+	}).WithError(err).Warningln("node failed, failing over to the next one")
+	e.mux.Lock()
+	e.downUntil[url] = time.Now().Add(nodeCooldown)
+	e.mux.Unlock()
+}
+
+func (e *Endpoints) markUp(url string) {
+	e.mux.Lock()
+	delete(e.downUntil, url)
+	e.mux.Unlock()
+}
+
+// roundTrip sends the request to the node within its rate limit, retrying it if the node
+// responds with 429 Too Many Requests.
+func (e *Endpoints) roundTrip(req *http.Request, url string, body []byte) (*http.Response, error) {
+	limit := e.limits[url]
+	for retry := 0; ; retry++ {
+		if err := limit.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+		nodeReq, err := http.NewRequest(req.Method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		nodeReq = nodeReq.WithContext(req.Context())
+		for name, values := range req.Header {
+			nodeReq.Header[name] = values
+		}
+		e.authorize(nodeReq, url)
+		e.meter(url, body)
+		resp, err := e.transport.RoundTrip(nodeReq)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || retry == nodeThrottleRetries {
+			return resp, err
+		}
+		resp.Body.Close()
+		backoff := nodeThrottleBackoff
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			backoff = time.Duration(seconds) * time.Second
+		}
+		log.WithFields(log.Fields{
+			"group":   e.group,
+			"node":    redactURL(url),
+			"backoff": backoff.String(),
+		}).Debugln("node is rate limiting the requests")
+		limit.Backoff(backoff)
+	}
+}
+
+// probe checks the node is live calling net_version, with the credentials of the node.
+func (e *Endpoints) probe(ctx context.Context, url string) error {
+	if e.cassette != nil && e.cassette.Replaying() {
+		// the nodes are not called
+		return nil
+	}
+	return e.callNode(ctx, url, nil, "net_version")
+}
+
+// callNode calls the method of the node directly, bypassing the failover, limits and metering.
+func (e *Endpoints) callNode(ctx context.Context, url string, result interface{}, method string) error {
+	if !isHTTP(url) {
+		client, err := rpc.DialContext(ctx, url)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		return client.CallContext(ctx, result, method)
+	}
+	body := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":%q,"params":[]}`, method)
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	e.authorize(req, url)
+	resp, err := e.transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("node responded with %s", resp.Status)
+	}
+	var msg struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return err
+	} else if msg.Error != nil {
+		return errors.New(msg.Error.Message)
+	} else if result == nil {
+		return nil
+	}
+	return json.Unmarshal(msg.Result, result)
+}
+
+type failoverTransport struct {
+	endpoints *Endpoints
+	sticky    bool
+
+	batch *batcher
+
+	mux     *sync.Mutex
+	current string
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		data, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = data
+	}
+	cache := t.endpoints.cache
+	if cache != nil {
+		if data, ok := cache.Get(t.call, body); ok {
+			return jsonResponse(req, data), nil
+		}
+	}
+	var (
+		resp *http.Response
+		err  error
+	)
+	if t.batch != nil {
+		resp, err = t.batch.Do(req, body)
+	} else {
+		resp, err = t.send(req, body)
+	}
+	if err != nil || cache == nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	cache.Put(t.call, body, data)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// call calls the node, bypassing the batching and the cache.
+func (t *failoverTransport) call(method string, params ...interface{}) (json.RawMessage, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoints.urls[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.send(req, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var msg *rpcMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, err
+	} else if len(msg.Error) > 0 {
+		return nil, fmt.Errorf("%s failed: %s", method, msg.Error)
+	}
+	return msg.Result, nil
+}
+
+// send sends the request to the nodes in order, until one succeeds.
+func (t *failoverTransport) send(req *http.Request, body []byte) (*http.Response, error) {
+	t.mux.Lock()
+	preferred := t.current
+	t.mux.Unlock()
+	var lastErr error
+	for _, url := range t.endpoints.candidates(preferred) {
+		resp, err := t.endpoints.roundTrip(req, url, body)
+		if err == nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500) {
+			resp.Body.Close()
+			err = fmt.Errorf("node responded with %s", resp.Status)
+		}
+		if err != nil {
+			if req.Context().Err() != nil {
+				// canceled by the caller, not the node failure
+				return nil, err
+			}
+			t.endpoints.markDown(url, err)
+			lastErr = err
+			continue
+		}
+		t.endpoints.markUp(url)
+		if t.endpoints.cassette != nil {
+			data, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			t.endpoints.cassette.Record(body, data)
+			resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+		}
+		if t.sticky {
+			t.mux.Lock()
+			t.current = url
+			t.mux.Unlock()
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("all nodes of group %s failed, last error: %v", t.endpoints.group, lastErr)
+}
+
+func isHTTP(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fingerprintEmojis are 64 pictographs telling apart well in the terminals, the fingerprint
+// takes 6 bits of the hash per emoji.
+var fingerprintEmojis = []string{
+	"🐶", "🐱", "🐭", "🐰", "🦊", "🐻", "🐼", "🐨",
+	"🐯", "🦁", "🐮", "🐷", "🐸", "🐵", "🐔", "🐧",
+	"🐦", "🦆", "🦉", "🐴", "🦄", "🐝", "🐛", "🦋",
+	"🐌", "🐞", "🐢", "🐍", "🐙", "🦀", "🐬", "🐳",
+	"🌵", "🌲", "🌴", "🍀", "🍁", "🍄", "🌻", "🌹",
+	"🍎", "🍋", "🍌", "🍉", "🍇", "🍓", "🍒", "🍍",
+	"🥕", "🌽", "🍞", "🧀", "🍕", "🍩", "🍪", "🎂",
+	"🚗", "🚲", "🚀", "⛵", "🔑", "🔔", "🎈", "⚽",
+}
+
+// fingerprintLength is the number of emojis, 24 bits of the hash.
+const fingerprintLength = 4
+
+// AddressFingerprint renders the address as emojis derived from its hash, so the addresses
+// differing anywhere look different, unlike the addresses sharing the prefix and the suffix
+// only, which are easy to mine to fool the operators comparing the ends.
+func AddressFingerprint(address common.Address) string {
+	hash := crypto.Keccak256(address.Bytes())
+	bits := uint(hash[0])<<16 | uint(hash[1])<<8 | uint(hash[2])
+	var emojis []string
+	for i := fingerprintLength - 1; i >= 0; i-- {
+		emojis = append(emojis, fingerprintEmojis[(bits>>(6*uint(i)))&63])
+	}
+	return strings.Join(emojis, "")
+}
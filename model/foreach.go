@@ -0,0 +1,165 @@
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/AtlantPlatform/yaml"
+)
+
+// ForeachSpec repeats the command for every row of the data source, the row fields
+// are bound as template variables, e.g. {{ .address }}, in all fields of the command.
+type ForeachSpec struct {
+	// List is an inline list of rows, either maps or scalars bound as {{ .value }}.
+	List []interface{} `yaml:"list"`
+	// Wallets is a regexp of wallet names, each row has {{ .name }} and {{ .address }}.
+	Wallets string `yaml:"wallets"`
+	// File is a path to CSV file with the header row, or JSON file with an array of objects.
+	File string `yaml:"file"`
+
+	namespace string `yaml:"-"`
+}
+
+// rows loads the rows of the data source, each row also has the {{ .index }} variable.
+func (spec *ForeachSpec) rows(ctx AppContext, root *Spec) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	switch {
+	case len(spec.List) > 0 && len(spec.Wallets) == 0 && len(spec.File) == 0:
+		for _, item := range spec.List {
+			rows = append(rows, foreachRow(item))
+		}
+	case len(spec.Wallets) > 0 && len(spec.List) == 0 && len(spec.File) == 0:
+		rx, err := regexp.Compile(spec.Wallets)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile wallets regexp: %v", err)
+		}
+		for name, wallet := range root.Wallets.inNamespace(spec.namespace) {
+			if !rx.MatchString(name) {
+				continue
+			}
+			rows = append(rows, map[string]interface{}{
+				"name":    name,
+				"address": wallet.Address,
+			})
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			return rows[i]["name"].(string) < rows[j]["name"].(string)
+		})
+	case len(spec.File) > 0 && len(spec.List) == 0 && len(spec.Wallets) == 0:
+		path := spec.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ctx.SpecDir(), path)
+		}
+		loaded, err := loadRows(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load rows from %s: %v", spec.File, err)
+		}
+		rows = loaded
+	default:
+		return nil, errors.New("foreach must have exactly one of list, wallets or file")
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("foreach has no rows")
+	}
+	for i, row := range rows {
+		row["index"] = i
+	}
+	return rows, nil
+}
+
+func foreachRow(item interface{}) map[string]interface{} {
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return map[string]interface{}{
+			"value": item,
+		}
+	}
+	row := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		row[nillableStr(k)] = v
+	}
+	return row
+}
+
+func loadRows(path string) ([]map[string]interface{}, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var items []interface{}
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		rows := make([]map[string]interface{}, 0, len(items))
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				rows = append(rows, m)
+				continue
+			}
+			rows = append(rows, map[string]interface{}{
+				"value": item,
+			})
+		}
+		return rows, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	} else if len(records) == 0 {
+		return nil, errors.New("no header row")
+	}
+	header := records[0]
+	rows := make([]map[string]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, field := range header {
+			if i < len(record) {
+				row[strings.TrimSpace(field)] = strings.TrimSpace(record[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// expandForeach renders the command spec for each row, the spec is marshalled back into YAML
+// without the foreach field, so the template variables can be used in any field.
+func expandForeach(ctx AppContext, root *Spec, foreach *ForeachSpec, cmd interface{}) ([][]byte, error) {
+	rows, err := foreach.rows(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := yaml.Marshal(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal command spec: %v", err)
+	}
+	tpl, err := template.New("foreach").Option("missingkey=error").Parse(string(doc))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+	docs := make([][]byte, 0, len(rows))
+	for i, row := range rows {
+		buf := new(bytes.Buffer)
+		if err := tpl.Execute(buf, row); err != nil {
+			return nil, fmt.Errorf("row %d: %v", i, err)
+		}
+		docs = append(docs, buf.Bytes())
+	}
+	return docs, nil
+}
@@ -0,0 +1,211 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// abiEntry is a JSON ABI entry produced from a human-readable fragment.
+type abiEntry struct {
+	Type            string     `json:"type"`
+	Name            string     `json:"name,omitempty"`
+	Inputs          []abiParam `json:"inputs"`
+	Outputs         []abiParam `json:"outputs,omitempty"`
+	StateMutability string     `json:"stateMutability,omitempty"`
+	Constant        bool       `json:"constant,omitempty"`
+	Payable         bool       `json:"payable,omitempty"`
+	Anonymous       bool       `json:"anonymous,omitempty"`
+}
+
+type abiParam struct {
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	Indexed    bool       `json:"indexed,omitempty"`
+	Components []abiParam `json:"components,omitempty"`
+}
+
+// parseFragments converts ethers-style human-readable ABI fragments, like
+//
+//	function transfer(address to, uint256 amount) returns (bool)
+//	event Transfer(address indexed from, address indexed to, uint256 value)
+//
+// into the JSON ABI. Functions, events, custom errors and constructors are supported,
+// the function keyword may be omitted.
+func parseFragments(fragments []string) ([]byte, error) {
+	entries := make([]*abiEntry, 0, len(fragments))
+	for _, fragment := range fragments {
+		entry, err := parseFragment(fragment)
+		if err != nil {
+			err = fmt.Errorf("%v: %s", err, fragment)
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return json.Marshal(entries)
+}
+
+func parseFragment(fragment string) (*abiEntry, error) {
+	fragment = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(fragment), ";"))
+	entry := &abiEntry{
+		Type: "function",
+	}
+	for _, kind := range []string{"function", "event", "error", "constructor"} {
+		if strings.HasPrefix(fragment, kind+" ") || strings.HasPrefix(fragment, kind+"(") {
+			entry.Type = kind
+			fragment = strings.TrimSpace(fragment[len(kind):])
+			break
+		}
+	}
+	open := strings.IndexByte(fragment, '(')
+	if open < 0 {
+		return nil, errors.New("fragment has no parameter list")
+	}
+	entry.Name = strings.TrimSpace(fragment[:open])
+	if entry.Type == "constructor" && len(entry.Name) > 0 {
+		return nil, errors.New("constructor must not have a name")
+	} else if entry.Type != "constructor" && !isIdentifier(entry.Name) {
+		return nil, fmt.Errorf("invalid name %q", entry.Name)
+	}
+	params, rest, err := splitParens(fragment[open:])
+	if err != nil {
+		return nil, err
+	}
+	if entry.Inputs, err = parseParams(params, entry.Type == "event"); err != nil {
+		return nil, err
+	}
+	for len(rest) > 0 {
+		var word string
+		if i := strings.IndexAny(rest, " ("); i >= 0 {
+			word, rest = rest[:i], strings.TrimSpace(rest[i:])
+		} else {
+			word, rest = rest, ""
+		}
+		switch word {
+		case "view", "pure":
+			entry.StateMutability = word
+			entry.Constant = true
+		case "payable":
+			entry.StateMutability = word
+			entry.Payable = true
+		case "nonpayable":
+			entry.StateMutability = word
+		case "external", "public":
+		case "anonymous":
+			if entry.Type != "event" {
+				return nil, errors.New("only events can be anonymous")
+			}
+			entry.Anonymous = true
+		case "returns":
+			if entry.Type != "function" {
+				return nil, errors.New("only functions can have return values")
+			}
+			var outputs string
+			if outputs, rest, err = splitParens(rest); err != nil {
+				return nil, err
+			}
+			if entry.Outputs, err = parseParams(outputs, false); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q", word)
+		}
+	}
+	if entry.Type == "function" && len(entry.StateMutability) == 0 {
+		entry.StateMutability = "nonpayable"
+	}
+	if entry.Outputs == nil && entry.Type == "function" {
+		entry.Outputs = []abiParam{}
+	}
+	return entry, nil
+}
+
+// splitParens returns the contents of the leading parenthesized group and the rest of the string.
+func splitParens(str string) (string, string, error) {
+	str = strings.TrimSpace(str)
+	if !strings.HasPrefix(str, "(") {
+		return "", "", errors.New("expected parameter list")
+	}
+	depth := 0
+	for i, c := range str {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return str[1:i], strings.TrimSpace(str[i+1:]), nil
+			}
+		}
+	}
+	return "", "", errors.New("unbalanced parentheses")
+}
+
+func parseParams(params string, allowIndexed bool) ([]abiParam, error) {
+	result := []abiParam{}
+	for i, param := range splitComponents(params) {
+		typ, rest := cutType(param)
+		if len(typ) == 0 {
+			return nil, fmt.Errorf("empty parameter %d", i)
+		}
+		var p abiParam
+		if isTupleType(typ) {
+			argType, err := parseArgType(typ)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %d: %v", i, err)
+			}
+			p = argType.abiParam("")
+		} else {
+			p.Type = normalizeType(typ)
+			if _, err := abi.NewType(p.Type); err != nil {
+				return nil, err
+			}
+		}
+		for _, field := range strings.Fields(rest) {
+			switch field {
+			case "indexed":
+				if !allowIndexed {
+					return nil, errors.New("only event parameters can be indexed")
+				}
+				p.Indexed = true
+			case "memory", "calldata", "storage":
+			default:
+				if len(p.Name) > 0 || !isIdentifier(field) {
+					return nil, fmt.Errorf("unexpected %q in parameter %d", field, i)
+				}
+				p.Name = field
+			}
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// normalizeType expands uint and int aliases to their canonical form.
+func normalizeType(typ string) string {
+	for _, alias := range []string{"uint", "int"} {
+		if typ == alias || strings.HasPrefix(typ, alias+"[") {
+			return alias + "256" + typ[len(alias):]
+		}
+	}
+	return typ
+}
+
+func isIdentifier(str string) bool {
+	if len(str) == 0 {
+		return false
+	}
+	for i, c := range str {
+		switch {
+		case c == '_' || c == '$':
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Funding are the top-up rules of the fund command, keyed by name.
+type Funding map[string]*FundSpec
+
+// FundSpec keeps the wallets matching the Wallets regexp funded from the From wallet: the balances
+// below Min are topped up to TopUp, Min by default. The amounts are in ether, e.g. 0.05 ETH, or in
+// a token of the spec, e.g. 500 USDC. Cap limits the amount sent by a run of the rule, the deficits
+// over it are left for the next run and alerted.
+type FundSpec struct {
+	From    string `yaml:"from"`
+	Wallets string `yaml:"wallets"`
+	Min     string `yaml:"min"`
+	TopUp   string `yaml:"topUp"`
+	Cap     string `yaml:"cap"`
+
+	from    *WalletSpec    `yaml:"-"`
+	wallets *regexp.Regexp `yaml:"-"`
+	token   *TokenSpec     `yaml:"-"`
+	min     *big.Int       `yaml:"-"`
+	topUp   *big.Int       `yaml:"-"`
+	cap     *big.Int       `yaml:"-"`
+}
+
+func (funding Funding) Validate(ctx AppContext, spec *Spec) bool {
+	for name, fund := range funding {
+		validateLog := log.WithFields(log.Fields{
+			"section": "Funding",
+			"fund":    name,
+		})
+		if fund == nil {
+			validateLog.Errorln("fund has no spec")
+			return false
+		} else if err := fund.validate(spec); err != nil {
+			validateLog.WithError(err).Errorln("invalid fund")
+			return false
+		}
+	}
+	return true
+}
+
+func (spec *FundSpec) validate(root *Spec) error {
+	var ok bool
+	if spec.from, ok = root.Wallets.WalletSpec(spec.From); !ok {
+		return fmt.Errorf("wallet %s to fund from is not found", spec.From)
+	}
+	if len(spec.Wallets) == 0 {
+		return errors.New("fund has no wallets to top up")
+	}
+	var err error
+	if spec.wallets, err = regexp.Compile(spec.Wallets); err != nil {
+		return fmt.Errorf("invalid wallets regexp: %v", err)
+	}
+	if len(spec.Min) == 0 {
+		return errors.New("fund has no min balance")
+	}
+	if spec.token, spec.min, err = root.assetAmount(spec.Min); err != nil {
+		return fmt.Errorf("min: %v", err)
+	}
+	// the amounts of the rule must be of the same asset
+	sameAsset := func(field, value string) (*big.Int, error) {
+		token, amount, err := root.assetAmount(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field, err)
+		} else if token != spec.token {
+			return nil, fmt.Errorf("%s must be in %s, like min", field, spec.Asset())
+		}
+		return amount, nil
+	}
+	spec.topUp = spec.min
+	if len(spec.TopUp) > 0 {
+		if spec.topUp, err = sameAsset("topUp", spec.TopUp); err != nil {
+			return err
+		} else if spec.topUp.Cmp(spec.min) < 0 {
+			return errors.New("topUp must not be less than min")
+		}
+	}
+	if len(spec.Cap) > 0 {
+		if spec.cap, err = sameAsset("cap", spec.Cap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assetAmount parses the amount of ether, e.g. 0.5 ETH or 20 gwei, or of a token of the spec,
+// e.g. 100 DAI, into the smallest units. The token is nil for ether.
+func (spec *Spec) assetAmount(value string) (*TokenSpec, *big.Int, error) {
+	if len(strings.Fields(value)) != 2 {
+		return nil, nil, errors.New("must be an amount with the asset, e.g. 0.5 ETH or 100 DAI")
+	}
+	if amount, ok, err := spec.tokenAmount(value); err != nil {
+		return nil, nil, err
+	} else if ok {
+		token, _ := spec.Tokens.Find(strings.Fields(value)[1])
+		return token, amount, nil
+	}
+	amount, err := parseWei(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, amount, nil
+}
+
+// FromWallet is the wallet the top-ups are sent from.
+func (spec *FundSpec) FromWallet() *WalletSpec {
+	return spec.from
+}
+
+// Funded returns the wallets kept funded by name, except the wallet funding them.
+func (spec *FundSpec) Funded(root *Spec) []*WalletSpec {
+	var wallets []*WalletSpec
+	for _, wallet := range root.Wallets.GetAll(spec.wallets) {
+		if !strings.EqualFold(wallet.Address, spec.from.Address) {
+			wallets = append(wallets, wallet)
+		}
+	}
+	return wallets
+}
+
+// Token is the token of the balances, nil for ether.
+func (spec *FundSpec) Token() *TokenSpec {
+	return spec.token
+}
+
+// Asset is the symbol of the balances, ETH for ether.
+func (spec *FundSpec) Asset() string {
+	if spec.token == nil {
+		return "ETH"
+	}
+	return strings.ToUpper(spec.token.Symbol)
+}
+
+// Decimals are the decimals of the asset.
+func (spec *FundSpec) Decimals() int {
+	if spec.token == nil || spec.token.Decimals == nil {
+		return 18
+	}
+	return *spec.token.Decimals
+}
+
+// Deficit is the amount topping up the balance, zero if it's not below min.
+func (spec *FundSpec) Deficit(balance *big.Int) *big.Int {
+	if balance.Cmp(spec.min) >= 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).Sub(spec.topUp, balance)
+}
+
+// MinBalance is the balance the wallets are topped up below.
+func (spec *FundSpec) MinBalance() *big.Int {
+	return spec.min
+}
+
+// CapAmount is the amount a run may send, nil if it's not capped.
+func (spec *FundSpec) CapAmount() *big.Int {
+	return spec.cap
+}
+
+// Names returns the names of the rules, sorted, so the runs fund the wallets in the same order.
+func (funding Funding) Names() []string {
+	names := make([]string, 0, len(funding))
+	for name := range funding {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
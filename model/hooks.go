@@ -0,0 +1,94 @@
+package model
+
+import (
+	log "github.com/Sirupsen/logrus"
+)
+
+// Hooks are lifecycle hooks of targets, keyed by target name.
+type Hooks map[string]*HooksSpec
+
+// HooksSpec lists the commands to run around the target: before its commands, after all of them
+// succeeded, and on error, i.e. when a before hook, a command of the target or an after hook fails.
+// On is the event subscription, which runs the target for each matching event, until interrupted.
+// When is the condition to run the target, its commands and hooks are skipped otherwise.
+type HooksSpec struct {
+	Before  []string `yaml:"before"`
+	After   []string `yaml:"after"`
+	OnError []string `yaml:"onError"`
+	On      string   `yaml:"on"`
+	When    string   `yaml:"when"`
+
+	trigger   *Trigger    `yaml:"-"`
+	condition *Expression `yaml:"-"`
+}
+
+func (hooks Hooks) Validate(ctx AppContext, spec *Spec) bool {
+	validateLog := log.WithFields(log.Fields{
+		"section": "Hooks",
+		"func":    "Validate",
+	})
+	for target, hooksSpec := range hooks {
+		if _, ok := spec.Targets[target]; !ok {
+			validateLog.WithField("target", target).Errorln("hooks of unknown target")
+			return false
+		} else if hooksSpec == nil {
+			continue
+		}
+		if len(hooksSpec.On) > 0 {
+			triggerLog := validateLog.WithFields(log.Fields{
+				"target": target,
+				"on":     hooksSpec.On,
+			})
+			trigger, err := ParseTrigger(hooksSpec.On)
+			if err != nil {
+				triggerLog.WithError(err).Errorln("invalid trigger")
+				return false
+			} else if spec.HasCommand(TriggerEventOutput) {
+				triggerLog.Errorln("command named event shadows the trigger event")
+				return false
+			} else if err := trigger.resolve(spec); err != nil {
+				triggerLog.WithError(err).Errorln("failed to resolve the trigger event")
+				return false
+			}
+			hooksSpec.trigger = trigger
+		}
+		condition, err := validateTargetCondition(ctx, spec, hooksSpec.When)
+		if err != nil {
+			validateLog.WithField("target", target).WithError(err).Errorln("failed to parse when condition")
+			return false
+		}
+		hooksSpec.condition = condition
+		for _, name := range hooksSpec.commands() {
+			if !spec.HasCommand(name) {
+				validateLog.WithFields(log.Fields{
+					"target":  target,
+					"command": name,
+				}).Errorln("hook command not found")
+				return false
+			}
+			if !spec.validateCommand(ctx, name) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (spec *HooksSpec) commands() []string {
+	names := make([]string, 0, len(spec.Before)+len(spec.After)+len(spec.OnError))
+	names = append(names, spec.Before...)
+	names = append(names, spec.After...)
+	return append(names, spec.OnError...)
+}
+
+func (spec *HooksSpec) CountArgsUsing(set map[int]struct{}, root *Spec) {
+	for _, name := range spec.commands() {
+		root.CountArgsUsing(set, name)
+	}
+	countConditionArgs(spec.When, set)
+}
+
+// Condition returns the parsed when condition, nil means the target always runs.
+func (spec *HooksSpec) Condition() *Expression {
+	return spec.condition
+}
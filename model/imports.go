@@ -0,0 +1,420 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/yaml"
+)
+
+// namespaceDelim separates the namespace of an import from the names of its wallets, contracts
+// and commands, e.g. base/treasury, the dot can't be used since it's the reference delimiter.
+const namespaceDelim = "/"
+
+// ImportSpec is another playbook, which wallets, contracts, commands and targets are merged
+// into the spec under the namespace. The playbook is either a local file or a file in git repo.
+type ImportSpec struct {
+	Path string `yaml:"path"`
+	Git  string `yaml:"git"`
+	Ref  string `yaml:"ref"`
+	// File is the path of the playbook inside the git repo, playbook.yml by default.
+	File string `yaml:"file"`
+	// As is the namespace of the imported names.
+	As string `yaml:"as"`
+}
+
+var namespaceRx = regexp.MustCompile(`^[\w-]+$`)
+
+// ResolveImports loads the imported playbooks and merges them into the spec, this must be
+// done before the spec is validated, imports of imported playbooks are resolved as well.
+func (spec *Spec) ResolveImports(specDir string) error {
+	return spec.resolveImports(specDir, make(map[string]struct{}))
+}
+
+func (spec *Spec) resolveImports(specDir string, visiting map[string]struct{}) error {
+	for _, imp := range spec.Imports {
+		if !namespaceRx.MatchString(imp.As) {
+			return fmt.Errorf("import namespace '%s' must be a non-empty name", imp.As)
+		}
+		path, err := imp.localPath(specDir)
+		if err != nil {
+			return fmt.Errorf("import %s: %v", imp.As, err)
+		}
+		if _, ok := visiting[path]; ok {
+			return fmt.Errorf("import %s: import cycle through %s", imp.As, path)
+		}
+		data, err := ReadSpecFile(path)
+		if err != nil {
+			return fmt.Errorf("import %s: %v", imp.As, err)
+		}
+		if err := CheckSpecVersion(data); err != nil {
+			return fmt.Errorf("import %s: %s: %v", imp.As, path, err)
+		}
+		if data, err = RenderSpec(data, nil); err != nil {
+			return fmt.Errorf("import %s: %v", imp.As, err)
+		}
+		var imported *Spec
+		if err := yaml.Unmarshal(data, &imported); err != nil {
+			return fmt.Errorf("import %s: failed to parse YAML: %v", imp.As, err)
+		} else if imported == nil {
+			continue
+		}
+		if err := imported.checkImportable(); err != nil {
+			return fmt.Errorf("import %s: %v", imp.As, err)
+		}
+		if err := imported.ResolveSecrets(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("import %s: %v", imp.As, err)
+		}
+		visiting[path] = struct{}{}
+		err = imported.resolveImports(filepath.Dir(path), visiting)
+		delete(visiting, path)
+		if err != nil {
+			return fmt.Errorf("import %s: %v", imp.As, err)
+		}
+		if err := imported.relocate(filepath.Dir(path), specDir); err != nil {
+			return fmt.Errorf("import %s: %v", imp.As, err)
+		}
+		imported.namespace(imp.As)
+		if err := spec.merge(imported); err != nil {
+			return fmt.Errorf("import %s: %v", imp.As, err)
+		}
+	}
+	return nil
+}
+
+// checkImportable fails on the sections that are not merged into the importing spec,
+// and on the write commands without a wallet pattern.
+func (spec *Spec) checkImportable() error {
+	if len(spec.Hooks) > 0 {
+		return errors.New("HOOKS of imported playbooks are not supported, declare them in the importing one")
+	} else if len(spec.Templates) > 0 {
+		return errors.New("TEMPLATES of imported playbooks are not supported, declare them in the importing one")
+	} else if len(spec.Params) > 0 {
+		return errors.New("PARAMS of imported playbooks are not supported, they can't be overridden with -p")
+	}
+	for name, cmd := range spec.WriteCmds {
+		if cmd != nil && len(cmd.Wallet) == 0 && cmd.Foreach == nil {
+			return fmt.Errorf("write command %s has an empty wallet pattern", name)
+		}
+	}
+	return nil
+}
+
+// localPath returns the absolute path of the imported playbook, git repos
+// are cloned into the cache dir and checked out at the ref.
+func (imp *ImportSpec) localPath(specDir string) (string, error) {
+	if len(imp.Path) > 0 && len(imp.Git) > 0 {
+		return "", errors.New("import must have either path or git, not both")
+	} else if len(imp.Path) > 0 {
+		path := filepath.FromSlash(imp.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(specDir, path)
+		}
+		return filepath.Abs(path)
+	} else if len(imp.Git) == 0 {
+		return "", errors.New("import must have path or git")
+	} else if strings.HasPrefix(strings.ToLower(imp.Git), "ext::") {
+		// the ext transport runs the command of the URL
+		return "", fmt.Errorf("import git %s: ext:: URLs are not supported", imp.Git)
+	} else if strings.HasPrefix(imp.Ref, "-") {
+		return "", fmt.Errorf("import ref %s: refs can't start with -", imp.Ref)
+	}
+	sum := sha256.Sum256([]byte(imp.Git))
+	dir := cachePath(specDir, "imports", hex.EncodeToString(sum[:8]))
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", err
+		}
+		if err := runGit("", "clone", "--quiet", "--", imp.Git, dir); err != nil {
+			return "", err
+		}
+	} else if len(imp.Ref) > 0 {
+		if err := runGit(dir, "fetch", "--quiet", "--tags", "origin"); err != nil {
+			return "", err
+		}
+	}
+	if len(imp.Ref) > 0 {
+		// branches are checked out from the remote, so the cached clone follows them
+		if err := runGit(dir, "checkout", "--quiet", "--detach", "origin/"+imp.Ref); err != nil {
+			if err := runGit(dir, "checkout", "--quiet", "--detach", imp.Ref); err != nil {
+				return "", err
+			}
+		}
+	}
+	file := imp.File
+	if len(file) == 0 {
+		file = "playbook.yml"
+	}
+	return filepath.Join(dir, filepath.FromSlash(file)), nil
+}
+
+func runGit(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// relocate makes the file paths of the imported spec relative to the importing spec dir.
+func (spec *Spec) relocate(fromDir, toDir string) error {
+	rel := func(path string) (string, error) {
+		if len(path) == 0 || filepath.IsAbs(path) {
+			return path, nil
+		}
+		return filepath.Rel(toDir, filepath.Join(fromDir, filepath.FromSlash(path)))
+	}
+	var err error
+	for _, contract := range spec.Contracts {
+		if contract.SolPath, err = rel(contract.SolPath); err != nil {
+			return err
+		} else if contract.ABIFile, err = rel(contract.ABIFile); err != nil {
+			return err
+		}
+	}
+	for _, wallet := range spec.Wallets {
+		if strings.HasPrefix(wallet.KeyFile, "keystore://") {
+			continue
+		}
+		if wallet.KeyFile, err = rel(wallet.KeyFile); err != nil {
+			return err
+		} else if wallet.KeyStore, err = rel(wallet.KeyStore); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var namespaceRefRx = regexp.MustCompile(`@@|@` + deploymentsPrefix + `\.|@[\w/-]+`)
+
+// namespace prefixes the names of wallets, contracts, commands and targets,
+// as well as references to them from the commands and targets.
+func (spec *Spec) namespace(ns string) {
+	name := func(str string) string {
+		if len(str) == 0 {
+			return str
+		}
+		return ns + namespaceDelim + str
+	}
+	refs := func(str string) string {
+		return namespaceRefRx.ReplaceAllStringFunc(str, func(ref string) string {
+			if ref == "@@" || ref == "@"+deploymentsPrefix+"." {
+				return ref
+			}
+			return "@" + name(ref[1:])
+		})
+	}
+	// the wallet regexps are kept, matching the wallet names within the namespace
+	scope := func(inner string) string {
+		if len(inner) == 0 {
+			return ns
+		}
+		return name(inner)
+	}
+	addressOrName := func(str string) string {
+		if len(str) == 0 || str == ZeroAddress || common.IsHexAddress(str) || isWalletRef(str) {
+			return refs(str)
+		}
+		return name(str)
+	}
+	foreach := func(foreach *ForeachSpec) {
+		if foreach != nil {
+			foreach.namespace = scope(foreach.namespace)
+		}
+	}
+	instance := func(instance *ContractInstanceSpec) {
+		if instance != nil {
+			instance.Name = name(instance.Name)
+		}
+	}
+	names := func(list []string) []string {
+		for i := range list {
+			list[i] = name(list[i])
+		}
+		return list
+	}
+	params := func(spec *ParamSpec) {
+		for i, param := range spec.Params {
+			switch p := param.(type) {
+			case string:
+				spec.Params[i] = refs(p)
+			case map[interface{}]interface{}:
+				for _, field := range []string{"value", "reference", "expr"} {
+					if str, ok := p[field].(string); ok {
+						p[field] = refs(str)
+					}
+				}
+			}
+		}
+		for k, v := range spec.Args {
+			if str, ok := v.(string); ok {
+				spec.Args[k] = refs(str)
+			}
+		}
+	}
+
+	wallets := make(Wallets, len(spec.Wallets))
+	for k, v := range spec.Wallets {
+		wallets[name(k)] = v
+	}
+	spec.Wallets = wallets
+	contracts := make(Contracts, len(spec.Contracts))
+	for k, v := range spec.Contracts {
+		for _, i := range v.Instances {
+			instance(i)
+		}
+		contracts[name(k)] = v
+	}
+	spec.Contracts = contracts
+	callCmds := make(CallCmds, len(spec.CallCmds))
+	for k, cmd := range spec.CallCmds {
+		cmd.namespace = scope(cmd.namespace)
+		foreach(cmd.Foreach)
+		params(&cmd.ParamSpec)
+		cmd.Needs = names(cmd.Needs)
+		cmd.When = refs(cmd.When)
+		cmd.OnFailure = name(cmd.OnFailure)
+		callCmds[name(k)] = cmd
+	}
+	spec.CallCmds = callCmds
+	viewCmds := make(ViewCmds, len(spec.ViewCmds))
+	for k, cmd := range spec.ViewCmds {
+		cmd.namespace = scope(cmd.namespace)
+		foreach(cmd.Foreach)
+		instance(cmd.Instance)
+		params(&cmd.ParamSpec)
+		cmd.Needs = names(cmd.Needs)
+		cmd.When = refs(cmd.When)
+		cmd.OnFailure = name(cmd.OnFailure)
+		viewCmds[name(k)] = cmd
+	}
+	spec.ViewCmds = viewCmds
+	writeCmds := make(WriteCmds, len(spec.WriteCmds))
+	for k, cmd := range spec.WriteCmds {
+		cmd.namespace = scope(cmd.namespace)
+		foreach(cmd.Foreach)
+		cmd.To = addressOrName(cmd.To)
+		cmd.Value = Valuer(refs(string(cmd.Value)))
+		cmd.NewOwner = addressOrName(cmd.NewOwner)
+		instance(cmd.Instance)
+		instance(cmd.Clone)
+		params(&cmd.ParamSpec)
+		cmd.Needs = names(cmd.Needs)
+		cmd.When = refs(cmd.When)
+		cmd.OnFailure = name(cmd.OnFailure)
+		writeCmds[name(k)] = cmd
+	}
+	spec.WriteCmds = writeCmds
+	targets := make(Targets, len(spec.Targets))
+	for k, target := range spec.Targets {
+		for i, cmd := range target {
+			entry := name(cmd.Name())
+			if cmd.IsDeferred() {
+				entry += " " + targetCommandDefer
+			}
+			target[i] = TargetCommandSpec(entry)
+		}
+		targets[name(k)] = target
+	}
+	spec.Targets = targets
+}
+
+// merge adds the wallets, contracts, tokens, commands and targets of the imported spec.
+func (spec *Spec) merge(imported *Spec) error {
+	if len(imported.Wallets) > 0 && spec.Wallets == nil {
+		spec.Wallets = make(Wallets)
+	}
+	for k, v := range imported.Wallets {
+		if _, ok := spec.Wallets[k]; ok {
+			return fmt.Errorf("wallet %s is already defined", k)
+		}
+		spec.Wallets[k] = v
+	}
+	if len(imported.Contracts) > 0 && spec.Contracts == nil {
+		spec.Contracts = make(Contracts)
+	}
+	for k, v := range imported.Contracts {
+		if _, ok := spec.Contracts[k]; ok {
+			return fmt.Errorf("contract %s is already defined", k)
+		}
+		spec.Contracts[k] = v
+	}
+	if len(imported.Tokens) > 0 && spec.Tokens == nil {
+		spec.Tokens = make(Tokens)
+	}
+	for k, v := range imported.Tokens {
+		// tokens are not namespaced, the symbols are shared by all specs
+		if token, ok := spec.Tokens[k]; ok && (token == nil || v == nil || !strings.EqualFold(token.Address, v.Address)) {
+			return fmt.Errorf("token %s is already defined", k)
+		}
+		spec.Tokens[k] = v
+	}
+	// the lists are imported at validation, once the chain is known
+	spec.TokenLists = append(spec.TokenLists, imported.TokenLists...)
+	if len(imported.AddressBook) > 0 && spec.AddressBook == nil {
+		spec.AddressBook = make(AddressBook)
+	}
+	for k, v := range imported.AddressBook {
+		// labels are not namespaced either
+		if address, ok := spec.AddressBook[k]; ok && !strings.EqualFold(address, v) {
+			return fmt.Errorf("label %s is already defined", k)
+		}
+		spec.AddressBook[k] = v
+	}
+	if len(imported.NFTs) > 0 && spec.NFTs == nil {
+		spec.NFTs = make(NFTs)
+	}
+	for k, v := range imported.NFTs {
+		if nft, ok := spec.NFTs[k]; ok && (nft == nil || v == nil || !strings.EqualFold(nft.Address, v.Address)) {
+			return fmt.Errorf("nft %s is already defined", k)
+		}
+		spec.NFTs[k] = v
+	}
+	if len(imported.CallCmds) > 0 && spec.CallCmds == nil {
+		spec.CallCmds = make(CallCmds)
+	}
+	for k, v := range imported.CallCmds {
+		if spec.HasCommand(k) {
+			return fmt.Errorf("command %s is already defined", k)
+		}
+		spec.CallCmds[k] = v
+	}
+	if len(imported.ViewCmds) > 0 && spec.ViewCmds == nil {
+		spec.ViewCmds = make(ViewCmds)
+	}
+	for k, v := range imported.ViewCmds {
+		if spec.HasCommand(k) {
+			return fmt.Errorf("command %s is already defined", k)
+		}
+		spec.ViewCmds[k] = v
+	}
+	if len(imported.WriteCmds) > 0 && spec.WriteCmds == nil {
+		spec.WriteCmds = make(WriteCmds)
+	}
+	for k, v := range imported.WriteCmds {
+		if spec.HasCommand(k) {
+			return fmt.Errorf("command %s is already defined", k)
+		}
+		spec.WriteCmds[k] = v
+	}
+	if len(imported.Targets) > 0 && spec.Targets == nil {
+		spec.Targets = make(Targets)
+	}
+	for k, v := range imported.Targets {
+		if _, ok := spec.Targets[k]; ok {
+			return fmt.Errorf("target %s is already defined", k)
+		}
+		spec.Targets[k] = v
+	}
+	return nil
+}
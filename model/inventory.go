@@ -1,8 +1,9 @@
 package model
 
 import (
+	"context"
+
 	log "github.com/Sirupsen/logrus"
-	"github.com/ethereum/go-ethereum/rpc"
 )
 
 type Inventory map[string]InventorySpec
@@ -11,48 +12,53 @@ func (inventory Inventory) Validate(ctx AppContext, spec *Spec) bool {
 	for groupName, nodes := range inventory {
 		if groupName == ctx.NodeGroup() {
 			// check only groups that are used
-			if !nodes.Validate(ctx, groupName) {
+			if !spec.validateNodes(ctx, groupName) {
+				return false
+			}
+			endpoints, ok := spec.Endpoints(groupName)
+			if !ok || !nodes.Validate(ctx, groupName, endpoints, spec.Config) {
+				spec.nodesUnavailable = true
 				return false
 			}
+			inventory[groupName] = nodes
 		}
 	}
 	return true
 }
 
-func (inventory Inventory) GetClient(groupName string) (*rpc.Client, bool) {
-	group, ok := inventory[groupName]
-	if !ok {
-		return nil, false
-	}
-	client, err := rpc.Dial(group[0])
-	if err != nil {
-		return nil, false
-	}
-	return client, true
-}
-
+// InventorySpec lists the nodes of the group in order of priority, the requests fail over
+// to the next live node when one fails.
 type InventorySpec []string
 
-func (spec *InventorySpec) Validate(ctx AppContext, groupName string) bool {
+// Validate health-checks the nodes, the nodes not live, syncing or lagging behind are dropped.
+func (spec *InventorySpec) Validate(ctx AppContext, groupName string, endpoints *Endpoints, config *ConfigSpec) bool {
 	validateLog := log.WithFields(log.Fields{
 		"section": "Inventory",
 		"group":   groupName,
 	})
+	var live InventorySpec
 	for _, node := range *spec {
-		client, err := rpc.Dial(node)
+		probeCtx, cancel := context.WithTimeout(ctx, nodeProbeTimeout)
+		err := endpoints.probe(probeCtx, node)
+		cancel()
 		if err != nil {
-			validateLog.WithError(err).Warningln("failed to connect a Geth node")
+			validateLog.WithError(err).WithField("node", redactURL(node)).Warningf("Geth node is limited")
 			continue
-		} else if err := client.Call(nil, "net_version"); err != nil {
-			validateLog.WithError(err).Warningf("Geth node is limited")
-			continue
-		}
-		client.Close()
-		*spec = InventorySpec{
-			node,
 		}
-		return true
+		live = append(live, node)
+	}
+	if len(live) == 0 {
+		validateLog.Errorln("live Geth nodes not found")
+		return false
 	}
-	validateLog.Errorln("live Geth nodes not found")
-	return false
+	if config == nil {
+		config = DefaultConfigSpec
+	}
+	live = endpoints.syncedNodes(ctx, live, config, validateLog)
+	if len(live) == 0 {
+		validateLog.Errorln("synced Geth nodes not found")
+		return false
+	}
+	*spec = live
+	return true
 }
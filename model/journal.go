@@ -0,0 +1,302 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const runsDir = "runs"
+
+type JournalStatus string
+
+const (
+	// JournalSent means the transactions of the command have been sent, but not awaited yet.
+	JournalSent JournalStatus = "sent"
+	// JournalDone means the command has been completed.
+	JournalDone JournalStatus = "done"
+	// JournalFailed means the command has failed and must be run again.
+	JournalFailed JournalStatus = "failed"
+	// JournalSending means the command is sending its transactions, the ones of its items are journaled.
+	JournalSending JournalStatus = "sending"
+)
+
+// RunJournal is the execution state of a target run, stored as runs/<run-id>.json next to the spec file,
+// so an interrupted run can be resumed without repeating the completed commands.
+type RunJournal struct {
+	ID        string          `json:"id"`
+	Target    string          `json:"target"`
+	Args      []string        `json:"args"`
+	NodeGroup string          `json:"nodeGroup"`
+	Started   time.Time       `json:"started"`
+	Commands  []*JournalEntry `json:"commands"`
+	// Balances are the balances of the wallets at the end of the run, compared by balances --since.
+	Balances *BalanceSnapshot `json:"balances,omitempty"`
+
+	specDir string
+	mux     *sync.Mutex
+}
+
+// JournalEntry is the state of the command at its position in the target.
+type JournalEntry struct {
+	Name    string           `json:"name"`
+	Status  JournalStatus    `json:"status"`
+	Results []*JournalResult `json:"results"`
+	// Items are the transactions of the write command per iteration and wallet, journaled before they are
+	// sent, so the items sent before the run is interrupted or the command fails are not sent again.
+	Items []*JournalItem `json:"items,omitempty"`
+}
+
+// JournalItem is the state of an iteration of a write command from a wallet, the iteration is 0
+// for the commands without foreach.
+type JournalItem struct {
+	Iteration int    `json:"iteration"`
+	Wallet    string `json:"wallet"`
+	// Hashes are the transactions signed for the item, recorded before each one is sent.
+	Hashes []string `json:"hashes,omitempty"`
+	// Sent is set once all the transactions of the item have been sent, with the result of the item.
+	Sent   bool           `json:"sent,omitempty"`
+	Result *JournalResult `json:"result,omitempty"`
+}
+
+type JournalResult struct {
+	Wallet   string            `json:"wallet,omitempty"`
+	Result   json.RawMessage   `json:"result,omitempty"`
+	Txs      []string          `json:"txs,omitempty"`
+	Receipts []*JournalReceipt `json:"receipts,omitempty"`
+}
+
+// JournalReceipt is the receipt of a transaction of the command, the block is set
+// by the monitoring of reorgs, which updates it when the transaction moves.
+type JournalReceipt struct {
+	TxHash          string `json:"txHash"`
+	Status          uint64 `json:"status"`
+	GasUsed         uint64 `json:"gasUsed"`
+	ContractAddress string `json:"contractAddress,omitempty"`
+	BlockNumber     uint64 `json:"blockNumber,omitempty"`
+	BlockHash       string `json:"blockHash,omitempty"`
+}
+
+// BalanceSnapshot is the inventory of the wallet balances at the block, in the smallest units, keyed by
+// the wallet address and the asset, ETH or the token symbol, with the decimals of the assets.
+type BalanceSnapshot struct {
+	Block    uint64                       `json:"block"`
+	Time     time.Time                    `json:"time"`
+	Balances map[string]map[string]string `json:"balances"`
+	Decimals map[string]int               `json:"decimals"`
+}
+
+// NewRunJournal starts the journal of a target run, the run ID is the target name with the start time
+// and a random suffix, so the runs started within the same second don't overwrite each other's journal.
+func NewRunJournal(specDir, target, nodeGroup string, args []string) *RunJournal {
+	started := time.Now().UTC()
+	var suffix [3]byte
+	_, _ = rand.Read(suffix[:])
+	return &RunJournal{
+		ID:        fmt.Sprintf("%s-%s-%x", target, started.Format("20060102T150405"), suffix),
+		Target:    target,
+		Args:      args,
+		NodeGroup: nodeGroup,
+		Started:   started,
+
+		specDir: specDir,
+		mux:     new(sync.Mutex),
+	}
+}
+
+func LoadRunJournal(specDir, id string) (*RunJournal, error) {
+	data, err := ioutil.ReadFile(runJournalPath(specDir, id))
+	if err != nil {
+		return nil, err
+	}
+	journal := &RunJournal{
+		specDir: specDir,
+		mux:     new(sync.Mutex),
+	}
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+// ListRunJournals loads the journals of the target runs in the runs dir, in the order they were started.
+// The other files of the dir, like the airdrop journals and the scan cursors, are left out.
+func ListRunJournals(specDir string) ([]*RunJournal, error) {
+	files, err := ioutil.ReadDir(filepath.Join(specDir, runsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var journals []*RunJournal
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		journal, err := LoadRunJournal(specDir, strings.TrimSuffix(file.Name(), ".json"))
+		if err != nil || len(journal.Target) == 0 {
+			continue
+		}
+		journals = append(journals, journal)
+	}
+	sort.SliceStable(journals, func(i, j int) bool {
+		return journals[i].Started.Before(journals[j].Started)
+	})
+	return journals, nil
+}
+
+func runJournalPath(specDir, id string) string {
+	return filepath.Join(specDir, runsDir, id+".json")
+}
+
+// Entry returns the state of the command at the position in the target, nil if it has not been run.
+func (j *RunJournal) Entry(position int) *JournalEntry {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	if position >= len(j.Commands) {
+		return nil
+	}
+	return j.Commands[position]
+}
+
+// Record saves the state of the command at the position in the target, the journal
+// is stored after each update, so it survives the process being killed. The items
+// of the command journaled before are kept.
+func (j *RunJournal) Record(position int, entry *JournalEntry) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	for len(j.Commands) <= position {
+		j.Commands = append(j.Commands, nil)
+	}
+	if prev := j.Commands[position]; prev != nil && prev.Name == entry.Name && entry.Items == nil {
+		entry.Items = prev.Items
+	}
+	j.Commands[position] = entry
+	return j.save()
+}
+
+// Item returns a copy of the journaled item of the command at the position, nil if there is none.
+func (j *RunJournal) Item(position int, name string, iteration int, wallet string) *JournalItem {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	if _, item := j.findItem(position, name, iteration, wallet); item != nil {
+		copied := *item
+		copied.Hashes = append([]string{}, item.Hashes...)
+		return &copied
+	}
+	return nil
+}
+
+// RecordItem saves the item of the command at the position, replacing the journaled one of the same
+// iteration and wallet. A command not journaled yet gets an entry with the sending status.
+func (j *RunJournal) RecordItem(position int, name string, item *JournalItem) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	for len(j.Commands) <= position {
+		j.Commands = append(j.Commands, nil)
+	}
+	entry, prev := j.findItem(position, name, item.Iteration, item.Wallet)
+	if entry == nil {
+		entry = &JournalEntry{
+			Name:   name,
+			Status: JournalSending,
+		}
+		j.Commands[position] = entry
+	}
+	copied := *item
+	copied.Hashes = append([]string{}, item.Hashes...)
+	if prev != nil {
+		*prev = copied
+	} else {
+		entry.Items = append(entry.Items, &copied)
+	}
+	return j.save()
+}
+
+// DropItemTx removes the items of the command at the position having sent the transaction,
+// so they are sent again when the run is resumed.
+func (j *RunJournal) DropItemTx(position int, name, txHash string) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	if position >= len(j.Commands) || j.Commands[position] == nil || j.Commands[position].Name != name {
+		return nil
+	}
+	entry := j.Commands[position]
+	items := entry.Items[:0]
+	for _, item := range entry.Items {
+		if !containsString(item.Hashes, strings.ToLower(txHash)) {
+			items = append(items, item)
+		}
+	}
+	entry.Items = items
+	return j.save()
+}
+
+// findItem returns the entry of the command at the position and its item of the iteration and wallet.
+func (j *RunJournal) findItem(position int, name string, iteration int, wallet string) (*JournalEntry, *JournalItem) {
+	if position >= len(j.Commands) || j.Commands[position] == nil || j.Commands[position].Name != name {
+		return nil, nil
+	}
+	entry := j.Commands[position]
+	for _, item := range entry.Items {
+		if item.Iteration == iteration && strings.EqualFold(item.Wallet, wallet) {
+			return entry, item
+		}
+	}
+	return entry, nil
+}
+
+// RecordBalances saves the balances of the wallets at the end of the run.
+func (j *RunJournal) RecordBalances(snapshot *BalanceSnapshot) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.Balances = snapshot
+	return j.save()
+}
+
+func (j *RunJournal) save() error {
+	if err := os.MkdirAll(filepath.Join(j.specDir, runsDir), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(j, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(runJournalPath(j.specDir, j.ID), data)
+}
+
+// writeFileAtomic writes the file through a temp file in the same dir, renamed over it,
+// so a process killed while writing leaves the previous content instead of a truncated file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	} else if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	} else if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	} else if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
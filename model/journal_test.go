@@ -0,0 +1,66 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRunJournalItems(t *testing.T) {
+	specDir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(specDir)
+	journal := NewRunJournal(specDir, "payouts", "default", nil)
+	items := []*JournalItem{
+		{Iteration: 0, Wallet: wallet0, Hashes: []string{"0x01"}},
+		{Iteration: 1, Wallet: wallet0, Hashes: []string{"0x02", "0x03"}},
+		{Iteration: 1, Wallet: wallet1, Hashes: []string{"0x04"}},
+	}
+	for _, item := range items {
+		if err := journal.RecordItem(2, "pay", item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entry := journal.Entry(2); entry == nil || entry.Status != JournalSending || len(entry.Items) != 3 {
+		t.Fatalf("got entry %+v, want the sending items", entry)
+	}
+	sent := &JournalItem{Iteration: 1, Wallet: wallet1, Hashes: []string{"0x04"}, Sent: true}
+	if err := journal.RecordItem(2, "pay", sent); err != nil {
+		t.Fatal(err)
+	} else if err := journal.Record(2, &JournalEntry{Name: "pay", Status: JournalFailed}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadRunJournal(specDir, journal.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		iteration int
+		wallet    string
+		item      *JournalItem
+	}{
+		{"first iteration", 0, wallet0, items[0]},
+		{"lowercase wallet", 1, "0x17ec8597ff92c3f44523bdc65bf0f1be632917ff", items[1]},
+		{"replaced item", 1, wallet1, sent},
+		{"unknown iteration", 2, wallet0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if item := loaded.Item(2, "pay", tt.iteration, tt.wallet); !reflect.DeepEqual(item, tt.item) {
+				t.Fatalf("got item %+v, want %+v", item, tt.item)
+			}
+		})
+	}
+	if item := loaded.Item(2, "other", 0, wallet0); item != nil {
+		t.Errorf("got item %+v of another command", item)
+	}
+	if err := loaded.DropItemTx(2, "pay", "0x03"); err != nil {
+		t.Fatal(err)
+	} else if item := loaded.Item(2, "pay", 1, wallet0); item != nil {
+		t.Errorf("got item %+v after its transaction is dropped", item)
+	}
+}
@@ -0,0 +1,141 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// LedgerEntry is a transfer of a wallet expected by the ledger of a backoffice system, reconciled
+// with the transfers on chain. The counterparty and the transaction are matched only if given.
+type LedgerEntry struct {
+	Line      int
+	Reference string
+	Time      time.Time
+	// DateOnly is set if the ledger has the day of the transfer only, not the time.
+	DateOnly     bool
+	Wallet       string
+	Direction    string
+	Counterparty string
+	Asset        string
+	Amount       string
+	TxHash       string
+}
+
+// LoadLedger reads the expected transfers from the CSV file (or a JSON array) with the date, wallet,
+// direction (in or out), asset and amount columns, and the optional counterparty, txHash and reference.
+// The wallets are the names of the spec wallets or their addresses, the amounts are in asset units.
+func (spec *Spec) LoadLedger(path string) ([]*LedgerEntry, error) {
+	rows, err := loadRows(path)
+	if err != nil {
+		return nil, err
+	} else if len(rows) == 0 {
+		return nil, errors.New("no entries in the ledger")
+	}
+	entries := make([]*LedgerEntry, 0, len(rows))
+	for i, row := range rows {
+		// the header row is the first line
+		entry := &LedgerEntry{
+			Line:      i + 2,
+			Reference: airdropField(row, "reference", "ref", "id"),
+			Direction: strings.ToLower(airdropField(row, "direction")),
+			Asset:     strings.ToUpper(airdropField(row, "asset", "currency")),
+			TxHash:    strings.ToLower(airdropField(row, "txHash", "tx")),
+		}
+		if err := spec.parseLedgerEntry(entry, row); err != nil {
+			return nil, fmt.Errorf("line %d: %v", entry.Line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (spec *Spec) parseLedgerEntry(entry *LedgerEntry, row map[string]interface{}) error {
+	date := airdropField(row, "date", "time")
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			entry.Time, entry.DateOnly = t.UTC(), layout == "2006-01-02"
+			break
+		}
+	}
+	if entry.Time.IsZero() {
+		return fmt.Errorf("date %q must be like 2024-05-01 or a timestamp", date)
+	}
+	wallet := strings.TrimPrefix(airdropField(row, "wallet"), "@")
+	if walletSpec, ok := spec.Wallets.WalletSpec(wallet); ok && common.IsHexAddress(walletSpec.Address) {
+		entry.Wallet = wallet
+	} else if common.IsHexAddress(wallet) {
+		entry.Wallet = spec.Wallets.NameOf(wallet)
+	}
+	if len(entry.Wallet) == 0 {
+		return fmt.Errorf("wallet %q is not a wallet of the spec", wallet)
+	}
+	if entry.Direction != "in" && entry.Direction != "out" {
+		return fmt.Errorf("direction %q must be in or out", entry.Direction)
+	}
+	if len(entry.Asset) == 0 {
+		return errors.New("no asset, must be ETH or a token symbol")
+	}
+	entry.Amount = airdropField(row, "amount")
+	if amount, ok := new(big.Rat).SetString(entry.Amount); !ok || amount.Sign() <= 0 {
+		return fmt.Errorf("amount %q must be a positive number", entry.Amount)
+	}
+	if counterparty := airdropField(row, "counterparty"); len(counterparty) > 0 {
+		address, err := spec.ResolveAddress(counterparty)
+		if err != nil {
+			return fmt.Errorf("counterparty %s: %v", counterparty, err)
+		}
+		entry.Counterparty = strings.ToLower(address.Hex())
+	}
+	if len(entry.TxHash) > 0 && len(common.FromHex(entry.TxHash)) != common.HashLength {
+		return fmt.Errorf("txHash %q is not a transaction hash", entry.TxHash)
+	}
+	return nil
+}
+
+// LedgerPeriod returns the period covering the entries of the ledger and the window around them.
+func LedgerPeriod(entries []*LedgerEntry, window time.Duration) (from, to *BlockRef) {
+	from, to = &BlockRef{Time: entries[0].Time}, &BlockRef{Time: entries[0].Time}
+	for _, entry := range entries {
+		if entry.Time.Before(from.Time) {
+			from.Time = entry.Time
+		}
+		if end := entry.End(); end.After(to.Time) {
+			to.Time = end
+		}
+	}
+	from.Time, to.Time = from.Time.Add(-window), to.Time.Add(window)
+	return from, to
+}
+
+// LedgerWallets returns the names of the wallets of the entries in order.
+func LedgerWallets(entries []*LedgerEntry) []string {
+	var wallets []string
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if !seen[entry.Wallet] {
+			seen[entry.Wallet] = true
+			wallets = append(wallets, entry.Wallet)
+		}
+	}
+	sort.Strings(wallets)
+	return wallets
+}
+
+// End is the time of the entry, or the end of its day.
+func (entry *LedgerEntry) End() time.Time {
+	if entry.DateOnly {
+		return entry.Time.Add(24 * time.Hour)
+	}
+	return entry.Time
+}
+
+// Within reports whether the time is within the window around the entry, or around its day.
+func (entry *LedgerEntry) Within(t time.Time, window time.Duration) bool {
+	return !t.Before(entry.Time.Add(-window)) && t.Before(entry.End().Add(window))
+}
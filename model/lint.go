@@ -0,0 +1,261 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/yaml"
+)
+
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+	LintInfo    LintSeverity = "info"
+)
+
+// LintSpec configures the linter per project, rules are referenced by ID.
+type LintSpec struct {
+	// Disable lists the rules to skip.
+	Disable []string `yaml:"disable"`
+	// Severity overrides the default severity of rules.
+	Severity map[string]LintSeverity `yaml:"severity"`
+}
+
+type LintRule struct {
+	ID       string
+	Name     string
+	Severity LintSeverity
+	Desc     string
+
+	check func(spec *Spec, report func(subject, msg string))
+}
+
+// LintRules are the best-practice rules checked by the lint command.
+var LintRules = []*LintRule{{
+	ID:       "PB001",
+	Name:     "plaintext-privkey",
+	Severity: LintError,
+	Desc:     "Wallet has an unprotected private key in the spec",
+	check:    lintPlaintextPrivKeys,
+}, {
+	ID:       "PB002",
+	Name:     "value-without-gas-limit",
+	Severity: LintWarning,
+	Desc:     "Write command transfers value without an explicit gasLimit",
+	check:    lintValueGasLimits,
+}, {
+	ID:       "PB003",
+	Name:     "unchecksummed-address",
+	Severity: LintWarning,
+	Desc:     "Address is not EIP-55 checksummed",
+	check:    lintUnchecksummedAddresses,
+}, {
+	ID:       "PB004",
+	Name:     "unreferenced-wallet",
+	Severity: LintInfo,
+	Desc:     "Wallet is not used by any command",
+	check:    lintUnreferencedWallets,
+}, {
+	ID:       "PB005",
+	Name:     "unconfirmed-mainnet-write",
+	Severity: LintError,
+	Desc:     "Write command runs on mainnet without confirm",
+	check:    lintMainnetConfirmations,
+}}
+
+type LintIssue struct {
+	Rule     *LintRule
+	Severity LintSeverity
+	Subject  string
+	Message  string
+}
+
+func (issue *LintIssue) String() string {
+	return fmt.Sprintf("%s %s[%s] %s: %s", issue.Severity,
+		issue.Rule.ID, issue.Rule.Name, issue.Subject, issue.Message)
+}
+
+// LintIssues checks the spec against the enabled rules, the issues are sorted by rule and subject.
+func (spec *Spec) LintIssues() ([]*LintIssue, error) {
+	config := spec.Lint
+	if config == nil {
+		config = new(LintSpec)
+	}
+	disabled := make(map[string]struct{}, len(config.Disable))
+	for _, id := range config.Disable {
+		rule := findLintRule(id)
+		if rule == nil {
+			return nil, fmt.Errorf("unknown lint rule %s in disable", id)
+		}
+		disabled[rule.ID] = struct{}{}
+	}
+	severities := make(map[string]LintSeverity, len(config.Severity))
+	for id, severity := range config.Severity {
+		rule := findLintRule(id)
+		if rule == nil {
+			return nil, fmt.Errorf("unknown lint rule %s in severity", id)
+		}
+		switch severity {
+		case LintError, LintWarning, LintInfo:
+		default:
+			return nil, fmt.Errorf("lint rule %s: severity must be error, warning or info", id)
+		}
+		severities[rule.ID] = severity
+	}
+	var issues []*LintIssue
+	for _, rule := range LintRules {
+		if _, ok := disabled[rule.ID]; ok {
+			continue
+		}
+		severity := rule.Severity
+		if s, ok := severities[rule.ID]; ok {
+			severity = s
+		}
+		var ruleIssues []*LintIssue
+		rule.check(spec, func(subject, msg string) {
+			ruleIssues = append(ruleIssues, &LintIssue{
+				Rule:     rule,
+				Severity: severity,
+				Subject:  subject,
+				Message:  msg,
+			})
+		})
+		sort.Slice(ruleIssues, func(i, j int) bool {
+			return ruleIssues[i].Subject < ruleIssues[j].Subject
+		})
+		issues = append(issues, ruleIssues...)
+	}
+	return issues, nil
+}
+
+// findLintRule finds the rule by its ID or name.
+func findLintRule(id string) *LintRule {
+	for _, rule := range LintRules {
+		if rule.ID == id || rule.Name == id {
+			return rule
+		}
+	}
+	return nil
+}
+
+func lintPlaintextPrivKeys(spec *Spec, report func(subject, msg string)) {
+	for name, wallet := range spec.Wallets {
+		if wallet != nil && len(wallet.PrivKey) > 0 {
+			report("WALLETS."+name, "privkey is stored in plain text, use a keyfile or sops-encrypted SECRETS")
+		}
+	}
+}
+
+func lintValueGasLimits(spec *Spec, report func(subject, msg string)) {
+	tokenRx := regexp.MustCompile(`\s[a-zA-Z]\w*$`)
+	for name, cmd := range spec.WriteCmds {
+		value := strings.TrimSpace(string(cmd.Value))
+		if denom := tokenRx.FindString(value); len(denom) > 0 && !IsCommonDenominator(strings.ToLower(denom[1:])) {
+			continue // token transfer
+		}
+		if len(value) > 0 && cmd.GasLimit == 0 {
+			report("WRITE."+name, "value is sent with an estimated gas limit, set gasLimit")
+		}
+	}
+}
+
+func lintUnchecksummedAddresses(spec *Spec, report func(subject, msg string)) {
+	check := func(subject, address string) {
+		if address == ZeroAddress || !common.IsHexAddress(address) {
+			return
+		}
+		if checksummed := common.HexToAddress(address).Hex(); address != checksummed {
+			report(subject, fmt.Sprintf("%s should be %s", address, checksummed))
+		}
+	}
+	checkParams := func(subject string, params ParamSpec) {
+		for i, param := range params.Params {
+			if p, ok := param.(map[interface{}]interface{}); ok && p["type"] == "address" {
+				if value, ok := p["value"].(string); ok {
+					check(fmt.Sprintf("%s.params[%d]", subject, i), value)
+				}
+			}
+		}
+	}
+	for name, wallet := range spec.Wallets {
+		if wallet != nil {
+			check("WALLETS."+name, wallet.Address)
+		}
+	}
+	for name, contract := range spec.Contracts {
+		for i, instance := range contract.Instances {
+			check(fmt.Sprintf("CONTRACTS.%s.instances[%d]", name, i), instance.Address)
+		}
+	}
+	for name, cmd := range spec.CallCmds {
+		checkParams("CALL."+name, cmd.ParamSpec)
+	}
+	for name, cmd := range spec.ViewCmds {
+		checkParams("VIEW."+name, cmd.ParamSpec)
+	}
+	for name, cmd := range spec.WriteCmds {
+		check("WRITE."+name, cmd.To)
+		check("WRITE."+name, cmd.NewOwner)
+		checkParams("WRITE."+name, cmd.ParamSpec)
+	}
+}
+
+func lintUnreferencedWallets(spec *Spec, report func(subject, msg string)) {
+	matched := make(map[string]bool)
+	addRx := func(rx, ns string) {
+		compiled, err := regexp.Compile(rx)
+		if err != nil || len(rx) == 0 {
+			return
+		}
+		for name := range spec.Wallets.inNamespace(ns) {
+			if compiled.MatchString(name) {
+				if len(ns) > 0 {
+					name = ns + namespaceDelim + name
+				}
+				matched[name] = true
+			}
+		}
+	}
+	for _, cmd := range spec.CallCmds {
+		addRx(cmd.Wallet, cmd.namespace)
+	}
+	for _, cmd := range spec.ViewCmds {
+		addRx(cmd.Wallet, cmd.namespace)
+	}
+	for _, cmd := range spec.WriteCmds {
+		addRx(cmd.Wallet, cmd.namespace)
+	}
+	// wallets may be referenced as @name from any field of the commands
+	cmds, _ := yaml.Marshal([]interface{}{spec.CallCmds, spec.ViewCmds, spec.WriteCmds, spec.Templates})
+	for name := range spec.Wallets {
+		refRx := regexp.MustCompile(`@` + regexp.QuoteMeta(name) + `(?:[^\w/-]|$)`)
+		used := refRx.Match(cmds) || matched[name]
+		for _, cmd := range spec.WriteCmds {
+			used = used || cmd.To == name || cmd.NewOwner == name
+		}
+		if !used {
+			report("WALLETS."+name, "wallet is not matched or referenced by any command")
+		}
+	}
+}
+
+func lintMainnetConfirmations(spec *Spec, report func(subject, msg string)) {
+	chainID := DefaultConfigSpec.ChainID
+	if spec.Config != nil && len(spec.Config.ChainID) > 0 {
+		chainID = strings.TrimSpace(spec.Config.ChainID)
+	}
+	if chainID != "1" {
+		return
+	}
+	for name, cmd := range spec.WriteCmds {
+		if !cmd.Confirm {
+			report("WRITE."+name, "command sends mainnet transactions without confirm: true")
+		}
+	}
+}
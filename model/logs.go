@@ -0,0 +1,230 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// logFilterRx matches the conditions on the event args, like value>=1000 or from=@alice.
+var logFilterRx = regexp.MustCompile(`^(\w+)\s*(==|=|!=|>=|<=|>|<)\s*(.+)$`)
+
+// LogFilter is the condition on an event arg of the logs query, the addresses are compared
+// by value, the integers numerically, the rest as text.
+type LogFilter struct {
+	Arg   string
+	Op    string
+	Value string
+
+	typ   abi.Type
+	topic int
+}
+
+func ParseLogFilter(str string) (*LogFilter, error) {
+	m := logFilterRx.FindStringSubmatch(strings.TrimSpace(str))
+	if m == nil {
+		return nil, errors.New("filter must be ARG OP VALUE, where OP is one of = != > >= < <=")
+	}
+	op := m[2]
+	if op == "==" {
+		op = "="
+	}
+	return &LogFilter{
+		Arg:   m[1],
+		Op:    op,
+		Value: strings.TrimSpace(m[3]),
+	}, nil
+}
+
+// LookupEvent finds the event by name or signature, like Transfer or Transfer(address,address,uint256),
+// in the ABI of the contract, which is the name of a contract spec or a token, or an address, a wallet,
+// a label or an ENS name of the emitter. Returns the event and the addresses of the emitters.
+func (spec *Spec) LookupEvent(contract, event string) (*abi.Event, []common.Address, error) {
+	abis, addresses, err := spec.lookupEmitters(contract)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, contractABI := range abis {
+		if abiEvent, ok := findEventByName(contractABI, event); ok {
+			return abiEvent, addresses, nil
+		}
+	}
+	if err := spec.Contracts.unsupportedEntry(contract, event); err != nil {
+		return nil, nil, err
+	}
+	return nil, nil, fmt.Errorf("event %s is not found in the ABI of %s", event, contract)
+}
+
+// lookupEmitters returns the ABIs the events of the contract are looked up in, in the order of preference,
+// and the addresses of the emitters.
+func (spec *Spec) lookupEmitters(contract string) ([]abi.ABI, []common.Address, error) {
+	var abis []abi.ABI
+	var addresses []common.Address
+	if contractSpec, ok := spec.Contracts.ContractSpec(contract); ok && contractSpec != nil {
+		abis = append(abis, contractSpec.abi)
+		for _, instance := range contractSpec.Instances {
+			if instance.IsDeployed() {
+				addresses = append(addresses, common.HexToAddress(instance.Address))
+			}
+		}
+		if len(addresses) == 0 {
+			return nil, nil, fmt.Errorf("contract %s has no deployed instances", contract)
+		}
+	} else if token, err := spec.FindToken(contract); err == nil {
+		abis = append(abis, erc20ABI())
+		addresses = append(addresses, common.HexToAddress(token.Address))
+	} else {
+		address, err := spec.ResolveAddress(contract)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s is not a contract, a token nor an address", contract)
+		}
+		addresses = append(addresses, address)
+		// the ABI of the instance is preferred, then any ABI of the spec having the event
+		for _, contractSpec := range spec.Contracts {
+			for _, instance := range contractSpec.Instances {
+				if instance.MatchesAddress(address.Hex()) {
+					abis = append([]abi.ABI{contractSpec.abi}, abis...)
+				}
+			}
+			abis = append(abis, contractSpec.abi)
+		}
+		abis = append(abis, erc20ABI())
+	}
+	return abis, addresses, nil
+}
+
+func findEventByName(contractABI abi.ABI, name string) (*abi.Event, bool) {
+	name = strings.Replace(name, " ", "", -1)
+	for _, event := range contractABI.Events {
+		if event.Anonymous {
+			continue
+		}
+		if event.Name == name || EventSignature(&event) == name {
+			event := event
+			return &event, true
+		}
+	}
+	return nil, false
+}
+
+// EventSignature is the canonical signature of the event, like Transfer(address,address,uint256).
+func EventSignature(event *abi.Event) string {
+	types := make([]string, len(event.Inputs))
+	for i, input := range event.Inputs {
+		types[i] = input.Type.String()
+	}
+	return fmt.Sprintf("%s(%s)", event.Name, strings.Join(types, ","))
+}
+
+func erc20ABI() abi.ABI {
+	abiJSON, _ := parseFragments(erc20Fragments)
+	contractABI, _ := abi.JSON(bytes.NewReader(abiJSON))
+	return contractABI
+}
+
+// ResolveLogFilters checks the args of the filters against the event, resolving the wallets,
+// labels and ENS names of the address args, and returns the topics matching the indexed args
+// of the equality filters, so the nodes filter the logs by them.
+func (spec *Spec) ResolveLogFilters(event *abi.Event, filters []*LogFilter) ([][]common.Hash, error) {
+	topics := [][]common.Hash{{event.Id()}}
+	for _, filter := range filters {
+		topic, found := 0, false
+		for i, input := range event.Inputs {
+			if input.Indexed {
+				topic++
+			}
+			name := input.Name
+			if len(name) == 0 {
+				name = fmt.Sprintf("arg%d", i)
+			}
+			if name != filter.Arg {
+				continue
+			}
+			found = true
+			filter.typ = input.Type
+			if input.Indexed {
+				filter.topic = topic
+			}
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("event %s has no arg %s", event.Name, filter.Arg)
+		}
+		switch filter.typ.T {
+		case abi.AddressTy:
+			address, err := spec.ResolveAddress(filter.Value)
+			if err != nil {
+				return nil, fmt.Errorf("filter of %s: %v", filter.Arg, err)
+			} else if filter.Op != "=" && filter.Op != "!=" {
+				return nil, fmt.Errorf("filter of address %s must be = or !=", filter.Arg)
+			}
+			filter.Value = strings.ToLower(address.Hex())
+		case abi.IntTy, abi.UintTy:
+			if _, ok := new(big.Int).SetString(filter.Value, 10); !ok {
+				return nil, fmt.Errorf("filter of %s must be an integer", filter.Arg)
+			}
+		default:
+			if filter.Op != "=" && filter.Op != "!=" {
+				return nil, fmt.Errorf("filter of %s must be = or !=", filter.Arg)
+			}
+		}
+		if filter.topic == 0 || filter.Op != "=" {
+			continue
+		}
+		var hash common.Hash
+		switch filter.typ.T {
+		case abi.AddressTy:
+			hash = common.BytesToHash(common.HexToAddress(filter.Value).Bytes())
+		case abi.UintTy:
+			value, _ := new(big.Int).SetString(filter.Value, 10)
+			hash = common.BigToHash(value)
+		default:
+			continue
+		}
+		for len(topics) <= filter.topic {
+			topics = append(topics, nil)
+		}
+		topics[filter.topic] = append(topics[filter.topic], hash)
+	}
+	return topics, nil
+}
+
+// Match checks the decoded value of the arg, as in the decoded events.
+func (filter *LogFilter) Match(value interface{}) bool {
+	text := fmt.Sprint(value)
+	switch filter.typ.T {
+	case abi.IntTy, abi.UintTy:
+		x, ok1 := new(big.Int).SetString(text, 10)
+		y, ok2 := new(big.Int).SetString(filter.Value, 10)
+		if !ok1 || !ok2 {
+			return false
+		}
+		cmp := x.Cmp(y)
+		switch filter.Op {
+		case "=":
+			return cmp == 0
+		case "!=":
+			return cmp != 0
+		case ">":
+			return cmp > 0
+		case ">=":
+			return cmp >= 0
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		}
+		return false
+	}
+	equal := strings.EqualFold(text, filter.Value)
+	if filter.Op == "!=" {
+		return !equal
+	}
+	return equal
+}
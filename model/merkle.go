@@ -0,0 +1,125 @@
+package model
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// MerkleDistribution is the Merkle tree of the allocations in the format of Uniswap
+// MerkleDistributor, the claims are by address, with the amount and the proof of the claim.
+type MerkleDistribution struct {
+	MerkleRoot common.Hash             `json:"merkleRoot"`
+	TokenTotal string                  `json:"tokenTotal"`
+	Claims     map[string]*MerkleClaim `json:"claims"`
+}
+
+type MerkleClaim struct {
+	Index  uint64        `json:"index"`
+	Amount string        `json:"amount"`
+	Proof  []common.Hash `json:"proof"`
+}
+
+// NewMerkleDistribution builds the tree the way MerkleDistributor verifies the claims: the leaves
+// are keccak256(abi.encodePacked(index, account, amount)) sorted by value, the pairs are hashed
+// in sorted order, and the indices are of the checksummed addresses sorted as strings.
+func NewMerkleDistribution(recipients AirdropRecipients) *MerkleDistribution {
+	accounts := make([]string, len(recipients))
+	amounts := make(map[string]*AirdropRecipient, len(recipients))
+	for i, recipient := range recipients {
+		accounts[i] = common.HexToAddress(recipient.Address).Hex()
+		amounts[accounts[i]] = recipient
+	}
+	sort.Strings(accounts)
+	distribution := &MerkleDistribution{
+		TokenTotal: merkleAmount(recipients.Total()),
+		Claims:     make(map[string]*MerkleClaim, len(accounts)),
+	}
+	leaves := make([]common.Hash, len(accounts))
+	for i, account := range accounts {
+		amount := amounts[account].Value()
+		leaves[i] = merkleLeaf(uint64(i), common.HexToAddress(account), amount)
+		distribution.Claims[account] = &MerkleClaim{
+			Index:  uint64(i),
+			Amount: merkleAmount(amount),
+		}
+	}
+	sorted := append([]common.Hash{}, leaves...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Bytes(), sorted[j].Bytes()) < 0
+	})
+	layers := [][]common.Hash{sorted}
+	for layer := sorted; len(layer) > 1; {
+		next := make([]common.Hash, 0, (len(layer)+1)/2)
+		for i := 0; i < len(layer); i += 2 {
+			if i+1 == len(layer) {
+				// the odd node is promoted to the next layer
+				next = append(next, layer[i])
+				continue
+			}
+			next = append(next, merklePair(layer[i], layer[i+1]))
+		}
+		layers = append(layers, next)
+		layer = next
+	}
+	distribution.MerkleRoot = layers[len(layers)-1][0]
+	positions := make(map[common.Hash]int, len(sorted))
+	for i, leaf := range sorted {
+		positions[leaf] = i
+	}
+	for i, account := range accounts {
+		claim := distribution.Claims[account]
+		claim.Proof = []common.Hash{}
+		position := positions[leaves[i]]
+		for _, layer := range layers[:len(layers)-1] {
+			if pair := position ^ 1; pair < len(layer) {
+				claim.Proof = append(claim.Proof, layer[pair])
+			}
+			position /= 2
+		}
+	}
+	return distribution
+}
+
+// merkleAmount is the amount in hex of even length, as ethers BigNumber formats it.
+func merkleAmount(amount *big.Int) string {
+	hex := amount.Text(16)
+	if len(hex)%2 == 1 {
+		hex = "0" + hex
+	}
+	return "0x" + hex
+}
+
+// merkleLeaf is keccak256(abi.encodePacked(index, account, amount)) of the claim.
+func merkleLeaf(index uint64, account common.Address, amount *big.Int) common.Hash {
+	return crypto.Keccak256Hash(
+		common.LeftPadBytes(new(big.Int).SetUint64(index).Bytes(), 32),
+		account.Bytes(),
+		common.LeftPadBytes(amount.Bytes(), 32),
+	)
+}
+
+// merklePair hashes the pair of nodes in sorted order, as OpenZeppelin MerkleProof does.
+func merklePair(a, b common.Hash) common.Hash {
+	if bytes.Compare(a.Bytes(), b.Bytes()) > 0 {
+		a, b = b, a
+	}
+	return crypto.Keccak256Hash(a.Bytes(), b.Bytes())
+}
+
+// VerifyMerkleProof checks the proof of the claim against the root.
+func VerifyMerkleProof(root common.Hash, account common.Address, claim *MerkleClaim) bool {
+	amount, ok := new(big.Int).SetString(strings.TrimPrefix(claim.Amount, "0x"), 16)
+	if !ok {
+		return false
+	}
+	node := merkleLeaf(claim.Index, account, amount)
+	for _, sibling := range claim.Proof {
+		node = merklePair(node, sibling)
+	}
+	return node == root
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// the wallets of the Uniswap merkle-distributor tests, the first three are of its parseBalanceMap test
+const (
+	wallet0 = "0x17ec8597ff92C3F44523bDc65BF0f1bE632917ff"
+	wallet1 = "0x63FC2aD3d021a4D7e64323529a55a9442C444dA0"
+	wallet2 = "0xD1D84F0e28D6fedF03c73151f98dF95139700aa7"
+	wallet3 = "0xd59ca627Af68D29C547B91066297a7c469a7bF72"
+	wallet4 = "0xc2FCc7Bcf743153C58Efd44E6E723E9819E9A10A"
+)
+
+func TestMerkleLeaf(t *testing.T) {
+	tests := []struct {
+		index   uint64
+		account string
+		amount  int64
+		leaf    string
+	}{
+		{0, wallet0, 200, "0xd31de46890d4a77baeebddbd77bf73b5c626397b73ee8c69b51efe4c9a5a72fa"},
+		{1, wallet1, 300, "0xceaacce7533111e902cc548e961d77b23a4d8cd073c6b68ccf55c62bd47fc36b"},
+		{2, wallet2, 250, "0xbfeb956a3b705056020a3b64c540bff700c0f6c96c55c0a5fcab57124cb36f7b"},
+	}
+	for _, tt := range tests {
+		leaf := merkleLeaf(tt.index, common.HexToAddress(tt.account), big.NewInt(tt.amount))
+		if leaf != common.HexToHash(tt.leaf) {
+			t.Errorf("leaf of %s: got %s, want %s", tt.account, leaf.Hex(), tt.leaf)
+		}
+	}
+}
+
+func TestNewMerkleDistribution(t *testing.T) {
+	tests := []struct {
+		name         string
+		recipients   AirdropRecipients
+		distribution *MerkleDistribution
+	}{{
+		name: "uniswap parseBalanceMap",
+		recipients: AirdropRecipients{
+			{Address: strings.ToLower(wallet2), Units: "250"},
+			{Address: wallet0, Units: "200"},
+			{Address: strings.ToLower(wallet1), Units: "300"},
+		},
+		distribution: &MerkleDistribution{
+			MerkleRoot: common.HexToHash("0x2ec9c2fc2a55df417ba88ecd833f165fa3c5941772ebaf8c5f4debe33f4d1b12"),
+			TokenTotal: "0x02ee",
+			Claims: map[string]*MerkleClaim{
+				wallet0: {
+					Index:  0,
+					Amount: "0xc8",
+					Proof: []common.Hash{
+						common.HexToHash("0x2a411ed78501edb696adca9e41e78d8256b61cfac45612fa0434d7cf87d916c6"),
+					},
+				},
+				wallet1: {
+					Index:  1,
+					Amount: "0x012c",
+					Proof: []common.Hash{
+						common.HexToHash("0xbfeb956a3b705056020a3b64c540bff700c0f6c96c55c0a5fcab57124cb36f7b"),
+						common.HexToHash("0xd31de46890d4a77baeebddbd77bf73b5c626397b73ee8c69b51efe4c9a5a72fa"),
+					},
+				},
+				wallet2: {
+					Index:  2,
+					Amount: "0xfa",
+					Proof: []common.Hash{
+						common.HexToHash("0xceaacce7533111e902cc548e961d77b23a4d8cd073c6b68ccf55c62bd47fc36b"),
+						common.HexToHash("0xd31de46890d4a77baeebddbd77bf73b5c626397b73ee8c69b51efe4c9a5a72fa"),
+					},
+				},
+			},
+		},
+	}, {
+		name: "single recipient",
+		recipients: AirdropRecipients{
+			{Address: wallet0, Units: "200"},
+		},
+		distribution: &MerkleDistribution{
+			MerkleRoot: common.HexToHash("0xd31de46890d4a77baeebddbd77bf73b5c626397b73ee8c69b51efe4c9a5a72fa"),
+			TokenTotal: "0xc8",
+			Claims: map[string]*MerkleClaim{
+				wallet0: {
+					Index:  0,
+					Amount: "0xc8",
+					Proof:  []common.Hash{},
+				},
+			},
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distribution := NewMerkleDistribution(tt.recipients)
+			if !reflect.DeepEqual(distribution, tt.distribution) {
+				t.Fatalf("got distribution %+v, want %+v", distribution, tt.distribution)
+			}
+		})
+	}
+}
+
+func TestVerifyMerkleProof(t *testing.T) {
+	recipients := AirdropRecipients{
+		{Address: wallet0, Units: "1"},
+		{Address: wallet1, Units: "2"},
+		{Address: wallet2, Units: "3"},
+		{Address: wallet3, Units: "4"},
+		{Address: wallet4, Units: "1000000000000000000000"},
+	}
+	distribution := NewMerkleDistribution(recipients)
+	if distribution.TokenTotal != "0x3635c9adc5dea0000a" {
+		t.Errorf("got token total %s", distribution.TokenTotal)
+	}
+	for account, claim := range distribution.Claims {
+		if !VerifyMerkleProof(distribution.MerkleRoot, common.HexToAddress(account), claim) {
+			t.Errorf("proof of %s doesn't verify", account)
+		}
+		tampered := *claim
+		tampered.Amount = merkleAmount(big.NewInt(5))
+		if VerifyMerkleProof(distribution.MerkleRoot, common.HexToAddress(account), &tampered) {
+			t.Errorf("proof of %s verifies with a different amount", account)
+		}
+		tampered = *claim
+		tampered.Index++
+		if VerifyMerkleProof(distribution.MerkleRoot, common.HexToAddress(account), &tampered) {
+			t.Errorf("proof of %s verifies with a different index", account)
+		}
+	}
+}
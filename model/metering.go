@@ -0,0 +1,88 @@
+package model
+
+import (
+	log "github.com/Sirupsen/logrus"
+)
+
+// defaultComputeUnits is the estimated cost of the methods not listed in computeUnits.
+const defaultComputeUnits = 20
+
+// computeUnits are the estimated costs of the methods, close to the pricing of common providers
+// charging per compute unit, so the usage of a run can be compared with the plan of the provider.
+var computeUnits = map[string]int{
+	"eth_chainId":               0,
+	"net_version":               0,
+	"eth_blockNumber":           10,
+	"eth_getTransactionReceipt": 15,
+	"eth_getBlockByNumber":      16,
+	"eth_getBlockByHash":        16,
+	"eth_getStorageAt":          17,
+	"eth_getTransactionByHash":  17,
+	"eth_getBalance":            19,
+	"eth_getCode":               19,
+	"eth_gasPrice":              19,
+	"eth_getProof":              21,
+	"eth_call":                  26,
+	"eth_getTransactionCount":   26,
+	"eth_getLogs":               75,
+	"eth_estimateGas":           87,
+	"eth_sendRawTransaction":    250,
+	"debug_traceTransaction":    309,
+	"debug_traceCall":           309,
+	"txpool_content":            1000,
+}
+
+// NodeUsage is the usage of the node during the run, batches count every call.
+type NodeUsage struct {
+	Node         string
+	Calls        int
+	ComputeUnits int
+	Quota        int
+}
+
+// meter counts the calls of the request sent to the node, and warns once the quota is crossed.
+func (e *Endpoints) meter(url string, body []byte) {
+	requests, _ := rpcMessages(body)
+	var units int
+	for _, request := range requests {
+		cost, ok := computeUnits[request.Method]
+		if !ok {
+			cost = defaultComputeUnits
+		}
+		units += cost
+	}
+	e.mux.Lock()
+	usage, ok := e.usage[url]
+	if !ok {
+		usage = &NodeUsage{
+			Node:  redactURL(url),
+			Quota: e.quotas[url],
+		}
+		e.usage[url] = usage
+	}
+	crossed := usage.Quota > 0 && usage.ComputeUnits < usage.Quota && usage.ComputeUnits+units >= usage.Quota
+	usage.Calls += len(requests)
+	usage.ComputeUnits += units
+	e.mux.Unlock()
+	if crossed {
+		log.WithFields(log.Fields{
+			"group": e.group,
+			"node":  usage.Node,
+			"quota": usage.Quota,
+		}).Warningln("the run has crossed the compute units quota of the node")
+	}
+}
+
+// Usage returns the usage of the nodes called, in order of priority.
+func (e *Endpoints) Usage() []*NodeUsage {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	var usage []*NodeUsage
+	for _, url := range e.urls {
+		if nodeUsage, ok := e.usage[url]; ok {
+			copied := *nodeUsage
+			usage = append(usage, &copied)
+		}
+	}
+	return usage
+}
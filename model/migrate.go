@@ -0,0 +1,124 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SpecVersion is the current version of the spec format, specs without the version field are version 1.
+const SpecVersion = 2
+
+type specMigration struct {
+	// From is the version migrated from, to the next one.
+	From int
+	Desc string
+
+	migrate func(lines []string) []string
+}
+
+// specMigrations rewrite the spec text line by line, so comments and formatting are preserved.
+var specMigrations = []*specMigration{{
+	From:    1,
+	Desc:    "the eth denomination means ether now, values in eth are rewritten in wei",
+	migrate: migrateEthValues,
+}}
+
+var (
+	specVersionRx = regexp.MustCompile(`(?m)^version:\s*["']?(\d+)["']?\s*(#.*)?$`)
+	sectionRx     = regexp.MustCompile(`^([A-Z]+):`)
+)
+
+// ParseSpecVersion reads the version of the spec format from the spec data.
+func ParseSpecVersion(data []byte) (int, error) {
+	m := specVersionRx.FindSubmatch(data)
+	if m == nil {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(string(m[1]))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid spec version: %s", m[1])
+	} else if version > SpecVersion {
+		return 0, fmt.Errorf("spec version %d is newer than supported %d, upgrade the tool", version, SpecVersion)
+	}
+	return version, nil
+}
+
+// CheckSpecVersion fails on the spec data of an older format version, the specs
+// are never migrated on load, only rewritten by the migrate command.
+func CheckSpecVersion(data []byte) error {
+	version, err := ParseSpecVersion(data)
+	if err != nil {
+		return err
+	} else if version < SpecVersion {
+		return fmt.Errorf("spec format version %d is outdated, run `migrate` to update the file to version %d",
+			version, SpecVersion)
+	}
+	return nil
+}
+
+// MigrateSpec rewrites the spec data of an older format version to the current one,
+// it returns the descriptions of the applied migrations, none if the spec is up to date.
+func MigrateSpec(data []byte) ([]byte, []string, error) {
+	version, err := ParseSpecVersion(data)
+	if err != nil {
+		return nil, nil, err
+	} else if version == SpecVersion {
+		return data, nil, nil
+	}
+	lines := strings.Split(string(data), "\n")
+	var applied []string
+	for _, migration := range specMigrations {
+		if migration.From >= version {
+			lines = migration.migrate(lines)
+			applied = append(applied, fmt.Sprintf("v%d: %s", migration.From, migration.Desc))
+		}
+	}
+	return []byte(setSpecVersion(lines)), applied, nil
+}
+
+// setSpecVersion sets the version field to the current one, or adds it after the leading comments.
+func setSpecVersion(lines []string) string {
+	versionLine := fmt.Sprintf("version: %d", SpecVersion)
+	for i, line := range lines {
+		if specVersionRx.MatchString(line) {
+			lines[i] = versionLine
+			return strings.Join(lines, "\n")
+		}
+	}
+	at := 0
+	for at < len(lines) && (strings.HasPrefix(lines[at], "#") || strings.HasPrefix(lines[at], "---")) {
+		at++
+	}
+	lines = append(lines[:at], append([]string{versionLine, ""}, lines[at:]...)...)
+	return strings.Join(lines, "\n")
+}
+
+// inSections calls the rewrite for lines of the top-level sections.
+func inSections(lines []string, sections []string, rewrite func(line string) string) []string {
+	var current string
+	for i, line := range lines {
+		if m := sectionRx.FindStringSubmatch(line); m != nil {
+			current = m[1]
+			continue
+		}
+		for _, section := range sections {
+			if current == section {
+				lines[i] = rewrite(line)
+			}
+		}
+	}
+	return lines
+}
+
+var ethValueRx = regexp.MustCompile(`^(\s+value:\s*["']?[^#"']*\s)eth(["']?\s*(#.*)?)$`)
+
+func migrateEthValues(lines []string) []string {
+	return inSections(lines, []string{"WRITE", "TEMPLATES"}, func(line string) string {
+		if m := ethValueRx.FindStringSubmatch(line); m != nil {
+			return m[1] + "wei" + m[2]
+		}
+		return line
+	})
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AtlantPlatform/yaml"
+)
+
+func TestMigrateSpecRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       string
+		migrated   string
+		migrations int
+	}{{
+		name: "version 1 without the version field",
+		spec: `# token sale
+CONFIG:
+  gasPrice: 1 gwei
+
+WRITE:
+  buy:
+    wallet: alice
+    to: "0x5f2a1e9b3c7d4e8f6a0b1c2d3e4f5a6b7c8d9e0f"
+    value: 100 eth # the old denomination
+`,
+		migrated: `# token sale
+version: 2
+
+CONFIG:
+  gasPrice: 1 gwei
+
+WRITE:
+  buy:
+    wallet: alice
+    to: "0x5f2a1e9b3c7d4e8f6a0b1c2d3e4f5a6b7c8d9e0f"
+    value: 100 wei # the old denomination
+`,
+		migrations: 1,
+	}, {
+		name: "version 1 set explicitly",
+		spec: `version: 1
+TEMPLATES:
+  pay:
+    value: "5 eth"
+VIEW:
+  total:
+    value: 5 eth
+`,
+		migrated: `version: 2
+TEMPLATES:
+  pay:
+    value: "5 wei"
+VIEW:
+  total:
+    value: 5 eth
+`,
+		migrations: 1,
+	}, {
+		name: "current version",
+		spec: `version: 2
+WRITE:
+  buy:
+    value: 100 eth
+`,
+		migrated: `version: 2
+WRITE:
+  buy:
+    value: 100 eth
+`,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrated, migrations, err := MigrateSpec([]byte(tt.spec))
+			if err != nil {
+				t.Fatalf("MigrateSpec: %v", err)
+			} else if len(migrations) != tt.migrations {
+				t.Fatalf("got %d migrations, want %d: %v", len(migrations), tt.migrations, migrations)
+			} else if string(migrated) != tt.migrated {
+				t.Fatalf("migrated spec:\n%s\nwant:\n%s", migrated, tt.migrated)
+			}
+			if err := CheckSpecVersion(migrated); err != nil {
+				t.Fatalf("CheckSpecVersion of the migrated spec: %v", err)
+			}
+			again, migrations, err := MigrateSpec(migrated)
+			if err != nil {
+				t.Fatalf("MigrateSpec of the migrated spec: %v", err)
+			} else if len(migrations) > 0 || string(again) != string(migrated) {
+				t.Fatalf("migrated spec is migrated again: %v", migrations)
+			}
+			var spec *Spec
+			if err := yaml.Unmarshal(migrated, &spec); err != nil {
+				t.Fatalf("failed to parse the migrated spec: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckSpecVersion(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{spec: "WRITE: {}\n", err: "run `migrate`"},
+		{spec: "version: 1\n", err: "run `migrate`"},
+		{spec: "version: \"2\" # current\n"},
+		{spec: "version: 3\n", err: "newer than supported"},
+		{spec: "version: 0\n", err: "invalid spec version"},
+	}
+	for _, tt := range tests {
+		err := CheckSpecVersion([]byte(tt.spec))
+		if len(tt.err) == 0 && err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.spec, err)
+		} else if len(tt.err) > 0 && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%q: got error %v, want %q", tt.spec, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Severities of the alerts of the monitor rules.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Monitors are the rules of the monitor command, keyed by name.
+type Monitors map[string]*MonitorSpec
+
+// MonitorSpec alerts on the events On, given like the on hook of targets, e.g. token.Paused or
+// usdc.Transfer(value>1000000 USDC), where the filters of the integer args are the thresholds and
+// may be token amounts; contract.* matches any event of the contract. With Callers, only the events
+// of the transactions sent by other accounts alert, e.g. a mint by an unknown minter.
+type MonitorSpec struct {
+	On       string   `yaml:"on"`
+	Callers  []string `yaml:"callers"`
+	Severity string   `yaml:"severity"`
+
+	trigger *Trigger                `yaml:"-"`
+	callers map[common.Address]bool `yaml:"-"`
+}
+
+func (monitors Monitors) Validate(ctx AppContext, spec *Spec) bool {
+	for name, monitor := range monitors {
+		validateLog := log.WithFields(log.Fields{
+			"section": "Monitors",
+			"monitor": name,
+		})
+		if monitor == nil {
+			validateLog.Errorln("monitor has no spec")
+			return false
+		} else if err := monitor.validate(spec); err != nil {
+			validateLog.WithError(err).Errorln("invalid monitor")
+			return false
+		}
+	}
+	return true
+}
+
+func (spec *MonitorSpec) validate(root *Spec) error {
+	if len(spec.On) == 0 {
+		return errors.New("monitor has no event to alert on")
+	}
+	switch spec.Severity {
+	case "":
+		spec.Severity = SeverityWarning
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("unknown severity %s, must be info, warning or critical", spec.Severity)
+	}
+	trigger, err := ParseTrigger(spec.On)
+	if err != nil {
+		return err
+	}
+	if trigger.Event == "*" {
+		if len(trigger.Filters) > 0 {
+			return errors.New("any event of the contract can't be filtered by args")
+		}
+		if _, trigger.addresses, err = root.lookupEmitters(trigger.Contract); err != nil {
+			return err
+		}
+	} else {
+		if trigger.abiEvent, trigger.addresses, err = root.LookupEvent(trigger.Contract, trigger.Event); err != nil {
+			return err
+		} else if err := root.resolveAmountFilters(trigger.abiEvent, trigger.Filters); err != nil {
+			return err
+		} else if _, err := root.ResolveLogFilters(trigger.abiEvent, trigger.Filters); err != nil {
+			return err
+		}
+	}
+	spec.trigger = trigger
+	spec.callers = make(map[common.Address]bool, len(spec.Callers))
+	for _, caller := range spec.Callers {
+		address, err := root.ResolveAddress(caller)
+		if err != nil {
+			return fmt.Errorf("caller %s: %v", caller, err)
+		}
+		spec.callers[address] = true
+	}
+	return nil
+}
+
+// ABIEvent returns the event of the rule, nil if any event of the contract matches.
+func (spec *MonitorSpec) ABIEvent() *abi.Event {
+	return spec.trigger.abiEvent
+}
+
+// Emitters returns the addresses of the contract of the rule.
+func (spec *MonitorSpec) Emitters() []common.Address {
+	return spec.trigger.addresses
+}
+
+// Filters returns the conditions on the event args.
+func (spec *MonitorSpec) Filters() []*LogFilter {
+	return spec.trigger.Filters
+}
+
+// ChecksCallers reports whether the rule alerts on the unknown callers only.
+func (spec *MonitorSpec) ChecksCallers() bool {
+	return len(spec.callers) > 0
+}
+
+// KnownCaller reports whether the account is one of the callers of the rule.
+func (spec *MonitorSpec) KnownCaller(address common.Address) bool {
+	return spec.callers[address]
+}
+
+// Names returns the names of the rules, sorted, so the alerts of an event are in the same order.
+func (monitors Monitors) Names() []string {
+	names := make([]string, 0, len(monitors))
+	for name := range monitors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MonitoredAddresses returns the deployed instances of the spec contracts and the emitters of the rules,
+// the events of all of them are watched by the monitor command.
+func (spec *Spec) MonitoredAddresses(monitors Monitors) []common.Address {
+	seen := make(map[common.Address]bool)
+	var addresses []common.Address
+	add := func(address common.Address) {
+		if !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+	for _, contractSpec := range spec.Contracts {
+		if contractSpec == nil {
+			continue
+		}
+		for _, instance := range contractSpec.Instances {
+			if instance.IsDeployed() {
+				add(common.HexToAddress(instance.Address))
+			}
+		}
+	}
+	for _, name := range monitors.Names() {
+		for _, address := range monitors[name].Emitters() {
+			add(address)
+		}
+	}
+	return addresses
+}
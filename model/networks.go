@@ -0,0 +1,198 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Networks are overlays of the spec per network, e.g. mainnet, sepolia or local,
+// the overlay is applied once the network is selected with --network.
+type Networks map[string]*NetworkSpec
+
+type NetworkSpec struct {
+	// Group is the inventory group of the network nodes, used unless -g is set explicitly.
+	Group string `yaml:"group"`
+	// Nodes replace the nodes of the group, or make the group named after the network.
+	Nodes []*NodeSpec `yaml:"nodes"`
+	// Config overrides the non-empty config values, e.g. chainID or gasPrice.
+	Config *ConfigSpec `yaml:"config"`
+	// Wallets replace the wallets with the same name, or add new ones.
+	Wallets Wallets `yaml:"wallets"`
+	// Contracts set the addresses of contract instances, in order of instances.
+	Contracts map[string][]string `yaml:"contracts"`
+}
+
+// NodeSpec is an RPC endpoint of the network, the nodes with lower priority values are preferred,
+// the nodes of the same priority are tried in order.
+type NodeSpec struct {
+	URL      string `yaml:"url"`
+	Priority int    `yaml:"priority"`
+	// RateLimit is the requests per second sent to the node, overriding rateLimit of the config,
+	// Burst is the requests sent at once.
+	RateLimit int `yaml:"rateLimit"`
+	Burst     int `yaml:"burst"`
+	// Quota is the compute units the run may use on the node before a warning, overriding rpcQuota of the config.
+	Quota int `yaml:"quota"`
+	// Headers are sent with each request to the node, e.g. the API key of a private provider.
+	Headers map[string]string `yaml:"headers"`
+	// BearerToken is sent in the Authorization header, usually set from an env var.
+	BearerToken string `yaml:"bearerToken"`
+	// JWTSecret is the file with the hex-encoded secret signing a fresh token for each request,
+	// as the engine API of execution clients expects.
+	JWTSecret string `yaml:"jwtSecret"`
+
+	jwtKey []byte `yaml:"-"`
+}
+
+// validateNodes checks the credentials of the network nodes in the group and loads the JWT secrets.
+func (spec *Spec) validateNodes(ctx AppContext, groupName string) bool {
+	validateLog := log.WithFields(log.Fields{
+		"section": "Networks",
+		"group":   groupName,
+	})
+	for _, nodeURL := range spec.Inventory[groupName] {
+		node, ok := spec.nodes[nodeURL]
+		if !ok {
+			continue
+		}
+		nodeLog := validateLog.WithField("node", redactURL(nodeURL))
+		if node.hasAuth() && !isHTTP(nodeURL) {
+			nodeLog.Errorln("headers, bearerToken and jwtSecret can be set for HTTP nodes only")
+			return false
+		} else if len(node.BearerToken) > 0 && len(node.JWTSecret) > 0 {
+			nodeLog.Errorln("bearerToken and jwtSecret are mutually exclusive")
+			return false
+		}
+		if len(node.JWTSecret) > 0 {
+			if err := node.loadJWTSecret(ctx.SpecDir()); err != nil {
+				nodeLog.WithError(err).Errorln("failed to load jwtSecret")
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ApplyNetwork applies the overlay of the network to the spec, before it's validated.
+func (spec *Spec) ApplyNetwork(name string) (*NetworkSpec, error) {
+	network, ok := spec.Networks[name]
+	if !ok || network == nil {
+		return nil, fmt.Errorf("network %s is not defined in NETWORKS", name)
+	}
+	if len(network.Nodes) > 0 {
+		nodes := make([]*NodeSpec, 0, len(network.Nodes))
+		for _, node := range network.Nodes {
+			if node == nil {
+				continue // an empty list item
+			} else if len(node.URL) == 0 {
+				return nil, fmt.Errorf("network %s: node has no url", name)
+			}
+			nodes = append(nodes, node)
+		}
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].Priority < nodes[j].Priority
+		})
+		if len(network.Group) == 0 {
+			network.Group = name
+		}
+		if spec.Inventory == nil {
+			spec.Inventory = make(Inventory)
+		}
+		group := make(InventorySpec, 0, len(nodes))
+		for _, node := range nodes {
+			group = append(group, node.URL)
+			if spec.nodes == nil {
+				spec.nodes = make(map[string]*NodeSpec)
+			}
+			spec.nodes[node.URL] = node
+		}
+		spec.Inventory[network.Group] = group
+	}
+	if network.Config != nil {
+		config := *DefaultConfigSpec
+		if spec.Config != nil {
+			config = *spec.Config
+		}
+		overlayFields(&config, network.Config)
+		spec.Config = &config
+	}
+	if len(network.Wallets) > 0 && spec.Wallets == nil {
+		spec.Wallets = make(Wallets)
+	}
+	for walletName, wallet := range network.Wallets {
+		spec.Wallets[walletName] = wallet
+	}
+	for contractName, addresses := range network.Contracts {
+		contract, ok := spec.Contracts[contractName]
+		if !ok || contract == nil {
+			return nil, fmt.Errorf("network %s: contract %s not found", name, contractName)
+		} else if len(addresses) > len(contract.Instances) {
+			err := fmt.Errorf("network %s: contract %s has %d instances, got %d addresses",
+				name, contractName, len(contract.Instances), len(addresses))
+			return nil, err
+		}
+		for i, address := range addresses {
+			spec.relocateInstance(contractName, contract.Instances[i].Address, address)
+			contract.Instances[i].Address = address
+		}
+	}
+	return network, nil
+}
+
+// relocateInstance updates the instances referenced by commands, which are copies of the contract
+// instances made by YAML anchors, so they are matched by the contract name and the address.
+func (spec *Spec) relocateInstance(contractName, oldAddress, newAddress string) {
+	relocate := func(instance *ContractInstanceSpec) {
+		if instance != nil && instance.Name == contractName && strings.EqualFold(instance.Address, oldAddress) {
+			instance.Address = newAddress
+		}
+	}
+	for _, cmd := range spec.ViewCmds {
+		relocate(cmd.Instance)
+	}
+	for _, cmd := range spec.WriteCmds {
+		relocate(cmd.Instance)
+		relocate(cmd.Clone)
+	}
+}
+
+// overlayFields sets the non-empty exported values of the overlay struct
+// into the struct of the same type, both must be pointers. Maps are merged
+// by key into a copy of the destination map, non-empty slices replace it.
+func overlayFields(dst, overlay interface{}) {
+	dstValue := reflect.ValueOf(dst).Elem()
+	src := reflect.ValueOf(overlay).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Field(i)
+		if dstValue.Type().Field(i).PkgPath != "" {
+			continue // unexported
+		}
+		switch field.Kind() {
+		case reflect.Map:
+			if field.Len() == 0 {
+				continue
+			}
+			target := dstValue.Field(i)
+			merged := reflect.MakeMapWithSize(field.Type(), target.Len()+field.Len())
+			for _, key := range target.MapKeys() {
+				merged.SetMapIndex(key, target.MapIndex(key))
+			}
+			for _, key := range field.MapKeys() {
+				merged.SetMapIndex(key, field.MapIndex(key))
+			}
+			target.Set(merged)
+		case reflect.Slice:
+			if field.Len() > 0 {
+				dstValue.Field(i).Set(field)
+			}
+		default:
+			if !field.IsZero() {
+				dstValue.Field(i).Set(field)
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package model
+
+import (
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// KindNFTTransfer is a WRITE sending the token of the collection with safeTransferFrom,
+	// the amount of it for ERC-1155.
+	KindNFTTransfer CommandKind = "nft-transfer"
+	// KindNFTBatchTransfer is a WRITE sending the amounts of ERC-1155 tokens with safeBatchTransferFrom.
+	KindNFTBatchTransfer CommandKind = "nft-batch-transfer"
+	// KindNFTOwner is a VIEW of the owner of the ERC-721 token.
+	KindNFTOwner CommandKind = "nft-owner"
+	// KindNFTBalance is a VIEW of the tokens owned by the matching wallets,
+	// or the amount of the token for ERC-1155.
+	KindNFTBalance CommandKind = "nft-balance"
+)
+
+// expandKind expands the high-level command of the kind into the method call.
+func (spec *WriteCmdSpec) expandKind(validateLog *log.Entry, root *Spec) bool {
+	switch spec.Kind {
+	case KindNFTTransfer, KindNFTBatchTransfer:
+		return spec.expandNFTKind(validateLog, root)
+	}
+	return spec.expandTokenKind(validateLog, root)
+}
+
+// expandKind expands the high-level command of the kind into the method call.
+func (spec *ViewCmdSpec) expandKind(validateLog *log.Entry, root *Spec) bool {
+	switch spec.Kind {
+	case KindNFTOwner, KindNFTBalance:
+		return spec.expandNFTKind(validateLog, root)
+	}
+	return spec.expandTokenKind(validateLog, root)
+}
+
+// countKindArgs adds the args referenced by the fields of the kind, which are
+// counted before the command is expanded, e.g. tokenId: $1.
+func countKindArgs(set map[int]struct{}, fields ...string) {
+	for _, field := range fields {
+		if !isArgRef(field) {
+			continue
+		}
+		if argID, err := argReferenceID(field); err == nil {
+			set[argID] = struct{}{}
+		}
+	}
+}
+
+// uintParam is the uint256 param of the value, CLI args are resolved in references.
+func uintParam(value string) map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		"type":      string(ParamTypeUInt256),
+		"reference": value,
+	}
+}
+
+// uintArrayParam is the uint256[] param of the values.
+func uintArrayParam(values []string) map[interface{}]interface{} {
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = v
+	}
+	return map[interface{}]interface{}{
+		"type":  "uint256[]",
+		"value": list,
+	}
+}
+
+// expandNFTKind turns nft-transfer and nft-batch-transfer into the safe transfers
+// of the collection from the sending wallet, the recipient 'to' becomes the param.
+func (spec *WriteCmdSpec) expandNFTKind(validateLog *log.Entry, root *Spec) bool {
+	if spec.nft != nil {
+		// expanded already
+		return true
+	} else if len(spec.NFT) == 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("no nft collection specified")
+		return false
+	} else if spec.Instance != nil || len(spec.Method) > 0 || len(spec.Params) > 0 || len(spec.Args) > 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("nft commands must not have instance, method nor params specified")
+		return false
+	} else if spec.Clone != nil || len(spec.Ownership) > 0 || len(spec.Value) > 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("nft commands can't be used with value, clone or ownership helpers")
+		return false
+	} else if len(spec.To) == 0 {
+		validateLog.Errorln("no recipient 'to' specified")
+		return false
+	}
+	nft, err := root.FindNFT(spec.NFT)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to find the collection")
+		return false
+	}
+	to, err := tokenAccountParam(root, spec.To)
+	if err != nil {
+		validateLog.WithError(err).Errorln("invalid recipient")
+		return false
+	}
+	from := map[interface{}]interface{}{
+		"type":  string(ParamTypeAddress),
+		"value": walletPrefix + walletPrefix,
+	}
+	data := map[interface{}]interface{}{
+		"type":  string(ParamTypeBytes),
+		"value": "0x",
+	}
+	if len(spec.Data) > 0 {
+		data["value"] = spec.Data
+	}
+	switch spec.Kind {
+	case KindNFTTransfer:
+		if len(spec.TokenID) == 0 {
+			validateLog.Errorln("no tokenId specified to transfer")
+			return false
+		} else if len(spec.TokenIDs) > 0 || len(spec.Amounts) > 0 {
+			validateLog.Errorln("tokenIds and amounts are of nft-batch-transfer, use tokenId and amount")
+			return false
+		}
+		if nft.Standard == ERC1155 {
+			amount := spec.Amount
+			if len(amount) == 0 {
+				amount = "1"
+			}
+			spec.Params = []interface{}{from, to, uintParam(spec.TokenID), uintParam(amount), data}
+		} else if len(spec.Amount) > 0 {
+			validateLog.Errorln("amount must not be specified for ERC-721 tokens")
+			return false
+		} else {
+			spec.Params = []interface{}{from, to, uintParam(spec.TokenID), data}
+		}
+		spec.Method = "safeTransferFrom"
+	case KindNFTBatchTransfer:
+		if nft.Standard != ERC1155 {
+			validateLog.WithField("standard", nft.Standard).Errorln("batch transfers are of ERC-1155 tokens only")
+			return false
+		} else if len(spec.TokenIDs) == 0 {
+			validateLog.Errorln("no tokenIds specified to transfer")
+			return false
+		} else if len(spec.Amounts) != len(spec.TokenIDs) {
+			validateLog.Errorln("amounts must be specified for each of tokenIds")
+			return false
+		} else if len(spec.TokenID) > 0 || len(spec.Amount) > 0 {
+			validateLog.Errorln("tokenId and amount are of nft-transfer, use tokenIds and amounts")
+			return false
+		}
+		spec.Params = []interface{}{from, to, uintArrayParam(spec.TokenIDs), uintArrayParam(spec.Amounts), data}
+		spec.Method = "safeBatchTransferFrom"
+	}
+	spec.Instance = nft.instance
+	spec.To = ""
+	spec.nft = nft
+	return true
+}
+
+// expandNFTKind turns nft-owner into the ownerOf call of the token, and nft-balance into
+// the balanceOf call with the matching wallets as owners.
+func (spec *ViewCmdSpec) expandNFTKind(validateLog *log.Entry, root *Spec) bool {
+	if spec.nft != nil {
+		// expanded already
+		return true
+	} else if len(spec.NFT) == 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("no nft collection specified")
+		return false
+	} else if spec.Instance != nil || len(spec.Method) > 0 || len(spec.Params) > 0 || len(spec.Args) > 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("nft commands must not have instance, method nor params specified")
+		return false
+	}
+	nft, err := root.FindNFT(spec.NFT)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to find the collection")
+		return false
+	}
+	switch spec.Kind {
+	case KindNFTOwner:
+		if nft.Standard != ERC721 {
+			validateLog.WithField("standard", nft.Standard).Errorln("owners are of ERC-721 tokens only, use nft-balance")
+			return false
+		} else if len(spec.TokenID) == 0 {
+			validateLog.Errorln("no tokenId specified")
+			return false
+		}
+		spec.Method = "ownerOf"
+		spec.Params = []interface{}{uintParam(spec.TokenID)}
+	case KindNFTBalance:
+		if len(spec.matching) == 0 {
+			validateLog.WithField("kind", spec.Kind).Errorln("no wallets specified, the owners of the tokens")
+			return false
+		}
+		owner := map[interface{}]interface{}{
+			"type":  string(ParamTypeAddress),
+			"value": walletPrefix + walletPrefix,
+		}
+		spec.Method = "balanceOf"
+		spec.Params = []interface{}{owner}
+		if nft.Standard == ERC1155 {
+			if len(spec.TokenID) == 0 {
+				validateLog.Errorln("no tokenId specified, balances of ERC-1155 are per token")
+				return false
+			}
+			spec.Params = append(spec.Params, uintParam(spec.TokenID))
+		} else if len(spec.TokenID) > 0 {
+			validateLog.Errorln("tokenId must not be specified for the balance of ERC-721 tokens")
+			return false
+		}
+	}
+	spec.Instance = nft.instance
+	spec.nft = nft
+	return true
+}
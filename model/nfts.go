@@ -0,0 +1,246 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/AtlantPlatform/ethfw"
+	"github.com/AtlantPlatform/ethfw/sol"
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type NFTStandard string
+
+const (
+	ERC721  NFTStandard = "erc721"
+	ERC1155 NFTStandard = "erc1155"
+)
+
+// ERC-165 interface IDs of the standards.
+var (
+	erc721InterfaceID           = common.FromHex("0x80ac58cd")
+	erc721EnumerableInterfaceID = common.FromHex("0x780e9d63")
+	erc1155InterfaceID          = common.FromHex("0xd9b67a26")
+)
+
+// erc721Fragments is the ABI the ERC-721 collections are bound with, safeTransferFrom
+// is declared with data only, as overloads can't be told apart by the method name.
+var erc721Fragments = []string{
+	"function name() view returns (string)",
+	"function symbol() view returns (string)",
+	"function balanceOf(address owner) view returns (uint256)",
+	"function ownerOf(uint256 tokenId) view returns (address)",
+	"function tokenURI(uint256 tokenId) view returns (string)",
+	"function totalSupply() view returns (uint256)",
+	"function tokenOfOwnerByIndex(address owner, uint256 index) view returns (uint256)",
+	"function getApproved(uint256 tokenId) view returns (address)",
+	"function isApprovedForAll(address owner, address operator) view returns (bool)",
+	"function approve(address to, uint256 tokenId)",
+	"function setApprovalForAll(address operator, bool approved)",
+	"function transferFrom(address from, address to, uint256 tokenId)",
+	"function safeTransferFrom(address from, address to, uint256 tokenId, bytes data)",
+	"event Transfer(address indexed from, address indexed to, uint256 indexed tokenId)",
+	"event Approval(address indexed owner, address indexed approved, uint256 indexed tokenId)",
+	"event ApprovalForAll(address indexed owner, address indexed operator, bool approved)",
+}
+
+// erc1155Fragments is the ABI the ERC-1155 collections are bound with.
+var erc1155Fragments = []string{
+	"function balanceOf(address account, uint256 id) view returns (uint256)",
+	"function balanceOfBatch(address[] accounts, uint256[] ids) view returns (uint256[])",
+	"function uri(uint256 id) view returns (string)",
+	"function isApprovedForAll(address account, address operator) view returns (bool)",
+	"function setApprovalForAll(address operator, bool approved)",
+	"function safeTransferFrom(address from, address to, uint256 id, uint256 amount, bytes data)",
+	"function safeBatchTransferFrom(address from, address to, uint256[] ids, uint256[] amounts, bytes data)",
+	"event TransferSingle(address indexed operator, address indexed from, address indexed to, uint256 id, uint256 value)",
+	"event TransferBatch(address indexed operator, address indexed from, address indexed to, uint256[] ids, uint256[] values)",
+	"event ApprovalForAll(address indexed account, address indexed operator, bool approved)",
+}
+
+// NFTs are the ERC-721 and ERC-1155 collections by name.
+type NFTs map[string]*NFTSpec
+
+// NFTSpec is the collection declared by address or ENS name, the standard is detected
+// with ERC-165, unless specified, and cached on disk per chain and address.
+type NFTSpec struct {
+	Address  string      `yaml:"address"`
+	Standard NFTStandard `yaml:"standard"`
+	// Enumerable is whether ERC-721 tokens of an owner can be enumerated by index, detected with ERC-165.
+	Enumerable *bool `yaml:"enumerable"`
+
+	ensName  string                `yaml:"-"`
+	instance *ContractInstanceSpec `yaml:"-"`
+}
+
+type nftMetadata struct {
+	Standard   NFTStandard `json:"standard"`
+	Enumerable bool        `json:"enumerable"`
+}
+
+func (nfts NFTs) Validate(ctx AppContext, spec *Spec) bool {
+	var client *rpc.Client
+	if len(ctx.AppCommand()) > 0 {
+		// the nodes have been validated with the inventory
+		if endpoints, ok := spec.Endpoints(ctx.NodeGroup()); ok {
+			client, _ = endpoints.Dial(false)
+		}
+	}
+	if client != nil {
+		defer client.Close()
+	}
+	for name, nft := range nfts {
+		if nft == nil {
+			log.WithFields(log.Fields{
+				"section": "NFTs",
+				"nft":     name,
+			}).Errorln("nft spec must have an address")
+			return false
+		}
+		if !nft.Validate(ctx, name, spec, client) {
+			return false
+		}
+	}
+	return true
+}
+
+func (spec *NFTSpec) Validate(ctx AppContext, name string, root *Spec, client *rpc.Client) bool {
+	validateLog := log.WithFields(log.Fields{
+		"section": "NFTs",
+		"nft":     name,
+	})
+	if isENSName(spec.Address) {
+		address, err := root.ResolveENS(ctx, spec.Address)
+		if err != nil && client == nil {
+			validateLog.WithField("ens", spec.Address).Warningln("ENS name is not resolved without nodes")
+			return true
+		} else if err != nil {
+			validateLog.WithError(err).Errorln("failed to resolve collection ENS name")
+			return false
+		}
+		spec.ensName = spec.Address
+		spec.Address = strings.ToLower(address.Hex())
+	} else if !common.IsHexAddress(spec.Address) {
+		validateLog.Errorln("collection address is not valid (must be hex string starting from 0x, or ENS name)")
+		return false
+	}
+	switch spec.Standard {
+	case ERC721, ERC1155:
+	case "":
+		metadata, err := spec.metadata(ctx, root.Config, client)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to detect the standard of the collection")
+			return false
+		} else if metadata == nil {
+			validateLog.Warningln("collection standard is not known without nodes")
+			return true
+		}
+		spec.Standard = metadata.Standard
+		if spec.Enumerable == nil {
+			enumerable := metadata.Enumerable
+			spec.Enumerable = &enumerable
+		}
+	default:
+		validateLog.WithField("standard", spec.Standard).Errorln("unknown collection standard (erc721 or erc1155)")
+		return false
+	}
+	fragments := erc721Fragments
+	if spec.Standard == ERC1155 {
+		fragments = erc1155Fragments
+	}
+	abiJSON, _ := parseFragments(fragments)
+	binding, err := ethfw.BindContract(nil, &sol.Contract{
+		Name: name,
+		ABI:  abiJSON,
+	})
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to create collection binding")
+		return false
+	}
+	spec.instance = &ContractInstanceSpec{
+		Name:    name,
+		Address: strings.ToLower(spec.Address),
+		binding: binding,
+	}
+	return true
+}
+
+// metadata returns the cached standard of the collection, or detects it with ERC-165.
+// It's nil, if not cached and there are no nodes.
+func (spec *NFTSpec) metadata(ctx AppContext, config *ConfigSpec, client *rpc.Client) (*nftMetadata, error) {
+	path := cachePath(ctx.SpecDir(), "nfts", config.ChainID, strings.ToLower(spec.Address)+".json")
+	if data, ok := readCache(path); ok {
+		var metadata *nftMetadata
+		if err := json.Unmarshal(data, &metadata); err == nil && metadata != nil {
+			return metadata, nil
+		}
+	}
+	if client == nil {
+		return nil, nil
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, tokenFetchTimeout)
+	defer cancel()
+	address := common.HexToAddress(spec.Address)
+	metadata := new(nftMetadata)
+	if ok, err := supportsInterface(fetchCtx, client, address, erc721InterfaceID); err != nil {
+		return nil, err
+	} else if ok {
+		metadata.Standard = ERC721
+		metadata.Enumerable, _ = supportsInterface(fetchCtx, client, address, erc721EnumerableInterfaceID)
+	} else if ok, err := supportsInterface(fetchCtx, client, address, erc1155InterfaceID); err != nil {
+		return nil, err
+	} else if ok {
+		metadata.Standard = ERC1155
+	} else {
+		return nil, fmt.Errorf("%s supports neither ERC-721 nor ERC-1155, specify the standard", spec.Address)
+	}
+	if data, err := json.Marshal(metadata); err == nil {
+		if err := writeCache(path, data); err != nil {
+			log.WithError(err).Warningln("failed to cache collection standard")
+		}
+	}
+	return metadata, nil
+}
+
+// supportsInterface calls ERC-165 supportsInterface(bytes4), the contracts without it don't support any.
+func supportsInterface(ctx context.Context, client *rpc.Client, address common.Address, interfaceID []byte) (bool, error) {
+	data := append(common.FromHex("0x01ffc9a7"), common.RightPadBytes(interfaceID, 32)...)
+	result, err := ethCall(ctx, client, address, data)
+	if _, ok := err.(rpc.Error); ok {
+		// reverted
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return len(result) == 32 && result[31] == 1, nil
+}
+
+// IsEnumerable reports whether the ERC-721 tokens of an owner can be enumerated by index.
+func (spec *NFTSpec) IsEnumerable() bool {
+	return spec.Standard == ERC721 && spec.Enumerable != nil && *spec.Enumerable
+}
+
+// ENSName is the ENS name the collection address has been resolved from.
+func (spec *NFTSpec) ENSName() string {
+	return spec.ensName
+}
+
+// Instance is the collection contract bound with the ABI of the standard.
+func (spec *NFTSpec) Instance() *ContractInstanceSpec {
+	return spec.instance
+}
+
+// FindNFT returns the collection by the name in NFTS section.
+func (spec *Spec) FindNFT(name string) (*NFTSpec, error) {
+	nft, ok := spec.NFTs[name]
+	if !ok || nft == nil {
+		return nil, fmt.Errorf("collection %s is not found in NFTS section", name)
+	} else if nft.instance == nil {
+		return nil, fmt.Errorf("collection %s standard is not known, nodes are required", name)
+	}
+	return nft, nil
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hasAuth reports whether the node sends credentials other than the basic auth of the URL.
+func (node *NodeSpec) hasAuth() bool {
+	return len(node.Headers) > 0 || len(node.BearerToken) > 0 || len(node.JWTSecret) > 0
+}
+
+// loadJWTSecret reads the hex-encoded secret of the node, relative to the spec dir.
+func (node *NodeSpec) loadJWTSecret(specDir string) error {
+	path := node.JWTSecret
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(specDir, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	key, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+	if err != nil {
+		return fmt.Errorf("failed to decode jwtSecret: %v", err)
+	} else if len(key) != 32 {
+		return errors.New("jwtSecret must be 32 bytes hex-encoded")
+	}
+	node.jwtKey = key
+	return nil
+}
+
+// authorize sets the credentials of the node on the request: the headers, the bearer token or
+// a fresh JWT signed with the secret, and the basic auth set in the user info of the URL.
+func (e *Endpoints) authorize(req *http.Request, nodeURL string) {
+	if u, err := url.Parse(nodeURL); err == nil && u.User != nil {
+		password, _ := u.User.Password()
+		req.SetBasicAuth(u.User.Username(), password)
+	}
+	node, ok := e.nodes[nodeURL]
+	if !ok {
+		return
+	}
+	for name, value := range node.Headers {
+		req.Header.Set(name, value)
+	}
+	if len(node.jwtKey) > 0 {
+		req.Header.Set("Authorization", "Bearer "+jwtToken(node.jwtKey, time.Now()))
+	} else if len(node.BearerToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+node.BearerToken)
+	}
+}
+
+// jwtToken returns the HS256 token with the issued-at claim, as the engine API of execution
+// clients expects, it's valid for a minute.
+func jwtToken(key []byte, now time.Time) string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims := enc.EncodeToString([]byte(fmt.Sprintf(`{"iat":%d}`, now.Unix())))
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(header + "." + claims))
+	return header + "." + claims + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+// redactURL hides the password in the user info of the URL, so it's not logged.
+func redactURL(nodeURL string) string {
+	u, err := url.Parse(nodeURL)
+	if err != nil || u.User == nil {
+		return nodeURL
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
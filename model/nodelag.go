@@ -0,0 +1,100 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// defaultMaxLag is the number of blocks a node may be behind the highest head, unless set in the config.
+const defaultMaxLag = 5
+
+// syncedNodes drops the nodes still syncing, or lagging more than maxLag blocks behind the highest
+// head of the nodes and of the headReference node, so the reads are not stale. If no node is synced,
+// it waits for the nodes to catch up, up to waitSync.
+func (e *Endpoints) syncedNodes(ctx AppContext, nodes []string, config *ConfigSpec, validateLog *log.Entry) []string {
+	if e.cassette != nil && e.cassette.Replaying() {
+		return nodes
+	}
+	waitSync, _ := config.WaitSyncDuration()
+	deadline := time.Now().Add(waitSync)
+	for {
+		synced := e.syncedNodesOnce(ctx, nodes, config, validateLog)
+		if len(synced) > 0 || !time.Now().Before(deadline) {
+			return synced
+		}
+		validateLog.WithField("timeout", waitSync.String()).Infoln("waiting for the nodes to sync")
+		select {
+		case <-time.After(config.BlockTimeDuration()):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (e *Endpoints) syncedNodesOnce(ctx AppContext, nodes []string, config *ConfigSpec, validateLog *log.Entry) []string {
+	maxLag := uint64(config.MaxLag)
+	if maxLag == 0 {
+		maxLag = defaultMaxLag
+	}
+	heads := make(map[string]uint64, len(nodes))
+	var highest uint64
+	for _, node := range nodes {
+		nodeLog := validateLog.WithField("node", redactURL(node))
+		var syncing json.RawMessage
+		if err := e.callNodeTimeout(ctx, node, &syncing, "eth_syncing"); err == nil && string(syncing) != "false" {
+			nodeLog.Warningln("node is syncing")
+			continue
+		}
+		head, err := e.headOf(ctx, node)
+		if err != nil {
+			nodeLog.WithError(err).Warningln("failed to get the head of the node")
+			continue
+		}
+		heads[node] = head
+		if head > highest {
+			highest = head
+		}
+	}
+	if len(config.HeadReference) > 0 {
+		reference, err := e.headOf(ctx, config.HeadReference)
+		if err != nil {
+			validateLog.WithError(err).Warningln("failed to get the head of the reference node")
+		} else if reference > highest {
+			highest = reference
+		}
+	}
+	var synced []string
+	for _, node := range nodes {
+		head, ok := heads[node]
+		if !ok {
+			continue
+		} else if head+maxLag < highest {
+			validateLog.WithFields(log.Fields{
+				"node":    redactURL(node),
+				"head":    head,
+				"highest": highest,
+			}).Warningln("node is lagging behind")
+			continue
+		}
+		synced = append(synced, node)
+	}
+	return synced
+}
+
+func (e *Endpoints) headOf(ctx context.Context, node string) (uint64, error) {
+	var head hexutil.Uint64
+	if err := e.callNodeTimeout(ctx, node, &head, "eth_blockNumber"); err != nil {
+		return 0, err
+	}
+	return uint64(head), nil
+}
+
+func (e *Endpoints) callNodeTimeout(ctx context.Context, node string, result interface{}, method string) error {
+	callCtx, cancel := context.WithTimeout(ctx, nodeProbeTimeout)
+	defer cancel()
+	return e.callNode(callCtx, node, result, method)
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// StateOverrideSpec overrides the account state for a view call, the map key is
+// the account address or a wallet name. Storage maps slots to values, other slots are kept.
+type StateOverrideSpec struct {
+	Balance string                      `yaml:"balance"`
+	Nonce   string                      `yaml:"nonce"`
+	Code    string                      `yaml:"code"`
+	Storage map[interface{}]interface{} `yaml:"storage"`
+}
+
+// StateOverride is the account override in the format of eth_call.
+type StateOverride struct {
+	Balance   *hexutil.Big                `json:"balance,omitempty"`
+	Nonce     *hexutil.Uint64             `json:"nonce,omitempty"`
+	Code      hexutil.Bytes               `json:"code,omitempty"`
+	StateDiff map[common.Hash]common.Hash `json:"stateDiff,omitempty"`
+}
+
+type StateOverrides map[common.Address]*StateOverride
+
+func parseStateOverrides(root *Spec, specs map[string]*StateOverrideSpec) (StateOverrides, error) {
+	overrides := make(StateOverrides, len(specs))
+	for account, spec := range specs {
+		if spec == nil {
+			continue
+		}
+		address, err := overrideAddress(root, account)
+		if err != nil {
+			return nil, err
+		}
+		override, err := spec.parse()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", account, err)
+		}
+		overrides[address] = override
+	}
+	return overrides, nil
+}
+
+func overrideAddress(root *Spec, account string) (common.Address, error) {
+	if wallet, ok := root.Wallets.WalletSpec(strings.TrimPrefix(account, walletPrefix)); ok {
+		return common.HexToAddress(wallet.Address), nil
+	} else if common.IsHexAddress(account) {
+		return common.HexToAddress(account), nil
+	}
+	err := fmt.Errorf("override account must be an address or a wallet name: %s", account)
+	return common.Address{}, err
+}
+
+func (spec *StateOverrideSpec) parse() (*StateOverride, error) {
+	override := &StateOverride{}
+	if len(spec.Balance) > 0 {
+		result, err := NewEvaler().Run(spec.Balance, ExprTypeInterger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse balance: %v", err)
+		}
+		override.Balance = (*hexutil.Big)(result.(*big.Int))
+	}
+	if len(spec.Nonce) > 0 {
+		nonce, ok := new(big.Int).SetString(spec.Nonce, 0)
+		if !ok || !nonce.IsUint64() {
+			return nil, errors.New("failed to parse nonce")
+		}
+		v := hexutil.Uint64(nonce.Uint64())
+		override.Nonce = &v
+	}
+	if len(spec.Code) > 0 {
+		code, err := hexutil.Decode(spec.Code)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse code: %v", err)
+		}
+		override.Code = code
+	}
+	if len(spec.Storage) > 0 {
+		override.StateDiff = make(map[common.Hash]common.Hash, len(spec.Storage))
+		for slot, value := range spec.Storage {
+			slotHash, err := ParseWord(nillableStr(slot))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse storage slot %v: %v", slot, err)
+			}
+			valueHash, err := ParseWord(nillableStr(value))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse storage value of slot %v: %v", slot, err)
+			}
+			override.StateDiff[slotHash] = valueHash
+		}
+	}
+	return override, nil
+}
+
+// ParseWord parses a 32-byte word given as hex (0x-prefixed) or decimal number.
+func ParseWord(str string) (common.Hash, error) {
+	v, ok := new(big.Int).SetString(str, 0)
+	if !ok || v.Sign() < 0 || v.BitLen() > 256 {
+		return common.Hash{}, errors.New("must be a 32-byte hex or decimal number")
+	}
+	return common.BigToHash(v), nil
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type OwnershipAction string
+
+const (
+	OwnershipTransfer OwnershipAction = "transfer"
+	OwnershipRenounce OwnershipAction = "renounce"
+)
+
+// DefaultAdminRole is the admin role of AccessControl contracts.
+const DefaultAdminRole = "DEFAULT_ADMIN_ROLE"
+
+// MethodCall is a contract method invocation prepared during validation.
+type MethodCall struct {
+	Method string
+	Params []interface{}
+}
+
+// ownershipCalls detects whether the contract is Ownable or AccessControl from its ABI
+// and returns the method calls needed to transfer or renounce the ownership.
+func ownershipCalls(contractABI abi.ABI, action OwnershipAction,
+	newOwner common.Address, role string) ([]*MethodCall, error) {
+	if hasMethod(contractABI, "transferOwnership", "address") && len(role) == 0 {
+		switch action {
+		case OwnershipTransfer:
+			return []*MethodCall{{
+				Method: "transferOwnership",
+				Params: []interface{}{newOwner},
+			}}, nil
+		case OwnershipRenounce:
+			if !hasMethod(contractABI, "renounceOwnership") {
+				return nil, errors.New("contract is Ownable, but has no renounceOwnership method")
+			}
+			return []*MethodCall{{
+				Method: "renounceOwnership",
+			}}, nil
+		}
+	}
+	if hasMethod(contractABI, "grantRole", "bytes32", "address") &&
+		hasMethod(contractABI, "renounceRole", "bytes32", "address") {
+		if len(role) == 0 {
+			role = DefaultAdminRole
+		}
+		roleHash, err := roleID(role)
+		if err != nil {
+			return nil, err
+		}
+		renounce := &MethodCall{
+			Method: "renounceRole",
+			Params: []interface{}{roleHash, PlaceholderAddr},
+		}
+		switch action {
+		case OwnershipTransfer:
+			grant := &MethodCall{
+				Method: "grantRole",
+				Params: []interface{}{roleHash, newOwner},
+			}
+			return []*MethodCall{grant, renounce}, nil
+		case OwnershipRenounce:
+			return []*MethodCall{renounce}, nil
+		}
+	}
+	err := errors.New("contract ABI is neither Ownable nor AccessControl")
+	return nil, err
+}
+
+func hasMethod(contractABI abi.ABI, name string, inputs ...string) bool {
+	method, ok := contractABI.Methods[name]
+	if !ok || len(method.Inputs) != len(inputs) {
+		return false
+	}
+	for i, input := range method.Inputs {
+		if input.Type.String() != inputs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// roleID returns the AccessControl role identifier, that is either a 32-byte hex string,
+// or keccak256 of the role name. The admin role is zero.
+func roleID(role string) ([32]byte, error) {
+	var id [32]byte
+	if role == DefaultAdminRole {
+		return id, nil
+	}
+	if strings.HasPrefix(role, "0x") {
+		data, err := hexutil.Decode(role)
+		if err != nil || len(data) != 32 {
+			err := fmt.Errorf("role must be a name or 32-byte hex string: %s", role)
+			return id, err
+		}
+		copy(id[:], data)
+		return id, nil
+	}
+	copy(id[:], crypto.Keccak256([]byte(role)))
+	return id, nil
+}
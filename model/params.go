@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -14,8 +15,10 @@ import (
 
 type ParamSpec struct {
 	Params []interface{} `yaml:"params"`
+	Args   NamedArgs     `yaml:"args"`
 
-	paramValues []interface{} `yaml:"-"`
+	paramValues  []interface{} `yaml:"-"`
+	argsResolved bool          `yaml:"-"`
 }
 
 func (spec *ParamSpec) Validate(ctx AppContext, name string, root *Spec) bool {
@@ -57,6 +60,55 @@ func (spec *ParamSpec) validateParam(ctx AppContext,
 		}
 		paramType := ParamType(typ.(string))
 
+		if exprStr := nillableStr(p["expr"]); len(exprStr) > 0 {
+			if len(valueStr) > 0 || len(referenceStr) > 0 {
+				validateLog.Errorln("expr cannot co-exist with value or reference in param spec")
+				return false
+			}
+			expr, err := parseExpression(ctx, root, exprStr)
+			if err != nil {
+				validateLog.WithField("expr", exprStr).WithError(err).Errorln("failed to parse param expression")
+				return false
+			}
+			spec.paramValues[paramID] = &ParamExpression{
+				Expression: expr,
+				Type:       paramType,
+			} // will be evaluated later
+			return true
+		}
+		path := fmt.Sprintf("params[%d]", paramID)
+		if argName := nillableStr(p["name"]); len(argName) > 0 {
+			path = argName
+		}
+		if isTupleType(string(paramType)) {
+			if len(referenceStr) > 0 {
+				validateLog.WithField("type", string(paramType)).Errorln("tuple params must have a map or a list value")
+				return false
+			}
+			v, err := newTupleValue(root, evaler, string(paramType), p["value"], path)
+			if err != nil {
+				validateLog.WithError(err).Errorln("param parsing error, check type")
+				return false
+			}
+			spec.paramValues[paramID] = v
+			return true
+		}
+		if list, ok := p["value"].([]interface{}); ok {
+			if !isArrayType(string(paramType)) {
+				validateLog.WithField("type", string(paramType)).Errorln("list values are allowed only for array types")
+				return false
+			}
+			v, err := parseArrayParam(root, evaler, string(paramType), list, path)
+			if err != nil {
+				validateLog.WithError(err).Errorln("param parsing error, check type")
+				return false
+			}
+			spec.paramValues[paramID] = v
+			return true
+		} else if _, ok := p["value"].(map[interface{}]interface{}); ok {
+			validateLog.WithField("type", string(paramType)).Errorln("map values are allowed only for tuple types")
+			return false
+		}
 		if len(referenceStr) > 0 {
 			refLog := validateLog.WithField("reference", referenceStr)
 			if isWalletRef(referenceStr) {
@@ -64,6 +116,10 @@ func (spec *ParamSpec) validateParam(ctx AppContext,
 					spec.paramValues[paramID] = PlaceholderAddr // will be resolved later
 					return true
 				}
+				if ref, ok := newCommandOutputReference(root, referenceStr); ok {
+					spec.paramValues[paramID] = ref // will be resolved later
+					return true
+				}
 				ref, err := newWalletFieldReference(root, referenceStr)
 				if err != nil {
 					refLog.WithError(err).Errorln("failed to resolve reference")
@@ -102,16 +158,40 @@ func (spec *ParamSpec) validateParam(ctx AppContext,
 					spec.paramValues[paramID] = PlaceholderAddr // will be resolved later
 					return true
 				}
-				if _, existing := root.Wallets.WalletSpec(walletName); !existing {
+				if _, existing := root.Wallets.WalletSpec(walletName); existing {
+					ref := &WalletFieldReference{
+						WalletName: walletName,
+						FieldName:  WalletSpecAddressField,
+					}
+					spec.paramValues[paramID] = ref // will be resolved later
+					return true
+				} else if _, labeled := root.AddressBook.Address(walletName); !labeled {
 					validateLog.WithField("wallet", walletName).Errorln("unknown wallet reference")
 					return false
 				}
-				ref := &WalletFieldReference{
-					WalletName: walletName,
-					FieldName:  WalletSpecAddressField,
-				}
-				spec.paramValues[paramID] = ref // will be resolved later
-				return true
+			}
+		}
+		if len(valueStr) > 0 && paramType == ParamTypeAddress {
+			// labels of the address book, e.g. @binance or binance
+			if address, ok := root.AddressBook.Address(valueStr); ok {
+				valueStr = address.Hex()
+			}
+		}
+		if len(valueStr) > 0 && paramType == ParamTypeAddress && isENSName(valueStr) {
+			address, err := root.ResolveENS(ctx, valueStr)
+			if err != nil {
+				validateLog.WithField("ens", valueStr).WithError(err).Errorln("failed to resolve ENS name")
+				return false
+			}
+			valueStr = address.Hex()
+		}
+		if len(valueStr) > 0 && isIntegerParamType(paramType) {
+			// token amounts, e.g. 100 DAI
+			if amount, ok, err := root.tokenAmount(valueStr); err != nil {
+				validateLog.WithField("value", valueStr).WithError(err).Errorln("param parsing error, check token amount")
+				return false
+			} else if ok {
+				valueStr = amount.String()
 			}
 		}
 		if len(valueStr) > 0 {
@@ -136,11 +216,13 @@ func (spec *ParamSpec) validateParam(ctx AppContext,
 }
 
 func (spec *ParamSpec) CountArgsUsing(set map[int]struct{}) {
+	spec.Args.countArgsUsing(set)
 	for _, param := range spec.Params {
 		p, ok := param.(map[interface{}]interface{})
 		if !ok {
 			continue
 		}
+		countConditionArgs(nillableStr(p["expr"]), set)
 		referenceStr := nillableStr(p["reference"])
 		if len(referenceStr) == 0 {
 			continue
@@ -212,6 +294,12 @@ const (
 	ParamTypeBytes   ParamType = "bytes"
 )
 
+// isIntegerParamType reports whether the param is an integer of any size, e.g. uint256.
+func isIntegerParamType(typ ParamType) bool {
+	str := string(typ)
+	return (strings.HasPrefix(str, "uint") || strings.HasPrefix(str, "int")) && !isArrayType(str)
+}
+
 func parseParam(evaler *Evaler, typ ParamType, value string) (vv interface{}, ok bool) {
 	parseIntBits := func(bits int) (interface{}, bool) {
 		if result, err := evaler.Run(value, ExprTypeInterger); err == nil {
@@ -247,6 +335,10 @@ func parseParam(evaler *Evaler, typ ParamType, value string) (vv interface{}, ok
 		ok = true
 	case ParamTypeAddress:
 		if ok = common.IsHexAddress(value); ok {
+			if !isChecksumValid(value) {
+				log.WithField("address", value).Warningln("address checksum mismatch, check for typos")
+				return nil, false
+			}
 			vv = common.HexToAddress(value)
 		}
 	case ParamTypeByte:
@@ -383,6 +475,56 @@ type ArgReference struct {
 	ArgID int
 }
 
+// CommandOutputReference references the result of a command that has been run earlier
+// within the same target, either as a whole (@name), an element of array result (@name.0),
+// or a field (@name.field): the result of the wallet for multi-wallet commands, a key of
+// a map result, or the address of the instance deployed by the command.
+type CommandOutputReference struct {
+	CmdName string
+	Index   int
+	Field   string
+}
+
+func (ref *CommandOutputReference) String() string {
+	if len(ref.Field) > 0 {
+		return "@" + ref.CmdName + "." + ref.Field
+	} else if ref.Index >= 0 {
+		return fmt.Sprintf("@%s.%d", ref.CmdName, ref.Index)
+	}
+	return "@" + ref.CmdName
+}
+
+var outputFieldRx = regexp.MustCompile(`^[\w-]+$`)
+
+func newCommandOutputReference(root *Spec, value string) (*CommandOutputReference, bool) {
+	refParts := strings.Split(value[1:], refDelim)
+	if len(refParts) > 2 {
+		return nil, false
+	} else if _, ok := root.Wallets.WalletSpec(refParts[0]); ok {
+		// wallet names take precedence
+		return nil, false
+	} else if !root.HasCommand(refParts[0]) && refParts[0] != TriggerEventOutput {
+		return nil, false
+	}
+	ref := &CommandOutputReference{
+		CmdName: refParts[0],
+		Index:   -1,
+	}
+	if len(refParts) == 2 {
+		if index, err := strconv.Atoi(refParts[1]); err == nil {
+			if index < 0 {
+				return nil, false
+			}
+			ref.Index = index
+		} else if outputFieldRx.MatchString(refParts[1]) {
+			ref.Field = refParts[1]
+		} else {
+			return nil, false
+		}
+	}
+	return ref, true
+}
+
 func createStaticBytes(length int, value string) (interface{}, bool) {
 	var buf []byte
 	if strings.HasPrefix(value, "0x") {
@@ -0,0 +1,211 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	priceAPIKeyEnv = "PRICE_API_KEY"
+	// currentPriceTTL is how long the current prices are cached on disk, so the reports run
+	// one after another don't query the API again.
+	currentPriceTTL = 5 * time.Minute
+)
+
+var fiatCurrencyRx = regexp.MustCompile(`^[a-z]{3,4}$`)
+
+// pricePlatforms are the asset platforms of the price API by chain ID, the coins of the tokens
+// without priceID are looked up by their address on the platform of the chain.
+var pricePlatforms = map[string]string{
+	"1":     "ethereum",
+	"10":    "optimistic-ethereum",
+	"56":    "binance-smart-chain",
+	"100":   "xdai",
+	"137":   "polygon-pos",
+	"8453":  "base",
+	"42161": "arbitrum-one",
+	"43114": "avalanche",
+}
+
+// PriceAPI is the client of the CoinGecko-compatible price API of priceURL, the prices are in the fiat
+// currency of the config. The prices of the past days are cached on disk for good, the current ones
+// for a few minutes, and the coins of the tokens looked up by address are cached too.
+type PriceAPI struct {
+	url      string
+	apiKey   string
+	currency string
+	platform string
+	specDir  string
+}
+
+func (spec *ConfigSpec) PriceAPI() *PriceAPI {
+	apiKey := spec.PriceAPIKey
+	if len(apiKey) == 0 {
+		apiKey = os.Getenv(priceAPIKeyEnv)
+	}
+	return &PriceAPI{
+		url:      strings.TrimSuffix(spec.PriceURL, "/"),
+		apiKey:   apiKey,
+		currency: spec.FiatCurrency,
+		platform: pricePlatforms[spec.ChainID],
+		specDir:  spec.SpecDir,
+	}
+}
+
+// SetFiatCurrency overrides the fiat currency of the config, e.g. by the currency option of the reports.
+func (spec *ConfigSpec) SetFiatCurrency(currency string) error {
+	currency = strings.ToLower(currency)
+	if !fiatCurrencyRx.MatchString(currency) {
+		return fmt.Errorf("%s is not a currency code, e.g. usd", currency)
+	}
+	spec.FiatCurrency = currency
+	return nil
+}
+
+// Currency is the fiat currency the prices are in, e.g. usd.
+func (api *PriceAPI) Currency() string {
+	return api.currency
+}
+
+// CoinID returns the coin of the token in the price API, its priceID or the one listed for its address.
+func (api *PriceAPI) CoinID(ctx context.Context, token *TokenSpec) (string, error) {
+	if len(token.PriceID) > 0 {
+		return token.PriceID, nil
+	} else if len(api.platform) == 0 {
+		return "", fmt.Errorf("set priceID of %s, the chain has no known platform in the price API", token.Symbol)
+	}
+	address := strings.ToLower(token.Address)
+	path := cachePath(api.specDir, "prices", "coins", api.platform, address)
+	if data, ok := readCache(path); ok {
+		return string(data), nil
+	}
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := api.get(ctx, "coins/"+api.platform+"/contract/"+address, nil, &result); err != nil {
+		return "", err
+	} else if len(result.ID) == 0 {
+		return "", fmt.Errorf("no coin of %s is listed in the price API", token.Symbol)
+	}
+	_ = writeCache(path, []byte(result.ID))
+	return result.ID, nil
+}
+
+// Price returns the price of the coin in the fiat currency: the current one if the time is zero,
+// otherwise the price of its day in UTC.
+func (api *PriceAPI) Price(ctx context.Context, coinID string, at time.Time) (*big.Rat, error) {
+	if at.IsZero() {
+		return api.currentPrice(ctx, coinID)
+	}
+	day := at.UTC().Format("02-01-2006")
+	path := cachePath(api.specDir, "prices", api.currency, coinID, day)
+	if data, ok := readCache(path); ok {
+		return parsePrice(string(data))
+	}
+	query := url.Values{}
+	query.Set("date", day)
+	query.Set("localization", "false")
+	var result struct {
+		MarketData struct {
+			CurrentPrice map[string]json.Number `json:"current_price"`
+		} `json:"market_data"`
+	}
+	if err := api.get(ctx, "coins/"+url.PathEscape(coinID)+"/history", query, &result); err != nil {
+		return nil, err
+	}
+	value, ok := result.MarketData.CurrentPrice[api.currency]
+	if !ok {
+		return nil, fmt.Errorf("no %s price of %s on %s", api.currency, coinID, day)
+	}
+	price, err := parsePrice(value.String())
+	if err != nil {
+		return nil, err
+	}
+	// the price of the current day changes until it's over
+	if at.UTC().AddDate(0, 0, 1).Before(time.Now().UTC()) {
+		_ = writeCache(path, []byte(value.String()))
+	}
+	return price, nil
+}
+
+func (api *PriceAPI) currentPrice(ctx context.Context, coinID string) (*big.Rat, error) {
+	path := cachePath(api.specDir, "prices", api.currency, coinID, "current")
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < currentPriceTTL {
+		if data, ok := readCache(path); ok {
+			return parsePrice(string(data))
+		}
+	}
+	query := url.Values{}
+	query.Set("ids", coinID)
+	query.Set("vs_currencies", api.currency)
+	var result map[string]map[string]json.Number
+	if err := api.get(ctx, "simple/price", query, &result); err != nil {
+		return nil, err
+	}
+	value, ok := result[coinID][api.currency]
+	if !ok {
+		return nil, fmt.Errorf("no %s price of %s", api.currency, coinID)
+	}
+	price, err := parsePrice(value.String())
+	if err != nil {
+		return nil, err
+	}
+	_ = writeCache(path, []byte(value.String()))
+	return price, nil
+}
+
+func (api *PriceAPI) get(ctx context.Context, method string, query url.Values, v interface{}) error {
+	endpoint := api.url + "/" + method
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if len(api.apiKey) > 0 {
+		// the paid plans have their own host and header
+		if strings.HasPrefix(req.URL.Host, "pro-api.") {
+			req.Header.Set("x-cg-pro-api-key", api.apiKey)
+		} else {
+			req.Header.Set("x-cg-demo-api-key", api.apiKey)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("price API responded with status %s", resp.Status)
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("price API returned malformed response: %v", err)
+	}
+	return nil
+}
+
+func parsePrice(value string) (*big.Rat, error) {
+	price, ok := new(big.Rat).SetString(value)
+	if !ok || price.Sign() <= 0 {
+		return nil, errors.New("price API returned malformed price " + value)
+	}
+	return price, nil
+}
+
+// FormatFiat formats the value in the fiat currency with two decimals.
+func FormatFiat(value *big.Rat) string {
+	return value.FloatString(2)
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimit is a token bucket limiting the requests per second sent to a node, a burst of
+// requests up to the bucket size is sent at once. Zero rate means no limit, but the requests
+// are still held back after the node has responded with 429 Too Many Requests.
+type rateLimit struct {
+	rate  float64
+	burst float64
+
+	mux    *sync.Mutex
+	tokens float64
+	last   time.Time
+	until  time.Time
+}
+
+func newRateLimit(rate, burst int) *rateLimit {
+	if burst < 1 {
+		burst = rate
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimit{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		mux:    new(sync.Mutex),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until the request may be sent, or the context is done.
+func (l *rateLimit) Wait(ctx context.Context) error {
+	for {
+		l.mux.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		var wait time.Duration
+		if now.Before(l.until) {
+			wait = l.until.Sub(now)
+		} else if l.rate <= 0 || l.tokens >= 1 {
+			l.tokens--
+			l.mux.Unlock()
+			return nil
+		} else {
+			wait = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.mux.Unlock()
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Backoff holds the requests for the duration, e.g. the Retry-After of the node.
+func (l *rateLimit) Backoff(d time.Duration) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if until := time.Now().Add(d); until.After(l.until) {
+		l.until = until
+	}
+	l.tokens = 0
+}
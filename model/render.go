@@ -0,0 +1,115 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/AtlantPlatform/yaml"
+)
+
+// Spec templates use ${{ }} delimiters, so they don't clash with {{ }}
+// of foreach and command templates, which are rendered later.
+const (
+	specTemplateLeftDelim  = "${{"
+	specTemplateRightDelim = "}}"
+)
+
+// specTemplateData is available in spec templates as .Env and .Params.
+type specTemplateData struct {
+	Env    map[string]string
+	Params map[string]string
+}
+
+var specTemplateFuncs = template.FuncMap{
+	"env": os.Getenv,
+	// default returns the value, or the default if it's empty, e.g. env "RPC" | default "http://localhost:8545"
+	"default": func(def string, value interface{}) string {
+		if str := fmt.Sprint(value); value != nil && len(str) > 0 {
+			return str
+		}
+		return def
+	},
+	// toWei converts an amount with unit into wei, e.g. toWei "1.5 ether" or toWei "1.5" "ether"
+	"toWei": func(amount string, unit ...string) (string, error) {
+		wei, err := parseWei(strings.Join(append([]string{amount}, unit...), " "))
+		if err != nil {
+			return "", err
+		}
+		return wei.String(), nil
+	},
+	"now": func() time.Time {
+		return time.Now().UTC()
+	},
+}
+
+// RenderSpec renders the spec data as a template with access to env vars, params and helper funcs,
+// then substitutes the @params.name references. The overrides are params from the command line.
+func RenderSpec(data []byte, overrides []string) ([]byte, error) {
+	if bytes.Contains(data, []byte(specTemplateLeftDelim)) {
+		tpl, err := template.New("spec").
+			Delims(specTemplateLeftDelim, specTemplateRightDelim).
+			Funcs(specTemplateFuncs).
+			Option("missingkey=zero").
+			Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse spec template: %v", err)
+		}
+		buf := new(bytes.Buffer)
+		if err := tpl.Execute(buf, &specTemplateData{
+			Env:    environ(),
+			Params: templateParams(data, overrides),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to render spec template: %v", err)
+		}
+		data = buf.Bytes()
+	}
+	return RenderParams(data, overrides)
+}
+
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	return env
+}
+
+// templateParams makes a best effort to get the param values for the spec template, before
+// the spec is rendered the PARAMS section may not be valid YAML, then only overrides are set.
+// Invalid values are reported later, when the params are substituted.
+func templateParams(data []byte, overrides []string) map[string]string {
+	var section struct {
+		Params SpecParams `yaml:"PARAMS"`
+	}
+	_ = yaml.Unmarshal(data, &section)
+	params := make(map[string]string)
+	for name, param := range section.Params {
+		if param == nil {
+			continue
+		}
+		if value, err := param.normalize(); err == nil {
+			params[name] = value
+		}
+	}
+	for _, override := range overrides {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		params[parts[0]] = parts[1]
+		if param, ok := section.Params[parts[0]]; ok && param != nil {
+			param.Value = parts[1]
+			if value, err := param.normalize(); err == nil {
+				params[parts[0]] = value
+			}
+		}
+	}
+	return params
+}
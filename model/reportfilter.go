@@ -0,0 +1,90 @@
+package model
+
+import (
+	"errors"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ReportFilterSpec filters the dust and the spam tokens out of the balances and the history reports.
+// MinValue is the value in the fiat currency a token balance or a transfer must reach, HideZero hides
+// the tokens no wallet holds. The assets of Deny, symbols or token addresses, are always hidden, the ones
+// of Allow never are. With Spam, the tokens looking like spam are hidden: the symbols with links or
+// lures, the tokens posing as the ones of the spec and the zero transfers of address poisoning.
+type ReportFilterSpec struct {
+	MinValue float64  `yaml:"minValue"`
+	HideZero bool     `yaml:"hideZero"`
+	Allow    []string `yaml:"allow"`
+	Deny     []string `yaml:"deny"`
+	Spam     bool     `yaml:"spam"`
+
+	minValue *big.Rat `yaml:"-"`
+}
+
+var (
+	// spamSymbolRx matches the links and the lures of the airdropped scam tokens, e.g. "$ CLAIM AT ETH-GIFT.COM"
+	spamSymbolRx = regexp.MustCompile(`(?i)(https?:|www\.|t\.me/|\.(com|io|org|net|xyz|app|site|gift|club|top|vip|live|cc|me)\b|claim|reward|airdrop|visit|voucher|bonus)`)
+	// plainSymbolRx is what the symbols of the genuine tokens look like
+	plainSymbolRx = regexp.MustCompile(`^[A-Za-z0-9.\-_+$]{1,16}$`)
+)
+
+func (spec *ReportFilterSpec) validate() error {
+	if spec.MinValue < 0 {
+		return errors.New("minValue must not be negative")
+	}
+	for _, asset := range append(append([]string{}, spec.Allow...), spec.Deny...) {
+		if len(asset) == 0 {
+			return errors.New("allow and deny must list symbols or token addresses")
+		}
+	}
+	if spec.MinValue > 0 {
+		spec.minValue, _ = new(big.Rat).SetString(strconv.FormatFloat(spec.MinValue, 'f', -1, 64))
+	}
+	return nil
+}
+
+// MinFiatValue is the min value of minValue, nil if not set.
+func (spec *ReportFilterSpec) MinFiatValue() *big.Rat {
+	return spec.minValue
+}
+
+// Allowed tells if the asset of the symbol and the token address is listed in allow.
+func (spec *ReportFilterSpec) Allowed(symbol, address string) bool {
+	return assetListed(spec.Allow, symbol, address)
+}
+
+// Denied tells if the asset of the symbol and the token address is listed in deny.
+func (spec *ReportFilterSpec) Denied(symbol, address string) bool {
+	return assetListed(spec.Deny, symbol, address)
+}
+
+func assetListed(list []string, symbol, address string) bool {
+	for _, asset := range list {
+		if common.IsHexAddress(asset) {
+			if len(address) > 0 && common.HexToAddress(asset) == common.HexToAddress(address) {
+				return true
+			}
+		} else if strings.EqualFold(asset, symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+// SpamToken tells if the token of the symbol and the address looks like spam: its symbol has a link or
+// a lure, isn't the plain symbol of a token, or is the one of ether or of a token of the spec at another address.
+func (spec *Spec) SpamToken(symbol, address string) bool {
+	if spamSymbolRx.MatchString(symbol) || !plainSymbolRx.MatchString(symbol) {
+		return true
+	} else if strings.EqualFold(symbol, "ETH") {
+		return true
+	}
+	if token, ok := spec.Tokens.Find(symbol); ok && len(address) > 0 && common.IsHexAddress(token.Address) {
+		return common.HexToAddress(token.Address) != common.HexToAddress(address)
+	}
+	return false
+}
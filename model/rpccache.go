@@ -0,0 +1,242 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// finalizedCheckInterval is the interval of checking the finalized block, the calls reading
+// the blocks after it are not cached until it's checked again.
+const finalizedCheckInterval = 12 * time.Second
+
+// FinalizedDepth is the depth of the block considered final, when the node doesn't know the finalized tag.
+const FinalizedDepth = 64
+
+// rpcCache keeps the responses to the calls reading the chain state that can't change: at
+// finalized blocks, or at blocks referenced by hash. The entries are keyed by the genesis hash
+// of the chain, the method and the params, so chains and resets of dev chains don't mix up.
+type rpcCache struct {
+	db *leveldb.DB
+
+	mux       *sync.Mutex
+	genesis   string
+	finalized uint64
+	checked   time.Time
+}
+
+// openRPCCache opens the cache database next to the spec, it's nil if another run holds it.
+func openRPCCache(specDir string) *rpcCache {
+	path := cachePath(specDir, "rpc")
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		log.WithError(err).WithField("path", path).Warningln("failed to open the RPC cache, the calls are not cached")
+		return nil
+	}
+	cache := &rpcCache{
+		db:  db,
+		mux: new(sync.Mutex),
+	}
+	return cache
+}
+
+// rpcCaller calls the node bypassing the cache.
+type rpcCaller func(method string, params ...interface{}) (json.RawMessage, error)
+
+// Get returns the cached response to the call, with the id of the request.
+func (c *rpcCache) Get(call rpcCaller, body []byte) ([]byte, bool) {
+	requests, batch := rpcMessages(body)
+	if batch || len(requests) != 1 || !cacheableMethods[requests[0].Method] {
+		return nil, false
+	}
+	key, ok := c.key(call, requests[0])
+	if !ok {
+		return nil, false
+	}
+	result, err := c.db.Get([]byte(key), nil)
+	if err != nil {
+		return nil, false
+	}
+	data, err := json.Marshal(&rpcMessage{
+		Version: "2.0",
+		ID:      requests[0].ID,
+		Result:  result,
+	})
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Put caches the response to the call, if the state it has read is final.
+func (c *rpcCache) Put(call rpcCaller, body, respBody []byte) {
+	requests, batch := rpcMessages(body)
+	if batch || len(requests) != 1 || !cacheableMethods[requests[0].Method] {
+		return
+	}
+	responses, _ := rpcMessages(respBody)
+	if len(responses) != 1 || len(responses[0].Error) > 0 ||
+		len(responses[0].Result) == 0 || string(responses[0].Result) == "null" {
+		return
+	}
+	request, result := requests[0], responses[0].Result
+	if !c.isFinal(call, request, result) {
+		return
+	}
+	key, ok := c.key(call, request)
+	if !ok {
+		return
+	}
+	if err := c.db.Put([]byte(key), result, nil); err != nil {
+		log.WithError(err).Debugln("failed to cache the RPC response")
+	}
+}
+
+// cacheableMethods are the methods reading the state at a block, or a mined transaction.
+var cacheableMethods = map[string]bool{
+	"eth_call":                  true,
+	"eth_getBalance":            true,
+	"eth_getCode":               true,
+	"eth_getTransactionCount":   true,
+	"eth_getStorageAt":          true,
+	"eth_getProof":              true,
+	"eth_getBlockByNumber":      true,
+	"eth_getBlockByHash":        true,
+	"eth_getLogs":               true,
+	"eth_getTransactionByHash":  true,
+	"eth_getTransactionReceipt": true,
+}
+
+// isFinal reports whether the state read by the call can't change anymore.
+func (c *rpcCache) isFinal(call rpcCaller, request *rpcMessage, result json.RawMessage) bool {
+	var params []json.RawMessage
+	json.Unmarshal(request.Params, &params)
+	switch request.Method {
+	case "eth_getBlockByHash":
+		return true
+	case "eth_getTransactionByHash", "eth_getTransactionReceipt":
+		var tx struct {
+			BlockNumber *hexutil.Big `json:"blockNumber"`
+		}
+		if err := json.Unmarshal(result, &tx); err != nil || tx.BlockNumber == nil {
+			// pending
+			return false
+		}
+		return c.isFinalBlock(call, tx.BlockNumber.ToInt().Uint64())
+	case "eth_getLogs":
+		if len(params) == 0 {
+			return false
+		}
+		var query struct {
+			BlockHash *string `json:"blockHash"`
+			ToBlock   *string `json:"toBlock"`
+		}
+		if err := json.Unmarshal(params[0], &query); err != nil {
+			return false
+		} else if query.BlockHash != nil {
+			return true
+		} else if query.ToBlock == nil {
+			return false
+		}
+		return c.isFinalRef(call, *query.ToBlock)
+	case "eth_getBlockByNumber":
+		return len(params) > 0 && c.isFinalParam(call, params[0])
+	}
+	// the block is the last param of the state reads
+	return len(params) > 1 && c.isFinalParam(call, params[len(params)-1])
+}
+
+// isFinalParam checks the block param: a number, a hash, or an EIP-1898 object.
+func (c *rpcCache) isFinalParam(call rpcCaller, param json.RawMessage) bool {
+	var ref string
+	if err := json.Unmarshal(param, &ref); err == nil {
+		return c.isFinalRef(call, ref)
+	}
+	var obj struct {
+		BlockHash   *string `json:"blockHash"`
+		BlockNumber *string `json:"blockNumber"`
+	}
+	if err := json.Unmarshal(param, &obj); err != nil {
+		return false
+	} else if obj.BlockHash != nil {
+		return true
+	} else if obj.BlockNumber != nil {
+		return c.isFinalRef(call, *obj.BlockNumber)
+	}
+	return false
+}
+
+func (c *rpcCache) isFinalRef(call rpcCaller, ref string) bool {
+	if len(ref) == 66 && strings.HasPrefix(ref, "0x") {
+		// block hash
+		return true
+	}
+	number, err := hexutil.DecodeUint64(ref)
+	if err != nil {
+		// tags, e.g. latest
+		return false
+	}
+	return c.isFinalBlock(call, number)
+}
+
+// isFinalBlock compares the block with the finalized one, which is checked again when it's
+// not far enough, but not more often than the check interval.
+func (c *rpcCache) isFinalBlock(call rpcCaller, number uint64) bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if number <= c.finalized {
+		return true
+	} else if time.Since(c.checked) < finalizedCheckInterval {
+		return false
+	}
+	c.checked = time.Now()
+	if finalized, ok := finalizedBlock(call); ok {
+		c.finalized = finalized
+	}
+	return number <= c.finalized
+}
+
+func finalizedBlock(call rpcCaller) (uint64, bool) {
+	var header struct {
+		Number *hexutil.Big `json:"number"`
+	}
+	if result, err := call("eth_getBlockByNumber", "finalized", false); err == nil {
+		if err := json.Unmarshal(result, &header); err == nil && header.Number != nil {
+			return header.Number.ToInt().Uint64(), true
+		}
+	}
+	result, err := call("eth_blockNumber")
+	if err != nil {
+		return 0, false
+	}
+	var latest hexutil.Uint64
+	if err := json.Unmarshal(result, &latest); err != nil || latest < FinalizedDepth {
+		return 0, false
+	}
+	return uint64(latest) - FinalizedDepth, true
+}
+
+// key returns the cache key of the call, it's not known until the genesis hash is.
+func (c *rpcCache) key(call rpcCaller, request *rpcMessage) (string, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if len(c.genesis) == 0 {
+		var genesis struct {
+			Hash string `json:"hash"`
+		}
+		result, err := call("eth_getBlockByNumber", "0x0", false)
+		if err != nil {
+			return "", false
+		} else if err := json.Unmarshal(result, &genesis); err != nil || len(genesis.Hash) == 0 {
+			return "", false
+		}
+		c.genesis = genesis.Hash
+	}
+	return fmt.Sprintf("%s/%s", c.genesis, callKey(request.Method, request.Params)), true
+}
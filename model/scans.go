@@ -0,0 +1,238 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// defaultScanChunk is the number of blocks a scan processes between the saves of its cursor.
+const defaultScanChunk = 1000
+
+// Scans are the backfills of the chain history run by the scan command, keyed by name.
+type Scans map[string]*ScanSpec
+
+// ScanSpec iterates the blocks From To, default is the latest block at the start, in chunks
+// of Chunk blocks, applying the extractors and appending their rows to the Output file, JSON
+// lines or CSV by the extension. The cursor is saved after each chunk, so the scan resumes.
+type ScanSpec struct {
+	From    string           `yaml:"from"`
+	To      string           `yaml:"to"`
+	Chunk   int              `yaml:"chunk"`
+	Output  string           `yaml:"output"`
+	Extract []*ExtractorSpec `yaml:"extract"`
+
+	from *BlockRef `yaml:"-"`
+	to   *BlockRef `yaml:"-"`
+}
+
+// ExtractorSpec is exactly one of: Events, the logs of the event like the on hook of targets,
+// e.g. usdc.Transfer(to=@treasury); Traces, the internal and external calls to the account, from
+// trace_filter of the node; Balances, the ether or Token balances of the accounts every Every blocks.
+type ExtractorSpec struct {
+	Events   string   `yaml:"events"`
+	Traces   string   `yaml:"traces"`
+	Balances []string `yaml:"balances"`
+	Token    string   `yaml:"token"`
+	Every    int      `yaml:"every"`
+
+	trigger  *Trigger         `yaml:"-"`
+	traced   common.Address   `yaml:"-"`
+	accounts []common.Address `yaml:"-"`
+	token    *TokenSpec       `yaml:"-"`
+}
+
+func (scans Scans) Validate(ctx AppContext, spec *Spec) bool {
+	for name, scan := range scans {
+		validateLog := log.WithFields(log.Fields{
+			"section": "Scans",
+			"scan":    name,
+		})
+		if scan == nil {
+			validateLog.Errorln("scan has no spec")
+			return false
+		}
+		var err error
+		if len(scan.From) > 0 {
+			if scan.from, err = ParseBlockRef(scan.From); err != nil {
+				validateLog.WithError(err).Errorln("invalid block to scan from")
+				return false
+			}
+		}
+		if len(scan.To) > 0 {
+			if scan.to, err = ParseBlockRef(scan.To); err != nil {
+				validateLog.WithError(err).Errorln("invalid block to scan to")
+				return false
+			}
+		}
+		if scan.Chunk < 0 {
+			validateLog.Errorln("chunk must be a positive number of blocks")
+			return false
+		} else if scan.Chunk == 0 {
+			scan.Chunk = defaultScanChunk
+		}
+		if len(scan.Output) == 0 {
+			validateLog.Errorln("scan has no output file")
+			return false
+		} else if len(scan.Extract) == 0 {
+			validateLog.Errorln("scan has no extractors")
+			return false
+		}
+		for i, extractor := range scan.Extract {
+			if err := extractor.validate(spec); err != nil {
+				validateLog.WithField("extractor", i).WithError(err).Errorln("invalid extractor")
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (spec *ExtractorSpec) validate(root *Spec) error {
+	if spec == nil {
+		return errors.New("extractor has no spec")
+	}
+	var kinds int
+	for _, set := range []bool{len(spec.Events) > 0, len(spec.Traces) > 0, len(spec.Balances) > 0} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return errors.New("extractor must have exactly one of events, traces or balances")
+	}
+	switch {
+	case len(spec.Events) > 0:
+		trigger, err := ParseTrigger(spec.Events)
+		if err != nil {
+			return err
+		} else if err := trigger.resolve(root); err != nil {
+			return err
+		}
+		spec.trigger = trigger
+	case len(spec.Traces) > 0:
+		address, err := root.ResolveAddress(spec.Traces)
+		if err != nil {
+			return err
+		}
+		spec.traced = address
+	default:
+		for _, account := range spec.Balances {
+			address, err := root.ResolveAddress(account)
+			if err != nil {
+				return err
+			}
+			spec.accounts = append(spec.accounts, address)
+		}
+		if len(spec.Token) > 0 {
+			token, err := root.FindToken(spec.Token)
+			if err != nil {
+				return err
+			}
+			spec.token = token
+		}
+		if spec.Every < 0 {
+			return errors.New("balances must be sampled every positive number of blocks")
+		}
+	}
+	return nil
+}
+
+// BlockRange returns the references of the first and the last block of the scan, nil if not set.
+func (spec *ScanSpec) BlockRange() (from, to *BlockRef) {
+	return spec.from, spec.to
+}
+
+// Trigger returns the event of the events extractor, nil for the others.
+func (spec *ExtractorSpec) Trigger() *Trigger {
+	return spec.trigger
+}
+
+// Traced returns the account of the traces extractor, the zero address for the others.
+func (spec *ExtractorSpec) Traced() common.Address {
+	return spec.traced
+}
+
+// Accounts returns the accounts of the balances extractor.
+func (spec *ExtractorSpec) Accounts() []common.Address {
+	return spec.accounts
+}
+
+// BalanceToken returns the token of the balances extractor, nil for ether.
+func (spec *ExtractorSpec) BalanceToken() *TokenSpec {
+	return spec.token
+}
+
+// ScanCursor is the progress of the scan, stored as runs/scan-<name>.json next to the spec file:
+// Next is the first block not scanned yet, To is the last block of the scan, fixed at the start.
+type ScanCursor struct {
+	Scan      string    `json:"scan"`
+	NodeGroup string    `json:"nodeGroup"`
+	Next      uint64    `json:"next"`
+	To        uint64    `json:"to"`
+	Rows      int       `json:"rows"`
+	Updated   time.Time `json:"updated"`
+
+	specDir string
+}
+
+func NewScanCursor(specDir, scan, nodeGroup string, from, to uint64) *ScanCursor {
+	return &ScanCursor{
+		Scan:      scan,
+		NodeGroup: nodeGroup,
+		Next:      from,
+		To:        to,
+
+		specDir: specDir,
+	}
+}
+
+// LoadScanCursor loads the cursor of the scan, it's nil if the scan has not been started.
+func LoadScanCursor(specDir, scan string) (*ScanCursor, error) {
+	data, err := ioutil.ReadFile(runJournalPath(specDir, scanCursorID(scan)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	cursor := &ScanCursor{
+		specDir: specDir,
+	}
+	if err := json.Unmarshal(data, cursor); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+func scanCursorID(scan string) string {
+	return "scan-" + scan
+}
+
+// Done reports whether all blocks of the scan have been scanned.
+func (c *ScanCursor) Done() bool {
+	return c.Next > c.To
+}
+
+// Save stores the cursor, once the rows of the scanned blocks are written.
+func (c *ScanCursor) Save() error {
+	if err := os.MkdirAll(filepath.Join(c.specDir, runsDir), 0755); err != nil {
+		return err
+	}
+	c.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(runJournalPath(c.specDir, scanCursorID(c.Scan)), data, 0644)
+}
+
+// Remove deletes the cursor, so the scan starts over.
+func (c *ScanCursor) Remove() error {
+	return os.Remove(runJournalPath(c.specDir, scanCursorID(c.Scan)))
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/AtlantPlatform/yaml"
+)
+
+// JSONSchema generates the JSON Schema of the spec format from the model structs,
+// so editors can validate and complete the playbook files.
+func JSONSchema() map[string]interface{} {
+	definitions := make(map[string]interface{})
+	schemaOf(reflect.TypeOf(Spec{}), definitions)
+	root := definitions["Spec"].(map[string]interface{})
+	delete(definitions, "Spec")
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = "ethereum-playbook spec"
+	root["definitions"] = definitions
+	return root
+}
+
+func schemaOf(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), definitions)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaOf(t.Elem(), definitions),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaOf(t.Elem(), definitions),
+		}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := definitions[name]; !ok {
+			// the placeholder breaks recursion of self-referencing types
+			definitions[name] = nil
+			properties := make(map[string]interface{})
+			structProperties(t, properties, definitions)
+			definitions[name] = map[string]interface{}{
+				"type":                 "object",
+				"properties":           properties,
+				"additionalProperties": false,
+			}
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + name}
+	}
+	// interface{} values, e.g. params, accept anything
+	return map[string]interface{}{}
+}
+
+func structProperties(t reflect.Type, properties, definitions map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")
+		if tag[0] == "-" {
+			continue
+		} else if len(tag) > 1 && tag[1] == "inline" {
+			structProperties(field.Type, properties, definitions)
+			continue
+		}
+		name := tag[0]
+		if len(name) == 0 {
+			name = strings.ToLower(field.Name)
+		}
+		properties[name] = schemaOf(field.Type, definitions)
+	}
+}
+
+// SpecError is a problem found in the spec file at the position.
+type SpecError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+var (
+	specErrorLineRx    = regexp.MustCompile(`^line (\d+): (.*)$`)
+	unknownFieldRx     = regexp.MustCompile(`^field (\S+) not found in type (\S+)$`)
+	unmarshalValueRx   = regexp.MustCompile("^cannot unmarshal !!\\w+ `([^`]*)` into (\\S+)$")
+	truncatedValueTail = "..."
+)
+
+// CheckStrict parses the spec data rejecting unknown keys and values of wrong types, which are
+// silently ignored otherwise, the errors have positions of the offending keys or values.
+func CheckStrict(data []byte) []*SpecError {
+	var spec *Spec
+	err := yaml.UnmarshalStrict(data, &spec)
+	if err == nil {
+		return nil
+	}
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		// syntax errors have the line in the message already
+		return []*SpecError{{Message: err.Error()}}
+	}
+	lines := strings.Split(string(data), "\n")
+	specErrors := make([]*SpecError, 0, len(typeErr.Errors))
+	for _, msg := range typeErr.Errors {
+		specErr := &SpecError{Message: msg}
+		m := specErrorLineRx.FindStringSubmatch(msg)
+		if m == nil {
+			specErrors = append(specErrors, specErr)
+			continue
+		}
+		specErr.Line, _ = strconv.Atoi(m[1])
+		specErr.Message = m[2]
+		var needle string
+		if m := unknownFieldRx.FindStringSubmatch(specErr.Message); m != nil {
+			typeName := m[2][strings.LastIndex(m[2], ".")+1:]
+			specErr.Message = fmt.Sprintf("unknown field %s in %s", m[1], typeName)
+			needle = m[1]
+		} else if m := unmarshalValueRx.FindStringSubmatch(specErr.Message); m != nil {
+			needle = strings.TrimSuffix(m[1], truncatedValueTail)
+		}
+		if specErr.Line > 0 && specErr.Line <= len(lines) {
+			specErr.Column = strings.Index(lines[specErr.Line-1], needle) + 1
+		}
+		specErrors = append(specErrors, specErr)
+	}
+	return specErrors
+}
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const screeningAPIKeyEnv = "SCREENING_API_KEY"
+
+// Screener checks the counterparties of the transfers against the denylist file of screeningList
+// and the screening API of screeningURL, e.g. the sanctioned-address lists.
+type Screener struct {
+	denylist map[common.Address]string
+	url      string
+	apiKey   string
+
+	results    map[common.Address]string
+	resultsMux *sync.Mutex
+}
+
+// screeningResponse is the verdict of the screening API, either the blocked flag with the reason,
+// or the identifications of the address, like in the Chainalysis sanctions API.
+type screeningResponse struct {
+	Blocked         bool   `json:"blocked"`
+	Reason          string `json:"reason"`
+	Identifications []struct {
+		Category string `json:"category"`
+		Name     string `json:"name"`
+	} `json:"identifications"`
+}
+
+// Screener returns the screener of the config, nil if neither the denylist nor the API is set.
+func (spec *ConfigSpec) Screener() (*Screener, error) {
+	if len(spec.ScreeningList) == 0 && len(spec.ScreeningURL) == 0 {
+		return nil, nil
+	}
+	screener := &Screener{
+		denylist:   make(map[common.Address]string),
+		url:        spec.ScreeningURL,
+		apiKey:     spec.ScreeningAPIKey,
+		results:    make(map[common.Address]string),
+		resultsMux: new(sync.Mutex),
+	}
+	if len(screener.apiKey) == 0 {
+		screener.apiKey = os.Getenv(screeningAPIKeyEnv)
+	}
+	if len(spec.ScreeningList) > 0 {
+		path := spec.ScreeningList
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(spec.SpecDir, path)
+		}
+		if err := screener.loadDenylist(path); err != nil {
+			return nil, fmt.Errorf("failed to load screening list: %v", err)
+		}
+	}
+	return screener, nil
+}
+
+// loadDenylist reads the addresses of the file, one per line, optionally followed by the reason
+// after a comma or a space. Empty lines and # comments are skipped.
+func (s *Screener) loadDenylist(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		address, reason := text, "listed in the screening list"
+		if i := strings.IndexAny(text, ", \t"); i > 0 {
+			address = text[:i]
+			if r := strings.TrimSpace(strings.Trim(text[i:], ", \t")); len(r) > 0 {
+				reason = r
+			}
+		}
+		if !common.IsHexAddress(address) {
+			return fmt.Errorf("%s:%d: %s is not an address", path, line, address)
+		}
+		s.denylist[common.HexToAddress(address)] = reason
+	}
+	return scanner.Err()
+}
+
+// Screen returns the reason the address is flagged, empty if it's clear. The API verdicts are
+// kept for the run, an API failure is an error, so the transfer is not sent unscreened.
+func (s *Screener) Screen(ctx context.Context, address common.Address) (string, error) {
+	if reason, ok := s.denylist[address]; ok {
+		return reason, nil
+	} else if len(s.url) == 0 {
+		return "", nil
+	}
+	s.resultsMux.Lock()
+	reason, ok := s.results[address]
+	s.resultsMux.Unlock()
+	if ok {
+		return reason, nil
+	}
+	reason, err := s.query(ctx, address)
+	if err != nil {
+		return "", err
+	}
+	s.resultsMux.Lock()
+	s.results[address] = reason
+	s.resultsMux.Unlock()
+	return reason, nil
+}
+
+// query asks the API about the address, substituted for {address} in the URL, or appended to it.
+func (s *Screener) query(ctx context.Context, address common.Address) (string, error) {
+	url := s.url
+	if strings.Contains(url, "{address}") {
+		url = strings.Replace(url, "{address}", address.Hex(), -1)
+	} else {
+		url = strings.TrimSuffix(url, "/") + "/" + address.Hex()
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if len(s.apiKey) > 0 {
+		req.Header.Set("X-API-Key", s.apiKey)
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	} else if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("screening API responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var verdict screeningResponse
+	if err := json.Unmarshal(body, &verdict); err != nil {
+		return "", fmt.Errorf("malformed screening API response: %v", err)
+	}
+	if len(verdict.Identifications) > 0 {
+		var reasons []string
+		for _, id := range verdict.Identifications {
+			reasons = append(reasons, strings.TrimSpace(id.Category+" "+id.Name))
+		}
+		return strings.Join(reasons, ", "), nil
+	} else if verdict.Blocked {
+		if len(verdict.Reason) == 0 {
+			return "flagged by the screening API", nil
+		}
+		return verdict.Reason, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,183 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// abiFunction is a function entry of the raw ABI, the ABI parser of go-ethereum
+// keeps only one of overloaded functions, so those are parsed separately.
+type abiFunction struct {
+	Name     string
+	Sig      string
+	Selector string
+}
+
+func parseABIFunctions(abiJSON []byte) ([]*abiFunction, error) {
+	var fields []struct {
+		Type   string
+		Name   string
+		Inputs []abiParam
+	}
+	if err := json.Unmarshal(abiJSON, &fields); err != nil {
+		return nil, err
+	}
+	var functions []*abiFunction
+	for _, field := range fields {
+		if field.Type != "function" && field.Type != "" {
+			continue
+		}
+		types := make([]string, len(field.Inputs))
+		for i, input := range field.Inputs {
+			types[i] = input.Type
+			if typ, err := argTypeOf(input); err == nil && typ.kind != argElem {
+				types[i] = typ.canonical()
+			}
+		}
+		sig := fmt.Sprintf("%s(%s)", field.Name, strings.Join(types, ","))
+		functions = append(functions, &abiFunction{
+			Name:     field.Name,
+			Sig:      sig,
+			Selector: hexutil.Encode(crypto.Keccak256([]byte(sig))[:4]),
+		})
+	}
+	return functions, nil
+}
+
+// checkSelectors reports functions with colliding selectors, either within a single ABI,
+// or across contract specs whose instances share the same address, like a proxy and its implementation.
+// A function declared by more than one of those contracts is a conflict as well, as it shadows the others.
+func (contracts Contracts) checkSelectors() bool {
+	validateLog := log.WithFields(log.Fields{
+		"section": "Contracts",
+	})
+	byAddress := make(map[string][]string)
+	for name, contract := range contracts {
+		seen := make(map[string]string)
+		for _, fn := range contract.functions {
+			if sig, ok := seen[fn.Selector]; ok && sig != fn.Sig {
+				validateLog.WithFields(log.Fields{
+					"contract":   name,
+					"selector":   fn.Selector,
+					"signatures": sig + ", " + fn.Sig,
+				}).Errorln("function selector collision in contract ABI")
+				return false
+			}
+			seen[fn.Selector] = fn.Sig
+		}
+		for _, instance := range contract.Instances {
+			if !instance.IsDeployed() {
+				continue
+			}
+			address := strings.ToLower(instance.Address)
+			if !containsString(byAddress[address], name) {
+				byAddress[address] = append(byAddress[address], name)
+			}
+		}
+	}
+	for address, names := range byAddress {
+		if len(names) < 2 {
+			continue
+		}
+		sort.Strings(names)
+		seen := make(map[string]string)
+		owners := make(map[string]string)
+		for _, name := range names {
+			for _, fn := range contracts[name].functions {
+				sig, ok := seen[fn.Selector]
+				if !ok {
+					seen[fn.Selector] = fn.Sig
+					owners[fn.Selector] = name
+					continue
+				}
+				collisionLog := validateLog.WithFields(log.Fields{
+					"address":  address,
+					"selector": fn.Selector,
+					"signatures": fmt.Sprintf("%s in %s, %s in %s",
+						sig, owners[fn.Selector], fn.Sig, name),
+				})
+				if sig != fn.Sig {
+					collisionLog.Errorln("function selector collision between contracts at the same address")
+				} else {
+					collisionLog.Errorln("function is shadowed by another contract at the same address")
+				}
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// resolveMethod resolves a method specified by 4-byte selector into its name, and ensures that
+// the name is not ambiguous, since only one of overloaded functions can be called.
+func (contracts Contracts) resolveMethod(instance *ContractInstanceSpec, method string) (string, error) {
+	contract, ok := contracts[instance.Name]
+	if !ok {
+		return method, nil
+	}
+	var selector string
+	if isSelector(method) {
+		selector = strings.ToLower(method)
+		var found *abiFunction
+		for _, fn := range contract.functions {
+			if fn.Selector == selector {
+				found = fn
+				break
+			}
+		}
+		if found == nil {
+			err := fmt.Errorf("no function with selector %s in contract ABI", method)
+			return "", err
+		}
+		method = found.Name
+	}
+	var overloads []string
+	for _, fn := range contract.functions {
+		if fn.Name == method {
+			overloads = append(overloads, fn.Sig)
+		}
+	}
+	if len(overloads) > 1 {
+		if kept, ok := contract.abi.Methods[method]; ok {
+			keptID := kept.Id()
+			if tuple, ok := contract.tuples[method]; ok {
+				keptID = tuple.id
+			}
+			if hexutil.Encode(keptID) == selector {
+				// the selector points to the overload kept by the ABI encoder
+				return method, nil
+			}
+		}
+		err := fmt.Errorf("ambiguous method name %s, overloads: %s", method, strings.Join(overloads, ", "))
+		return "", err
+	}
+	if _, ok := contract.abi.Methods[method]; !ok {
+		if err := contracts.unsupportedEntry(instance.Name, method); err != nil {
+			return "", err
+		}
+	}
+	return method, nil
+}
+
+func isSelector(method string) bool {
+	if len(method) != 10 || !strings.HasPrefix(method, "0x") {
+		return false
+	}
+	_, err := hexutil.Decode(method)
+	return err == nil
+}
+
+func containsString(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
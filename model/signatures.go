@@ -0,0 +1,131 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type SignatureKind string
+
+const (
+	SignatureFunction SignatureKind = "function"
+	SignatureEvent    SignatureKind = "event"
+)
+
+const (
+	openchainURL = "https://api.openchain.xyz/signature-database/v1/lookup"
+	fourByteURL  = "https://www.4byte.directory/api/v1"
+)
+
+var (
+	signatureMisses    = make(map[string]struct{})
+	signatureMissesMux = new(sync.Mutex)
+)
+
+// LookupSignature resolves a 4-byte function selector or a 32-byte event topic into
+// its text signature, such as transfer(address,uint256), using the openchain database
+// with a fallback to 4byte.directory. Enabled by signatureLookup in config, found
+// signatures are cached on disk, so each one is queried only once.
+func LookupSignature(ctx context.Context, config *ConfigSpec, kind SignatureKind, hash string) (string, bool) {
+	if !config.SignatureLookup {
+		return "", false
+	}
+	hash = strings.ToLower(hash)
+	path := cachePath(config.SpecDir, "signatures", string(kind), hash)
+	if data, ok := readCache(path); ok {
+		return string(data), true
+	}
+	signatureMissesMux.Lock()
+	_, missed := signatureMisses[path]
+	signatureMissesMux.Unlock()
+	if missed {
+		return "", false
+	}
+	signature, err := lookupOpenchain(ctx, kind, hash)
+	if err != nil || len(signature) == 0 {
+		signature, err = lookup4Byte(ctx, kind, hash)
+	}
+	if err != nil || len(signature) == 0 {
+		signatureMissesMux.Lock()
+		signatureMisses[path] = struct{}{}
+		signatureMissesMux.Unlock()
+		return "", false
+	}
+	_ = writeCache(path, []byte(signature))
+	return signature, true
+}
+
+func lookupOpenchain(ctx context.Context, kind SignatureKind, hash string) (string, error) {
+	query := url.Values{}
+	query.Set(string(kind), hash)
+	query.Set("filter", "true")
+	var result struct {
+		Ok     bool `json:"ok"`
+		Result map[string]map[string][]struct {
+			Name string `json:"name"`
+		} `json:"result"`
+	}
+	if err := getJSON(ctx, openchainURL+"?"+query.Encode(), &result); err != nil {
+		return "", err
+	} else if !result.Ok {
+		return "", fmt.Errorf("openchain lookup failed for %s", hash)
+	}
+	matches := result.Result[string(kind)][hash]
+	if len(matches) == 0 {
+		return "", nil
+	}
+	return matches[0].Name, nil
+}
+
+func lookup4Byte(ctx context.Context, kind SignatureKind, hash string) (string, error) {
+	endpoint := "/signatures/"
+	if kind == SignatureEvent {
+		endpoint = "/event-signatures/"
+	}
+	query := url.Values{}
+	query.Set("hex_signature", hash)
+	var result struct {
+		Results []struct {
+			ID            int    `json:"id"`
+			TextSignature string `json:"text_signature"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, fourByteURL+endpoint+"?"+query.Encode(), &result); err != nil {
+		return "", err
+	}
+	if len(result.Results) == 0 {
+		return "", nil
+	}
+	// the earliest submission is the most likely one, others are often collisions
+	sort.Slice(result.Results, func(i, j int) bool {
+		return result.Results[i].ID < result.Results[j].ID
+	})
+	return result.Results[0].TextSignature, nil
+}
+
+func getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	} else if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("%s responded with status %s", req.URL.Host, resp.Status)
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SnapshotCheckpoint is the state of the holders of the asset reconstructed from the transfer logs
+// of the blocks before Next, stored as runs/snapshot-<asset>.json next to the spec file, so the later
+// snapshots apply the new blocks only. Balances are the units of the ERC-20 holders, Owners are
+// the owners of the ERC-721 tokens by ID.
+type SnapshotCheckpoint struct {
+	Asset     string              `json:"asset"`
+	Address   string              `json:"address"`
+	NodeGroup string              `json:"nodeGroup"`
+	Next      uint64              `json:"next"`
+	Balances  map[string]*big.Int `json:"balances,omitempty"`
+	Owners    map[string]string   `json:"owners,omitempty"`
+	Updated   time.Time           `json:"updated"`
+
+	specDir string
+}
+
+func NewSnapshotCheckpoint(specDir, asset, address, nodeGroup string, from uint64) *SnapshotCheckpoint {
+	return &SnapshotCheckpoint{
+		Asset:     asset,
+		Address:   strings.ToLower(address),
+		NodeGroup: nodeGroup,
+		Next:      from,
+		Balances:  make(map[string]*big.Int),
+		Owners:    make(map[string]string),
+
+		specDir: specDir,
+	}
+}
+
+// LoadSnapshotCheckpoint loads the checkpoint of the asset, it's nil if no snapshot has been taken.
+func LoadSnapshotCheckpoint(specDir, asset string) (*SnapshotCheckpoint, error) {
+	data, err := ioutil.ReadFile(runJournalPath(specDir, snapshotCheckpointID(asset)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	checkpoint := &SnapshotCheckpoint{
+		specDir: specDir,
+	}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	if checkpoint.Balances == nil {
+		checkpoint.Balances = make(map[string]*big.Int)
+	}
+	if checkpoint.Owners == nil {
+		checkpoint.Owners = make(map[string]string)
+	}
+	return checkpoint, nil
+}
+
+func snapshotCheckpointID(asset string) string {
+	return "snapshot-" + strings.ToLower(asset)
+}
+
+// Copy returns the copy of the checkpoint state, to apply the blocks that are not checkpointed.
+func (c *SnapshotCheckpoint) Copy() *SnapshotCheckpoint {
+	copied := *c
+	copied.Balances = make(map[string]*big.Int, len(c.Balances))
+	for holder, balance := range c.Balances {
+		copied.Balances[holder] = new(big.Int).Set(balance)
+	}
+	copied.Owners = make(map[string]string, len(c.Owners))
+	for tokenID, owner := range c.Owners {
+		copied.Owners[tokenID] = owner
+	}
+	return &copied
+}
+
+// Save stores the checkpoint, once the transfers of the blocks before Next are applied.
+func (c *SnapshotCheckpoint) Save() error {
+	if err := os.MkdirAll(filepath.Join(c.specDir, runsDir), 0755); err != nil {
+		return err
+	}
+	c.Updated = time.Now().UTC()
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(runJournalPath(c.specDir, snapshotCheckpointID(c.Asset)), data, 0644)
+}
+
+// Remove deletes the checkpoint, so the holders are reconstructed from the first block.
+func (c *SnapshotCheckpoint) Remove() error {
+	return os.Remove(runJournalPath(c.specDir, snapshotCheckpointID(c.Asset)))
+}
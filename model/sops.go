@@ -0,0 +1,106 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/AtlantPlatform/yaml"
+)
+
+// sopsBinEnv overrides the path of the sops binary, which is looked up in PATH by default.
+const sopsBinEnv = "SOPS_BIN"
+
+// IsSopsEncrypted checks whether the YAML data is a file encrypted by sops, such files have
+// the sops metadata key, values may be encrypted partially with encrypted_regex or suffix.
+func IsSopsEncrypted(data []byte) bool {
+	var doc struct {
+		Sops map[string]interface{} `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	return doc.Sops != nil
+}
+
+// DecryptSops decrypts the sops-encrypted YAML file using the PGP, age or KMS keys
+// of the operator, the decryption is done by the sops binary.
+func DecryptSops(path string) ([]byte, error) {
+	bin := os.Getenv(sopsBinEnv)
+	if len(bin) == 0 {
+		bin = "sops"
+	}
+	cmd := exec.Command(bin, "--decrypt", "--input-type", "yaml", "--output-type", "yaml", path)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("sops: %v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("sops: %v", err)
+	}
+	return out, nil
+}
+
+// ReadSpecFile reads the spec file, decrypting it if it has been encrypted by sops.
+func ReadSpecFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if IsSopsEncrypted(data) {
+		return DecryptSops(path)
+	}
+	return data, nil
+}
+
+// ResolveSecrets loads the sops-encrypted files listed in SECRETS, which keep the sensitive parts
+// of the spec, like wallet passwords or API keys, so the playbook itself can be stored in git as is.
+// Wallets of secrets are merged field by field with wallets of the same name, config values
+// override the config of the spec.
+func (spec *Spec) ResolveSecrets(specDir string) error {
+	for _, file := range spec.Secrets {
+		path := filepath.FromSlash(file)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(specDir, path)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("secrets %s: %v", file, err)
+		} else if !IsSopsEncrypted(data) {
+			return fmt.Errorf("secrets %s: file is not encrypted by sops", file)
+		}
+		if data, err = DecryptSops(path); err != nil {
+			return fmt.Errorf("secrets %s: %v", file, err)
+		}
+		var secrets *Spec
+		if err := yaml.Unmarshal(data, &secrets); err != nil {
+			return fmt.Errorf("secrets %s: failed to parse YAML: %v", file, err)
+		} else if secrets == nil {
+			continue
+		}
+		if secrets.Config != nil {
+			config := *DefaultConfigSpec
+			if spec.Config != nil {
+				config = *spec.Config
+			}
+			overlayFields(&config, secrets.Config)
+			spec.Config = &config
+		}
+		if len(secrets.Wallets) > 0 && spec.Wallets == nil {
+			spec.Wallets = make(Wallets)
+		}
+		for name, wallet := range secrets.Wallets {
+			if wallet == nil {
+				continue
+			} else if existing, ok := spec.Wallets[name]; ok && existing != nil {
+				overlayFields(existing, wallet)
+				continue
+			}
+			spec.Wallets[name] = wallet
+		}
+	}
+	return nil
+}
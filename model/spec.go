@@ -1,19 +1,61 @@
 package model
 
-import log "github.com/Sirupsen/logrus"
+import (
+	"context"
+	"errors"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+)
 
 type Spec struct {
-	Config    *ConfigSpec `yaml:"CONFIG"`
-	Inventory Inventory   `yaml:"INVENTORY"`
-	Wallets   Wallets     `yaml:"WALLETS"`
-	Contracts Contracts   `yaml:"CONTRACTS"`
-	Targets   Targets     `yaml:"TARGETS"`
+	// Version of the spec format, see migrate command.
+	Version int `yaml:"version"`
+
+	Config      *ConfigSpec      `yaml:"CONFIG"`
+	Inventory   Inventory        `yaml:"INVENTORY"`
+	Wallets     Wallets          `yaml:"WALLETS"`
+	Tokens      Tokens           `yaml:"TOKENS"`
+	TokenLists  []*TokenListSpec `yaml:"TOKENLISTS"`
+	AddressBook AddressBook      `yaml:"ADDRESSBOOK"`
+	NFTs        NFTs             `yaml:"NFTS"`
+	Contracts   Contracts        `yaml:"CONTRACTS"`
+	Targets     Targets          `yaml:"TARGETS"`
+	Hooks       Hooks            `yaml:"HOOKS"`
+	Scans       Scans            `yaml:"SCANS"`
+	Monitors    Monitors         `yaml:"MONITORS"`
+	Funding     Funding          `yaml:"FUNDING"`
+	Vesting     Vesting          `yaml:"VESTING"`
+	Staking     Staking          `yaml:"STAKING"`
+	Templates   Templates        `yaml:"TEMPLATES"`
+	Imports     []*ImportSpec    `yaml:"IMPORTS"`
+	Params      SpecParams       `yaml:"PARAMS"`
+	Networks    Networks         `yaml:"NETWORKS"`
+	Secrets     []string         `yaml:"SECRETS"`
+	Lint        *LintSpec        `yaml:"LINT"`
 
 	ViewCmds  ViewCmds  `yaml:"VIEW"`
 	WriteCmds WriteCmds `yaml:"WRITE"`
 	CallCmds  CallCmds  `yaml:"CALL"`
 
-	uniqueNames map[string]struct{} `yaml:"-"`
+	uniqueNames      map[string]struct{}       `yaml:"-"`
+	nodesUnavailable bool                      `yaml:"-"`
+	nodes            map[string]*NodeSpec      `yaml:"-"`
+	cassette         *Cassette                 `yaml:"-"`
+	rpcCache         *rpcCache                 `yaml:"-"`
+	ens              *ensResolver              `yaml:"-"`
+	labels           map[common.Address]string `yaml:"-"`
+}
+
+// UseCassette makes the nodes record the calls to the cassette, or replaces them with the cassette being replayed.
+func (spec *Spec) UseCassette(cassette *Cassette) {
+	spec.cassette = cassette
+}
+
+// NodesUnavailable reports whether the validation has failed because no node of the group is live.
+func (spec *Spec) NodesUnavailable() bool {
+	return spec.nodesUnavailable
 }
 
 func (spec *Spec) Validate(ctx AppContext) bool {
@@ -36,8 +78,32 @@ func (spec *Spec) Validate(ctx AppContext) bool {
 			return false
 		}
 	}
-	if spec.ViewCmds == nil && spec.WriteCmds == nil && spec.CallCmds == nil {
-		validateLog.Errorln("spec must contain at least one of VIEW, WRITE or CALL sections")
+	spec.ens = &ensResolver{
+		ctx:       ctx,
+		spec:      spec,
+		addresses: make(map[string]common.Address),
+		names:     make(map[common.Address]string),
+	}
+	if len(spec.TokenLists) > 0 {
+		if !spec.importTokenLists(ctx) {
+			validateLog.Errorln("token lists import failed")
+			return false
+		}
+	}
+	if spec.Tokens != nil {
+		if !spec.Tokens.Validate(ctx, spec) {
+			validateLog.Errorln("tokens spec validation failed")
+			return false
+		}
+	}
+	if spec.NFTs != nil {
+		if !spec.NFTs.Validate(ctx, spec) {
+			validateLog.Errorln("nfts spec validation failed")
+			return false
+		}
+	}
+	if spec.ViewCmds == nil && spec.WriteCmds == nil && spec.CallCmds == nil && spec.Templates == nil {
+		validateLog.Errorln("spec must contain at least one of VIEW, WRITE, CALL or TEMPLATES sections")
 		return false
 	}
 	if spec.Wallets != nil {
@@ -49,6 +115,12 @@ func (spec *Spec) Validate(ctx AppContext) bool {
 		validateLog.Errorln("spec must contain the WALLET section, if WRITE or CALL sections are provided")
 		return false
 	}
+	if spec.AddressBook != nil {
+		if !spec.AddressBook.Validate(ctx, spec) {
+			validateLog.Errorln("address book spec validation failed")
+			return false
+		}
+	}
 	if spec.Contracts != nil {
 		if !spec.Contracts.Validate(ctx, spec) {
 			validateLog.Errorln("contracts spec validation failed")
@@ -74,15 +146,85 @@ func (spec *Spec) Validate(ctx AppContext) bool {
 			return false
 		}
 	}
+	if spec.Templates != nil {
+		if !spec.Templates.Validate(ctx, spec) {
+			validateLog.Errorln("templates spec validation failed")
+			return false
+		}
+	}
 	if spec.Targets != nil {
 		if !spec.Targets.Validate(ctx, spec) {
 			validateLog.Errorln("targets spec validation failed")
 			return false
 		}
 	}
+	if spec.Hooks != nil {
+		if !spec.Hooks.Validate(ctx, spec) {
+			validateLog.Errorln("hooks spec validation failed")
+			return false
+		}
+	}
+	if spec.Scans != nil {
+		if !spec.Scans.Validate(ctx, spec) {
+			validateLog.Errorln("scans spec validation failed")
+			return false
+		}
+	}
+	if spec.Monitors != nil {
+		if !spec.Monitors.Validate(ctx, spec) {
+			validateLog.Errorln("monitors spec validation failed")
+			return false
+		}
+	}
+	if spec.Funding != nil {
+		if !spec.Funding.Validate(ctx, spec) {
+			validateLog.Errorln("funding spec validation failed")
+			return false
+		}
+	}
+	if spec.Vesting != nil {
+		if !spec.Vesting.Validate(ctx, spec) {
+			validateLog.Errorln("vesting spec validation failed")
+			return false
+		}
+	}
+	if spec.Staking != nil {
+		if !spec.Staking.Validate(ctx, spec) {
+			validateLog.Errorln("staking spec validation failed")
+			return false
+		}
+	}
 	return true
 }
 
+// validateCommand validates the command of any section by name.
+func (spec *Spec) validateCommand(ctx AppContext, name string) bool {
+	if cmd, ok := spec.CallCmds[name]; ok {
+		return cmd.Validate(ctx, name, spec)
+	} else if cmd, ok := spec.ViewCmds[name]; ok {
+		return cmd.Validate(ctx, name, spec)
+	} else if cmd, ok := spec.WriteCmds[name]; ok {
+		return cmd.Validate(ctx, name, spec)
+	}
+	return false
+}
+
+// TargetArgCount returns the number of CLI args used by the target commands, their compensating
+// commands and the target hooks.
+func (spec *Spec) TargetArgCount(name string) int {
+	set := make(map[int]struct{})
+	spec.Targets[name].CountArgsUsing(set, spec)
+	for _, cmdName := range spec.Targets[name].CmdNames() {
+		if onFailure := spec.CommandOnFailure(cmdName); len(onFailure) > 0 {
+			spec.CountArgsUsing(set, onFailure)
+		}
+	}
+	if hooks := spec.Hooks[name]; hooks != nil {
+		hooks.CountArgsUsing(set, spec)
+	}
+	return len(set)
+}
+
 func (spec *Spec) CountArgsUsing(set map[int]struct{}, name string) {
 	if cmd, ok := spec.CallCmds[name]; ok {
 		cmd.CountArgsUsing(set)
@@ -90,6 +232,16 @@ func (spec *Spec) CountArgsUsing(set map[int]struct{}, name string) {
 		cmd.CountArgsUsing(set)
 	} else if cmd, ok := spec.WriteCmds[name]; ok {
 		cmd.CountArgsUsing(set)
+	} else if cmd, _, err := spec.instantiateTemplate(name); err == nil {
+		// template instances are registered as commands only during validation
+		switch c := cmd.(type) {
+		case *CallCmdSpec:
+			c.CountArgsUsing(set)
+		case *ViewCmdSpec:
+			c.CountArgsUsing(set)
+		case *WriteCmdSpec:
+			c.CountArgsUsing(set)
+		}
 	}
 }
 
@@ -104,4 +256,101 @@ func (spec *Spec) ArgCount(name string) int {
 	return 0
 }
 
+func (spec *Spec) HasCommand(name string) bool {
+	if _, ok := spec.CallCmds[name]; ok {
+		return true
+	} else if _, ok := spec.ViewCmds[name]; ok {
+		return true
+	} else if _, ok := spec.WriteCmds[name]; ok {
+		return true
+	}
+	return false
+}
+
+// CommandNeeds returns the names of commands the command depends on.
+func (spec *Spec) CommandNeeds(name string) []string {
+	if cmd, ok := spec.CallCmds[name]; ok {
+		return cmd.Needs
+	} else if cmd, ok := spec.ViewCmds[name]; ok {
+		return cmd.Needs
+	} else if cmd, ok := spec.WriteCmds[name]; ok {
+		return cmd.Needs
+	}
+	return nil
+}
+
+// CommandOnFailure returns the name of the compensating command of the command, if it has one.
+func (spec *Spec) CommandOnFailure(name string) string {
+	if cmd, ok := spec.CallCmds[name]; ok {
+		return cmd.OnFailure
+	} else if cmd, ok := spec.ViewCmds[name]; ok {
+		return cmd.OnFailure
+	} else if cmd, ok := spec.WriteCmds[name]; ok {
+		return cmd.OnFailure
+	}
+	return ""
+}
+
+// CommandConfirm reports whether the command requires an approval before it's run.
+func (spec *Spec) CommandConfirm(name string) bool {
+	if cmd, ok := spec.CallCmds[name]; ok {
+		return cmd.Confirm
+	} else if cmd, ok := spec.WriteCmds[name]; ok {
+		return cmd.Confirm
+	}
+	return false
+}
+
+// CommandDescription returns the description of the command.
+func (spec *Spec) CommandDescription(name string) string {
+	if cmd, ok := spec.CallCmds[name]; ok {
+		return cmd.Description
+	} else if cmd, ok := spec.ViewCmds[name]; ok {
+		return cmd.Description
+	} else if cmd, ok := spec.WriteCmds[name]; ok {
+		return cmd.Description
+	}
+	return ""
+}
+
+// CommandCondition returns the when condition of the command, if it has one.
+func (spec *Spec) CommandCondition(name string) *Expression {
+	if cmd, ok := spec.CallCmds[name]; ok {
+		return cmd.Condition()
+	} else if cmd, ok := spec.ViewCmds[name]; ok {
+		return cmd.Condition()
+	} else if cmd, ok := spec.WriteCmds[name]; ok {
+		return cmd.Condition()
+	}
+	return nil
+}
+
+// TargetCondition returns the when condition of the target, if it has one.
+func (spec *Spec) TargetCondition(name string) *Expression {
+	if hooks := spec.Hooks[name]; hooks != nil {
+		return hooks.Condition()
+	}
+	return nil
+}
+
+// ResolveAddress returns the address of a wallet, an address book label, the first instance of a contract,
+// the hex address itself or the address of the ENS name, the name may be prefixed with @ like wallet references.
+func (spec *Spec) ResolveAddress(name string) (common.Address, error) {
+	if common.IsHexAddress(name) {
+		return common.HexToAddress(name), nil
+	} else if isENSName(name) {
+		return spec.ResolveENS(context.Background(), name)
+	}
+	name = strings.TrimPrefix(name, "@")
+	if wallet, ok := spec.Wallets.WalletSpec(name); ok {
+		return common.HexToAddress(wallet.Address), nil
+	} else if address, ok := spec.AddressBook.Address(name); ok {
+		return address, nil
+	}
+	if contract, ok := spec.Contracts.ContractSpec(name); ok && len(contract.Instances) > 0 {
+		return common.HexToAddress(contract.Instances[0].Address), nil
+	}
+	return common.Address{}, errors.New("not a hex address, ENS name, wallet, label or contract name")
+}
+
 type FieldName string
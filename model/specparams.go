@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/yaml"
+)
+
+// SpecParams are spec-wide typed values, referenced from any field as @params.name,
+// the references are substituted before the spec is parsed.
+type SpecParams map[string]*SpecParam
+
+type SpecParamType string
+
+const (
+	SpecParamAddress  SpecParamType = "address"
+	SpecParamWei      SpecParamType = "wei"
+	SpecParamUint     SpecParamType = "uint"
+	SpecParamString   SpecParamType = "string"
+	SpecParamDuration SpecParamType = "duration"
+)
+
+type SpecParam struct {
+	Type  SpecParamType `yaml:"type"`
+	Value string        `yaml:"value"`
+	Desc  string        `yaml:"desc"`
+}
+
+// normalize checks the value against the type, wei values with units
+// like "10 gwei" are converted into the amount of wei.
+func (param *SpecParam) normalize() (string, error) {
+	value := strings.TrimSpace(param.Value)
+	switch param.Type {
+	case SpecParamAddress:
+		if !common.IsHexAddress(value) {
+			return "", errors.New("not a hex address")
+		}
+		return value, nil
+	case SpecParamWei:
+		wei, err := parseWei(value)
+		if err != nil {
+			return "", err
+		}
+		return wei.String(), nil
+	case SpecParamUint:
+		n, ok := new(big.Int).SetString(value, 0)
+		if !ok || n.Sign() < 0 {
+			return "", errors.New("not an unsigned integer")
+		}
+		return n.String(), nil
+	case SpecParamDuration:
+		d, err := ParseDuration(value)
+		if err != nil {
+			return "", err
+		}
+		return d.String(), nil
+	case SpecParamString, "":
+		return param.Value, nil
+	}
+	return "", fmt.Errorf("unknown type %s, must be address, wei, uint, string or duration", param.Type)
+}
+
+var specParamRefRx = regexp.MustCompile(`@params\.([\w-]+)`)
+
+// RenderParams substitutes @params.name references in the spec data with the values from the
+// PARAMS section, the overrides are values from the command line in the form name=value.
+func RenderParams(data []byte, overrides []string) ([]byte, error) {
+	var section struct {
+		Params SpecParams `yaml:"PARAMS"`
+	}
+	if err := yaml.Unmarshal(data, &section); err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("param override must be name=value: %s", override)
+		}
+		param, ok := section.Params[parts[0]]
+		if !ok || param == nil {
+			return nil, fmt.Errorf("param %s is not defined in PARAMS", parts[0])
+		}
+		param.Value = parts[1]
+	}
+	values := make(map[string]string, len(section.Params))
+	for name, param := range section.Params {
+		if param == nil {
+			return nil, fmt.Errorf("param %s has no value", name)
+		}
+		value, err := param.normalize()
+		if err != nil {
+			return nil, fmt.Errorf("param %s: %v", name, err)
+		}
+		values[name] = value
+	}
+	var err error
+	data = specParamRefRx.ReplaceAllFunc(data, func(ref []byte) []byte {
+		name := string(specParamRefRx.FindSubmatch(ref)[1])
+		value, ok := values[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("param %s is not defined in PARAMS", name)
+		}
+		return []byte(value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
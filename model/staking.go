@@ -0,0 +1,134 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Staking are the staking positions of the spec wallets, keyed by name.
+type Staking map[string]*StakingSpec
+
+// StakingSpec is the stake of the Wallet: the beacon chain Validators by index or pubkey, whose
+// balances are read from the beacon API of beaconURL, and the liquid staking tokens it holds.
+type StakingSpec struct {
+	Wallet     string     `yaml:"wallet"`
+	Validators []string   `yaml:"validators"`
+	LSTs       []*LSTSpec `yaml:"lsts"`
+
+	wallet *WalletSpec `yaml:"-"`
+}
+
+// LSTSpec is a liquid staking token of the spec, Rate is the view of the token returning the ether per
+// token with 18 decimals. The rates of the common tokens are known by symbol, the others are 1:1.
+type LSTSpec struct {
+	Token string `yaml:"token"`
+	Rate  string `yaml:"rate"`
+
+	token *TokenSpec `yaml:"-"`
+}
+
+// lstRates are the exchange rate views of the well-known liquid staking tokens by symbol,
+// stETH and the other rebasing tokens are 1:1.
+var lstRates = map[string]string{
+	"wsteth":  "stEthPerToken()",
+	"reth":    "getExchangeRate()",
+	"cbeth":   "exchangeRate()",
+	"sfrxeth": "pricePerShare()",
+}
+
+var (
+	validatorPubkeyRx = regexp.MustCompile(`^0x[0-9a-fA-F]{96}$`)
+	rateViewRx        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\(\)$`)
+)
+
+func (staking Staking) Validate(ctx AppContext, spec *Spec) bool {
+	for name, position := range staking {
+		validateLog := log.WithFields(log.Fields{
+			"section": "Staking",
+			"stake":   name,
+		})
+		if position == nil {
+			validateLog.Errorln("stake has no spec")
+			return false
+		} else if err := position.validate(spec); err != nil {
+			validateLog.WithError(err).Errorln("invalid stake")
+			return false
+		}
+	}
+	return true
+}
+
+func (spec *StakingSpec) validate(root *Spec) error {
+	var ok bool
+	if spec.wallet, ok = root.Wallets.WalletSpec(spec.Wallet); !ok {
+		return fmt.Errorf("wallet %s of the stake is not found", spec.Wallet)
+	} else if !common.IsHexAddress(spec.wallet.Address) {
+		return fmt.Errorf("wallet %s has no address", spec.Wallet)
+	}
+	if len(spec.Validators) == 0 && len(spec.LSTs) == 0 {
+		return errors.New("stake has neither validators nor lsts")
+	}
+	for _, id := range spec.Validators {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil && !validatorPubkeyRx.MatchString(id) {
+			return fmt.Errorf("validator %q must be an index or a 48-byte pubkey", id)
+		}
+	}
+	if len(spec.Validators) > 0 && len(root.Config.BeaconURL) == 0 {
+		return errors.New("validators are read from the beacon API, set beaconURL in the config")
+	}
+	for _, lst := range spec.LSTs {
+		if lst == nil {
+			return errors.New("lst has no spec")
+		}
+		var err error
+		if lst.token, err = root.FindToken(lst.Token); err != nil {
+			return err
+		}
+		if len(lst.Rate) == 0 {
+			lst.Rate = lstRates[strings.ToLower(lst.token.Symbol)]
+		} else if !rateViewRx.MatchString(lst.Rate) {
+			return fmt.Errorf("rate %q of %s must be a view without arguments, e.g. stEthPerToken()", lst.Rate, lst.Token)
+		}
+	}
+	return nil
+}
+
+// StakerWallet is the wallet of the stake, which holds the tokens and withdraws from the validators.
+func (spec *StakingSpec) StakerWallet() *WalletSpec {
+	return spec.wallet
+}
+
+// TokenSpec is the liquid staking token.
+func (spec *LSTSpec) TokenSpec() *TokenSpec {
+	return spec.token
+}
+
+// Asset is the symbol of the token.
+func (spec *LSTSpec) Asset() string {
+	return strings.ToUpper(spec.token.Symbol)
+}
+
+// Decimals are the decimals of the token.
+func (spec *LSTSpec) Decimals() int {
+	if spec.token.Decimals == nil {
+		return 18
+	}
+	return *spec.token.Decimals
+}
+
+// Names returns the names of the stakes, sorted.
+func (staking Staking) Names() []string {
+	names := make([]string, 0, len(staking))
+	for name := range staking {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// StorageKey parses a mapping key, addresses are given in hex or by the wallet or
+// contract name, other value types as a hex or decimal number.
+func (spec *Spec) StorageKey(key string) (common.Hash, error) {
+	if address, err := spec.ResolveAddress(key); err == nil {
+		return address.Hash(), nil
+	}
+	word, err := ParseWord(key)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid word %s: %v", key, err)
+	}
+	return word, nil
+}
+
+// MappingSlot returns the slot of the mapping value, stored at keccak256(key . slot).
+func MappingSlot(slot, key common.Hash) common.Hash {
+	return crypto.Keccak256Hash(key.Bytes(), slot.Bytes())
+}
+
+// ArraySlot returns the slot of the dynamic array element, the elements are stored
+// starting at keccak256(slot), assuming each element takes a whole slot.
+func ArraySlot(slot common.Hash, index *big.Int) common.Hash {
+	return SlotOffset(crypto.Keccak256Hash(slot.Bytes()), index)
+}
+
+// SlotOffset returns the slot shifted by the offset, e.g. to a struct field.
+func SlotOffset(slot common.Hash, offset *big.Int) common.Hash {
+	n := new(big.Int).Add(slot.Big(), offset)
+	return common.BigToHash(math.U256(n))
+}
@@ -0,0 +1,196 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// KindGraphQL is a VIEW querying the subgraph of the config, its result has the Select fields
+// of the response data, so they can be referenced as @cmd.field by the later commands.
+const KindGraphQL CommandKind = "graphql"
+
+var graphQLIntRx = regexp.MustCompile(`^-?[0-9]+$`)
+
+// validateGraphQL validates the subgraph query, the args are the query variables.
+func (spec *ViewCmdSpec) validateGraphQL(ctx AppContext, name string, root *Spec, validateLog *log.Entry) bool {
+	if len(spec.Wallet) > 0 || spec.Instance != nil || len(spec.Method) > 0 {
+		validateLog.Errorln("graphql views can't have wallet, instance or method")
+		return false
+	} else if spec.Enumerate != nil || len(spec.Overrides) > 0 || len(spec.At) > 0 ||
+		len(spec.Transform) > 0 || spec.Expect != nil {
+		validateLog.Errorln("graphql views can't have enumerate, overrides, at, transform or expect")
+		return false
+	} else if len(spec.Params) > 0 && !spec.argsResolved {
+		validateLog.Errorln("graphql variables must be specified as args")
+		return false
+	}
+	if _, ok := root.Config.Subgraphs[spec.Subgraph]; !ok {
+		validateLog.WithField("subgraph", spec.Subgraph).Errorln("subgraph not found in config")
+		return false
+	} else if len(strings.TrimSpace(spec.Query)) == 0 {
+		validateLog.Errorln("no graphql query is specified")
+		return false
+	} else if len(spec.Select) == 0 {
+		validateLog.Errorln("no fields to select from the graphql response")
+		return false
+	}
+	for field, path := range spec.Select {
+		if !outputFieldRx.MatchString(field) {
+			validateLog.WithField("field", field).Errorln("selected field name must be alphanumeric")
+			return false
+		}
+		for _, part := range strings.Split(path, ".") {
+			if len(part) == 0 {
+				validateLog.WithField("field", field).Errorln("selected field has an empty path element")
+				return false
+			}
+		}
+	}
+	if !spec.argsResolved {
+		spec.variables = make([]string, 0, len(spec.Args))
+		for variable := range spec.Args {
+			spec.variables = append(spec.variables, variable)
+		}
+		sort.Strings(spec.variables)
+		for _, variable := range spec.variables {
+			spec.Params = append(spec.Params, namedArgParam(spec.Args[variable], variable, string(ParamTypeString)))
+		}
+		spec.argsResolved = true
+	}
+	if !spec.ParamSpec.Validate(ctx, name, root) {
+		return false
+	}
+	condition, err := validateCondition(ctx, root, spec.When)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to parse when condition")
+		return false
+	}
+	spec.condition = condition
+	return true
+}
+
+// GraphQLVariables returns the names of the query variables, in the order of the param values.
+func (spec *ViewCmdSpec) GraphQLVariables() []string {
+	return spec.variables
+}
+
+type graphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// QuerySubgraph posts the query with the variables to the subgraph, returning the data of the response.
+func (config *ConfigSpec) QuerySubgraph(ctx AppContext, subgraph, query string,
+	variables map[string]interface{}) (map[string]interface{}, error) {
+	endpoint, ok := config.Subgraphs[subgraph]
+	if !ok {
+		err := fmt.Errorf("subgraph %s not found in config", subgraph)
+		return nil, err
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("subgraph %s responded with status %s", subgraph, resp.Status)
+		return nil, err
+	}
+	var response graphQLResponse
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&response); err != nil {
+		return nil, err
+	} else if len(response.Errors) > 0 {
+		messages := make([]string, 0, len(response.Errors))
+		for _, graphQLErr := range response.Errors {
+			messages = append(messages, graphQLErr.Message)
+		}
+		err := fmt.Errorf("subgraph %s error: %s", subgraph, strings.Join(messages, "; "))
+		return nil, err
+	} else if response.Data == nil {
+		return nil, errors.New("subgraph response has no data")
+	}
+	return response.Data, nil
+}
+
+// SelectGraphQL returns the value at the dotted path of the response data, list elements are selected
+// by their index, e.g. swaps.0.amountUSD. Integers, including the BigInt strings, are returned as *big.Int.
+func SelectGraphQL(data map[string]interface{}, path string) (interface{}, error) {
+	var value interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			field, ok := v[part]
+			if !ok {
+				err := fmt.Errorf("no field %s in %s", part, path)
+				return nil, err
+			}
+			value = field
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(v) {
+				err := fmt.Errorf("no element %s in %s", part, path)
+				return nil, err
+			}
+			value = v[index]
+		default:
+			err := fmt.Errorf("no field %s in %s, the parent is not an object", part, path)
+			return nil, err
+		}
+	}
+	return graphQLValue(value), nil
+}
+
+func graphQLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, ok := new(big.Int).SetString(v.String(), 10); ok {
+			return i
+		}
+		return v.String()
+	case string:
+		if graphQLIntRx.MatchString(v) {
+			i, _ := new(big.Int).SetString(v, 10)
+			return i
+		}
+		return v
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = graphQLValue(item)
+		}
+		return list
+	case map[string]interface{}:
+		fields := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			fields[k] = graphQLValue(item)
+		}
+		return fields
+	}
+	return value
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const swapAPIKeyEnv = "SWAP_API_KEY"
+
+// NativeAssetAddress is how the aggregator refers to ether.
+var NativeAssetAddress = common.HexToAddress("0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE")
+
+// SwapAPI is the client of the 1inch-compatible aggregator API of swapURL, for the chain of the config.
+type SwapAPI struct {
+	url     string
+	apiKey  string
+	chainID string
+}
+
+// SwapTx is the transaction of the swap built by the aggregator, sent to its router.
+type SwapTx struct {
+	To    common.Address `json:"to"`
+	Data  hexutil.Bytes  `json:"data"`
+	Value string         `json:"value"`
+}
+
+type swapResponse struct {
+	DstAmount   string  `json:"dstAmount"`
+	Tx          *SwapTx `json:"tx"`
+	Address     string  `json:"address"`
+	Description string  `json:"description"`
+}
+
+func (spec *ConfigSpec) SwapAPI() *SwapAPI {
+	apiKey := spec.SwapAPIKey
+	if len(apiKey) == 0 {
+		apiKey = os.Getenv(swapAPIKeyEnv)
+	}
+	return &SwapAPI{
+		url:     strings.TrimSuffix(spec.SwapURL, "/"),
+		apiKey:  apiKey,
+		chainID: spec.ChainID,
+	}
+}
+
+// Quote is the amount of dst the amount of src is swapped for, as of now.
+func (api *SwapAPI) Quote(ctx context.Context, src, dst common.Address, amount *big.Int) (*big.Int, error) {
+	query := url.Values{}
+	query.Set("src", src.Hex())
+	query.Set("dst", dst.Hex())
+	query.Set("amount", amount.String())
+	result, err := api.get(ctx, "quote", query)
+	if err != nil {
+		return nil, err
+	}
+	return parseSwapAmount(result.DstAmount)
+}
+
+// Spender is the router the tokens are approved to before the swaps.
+func (api *SwapAPI) Spender(ctx context.Context) (common.Address, error) {
+	result, err := api.get(ctx, "approve/spender", url.Values{})
+	if err != nil {
+		return common.Address{}, err
+	} else if !common.IsHexAddress(result.Address) {
+		return common.Address{}, fmt.Errorf("swap API returned malformed spender %q", result.Address)
+	}
+	return common.HexToAddress(result.Address), nil
+}
+
+// Swap builds the transaction of the swap from the account, reverting if less than the quote
+// minus the slippage in percents is received. The allowance must be set beforehand.
+func (api *SwapAPI) Swap(ctx context.Context, src, dst common.Address, amount *big.Int,
+	from common.Address, slippage float64) (*SwapTx, *big.Int, error) {
+	query := url.Values{}
+	query.Set("src", src.Hex())
+	query.Set("dst", dst.Hex())
+	query.Set("amount", amount.String())
+	query.Set("from", from.Hex())
+	query.Set("origin", from.Hex())
+	query.Set("slippage", strconv.FormatFloat(slippage, 'f', -1, 64))
+	// the transaction is estimated when it's signed
+	query.Set("disableEstimate", "true")
+	result, err := api.get(ctx, "swap", query)
+	if err != nil {
+		return nil, nil, err
+	} else if result.Tx == nil || len(result.Tx.Data) == 0 {
+		return nil, nil, fmt.Errorf("swap API returned no transaction")
+	}
+	dstAmount, err := parseSwapAmount(result.DstAmount)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.Tx, dstAmount, nil
+}
+
+func (api *SwapAPI) get(ctx context.Context, method string, query url.Values) (*swapResponse, error) {
+	req, err := http.NewRequest("GET", api.url+"/"+api.chainID+"/"+method+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(api.apiKey) > 0 {
+		req.Header.Set("Authorization", "Bearer "+api.apiKey)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result swapResponse
+	if err := json.Unmarshal(body, &result); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("swap API returned malformed response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(result.Description) > 0 {
+			return nil, fmt.Errorf("swap API responded with status %s: %s", resp.Status, result.Description)
+		}
+		return nil, fmt.Errorf("swap API responded with status %s", resp.Status)
+	}
+	return &result, nil
+}
+
+func parseSwapAmount(amount string) (*big.Int, error) {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("swap API returned malformed amount %q", amount)
+	}
+	return value, nil
+}
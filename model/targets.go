@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"strings"
 
 	log "github.com/Sirupsen/logrus"
@@ -41,6 +42,12 @@ func (spec TargetSpec) Validate(ctx AppContext, name string, root *Spec) bool {
 	})
 	for _, cmdSpec := range spec {
 		cmdName := cmdSpec.Name()
+		if _, _, ok := parseTemplateCall(cmdName); ok && !root.HasCommand(cmdName) {
+			if err := root.registerTemplateInstance(cmdName); err != nil {
+				validateLog.WithField("command", cmdName).WithError(err).Errorln("failed to instantiate template")
+				return false
+			}
+		}
 		var found bool
 		if cmd, isFound := root.CallCmds[cmdName]; isFound {
 			if cmdSpec.IsDeferred() {
@@ -65,6 +72,10 @@ func (spec TargetSpec) Validate(ctx AppContext, name string, root *Spec) bool {
 			continue
 		}
 		if cmd, isFound := root.WriteCmds[cmdName]; isFound {
+			if cmdSpec.IsDeferred() && len(cmd.ExpectEvents) > 0 {
+				validateLog.WithField("command", cmdName).Errorln("expected events are checked in awaited commands only, not deferred")
+				return false
+			}
 			if !cmd.Validate(ctx, cmdName, root) {
 				return false
 			}
@@ -76,9 +87,116 @@ func (spec TargetSpec) Validate(ctx AppContext, name string, root *Spec) bool {
 			return false
 		}
 	}
+	if !spec.validateCompensations(ctx, root, validateLog) {
+		return false
+	}
+	if _, err := spec.Dependencies(root); err != nil {
+		validateLog.WithError(err).Errorln("invalid command dependencies")
+		return false
+	}
+	return true
+}
+
+// validateCompensations checks the onFailure commands of the target commands, compensating
+// commands are run by the executor directly, so they can't have compensations on their own.
+func (spec TargetSpec) validateCompensations(ctx AppContext, root *Spec, validateLog *log.Entry) bool {
+	for _, cmdSpec := range spec {
+		compensation := root.CommandOnFailure(cmdSpec.Name())
+		if len(compensation) == 0 {
+			continue
+		}
+		cmdLog := validateLog.WithFields(log.Fields{
+			"command":   cmdSpec.Name(),
+			"onFailure": compensation,
+		})
+		if !root.HasCommand(compensation) {
+			cmdLog.Errorln("compensating command not found")
+			return false
+		} else if len(root.CommandOnFailure(compensation)) > 0 {
+			cmdLog.Errorln("compensating command can't have onFailure itself")
+			return false
+		}
+		if !root.validateCommand(ctx, compensation) {
+			return false
+		}
+	}
 	return true
 }
 
+// Dependencies resolves the needs of target commands into the graph, where each entry lists
+// the offsets of target entries it depends on. A needed command that occurs multiple times
+// in the target resolves to the nearest preceding occurrence. Commands without needs keep
+// the order of the target, depending on all entries before them. It returns nil when
+// no command of the target has needs, so the target runs sequentially.
+func (spec TargetSpec) Dependencies(root *Spec) ([][]int, error) {
+	var hasNeeds bool
+	for _, cmd := range spec {
+		if len(root.CommandNeeds(cmd.Name())) > 0 {
+			hasNeeds = true
+			break
+		}
+	}
+	if !hasNeeds {
+		return nil, nil
+	}
+	deps := make([][]int, len(spec))
+	for i, cmd := range spec {
+		needs := root.CommandNeeds(cmd.Name())
+		if len(needs) == 0 {
+			for j := 0; j < i; j++ {
+				deps[i] = append(deps[i], j)
+			}
+			continue
+		}
+		for _, need := range needs {
+			dep := -1
+			for j := range spec {
+				if spec[j].Name() != need || j == i {
+					continue
+				}
+				if j < i || dep < 0 {
+					dep = j
+				}
+			}
+			if dep < 0 {
+				err := fmt.Errorf("command %s needs %s, which is not in the target", cmd.Name(), need)
+				return nil, err
+			}
+			deps[i] = append(deps[i], dep)
+		}
+	}
+	// detect cycles using depth-first search
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(spec))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("dependency cycle through command %s", spec[i].Name())
+		case visited:
+			return nil
+		}
+		state[i] = visiting
+		for _, dep := range deps[i] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		return nil
+	}
+	for i := range spec {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return deps, nil
+}
+
 func (spec TargetSpec) CmdNames() []string {
 	names := make([]string, 0, len(spec))
 	for _, cmd := range spec {
@@ -87,11 +205,15 @@ func (spec TargetSpec) CmdNames() []string {
 	return names
 }
 
-func (spec TargetSpec) ArgCount(root *Spec) int {
-	set := make(map[int]struct{})
+func (spec TargetSpec) CountArgsUsing(set map[int]struct{}, root *Spec) {
 	for _, cmd := range spec {
 		root.CountArgsUsing(set, cmd.Name())
 	}
+}
+
+func (spec TargetSpec) ArgCount(root *Spec) int {
+	set := make(map[int]struct{})
+	spec.CountArgsUsing(set, root)
 	return len(set)
 }
 
@@ -106,3 +228,88 @@ func (spec TargetCommandSpec) Name() string {
 func (spec TargetCommandSpec) IsDeferred() bool {
 	return strings.HasSuffix(string(spec), targetCommandDefer)
 }
+
+// IsReadOnlyTarget reports whether the target, including its hooks and compensating commands,
+// only reads the chain state: it has views and read-only CALL commands, but no WRITE commands.
+func (spec *Spec) IsReadOnlyTarget(name string) bool {
+	target, ok := spec.Targets[name]
+	if !ok {
+		return false
+	}
+	names := target.CmdNames()
+	if hooks := spec.Hooks[name]; hooks != nil {
+		names = append(names, hooks.commands()...)
+	}
+	for _, cmdName := range names {
+		if onFailure := spec.CommandOnFailure(cmdName); len(onFailure) > 0 {
+			names = append(names, onFailure)
+		}
+	}
+	for _, cmdName := range names {
+		if _, ok := spec.WriteCmds[cmdName]; ok {
+			return false
+		} else if cmd, ok := spec.CallCmds[cmdName]; ok && !cmd.IsReadOnly() {
+			return false
+		}
+	}
+	return true
+}
+
+// TargetItems returns the number of items the target is expected to run: each command counts once
+// per row of foreach and per wallet it fans out to. Compensating and onError commands are not counted.
+func (spec *Spec) TargetItems(name string) int {
+	target, ok := spec.Targets[name]
+	if !ok {
+		return 0
+	}
+	var names []string
+	if hooks := spec.Hooks[name]; hooks != nil {
+		names = append(names, hooks.Before...)
+		names = append(names, hooks.After...)
+	}
+	names = append(names, target.CmdNames()...)
+	var items int
+	for _, cmdName := range names {
+		items += spec.CommandItems(cmdName)
+	}
+	return items
+}
+
+// CommandItems returns the number of items the validated command runs, at least one.
+func (spec *Spec) CommandItems(name string) int {
+	var wallets []int
+	if cmd, ok := spec.CallCmds[name]; ok {
+		for _, iteration := range cmd.Iterations() {
+			wallets = append(wallets, len(iteration.MatchingWallets()))
+		}
+		if len(wallets) == 0 {
+			wallets = append(wallets, len(cmd.MatchingWallets()))
+		}
+	} else if cmd, ok := spec.ViewCmds[name]; ok {
+		for _, iteration := range cmd.Iterations() {
+			wallets = append(wallets, len(iteration.MatchingWallets()))
+		}
+		if len(wallets) == 0 {
+			wallets = append(wallets, len(cmd.MatchingWallets()))
+		}
+	} else if cmd, ok := spec.WriteCmds[name]; ok {
+		for _, iteration := range cmd.Iterations() {
+			wallets = append(wallets, len(iteration.FanoutWallets()))
+		}
+		if len(wallets) == 0 {
+			wallets = append(wallets, len(cmd.FanoutWallets()))
+		}
+	}
+	var items int
+	for _, n := range wallets {
+		if n == 0 {
+			// the command is run once, not fanned out
+			n = 1
+		}
+		items += n
+	}
+	if items == 0 {
+		return 1
+	}
+	return items
+}
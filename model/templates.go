@@ -0,0 +1,203 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"text/template"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/yaml"
+)
+
+// Templates are command specs defined once with typed parameters, and instantiated in targets
+// with different arguments, e.g. transfer(to=@alice, amount=100).
+type Templates map[string]*TemplateSpec
+
+type TemplateKind string
+
+const (
+	TemplateKindCall  TemplateKind = "call"
+	TemplateKindView  TemplateKind = "view"
+	TemplateKindWrite TemplateKind = "write"
+)
+
+type TemplateSpec struct {
+	// Kind is the section of the instantiated command: call, view or write.
+	Kind TemplateKind `yaml:"kind"`
+	// Params are the names of the template params and their types: address, bool, string, int* or uint*.
+	Params map[string]string `yaml:"params"`
+	// Command is the command spec, the params are bound as template variables, e.g. {{ .to }}.
+	Command map[string]interface{} `yaml:"command"`
+}
+
+func (templates Templates) Validate(ctx AppContext, spec *Spec) bool {
+	validateLog := log.WithFields(log.Fields{
+		"section": "Templates",
+		"func":    "Validate",
+	})
+	for name, tpl := range templates {
+		tplLog := validateLog.WithField("template", name)
+		if tpl == nil || len(tpl.Command) == 0 {
+			tplLog.Errorln("template has no command spec")
+			return false
+		}
+		switch tpl.Kind {
+		case TemplateKindCall, TemplateKindView, TemplateKindWrite:
+		default:
+			tplLog.WithField("kind", tpl.Kind).Errorln("template kind must be call, view or write")
+			return false
+		}
+		for param, typ := range tpl.Params {
+			if !isTemplateParamType(typ) {
+				tplLog.WithFields(log.Fields{
+					"param": param,
+					"type":  typ,
+				}).Errorln("unsupported template param type")
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isTemplateParamType(typ string) bool {
+	switch {
+	case typ == "address", typ == "bool", typ == "string":
+		return true
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		return true
+	}
+	return false
+}
+
+// parseTemplateCall parses the target command of the form template(name=value, ...).
+func parseTemplateCall(str string) (string, map[string]string, bool) {
+	open := strings.Index(str, "(")
+	if open <= 0 || !strings.HasSuffix(str, ")") {
+		return "", nil, false
+	}
+	name := strings.TrimSpace(str[:open])
+	args := make(map[string]string)
+	body := strings.TrimSpace(str[open+1 : len(str)-1])
+	if len(body) == 0 {
+		return name, args, true
+	}
+	for _, arg := range strings.Split(body, ",") {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return "", nil, false
+		}
+		args[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return name, args, true
+}
+
+// checkTemplateArg checks the arg value against the param type, references
+// to wallets ($1, @alice) are resolved later, along with the command params.
+func checkTemplateArg(typ, value string) error {
+	if isArgRef(value) || isWalletRef(value) {
+		return nil
+	}
+	switch {
+	case typ == "address":
+		if !common.IsHexAddress(value) {
+			return errors.New("not a hex address")
+		}
+	case typ == "bool":
+		if value != "true" && value != "false" {
+			return errors.New("not a bool")
+		}
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		n, ok := new(big.Int).SetString(value, 0)
+		if !ok {
+			return errors.New("not an integer")
+		} else if n.Sign() < 0 && strings.HasPrefix(typ, "uint") {
+			return errors.New("negative value of unsigned type")
+		}
+	}
+	return nil
+}
+
+// instantiateTemplate renders the template command with args of the target command,
+// the result is *CallCmdSpec, *ViewCmdSpec or *WriteCmdSpec depending on the template kind.
+func (spec *Spec) instantiateTemplate(str string) (interface{}, TemplateKind, error) {
+	name, args, ok := parseTemplateCall(str)
+	if !ok {
+		return nil, "", errors.New("not a template instance")
+	}
+	tpl, ok := spec.Templates[name]
+	if !ok || tpl == nil {
+		return nil, "", fmt.Errorf("template %s not found", name)
+	}
+	for param, typ := range tpl.Params {
+		value, ok := args[param]
+		if !ok {
+			return nil, "", fmt.Errorf("template param %s is not set", param)
+		}
+		if err := checkTemplateArg(typ, value); err != nil {
+			return nil, "", fmt.Errorf("template param %s must be %s: %v", param, typ, err)
+		}
+	}
+	for arg := range args {
+		if _, ok := tpl.Params[arg]; !ok {
+			return nil, "", fmt.Errorf("template has no param %s", arg)
+		}
+	}
+	doc, err := yaml.Marshal(tpl.Command)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal template command: %v", err)
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(string(doc))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse template: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, args); err != nil {
+		return nil, "", fmt.Errorf("failed to render template: %v", err)
+	}
+	var cmd interface{}
+	switch tpl.Kind {
+	case TemplateKindCall:
+		cmd = new(CallCmdSpec)
+	case TemplateKindView:
+		cmd = new(ViewCmdSpec)
+	default:
+		cmd = new(WriteCmdSpec)
+	}
+	if err := yaml.Unmarshal(buf.Bytes(), cmd); err != nil {
+		return nil, "", fmt.Errorf("failed to parse rendered command: %v", err)
+	}
+	return cmd, tpl.Kind, nil
+}
+
+// registerTemplateInstance adds the template instance as a command named after the target
+// command, e.g. transfer(to=@alice, amount=100), so it runs like any other command.
+func (spec *Spec) registerTemplateInstance(str string) error {
+	cmd, kind, err := spec.instantiateTemplate(str)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case TemplateKindCall:
+		if spec.CallCmds == nil {
+			spec.CallCmds = make(CallCmds)
+		}
+		spec.CallCmds[str] = cmd.(*CallCmdSpec)
+	case TemplateKindView:
+		if spec.ViewCmds == nil {
+			spec.ViewCmds = make(ViewCmds)
+		}
+		spec.ViewCmds[str] = cmd.(*ViewCmdSpec)
+	default:
+		if spec.WriteCmds == nil {
+			spec.WriteCmds = make(WriteCmds)
+		}
+		spec.WriteCmds[str] = cmd.(*WriteCmdSpec)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package model
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CommandKind is the high-level command operating on a declared token, it's expanded
+// into the ERC-20 method call, so the method and params are not written by hand.
+type CommandKind string
+
+const (
+	// KindTokenTransfer is a WRITE sending the value in token units to the recipient.
+	KindTokenTransfer CommandKind = "token-transfer"
+	// KindTokenApprove is a WRITE approving the spender to spend the value in token units.
+	KindTokenApprove CommandKind = "token-approve"
+	// KindTokenAllowance is a VIEW of the amount the spender may spend from the matching wallets.
+	KindTokenAllowance CommandKind = "token-allowance"
+)
+
+// UnlimitedAllowance is the value of token-approve approving the max uint256.
+const UnlimitedAllowance = "unlimited"
+
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// FindToken returns the token by the name in TOKENS section, or by the symbol.
+func (spec *Spec) FindToken(nameOrSymbol string) (*TokenSpec, error) {
+	token, ok := spec.Tokens[nameOrSymbol]
+	if !ok || token == nil {
+		if token, ok = spec.Tokens.Find(nameOrSymbol); !ok {
+			return nil, fmt.Errorf("token %s is not found in TOKENS section", nameOrSymbol)
+		}
+	}
+	if token.instance == nil || len(token.Symbol) == 0 || token.Decimals == nil {
+		return nil, fmt.Errorf("token %s metadata is not known, nodes are required", nameOrSymbol)
+	}
+	return token, nil
+}
+
+// tokenAccountParam is the address param of a wallet name, an address or an ENS name.
+func tokenAccountParam(root *Spec, account string) (interface{}, error) {
+	if _, ok := root.Wallets.WalletSpec(account); ok {
+		return map[interface{}]interface{}{
+			"type":  string(ParamTypeAddress),
+			"value": walletPrefix + account,
+		}, nil
+	} else if _, ok := root.AddressBook.Address(account); ok || common.IsHexAddress(account) || isENSName(account) {
+		return map[interface{}]interface{}{
+			"type":  string(ParamTypeAddress),
+			"value": account,
+		}, nil
+	}
+	return nil, fmt.Errorf("%s must be a wallet name, a label, an address or an ENS name", account)
+}
+
+// expandTokenKind turns token-transfer into the transfer of the value in token units,
+// and token-approve into the approve call of the token.
+func (spec *WriteCmdSpec) expandTokenKind(validateLog *log.Entry, root *Spec) bool {
+	if spec.token != nil {
+		// expanded already
+		return true
+	} else if len(spec.Token) == 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("no token specified")
+		return false
+	} else if spec.Instance != nil || len(spec.Method) > 0 || len(spec.Params) > 0 || len(spec.Args) > 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("token commands must not have instance, method nor params specified")
+		return false
+	} else if spec.Clone != nil || len(spec.Ownership) > 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("token commands can't be used with clone or ownership helpers")
+		return false
+	} else if len(spec.Value) == 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("no value specified, the amount in token units")
+		return false
+	}
+	token, err := root.FindToken(spec.Token)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to find the token")
+		return false
+	}
+	switch spec.Kind {
+	case KindTokenTransfer:
+		if len(spec.To) == 0 {
+			validateLog.Errorln("no recipient 'to' specified")
+			return false
+		} else if len(spec.Spender) > 0 {
+			validateLog.Errorln("spender must not be specified while transferring")
+			return false
+		}
+		if denominator := spec.Value.Denominator(); denominator != strings.ToLower(token.Symbol) {
+			if len(denominator) > 0 {
+				validateLog.WithField("denominator", denominator).Errorln("value must be in units of the token")
+				return false
+			}
+			spec.Value = Valuer(strings.TrimSpace(string(spec.Value)) + " " + token.Symbol)
+		}
+	case KindTokenApprove:
+		if len(spec.Spender) == 0 {
+			validateLog.Errorln("no spender specified to approve")
+			return false
+		} else if len(spec.To) > 0 {
+			validateLog.Errorln("recipient 'to' must not be specified while approving")
+			return false
+		}
+		spender, err := tokenAccountParam(root, spec.Spender)
+		if err != nil {
+			validateLog.WithError(err).Errorln("invalid spender")
+			return false
+		}
+		amount := map[interface{}]interface{}{
+			"type": "uint256",
+		}
+		value := strings.TrimSpace(string(spec.Value))
+		if value == UnlimitedAllowance {
+			amount["value"] = maxUint256.String()
+		} else {
+			if denominator := spec.Value.Denominator(); len(denominator) == 0 {
+				value += " " + token.Symbol
+			} else if denominator != strings.ToLower(token.Symbol) {
+				validateLog.WithField("denominator", denominator).Errorln("value must be in units of the token")
+				return false
+			}
+			// CLI args are resolved in references
+			amount["reference"] = value
+		}
+		spec.Instance = token.instance
+		spec.Method = "approve"
+		spec.Params = []interface{}{spender, amount}
+		spec.Value = ""
+	default:
+		validateLog.WithField("kind", spec.Kind).Errorln("unknown write command kind (token-transfer, token-approve, nft-transfer or nft-batch-transfer)")
+		return false
+	}
+	spec.token = token
+	return true
+}
+
+// expandTokenKind turns token-allowance into the allowance call of the token, with the matching
+// wallets as owners, the result is converted into token units unless transformed otherwise.
+func (spec *ViewCmdSpec) expandTokenKind(validateLog *log.Entry, root *Spec) bool {
+	if spec.token != nil {
+		// expanded already
+		return true
+	} else if spec.Kind != KindTokenAllowance {
+		validateLog.WithField("kind", spec.Kind).Errorln("unknown view command kind (token-allowance, nft-owner or nft-balance)")
+		return false
+	} else if len(spec.Token) == 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("no token specified")
+		return false
+	} else if spec.Instance != nil || len(spec.Method) > 0 || len(spec.Params) > 0 || len(spec.Args) > 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("token commands must not have instance, method nor params specified")
+		return false
+	} else if len(spec.matching) == 0 {
+		validateLog.WithField("kind", spec.Kind).Errorln("no wallets specified, the owners of the allowance")
+		return false
+	} else if len(spec.Spender) == 0 {
+		validateLog.Errorln("no spender specified to check the allowance of")
+		return false
+	}
+	token, err := root.FindToken(spec.Token)
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to find the token")
+		return false
+	}
+	spender, err := tokenAccountParam(root, spec.Spender)
+	if err != nil {
+		validateLog.WithError(err).Errorln("invalid spender")
+		return false
+	}
+	owner := map[interface{}]interface{}{
+		"type":  string(ParamTypeAddress),
+		"value": walletPrefix + walletPrefix,
+	}
+	spec.token = token
+	spec.Instance = token.instance
+	spec.Method = "allowance"
+	spec.Params = []interface{}{owner, spender}
+	if unit, _ := token.Unit(); len(spec.Transform) == 0 && unit.Cmp(big.NewInt(1)) > 0 {
+		spec.Transform = []interface{}{
+			map[interface{}]interface{}{string(TransformDiv): unit.String()},
+		}
+	}
+	return true
+}
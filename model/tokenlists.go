@@ -0,0 +1,167 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// tokenListCacheTTL is how long the fetched token lists are used before fetching them again.
+const tokenListCacheTTL = 24 * time.Hour
+
+// TokenListSpec is a token list (https://tokenlists.org) the tokens of the chain are imported from
+// into the TOKENS section, by lowercase symbol. Symbols limits the import to the listed symbols.
+type TokenListSpec struct {
+	URL     string   `yaml:"url"`
+	Symbols []string `yaml:"symbols"`
+}
+
+type tokenList struct {
+	Name   string            `json:"name"`
+	Tokens []*tokenListEntry `json:"tokens"`
+}
+
+type tokenListEntry struct {
+	ChainID  int64  `json:"chainId"`
+	Address  string `json:"address"`
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Decimals int    `json:"decimals"`
+}
+
+// importTokenLists adds the tokens of the lists for the chain of the config, the tokens
+// declared in the spec take precedence over the listed ones with the same name or address.
+func (spec *Spec) importTokenLists(ctx AppContext) bool {
+	chainID, ok := spec.Config.ChainIDInt()
+	if !ok {
+		// reported by the config validation
+		return false
+	}
+	if spec.Tokens == nil {
+		spec.Tokens = make(Tokens)
+	}
+	addresses := make(map[common.Address]string, len(spec.Tokens))
+	for name, token := range spec.Tokens {
+		if token != nil && common.IsHexAddress(token.Address) {
+			addresses[common.HexToAddress(token.Address)] = name
+		}
+	}
+	for _, listSpec := range spec.TokenLists {
+		validateLog := log.WithFields(log.Fields{
+			"section": "TokenLists",
+			"url":     listSpec.URL,
+		})
+		list, err := listSpec.fetch(ctx.SpecDir())
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to fetch token list")
+			return false
+		}
+		allowed := make(map[string]bool, len(listSpec.Symbols))
+		for _, symbol := range listSpec.Symbols {
+			allowed[strings.ToLower(symbol)] = false
+		}
+		var imported int
+		for _, entry := range list.Tokens {
+			name := strings.ToLower(entry.Symbol)
+			if entry.ChainID != chainID.Int64() {
+				continue
+			} else if _, ok := allowed[name]; len(allowed) > 0 && !ok {
+				continue
+			}
+			allowed[name] = true
+			entryLog := validateLog.WithFields(log.Fields{
+				"symbol":  entry.Symbol,
+				"address": entry.Address,
+			})
+			if !common.IsHexAddress(entry.Address) || !tokenSymbolRx.MatchString(entry.Symbol) ||
+				IsCommonDenominator(name) || entry.Decimals < 0 || entry.Decimals > maxTokenDecimals {
+				entryLog.Warningln("skipping malformed token list entry")
+				continue
+			}
+			address := common.HexToAddress(entry.Address)
+			if other, ok := addresses[address]; ok {
+				entryLog.WithField("token", other).Debugln("token is declared already")
+				continue
+			} else if _, ok := spec.Tokens[name]; ok {
+				entryLog.Debugln("token name is taken by another token")
+				continue
+			}
+			decimals := entry.Decimals
+			spec.Tokens[name] = &TokenSpec{
+				Address:  strings.ToLower(address.Hex()),
+				Symbol:   entry.Symbol,
+				Name:     entry.Name,
+				Decimals: &decimals,
+			}
+			addresses[address] = name
+			imported++
+		}
+		for symbol, found := range allowed {
+			if !found {
+				validateLog.WithField("symbol", symbol).Warningln("token is not in the list for the chain")
+			}
+		}
+		validateLog.WithFields(log.Fields{
+			"list":   list.Name,
+			"tokens": imported,
+		}).Debugln("token list imported")
+	}
+	return true
+}
+
+// fetch returns the token list, it's fetched once a day, the cached list is used when the URL fails.
+func (spec *TokenListSpec) fetch(specDir string) (*tokenList, error) {
+	if !strings.HasPrefix(spec.URL, "https://") && !strings.HasPrefix(spec.URL, "http://") {
+		return nil, errors.New("token list URL must be http(s)")
+	}
+	sum := sha256.Sum256([]byte(spec.URL))
+	path := cachePath(specDir, "tokenlists", hex.EncodeToString(sum[:8])+".json")
+	var list *tokenList
+	if cached, ok := readCache(path); ok {
+		if err := json.Unmarshal(cached, &list); err != nil {
+			list = nil
+		}
+	}
+	if info, err := os.Stat(path); err == nil && list != nil && time.Since(info.ModTime()) < tokenListCacheTTL {
+		return list, nil
+	}
+	data, err := fetchTokenList(spec.URL)
+	if err == nil {
+		var fetched *tokenList
+		if err = json.Unmarshal(data, &fetched); err == nil && fetched != nil {
+			if err := writeCache(path, data); err != nil {
+				log.WithError(err).Warningln("failed to cache token list")
+			}
+			return fetched, nil
+		} else if err == nil {
+			err = errors.New("token list is empty")
+		}
+	}
+	if list != nil {
+		log.WithError(err).WithField("url", spec.URL).Warningln("failed to fetch token list, using the cached one")
+		return list, nil
+	}
+	return nil, err
+}
+
+func fetchTokenList(url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token list request failed: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
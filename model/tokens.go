@@ -0,0 +1,339 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/AtlantPlatform/ethfw"
+	"github.com/AtlantPlatform/ethfw/sol"
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// tokenFetchTimeout is the timeout of fetching the metadata of a token.
+const tokenFetchTimeout = 10 * time.Second
+
+// tokenSymbolRx matches the symbols usable as denominators, like the denominators of values.
+var tokenSymbolRx = regexp.MustCompile(`^[a-zA-Z]\w*$`)
+
+// maxTokenDecimals is the most decimals an amount of uint256 can have.
+const maxTokenDecimals = 77
+
+// erc20Fragments is the ABI the tokens are bound with.
+var erc20Fragments = []string{
+	"function name() view returns (string)",
+	"function symbol() view returns (string)",
+	"function decimals() view returns (uint8)",
+	"function totalSupply() view returns (uint256)",
+	"function balanceOf(address owner) view returns (uint256)",
+	"function allowance(address owner, address spender) view returns (uint256)",
+	"function transfer(address to, uint256 value) returns (bool)",
+	"function transferFrom(address from, address to, uint256 value) returns (bool)",
+	"function approve(address spender, uint256 value) returns (bool)",
+	"event Transfer(address indexed from, address indexed to, uint256 value)",
+	"event Approval(address indexed owner, address indexed spender, uint256 value)",
+}
+
+// Tokens are the ERC-20 tokens by name, their symbols are the denominators of token
+// amounts in values and params, e.g. 100 DAI is converted using the decimals of DAI.
+type Tokens map[string]*TokenSpec
+
+// TokenSpec is the token declared by address or ENS name, the symbol, name and decimals are
+// fetched from the token contract, unless specified, and cached on disk per chain and address.
+type TokenSpec struct {
+	Address  string `yaml:"address"`
+	Symbol   string `yaml:"symbol"`
+	Name     string `yaml:"name"`
+	Decimals *int   `yaml:"decimals"`
+	// PriceFeed is the Chainlink feed of the token price, the swaps are checked against.
+	PriceFeed string `yaml:"priceFeed"`
+	// PriceID is the coin of the token in the price API, looked up by the address if not set.
+	PriceID string `yaml:"priceID"`
+
+	ensName  string                `yaml:"-"`
+	instance *ContractInstanceSpec `yaml:"-"`
+}
+
+type tokenMetadata struct {
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Decimals int    `json:"decimals"`
+}
+
+func (tokens Tokens) Validate(ctx AppContext, spec *Spec) bool {
+	var client *rpc.Client
+	if len(ctx.AppCommand()) > 0 {
+		// the nodes have been validated with the inventory
+		if endpoints, ok := spec.Endpoints(ctx.NodeGroup()); ok {
+			client, _ = endpoints.Dial(false)
+		}
+	}
+	if client != nil {
+		defer client.Close()
+	}
+	symbols := make(map[string]string, len(tokens))
+	for name, token := range tokens {
+		if token == nil {
+			log.WithFields(log.Fields{
+				"section": "Tokens",
+				"token":   name,
+			}).Errorln("token spec must have an address")
+			return false
+		}
+		if !token.Validate(ctx, name, spec, client) {
+			return false
+		}
+		if len(token.Symbol) == 0 {
+			continue
+		}
+		symbol := strings.ToLower(token.Symbol)
+		validateLog := log.WithFields(log.Fields{
+			"section": "Tokens",
+			"token":   name,
+			"symbol":  token.Symbol,
+		})
+		if other, ok := symbols[symbol]; ok {
+			validateLog.WithField("other", other).Errorln("token symbol is not unique, override the symbol of one token")
+			return false
+		} else if IsCommonDenominator(symbol) {
+			validateLog.Errorln("token symbol clashes with the ether unit, override the symbol")
+			return false
+		}
+		symbols[symbol] = name
+	}
+	return true
+}
+
+func (spec *TokenSpec) Validate(ctx AppContext, name string, root *Spec, client *rpc.Client) bool {
+	validateLog := log.WithFields(log.Fields{
+		"section": "Tokens",
+		"token":   name,
+	})
+	if isENSName(spec.Address) {
+		address, err := root.ResolveENS(ctx, spec.Address)
+		if err != nil && client == nil {
+			validateLog.WithField("ens", spec.Address).Warningln("ENS name is not resolved without nodes")
+			return true
+		} else if err != nil {
+			validateLog.WithError(err).Errorln("failed to resolve token ENS name")
+			return false
+		}
+		spec.ensName = spec.Address
+		spec.Address = strings.ToLower(address.Hex())
+	} else if !common.IsHexAddress(spec.Address) {
+		validateLog.Errorln("token address is not valid (must be hex string starting from 0x, or ENS name)")
+		return false
+	}
+	if len(spec.PriceFeed) > 0 && !common.IsHexAddress(spec.PriceFeed) {
+		validateLog.Errorln("token price feed address is not valid")
+		return false
+	}
+	if spec.Decimals != nil && (*spec.Decimals < 0 || *spec.Decimals > maxTokenDecimals) {
+		validateLog.WithField("decimals", *spec.Decimals).Errorln("token decimals must be within 0..77")
+		return false
+	}
+	if len(spec.Symbol) == 0 || len(spec.Name) == 0 || spec.Decimals == nil {
+		metadata, err := spec.metadata(ctx, root.Config, client)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to fetch token metadata")
+			return false
+		} else if metadata == nil {
+			validateLog.Warningln("token metadata is not known without nodes")
+		} else {
+			if len(spec.Symbol) == 0 {
+				spec.Symbol = metadata.Symbol
+			}
+			if len(spec.Name) == 0 {
+				spec.Name = metadata.Name
+			}
+			if spec.Decimals == nil {
+				decimals := metadata.Decimals
+				spec.Decimals = &decimals
+			}
+		}
+	}
+	if len(spec.Symbol) > 0 && !tokenSymbolRx.MatchString(spec.Symbol) {
+		validateLog.WithField("symbol", spec.Symbol).Errorln("token symbol is not a valid denominator, override the symbol")
+		return false
+	}
+	binding, err := ethfw.BindContract(nil, erc20Contract(name))
+	if err != nil {
+		validateLog.WithError(err).Errorln("failed to create token binding")
+		return false
+	}
+	spec.instance = &ContractInstanceSpec{
+		Name:        name,
+		Address:     strings.ToLower(spec.Address),
+		binding:     binding,
+		tokenSymbol: strings.ToUpper(spec.Symbol),
+	}
+	return true
+}
+
+func erc20Contract(name string) *sol.Contract {
+	abiJSON, _ := parseFragments(erc20Fragments)
+	return &sol.Contract{
+		Name: name,
+		ABI:  abiJSON,
+	}
+}
+
+// metadata returns the cached metadata of the token, or fetches it from the token contract.
+// It's nil, if not cached and there are no nodes.
+func (spec *TokenSpec) metadata(ctx AppContext, config *ConfigSpec, client *rpc.Client) (*tokenMetadata, error) {
+	path := cachePath(ctx.SpecDir(), "tokens", config.ChainID, strings.ToLower(spec.Address)+".json")
+	if data, ok := readCache(path); ok {
+		var metadata *tokenMetadata
+		if err := json.Unmarshal(data, &metadata); err == nil && metadata != nil {
+			return metadata, nil
+		}
+	}
+	if client == nil {
+		return nil, nil
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, tokenFetchTimeout)
+	defer cancel()
+	address := common.HexToAddress(spec.Address)
+	metadata := new(tokenMetadata)
+	// symbol()
+	result, err := ethCall(fetchCtx, client, address, common.FromHex("0x95d89b41"))
+	if err != nil {
+		return nil, err
+	} else if metadata.Symbol, err = decodeTokenString(result); err != nil {
+		return nil, fmt.Errorf("symbol: %v", err)
+	}
+	// name() is optional in ERC-20
+	if result, err = ethCall(fetchCtx, client, address, common.FromHex("0x06fdde03")); err == nil {
+		metadata.Name, _ = decodeTokenString(result)
+	}
+	// decimals()
+	if result, err = ethCall(fetchCtx, client, address, common.FromHex("0x313ce567")); err != nil {
+		return nil, err
+	} else if len(result) != 32 {
+		return nil, errors.New("decimals: not a token contract")
+	}
+	decimals := new(big.Int).SetBytes(result)
+	if !decimals.IsInt64() || decimals.Int64() > maxTokenDecimals {
+		return nil, fmt.Errorf("decimals: %s is out of range", decimals)
+	}
+	metadata.Decimals = int(decimals.Int64())
+	if data, err := json.Marshal(metadata); err == nil {
+		if err := writeCache(path, data); err != nil {
+			log.WithError(err).Warningln("failed to cache token metadata")
+		}
+	}
+	return metadata, nil
+}
+
+// decodeTokenString decodes the string returned by symbol() or name(), some older
+// tokens, e.g. MKR, return bytes32 instead.
+func decodeTokenString(result []byte) (string, error) {
+	if len(result) == 32 {
+		return string(bytes.TrimRight(result, "\x00")), nil
+	} else if len(result) < 64 {
+		return "", errors.New("not a token contract")
+	}
+	offset := new(big.Int).SetBytes(result[:32])
+	if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(result)) {
+		return "", errors.New("malformed string")
+	}
+	start := offset.Uint64() + 32
+	length := new(big.Int).SetBytes(result[start-32 : start])
+	if !length.IsUint64() || start+length.Uint64() > uint64(len(result)) {
+		return "", errors.New("malformed string")
+	}
+	return string(result[start : start+length.Uint64()]), nil
+}
+
+// Find returns the token by the symbol, case-insensitive.
+func (tokens Tokens) Find(symbol string) (*TokenSpec, bool) {
+	for _, token := range tokens {
+		if len(token.Symbol) > 0 && strings.EqualFold(token.Symbol, symbol) {
+			return token, true
+		}
+	}
+	return nil, false
+}
+
+// denominators are the lower-case symbols of the tokens.
+func (tokens Tokens) denominators() []string {
+	names := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if len(token.Symbol) > 0 {
+			names = append(names, strings.ToLower(token.Symbol))
+		}
+	}
+	return names
+}
+
+// Unit is the amount of one token in the smallest units, it's false if the decimals are not known.
+func (spec *TokenSpec) Unit() (*big.Int, bool) {
+	if spec.Decimals == nil {
+		return nil, false
+	}
+	return weiUnit(int64(*spec.Decimals)), true
+}
+
+// ENSName is the ENS name the token address has been resolved from.
+func (spec *TokenSpec) ENSName() string {
+	return spec.ensName
+}
+
+// Instance is the token contract bound with the ERC-20 ABI.
+func (spec *TokenSpec) Instance() *ContractInstanceSpec {
+	return spec.instance
+}
+
+// FindTokenInstance returns the token contract by the symbol, the tokens
+// section first, then the contract instances with the fetched symbol.
+func (spec *Spec) FindTokenInstance(symbol string) (*ContractInstanceSpec, bool) {
+	if token, ok := spec.Tokens.Find(symbol); ok && token.instance != nil {
+		return token.instance, true
+	}
+	return spec.Contracts.FindByTokenSymbol(symbol)
+}
+
+// denominatorUnit is the unit of the value denominator: ether units and the tokens with known decimals.
+func (spec *Spec) denominatorUnit(denominator string) (*big.Int, bool) {
+	if unit, ok := weiUnits[denominator]; ok {
+		return unit, true
+	}
+	if token, ok := spec.Tokens.Find(denominator); ok {
+		return token.Unit()
+	}
+	return nil, false
+}
+
+// tokenAmount converts the amount in token units, e.g. 100 DAI or 0.5 USDC,
+// into the smallest units. It's false if the amount has no token symbol.
+func (spec *Spec) tokenAmount(value string) (*big.Int, bool, error) {
+	parts := strings.Fields(value)
+	if len(parts) != 2 {
+		return nil, false, nil
+	}
+	token, ok := spec.Tokens.Find(parts[1])
+	if !ok {
+		return nil, false, nil
+	}
+	unit, ok := token.Unit()
+	if !ok {
+		return nil, true, fmt.Errorf("decimals of %s are not known", token.Symbol)
+	}
+	amount, ok := new(big.Rat).SetString(parts[0])
+	if !ok {
+		return nil, true, fmt.Errorf("not an amount: %s", parts[0])
+	}
+	units, err := denominateAmount(amount, unit)
+	if err != nil {
+		return nil, true, fmt.Errorf("amount %s is a fraction of the smallest unit of %s", parts[0], token.Symbol)
+	}
+	return units, true, nil
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+type TransformOp string
+
+const (
+	TransformDiv      TransformOp = "div"
+	TransformMul      TransformOp = "mul"
+	TransformAdd      TransformOp = "add"
+	TransformSub      TransformOp = "sub"
+	TransformHex      TransformOp = "hex"
+	TransformChecksum TransformOp = "checksum"
+	TransformSlice    TransformOp = "slice"
+)
+
+// IsArithmetic reports whether the op takes a number or another command output as operand.
+func (op TransformOp) IsArithmetic() bool {
+	switch op {
+	case TransformDiv, TransformMul, TransformAdd, TransformSub:
+		return true
+	}
+	return false
+}
+
+// TransformStep is a parsed step of the view result transformation pipeline.
+type TransformStep struct {
+	Op TransformOp
+
+	// Number or Ref is the operand of arithmetic ops.
+	Number *big.Rat
+	Ref    *CommandOutputReference
+
+	// Start and End are the bounds of slice, negative values count from the end,
+	// HasEnd is false when slicing to the end.
+	Start  int
+	End    int
+	HasEnd bool
+}
+
+// parseTransforms parses the list of steps, each is either an op name (hex, checksum)
+// or a single-key map of the op and its operand, e.g. {div: 1e18} or {slice: [0, 2]}.
+func parseTransforms(root *Spec, list []interface{}) ([]*TransformStep, error) {
+	evaler := NewEvaler()
+	steps := make([]*TransformStep, 0, len(list))
+	for i, item := range list {
+		var step *TransformStep
+		var err error
+		switch v := item.(type) {
+		case string:
+			step, err = parseTransformStep(root, evaler, TransformOp(v), nil)
+		case map[interface{}]interface{}:
+			if len(v) != 1 {
+				err = errors.New("step must have exactly one op")
+				break
+			}
+			for op, arg := range v {
+				step, err = parseTransformStep(root, evaler, TransformOp(nillableStr(op)), arg)
+			}
+		default:
+			err = fmt.Errorf("unexpected step type: %T", item)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("transform[%d]: %v", i, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func parseTransformStep(root *Spec, evaler *Evaler, op TransformOp, arg interface{}) (*TransformStep, error) {
+	step := &TransformStep{
+		Op: op,
+	}
+	switch {
+	case op == TransformHex || op == TransformChecksum:
+		if arg != nil {
+			return nil, fmt.Errorf("%s takes no operand", op)
+		}
+	case op.IsArithmetic():
+		str := nillableStr(arg)
+		if len(str) == 0 {
+			return nil, fmt.Errorf("%s requires an operand", op)
+		}
+		if isWalletRef(str) {
+			ref, ok := newCommandOutputReference(root, str)
+			if !ok {
+				return nil, fmt.Errorf("%s operand must reference a command output: %s", op, str)
+			}
+			step.Ref = ref
+			break
+		}
+		result, err := evaler.Run(str, ExprTypeInterger, ExprTypeFloat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate %s operand: %v", op, err)
+		}
+		switch v := result.(type) {
+		case *big.Int:
+			step.Number = new(big.Rat).SetInt(v)
+		case *big.Float:
+			step.Number, _ = v.Rat(nil)
+		}
+		if op == TransformDiv && step.Number.Sign() == 0 {
+			return nil, errors.New("division by zero")
+		}
+	case op == TransformSlice:
+		var bounds []interface{}
+		switch v := arg.(type) {
+		case []interface{}:
+			bounds = v
+		default:
+			bounds = []interface{}{v}
+		}
+		if len(bounds) == 0 || len(bounds) > 2 {
+			return nil, errors.New("slice requires [start] or [start, end] bounds")
+		}
+		start, err := strconv.Atoi(nillableStr(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid slice start: %v", err)
+		}
+		step.Start = start
+		if len(bounds) == 2 {
+			end, err := strconv.Atoi(nillableStr(bounds[1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid slice end: %v", err)
+			}
+			step.End = end
+			step.HasEnd = true
+		}
+	default:
+		ops := []string{
+			string(TransformDiv), string(TransformMul), string(TransformAdd), string(TransformSub),
+			string(TransformHex), string(TransformChecksum), string(TransformSlice),
+		}
+		sort.Strings(ops)
+		return nil, fmt.Errorf("unknown op %s, supported: %v", op, ops)
+	}
+	return step, nil
+}
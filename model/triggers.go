@@ -0,0 +1,98 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TriggerEventOutput is the name the event triggering the run of a target is referenced by,
+// like an output of a command: @event.value, @event.txHash.
+const TriggerEventOutput = "event"
+
+// Trigger is the event subscription running the target for each matching event, given
+// as contract.Event or contract.Event(arg=value, ...), with the filters of the logs command.
+type Trigger struct {
+	Contract string
+	Event    string
+	Filters  []*LogFilter
+
+	abiEvent  *abi.Event
+	addresses []common.Address
+}
+
+func ParseTrigger(str string) (*Trigger, error) {
+	head, filters, err := parseEventFilters(str, "trigger")
+	if err != nil {
+		return nil, err
+	}
+	dot := strings.LastIndexByte(head, '.')
+	if dot <= 0 || dot == len(head)-1 {
+		return nil, errors.New("trigger must be contract.Event(arg=value, ...)")
+	}
+	trigger := &Trigger{
+		Contract: head[:dot],
+		Event:    head[dot+1:],
+		Filters:  filters,
+	}
+	return trigger, nil
+}
+
+// parseEventFilters splits Event(arg=value, ...) into the head and the filters of the args.
+func parseEventFilters(str, what string) (string, []*LogFilter, error) {
+	str = strings.TrimSpace(str)
+	head, args := str, ""
+	if i := strings.IndexByte(str, '('); i >= 0 {
+		if !strings.HasSuffix(str, ")") {
+			return "", nil, fmt.Errorf("%s filters must be enclosed in parentheses", what)
+		}
+		head, args = str[:i], str[i+1:len(str)-1]
+	}
+	var filters []*LogFilter
+	for _, arg := range strings.Split(args, ",") {
+		if len(strings.TrimSpace(arg)) == 0 {
+			continue
+		}
+		filter, err := ParseLogFilter(arg)
+		if err != nil {
+			return "", nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return strings.TrimSpace(head), filters, nil
+}
+
+// resolve finds the event and the emitters of the trigger, and checks the filters against the event.
+func (trigger *Trigger) resolve(spec *Spec) error {
+	abiEvent, addresses, err := spec.LookupEvent(trigger.Contract, trigger.Event)
+	if err != nil {
+		return err
+	}
+	if _, err := spec.ResolveLogFilters(abiEvent, trigger.Filters); err != nil {
+		return err
+	}
+	trigger.abiEvent = abiEvent
+	trigger.addresses = addresses
+	return nil
+}
+
+// ABIEvent returns the event of the trigger, resolved during validation.
+func (trigger *Trigger) ABIEvent() *abi.Event {
+	return trigger.abiEvent
+}
+
+// Addresses returns the emitters of the trigger event, resolved during validation.
+func (trigger *Trigger) Addresses() []common.Address {
+	return trigger.addresses
+}
+
+// TargetTrigger returns the event trigger of the target, nil if the target runs once.
+func (spec *Spec) TargetTrigger(name string) *Trigger {
+	if hooks := spec.Hooks[name]; hooks != nil {
+		return hooks.trigger
+	}
+	return nil
+}
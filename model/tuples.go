@@ -0,0 +1,771 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type argKind int
+
+const (
+	argElem argKind = iota
+	argTuple
+	argSlice
+	argArray
+)
+
+// argType is an ABI type which may contain tuples, the vendored ABI encoder has no tuple type.
+// The types without tuples are kept as the types of the encoder, the tuples, the arrays
+// of them and the arrays of dynamic elements are encoded here.
+type argType struct {
+	kind   argKind
+	elem   abi.Type
+	fields []*argType
+	names  []string
+	inner  *argType
+	size   int
+}
+
+// isTupleType reports whether the param type is a tuple or an array of tuples, e.g. (address to,uint256 amount)[]
+// or tuple[] with the components taken from the ABI.
+func isTupleType(typ string) bool {
+	return strings.HasPrefix(typ, "(") || strings.HasPrefix(typ, "tuple")
+}
+
+// parseArgType parses a type of the params and the fragments, where tuples are spelled with
+// their components, like (address to, uint256 amount)[] or tuple(address,uint256).
+func parseArgType(str string) (*argType, error) {
+	str = strings.TrimSpace(str)
+	if strings.HasPrefix(str, "tuple(") {
+		str = str[len("tuple"):]
+	}
+	if !strings.HasPrefix(str, "(") {
+		elem, err := abi.NewType(normalizeType(str))
+		if err != nil {
+			return nil, err
+		}
+		return &argType{kind: argElem, elem: elem}, nil
+	}
+	components, dims, err := splitParens(str)
+	if err != nil {
+		return nil, err
+	}
+	typ := &argType{kind: argTuple}
+	for i, component := range splitComponents(components) {
+		fieldType, rest := cutType(component)
+		if len(fieldType) == 0 {
+			return nil, fmt.Errorf("empty component %d", i)
+		}
+		field, err := parseArgType(fieldType)
+		if err != nil {
+			return nil, fmt.Errorf("component %d: %v", i, err)
+		}
+		var name string
+		for _, word := range strings.Fields(rest) {
+			switch {
+			case word == "memory" || word == "calldata":
+			case len(name) > 0 || !isIdentifier(word):
+				return nil, fmt.Errorf("unexpected %q in component %d", word, i)
+			default:
+				name = word
+			}
+		}
+		typ.fields = append(typ.fields, field)
+		typ.names = append(typ.names, name)
+	}
+	if len(typ.fields) == 0 {
+		return nil, errors.New("tuple has no components")
+	}
+	return typ.withDims(dims)
+}
+
+// argTypeOf returns the type of the JSON ABI param, tuples have their components listed.
+func argTypeOf(param abiParam) (*argType, error) {
+	if !strings.HasPrefix(param.Type, "tuple") {
+		elem, err := abi.NewType(param.Type)
+		if err != nil {
+			return nil, err
+		}
+		return &argType{kind: argElem, elem: elem}, nil
+	}
+	typ := &argType{kind: argTuple}
+	for i, component := range param.Components {
+		field, err := argTypeOf(component)
+		if err != nil {
+			return nil, fmt.Errorf("component %d of %s: %v", i, param.Name, err)
+		}
+		typ.fields = append(typ.fields, field)
+		typ.names = append(typ.names, component.Name)
+	}
+	if len(typ.fields) == 0 {
+		return nil, fmt.Errorf("tuple %s has no components", param.Name)
+	}
+	return typ.withDims(param.Type[len("tuple"):])
+}
+
+// withDims wraps the type into the arrays of the dimensions suffix, like [2][], which is
+// a dynamic array of the arrays of 2.
+func (typ *argType) withDims(dims string) (*argType, error) {
+	for len(dims) > 0 {
+		end := strings.IndexByte(dims, ']')
+		if dims[0] != '[' || end < 0 {
+			return nil, fmt.Errorf("unexpected %q after tuple", dims)
+		}
+		if end == 1 {
+			typ = &argType{kind: argSlice, inner: typ}
+		} else {
+			size, err := strconv.Atoi(dims[1:end])
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("invalid array size %q", dims[1:end])
+			}
+			typ = &argType{kind: argArray, inner: typ, size: size}
+		}
+		dims = dims[end+1:]
+	}
+	return typ, nil
+}
+
+// String is the type with the component names, as in the params, e.g. (address to,uint256 amount)[].
+func (typ *argType) String() string {
+	return typ.format(true)
+}
+
+// canonical is the type as in the function signatures, e.g. (address,uint256)[].
+func (typ *argType) canonical() string {
+	return typ.format(false)
+}
+
+func (typ *argType) format(named bool) string {
+	switch typ.kind {
+	case argTuple:
+		components := make([]string, len(typ.fields))
+		for i, field := range typ.fields {
+			components[i] = field.format(named)
+			if named && len(typ.names[i]) > 0 {
+				components[i] += " " + typ.names[i]
+			}
+		}
+		return "(" + strings.Join(components, ",") + ")"
+	case argSlice:
+		return typ.inner.format(named) + "[]"
+	case argArray:
+		return fmt.Sprintf("%s[%d]", typ.inner.format(named), typ.size)
+	}
+	return normalizeType(typ.elem.String())
+}
+
+// abiParam returns the JSON ABI param of the type, the tuples get their components listed.
+func (typ *argType) abiParam(name string) abiParam {
+	var dims string
+	for typ.kind == argSlice || typ.kind == argArray {
+		if typ.kind == argSlice {
+			dims = "[]" + dims
+		} else {
+			dims = fmt.Sprintf("[%d]", typ.size) + dims
+		}
+		typ = typ.inner
+	}
+	if typ.kind == argElem {
+		return abiParam{Name: name, Type: normalizeType(typ.elem.String()) + dims}
+	}
+	param := abiParam{
+		Name:       name,
+		Type:       "tuple" + dims,
+		Components: make([]abiParam, len(typ.fields)),
+	}
+	for i, field := range typ.fields {
+		param.Components[i] = field.abiParam(typ.names[i])
+	}
+	return param
+}
+
+// dynamic reports whether the encoding of the type is referenced by an offset in the head.
+func (typ *argType) dynamic() bool {
+	switch typ.kind {
+	case argTuple:
+		for _, field := range typ.fields {
+			if field.dynamic() {
+				return true
+			}
+		}
+		return false
+	case argSlice:
+		return true
+	case argArray:
+		return typ.inner.dynamic()
+	}
+	return isDynamicType(typ.elem)
+}
+
+func isDynamicType(typ abi.Type) bool {
+	switch typ.T {
+	case abi.StringTy, abi.BytesTy, abi.SliceTy:
+		return true
+	case abi.ArrayTy:
+		return isDynamicType(*typ.Elem)
+	}
+	return false
+}
+
+// encode encodes the value of the type, the tuples are lists of their component values in order,
+// the arrays of tuples are lists of them, the rest are the Go values of the ABI encoder.
+func (typ *argType) encode(value interface{}) ([]byte, error) {
+	switch typ.kind {
+	case argTuple:
+		values, ok := value.([]interface{})
+		if !ok || len(values) != len(typ.fields) {
+			return nil, fmt.Errorf("expected %d values for %s", len(typ.fields), typ.canonical())
+		}
+		return encodeSequence(typ.fields, values)
+	case argSlice, argArray:
+		values, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a list for %s", typ.canonical())
+		} else if typ.kind == argArray && len(values) != typ.size {
+			return nil, fmt.Errorf("expected %d elements for %s, got %d", typ.size, typ.canonical(), len(values))
+		}
+		types := make([]*argType, len(values))
+		for i := range types {
+			types[i] = typ.inner
+		}
+		data, err := encodeSequence(types, values)
+		if err != nil || typ.kind == argArray {
+			return data, err
+		}
+		length := common.LeftPadBytes(big.NewInt(int64(len(values))).Bytes(), 32)
+		return append(length, data...), nil
+	}
+	if array, ok := typ.dynamicArray(); ok {
+		values, ok := listOf(value)
+		if !ok {
+			return nil, fmt.Errorf("expected a list for %s", typ.canonical())
+		}
+		return array.encode(values)
+	}
+	data, err := abi.Arguments{{Type: typ.elem}}.Pack(value)
+	if err != nil {
+		return nil, err
+	} else if isDynamicType(typ.elem) {
+		// drop the offset of the single packed argument
+		return data[32:], nil
+	}
+	return data, nil
+}
+
+// encodeSequence encodes the values of a tuple or an array: the static values in place
+// in the head, and the offsets of the dynamic ones, which are appended in the tail.
+func encodeSequence(types []*argType, values []interface{}) ([]byte, error) {
+	encoded := make([][]byte, len(values))
+	headSize := 0
+	for i, typ := range types {
+		data, err := typ.encode(values[i])
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = data
+		if typ.dynamic() {
+			headSize += 32
+		} else {
+			headSize += len(data)
+		}
+	}
+	var head, tail []byte
+	for i, typ := range types {
+		if !typ.dynamic() {
+			head = append(head, encoded[i]...)
+			continue
+		}
+		offset := big.NewInt(int64(headSize + len(tail)))
+		head = append(head, common.LeftPadBytes(offset.Bytes(), 32)...)
+		tail = append(tail, encoded[i]...)
+	}
+	return append(head, tail...), nil
+}
+
+// decode decodes the value of the type at the start of the data, the inverse of encode: the tuples
+// and the arrays are lists of their values, the rest are the Go values of the ABI decoder.
+func (typ *argType) decode(data []byte) (interface{}, error) {
+	switch typ.kind {
+	case argTuple:
+		return decodeSequence(typ.fields, data)
+	case argSlice, argArray:
+		count := typ.size
+		if typ.kind == argSlice {
+			length, err := readOffset(data, 0)
+			if err != nil {
+				return nil, fmt.Errorf("length of %s: %v", typ.canonical(), err)
+			} else if length > len(data)/32 {
+				return nil, fmt.Errorf("length %d of %s exceeds the data", length, typ.canonical())
+			}
+			count, data = length, data[32:]
+		}
+		types := make([]*argType, count)
+		for i := range types {
+			types[i] = typ.inner
+		}
+		return decodeSequence(types, data)
+	}
+	if array, ok := typ.dynamicArray(); ok {
+		values, err := array.decode(data)
+		if err != nil {
+			return nil, err
+		}
+		return typedList(typ.elem, values.([]interface{}))
+	}
+	if isDynamicType(typ.elem) {
+		// the decoder expects the offset of the single argument
+		data = append(common.LeftPadBytes([]byte{32}, 32), data...)
+	}
+	values, err := abi.Arguments{{Type: typ.elem}}.UnpackValues(data)
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// dynamicArray returns the array of the ABI encoder type as an array of its elements, when they are
+// dynamic, like string[] or uint256[][]: the vendored encoder packs them without their offsets.
+func (typ *argType) dynamicArray() (*argType, bool) {
+	if typ.kind != argElem || (typ.elem.T != abi.SliceTy && typ.elem.T != abi.ArrayTy) || !isDynamicType(*typ.elem.Elem) {
+		return nil, false
+	}
+	inner := &argType{kind: argElem, elem: *typ.elem.Elem}
+	if typ.elem.T == abi.SliceTy {
+		return &argType{kind: argSlice, inner: inner}, true
+	}
+	return &argType{kind: argArray, inner: inner, size: typ.elem.Size}, true
+}
+
+// listOf returns the elements of a slice or an array value.
+func listOf(value interface{}) ([]interface{}, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+	values := make([]interface{}, v.Len())
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+	}
+	return values, true
+}
+
+// typedList returns the decoded elements as the Go value of the ABI encoder type, e.g. [][]*big.Int.
+func typedList(typ abi.Type, values []interface{}) (interface{}, error) {
+	var list reflect.Value
+	if typ.T == abi.SliceTy {
+		list = reflect.MakeSlice(typ.Type, len(values), len(values))
+	} else {
+		list = reflect.New(typ.Type).Elem()
+	}
+	for i, value := range values {
+		v := reflect.ValueOf(value)
+		if !v.Type().AssignableTo(list.Type().Elem()) {
+			return nil, fmt.Errorf("can't decode %s into %s", v.Type(), list.Type())
+		}
+		list.Index(i).Set(v)
+	}
+	return list.Interface(), nil
+}
+
+// decodeSequence decodes the values of a tuple or an array, following the offsets
+// of the dynamic ones from the start of the sequence.
+func decodeSequence(types []*argType, data []byte) ([]interface{}, error) {
+	values := make([]interface{}, len(types))
+	pos := 0
+	for i, typ := range types {
+		if !typ.dynamic() {
+			if pos+typ.staticSize() > len(data) {
+				return nil, fmt.Errorf("value %d of %s exceeds the data", i, typ.canonical())
+			}
+			value, err := typ.decode(data[pos:])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+			pos += typ.staticSize()
+			continue
+		}
+		offset, err := readOffset(data, pos)
+		if err != nil {
+			return nil, fmt.Errorf("offset of value %d of %s: %v", i, typ.canonical(), err)
+		}
+		value, err := typ.decode(data[offset:])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+		pos += 32
+	}
+	return values, nil
+}
+
+// staticSize is the size of the encoding of a static type.
+func (typ *argType) staticSize() int {
+	switch typ.kind {
+	case argTuple:
+		var size int
+		for _, field := range typ.fields {
+			size += field.staticSize()
+		}
+		return size
+	case argArray:
+		return typ.size * typ.inner.staticSize()
+	}
+	size := 32
+	for elem := typ.elem; elem.T == abi.ArrayTy; elem = *elem.Elem {
+		size *= elem.Size
+	}
+	return size
+}
+
+// readOffset reads the word at the position as an offset or a length, which must not exceed the data.
+func readOffset(data []byte, pos int) (int, error) {
+	if pos+32 > len(data) {
+		return 0, errors.New("data too short")
+	}
+	word := new(big.Int).SetBytes(data[pos : pos+32])
+	if !word.IsInt64() || word.Int64() > int64(len(data)) {
+		return 0, fmt.Errorf("%s exceeds the data", word)
+	}
+	return int(word.Int64()), nil
+}
+
+// PackTuple encodes the values of the tuple type, e.g. the args of a function like
+// ((address target,bool allowFailure,bytes callData)[]), where the tuples and the arrays
+// are lists of their values.
+func PackTuple(typ string, values []interface{}) ([]byte, error) {
+	tuple, err := parseTupleType(typ)
+	if err != nil {
+		return nil, err
+	}
+	return tuple.encode(values)
+}
+
+// UnpackTuple decodes the data of the tuple type, the inverse of PackTuple.
+func UnpackTuple(typ string, data []byte) ([]interface{}, error) {
+	tuple, err := parseTupleType(typ)
+	if err != nil {
+		return nil, err
+	}
+	values, err := tuple.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return values.([]interface{}), nil
+}
+
+func parseTupleType(str string) (*argType, error) {
+	typ, err := parseArgType(str)
+	if err != nil {
+		return nil, fmt.Errorf("type %s: %v", str, err)
+	} else if typ.kind != argTuple {
+		return nil, fmt.Errorf("type %s is not a tuple", str)
+	}
+	return typ, nil
+}
+
+// tupleMethod is a function or the constructor of the ABI with tuple inputs, the bindings get
+// the entry with no inputs, so the calls are packed here.
+type tupleMethod struct {
+	name   string
+	inputs *argType
+	id     []byte
+}
+
+func (method *tupleMethod) sig() string {
+	return method.name + method.inputs.canonical()
+}
+
+// namedInputs are the inputs with the types of the params, the tuples spelled with their components.
+func (method *tupleMethod) namedInputs() []methodInput {
+	inputs := make([]methodInput, len(method.inputs.fields))
+	for i, field := range method.inputs.fields {
+		inputs[i] = methodInput{
+			Name: method.inputs.names[i],
+			Type: field.String(),
+		}
+	}
+	return inputs
+}
+
+// pack encodes the call of the method, the constructor args have no selector.
+func (method *tupleMethod) pack(params []interface{}) ([]byte, error) {
+	values := make([]interface{}, len(params))
+	for i, param := range params {
+		if v, ok := param.(*TupleValue); ok {
+			values[i] = v.value
+			continue
+		}
+		values[i] = param
+	}
+	data, err := method.inputs.encode(values)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, method.id...), data...), nil
+}
+
+// sanitizeABI strips the tuples the vendored ABI parser can't parse off the JSON ABI: the functions and
+// the constructor with tuple inputs are kept without inputs and returned to be packed by the tuple encoder,
+// the functions returning tuples and the events and errors with tuples are dropped, and returned by name
+// with the reason, so the commands using them fail validation.
+func sanitizeABI(abiJSON []byte) ([]byte, map[string]*tupleMethod, map[string]string, error) {
+	var entries []map[string]json.RawMessage
+	if err := json.Unmarshal(abiJSON, &entries); err != nil {
+		return nil, nil, nil, err
+	}
+	methods := make(map[string]*tupleMethod)
+	dropped := make(map[string]string)
+	kept := make([]map[string]json.RawMessage, 0, len(entries))
+	for _, entry := range entries {
+		var kind, name string
+		var inputs, outputs []abiParam
+		for key, v := range map[string]interface{}{
+			"type":    &kind,
+			"name":    &name,
+			"inputs":  &inputs,
+			"outputs": &outputs,
+		} {
+			if data, ok := entry[key]; ok {
+				if err := json.Unmarshal(data, v); err != nil {
+					return nil, nil, nil, fmt.Errorf("malformed %s of ABI entry %s: %v", key, name, err)
+				}
+			}
+		}
+		isMethod := kind == "function" || kind == "" || kind == "constructor"
+		if !hasTupleParam(inputs) && !hasTupleParam(outputs) {
+			if isMethod {
+				// the ABI parser keeps the last of the overloads
+				delete(methods, name)
+			}
+			kept = append(kept, entry)
+			continue
+		} else if !isMethod {
+			dropped[name] = fmt.Sprintf("%s %s has struct args", kind, name)
+			continue
+		} else if hasTupleParam(outputs) {
+			dropped[name] = fmt.Sprintf("function %s returns structs", name)
+			continue
+		}
+		method := &tupleMethod{
+			name:   name,
+			inputs: &argType{kind: argTuple},
+		}
+		for i, input := range inputs {
+			typ, err := argTypeOf(input)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("input %d of %s: %v", i, name, err)
+			}
+			method.inputs.fields = append(method.inputs.fields, typ)
+			method.inputs.names = append(method.inputs.names, input.Name)
+		}
+		if kind != "constructor" {
+			method.id = crypto.Keccak256([]byte(method.sig()))[:4]
+		}
+		methods[name] = method
+		entry["inputs"] = json.RawMessage("[]")
+		kept = append(kept, entry)
+	}
+	if len(kept) == len(entries) && len(methods) == 0 {
+		return abiJSON, nil, nil, nil
+	}
+	data, err := json.Marshal(kept)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return data, methods, dropped, nil
+}
+
+// checkABI checks that the JSON ABI parses, with the tuples checked by the tuple encoder.
+func checkABI(abiJSON []byte) error {
+	sanitized, _, _, err := sanitizeABI(abiJSON)
+	if err != nil {
+		return err
+	}
+	_, err = abi.JSON(bytes.NewReader(sanitized))
+	return err
+}
+
+func hasTupleParam(params []abiParam) bool {
+	for _, param := range params {
+		if strings.HasPrefix(param.Type, "tuple") {
+			return true
+		}
+	}
+	return false
+}
+
+// splitComponents splits the list of params or tuple components by the commas outside of the nested tuples.
+func splitComponents(str string) []string {
+	if len(strings.TrimSpace(str)) == 0 {
+		return nil
+	}
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range str {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, str[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, str[start:])
+}
+
+// cutType splits the param into its type, which may be a tuple with spaces inside, and the rest of it.
+func cutType(param string) (string, string) {
+	param = strings.TrimSpace(param)
+	depth := 0
+	for i, c := range param {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case (c == ' ' || c == '\t') && depth == 0:
+			return param[:i], param[i+1:]
+		}
+	}
+	return param, ""
+}
+
+// TupleValue is the value of a struct param, or of an array of them, given as YAML maps keyed
+// by the component names or as lists of the components in order.
+type TupleValue struct {
+	typ   *argType
+	value interface{}
+
+	// with just tuple as the type, the value is parsed with the components of the ABI
+	raw    interface{}
+	path   string
+	root   *Spec
+	evaler *Evaler
+}
+
+func newTupleValue(root *Spec, evaler *Evaler, typ string, value interface{}, path string) (*TupleValue, error) {
+	v := &TupleValue{
+		raw:    value,
+		path:   path,
+		root:   root,
+		evaler: evaler,
+	}
+	if typ == "tuple" || strings.HasPrefix(typ, "tuple[") {
+		return v, nil
+	}
+	argType, err := parseArgType(typ)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	} else if argType.kind == argElem {
+		return nil, fmt.Errorf("%s: %s is not a tuple", path, typ)
+	}
+	return v, v.parse(argType)
+}
+
+func (v *TupleValue) parse(typ *argType) error {
+	value, err := parseTupleValue(v.root, v.evaler, typ, v.raw, v.path)
+	if err != nil {
+		return err
+	}
+	v.typ, v.value = typ, value
+	return nil
+}
+
+// Value is the value as a list of the component values, or a list of those for the arrays.
+func (v *TupleValue) Value() interface{} {
+	return v.value
+}
+
+// parseTupleValue parses the YAML value of the type into the values encoded by argType, errors name
+// the path of the offending component, e.g. orders[1].maker.
+func parseTupleValue(root *Spec, evaler *Evaler, typ *argType, value interface{}, path string) (interface{}, error) {
+	switch typ.kind {
+	case argElem:
+		v, err := parseArrayValue(root, evaler, typ.elem, value, path)
+		if err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	case argSlice, argArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a list of %s", path, typ.inner)
+		} else if typ.kind == argArray && len(items) != typ.size {
+			return nil, fmt.Errorf("%s: expected %d elements, got %d", path, typ.size, len(items))
+		}
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := parseTupleValue(root, evaler, typ.inner, item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+	values := make([]interface{}, len(typ.fields))
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		used := make(map[string]struct{}, len(v))
+		for i, field := range typ.fields {
+			name := typ.names[i]
+			if len(name) == 0 {
+				return nil, fmt.Errorf("%s: components of %s have no names, use a list", path, typ)
+			}
+			item, ok := v[name]
+			if !ok {
+				return nil, fmt.Errorf("%s.%s: missing %s", path, name, field)
+			}
+			used[name] = struct{}{}
+			parsed, err := parseTupleValue(root, evaler, field, item, path+"."+name)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = parsed
+		}
+		if len(used) != len(v) {
+			var unknown []string
+			for key := range v {
+				if _, ok := used[fmt.Sprint(key)]; !ok {
+					unknown = append(unknown, fmt.Sprint(key))
+				}
+			}
+			sort.Strings(unknown)
+			return nil, fmt.Errorf("%s: unknown fields %s of %s", path, strings.Join(unknown, ", "), typ)
+		}
+	case []interface{}:
+		if len(v) != len(typ.fields) {
+			return nil, fmt.Errorf("%s: expected %d components of %s, got %d", path, len(typ.fields), typ, len(v))
+		}
+		for i, field := range typ.fields {
+			fieldPath := fmt.Sprintf("%s[%d]", path, i)
+			if len(typ.names[i]) > 0 {
+				fieldPath = path + "." + typ.names[i]
+			}
+			parsed, err := parseTupleValue(root, evaler, field, v[i], fieldPath)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = parsed
+		}
+	default:
+		return nil, fmt.Errorf("%s: expected a map or a list for %s", path, typ)
+	}
+	return values, nil
+}
@@ -0,0 +1,240 @@
+package model
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestArgTypeEncode(t *testing.T) {
+	tests := []struct {
+		name      string
+		typ       string
+		canonical string
+		value     interface{}
+		data      []byte
+	}{{
+		name:      "nested static struct",
+		typ:       "(uint256 a, (address b, uint256 c) d)",
+		canonical: "(uint256,(address,uint256))",
+		value:     []interface{}{big.NewInt(1), []interface{}{common.HexToAddress(wallet0), big.NewInt(2)}},
+		data: abiWords(
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"00000000000000000000000017ec8597ff92c3f44523bdc65bf0f1be632917ff",
+			"0000000000000000000000000000000000000000000000000000000000000002",
+		),
+	}, {
+		name:      "dynamic struct",
+		typ:       "tuple(string s,uint256[] v)",
+		canonical: "(string,uint256[])",
+		value:     []interface{}{"abc", []*big.Int{big.NewInt(7), big.NewInt(8)}},
+		data: abiWords(
+			"0000000000000000000000000000000000000000000000000000000000000040",
+			"0000000000000000000000000000000000000000000000000000000000000080",
+			"0000000000000000000000000000000000000000000000000000000000000003",
+			"6162630000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000002",
+			"0000000000000000000000000000000000000000000000000000000000000007",
+			"0000000000000000000000000000000000000000000000000000000000000008",
+		),
+	}, {
+		name:      "nested dynamic struct",
+		typ:       "((string name, uint256[] ids) inner, uint256 n)",
+		canonical: "((string,uint256[]),uint256)",
+		value:     []interface{}{[]interface{}{"ab", []*big.Int{big.NewInt(5)}}, big.NewInt(9)},
+		data: abiWords(
+			"0000000000000000000000000000000000000000000000000000000000000040",
+			"0000000000000000000000000000000000000000000000000000000000000009",
+			"0000000000000000000000000000000000000000000000000000000000000040",
+			"0000000000000000000000000000000000000000000000000000000000000080",
+			"0000000000000000000000000000000000000000000000000000000000000002",
+			"6162000000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"0000000000000000000000000000000000000000000000000000000000000005",
+		),
+	}, {
+		name:      "static struct array",
+		typ:       "(address to, uint256 amount)[]",
+		canonical: "(address,uint256)[]",
+		value: []interface{}{
+			[]interface{}{common.HexToAddress(wallet0), big.NewInt(200)},
+			[]interface{}{common.HexToAddress(wallet1), big.NewInt(300)},
+		},
+		data: abiWords(
+			"0000000000000000000000000000000000000000000000000000000000000002",
+			"00000000000000000000000017ec8597ff92c3f44523bdc65bf0f1be632917ff",
+			"00000000000000000000000000000000000000000000000000000000000000c8",
+			"00000000000000000000000063fc2ad3d021a4d7e64323529a55a9442c444da0",
+			"000000000000000000000000000000000000000000000000000000000000012c",
+		),
+	}, {
+		name:      "fixed struct array",
+		typ:       "(uint256,bool)[2]",
+		canonical: "(uint256,bool)[2]",
+		value:     []interface{}{[]interface{}{big.NewInt(1), true}, []interface{}{big.NewInt(2), false}},
+		data: abiWords(
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"0000000000000000000000000000000000000000000000000000000000000002",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+		),
+	}, {
+		name:      "dynamic struct array",
+		typ:       "(address target, bool allowFailure, bytes callData)[]",
+		canonical: "(address,bool,bytes)[]",
+		value: []interface{}{
+			[]interface{}{common.HexToAddress(wallet0), true, common.FromHex("0x18160ddd")},
+			[]interface{}{common.HexToAddress(wallet1), false, []byte{}},
+		},
+		data: abiWords(
+			"0000000000000000000000000000000000000000000000000000000000000002",
+			"0000000000000000000000000000000000000000000000000000000000000040",
+			"00000000000000000000000000000000000000000000000000000000000000e0",
+			"00000000000000000000000017ec8597ff92c3f44523bdc65bf0f1be632917ff",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"0000000000000000000000000000000000000000000000000000000000000060",
+			"0000000000000000000000000000000000000000000000000000000000000004",
+			"18160ddd00000000000000000000000000000000000000000000000000000000",
+			"00000000000000000000000063fc2ad3d021a4d7e64323529a55a9442c444da0",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000060",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+		),
+	}, {
+		// the args of g(uint256[][],string[]) of the examples of the Solidity ABI spec
+		name:      "solidity spec nested arrays",
+		typ:       "(uint256[][],string[])",
+		canonical: "(uint256[][],string[])",
+		value: []interface{}{
+			[][]*big.Int{{big.NewInt(1), big.NewInt(2)}, {big.NewInt(3)}},
+			[]string{"one", "two", "three"},
+		},
+		data: abiWords(
+			"0000000000000000000000000000000000000000000000000000000000000040",
+			"0000000000000000000000000000000000000000000000000000000000000140",
+			"0000000000000000000000000000000000000000000000000000000000000002",
+			"0000000000000000000000000000000000000000000000000000000000000040",
+			"00000000000000000000000000000000000000000000000000000000000000a0",
+			"0000000000000000000000000000000000000000000000000000000000000002",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"0000000000000000000000000000000000000000000000000000000000000002",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"0000000000000000000000000000000000000000000000000000000000000003",
+			"0000000000000000000000000000000000000000000000000000000000000003",
+			"0000000000000000000000000000000000000000000000000000000000000060",
+			"00000000000000000000000000000000000000000000000000000000000000a0",
+			"00000000000000000000000000000000000000000000000000000000000000e0",
+			"0000000000000000000000000000000000000000000000000000000000000003",
+			"6f6e650000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000003",
+			"74776f0000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000005",
+			"7468726565000000000000000000000000000000000000000000000000000000",
+		),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, err := parseArgType(tt.typ)
+			if err != nil {
+				t.Fatalf("parse %s: %v", tt.typ, err)
+			} else if typ.canonical() != tt.canonical {
+				t.Errorf("got canonical type %s, want %s", typ.canonical(), tt.canonical)
+			}
+			data, err := typ.encode(tt.value)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			} else if !reflect.DeepEqual(data, tt.data) {
+				t.Fatalf("got data %x, want %x", data, tt.data)
+			}
+			value, err := typ.decode(data)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			} else if !reflect.DeepEqual(value, tt.value) {
+				t.Errorf("got decoded value %v, want %v", value, tt.value)
+			}
+		})
+	}
+}
+
+func TestArgTypeEncodeErrors(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value interface{}
+		err   string
+	}{
+		{"(address,uint256)", []interface{}{common.HexToAddress(wallet0)}, "expected 2 values for (address,uint256)"},
+		{"(uint256,bool)[2]", []interface{}{[]interface{}{big.NewInt(1), true}}, "expected 2 elements for (uint256,bool)[2], got 1"},
+		{"(address,uint256)[]", "0x", "expected a list for (address,uint256)[]"},
+		{"(string[2])", []interface{}{[]string{"one"}}, "expected 2 elements for string[2], got 1"},
+		{"((address,uint256) a,bool b)", []interface{}{[]interface{}{}, true}, "expected 2 values for (address,uint256)"},
+	}
+	for _, tt := range tests {
+		typ, err := parseArgType(tt.typ)
+		if err != nil {
+			t.Fatalf("parse %s: %v", tt.typ, err)
+		}
+		if _, err := typ.encode(tt.value); err == nil || err.Error() != tt.err {
+			t.Errorf("encode %s: got error %v, want %s", tt.typ, err, tt.err)
+		}
+	}
+}
+
+func TestUnpackTuple(t *testing.T) {
+	// the output of aggregate3 of Multicall3, a result with a word and a failed call without return data
+	output := abiWords(
+		"0000000000000000000000000000000000000000000000000000000000000020",
+		"0000000000000000000000000000000000000000000000000000000000000002",
+		"0000000000000000000000000000000000000000000000000000000000000040",
+		"00000000000000000000000000000000000000000000000000000000000000c0",
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"0000000000000000000000000000000000000000000000000000000000000040",
+		"0000000000000000000000000000000000000000000000000000000000000020",
+		"00000000000000000000000000000000000000000000000000000000000002ee",
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"0000000000000000000000000000000000000000000000000000000000000040",
+		"0000000000000000000000000000000000000000000000000000000000000000",
+	)
+	tests := []struct {
+		name   string
+		data   []byte
+		values []interface{}
+		err    string
+	}{{
+		name: "aggregate3 output",
+		data: output,
+		values: []interface{}{[]interface{}{
+			[]interface{}{true, common.FromHex("0x00000000000000000000000000000000000000000000000000000000000002ee")},
+			[]interface{}{false, []byte{}},
+		}},
+	}, {
+		name: "truncated",
+		data: output[:32*9],
+		err:  "offset of value 1 of bytes: data too short",
+	}, {
+		name: "array offset past the data",
+		data: replaceWord(append([]byte{0, 0, 0, 0}, output...), 0, "0x0000000000000000000000000000000000000000000000000000000000000400")[4:],
+		err:  "offset of value 0 of (bool,bytes)[]: 1024 exceeds the data",
+	}, {
+		name: "length past the data",
+		data: replaceWord(append([]byte{0, 0, 0, 0}, output...), 1, "0x0000000000000000000000000000000000000000000000000000000000000100")[4:],
+		err:  "length 256 of (bool,bytes)[] exceeds the data",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := UnpackTuple("((bool success, bytes returnData)[])", tt.data)
+			if len(tt.err) > 0 {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %s", err, tt.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("unpack: %v", err)
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Fatalf("got values %v, want %v", values, tt.values)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func weiUnit(exp int64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(exp), nil)
+}
+
+// weiUnits are the ether denominations accepted as suffixes of wei amounts, e.g. 20 gwei or 1.5 eth.
+var weiUnits = map[string]*big.Int{
+	"wei":        weiUnit(0),
+	"kwei":       weiUnit(3),
+	"babbage":    weiUnit(3),
+	"mwei":       weiUnit(6),
+	"lovelace":   weiUnit(6),
+	"gwei":       weiUnit(9),
+	"shannon":    weiUnit(9),
+	"szabo":      weiUnit(12),
+	"microether": weiUnit(12),
+	"finney":     weiUnit(15),
+	"milliether": weiUnit(15),
+	"ether":      weiUnit(18),
+	"eth":        weiUnit(18),
+}
+
+// parseWei parses the amount with an optional unit, e.g. 10 gwei, 500 finney or 0.5 ether.
+func parseWei(str string) (*big.Int, error) {
+	parts := strings.Fields(strings.ToLower(str))
+	if len(parts) == 0 || len(parts) > 2 {
+		return nil, errors.New("must be an amount with optional unit, e.g. 20 gwei or 1.5 eth")
+	}
+	unit := weiUnits["wei"]
+	if len(parts) == 2 {
+		var ok bool
+		if unit, ok = weiUnits[parts[1]]; !ok {
+			return nil, fmt.Errorf("unknown unit %s, must be one of wei, gwei, szabo, finney, ether, eth", parts[1])
+		}
+	}
+	amount, ok := new(big.Rat).SetString(parts[0])
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("not an amount: %s", parts[0])
+	}
+	return denominateAmount(amount, unit)
+}
+
+// denominateAmount converts the amount in units into wei, which must be whole.
+func denominateAmount(amount *big.Rat, unit *big.Int) (*big.Int, error) {
+	wei := new(big.Rat).Mul(amount, new(big.Rat).SetInt(unit))
+	if !wei.IsInt() {
+		return nil, fmt.Errorf("amount %s is a fraction of wei", strings.TrimRight(amount.FloatString(18), "0"))
+	}
+	return new(big.Int).Set(wei.Num()), nil
+}
+
+// FormatEther formats the amount in wei as ether, without trailing zeros, e.g. 0.0021.
+func FormatEther(wei *big.Int) string {
+	return FormatUnits(wei, 18)
+}
+
+// FormatUnits formats the amount in the smallest units of a token with the decimals, e.g. 1.5 USDC.
+func FormatUnits(amount *big.Int, decimals int) string {
+	if amount == nil {
+		return "0"
+	} else if decimals == 0 {
+		return amount.String()
+	}
+	str := new(big.Rat).SetFrac(amount, weiUnit(int64(decimals))).FloatString(decimals)
+	return strings.TrimSuffix(strings.TrimRight(str, "0"), ".")
+}
+
+// ParseUnits parses the amount in units of a token with the decimals into the smallest units, e.g. 1.5 USDC.
+func ParseUnits(str string, decimals int) (*big.Int, error) {
+	amount, ok := new(big.Rat).SetString(str)
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("not an amount: %s", str)
+	}
+	return denominateAmount(amount, weiUnit(int64(decimals)))
+}
+
+// Duration is a period of time, or a number of blocks, which is
+// converted to time using the block time of the network.
+type Duration struct {
+	Time   time.Duration
+	Blocks uint64
+}
+
+var daySuffixes = []string{"d", " day", " days"}
+
+// ParseDuration parses durations like 90s, 2h, 1h30m, 7d or 12 blocks.
+func ParseDuration(str string) (*Duration, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	if parts := strings.Fields(str); len(parts) == 2 && (parts[1] == "blocks" || parts[1] == "block") {
+		blocks, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("not a number of blocks: %s", parts[0])
+		}
+		return &Duration{Blocks: blocks}, nil
+	}
+	for _, suffix := range daySuffixes {
+		if !strings.HasSuffix(str, suffix) {
+			continue
+		}
+		days, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(str, suffix)), 64)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("not a number of days: %s", str)
+		}
+		return &Duration{Time: time.Duration(days * float64(24*time.Hour))}, nil
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return nil, err
+	}
+	return &Duration{Time: d}, nil
+}
+
+// Of returns the duration in time, blocks are converted using the block time.
+func (d *Duration) Of(blockTime time.Duration) time.Duration {
+	if d.Blocks > 0 {
+		return time.Duration(d.Blocks) * blockTime
+	}
+	return d.Time
+}
+
+func (d *Duration) String() string {
+	if d.Blocks == 1 {
+		return "1 block"
+	} else if d.Blocks > 0 {
+		return fmt.Sprintf("%d blocks", d.Blocks)
+	}
+	return d.Time.String()
+}
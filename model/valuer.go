@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"regexp"
 	"strings"
 
 	"github.com/AtlantPlatform/ethfw"
@@ -42,6 +43,7 @@ func (v Valuer) Parse(ctx AppContext, root *Spec, additionalDenominators []strin
 	valueStr = strings.Join(valueStrParts, " ")
 
 	denomintators := append(append([]string{}, commonDenominations...), additionalDenominators...)
+	denomintators = append(denomintators, root.Tokens.denominators()...)
 	valueStr = strings.ToLower(valueStr)
 	var valueDenomintator string
 	for _, den := range denomintators {
@@ -58,22 +60,96 @@ func (v Valuer) Parse(ctx AppContext, root *Spec, additionalDenominators []strin
 		err := fmt.Errorf("not a math expression in value string: %s", valueStr)
 		return nil, err
 	}
-	evaler := NewEvaler()
 	var value *big.Int
+	if unit, ok := root.denominatorUnit(valueDenomintator); ok {
+		// decimal amounts of ether units and tokens, e.g. 1.5 eth or 0.5 DAI, are converted exactly
+		if amount, ok := new(big.Rat).SetString(strings.TrimSpace(valueStr)); ok {
+			wei, err := denominateAmount(amount, unit)
+			if err != nil {
+				return nil, err
+			}
+			value = wei
+		}
+	}
+	if value == nil {
+		evaler := NewEvaler()
+		result, err := evaler.Run(valueStr, ExprTypeInterger)
+		if err != nil {
+			return nil, err
+		}
+		value = result.(*big.Int)
+		if unit, ok := root.denominatorUnit(valueDenomintator); ok {
+			value = new(big.Int).Mul(value, unit)
+		}
+	}
+	extended := &ExtendedValue{
+		Value:       value,
+		ValueWei:    ethfw.BigWei(value),
+		Denominator: valueDenomintator,
+	}
+	return extended, nil
+}
 
-	result, err := evaler.Run(valueStr, ExprTypeInterger)
-	if err != nil {
-		return nil, err
+var (
+	valueDenominatorRx = regexp.MustCompile(`\s+([a-zA-Z]\w*)$`)
+	// valueRefRx finds the function calls and the references in the value
+	valueRefRx = regexp.MustCompile(`\b(?:len|sum|min|max|abs)\(|@[\w/-]+(\.\w+)?|\$\d+`)
+)
+
+// expression returns the value without the denominator, if it's an expression
+// over outputs of other commands or with function calls, which are evaluated lazily.
+func (v Valuer) expression(root *Spec) (string, bool) {
+	valueStr := strings.TrimSpace(string(v))
+	if m := valueDenominatorRx.FindStringSubmatchIndex(valueStr); m != nil {
+		valueStr = valueStr[:m[0]]
 	}
-	value = result.(*big.Int)
+	for _, ref := range valueRefRx.FindAllString(valueStr, -1) {
+		if !isWalletRef(ref) && !isArgRef(ref) {
+			return valueStr, true // function call
+		} else if _, ok := newCommandOutputReference(root, ref); ok && isWalletRef(ref) {
+			return valueStr, true
+		}
+	}
+	return "", false
+}
 
-	if len(valueDenomintator) > 0 {
-		value = denominateValue(value, valueDenomintator)
+// Denominator returns the trailing denominator of the value, e.g. gwei or a token symbol.
+func (v Valuer) Denominator() string {
+	if m := valueDenominatorRx.FindStringSubmatch(strings.TrimSpace(string(v))); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// NewExtendedValue converts the result of the value expression, an integer or a float,
+// into the value of the denominator, amounts of ether units are converted into wei and
+// amounts of tokens into the smallest units.
+func NewExtendedValue(root *Spec, result interface{}, denominator string) (*ExtendedValue, error) {
+	var amount *big.Rat
+	switch r := result.(type) {
+	case *big.Int:
+		amount = new(big.Rat).SetInt(r)
+	case *big.Float:
+		amount, _ = r.Rat(nil)
+	default:
+		return nil, fmt.Errorf("value expression must yield a number, got %T", result)
+	}
+	value := new(big.Int)
+	if unit, ok := root.denominatorUnit(denominator); ok {
+		wei, err := denominateAmount(amount, unit)
+		if err != nil {
+			return nil, err
+		}
+		value = wei
+	} else if !amount.IsInt() {
+		return nil, fmt.Errorf("value %s is not an integer", amount.FloatString(18))
+	} else {
+		value.Set(amount.Num())
 	}
 	extended := &ExtendedValue{
 		Value:       value,
 		ValueWei:    ethfw.BigWei(value),
-		Denominator: valueDenomintator,
+		Denominator: denominator,
 	}
 	return extended, nil
 }
@@ -96,35 +172,15 @@ type ExtendedValue struct {
 	Denominator string
 }
 
-func denominateValue(val *big.Int, denominator string) *big.Int {
-	switch denominator {
-	case "wei", "eth":
-		return val
-	case "gwei":
-		v := big.NewInt(1)
-		v = v.Mul(val, big.NewInt(1e9))
-		v = v.Mul(v, val)
-		return v
-	case "ether":
-		v := big.NewInt(1)
-		v = v.Mul(v, big.NewInt(1e9)).Mul(v, big.NewInt(1e9))
-		v = v.Mul(v, val)
-		return v
-	default:
-		return val
-	}
-}
-
 func IsCommonDenominator(name string) bool {
-	for _, den := range commonDenominations {
-		if den == name {
-			return true
-		}
-	}
-	return false
+	_, ok := weiUnits[name]
+	return ok
 }
 
-var commonDenominations = []string{
-	"wei", "gwei", "ether",
-	"eth",
-}
+var commonDenominations = func() []string {
+	names := make([]string, 0, len(weiUnits))
+	for name := range weiUnits {
+		names = append(names, name)
+	}
+	return names
+}()
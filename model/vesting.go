@@ -0,0 +1,126 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Kinds of the vesting contracts.
+const (
+	// VestingOpenZeppelin is a VestingWallet of OpenZeppelin, or VestingWalletCliff, vesting
+	// its balance of ether or of a token to the beneficiary.
+	VestingOpenZeppelin = "openzeppelin"
+	// VestingSablier is a linear stream of a Sablier lockup contract, by the stream ID.
+	VestingSablier = "sablier"
+)
+
+// Vesting are the vesting and streaming schedules of the spec wallets, keyed by name.
+type Vesting map[string]*VestingSpec
+
+// VestingSpec is a schedule vesting ether or a token of the spec to the Wallet, the one releasing it:
+// a vesting wallet at Address, or the stream of StreamID of the Sablier contract at Address, which
+// streams the Token.
+type VestingSpec struct {
+	Kind     string `yaml:"kind"`
+	Address  string `yaml:"address"`
+	StreamID uint64 `yaml:"streamId"`
+	Token    string `yaml:"token"`
+	Wallet   string `yaml:"wallet"`
+
+	wallet *WalletSpec `yaml:"-"`
+	token  *TokenSpec  `yaml:"-"`
+}
+
+func (vesting Vesting) Validate(ctx AppContext, spec *Spec) bool {
+	for name, schedule := range vesting {
+		validateLog := log.WithFields(log.Fields{
+			"section":  "Vesting",
+			"schedule": name,
+		})
+		if schedule == nil {
+			validateLog.Errorln("schedule has no spec")
+			return false
+		} else if err := schedule.validate(spec); err != nil {
+			validateLog.WithError(err).Errorln("invalid schedule")
+			return false
+		}
+	}
+	return true
+}
+
+func (spec *VestingSpec) validate(root *Spec) error {
+	if len(spec.Kind) == 0 {
+		spec.Kind = VestingOpenZeppelin
+	}
+	switch spec.Kind {
+	case VestingOpenZeppelin:
+		if spec.StreamID != 0 {
+			return errors.New("streamId is set for Sablier streams only")
+		}
+	case VestingSablier:
+		if spec.StreamID == 0 {
+			return errors.New("Sablier stream has no streamId")
+		} else if len(spec.Token) == 0 {
+			return errors.New("Sablier stream has no token")
+		}
+	default:
+		return fmt.Errorf("unknown kind %s, must be %s or %s", spec.Kind, VestingOpenZeppelin, VestingSablier)
+	}
+	if !common.IsHexAddress(spec.Address) {
+		return fmt.Errorf("address %q of the contract is not valid", spec.Address)
+	}
+	var ok bool
+	if spec.wallet, ok = root.Wallets.WalletSpec(spec.Wallet); !ok {
+		return fmt.Errorf("wallet %s of the schedule is not found", spec.Wallet)
+	} else if !common.IsHexAddress(spec.wallet.Address) {
+		return fmt.Errorf("wallet %s has no address", spec.Wallet)
+	}
+	if len(spec.Token) > 0 {
+		var err error
+		if spec.token, err = root.FindToken(spec.Token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BeneficiaryWallet is the wallet the schedule vests to, which releases the vested amounts.
+func (spec *VestingSpec) BeneficiaryWallet() *WalletSpec {
+	return spec.wallet
+}
+
+// TokenSpec is the token of the schedule, nil for ether.
+func (spec *VestingSpec) TokenSpec() *TokenSpec {
+	return spec.token
+}
+
+// Asset is the symbol of the vested asset, ETH for ether.
+func (spec *VestingSpec) Asset() string {
+	if spec.token == nil {
+		return "ETH"
+	}
+	return strings.ToUpper(spec.token.Symbol)
+}
+
+// Decimals are the decimals of the asset.
+func (spec *VestingSpec) Decimals() int {
+	if spec.token == nil || spec.token.Decimals == nil {
+		return 18
+	}
+	return *spec.token.Decimals
+}
+
+// Names returns the names of the schedules, sorted.
+func (vesting Vesting) Names() []string {
+	names := make([]string, 0, len(vesting))
+	for name := range vesting {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
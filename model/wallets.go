@@ -27,7 +27,7 @@ type Wallets map[string]*WalletSpec
 
 func (wallets Wallets) Validate(ctx AppContext, spec *Spec) bool {
 	for name, wallet := range wallets {
-		if !wallet.Validate(ctx, name) {
+		if !wallet.Validate(ctx, name, spec) {
 			return false
 		}
 	}
@@ -36,7 +36,7 @@ func (wallets Wallets) Validate(ctx AppContext, spec *Spec) bool {
 
 func (wallets Wallets) NameOf(address string) string {
 	for name, wallet := range wallets {
-		if wallet.Address == address {
+		if strings.EqualFold(wallet.Address, address) {
 			return name
 		}
 	}
@@ -71,6 +71,22 @@ func (wallets Wallets) GetAll(rx *regexp.Regexp) []*WalletSpec {
 	return specs
 }
 
+// inNamespace returns the wallets of the imported playbook by their names within its namespace,
+// so the wallet regexps of its commands match its own wallets only. All wallets if ns is empty.
+func (wallets Wallets) inNamespace(ns string) Wallets {
+	if len(ns) == 0 {
+		return wallets
+	}
+	prefix := ns + namespaceDelim
+	scoped := make(Wallets)
+	for name, wallet := range wallets {
+		if strings.HasPrefix(name, prefix) {
+			scoped[strings.TrimPrefix(name, prefix)] = wallet
+		}
+	}
+	return scoped
+}
+
 func (wallets Wallets) WalletSpec(name string) (*WalletSpec, bool) {
 	spec, ok := wallets[name]
 	return spec, ok
@@ -85,16 +101,27 @@ type WalletSpec struct {
 	Balance  *big.Int `yaml:"-"`
 
 	privKey *ecdsa.PrivateKey `yaml:"-"`
+	ensName string            `yaml:"-"`
 }
 
-func (spec *WalletSpec) Validate(ctx AppContext, name string) bool {
+func (spec *WalletSpec) Validate(ctx AppContext, name string, root *Spec) bool {
 	validateLog := log.WithFields(log.Fields{
 		"section": "Wallets",
 		"wallet":  name,
 	})
+	if isENSName(spec.Address) {
+		address, err := root.ResolveENS(ctx, spec.Address)
+		if err != nil {
+			validateLog.WithError(err).Errorln("failed to resolve wallet ENS name")
+			return false
+		}
+		spec.ensName = spec.Address
+		spec.Address = strings.ToLower(address.Hex())
+		validateLog = validateLog.WithField("ens", spec.ensName)
+	}
 	if len(spec.Address) > 0 {
 		if spec.Address != ZeroAddress && !common.IsHexAddress(spec.Address) {
-			validateLog.Errorln("address is not valid (must be hex string starting from 0x)")
+			validateLog.Errorln("address is not valid (must be hex string starting from 0x, or ENS name)")
 			return false
 		}
 	}
@@ -255,6 +282,11 @@ func (spec *WalletSpec) Validate(ctx AppContext, name string) bool {
 	return true
 }
 
+// ENSName is the ENS name the wallet address has been resolved from.
+func (spec *WalletSpec) ENSName() string {
+	return spec.ensName
+}
+
 func (spec *WalletSpec) PrivKeyECDSA() *ecdsa.PrivateKey {
 	return spec.privKey
 }
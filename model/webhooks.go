@@ -0,0 +1,125 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Types of the notifications posted to the webhooks.
+const (
+	NotifyBlock            = "block"
+	NotifyEvent            = "event"
+	NotifyCommandCompleted = "command.completed"
+	NotifyCommandFailed    = "command.failed"
+	NotifyAlert            = "alert"
+)
+
+// WebhookSignatureHeader is the header of the HMAC-SHA256 signature of the payload, as sha256=<hex>.
+const WebhookSignatureHeader = "X-Playbook-Signature"
+
+// WebhookSpec is the URL the notifications of the Types are posted to, all but the blocks by default.
+// With the Secret, the payload is signed, so the receiver can check it has been sent by the playbook.
+type WebhookSpec struct {
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"`
+	Types  []string `yaml:"types"`
+}
+
+// Notification is the JSON payload of the webhooks, the fields set depend on the type: the blocks have
+// the number, the events the transaction, the emitter and the args, the commands the transactions,
+// and the error of the failed ones, the alerts the event along with the rule, its severity and the caller,
+// or the wallet below the min balance of a fund rule with its balance and the top-up in the smallest units.
+type Notification struct {
+	Type      string                 `json:"type"`
+	Time      time.Time              `json:"time"`
+	NodeGroup string                 `json:"nodeGroup"`
+	Target    string                 `json:"target,omitempty"`
+	Command   string                 `json:"command,omitempty"`
+	Block     uint64                 `json:"block,omitempty"`
+	TxHash    string                 `json:"txHash,omitempty"`
+	Address   string                 `json:"address,omitempty"`
+	Event     string                 `json:"event,omitempty"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+	Txs       []string               `json:"txs,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Rule      string                 `json:"rule,omitempty"`
+	Severity  string                 `json:"severity,omitempty"`
+	Caller    string                 `json:"caller,omitempty"`
+	Asset     string                 `json:"asset,omitempty"`
+	Balance   string                 `json:"balance,omitempty"`
+	Amount    string                 `json:"amount,omitempty"`
+}
+
+var notificationTypes = map[string]bool{
+	NotifyBlock:            true,
+	NotifyEvent:            true,
+	NotifyCommandCompleted: true,
+	NotifyCommandFailed:    true,
+	NotifyAlert:            true,
+}
+
+func (spec *WebhookSpec) validate() error {
+	if u, err := url.Parse(spec.URL); err != nil || len(u.Host) == 0 {
+		return errors.New("webhook must have a valid url")
+	}
+	for _, typ := range spec.Types {
+		if !notificationTypes[typ] {
+			return fmt.Errorf("unknown notification type %s, must be block, event, command.completed, command.failed or alert", typ)
+		}
+	}
+	return nil
+}
+
+// Accepts reports whether the notifications of the type are posted to the webhook.
+func (spec *WebhookSpec) Accepts(typ string) bool {
+	if len(spec.Types) == 0 {
+		return typ != NotifyBlock
+	}
+	for _, accepted := range spec.Types {
+		if accepted == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Post sends the notification to the webhook, signed with the secret if set.
+func (spec *WebhookSpec) Post(ctx context.Context, notification *Notification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", spec.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(spec.Secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(spec.Secret, data))
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with status %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// SignWebhookPayload returns the hex of the HMAC-SHA256 of the payload with the secret.
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// wrappedNatives are the canonical wrapped native tokens (WETH, WMATIC, WBNB, etc.) by chainID.
+var wrappedNatives = map[string]string{
+	"1":         "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", // Ethereum
+	"10":        "0x4200000000000000000000000000000000000006", // OP Mainnet
+	"56":        "0xbb4CdB9CBd36B01bD1cBaEBF2De08d9173bc095c", // BNB Smart Chain
+	"100":       "0xe91D153E0b41518A2Ce8Dd3D7944Fa863463a97d", // Gnosis
+	"137":       "0x0d500B1d8E8eF31E21C99d1Db9A6444d3ADf1270", // Polygon
+	"324":       "0x5AEa5775959fBC2557Cc8789bC1bf90A239D9a91", // zkSync Era
+	"8453":      "0x4200000000000000000000000000000000000006", // Base
+	"34443":     "0x4200000000000000000000000000000000000006", // Mode
+	"42161":     "0x82aF49447D8a07e3bd95BD0d56f35241523fBab1", // Arbitrum One
+	"43114":     "0xB31f66AA3C1e785363F0875A1B74E27b85FD66c7", // Avalanche
+	"59144":     "0xe5D7C2a44FfDDf6b295A15c148167daaAf5Cf34f", // Linea
+	"534352":    "0x5300000000000000000000000000000000000004", // Scroll
+	"7777777":   "0x4200000000000000000000000000000000000006", // Zora
+	"11155111":  "0xfFf9976782d46CC05630D1f6eBAb18b2324d6B14", // Sepolia
+	"11155420":  "0x4200000000000000000000000000000000000006", // OP Sepolia
+	"84532":     "0x4200000000000000000000000000000000000006", // Base Sepolia
+	"999999999": "0x4200000000000000000000000000000000000006", // Zora Sepolia
+}
+
+// WrappedNative is the wrapped native token of the chain, wrappedNativeAddress or the canonical one.
+func (spec *ConfigSpec) WrappedNative() (common.Address, error) {
+	if len(spec.WrappedNativeAddress) > 0 {
+		return common.HexToAddress(spec.WrappedNativeAddress), nil
+	} else if address, ok := wrappedNatives[spec.ChainID]; ok {
+		return common.HexToAddress(address), nil
+	}
+	return common.Address{}, fmt.Errorf("no wrapped native token known for chain %s, set wrappedNativeAddress", spec.ChainID)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+)
+
+// AlertRecord is the alert of the monitor command in the JSON output, one object per line.
+type AlertRecord struct {
+	Rule     string                 `json:"rule"`
+	Severity string                 `json:"severity"`
+	Block    uint64                 `json:"block"`
+	TxHash   string                 `json:"txHash"`
+	LogIndex uint                   `json:"logIndex"`
+	Address  string                 `json:"address"`
+	Contract string                 `json:"contract,omitempty"`
+	Event    string                 `json:"event"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+	Caller   string                 `json:"caller,omitempty"`
+	Labels   map[string]string      `json:"labels,omitempty"`
+}
+
+// checkAlertFormat allows the formats the alerts can be streamed in, a line per alert.
+func checkAlertFormat(format string) error {
+	if format != OutputText && format != OutputJSON {
+		return errors.New("alerts are streamed as text or JSON lines")
+	}
+	return nil
+}
+
+// writeAlert writes the alert as a text line, or as a JSON object on a line.
+func writeAlert(w io.Writer, format string, alert *executor.Alert) error {
+	if format == OutputJSON {
+		data, err := json.Marshal(&AlertRecord{
+			Rule:     alert.Rule,
+			Severity: alert.Severity,
+			Block:    alert.Block,
+			TxHash:   alert.TxHash,
+			LogIndex: alert.LogIndex,
+			Address:  alert.Address,
+			Contract: alert.Contract,
+			Event:    alert.Event.Event,
+			Args:     alert.Args,
+			Caller:   alert.Caller,
+			Labels:   alert.Labels,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	emitter := alert.Address
+	if len(alert.Contract) > 0 {
+		emitter = fmt.Sprintf("%s (%s)", alert.Address, alert.Contract)
+	}
+	event := alert.Event.Event
+	if len(event) == 0 {
+		event = "unknown event"
+	}
+	if len(alert.Caller) > 0 {
+		event = fmt.Sprintf("%s by %s", event, alert.Caller)
+	}
+	_, err := fmt.Fprintf(w, "%s\t%s\t%d\t%s:%d\t%s\t%s\n", alert.Severity, alert.Rule,
+		alert.Block, alert.TxHash, alert.LogIndex, emitter, event)
+	return err
+}
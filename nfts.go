@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+)
+
+// NFTRecord is the owned token in the machine-readable output formats.
+type NFTRecord struct {
+	TokenID string `json:"tokenId" yaml:"tokenId"`
+	Amount  string `json:"amount" yaml:"amount"`
+}
+
+// writeNFTs writes the owned tokens in the format to the output file, or to stdout if not set.
+func writeNFTs(format, path string, owned []*executor.OwnedNFT) error {
+	w, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	records := make([]*NFTRecord, 0, len(owned))
+	for _, nft := range owned {
+		records = append(records, &NFTRecord{
+			TokenID: nft.TokenID.String(),
+			Amount:  nft.Amount.String(),
+		})
+	}
+	switch format {
+	case OutputText:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "TOKEN ID\tAMOUNT\t")
+		for _, record := range records {
+			fmt.Fprintf(tw, "%s\t%s\t\n", record.TokenID, record.Amount)
+		}
+		return tw.Flush()
+	case OutputCSV:
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write([]string{"tokenId", "amount"}); err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := csvWriter.Write([]string{record.TokenID, record.Amount}); err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return writeStructured(w, format, records)
+}
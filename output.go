@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+	"github.com/AtlantPlatform/yaml"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+	OutputCSV  = "csv"
+)
+
+// ResultRecord is the stable schema of a command result in the machine-readable output formats.
+type ResultRecord struct {
+	Command  string            `json:"command"`
+	Wallet   string            `json:"wallet,omitempty"`
+	Network  string            `json:"network"`
+	TxHash   string            `json:"txHash,omitempty"`
+	TxHashes []string          `json:"txHashes,omitempty"`
+	GasUsed  uint64            `json:"gasUsed,omitempty"`
+	Result   interface{}       `json:"result,omitempty"`
+	Events   []*executor.Event `json:"events,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Skipped  string            `json:"skipped,omitempty"`
+}
+
+var csvHeader = []string{"command", "wallet", "network", "txHash", "gasUsed", "result", "events", "error", "skipped"}
+
+func checkOutputFormat(format string) error {
+	switch format {
+	case OutputText, OutputJSON, OutputYAML, OutputCSV:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %s, must be text, json, yaml or csv", format)
+}
+
+// outputNetwork is the name of the network overlay, or the node group if none is applied.
+func outputNetwork(ctx model.AppContext) string {
+	if len(*network) > 0 {
+		return *network
+	}
+	return ctx.NodeGroup()
+}
+
+// resultRecords converts the results of the command to the output records, a transaction
+// handle returned by the write command goes to txHash rather than the result.
+func resultRecords(spec *model.Spec, networkName, name string, results []*executor.CommandResult) []*ResultRecord {
+	_, isWrite := spec.WriteCmds[name]
+	records := make([]*ResultRecord, 0, len(results))
+	for _, result := range results {
+		record := &ResultRecord{
+			Command: name,
+			Wallet:  result.Wallet,
+			Network: networkName,
+			GasUsed: result.GasUsed,
+			Events:  result.Events,
+			Skipped: result.Skipped,
+		}
+		if result.Error != nil {
+			record.Error = result.Error.Error()
+		}
+		if handle, ok := result.Result.(string); ok && isWrite && len(result.Txs) == 0 {
+			record.TxHash = strings.TrimPrefix(handle, "tx:")
+		} else {
+			record.Result = prettify(result.Result)
+		}
+		for _, tx := range result.Txs {
+			record.TxHashes = append(record.TxHashes, strings.TrimPrefix(tx, "tx:"))
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// stdoutOutput is stdout as the output of a report, it's not closed once the report is written.
+type stdoutOutput struct {
+	io.Writer
+}
+
+func (stdoutOutput) Close() error {
+	return nil
+}
+
+// openOutput creates the output file of a report, or returns stdout if the path is not set.
+func openOutput(path string) (io.WriteCloser, error) {
+	if len(path) == 0 {
+		return stdoutOutput{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+// writeStructured writes the records of a report as indented JSON or as YAML, the reports only
+// write their text and CSV formats themselves.
+func writeStructured(w io.Writer, format string, records interface{}) error {
+	switch format {
+	case OutputJSON:
+		data, err := json.MarshalIndent(records, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case OutputYAML:
+		data, err := yaml.Marshal(records)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	if err := checkOutputFormat(format); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s output is not supported by the report", format)
+}
+
+// writeResultRecords writes the records in the format to the output file, or to stdout if not set.
+func writeResultRecords(format, path string, records []*ResultRecord) error {
+	w, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if records == nil {
+		records = []*ResultRecord{}
+	}
+	switch format {
+	case OutputJSON:
+		return writeStructured(w, format, records)
+	case OutputYAML:
+		// JSON is valid YAML, so the field names and order of the JSON schema are kept
+		data, err := json.Marshal(records)
+		if err != nil {
+			return err
+		}
+		var doc []yaml.MapSlice
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(doc); err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case OutputCSV:
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, record := range records {
+			txHash := record.TxHash
+			if len(record.TxHashes) > 0 {
+				txHash = strings.Join(record.TxHashes, " ")
+			}
+			var result, events string
+			if record.Result != nil {
+				result = csvValue(record.Result)
+			}
+			if len(record.Events) > 0 {
+				events = csvValue(record.Events)
+			}
+			var gasUsed string
+			if record.GasUsed > 0 {
+				gasUsed = strconv.FormatUint(record.GasUsed, 10)
+			}
+			row := []string{record.Command, record.Wallet, record.Network, txHash,
+				gasUsed, result, events, record.Error, record.Skipped}
+			if err := csvWriter.Write(row); err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return checkOutputFormat(format)
+}
+
+// csvValue formats the value for a CSV cell, strings as is and anything else as JSON.
+func csvValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// printPlan prints the transactions planned by the write commands in order, terraform-plan style,
+// the read commands are listed by name only. It returns the exit code, a transaction that
+// can't be estimated is a simulation failure.
+func printPlan(spec *model.Spec, title string, results [][]*executor.CommandResult) int {
+	var (
+		txCount     int
+		gasTotal    uint64
+		unestimated int
+	)
+	fmt.Printf("Plan of %s:\n\n", title)
+	for _, cmdResults := range results {
+		if len(cmdResults) == 0 {
+			continue
+		}
+		name := cmdResults[0].Name
+		for _, result := range cmdResults {
+			switch {
+			case result.Error != nil:
+				fmt.Printf("  ! %s: %v\n", name, result.Error)
+			case len(result.Skipped) > 0:
+				fmt.Printf("  - %s: skipped, %s\n", name, result.Skipped)
+			default:
+				txs, ok := result.Result.([]*executor.PlannedTx)
+				if !ok {
+					fmt.Printf("  = %s (read)\n", name)
+					continue
+				}
+				for _, tx := range txs {
+					printPlannedTx(spec, name, tx)
+					txCount++
+					if len(tx.GasError) > 0 {
+						unestimated++
+					}
+					gasTotal += tx.Gas
+				}
+			}
+		}
+	}
+	fmt.Printf("\nPlan: %d transactions, %d gas estimated", txCount, gasTotal)
+	if unestimated > 0 {
+		fmt.Printf(", %d not estimated", unestimated)
+	}
+	fmt.Println(".")
+	if code := exitCode(results, false); code != exitOK {
+		return code
+	} else if unestimated > 0 {
+		return exitSimulation
+	}
+	return exitOK
+}
+
+func printPlannedTx(spec *model.Spec, name string, tx *executor.PlannedTx) {
+	fmt.Printf("  + %s\n", name)
+	printField := func(field, value string) {
+		if len(value) > 0 {
+			fmt.Printf("      %-8s %s\n", field+":", value)
+		}
+	}
+	from := tx.From
+	if walletName := spec.Wallets.NameOf(tx.From); len(walletName) > 0 {
+		from = fmt.Sprintf("%s (@%s)", tx.From, walletName)
+	}
+	printField("from", from)
+	switch {
+	case len(tx.Creates) > 0:
+		printField("creates", fmt.Sprintf("%s (%s)", tx.Creates, tx.Contract))
+	case len(tx.Contract) > 0:
+		printField("to", fmt.Sprintf("%s (%s)", tx.To, tx.Contract))
+	default:
+		to := tx.To
+		if walletName := spec.Wallets.NameOf(tx.To); len(walletName) > 0 {
+			to = fmt.Sprintf("%s (@%s)", tx.To, walletName)
+		} else if contractName := spec.Contracts.NameOf(tx.To); len(contractName) > 0 {
+			to = fmt.Sprintf("%s (%s)", tx.To, contractName)
+		} else if label, ok := spec.AddressLabel(common.HexToAddress(tx.To)); ok {
+			to = fmt.Sprintf("%s (%s)", tx.To, label)
+		}
+		printField("to", to)
+	}
+	if len(tx.Method) > 0 {
+		printField("method", fmt.Sprintf("%s(%s)", tx.Method, strings.Join(tx.Args, ", ")))
+	}
+	if len(tx.Value) > 0 {
+		printField("value", tx.Value+" wei")
+	}
+	printField("nonce", fmt.Sprint(tx.Nonce))
+	if len(tx.GasError) > 0 {
+		printField("gas", "unknown, "+tx.GasError)
+	} else {
+		printField("gas", fmt.Sprint(tx.Gas))
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/AtlantPlatform/ethfw"
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// PortfolioRecord is the report of balances --all-networks in the JSON and YAML output formats,
+// the balances of the wallets by network, the errors of the networks not queried and the totals.
+type PortfolioRecord struct {
+	Networks map[string][]*BalanceRecord `json:"networks" yaml:"networks"`
+	Errors   map[string]string           `json:"errors,omitempty" yaml:"errors,omitempty"`
+	Totals   []*PortfolioTotalRecord     `json:"totals" yaml:"totals"`
+}
+
+// PortfolioTotalRecord is the holdings of a wallet on all networks by asset symbol, in asset units.
+type PortfolioTotalRecord struct {
+	Name     string            `json:"name" yaml:"name"`
+	Balances map[string]string `json:"balances" yaml:"balances"`
+	Currency string            `json:"currency,omitempty" yaml:"currency,omitempty"`
+	Values   map[string]string `json:"values,omitempty" yaml:"values,omitempty"`
+	Total    string            `json:"total,omitempty" yaml:"total,omitempty"`
+}
+
+// readPortfolio reads the balances of the wallets on every network of the spec, in order of the names,
+// and aggregates them. The report filters of the networks apply unless unfiltered.
+func readPortfolio(spec *model.Spec, foldWrapped, unfiltered bool, currency string) *executor.Portfolio {
+	names := make([]string, 0, len(spec.Networks))
+	for name := range spec.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	keycache := ethfw.NewKeyCache()
+	networks := make([]*executor.NetworkBalances, 0, len(names))
+	for _, name := range names {
+		balances := networkBalances(name, foldWrapped, unfiltered, currency, keycache)
+		if balances.Err != nil {
+			log.WithError(balances.Err).WithField("network", name).Warningln("failed to read the balances of the network")
+		}
+		networks = append(networks, balances)
+	}
+	return executor.AggregateBalances(networks)
+}
+
+// networkBalances loads the spec with the overlay of the network and reads the balance matrix of its
+// wallets, valued in the fiat currency of the network config or the one set. A network that fails is
+// reported with the error, so the other networks are still aggregated.
+func networkBalances(name string, foldWrapped, unfiltered bool, currency string,
+	keycache ethfw.KeyCache) *executor.NetworkBalances {
+	balances := &executor.NetworkBalances{
+		Network: name,
+	}
+	group := *nodeGroup
+	defer func() {
+		*nodeGroup = group
+	}()
+	spec, ok := loadNetworkSpec(name)
+	if !ok {
+		balances.Err = errors.New("failed to load the spec")
+		return balances
+	}
+	ctx, ok := validateSpecWith(spec, "balances", []string{"balances"}, keycache)
+	if !ok {
+		balances.Err = errors.New("spec is invalid for the network")
+		if spec.NodesUnavailable() {
+			balances.Err = errors.New("nodes are unavailable")
+		}
+		return balances
+	}
+	if len(currency) > 0 {
+		if err := spec.Config.SetFiatCurrency(currency); err != nil {
+			balances.Err = err
+			return balances
+		}
+	}
+	if unfiltered {
+		spec.Config.ReportFilter = nil
+	}
+	exec, err := executor.New(ctx, spec)
+	if err != nil {
+		balances.Err = err
+		return balances
+	}
+	balances.Matrix = exec.Balances(ctx, foldWrapped)
+	if len(spec.Config.FiatCurrency) > 0 {
+		exec.ValueBalances(ctx, balances.Matrix)
+	}
+	exec.FilterBalances(balances.Matrix)
+	return balances
+}
+
+// portfolioAmount formats the sum of the amounts in asset units, empty if there's none.
+func portfolioAmount(amount *big.Rat) string {
+	if amount == nil {
+		return ""
+	}
+	return strings.TrimRight(strings.TrimRight(amount.FloatString(18), "0"), ".")
+}
+
+// writePortfolio writes the balances of each network followed by the totals of the wallets across
+// the networks to the output file, or to stdout if not set. The CSV has a row per wallet, asset and
+// network, the totals are the rows of the total network.
+func writePortfolio(format, path string, portfolio *executor.Portfolio) error {
+	w, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	switch format {
+	case OutputText:
+		for _, network := range portfolio.Networks {
+			fmt.Fprintf(w, "%s\n", network.Network)
+			if network.Err != nil {
+				fmt.Fprintf(w, "  error: %v\n\n", network.Err)
+				continue
+			}
+			if err := writeBalanceTable(w, network.Matrix, false); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "Total")
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprint(tw, "WALLET\t")
+		for _, asset := range portfolio.Assets {
+			fmt.Fprintf(tw, "%s\t", asset)
+		}
+		if len(portfolio.Currency) > 0 {
+			fmt.Fprintf(tw, "%s\t", strings.ToUpper(portfolio.Currency))
+		}
+		fmt.Fprintln(tw)
+		total := new(big.Rat)
+		for _, row := range portfolio.Totals {
+			fmt.Fprintf(tw, "@%s\t", row.Name)
+			for _, amount := range row.Amounts {
+				cell := portfolioAmount(amount)
+				if len(cell) == 0 {
+					cell = "0"
+				}
+				fmt.Fprintf(tw, "%s\t", cell)
+			}
+			if len(portfolio.Currency) > 0 {
+				fmt.Fprintf(tw, "%s\t", model.FormatFiat(row.Total))
+				total.Add(total, row.Total)
+			}
+			fmt.Fprintln(tw)
+		}
+		if len(portfolio.Currency) > 0 {
+			fmt.Fprintf(tw, "TOTAL\t%s%s\t\n", strings.Repeat("\t", len(portfolio.Assets)), model.FormatFiat(total))
+		}
+		return tw.Flush()
+	case OutputCSV:
+		csvWriter := csv.NewWriter(w)
+		header := []string{"network", "wallet", "name", "asset", "amount"}
+		if len(portfolio.Currency) > 0 {
+			header = append(header, portfolio.Currency)
+		}
+		if err := csvWriter.Write(header); err != nil {
+			return err
+		}
+		for _, network := range portfolio.Networks {
+			if network.Matrix == nil {
+				continue
+			}
+			for _, row := range network.Matrix.Rows {
+				for i, asset := range network.Matrix.Assets {
+					if row.Errors[i] != nil {
+						continue
+					}
+					record := []string{network.Network, row.Wallet, row.Name, asset, row.Amounts[i]}
+					if len(portfolio.Currency) > 0 {
+						var value string
+						if row.Values != nil && row.Values[i] != nil {
+							value = model.FormatFiat(row.Values[i])
+						}
+						record = append(record, value)
+					}
+					if err := csvWriter.Write(record); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		for _, row := range portfolio.Totals {
+			for i, asset := range portfolio.Assets {
+				if row.Amounts[i] == nil {
+					continue
+				}
+				record := []string{"total", "", row.Name, asset, portfolioAmount(row.Amounts[i])}
+				if len(portfolio.Currency) > 0 {
+					var value string
+					if row.Values[i] != nil {
+						value = model.FormatFiat(row.Values[i])
+					}
+					record = append(record, value)
+				}
+				if err := csvWriter.Write(record); err != nil {
+					return err
+				}
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	record := &PortfolioRecord{
+		Networks: make(map[string][]*BalanceRecord, len(portfolio.Networks)),
+		Totals:   make([]*PortfolioTotalRecord, 0, len(portfolio.Totals)),
+	}
+	for _, network := range portfolio.Networks {
+		if network.Err != nil {
+			if record.Errors == nil {
+				record.Errors = make(map[string]string)
+			}
+			record.Errors[network.Network] = network.Err.Error()
+			continue
+		}
+		record.Networks[network.Network] = balanceRecords(network.Matrix, false)
+	}
+	for _, row := range portfolio.Totals {
+		totalRecord := &PortfolioTotalRecord{
+			Name:     row.Name,
+			Balances: make(map[string]string, len(portfolio.Assets)),
+		}
+		if len(portfolio.Currency) > 0 {
+			totalRecord.Currency = portfolio.Currency
+			totalRecord.Values = make(map[string]string, len(portfolio.Assets))
+			totalRecord.Total = model.FormatFiat(row.Total)
+		}
+		for i, asset := range portfolio.Assets {
+			if row.Amounts[i] == nil {
+				continue
+			}
+			totalRecord.Balances[asset] = portfolioAmount(row.Amounts[i])
+			if len(portfolio.Currency) > 0 && row.Values[i] != nil {
+				totalRecord.Values[asset] = model.FormatFiat(row.Values[i])
+			}
+		}
+		record.Totals = append(record.Totals, totalRecord)
+	}
+	return writeStructured(w, format, record)
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
 )
 
 func prettifyValue(v interface{}) interface{} {
@@ -56,6 +58,20 @@ func prettifyValue(v interface{}) interface{} {
 		return vv
 	case uint64:
 		return vv
+	case *executor.Calldata:
+		return vv
+	case *executor.AccountProof:
+		return vv
+	case *executor.StorageValue:
+		return vv
+	case *executor.TraceFrame:
+		return vv
+	case *executor.FoundBlock:
+		return vv
+	case []*executor.PlannedTx:
+		return vv
+	case []*executor.PendingTx:
+		return vv
 	case nil:
 		return nil
 	default:
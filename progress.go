@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+const (
+	progressBarWidth = 24
+	progressInterval = 500 * time.Millisecond
+)
+
+// progressBar shows the progress of the target on a single updating line: the items done
+// out of the total, the transactions sent per second, the time left and the failures so far.
+// The items are wallets the commands fan out to and rows of foreach, so long targets don't
+// flood the terminal. Log lines and results are printed above the line.
+type progressBar struct {
+	spec   *model.Spec
+	target string
+	w      *os.File
+
+	mux      *sync.Mutex
+	start    time.Time
+	total    int
+	done     int
+	running  int
+	failed   int
+	failing  int
+	txs      int
+	sending  int
+	stopC    chan struct{}
+	drawn    bool
+	paused   bool
+	finished bool
+}
+
+func newProgressBar(spec *model.Spec, target string) *progressBar {
+	return &progressBar{
+		spec:   spec,
+		target: target,
+		w:      os.Stderr,
+		mux:    new(sync.Mutex),
+		total:  spec.TargetItems(target),
+		stopC:  make(chan struct{}),
+	}
+}
+
+// Start redirects the log output above the line and starts refreshing the rate and the time left.
+func (p *progressBar) Start() {
+	p.start = time.Now()
+	log.SetOutput(p)
+	go func() {
+		t := time.NewTicker(progressInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				p.mux.Lock()
+				p.renderLocked()
+				p.mux.Unlock()
+			case <-p.stopC:
+				return
+			}
+		}
+	}()
+}
+
+// Stop erases the line and restores the log output.
+func (p *progressBar) Stop() {
+	log.SetOutput(os.Stderr)
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.finished {
+		return
+	}
+	p.finished = true
+	close(p.stopC)
+	p.clearLocked()
+}
+
+// Item counts the item of a running command, it implements executor.ProgressFunc.
+func (p *progressBar) Item(result *executor.CommandResult) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.running++
+	if result.Error != nil {
+		p.failing++
+	}
+	txs := countTxs(result)
+	p.txs += txs
+	p.sending += txs
+	p.renderLocked()
+}
+
+// Done counts the command finished, the items of the command that haven't been reported
+// while it was running, e.g. not fanned out or restored from the journal, are counted now.
+func (p *progressBar) Done(results []*executor.CommandResult) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	items := len(results)
+	if len(results) > 0 {
+		if n := p.spec.CommandItems(results[0].Name); n > items {
+			items = n
+		}
+	}
+	var errors, txs int
+	for _, result := range results {
+		if result.Error != nil {
+			errors++
+		}
+		txs += countTxs(result)
+	}
+	if txs > p.sending {
+		p.txs += txs - p.sending
+		p.sending = 0
+	} else {
+		p.sending -= txs
+	}
+	p.done += items
+	p.failed += errors
+	if p.running -= len(results); p.running < 0 {
+		p.running = 0
+	}
+	if p.failing -= errors; p.failing < 0 {
+		p.failing = 0
+	}
+	if p.done > p.total {
+		// compensating and onError commands are not known ahead
+		p.total = p.done
+	}
+	p.renderLocked()
+}
+
+// Print prints above the line, the line is not drawn until fn returns.
+func (p *progressBar) Print(fn func()) {
+	p.mux.Lock()
+	p.clearLocked()
+	p.paused = true
+	p.mux.Unlock()
+
+	fn()
+
+	p.mux.Lock()
+	p.paused = false
+	p.renderLocked()
+	p.mux.Unlock()
+}
+
+// Write prints the log output above the line.
+func (p *progressBar) Write(data []byte) (int, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.clearLocked()
+	n, err := p.w.Write(data)
+	p.renderLocked()
+	return n, err
+}
+
+func (p *progressBar) clearLocked() {
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\x1b[K")
+		p.drawn = false
+	}
+}
+
+func (p *progressBar) renderLocked() {
+	if p.finished || p.paused {
+		return
+	}
+	done := p.done + p.running
+	if done > p.total {
+		done = p.total
+	}
+	var percent int
+	if p.total > 0 {
+		percent = done * 100 / p.total
+	}
+	filled := percent * progressBarWidth / 100
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled)
+	elapsed := time.Since(p.start)
+	line := fmt.Sprintf("%s [%s] %d/%d", p.target, bar, done, p.total)
+	if p.txs > 0 {
+		line += fmt.Sprintf(", %.1f tx/s", float64(p.txs)/elapsed.Seconds())
+	}
+	if done > 0 && done < p.total {
+		left := time.Duration(float64(elapsed) / float64(done) * float64(p.total-done))
+		line += fmt.Sprintf(", ETA %s", left.Round(time.Second))
+	}
+	if failed := p.failed + p.failing; failed > 0 {
+		line += fmt.Sprintf(", %d failed", failed)
+	}
+	fmt.Fprintf(p.w, "\r\x1b[K%s", line)
+	p.drawn = true
+}
+
+// countTxs returns the number of transactions sent by the command for the result.
+func countTxs(result *executor.CommandResult) int {
+	if result.Error != nil || len(result.Skipped) > 0 {
+		return 0
+	}
+	var txs int
+	for _, handle := range result.TxHandles() {
+		if hash, ok := handle.(string); ok && strings.HasPrefix(hash, "tx:") {
+			txs++
+		}
+	}
+	return txs
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+)
+
+// ReconcileRecord is an unmatched entry of the reconcile command: an expected transfer of the ledger
+// not found on chain, or a transfer on chain not in the ledger.
+type ReconcileRecord struct {
+	Side         string `json:"side" yaml:"side"`
+	Line         int    `json:"line,omitempty" yaml:"line,omitempty"`
+	Reference    string `json:"reference,omitempty" yaml:"reference,omitempty"`
+	Time         string `json:"time" yaml:"time"`
+	Wallet       string `json:"wallet" yaml:"wallet"`
+	Direction    string `json:"direction" yaml:"direction"`
+	Counterparty string `json:"counterparty,omitempty" yaml:"counterparty,omitempty"`
+	Asset        string `json:"asset" yaml:"asset"`
+	Amount       string `json:"amount" yaml:"amount"`
+	TxHash       string `json:"txHash,omitempty" yaml:"txHash,omitempty"`
+}
+
+// ReconcileReport is the report of the reconcile command in the JSON and YAML output formats.
+type ReconcileReport struct {
+	Matched   int                `json:"matched" yaml:"matched"`
+	Unmatched []*ReconcileRecord `json:"unmatched" yaml:"unmatched"`
+}
+
+const (
+	reconcileSideLedger = "ledger"
+	reconcileSideChain  = "chain"
+)
+
+var reconcileColumns = []string{
+	"side", "line", "reference", "time", "wallet", "direction", "counterparty", "asset", "amount", "txHash",
+}
+
+// writeReconciliation writes the unmatched entries of both sides to the output file, or to stdout
+// if not set, the ledger entries first. The text and the JSON and YAML formats have the count of
+// the matched ones too.
+func writeReconciliation(format, path string, rec *executor.Reconciliation) error {
+	w, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	records := make([]*ReconcileRecord, 0, len(rec.Ledger)+len(rec.Chain))
+	for _, entry := range rec.Ledger {
+		timestamp := entry.Time.Format(time.RFC3339)
+		if entry.DateOnly {
+			timestamp = entry.Time.Format("2006-01-02")
+		}
+		records = append(records, &ReconcileRecord{
+			Side:         reconcileSideLedger,
+			Line:         entry.Line,
+			Reference:    entry.Reference,
+			Time:         timestamp,
+			Wallet:       entry.Wallet,
+			Direction:    entry.Direction,
+			Counterparty: entry.Counterparty,
+			Asset:        entry.Asset,
+			Amount:       entry.Amount,
+			TxHash:       entry.TxHash,
+		})
+	}
+	for _, entry := range rec.Chain {
+		records = append(records, &ReconcileRecord{
+			Side:         reconcileSideChain,
+			Time:         entry.Time.Format(time.RFC3339),
+			Wallet:       entry.Wallet,
+			Direction:    entry.Direction,
+			Counterparty: entry.Counterparty,
+			Asset:        entry.Asset,
+			Amount:       entry.Amount,
+			TxHash:       entry.TxHash,
+		})
+	}
+	switch format {
+	case OutputText:
+		fmt.Fprintf(w, "%d matched, %d unmatched in the ledger, %d unmatched on chain\n",
+			rec.Matched, len(rec.Ledger), len(rec.Chain))
+		if len(records) == 0 {
+			return nil
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "  SIDE\tENTRY\tTIME\tWALLET\tDIRECTION\tCOUNTERPARTY\tASSET\tAMOUNT")
+		for _, record := range records {
+			entry := record.TxHash
+			if record.Side == reconcileSideLedger {
+				entry = "line " + strconv.Itoa(record.Line)
+				if len(record.Reference) > 0 {
+					entry += " (" + record.Reference + ")"
+				}
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t@%s\t%s\t%s\t%s\t%s\n", record.Side, entry, record.Time,
+				record.Wallet, record.Direction, record.Counterparty, record.Asset, record.Amount)
+		}
+		return tw.Flush()
+	case OutputCSV:
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(reconcileColumns); err != nil {
+			return err
+		}
+		for _, record := range records {
+			var line string
+			if record.Line > 0 {
+				line = strconv.Itoa(record.Line)
+			}
+			err := csvWriter.Write([]string{record.Side, line, record.Reference, record.Time, record.Wallet,
+				record.Direction, record.Counterparty, record.Asset, record.Amount, record.TxHash})
+			if err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	report := &ReconcileReport{
+		Matched:   rec.Matched,
+		Unmatched: records,
+	}
+	return writeStructured(w, format, report)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// monitorRun keeps monitoring the transactions of the run for reorgs, reporting the ones that have changed
+// their block or status, or have been dropped. It returns exitReverted if a transaction has failed after
+// the reorg, its nonce has been taken by another transaction or it's dropped and can't be sent again,
+// so the CI pipelines don't take it as done.
+func monitorRun(ctx model.AppContext, exec *executor.Executor, journal *model.RunJournal, depth uint64) int {
+	reorgLog := log.WithFields(log.Fields{
+		"run":   journal.ID,
+		"depth": depth,
+	})
+	reorgLog.Infoln("monitoring the transactions of the run for reorgs")
+	code := exitOK
+	err := exec.MonitorReorgs(ctx, journal, depth, func(change *executor.ReorgChange) {
+		changeLog := log.WithFields(log.Fields{
+			"command": change.Command,
+			"txHash":  change.TxHash,
+			"block":   change.FromBlock,
+		})
+		switch {
+		case change.Replaced:
+			changeLog.Errorln("transaction dropped by a reorg has been replaced by another one with its nonce")
+			code = exitReverted
+		case change.Lost:
+			changeLog.Errorln("transaction dropped by a reorg can't be sent again, the node doesn't know it")
+			code = exitReverted
+		case change.Rebroadcast:
+			changeLog.Warningln("transaction dropped by a reorg, sent again")
+		default:
+			changeLog = changeLog.WithFields(log.Fields{
+				"newBlock":  change.ToBlock,
+				"status":    change.FromStatus,
+				"newStatus": change.ToStatus,
+			})
+			if change.ToStatus == 0 && change.FromStatus != 0 {
+				changeLog.Errorln("transaction has failed after a reorg")
+				code = exitReverted
+			} else {
+				changeLog.Warningln("transaction has been included in another block after a reorg")
+			}
+		}
+	})
+	if err != nil {
+		reorgLog.WithError(err).Errorln("failed to monitor the transactions")
+		if code == exitOK {
+			code = exitFailed
+		}
+	} else if ctx.Err() == nil {
+		reorgLog.Infoln("transactions of the run are settled")
+	}
+	return code
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+)
+
+// scanColumns are the CSV columns of the scan rows, the args of events are a JSON cell.
+var scanColumns = []string{
+	"block", "extractor", "txHash", "logIndex", "address", "event", "args",
+	"from", "to", "value", "method", "error", "asset", "balance",
+}
+
+// scanWriter appends the rows of a scan to its output, JSON lines or CSV by the extension.
+type scanWriter struct {
+	f   *os.File
+	csv *csv.Writer
+}
+
+// newScanWriter opens the output of the scan for appending, or truncates it when the scan starts
+// over. The CSV header is written to the empty file only, so the resumed scans continue the table.
+func newScanWriter(specDir, path string, truncate bool) (*scanWriter, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(specDir, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if truncate {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	sw := &scanWriter{
+		f: f,
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		sw.csv = csv.NewWriter(f)
+		if info, err := f.Stat(); err == nil && info.Size() == 0 {
+			if err := sw.csv.Write(scanColumns); err != nil {
+				f.Close()
+				return nil, err
+			}
+			sw.csv.Flush()
+		}
+	}
+	return sw, nil
+}
+
+func (sw *scanWriter) Write(rows []*executor.ScanRow) error {
+	for _, row := range rows {
+		if sw.csv == nil {
+			data, err := json.Marshal(row)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(sw.f, string(data)); err != nil {
+				return err
+			}
+			continue
+		}
+		var logIndex, args string
+		if row.LogIndex != nil {
+			logIndex = strconv.FormatUint(uint64(*row.LogIndex), 10)
+		}
+		if len(row.Args) > 0 {
+			data, err := json.Marshal(row.Args)
+			if err != nil {
+				return err
+			}
+			args = string(data)
+		}
+		record := []string{
+			strconv.FormatUint(row.Block, 10), row.Extractor, row.TxHash, logIndex, row.Address, row.Event, args,
+			row.From, row.To, row.Value, row.Method, row.Error, row.Asset, row.Balance,
+		}
+		if err := sw.csv.Write(record); err != nil {
+			return err
+		}
+	}
+	if sw.csv != nil {
+		sw.csv.Flush()
+		if err := sw.csv.Error(); err != nil {
+			return err
+		}
+	}
+	// the rows are on disk before the cursor moves past them
+	return sw.f.Sync()
+}
+
+func (sw *scanWriter) Close() error {
+	return sw.f.Close()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/csv"
+	"strings"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// HolderRecord is the holder of the snapshot command in the machine-readable output formats.
+type HolderRecord struct {
+	Address  string   `json:"address" yaml:"address"`
+	Amount   string   `json:"amount" yaml:"amount"`
+	TokenIDs []string `json:"tokenIds,omitempty" yaml:"tokenIds,omitempty"`
+}
+
+// writeSnapshot writes the holders to the output file, or to stdout if not set, as CSV with the address
+// and amount columns of the airdrop and merkle commands, unless the output format is JSON or YAML.
+// The amounts are in token units, the ERC-721 holders have the space-separated tokenIds column.
+func writeSnapshot(format, path string, holders []*executor.Holder, decimals int, nft bool) error {
+	w, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	records := make([]*HolderRecord, 0, len(holders))
+	for _, holder := range holders {
+		record := &HolderRecord{
+			Address: holder.Address,
+			Amount:  model.FormatUnits(holder.Balance, decimals),
+		}
+		for _, id := range holder.TokenIDs {
+			record.TokenIDs = append(record.TokenIDs, id.String())
+		}
+		records = append(records, record)
+	}
+	if format == OutputJSON || format == OutputYAML {
+		return writeStructured(w, format, records)
+	}
+	csvWriter := csv.NewWriter(w)
+	columns := []string{"address", "amount"}
+	if nft {
+		columns = append(columns, "tokenIds")
+	}
+	if err := csvWriter.Write(columns); err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := []string{record.Address, record.Amount}
+		if nft {
+			row = append(row, strings.Join(record.TokenIDs, " "))
+		}
+		if err := csvWriter.Write(row); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
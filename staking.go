@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// StakingRecord is the staking report in the JSON and YAML output formats, the amounts are in ether
+// and asset units.
+type StakingRecord struct {
+	Positions []*StakingPositionRecord `json:"positions" yaml:"positions"`
+	Wallets   []*StakedWalletRecord    `json:"wallets" yaml:"wallets"`
+}
+
+// StakingPositionRecord is a validator or a liquid staking token of a stake, Ether is what it's worth.
+type StakingPositionRecord struct {
+	Stake  string `json:"stake" yaml:"stake"`
+	Wallet string `json:"wallet" yaml:"wallet"`
+	Kind   string `json:"kind" yaml:"kind"`
+	ID     string `json:"id" yaml:"id"`
+	Status string `json:"status,omitempty" yaml:"status,omitempty"`
+	Amount string `json:"amount,omitempty" yaml:"amount,omitempty"`
+	Rate   string `json:"rate,omitempty" yaml:"rate,omitempty"`
+	Ether  string `json:"ether,omitempty" yaml:"ether,omitempty"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// StakedWalletRecord is the ether of a wallet, liquid and staked.
+type StakedWalletRecord struct {
+	Name       string `json:"name" yaml:"name"`
+	Liquid     string `json:"liquid,omitempty" yaml:"liquid,omitempty"`
+	Validators string `json:"validators" yaml:"validators"`
+	LSTs       string `json:"lsts" yaml:"lsts"`
+	Total      string `json:"total" yaml:"total"`
+	Error      string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+var stakingColumns = []string{"stake", "wallet", "kind", "id", "status", "amount", "rate", "ether", "error"}
+
+func stakingPositionRecord(position *executor.StakingPosition) *StakingPositionRecord {
+	record := &StakingPositionRecord{
+		Stake:  position.Stake,
+		Wallet: position.Wallet,
+		Kind:   position.Kind,
+		ID:     position.ID,
+		Status: position.Status,
+	}
+	if position.Err != nil {
+		record.Error = position.Err.Error()
+		return record
+	}
+	record.Amount = model.FormatUnits(position.Amount, position.Decimals)
+	if position.Rate != nil {
+		record.Rate = model.FormatUnits(position.Rate, 18)
+	}
+	record.Ether = model.FormatUnits(position.Ether, 18)
+	return record
+}
+
+func stakedWalletRecord(wallet *executor.StakedWallet) *StakedWalletRecord {
+	record := &StakedWalletRecord{
+		Name:       wallet.Name,
+		Validators: model.FormatUnits(wallet.Validators, 18),
+		LSTs:       model.FormatUnits(wallet.LSTs, 18),
+		Total:      model.FormatUnits(wallet.Total, 18),
+	}
+	if wallet.Err != nil {
+		record.Error = wallet.Err.Error()
+	} else {
+		record.Liquid = model.FormatUnits(wallet.Liquid, 18)
+	}
+	return record
+}
+
+// writeStaking writes the staking positions followed by the ether of their wallets to the output file,
+// or to stdout if not set. The CSV has a row per position and a liquid row per wallet, so the ether
+// of a wallet is the sum of its rows.
+func writeStaking(format, path string, report *executor.StakingReport) error {
+	w, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	record := &StakingRecord{
+		Positions: make([]*StakingPositionRecord, 0, len(report.Positions)),
+		Wallets:   make([]*StakedWalletRecord, 0, len(report.Wallets)),
+	}
+	for _, position := range report.Positions {
+		record.Positions = append(record.Positions, stakingPositionRecord(position))
+	}
+	for _, wallet := range report.Wallets {
+		record.Wallets = append(record.Wallets, stakedWalletRecord(wallet))
+	}
+	switch format {
+	case OutputText:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "STAKE\tWALLET\tKIND\tID\tSTATUS\tAMOUNT\tRATE\tETH")
+		for _, position := range record.Positions {
+			if len(position.Error) > 0 {
+				fmt.Fprintf(tw, "%s\t@%s\t%s\t%s\terror: %s\t\t\t\n", position.Stake, position.Wallet,
+					position.Kind, position.ID, position.Error)
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t@%s\t%s\t%s\t%s\t%s\t%s\t%s\n", position.Stake, position.Wallet, position.Kind,
+				position.ID, position.Status, position.Amount, position.Rate, position.Ether)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "WALLET\tLIQUID ETH\tVALIDATORS\tLSTS\tTOTAL ETH\t")
+		for _, wallet := range record.Wallets {
+			liquid := wallet.Liquid
+			if len(wallet.Error) > 0 {
+				liquid = "error"
+			}
+			fmt.Fprintf(tw, "@%s\t%s\t%s\t%s\t%s\t\n", wallet.Name, liquid, wallet.Validators, wallet.LSTs, wallet.Total)
+		}
+		return tw.Flush()
+	case OutputCSV:
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(stakingColumns); err != nil {
+			return err
+		}
+		for _, position := range record.Positions {
+			err := csvWriter.Write([]string{position.Stake, position.Wallet, position.Kind, position.ID,
+				position.Status, position.Amount, position.Rate, position.Ether, position.Error})
+			if err != nil {
+				return err
+			}
+		}
+		for _, wallet := range record.Wallets {
+			err := csvWriter.Write([]string{"", wallet.Name, "liquid", "ETH", "",
+				wallet.Liquid, "", wallet.Liquid, wallet.Error})
+			if err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return writeStructured(w, format, record)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorBold   = "\x1b[1m"
+	colorReset  = "\x1b[0m"
+)
+
+// useColors reports whether the output is colored: unless --no-color is set, when it's a terminal.
+func useColors(f *os.File) bool {
+	return !*noColor && isTerminal(f)
+}
+
+func isTerminal(f *os.File) bool {
+	return terminal.IsTerminal(int(f.Fd()))
+}
+
+type walletSpending struct {
+	Address string
+	Txs     int
+	GasUsed uint64
+	Spent   *big.Int
+}
+
+type summaryTx struct {
+	Command string
+	Hash    string
+}
+
+// summaryEtherPrice is the current price of ether the spending of the run is valued at,
+// nil if no fiatCurrency is set in config or the price is not known.
+func summaryEtherPrice(ctx context.Context, spec *model.Spec, exec *executor.Executor) *big.Rat {
+	if len(spec.Config.FiatCurrency) == 0 {
+		return nil
+	}
+	price, err := exec.FiatPrice(ctx, executor.EtherAsset, time.Time{})
+	if err != nil {
+		log.WithError(err).Warningln("no price of ether, the spending is not valued")
+		return nil
+	}
+	return price
+}
+
+// printSummary prints the summary of the target run: the commands succeeded, failed and skipped,
+// the transactions sent, gas and ether spent by each wallet, valued in the fiat currency at the
+// ether price if known, the links to the block explorer, and the calls made to the nodes.
+func printSummary(w *os.File, spec *model.Spec, title string,
+	results [][]*executor.CommandResult, etherPrice *big.Rat, usage []*model.NodeUsage) {
+	colored := useColors(w)
+	color := func(c, s string) string {
+		if !colored {
+			return s
+		}
+		return c + s + colorReset
+	}
+	var (
+		succeeded, failed, skipped int
+		txs                        []*summaryTx
+	)
+	wallets := make(map[string]*walletSpending)
+	for _, cmdResults := range results {
+		if len(cmdResults) == 0 {
+			continue
+		}
+		name := cmdResults[0].Name
+		switch {
+		case hasResultErrors(cmdResults):
+			failed++
+		case isSkipped(cmdResults):
+			skipped++
+		default:
+			succeeded++
+		}
+		cmdSpec, isWrite := spec.WriteCmds[name]
+		if !isWrite {
+			continue
+		}
+		for _, result := range cmdResults {
+			var hashes []string
+			for _, handle := range result.TxHandles() {
+				if hash, ok := handle.(string); ok && result.Error == nil {
+					hashes = append(hashes, strings.TrimPrefix(hash, "tx:"))
+				}
+			}
+			if len(hashes) == 0 {
+				continue
+			}
+			address := result.Wallet
+			if len(address) == 0 && cmdSpec.MatchingWallet() != nil {
+				address = cmdSpec.MatchingWallet().Address
+			}
+			key := strings.ToLower(address)
+			spending, ok := wallets[key]
+			if !ok {
+				spending = &walletSpending{
+					Address: address,
+					Spent:   new(big.Int),
+				}
+				wallets[key] = spending
+			}
+			spending.Txs += len(hashes)
+			spending.GasUsed += result.GasUsed
+			if result.Spent != nil {
+				spending.Spent.Add(spending.Spent, result.Spent)
+			}
+			for _, hash := range hashes {
+				txs = append(txs, &summaryTx{
+					Command: name,
+					Hash:    hash,
+				})
+			}
+		}
+	}
+	fmt.Fprintf(w, "\n%s\n", color(colorBold, "Summary of "+title))
+	fmt.Fprintf(w, "  %s, %s, %s\n",
+		color(colorGreen, fmt.Sprintf("%d succeeded", succeeded)),
+		color(colorRed, fmt.Sprintf("%d failed", failed)),
+		color(colorYellow, fmt.Sprintf("%d skipped", skipped)))
+	if len(wallets) > 0 {
+		addresses := make([]string, 0, len(wallets))
+		for address := range wallets {
+			addresses = append(addresses, address)
+		}
+		sort.Strings(addresses)
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		if etherPrice != nil {
+			fmt.Fprintf(tw, "  WALLET\tTXS\tGAS\tSPENT (ETH)\tSPENT (%s)\n", strings.ToUpper(spec.Config.FiatCurrency))
+		} else {
+			fmt.Fprintln(tw, "  WALLET\tTXS\tGAS\tSPENT (ETH)")
+		}
+		for _, address := range addresses {
+			spending := wallets[address]
+			label := spending.Address
+			if name := spec.Wallets.NameOf(spending.Address); len(name) > 0 {
+				label = fmt.Sprintf("@%s %s", name, spending.Address)
+			}
+			if etherPrice != nil {
+				spent := model.FormatEther(spending.Spent)
+				value, _ := new(big.Rat).SetString(spent)
+				fmt.Fprintf(tw, "  %s\t%d\t%d\t%s\t%s\n", label, spending.Txs, spending.GasUsed,
+					spent, model.FormatFiat(value.Mul(value, etherPrice)))
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%d\t%d\t%s\n", label, spending.Txs,
+				spending.GasUsed, model.FormatEther(spending.Spent))
+		}
+		tw.Flush()
+	}
+	if len(txs) > 0 {
+		fmt.Fprintln(w)
+		printSummaryTxs(w, spec, txs)
+	}
+	if len(usage) > 0 {
+		fmt.Fprintln(w)
+		printSummaryUsage(w, usage, color)
+	}
+}
+
+func printSummaryUsage(w io.Writer, usage []*model.NodeUsage, color func(c, s string) string) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  NODE\tCALLS\tCOMPUTE UNITS")
+	for _, nodeUsage := range usage {
+		units := fmt.Sprintf("%d", nodeUsage.ComputeUnits)
+		if nodeUsage.Quota > 0 {
+			units = fmt.Sprintf("%d of %d", nodeUsage.ComputeUnits, nodeUsage.Quota)
+			if nodeUsage.ComputeUnits >= nodeUsage.Quota {
+				units = color(colorYellow, units)
+			}
+		}
+		fmt.Fprintf(tw, "  %s\t%d\t%s\n", nodeUsage.Node, nodeUsage.Calls, units)
+	}
+	tw.Flush()
+}
+
+func printSummaryTxs(w io.Writer, spec *model.Spec, txs []*summaryTx) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  COMMAND\tTRANSACTION")
+	for _, tx := range txs {
+		link := tx.Hash
+		if txURL := spec.Config.ExplorerTxURL(tx.Hash); len(txURL) > 0 {
+			link = txURL
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", tx.Command, link)
+	}
+	tw.Flush()
+}
+
+func hasResultErrors(results []*executor.CommandResult) bool {
+	for _, result := range results {
+		if result.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func isSkipped(results []*executor.CommandResult) bool {
+	for _, result := range results {
+		if len(result.Skipped) == 0 || result.Result != nil {
+			return false
+		}
+	}
+	return true
+}
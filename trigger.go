@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// triggerTarget runs the target for each event matching its trigger, as the new blocks arrive, until
+// interrupted. The event is referenced by the commands as @event, the results of each run are printed
+// once it's done, a failed run is reported and the next event is awaited.
+func triggerTarget(ctx model.AppContext, exec *executor.Executor, spec *model.Spec, name string, trigger *model.Trigger) {
+	triggerLog := log.WithFields(log.Fields{
+		"target": name,
+		"on":     spec.Hooks[name].On,
+	})
+	triggerLog.Println("awaiting the trigger events")
+	err := exec.WatchEvents(ctx, trigger.ABIEvent(), trigger.Addresses(), trigger.Filters, func(event *executor.LogEvent) {
+		eventLog := triggerLog.WithFields(log.Fields{
+			"block":  event.Block,
+			"txHash": event.TxHash,
+		})
+		eventLog.WithField("event", event.Event.Event).Infoln("target triggered")
+		exec.SetTriggerEvent(event)
+		resultsC := make(chan []*executor.CommandResult, 100)
+		go exec.RunTarget(ctx, name, resultsC)
+		var all [][]*executor.CommandResult
+		for results := range resultsC {
+			all = append(all, results)
+			if !structuredOutput() && !*quiet {
+				fmt.Printf("%s:\n", results[0].Name)
+				exportResultsText(spec, results, "\t")
+			}
+			reportExpectations(results[0].Name, results)
+		}
+		if structuredOutput() {
+			exportResults(ctx, spec, "", all)
+		}
+		if code := exitCode(all, false); code != exitOK {
+			eventLog.WithField("code", code).Errorln("triggered run has failed")
+		}
+	})
+	if err != nil {
+		triggerLog.WithError(err).Fatalln("failed to watch the trigger events")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// VestingRecord is a vesting schedule in the machine-readable output formats, the amounts are in
+// asset units. NextUnlock is the cliff not reached yet, with the amount unlocked at it.
+type VestingRecord struct {
+	Name             string `json:"name" yaml:"name"`
+	Kind             string `json:"kind" yaml:"kind"`
+	Wallet           string `json:"wallet" yaml:"wallet"`
+	Asset            string `json:"asset" yaml:"asset"`
+	Start            string `json:"start" yaml:"start"`
+	Cliff            string `json:"cliff" yaml:"cliff"`
+	End              string `json:"end" yaml:"end"`
+	Total            string `json:"total" yaml:"total"`
+	Released         string `json:"released" yaml:"released"`
+	Releasable       string `json:"releasable" yaml:"releasable"`
+	Locked           string `json:"locked" yaml:"locked"`
+	NextUnlock       string `json:"nextUnlock,omitempty" yaml:"nextUnlock,omitempty"`
+	NextUnlockAmount string `json:"nextUnlockAmount,omitempty" yaml:"nextUnlockAmount,omitempty"`
+}
+
+var vestingColumns = []string{
+	"name", "kind", "wallet", "asset", "start", "cliff", "end", "total",
+	"released", "releasable", "locked", "nextUnlock", "nextUnlockAmount",
+}
+
+// vestingRecord converts the schedule into the record, with the next unlock after now.
+func vestingRecord(schedule *executor.VestingSchedule, now time.Time) *VestingRecord {
+	record := &VestingRecord{
+		Name:       schedule.Name,
+		Kind:       schedule.Kind,
+		Wallet:     schedule.Wallet,
+		Asset:      schedule.Asset,
+		Start:      schedule.Start.Format(time.RFC3339),
+		Cliff:      schedule.Cliff.Format(time.RFC3339),
+		End:        schedule.End.Format(time.RFC3339),
+		Total:      model.FormatUnits(schedule.Total, schedule.Decimals),
+		Released:   model.FormatUnits(schedule.Released, schedule.Decimals),
+		Releasable: model.FormatUnits(schedule.Releasable, schedule.Decimals),
+		Locked:     model.FormatUnits(schedule.Locked(), schedule.Decimals),
+	}
+	if unlock, amount := schedule.NextUnlock(now); amount != nil {
+		record.NextUnlock = unlock.Format(time.RFC3339)
+		record.NextUnlockAmount = model.FormatUnits(amount, schedule.Decimals)
+	}
+	return record
+}
+
+// writeVesting writes the vesting schedules to the output file, or to stdout if not set. The text
+// has the next unlock of each schedule: the date and the amount of the cliff, streaming until the
+// end, or vested.
+func writeVesting(format, path string, schedules []*executor.VestingSchedule) error {
+	w, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	now := time.Now().UTC()
+	records := make([]*VestingRecord, 0, len(schedules))
+	for _, schedule := range schedules {
+		records = append(records, vestingRecord(schedule, now))
+	}
+	switch format {
+	case OutputText:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "SCHEDULE\tWALLET\tASSET\tSTART\tCLIFF\tEND\tRELEASED\tRELEASABLE\tLOCKED\tNEXT UNLOCK")
+		for i, record := range records {
+			schedule := schedules[i]
+			next := "vested"
+			if len(record.NextUnlock) > 0 {
+				next = fmt.Sprintf("%s (%s)", schedule.Cliff.Format("2006-01-02"), record.NextUnlockAmount)
+			} else if now.Before(schedule.End) {
+				next = "streaming until " + schedule.End.Format("2006-01-02")
+			}
+			fmt.Fprintf(tw, "%s\t@%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", record.Name, record.Wallet, record.Asset,
+				schedule.Start.Format("2006-01-02"), schedule.Cliff.Format("2006-01-02"), schedule.End.Format("2006-01-02"),
+				record.Released, record.Releasable, record.Locked, next)
+		}
+		return tw.Flush()
+	case OutputCSV:
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(vestingColumns); err != nil {
+			return err
+		}
+		for _, record := range records {
+			err := csvWriter.Write([]string{record.Name, record.Kind, record.Wallet, record.Asset, record.Start,
+				record.Cliff, record.End, record.Total, record.Released, record.Releasable, record.Locked,
+				record.NextUnlock, record.NextUnlockAmount})
+			if err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+	return writeStructured(w, format, records)
+}
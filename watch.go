@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/AtlantPlatform/ethereum-playbook/executor"
+	"github.com/AtlantPlatform/ethereum-playbook/model"
+)
+
+// watchTarget re-runs the read-only target on new blocks, or every nth block. The first run
+// prints all the values, the next ones print the values that changed since the previous run.
+func watchTarget(ctx model.AppContext, exec *executor.Executor, spec *model.Spec, name string, every uint64) {
+	watchLog := log.WithField("target", name)
+	previous := make(map[string]string)
+	err := exec.WatchBlocks(ctx, every, func(block uint64) {
+		resultsC := make(chan []*executor.CommandResult, 100)
+		go exec.RunTarget(ctx, name, resultsC)
+		var (
+			changes []string
+			first   = len(previous) == 0
+		)
+		occurrences := make(map[string]int)
+		for results := range resultsC {
+			cmdName := results[0].Name
+			// the same command may run more than once within the target
+			if occurrences[cmdName]++; occurrences[cmdName] > 1 {
+				cmdName = fmt.Sprintf("%s#%d", cmdName, occurrences[cmdName])
+			}
+			for i, result := range results {
+				key := cmdName
+				if len(result.Wallet) > 0 {
+					key = fmt.Sprintf("%s (@%s)", cmdName, spec.Wallets.NameOf(result.Wallet))
+				} else if len(results) > 1 {
+					key = fmt.Sprintf("%s[%d]", cmdName, i)
+				}
+				value := watchValue(result)
+				if old, ok := previous[key]; first || !ok {
+					changes = append(changes, fmt.Sprintf("%s: %s", key, value))
+				} else if old != value {
+					changes = append(changes, fmt.Sprintf("%s: %s -> %s", key, old, value))
+				}
+				previous[key] = value
+			}
+		}
+		if len(changes) == 0 {
+			watchLog.WithField("block", block).Debugln("no changes")
+			return
+		}
+		fmt.Printf("block %d:\n", block)
+		for _, change := range changes {
+			fmt.Printf("\t%s\n", change)
+		}
+	})
+	if err != nil {
+		watchLog.WithError(err).Fatalln("failed to watch new blocks")
+	}
+}
+
+// watchValue formats the result as compact JSON, so the values are compared and printed on one line.
+func watchValue(result *executor.CommandResult) string {
+	var v interface{}
+	switch {
+	case result.Error != nil:
+		v = &ErrorObject{Error: result.Error.Error()}
+	case len(result.Skipped) > 0:
+		v = &SkippedObject{Skipped: result.Skipped}
+	default:
+		v = prettify(result.Result)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}